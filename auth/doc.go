@@ -1,8 +1,14 @@
 /*
 Package auth defines potentially multiple mechanisms to determine whether supplied
 user credentials in an IMAP session can be found in a defined user information system.
-Examples include an authenticator based on a user table in a PostgreSQL database and
-a simple (potentially insecure) plain user text file. It is easily possible to implement
-new authenticators that fit specific requirements.
+Examples include an authenticator based on a user table in a PostgreSQL database, one
+binding to an LDAP directory, and a simple (potentially insecure) plain user text file.
+It is easily possible to implement new authenticators that fit specific requirements.
+
+The SASL mechanisms available to the IMAP AUTHENTICATE command register themselves
+with this package. Each authenticator declares the mechanisms it is able to verify
+and implements the verifier interfaces these mechanisms require. Users of single
+sign-on providers authenticate with OAuth 2.0 bearer tokens via OAUTHBEARER or XOAUTH2,
+which the OAuth authenticator validates against a JWKS or an introspection endpoint.
 */
 package auth
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"fmt"
+
+	"crypto/x509"
+)
+
+// Structs
+
+// externalExchange is the server side of EXTERNAL,
+// which authenticates the user named in the client
+// certificate presented during the TLS handshake.
+type externalExchange struct {
+	verifier IdentityVerifier
+	conn     *ConnState
+	asked    bool
+}
+
+// Functions
+
+// newExternalExchange starts an EXTERNAL exchange.
+func newExternalExchange(verifier interface{}, conn *ConnState) (Exchange, error) {
+
+	identityVerifier, ok := verifier.(IdentityVerifier)
+	if !ok {
+		return nil, fmt.Errorf("authenticator is unable to look up users by name")
+	}
+
+	return &externalExchange{
+		verifier: identityVerifier,
+		conn:     conn,
+	}, nil
+}
+
+// certificateUser returns the user name a client
+// certificate was issued to, which is its common
+// name or else its first email address.
+func certificateUser(cert *x509.Certificate) string {
+
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+
+	if len(cert.EmailAddresses) > 0 {
+		return cert.EmailAddresses[0]
+	}
+
+	return ""
+}
+
+// Next implements Exchange.
+func (e *externalExchange) Next(response []byte) ([]byte, *Identity, error) {
+
+	// Ask for the authorization identity if the
+	// client did not send an initial response.
+	if (response == nil) && !e.asked {
+		e.asked = true
+		return []byte{}, nil, nil
+	}
+
+	// Only certificates the TLS handshake verified
+	// against the configured client CAs count.
+	if (e.conn.TLS == nil) || (len(e.conn.TLS.VerifiedChains) == 0) {
+		return nil, nil, fmt.Errorf("client did not present a verified certificate")
+	}
+
+	username := certificateUser(e.conn.TLS.VerifiedChains[0][0])
+	if username == "" {
+		return nil, nil, fmt.Errorf("client certificate does not name a user")
+	}
+
+	// Acting as another user is not supported.
+	if (len(response) > 0) && (string(response) != username) {
+		return nil, nil, fmt.Errorf("authorization identity differs from certificate user")
+	}
+
+	id, clientID, err := e.verifier.AuthenticateIdentity(username, e.conn.ClientAddr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nil, &Identity{
+		ID:       id,
+		ClientID: clientID,
+		UserName: username,
+	}, nil
+}
+
+// init registers the EXTERNAL mechanism.
+func init() {
+	RegisterMechanism(&Mechanism{Name: MechanismExternal, RequiresTLS: true, New: newExternalExchange})
+}
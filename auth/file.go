@@ -101,11 +101,9 @@ func (f *File) GetWorkerForUser(workers map[string]config.Worker, id int) (strin
 	return "", fmt.Errorf("no worker responsible for user ID %d", id)
 }
 
-// AuthenticatePlain performs the actual authentication
-// process by taking supplied credentials and attempting
-// to find a matching entry the in-memory list taken from
-// the authentication file.
-func (f *File) AuthenticatePlain(username string, password string, clientAddr string) (int, string, error) {
+// lookup returns the entry of the user
+// named username, if there is one.
+func (f *File) lookup(username string) (*User, error) {
 
 	// Search in user list for user matching supplied name.
 	i := sort.Search(len(f.Users), func(i int) bool {
@@ -114,18 +112,70 @@ func (f *File) AuthenticatePlain(username string, password string, clientAddr st
 
 	// If that user does not exist, throw an error.
 	if !((i < len(f.Users)) && (f.Users[i].Name == username)) {
-		return -1, "", fmt.Errorf("username not found in list of users")
+		return nil, fmt.Errorf("username not found in list of users")
+	}
+
+	return &f.Users[i], nil
+}
+
+// Mechanisms returns the SASL mechanisms File is able
+// to verify. SCRAM credentials can only be derived from
+// passwords stored in plain text, so SCRAM fails for
+// users whose entry contains a hash.
+func (f *File) Mechanisms() []string {
+	return []string{MechanismPlain, MechanismLogin, MechanismScramSHA256, MechanismScramSHA256Plus, MechanismExternal}
+}
+
+// AuthenticatePlain performs the actual authentication
+// process by taking supplied credentials and attempting
+// to find a matching entry the in-memory list taken from
+// the authentication file.
+func (f *File) AuthenticatePlain(username string, password string, clientAddr string) (int, string, error) {
+
+	user, err := f.lookup(username)
+	if err != nil {
+		return -1, "", err
 	}
 
-	// Check if passwords match.
-	if f.Users[i].Password != password {
+	// Check if passwords match. Entries without
+	// scheme prefix contain the plain password.
+	match, err := VerifyPassword(user.Password, password, SchemePlain)
+	if err != nil {
+		return -1, "", err
+	}
+
+	if !match {
 		return -1, "", fmt.Errorf("passwords did not match")
 	}
 
-	// Build the deterministic client-specific session identifier.
-	// Note: we expect this clientID to really identify exactly one
-	// device in one session of one user.
-	clientID := fmt.Sprintf("%s:%s", clientAddr, username)
+	return user.ID, clientID(clientAddr, username), nil
+}
+
+// AuthenticateIdentity returns ID and session identifier
+// of a user whose identity a SASL mechanism verified.
+func (f *File) AuthenticateIdentity(username string, clientAddr string) (int, string, error) {
+
+	user, err := f.lookup(username)
+	if err != nil {
+		return -1, "", err
+	}
+
+	return user.ID, clientID(clientAddr, username), nil
+}
+
+// ScramCredentials derives the SCRAM-SHA-256
+// credentials of a user from its password.
+func (f *File) ScramCredentials(username string) (*ScramCredentials, error) {
+
+	user, err := f.lookup(username)
+	if err != nil {
+		return nil, err
+	}
+
+	scheme, plain := splitScheme(user.Password, SchemePlain)
+	if scheme != SchemePlain {
+		return nil, fmt.Errorf("SCRAM requires the password of %s in plain text", username)
+	}
 
-	return f.Users[i].ID, clientID, nil
+	return NewScramCredentials(plain, scramSalt(username), ScramIterations), nil
 }
@@ -0,0 +1,286 @@
+package auth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/url"
+
+	"github.com/go-pluto/pluto/config"
+)
+
+// Constants
+
+const (
+	// ldapDefaultPoolSize is the number of idle
+	// connections kept if none is configured.
+	ldapDefaultPoolSize = 4
+
+	// ldapDefaultTimeout limits connecting and each
+	// operation if no timeout is configured.
+	ldapDefaultTimeout = 10 * time.Second
+
+	// ldapDefaultFilter finds users by their uid.
+	ldapDefaultFilter = "(uid=%s)"
+)
+
+// Structs
+
+// LDAPAuthenticator checks user credentials by binding
+// to an LDAP directory, either directly as the DN built
+// from the user name or as the DN found by a search.
+// Idle connections are kept in a pool.
+type LDAPAuthenticator struct {
+	Config    *config.AuthLDAP
+	tlsConfig *tls.Config
+	timeout   time.Duration
+	pool      chan *ldapConn
+	workers   []config.Worker
+}
+
+// Functions
+
+// NewLDAPAuthenticator expects to be supplied with the
+// LDAP section of the config file. It connects to the
+// directory once to check the configuration and returns
+// an initialized authenticator.
+func NewLDAPAuthenticator(conf *config.AuthLDAP, workers map[string]config.Worker) (*LDAPAuthenticator, error) {
+
+	u, err := url.Parse(conf.URL)
+	if (err != nil) || ((u.Scheme != "ldap") && (u.Scheme != "ldaps")) || (u.Host == "") {
+		return nil, fmt.Errorf("LDAP authenticator requires an ldap:// or ldaps:// URL")
+	}
+
+	if (conf.UserDNTemplate == "") && (conf.BaseDN == "") {
+		return nil, fmt.Errorf("LDAP authenticator requires either UserDNTemplate or BaseDN")
+	}
+
+	if conf.UserFilter == "" {
+		conf.UserFilter = ldapDefaultFilter
+	}
+
+	if conf.PoolSize <= 0 {
+		conf.PoolSize = ldapDefaultPoolSize
+	}
+
+	timeout := ldapDefaultTimeout
+	if conf.Timeout > 0 {
+		timeout = time.Duration(conf.Timeout) * time.Second
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName: u.Hostname(),
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if conf.RootCALoc != "" {
+
+		rootCert, err := ioutil.ReadFile(conf.RootCALoc)
+		if err != nil {
+			return nil, fmt.Errorf("could not read supplied LDAP root CA file: %v", err)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(rootCert) {
+			return nil, fmt.Errorf("failed to append LDAP root certificate to cert pool")
+		}
+	}
+
+	l := &LDAPAuthenticator{
+		Config:    conf,
+		tlsConfig: tlsConfig,
+		timeout:   timeout,
+		pool:      make(chan *ldapConn, conf.PoolSize),
+		workers:   orderWorkers(workers),
+	}
+
+	// Connect and, if configured, bind as service
+	// account once to detect configuration errors.
+	conn, err := l.get()
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.BindDN != "" {
+
+		err = conn.bind(conf.BindDN, conf.BindPassword)
+		if err != nil {
+			conn.close()
+			return nil, fmt.Errorf("could not bind to LDAP directory as %s: %v", conf.BindDN, err)
+		}
+	}
+
+	l.put(conn, nil)
+
+	return l, nil
+}
+
+// get returns an idle connection of the
+// pool or else opens a new one.
+func (l *LDAPAuthenticator) get() (*ldapConn, error) {
+
+	select {
+	case conn := <-l.pool:
+		return conn, nil
+	default:
+		return dialLDAP(l.Config.URL, l.Config.StartTLS, l.tlsConfig, l.timeout)
+	}
+}
+
+// put returns conn to the pool after an operation
+// ending with err. Connections failing for reasons
+// other than an LDAP result code are closed, as well
+// as those not fitting into the pool anymore.
+func (l *LDAPAuthenticator) put(conn *ldapConn, err error) {
+
+	if err != nil {
+
+		if _, ok := err.(*ldapError); !ok {
+			conn.close()
+			return
+		}
+	}
+
+	select {
+	case l.pool <- conn:
+	default:
+		conn.close()
+	}
+}
+
+// userID returns the user ID of entry, which is the
+// value of the configured ID attribute if numeric.
+// Other values and, without ID attribute, the user
+// name are shard keys mapped onto the workers.
+func (l *LDAPAuthenticator) userID(entry *ldapEntry, username string) (int, error) {
+
+	if l.Config.IDAttribute == "" {
+		return shardUserID(l.workers, username)
+	}
+
+	values := entry.Attributes[strings.ToLower(l.Config.IDAttribute)]
+	if (len(values) == 0) || (values[0] == "") {
+		return -1, fmt.Errorf("LDAP entry %s lacks attribute %s", entry.DN, l.Config.IDAttribute)
+	}
+
+	id, err := strconv.Atoi(values[0])
+	if err == nil {
+		return id, nil
+	}
+
+	return shardUserID(l.workers, values[0])
+}
+
+// authenticate binds as user and returns the user's
+// directory entry. The connection is bound to the
+// user afterwards.
+func (l *LDAPAuthenticator) authenticate(conn *ldapConn, username string, password string) (*ldapEntry, error) {
+
+	attrs := []string{}
+	if l.Config.IDAttribute != "" {
+		attrs = append(attrs, l.Config.IDAttribute)
+	}
+
+	// In direct mode, the user reads its own
+	// entry after binding successfully.
+	if l.Config.UserDNTemplate != "" {
+
+		dn := fmt.Sprintf(l.Config.UserDNTemplate, escapeDN(username))
+
+		err := conn.bind(dn, password)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(attrs) == 0 {
+			return &ldapEntry{DN: dn}, nil
+		}
+
+		entries, err := conn.search(dn, ldapScopeBase, "(objectClass=*)", attrs, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(entries) != 1 {
+			return nil, fmt.Errorf("could not read LDAP entry %s", dn)
+		}
+
+		return entries[0], nil
+	}
+
+	// Otherwise, search as service account for
+	// exactly one entry and bind as that entry.
+	err := conn.bind(l.Config.BindDN, l.Config.BindPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := conn.search(l.Config.BaseDN, ldapScopeSubtree, fmt.Sprintf(l.Config.UserFilter, escapeFilter(username)), attrs, 2)
+	if lErr, ok := err.(*ldapError); ok && (lErr.Code == ldapSizeLimitExceeded) {
+		return nil, fmt.Errorf("LDAP user filter matches multiple entries")
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(entries) != 1 {
+		return nil, fmt.Errorf("LDAP user filter matches %d entries", len(entries))
+	}
+
+	err = conn.bind(entries[0].DN, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries[0], nil
+}
+
+// GetWorkerForUser returns the name of the worker node
+// that is responsible for handling the user's mailbox.
+func (l *LDAPAuthenticator) GetWorkerForUser(workers map[string]config.Worker, id int) (string, error) {
+	return workerForUser(workers, id)
+}
+
+// Mechanisms returns the SASL mechanisms the LDAP
+// authenticator is able to verify. The directory only
+// checks passwords on bind, which rules out SCRAM.
+func (l *LDAPAuthenticator) Mechanisms() []string {
+	return []string{MechanismPlain, MechanismLogin}
+}
+
+// AuthenticatePlain binds to the directory with
+// the supplied credentials and returns ID and
+// session identifier of the user on success.
+func (l *LDAPAuthenticator) AuthenticatePlain(username string, password string, clientAddr string) (int, string, error) {
+
+	// An empty password performs an unauthenticated
+	// bind, which succeeds without any check.
+	if (username == "") || (password == "") {
+		return -1, "", fmt.Errorf("empty user name or password")
+	}
+
+	conn, err := l.get()
+	if err != nil {
+		return -1, "", err
+	}
+
+	entry, err := l.authenticate(conn, username, password)
+	l.put(conn, err)
+
+	if lErr, ok := err.(*ldapError); ok && (lErr.Code == ldapInvalidCredentials) {
+		return -1, "", fmt.Errorf("passwords did not match")
+	} else if err != nil {
+		return -1, "", err
+	}
+
+	id, err := l.userID(entry, username)
+	if err != nil {
+		return -1, "", err
+	}
+
+	return id, clientID(clientAddr, username), nil
+}
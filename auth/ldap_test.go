@@ -0,0 +1,313 @@
+package auth
+
+import (
+	"bufio"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+
+	"github.com/go-pluto/pluto/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// Structs
+
+// ldapStandIn is a minimal in-process LDAP server
+// knowing a fixed set of entries. It supports simple
+// binds, StartTLS, and searches with equality,
+// presence, and AND filters.
+type ldapStandIn struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+	entries   map[string]map[string]string
+	lock      sync.Mutex
+	dials     int
+}
+
+// Functions
+
+// newLDAPStandIn starts a stand-in on a random port
+// and writes its self-signed certificate to caFile.
+func newLDAPStandIn(t *testing.T, caFile string) *ldapStandIn {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nilf(t, err, "expected key generation not to fail but got: %v", err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ldap-stand-in"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nilf(t, err, "expected certificate creation not to fail but got: %v", err)
+
+	err = ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	assert.Nilf(t, err, "expected CA file to be written but got: %v", err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nilf(t, err, "expected listener but got: %v", err)
+
+	s := &ldapStandIn{
+		listener: listener,
+		tlsConfig: &tls.Config{
+			Certificates: []tls.Certificate{tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}},
+		},
+		entries: map[string]map[string]string{
+			"cn=pluto,ou=services,dc=example,dc=com": {"userpassword": "service-secret"},
+			"uid=alice,ou=people,dc=example,dc=com":  {"objectclass": "person", "uid": "alice", "uidnumber": "7", "userpassword": "wonderland"},
+			"uid=bob,ou=people,dc=example,dc=com":    {"objectclass": "person", "uid": "bob", "uidnumber": "b-17", "userpassword": "builder"},
+		},
+	}
+
+	go func() {
+
+		for {
+
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			s.lock.Lock()
+			s.dials++
+			s.lock.Unlock()
+
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+// matches evaluates filter against entry.
+func (s *ldapStandIn) matches(filter *berPacket, entry map[string]string) bool {
+
+	switch filter.tag {
+
+	case 0xa0:
+		for _, sub := range filter.children {
+			if !s.matches(sub, entry) {
+				return false
+			}
+		}
+		return true
+
+	case 0xa3:
+		return strings.EqualFold(entry[strings.ToLower(string(filter.children[0].value))], string(filter.children[1].value))
+
+	case 0x87:
+		_, found := entry[strings.ToLower(string(filter.value))]
+		return found
+	}
+
+	return false
+}
+
+// serve answers the requests of one client.
+func (s *ldapStandIn) serve(conn net.Conn) {
+
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	bound := false
+
+	respond := func(msgID *berPacket, op *berPacket) {
+		conn.Write(berConstructedOf(berSequence, msgID, op).bytes())
+	}
+
+	result := func(tag byte, code int) *berPacket {
+		return berConstructedOf(tag, berInt(berEnumerated, code), berString(""), berString(""))
+	}
+
+	for {
+
+		msg, err := readBER(reader)
+		if err != nil {
+			return
+		}
+
+		msgID, op := msg.children[0], msg.children[1]
+
+		switch op.tag {
+
+		case ldapExtendedRequest:
+
+			respond(msgID, result(ldapExtendedResponse, ldapSuccess))
+
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+
+			conn = tlsConn
+			reader = bufio.NewReader(tlsConn)
+
+		case ldapBindRequest:
+
+			dn, password := string(op.children[1].value), string(op.children[2].value)
+
+			entry, found := s.entries[dn]
+			if (dn == "") && (password == "") {
+				bound = false
+				respond(msgID, result(ldapBindResponse, ldapSuccess))
+			} else if found && (entry["userpassword"] == password) {
+				bound = true
+				respond(msgID, result(ldapBindResponse, ldapSuccess))
+			} else {
+				respond(msgID, result(ldapBindResponse, ldapInvalidCredentials))
+			}
+
+		case ldapSearchRequest:
+
+			// Anonymous users may not search.
+			if !bound {
+				respond(msgID, result(ldapSearchDone, 50))
+				continue
+			}
+
+			base := string(op.children[0].value)
+			scope, _ := op.children[1].int()
+			sizeLimit, _ := op.children[3].int()
+
+			sent := 0
+			code := ldapSuccess
+
+			for dn, entry := range s.entries {
+
+				if ((scope == ldapScopeBase) && (dn != base)) || !strings.HasSuffix(dn, base) || !s.matches(op.children[6], entry) {
+					continue
+				}
+
+				if sent == sizeLimit {
+					code = ldapSizeLimitExceeded
+					break
+				}
+
+				attrs := berConstructedOf(berSequence)
+				for _, attr := range op.children[7].children {
+					name := strings.ToLower(string(attr.value))
+					attrs.children = append(attrs.children, berConstructedOf(berSequence, berString(name), berConstructedOf(berSet, berString(entry[name]))))
+				}
+
+				respond(msgID, berConstructedOf(ldapSearchEntry, berString(dn), attrs))
+				sent++
+			}
+
+			respond(msgID, result(ldapSearchDone, code))
+
+		case ldapUnbindRequest:
+			return
+		}
+	}
+}
+
+// TestLDAPFilter executes a white-box unit test
+// on escaping and compiling search filters.
+func TestLDAPFilter(t *testing.T) {
+
+	assert.Equalf(t, "\\2a\\28uid=x\\29\\5c", escapeFilter("*(uid=x)\\"), "unexpected escaped filter value")
+	assert.Equalf(t, "\\#a\\,b\\=c\\ ", escapeDN("#a,b=c "), "unexpected escaped DN value")
+
+	filter, err := compileFilter("(&(objectClass=person)(|(uid=a\\2ab)(mail=*@example.com))(!(cn=*)))")
+	assert.Nilf(t, err, "expected filter to compile but got: %v", err)
+	assert.Equalf(t, byte(0xa0), filter.tag, "expected AND filter")
+	assert.Equalf(t, 3, len(filter.children), "expected three filters in AND")
+	assert.Equalf(t, "a*b", string(filter.children[1].children[0].children[1].value), "expected escape to be decoded")
+	assert.Equalf(t, byte(0xa4), filter.children[1].children[1].tag, "expected substring filter")
+	assert.Equalf(t, byte(0x87), filter.children[2].children[0].tag, "expected presence filter")
+
+	for _, malformed := range []string{"uid=a", "(uid=a", "(uid=a))", "(!(a=b)(c=d))", "(=a)"} {
+		_, err = compileFilter(malformed)
+		assert.NotNilf(t, err, "expected filter %s to be refused", malformed)
+	}
+}
+
+// TestLDAPAuthenticator executes a white-box unit test
+// on both bind modes of the LDAP authenticator against
+// a stand-in server.
+func TestLDAPAuthenticator(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "pluto-ldap")
+	assert.Nilf(t, err, "expected temporary directory but got: %v", err)
+	defer os.RemoveAll(dir)
+
+	caFile := filepath.Join(dir, "ca.pem")
+
+	s := newLDAPStandIn(t, caFile)
+	defer s.listener.Close()
+
+	l, err := NewLDAPAuthenticator(&config.AuthLDAP{
+		URL:          "ldap://" + s.listener.Addr().String(),
+		StartTLS:     true,
+		RootCALoc:    caFile,
+		BindDN:       "cn=pluto,ou=services,dc=example,dc=com",
+		BindPassword: "service-secret",
+		BaseDN:       "ou=people,dc=example,dc=com",
+		UserFilter:   "(&(objectClass=person)(uid=%s))",
+		IDAttribute:  "uidNumber",
+		PoolSize:     2,
+	}, testWorkers)
+	assert.Nilf(t, err, "expected NewLDAPAuthenticator() not to fail but got: %v", err)
+
+	// Search-then-bind with a numeric ID attribute.
+	id, clientID, err := l.AuthenticatePlain("alice", "wonderland", "127.0.0.1:4321")
+	assert.Nilf(t, err, "expected alice to authenticate but got: %v", err)
+	assert.Equalf(t, 7, id, "expected user ID of uidNumber")
+	assert.Equalf(t, "127.0.0.1:4321:alice", clientID, "unexpected client ID")
+
+	// Non-numeric values are shard keys.
+	id, _, err = l.AuthenticatePlain("bob", "builder", "127.0.0.1:4321")
+	assert.Nilf(t, err, "expected bob to authenticate but got: %v", err)
+	_, err = l.GetWorkerForUser(testWorkers, id)
+	assert.Nilf(t, err, "expected a worker for sharded user ID but got: %v", err)
+
+	_, _, err = l.AuthenticatePlain("alice", "builder", "127.0.0.1:4321")
+	assert.NotNilf(t, err, "expected wrong password to fail")
+
+	_, _, err = l.AuthenticatePlain("alice", "", "127.0.0.1:4321")
+	assert.NotNilf(t, err, "expected empty password to fail")
+
+	_, _, err = l.AuthenticatePlain("*", "wonderland", "127.0.0.1:4321")
+	assert.NotNilf(t, err, "expected wildcard user name not to match anyone")
+
+	// Pooled connections are reused.
+	s.lock.Lock()
+	assert.Equalf(t, 1, s.dials, "expected a single pooled connection")
+	s.lock.Unlock()
+
+	// Direct bind reading the user's own entry.
+	l, err = NewLDAPAuthenticator(&config.AuthLDAP{
+		URL:            "ldap://" + s.listener.Addr().String(),
+		UserDNTemplate: "uid=%s,ou=people,dc=example,dc=com",
+		IDAttribute:    "uidNumber",
+	}, testWorkers)
+	assert.Nilf(t, err, "expected NewLDAPAuthenticator() not to fail but got: %v", err)
+
+	id, _, err = l.AuthenticatePlain("alice", "wonderland", "127.0.0.1:4321")
+	assert.Nilf(t, err, "expected alice to authenticate but got: %v", err)
+	assert.Equalf(t, 7, id, "expected user ID of uidNumber")
+
+	_, _, err = l.AuthenticatePlain("alice,ou=services", "wonderland", "127.0.0.1:4321")
+	assert.NotNilf(t, err, "expected DN injection to fail")
+}
@@ -0,0 +1,771 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"crypto/tls"
+	"encoding/hex"
+)
+
+// Constants
+
+// BER identifiers of the LDAPv3 (RFC 4511)
+// elements pluto sends and receives.
+const (
+	berBoolean     byte = 0x01
+	berInteger     byte = 0x02
+	berOctetString byte = 0x04
+	berEnumerated  byte = 0x0a
+	berSequence    byte = 0x30
+	berSet         byte = 0x31
+	berConstructed byte = 0x20
+
+	ldapBindRequest      byte = 0x60
+	ldapBindResponse     byte = 0x61
+	ldapUnbindRequest    byte = 0x42
+	ldapSearchRequest    byte = 0x63
+	ldapSearchEntry      byte = 0x64
+	ldapSearchDone       byte = 0x65
+	ldapSearchReference  byte = 0x73
+	ldapExtendedRequest  byte = 0x77
+	ldapExtendedResponse byte = 0x78
+	ldapSimpleAuth       byte = 0x80
+	ldapExtendedName     byte = 0x80
+)
+
+const (
+	// ldapScopeBase searches only the base entry.
+	ldapScopeBase = 0
+	// ldapScopeSubtree searches the whole subtree.
+	ldapScopeSubtree = 2
+
+	// ldapSuccess is the result code of
+	// successful operations.
+	ldapSuccess = 0
+	// ldapSizeLimitExceeded is returned if a
+	// search matched more entries than allowed.
+	ldapSizeLimitExceeded = 4
+	// ldapInvalidCredentials is returned on
+	// binds with wrong DN or password.
+	ldapInvalidCredentials = 49
+
+	// ldapStartTLSOID names the StartTLS
+	// extended operation (RFC 4511, 4.14).
+	ldapStartTLSOID = "1.3.6.1.4.1.1466.20037"
+
+	// berMaxLength limits the size of elements
+	// accepted from the directory server.
+	berMaxLength = 16 * 1024 * 1024
+)
+
+// Structs
+
+// berPacket is one BER encoded element. Primitive
+// elements carry their content in value, constructed
+// ones in children.
+type berPacket struct {
+	tag      byte
+	value    []byte
+	children []*berPacket
+}
+
+// ldapError is a result code other than success
+// the directory server answered an operation with.
+type ldapError struct {
+	Code    int
+	Message string
+}
+
+// ldapEntry is one entry returned by a search.
+// Attribute names are stored in lower case.
+type ldapEntry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// ldapConn is a client connection to an LDAP
+// directory server. Each operation has to finish
+// within timeout.
+type ldapConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	msgID   int
+	timeout time.Duration
+}
+
+// Functions
+
+// berPrimitive returns a primitive element.
+func berPrimitive(tag byte, value []byte) *berPacket {
+	return &berPacket{tag: tag, value: value}
+}
+
+// berConstructedOf returns a constructed element.
+func berConstructedOf(tag byte, children ...*berPacket) *berPacket {
+	return &berPacket{tag: tag, children: children}
+}
+
+// berString returns an OCTET STRING element.
+func berString(s string) *berPacket {
+	return berPrimitive(berOctetString, []byte(s))
+}
+
+// berInt returns an INTEGER or ENUMERATED element
+// holding a non-negative number.
+func berInt(tag byte, i int) *berPacket {
+
+	value := []byte{byte(i)}
+	for i > 0xff {
+		i >>= 8
+		value = append([]byte{byte(i)}, value...)
+	}
+
+	// Keep the number positive in two's complement.
+	if value[0]&0x80 != 0 {
+		value = append([]byte{0}, value...)
+	}
+
+	return berPrimitive(tag, value)
+}
+
+// bytes returns the BER encoding of p.
+func (p *berPacket) bytes() []byte {
+
+	content := p.value
+	if p.children != nil {
+
+		content = nil
+		for _, child := range p.children {
+			content = append(content, child.bytes()...)
+		}
+	}
+
+	encoded := []byte{p.tag}
+
+	length := len(content)
+	if length < 0x80 {
+		encoded = append(encoded, byte(length))
+	} else {
+
+		lenBytes := []byte{}
+		for length > 0 {
+			lenBytes = append([]byte{byte(length)}, lenBytes...)
+			length >>= 8
+		}
+
+		encoded = append(encoded, (0x80 | byte(len(lenBytes))))
+		encoded = append(encoded, lenBytes...)
+	}
+
+	return append(encoded, content...)
+}
+
+// int returns the number contained in
+// an INTEGER or ENUMERATED element.
+func (p *berPacket) int() (int, error) {
+
+	if (len(p.value) == 0) || (len(p.value) > 4) {
+		return 0, fmt.Errorf("invalid BER integer")
+	}
+
+	i := int(int8(p.value[0]))
+	for _, b := range p.value[1:] {
+		i = (i << 8) | int(b)
+	}
+
+	return i, nil
+}
+
+// child returns the child at position i, if any.
+func (p *berPacket) child(i int) (*berPacket, error) {
+
+	if i >= len(p.children) {
+		return nil, fmt.Errorf("missing element %d in BER element 0x%x", i, p.tag)
+	}
+
+	return p.children[i], nil
+}
+
+// readBER reads the next element from r.
+func readBER(r io.Reader) (*berPacket, error) {
+
+	header := make([]byte, 2)
+
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if (header[0] & 0x1f) == 0x1f {
+		return nil, fmt.Errorf("unsupported BER tag 0x%x", header[0])
+	}
+
+	length := int(header[1])
+	if length == 0x80 {
+		return nil, fmt.Errorf("indefinite BER length not supported")
+	}
+
+	if length > 0x80 {
+
+		lenBytes := make([]byte, (length & 0x7f))
+		if len(lenBytes) > 4 {
+			return nil, fmt.Errorf("BER length too large")
+		}
+
+		_, err = io.ReadFull(r, lenBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		length = 0
+		for _, b := range lenBytes {
+			length = (length << 8) | int(b)
+		}
+	}
+
+	if (length < 0) || (length > berMaxLength) {
+		return nil, fmt.Errorf("BER length too large")
+	}
+
+	content := make([]byte, length)
+
+	_, err = io.ReadFull(r, content)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &berPacket{tag: header[0]}
+
+	if (header[0] & berConstructed) == 0 {
+		p.value = content
+		return p, nil
+	}
+
+	// Parse the children of constructed elements.
+	p.children = []*berPacket{}
+	contentReader := bytes.NewReader(content)
+
+	for contentReader.Len() > 0 {
+
+		child, err := readBER(contentReader)
+		if err != nil {
+			return nil, fmt.Errorf("malformed BER element: %v", err)
+		}
+
+		p.children = append(p.children, child)
+	}
+
+	return p, nil
+}
+
+// Error implements error.
+func (e *ldapError) Error() string {
+	return fmt.Sprintf("LDAP result code %d: %s", e.Code, e.Message)
+}
+
+// ldapResult returns an error if the LDAPResult
+// contained in op does not state success.
+func ldapResult(op *berPacket) error {
+
+	code, err := op.child(0)
+	if err != nil {
+		return err
+	}
+
+	resultCode, err := code.int()
+	if err != nil {
+		return err
+	}
+
+	if resultCode == ldapSuccess {
+		return nil
+	}
+
+	message := ""
+	if diagnostic, err := op.child(2); err == nil {
+		message = string(diagnostic.value)
+	}
+
+	return &ldapError{Code: resultCode, Message: message}
+}
+
+// escapeFilter escapes a value to be inserted
+// into a search filter (RFC 4515, 3).
+func escapeFilter(value string) string {
+
+	var escaped strings.Builder
+
+	for i := 0; i < len(value); i++ {
+
+		switch value[i] {
+		case '*', '(', ')', '\\', 0:
+			fmt.Fprintf(&escaped, "\\%02x", value[i])
+		default:
+			escaped.WriteByte(value[i])
+		}
+	}
+
+	return escaped.String()
+}
+
+// escapeDN escapes a value to be inserted
+// into a distinguished name (RFC 4514, 2.4).
+func escapeDN(value string) string {
+
+	var escaped strings.Builder
+
+	for i := 0; i < len(value); i++ {
+
+		c := value[i]
+
+		switch {
+		case strings.IndexByte(",+\"\\<>;=", c) >= 0:
+			escaped.WriteByte('\\')
+			escaped.WriteByte(c)
+		case c == 0:
+			escaped.WriteString("\\00")
+		case ((c == ' ') || (c == '#')) && (i == 0):
+			escaped.WriteByte('\\')
+			escaped.WriteByte(c)
+		case (c == ' ') && (i == (len(value) - 1)):
+			escaped.WriteString("\\ ")
+		default:
+			escaped.WriteByte(c)
+		}
+	}
+
+	return escaped.String()
+}
+
+// unescapeFilterValue decodes the \XX escapes
+// of a value contained in a search filter.
+func unescapeFilterValue(value string) ([]byte, error) {
+
+	decoded := []byte{}
+
+	for i := 0; i < len(value); i++ {
+
+		if value[i] != '\\' {
+			decoded = append(decoded, value[i])
+			continue
+		}
+
+		if (i + 2) >= len(value) {
+			return nil, fmt.Errorf("invalid escape in filter value %s", value)
+		}
+
+		b, err := hex.DecodeString(value[(i + 1):(i + 3)])
+		if err != nil {
+			return nil, fmt.Errorf("invalid escape in filter value %s", value)
+		}
+
+		decoded = append(decoded, b...)
+		i += 2
+	}
+
+	return decoded, nil
+}
+
+// compileFilter encodes a search filter
+// in its string form (RFC 4515).
+func compileFilter(filter string) (*berPacket, error) {
+
+	p, rest, err := parseFilter(strings.TrimSpace(filter))
+	if err != nil {
+		return nil, err
+	}
+
+	if rest != "" {
+		return nil, fmt.Errorf("unexpected %s after search filter", rest)
+	}
+
+	return p, nil
+}
+
+// parseFilter encodes the filter at the beginning
+// of filter and returns what follows it.
+func parseFilter(filter string) (*berPacket, string, error) {
+
+	if (len(filter) < 3) || (filter[0] != '(') {
+		return nil, "", fmt.Errorf("search filter has to start with '('")
+	}
+
+	switch filter[1] {
+
+	case '&', '|':
+
+		tag := byte(0xa0)
+		if filter[1] == '|' {
+			tag = 0xa1
+		}
+
+		set := berConstructedOf(tag)
+		rest := filter[2:]
+
+		for !strings.HasPrefix(rest, ")") {
+
+			var sub *berPacket
+			var err error
+
+			sub, rest, err = parseFilter(rest)
+			if err != nil {
+				return nil, "", err
+			}
+
+			set.children = append(set.children, sub)
+		}
+
+		return set, rest[1:], nil
+
+	case '!':
+
+		sub, rest, err := parseFilter(filter[2:])
+		if err != nil {
+			return nil, "", err
+		}
+
+		if !strings.HasPrefix(rest, ")") {
+			return nil, "", fmt.Errorf("negation has to contain exactly one filter")
+		}
+
+		return berConstructedOf(0xa2, sub), rest[1:], nil
+	}
+
+	end := strings.IndexByte(filter, ')')
+	if end < 0 {
+		return nil, "", fmt.Errorf("unterminated search filter %s", filter)
+	}
+
+	item := filter[1:end]
+
+	eq := strings.IndexByte(item, '=')
+	if eq < 1 {
+		return nil, "", fmt.Errorf("invalid search filter item %s", item)
+	}
+
+	attr := item[:eq]
+	rawValue := item[(eq + 1):]
+
+	// Comparisons other than equality are marked
+	// by the character preceding '='.
+	var tag byte
+	switch attr[(len(attr) - 1)] {
+	case '>':
+		tag = 0xa5
+	case '<':
+		tag = 0xa6
+	case '~':
+		tag = 0xa8
+	}
+
+	if tag != 0 {
+
+		value, err := unescapeFilterValue(rawValue)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return berConstructedOf(tag, berString(attr[:(len(attr)-1)]), berPrimitive(berOctetString, value)), filter[(end + 1):], nil
+	}
+
+	if rawValue == "*" {
+		return berPrimitive(0x87, []byte(attr)), filter[(end + 1):], nil
+	}
+
+	if !strings.Contains(rawValue, "*") {
+
+		value, err := unescapeFilterValue(rawValue)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return berConstructedOf(0xa3, berString(attr), berPrimitive(berOctetString, value)), filter[(end + 1):], nil
+	}
+
+	// Values containing wildcards are substring
+	// matches of initial, any, and final parts.
+	parts := strings.Split(rawValue, "*")
+	substrings := berConstructedOf(berSequence)
+
+	for i, part := range parts {
+
+		if part == "" {
+			continue
+		}
+
+		value, err := unescapeFilterValue(part)
+		if err != nil {
+			return nil, "", err
+		}
+
+		partTag := byte(0x81)
+		if i == 0 {
+			partTag = 0x80
+		} else if i == (len(parts) - 1) {
+			partTag = 0x82
+		}
+
+		substrings.children = append(substrings.children, berPrimitive(partTag, value))
+	}
+
+	return berConstructedOf(0xa4, berString(attr), substrings), filter[(end + 1):], nil
+}
+
+// dialLDAP connects to the directory server at
+// rawURL. Connections to ldaps:// URLs use TLS from
+// the start, others are upgraded via StartTLS if
+// startTLS is true.
+func dialLDAP(rawURL string, startTLS bool, tlsConfig *tls.Config, timeout time.Duration) (*ldapConn, error) {
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LDAP URL %s: %v", rawURL, err)
+	}
+
+	host := u.Host
+	dialer := &net.Dialer{Timeout: timeout}
+
+	var conn net.Conn
+
+	switch u.Scheme {
+
+	case "ldaps":
+
+		if u.Port() == "" {
+			host = net.JoinHostPort(host, "636")
+		}
+
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, tlsConfig)
+
+	case "ldap":
+
+		if u.Port() == "" {
+			host = net.JoinHostPort(host, "389")
+		}
+
+		conn, err = dialer.Dial("tcp", host)
+
+	default:
+		return nil, fmt.Errorf("unsupported LDAP URL scheme %s", u.Scheme)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to LDAP server: %v", err)
+	}
+
+	c := &ldapConn{
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		timeout: timeout,
+	}
+
+	if startTLS && (u.Scheme == "ldap") {
+
+		err = c.startTLS(tlsConfig)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// send writes op as the next message.
+func (c *ldapConn) send(op *berPacket) error {
+
+	c.msgID++
+	msg := berConstructedOf(berSequence, berInt(berInteger, c.msgID), op)
+
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+
+	_, err := c.conn.Write(msg.bytes())
+
+	return err
+}
+
+// receive reads the next message answering the
+// last request and returns its operation.
+func (c *ldapConn) receive() (*berPacket, error) {
+
+	msg, err := readBER(c.reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if (msg.tag != berSequence) || (len(msg.children) < 2) {
+		return nil, fmt.Errorf("malformed LDAP message")
+	}
+
+	msgID, err := msg.children[0].int()
+	if (err != nil) || (msgID != c.msgID) {
+		return nil, fmt.Errorf("LDAP message answers unexpected request")
+	}
+
+	return msg.children[1], nil
+}
+
+// request sends op and returns the operation of
+// the response, which has to be of type expected.
+func (c *ldapConn) request(op *berPacket, expected byte) (*berPacket, error) {
+
+	err := c.send(op)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.receive()
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.tag != expected {
+		return nil, fmt.Errorf("unexpected LDAP response 0x%x", resp.tag)
+	}
+
+	return resp, nil
+}
+
+// startTLS upgrades the connection to TLS.
+func (c *ldapConn) startTLS(tlsConfig *tls.Config) error {
+
+	resp, err := c.request(berConstructedOf(ldapExtendedRequest, berPrimitive(ldapExtendedName, []byte(ldapStartTLSOID))), ldapExtendedResponse)
+	if err != nil {
+		return fmt.Errorf("StartTLS failed: %v", err)
+	}
+
+	err = ldapResult(resp)
+	if err != nil {
+		return fmt.Errorf("StartTLS refused: %v", err)
+	}
+
+	tlsConn := tls.Client(c.conn, tlsConfig)
+
+	err = tlsConn.Handshake()
+	if err != nil {
+		return fmt.Errorf("StartTLS handshake failed: %v", err)
+	}
+
+	c.conn = tlsConn
+	c.reader = bufio.NewReader(tlsConn)
+
+	return nil
+}
+
+// bind authenticates the connection as dn via a
+// simple bind. Empty dn and password bind anonymously.
+func (c *ldapConn) bind(dn string, password string) error {
+
+	resp, err := c.request(berConstructedOf(ldapBindRequest,
+		berInt(berInteger, 3),
+		berString(dn),
+		berPrimitive(ldapSimpleAuth, []byte(password)),
+	), ldapBindResponse)
+	if err != nil {
+		return err
+	}
+
+	return ldapResult(resp)
+}
+
+// search returns at most sizeLimit entries below
+// base matching filter with supplied attributes.
+func (c *ldapConn) search(base string, scope int, filter string, attrs []string, sizeLimit int) ([]*ldapEntry, error) {
+
+	compiled, err := compileFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	attrList := berConstructedOf(berSequence)
+	for _, attr := range attrs {
+		attrList.children = append(attrList.children, berString(attr))
+	}
+
+	err = c.send(berConstructedOf(ldapSearchRequest,
+		berString(base),
+		berInt(berEnumerated, scope),
+		berInt(berEnumerated, 0),
+		berInt(berInteger, sizeLimit),
+		berInt(berInteger, int(c.timeout.Seconds())),
+		berPrimitive(berBoolean, []byte{0}),
+		compiled,
+		attrList,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []*ldapEntry{}
+
+	for {
+
+		resp, err := c.receive()
+		if err != nil {
+			return nil, err
+		}
+
+		switch resp.tag {
+
+		case ldapSearchEntry:
+
+			entry, err := parseEntry(resp)
+			if err != nil {
+				return nil, err
+			}
+
+			entries = append(entries, entry)
+
+		case ldapSearchReference:
+			// Referrals to other servers are not followed.
+
+		case ldapSearchDone:
+			return entries, ldapResult(resp)
+
+		default:
+			return nil, fmt.Errorf("unexpected LDAP response 0x%x", resp.tag)
+		}
+	}
+}
+
+// parseEntry decodes a SearchResultEntry.
+func parseEntry(op *berPacket) (*ldapEntry, error) {
+
+	if len(op.children) < 2 {
+		return nil, fmt.Errorf("malformed LDAP search result entry")
+	}
+
+	entry := &ldapEntry{
+		DN:         string(op.children[0].value),
+		Attributes: make(map[string][]string),
+	}
+
+	for _, attr := range op.children[1].children {
+
+		if len(attr.children) < 2 {
+			return nil, fmt.Errorf("malformed LDAP attribute in entry %s", entry.DN)
+		}
+
+		name := strings.ToLower(string(attr.children[0].value))
+		for _, value := range attr.children[1].children {
+			entry.Attributes[name] = append(entry.Attributes[name], string(value.value))
+		}
+	}
+
+	return entry, nil
+}
+
+// close unbinds and closes the connection.
+func (c *ldapConn) close() error {
+
+	c.send(berPrimitive(ldapUnbindRequest, []byte{}))
+
+	return c.conn.Close()
+}
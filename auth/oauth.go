@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Structs
+
+// oauthExchange is the server side of OAUTHBEARER
+// and, if xoauth2 is true, XOAUTH2. Both transfer
+// an OAuth 2.0 bearer token along with an optional
+// user name in key-value pairs separated by ^A.
+type oauthExchange struct {
+	verifier   TokenVerifier
+	clientAddr string
+	xoauth2    bool
+	step       int
+	failure    error
+}
+
+// Interfaces
+
+// TokenVerifier is implemented by authenticators able
+// to validate OAuth 2.0 bearer tokens. If username is
+// not empty, the token has to belong to that user.
+type TokenVerifier interface {
+	AuthenticateToken(token string, username string, clientAddr string) (*Identity, error)
+}
+
+// Functions
+
+// newOAuthExchange returns a function starting
+// OAUTHBEARER exchanges or, if xoauth2 is true,
+// XOAUTH2 exchanges.
+func newOAuthExchange(xoauth2 bool) func(verifier interface{}, conn *ConnState) (Exchange, error) {
+
+	return func(verifier interface{}, conn *ConnState) (Exchange, error) {
+
+		tokenVerifier, ok := verifier.(TokenVerifier)
+		if !ok {
+			return nil, fmt.Errorf("authenticator is unable to validate OAuth tokens")
+		}
+
+		return &oauthExchange{
+			verifier:   tokenVerifier,
+			clientAddr: conn.ClientAddr,
+			xoauth2:    xoauth2,
+		}, nil
+	}
+}
+
+// parseOAuthBearer parses the initial client response
+// of OAUTHBEARER and returns user name and token.
+func parseOAuthBearer(response string) (string, string, error) {
+
+	parts := strings.Split(response, "\x01")
+	if (len(parts) < 3) || (parts[(len(parts)-1)] != "") || (parts[(len(parts)-2)] != "") {
+		return "", "", fmt.Errorf("malformed OAUTHBEARER response")
+	}
+
+	// The GS2 header states that channel binding is not
+	// used, followed by the optional authorization identity.
+	gs2 := strings.Split(parts[0], ",")
+	if (len(gs2) != 3) || ((gs2[0] != "n") && (gs2[0] != "y")) || (gs2[2] != "") {
+		return "", "", fmt.Errorf("malformed or unsupported OAUTHBEARER GS2 header")
+	}
+
+	username := ""
+	if gs2[1] != "" {
+
+		if !strings.HasPrefix(gs2[1], "a=") {
+			return "", "", fmt.Errorf("malformed OAUTHBEARER authorization identity")
+		}
+
+		var err error
+		username, err = scramUsername(gs2[1][2:])
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	token, err := bearerToken(parts[1:(len(parts) - 2)])
+	if err != nil {
+		return "", "", err
+	}
+
+	return username, token, nil
+}
+
+// parseXOAuth2 parses the client response of
+// XOAUTH2 and returns user name and token.
+func parseXOAuth2(response string) (string, string, error) {
+
+	parts := strings.Split(response, "\x01")
+	if (len(parts) < 4) || (parts[(len(parts)-1)] != "") || (parts[(len(parts)-2)] != "") || !strings.HasPrefix(parts[0], "user=") {
+		return "", "", fmt.Errorf("malformed XOAUTH2 response")
+	}
+
+	token, err := bearerToken(parts[1:(len(parts) - 2)])
+	if err != nil {
+		return "", "", err
+	}
+
+	return strings.TrimPrefix(parts[0], "user="), token, nil
+}
+
+// bearerToken returns the token contained in the
+// auth value of supplied key-value pairs.
+func bearerToken(pairs []string) (string, error) {
+
+	for _, pair := range pairs {
+
+		kv := strings.SplitN(pair, "=", 2)
+		if (len(kv) != 2) || (kv[0] != "auth") {
+			continue
+		}
+
+		scheme := strings.SplitN(kv[1], " ", 2)
+		if (len(scheme) != 2) || !strings.EqualFold(scheme[0], "Bearer") || (strings.TrimSpace(scheme[1]) == "") {
+			return "", fmt.Errorf("auth value does not contain a bearer token")
+		}
+
+		return strings.TrimSpace(scheme[1]), nil
+	}
+
+	return "", fmt.Errorf("no auth value in OAuth response")
+}
+
+// Next implements Exchange.
+func (e *oauthExchange) Next(response []byte) ([]byte, *Identity, error) {
+
+	// Ask for the token if the client did
+	// not send an initial response.
+	if (response == nil) && (e.step == 0) {
+		return []byte{}, nil, nil
+	}
+
+	e.step++
+
+	// After a failed validation, the client only
+	// acknowledges our error details.
+	if e.failure != nil {
+		return nil, nil, e.failure
+	}
+
+	if e.step > 1 {
+		return nil, nil, fmt.Errorf("OAuth exchange already completed")
+	}
+
+	var username, token string
+	var err error
+
+	if e.xoauth2 {
+		username, token, err = parseXOAuth2(string(response))
+	} else {
+		username, token, err = parseOAuthBearer(string(response))
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	identity, err := e.verifier.AuthenticateToken(token, username, e.clientAddr)
+	if err != nil {
+
+		// Tell the client why the token was refused
+		// and fail once it acknowledged that.
+		e.failure = err
+
+		if e.xoauth2 {
+			return []byte(`{"status":"401","schemes":"bearer"}`), nil, nil
+		}
+
+		return []byte(`{"status":"invalid_token","schemes":"bearer"}`), nil, nil
+	}
+
+	return nil, identity, nil
+}
+
+// init registers OAUTHBEARER and XOAUTH2, which
+// must not send bearer tokens in cleartext.
+func init() {
+	RegisterMechanism(&Mechanism{Name: MechanismOAuthBearer, RequiresTLS: true, New: newOAuthExchange(false)})
+	RegisterMechanism(&Mechanism{Name: MechanismXOAuth2, RequiresTLS: true, New: newOAuthExchange(true)})
+}
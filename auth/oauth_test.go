@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/go-pluto/pluto/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// Variables
+
+// testWorkers split user IDs between two workers.
+var testWorkers = map[string]config.Worker{
+	"worker-1": config.Worker{Name: "worker-1", UserStart: 1, UserEnd: 10},
+	"worker-2": config.Worker{Name: "worker-2", UserStart: 11, UserEnd: 20},
+}
+
+// Functions
+
+// signToken returns a JWT carrying claims
+// signed by key via RS256.
+func signToken(t *testing.T, key *rsa.PrivateKey, claims map[string]interface{}) string {
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT","kid":"test-key"}`))
+
+	rawClaims, err := json.Marshal(claims)
+	assert.Nilf(t, err, "expected claims to marshal but got: %v", err)
+
+	input := fmt.Sprintf("%s.%s", header, base64.RawURLEncoding.EncodeToString(rawClaims))
+	digest := sha256.Sum256([]byte(input))
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	assert.Nilf(t, err, "expected signing not to fail but got: %v", err)
+
+	return fmt.Sprintf("%s.%s", input, base64.RawURLEncoding.EncodeToString(sig))
+}
+
+// TestOAuthJWKS executes a white-box unit test on
+// offline validation of JWTs against a JWKS file.
+func TestOAuthJWKS(t *testing.T) {
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nilf(t, err, "expected key generation not to fail but got: %v", err)
+
+	jwks := fmt.Sprintf(`{"keys":[{"kty":"RSA","kid":"test-key","use":"sig","n":"%s","e":"%s"}]}`,
+		base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()))
+
+	dir, err := ioutil.TempDir("", "pluto-oauth")
+	assert.Nilf(t, err, "expected temporary directory but got: %v", err)
+	defer os.RemoveAll(dir)
+
+	jwksFile := filepath.Join(dir, "jwks.json")
+	err = ioutil.WriteFile(jwksFile, []byte(jwks), 0600)
+	assert.Nilf(t, err, "expected JWKS file to be written but got: %v", err)
+
+	// JWTs of other applications have to be told apart.
+	_, err = NewOAuthAuthenticator(&config.AuthOAuth{
+		JWKSFile: jwksFile,
+		Issuer:   "https://sso.example.com/",
+		IDClaim:  "uid",
+	})
+	assert.NotNilf(t, err, "expected JWKS config without Audience to be refused")
+
+	_, err = NewOAuthAuthenticator(&config.AuthOAuth{
+		JWKSFile: jwksFile,
+		Audience: "pluto",
+		IDClaim:  "uid",
+	})
+	assert.NotNilf(t, err, "expected JWKS config without Issuer to be refused")
+
+	// Users have to be routed by their user ID.
+	_, err = NewOAuthAuthenticator(&config.AuthOAuth{
+		JWKSFile: jwksFile,
+		Issuer:   "https://sso.example.com/",
+		Audience: "pluto",
+	})
+	assert.NotNilf(t, err, "expected config without IDClaim to be refused")
+
+	o, err := NewOAuthAuthenticator(&config.AuthOAuth{
+		JWKSFile:      jwksFile,
+		Issuer:        "https://sso.example.com/",
+		Audience:      "pluto",
+		UsernameClaim: "preferred_username",
+		IDClaim:       "uid",
+	})
+	assert.Nilf(t, err, "expected NewOAuthAuthenticator() not to fail but got: %v", err)
+
+	claims := map[string]interface{}{
+		"iss":                "https://sso.example.com/",
+		"aud":                []string{"pluto", "webmail"},
+		"sub":                "2b1c7f9e",
+		"preferred_username": "user",
+		"uid":                14,
+		"exp":                time.Now().Add(time.Hour).Unix(),
+	}
+
+	identity, err := o.AuthenticateToken(signToken(t, key, claims), "", "127.0.0.1:4321")
+	assert.Nilf(t, err, "expected valid token to be accepted but got: %v", err)
+	assert.Equalf(t, "user", identity.UserName, "expected user name of username claim")
+	assert.Equalf(t, "127.0.0.1:4321:user", identity.ClientID, "unexpected client ID")
+
+	// The user ID of the token decides on the worker,
+	// just as it does for logins with a password.
+	assert.Equalf(t, 14, identity.ID, "expected user ID of ID claim")
+
+	worker, err := o.GetWorkerForUser(testWorkers, identity.ID)
+	assert.Nilf(t, err, "expected a worker for user ID but got: %v", err)
+	assert.Equalf(t, "worker-2", worker, "unexpected worker for user ID")
+
+	// Tokens without a valid user ID are refused
+	// instead of being assigned a made-up one.
+	for _, uid := range []interface{}{nil, "", "user", 1.5, -3} {
+
+		claims["uid"] = uid
+		_, err = o.AuthenticateToken(signToken(t, key, claims), "", "127.0.0.1:4321")
+		assert.NotNilf(t, err, "expected token with user ID %v to be refused", uid)
+	}
+
+	claims["uid"] = "14"
+	identity, err = o.AuthenticateToken(signToken(t, key, claims), "", "127.0.0.1:4321")
+	assert.Nilf(t, err, "expected numeric string as user ID to be accepted but got: %v", err)
+	assert.Equalf(t, 14, identity.ID, "expected user ID of ID claim")
+
+	// Naming the token's user is fine, others are not.
+	_, err = o.AuthenticateToken(signToken(t, key, claims), "user", "127.0.0.1:4321")
+	assert.Nilf(t, err, "expected token of named user to be accepted but got: %v", err)
+
+	_, err = o.AuthenticateToken(signToken(t, key, claims), "other", "127.0.0.1:4321")
+	assert.NotNilf(t, err, "expected token of other user to be refused")
+
+	claims["aud"] = "webmail"
+	_, err = o.AuthenticateToken(signToken(t, key, claims), "", "127.0.0.1:4321")
+	assert.NotNilf(t, err, "expected token for other audience to be refused")
+
+	claims["aud"] = "pluto"
+	claims["exp"] = time.Now().Add(-time.Hour).Unix()
+	_, err = o.AuthenticateToken(signToken(t, key, claims), "", "127.0.0.1:4321")
+	assert.NotNilf(t, err, "expected expired token to be refused")
+
+	// Tokens signed by other keys fail.
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	claims["exp"] = time.Now().Add(time.Hour).Unix()
+	_, err = o.AuthenticateToken(signToken(t, otherKey, claims), "", "127.0.0.1:4321")
+	assert.NotNilf(t, err, "expected token with wrong signature to be refused")
+}
+
+// TestOAuthIntrospection executes a white-box unit
+// test on validating tokens via an introspection
+// endpoint.
+func TestOAuthIntrospection(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		id, secret, ok := r.BasicAuth()
+		if !ok || (id != "pluto") || (secret != "secret") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.PostFormValue("token") != "valid-token" {
+			fmt.Fprint(w, `{"active":false}`)
+			return
+		}
+
+		fmt.Fprint(w, `{"active":true,"sub":"2b1c7f9e","username":"user","uid":3,"aud":"pluto"}`)
+	}))
+	defer server.Close()
+
+	o, err := NewOAuthAuthenticator(&config.AuthOAuth{
+		IntrospectionURL:          server.URL,
+		IntrospectionClientID:     "pluto",
+		IntrospectionClientSecret: "secret",
+		Audience:                  "pluto",
+		UsernameClaim:             "username",
+		IDClaim:                   "uid",
+	})
+	assert.Nilf(t, err, "expected NewOAuthAuthenticator() not to fail but got: %v", err)
+
+	conn := &ConnState{
+		ClientAddr: "127.0.0.1:4321",
+		TLS:        &tls.ConnectionState{},
+	}
+
+	// OAUTHBEARER with initial response.
+	exchange, err := NewExchange(MechanismOAuthBearer, o, conn)
+	assert.Nilf(t, err, "expected NewExchange() not to fail but got: %v", err)
+
+	_, identity, err := exchange.Next([]byte("n,a=user,\x01host=imap.example.com\x01port=993\x01auth=Bearer valid-token\x01\x01"))
+	assert.Nilf(t, err, "expected successful authentication but got error: %v", err)
+	assert.Equalf(t, "user", identity.UserName, "unexpected user name")
+	assert.Equalf(t, 3, identity.ID, "expected user ID of introspection response")
+
+	// A refused token leads to an error challenge
+	// and fails after the client acknowledged it.
+	exchange, _ = NewExchange(MechanismOAuthBearer, o, conn)
+	challenge, identity, err := exchange.Next([]byte("n,,\x01auth=Bearer revoked-token\x01\x01"))
+	assert.Nilf(t, err, "expected error challenge but got error: %v", err)
+	assert.Nilf(t, identity, "expected no identity for refused token")
+	assert.Equalf(t, `{"status":"invalid_token","schemes":"bearer"}`, string(challenge), "unexpected error challenge")
+
+	_, _, err = exchange.Next([]byte("\x01"))
+	assert.NotNilf(t, err, "expected refused token to fail")
+
+	// XOAUTH2 without initial response.
+	exchange, err = NewExchange(MechanismXOAuth2, o, conn)
+	assert.Nilf(t, err, "expected NewExchange() not to fail but got: %v", err)
+
+	challenge, _, _ = exchange.Next(nil)
+	assert.Equalf(t, []byte{}, challenge, "expected empty challenge without initial response")
+
+	_, identity, err = exchange.Next([]byte("user=user\x01auth=Bearer valid-token\x01\x01"))
+	assert.Nilf(t, err, "expected successful authentication but got error: %v", err)
+	assert.Equalf(t, "user", identity.UserName, "unexpected user name")
+
+	// Bearer tokens must not be sent in cleartext.
+	_, err = NewExchange(MechanismXOAuth2, o, &ConnState{ClientAddr: "127.0.0.1:4321"})
+	assert.NotNilf(t, err, "expected XOAUTH2 to require TLS")
+}
@@ -0,0 +1,432 @@
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Constants
+
+// Password schemes in the notation of Dovecot, which
+// prefixes stored hashes with the scheme in braces.
+const (
+	// SchemePlain stores the password itself.
+	SchemePlain = "PLAIN"
+	// SchemeSHA512 stores an unsalted SHA-512 hash
+	// and is only accepted for existing entries.
+	SchemeSHA512 = "SHA512"
+	// SchemeSSHA512 stores a salted SHA-512 hash.
+	SchemeSSHA512 = "SSHA512"
+	// SchemeSHA512Crypt stores a hash of the
+	// SHA-crypt family as used in /etc/shadow.
+	SchemeSHA512Crypt = "SHA512-CRYPT"
+	// SchemeBcrypt stores a bcrypt hash.
+	SchemeBcrypt = "BLF-CRYPT"
+	// SchemeArgon2ID stores an Argon2id hash
+	// in the PHC string format.
+	SchemeArgon2ID = "ARGON2ID"
+
+	// PreferredScheme is the scheme new hashes are
+	// produced with and old hashes are upgraded to.
+	PreferredScheme = SchemeArgon2ID
+)
+
+const (
+	// Argon2id parameters of newly produced hashes.
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 1
+	argon2KeyLen  = 32
+
+	// bcryptCost is the cost of new bcrypt hashes.
+	bcryptCost = 12
+
+	// passwordSaltLength is the number of random
+	// bytes of salts pluto generates.
+	passwordSaltLength = 16
+
+	// SHA-crypt rounds limits and default.
+	sha512CryptDefaultRounds = 5000
+	sha512CryptMinRounds     = 1000
+	sha512CryptMaxRounds     = 999999999
+
+	// cryptAlphabet is the base64 alphabet of
+	// crypt(3) style hashes.
+	cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+// Functions
+
+// splitScheme returns the scheme prefixed to hashed
+// and the hash itself. Hashes without prefix are of
+// scheme defaultScheme.
+func splitScheme(hashed string, defaultScheme string) (string, string) {
+
+	if strings.HasPrefix(hashed, "{") {
+
+		end := strings.IndexByte(hashed, '}')
+		if end > 0 {
+			return strings.ToUpper(hashed[1:end]), hashed[(end + 1):]
+		}
+	}
+
+	return defaultScheme, hashed
+}
+
+// randomSalt returns passwordSaltLength random bytes.
+func randomSalt() ([]byte, error) {
+
+	salt := make([]byte, passwordSaltLength)
+
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %v", err)
+	}
+
+	return salt, nil
+}
+
+// HashPassword hashes password with supplied scheme
+// and returns it prefixed with the scheme.
+func HashPassword(scheme string, password string) (string, error) {
+
+	var hash string
+
+	switch strings.ToUpper(scheme) {
+
+	case SchemePlain:
+		hash = password
+
+	case SchemeSSHA512:
+
+		salt, err := randomSalt()
+		if err != nil {
+			return "", err
+		}
+
+		sum := sha512.Sum512(append([]byte(password), salt...))
+		hash = base64.StdEncoding.EncodeToString(append(sum[:], salt...))
+
+	case SchemeSHA512Crypt:
+
+		salt, err := randomSalt()
+		if err != nil {
+			return "", err
+		}
+
+		// Salts of SHA-crypt consist of
+		// characters of its alphabet.
+		for i := range salt {
+			salt[i] = cryptAlphabet[(int(salt[i]) % len(cryptAlphabet))]
+		}
+
+		hash = sha512Crypt([]byte(password), salt, sha512CryptDefaultRounds, false)
+
+	case SchemeBcrypt:
+
+		raw, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate bcrypt hash: %v", err)
+		}
+
+		hash = string(raw)
+
+	case SchemeArgon2ID:
+
+		salt, err := randomSalt()
+		if err != nil {
+			return "", err
+		}
+
+		key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+		hash = fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argon2Memory, argon2Time, argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+
+	default:
+		return "", fmt.Errorf("unsupported password scheme %s", scheme)
+	}
+
+	return fmt.Sprintf("{%s}%s", strings.ToUpper(scheme), hash), nil
+}
+
+// VerifyPassword reports whether password matches the
+// stored hash in constant time. Hashes without scheme
+// prefix are of scheme defaultScheme.
+func VerifyPassword(hashed string, password string, defaultScheme string) (bool, error) {
+
+	scheme, hash := splitScheme(hashed, defaultScheme)
+
+	switch scheme {
+
+	case SchemePlain:
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(password)) == 1, nil
+
+	case SchemeSHA512:
+
+		sum := sha512.Sum512([]byte(password))
+
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(base64.StdEncoding.EncodeToString(sum[:]))) == 1, nil
+
+	case SchemeSSHA512:
+
+		raw, err := base64.StdEncoding.DecodeString(hash)
+		if (err != nil) || (len(raw) <= sha512.Size) {
+			return false, fmt.Errorf("malformed SSHA512 hash")
+		}
+
+		sum := sha512.Sum512(append([]byte(password), raw[sha512.Size:]...))
+
+		return subtle.ConstantTimeCompare(raw[:sha512.Size], sum[:]) == 1, nil
+
+	case SchemeSHA512Crypt:
+
+		salt, rounds, custom, err := parseSHA512Crypt(hash)
+		if err != nil {
+			return false, err
+		}
+
+		computed := sha512Crypt([]byte(password), salt, rounds, custom)
+
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(computed)) == 1, nil
+
+	case SchemeBcrypt:
+
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		} else if err != nil {
+			return false, fmt.Errorf("malformed bcrypt hash: %v", err)
+		}
+
+		return true, nil
+
+	case SchemeArgon2ID:
+
+		salt, key, memory, time, threads, err := parseArgon2ID(hash)
+		if err != nil {
+			return false, err
+		}
+
+		computed := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+
+		return subtle.ConstantTimeCompare(key, computed) == 1, nil
+	}
+
+	return false, fmt.Errorf("unsupported password scheme %s", scheme)
+}
+
+// NeedsRehash reports whether hashed is not of the
+// preferred scheme or uses weaker parameters than
+// hashes pluto produces now.
+func NeedsRehash(hashed string, defaultScheme string) bool {
+
+	scheme, hash := splitScheme(hashed, defaultScheme)
+	if scheme != PreferredScheme {
+		return true
+	}
+
+	_, _, memory, time, threads, err := parseArgon2ID(hash)
+	if err != nil {
+		return true
+	}
+
+	return (memory < argon2Memory) || (time < argon2Time) || (threads < argon2Threads)
+}
+
+// parseArgon2ID parses an Argon2id hash of the form
+// $argon2id$v=19$m=65536,t=3,p=1$<salt>$<key>.
+func parseArgon2ID(hash string) ([]byte, []byte, uint32, uint32, uint8, error) {
+
+	var version int
+	var memory, time uint32
+	var threads uint8
+
+	parts := strings.Split(hash, "$")
+	if (len(parts) != 6) || (parts[1] != "argon2id") {
+		return nil, nil, 0, 0, 0, fmt.Errorf("malformed ARGON2ID hash")
+	}
+
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if (err != nil) || (version != argon2.Version) {
+		return nil, nil, 0, 0, 0, fmt.Errorf("unsupported Argon2 version in ARGON2ID hash")
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads)
+	if (err != nil) || (memory == 0) || (time == 0) || (threads == 0) {
+		return nil, nil, 0, 0, 0, fmt.Errorf("malformed parameters in ARGON2ID hash")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, 0, 0, 0, fmt.Errorf("malformed salt in ARGON2ID hash")
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if (err != nil) || (len(key) == 0) {
+		return nil, nil, 0, 0, 0, fmt.Errorf("malformed key in ARGON2ID hash")
+	}
+
+	return salt, key, memory, time, threads, nil
+}
+
+// parseSHA512Crypt parses a hash of the form
+// $6$[rounds=N$]<salt>$<hash> and returns salt
+// and rounds and whether rounds were stated.
+func parseSHA512Crypt(hash string) ([]byte, int, bool, error) {
+
+	if !strings.HasPrefix(hash, "$6$") {
+		return nil, 0, false, fmt.Errorf("malformed SHA512-CRYPT hash")
+	}
+
+	parts := strings.Split(hash[3:], "$")
+	rounds := sha512CryptDefaultRounds
+	custom := false
+
+	if strings.HasPrefix(parts[0], "rounds=") {
+
+		var err error
+
+		rounds, err = strconv.Atoi(strings.TrimPrefix(parts[0], "rounds="))
+		if err != nil {
+			return nil, 0, false, fmt.Errorf("malformed rounds in SHA512-CRYPT hash")
+		}
+
+		parts = parts[1:]
+		custom = true
+	}
+
+	if len(parts) != 2 {
+		return nil, 0, false, fmt.Errorf("malformed SHA512-CRYPT hash")
+	}
+
+	return []byte(parts[0]), rounds, custom, nil
+}
+
+// sha512Crypt computes the SHA-crypt hash of
+// password with salt as specified by Ulrich Drepper
+// in "Unix crypt using SHA-256 and SHA-512".
+func sha512Crypt(password []byte, salt []byte, rounds int, custom bool) string {
+
+	if len(salt) > 16 {
+		salt = salt[:16]
+	}
+
+	if rounds < sha512CryptMinRounds {
+		rounds = sha512CryptMinRounds
+	} else if rounds > sha512CryptMaxRounds {
+		rounds = sha512CryptMaxRounds
+	}
+
+	// Digest B of password, salt, password.
+	b := sha512.New()
+	b.Write(password)
+	b.Write(salt)
+	b.Write(password)
+	sumB := b.Sum(nil)
+
+	// Digest A of password, salt, B for each byte
+	// of the password, and B or password for each
+	// bit of the password's length.
+	a := sha512.New()
+	a.Write(password)
+	a.Write(salt)
+
+	cnt := len(password)
+	for ; cnt > 64; cnt -= 64 {
+		a.Write(sumB)
+	}
+	a.Write(sumB[:cnt])
+
+	for cnt = len(password); cnt > 0; cnt >>= 1 {
+
+		if (cnt & 1) != 0 {
+			a.Write(sumB)
+		} else {
+			a.Write(password)
+		}
+	}
+
+	sumA := a.Sum(nil)
+
+	// Byte sequence P derived from the password.
+	dp := sha512.New()
+	for i := 0; i < len(password); i++ {
+		dp.Write(password)
+	}
+
+	p := bytes.Repeat(dp.Sum(nil), ((len(password) / 64) + 1))[:len(password)]
+
+	// Byte sequence S derived from the salt.
+	ds := sha512.New()
+	for i := 0; i < (16 + int(sumA[0])); i++ {
+		ds.Write(salt)
+	}
+
+	s := ds.Sum(nil)[:len(salt)]
+
+	// Stretch the digest over the supplied rounds.
+	sum := sumA
+	for i := 0; i < rounds; i++ {
+
+		c := sha512.New()
+
+		if (i & 1) != 0 {
+			c.Write(p)
+		} else {
+			c.Write(sum)
+		}
+
+		if (i % 3) != 0 {
+			c.Write(s)
+		}
+
+		if (i % 7) != 0 {
+			c.Write(p)
+		}
+
+		if (i & 1) != 0 {
+			c.Write(sum)
+		} else {
+			c.Write(p)
+		}
+
+		sum = c.Sum(nil)
+	}
+
+	var out strings.Builder
+
+	out.WriteString("$6$")
+	if custom {
+		fmt.Fprintf(&out, "rounds=%d$", rounds)
+	}
+	out.Write(salt)
+	out.WriteByte('$')
+
+	// Encode the digest in the byte order of
+	// the specification, three bytes at a time.
+	encode := func(b2 byte, b1 byte, b0 byte, n int) {
+
+		w := (uint(b2) << 16) | (uint(b1) << 8) | uint(b0)
+		for ; n > 0; n-- {
+			out.WriteByte(cryptAlphabet[(w & 0x3f)])
+			w >>= 6
+		}
+	}
+
+	for i := 0; i < 21; i++ {
+		encode(sum[((i*22)%63)], sum[(((i*22)+21)%63)], sum[(((i*22)+42)%63)], 4)
+	}
+	encode(0, 0, sum[63], 2)
+
+	return out.String()
+}
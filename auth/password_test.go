@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Variables
+
+// storedHashes contains hashes of the password
+// "Hello world!" as produced by other software.
+var storedHashes = []string{
+	"{SHA512-CRYPT}$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1",
+	"{SHA512-CRYPT}$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v.",
+	"{PLAIN}Hello world!",
+	"{SHA512}9s3ioPgZMUzd5V/CJ9jX2uPSjMVWIioKitZtkcytSq1glPUXohgjYMmqz2o9wyMWLLb9jN/+2w/gOPVehf+1tg==",
+}
+
+// Functions
+
+// TestVerifyPassword executes a white-box unit test
+// on verifying passwords against stored hashes.
+func TestVerifyPassword(t *testing.T) {
+
+	for _, hashed := range storedHashes {
+
+		match, err := VerifyPassword(hashed, "Hello world!", SchemePlain)
+		assert.Nilf(t, err, "expected %s to be verified but got: %v", hashed, err)
+		assert.Truef(t, match, "expected %s to match", hashed)
+
+		match, err = VerifyPassword(hashed, "Hello world?", SchemePlain)
+		assert.Nilf(t, err, "expected %s to be verified but got: %v", hashed, err)
+		assert.Falsef(t, match, "expected %s not to match wrong password", hashed)
+	}
+
+	// Entries without prefix are of the default scheme.
+	match, _ := VerifyPassword("Hello world!", "Hello world!", SchemePlain)
+	assert.Truef(t, match, "expected unprefixed plain password to match")
+
+	match, _ = VerifyPassword("9s3ioPgZMUzd5V/CJ9jX2uPSjMVWIioKitZtkcytSq1glPUXohgjYMmqz2o9wyMWLLb9jN/+2w/gOPVehf+1tg==", "Hello world!", SchemeSHA512)
+	assert.Truef(t, match, "expected unprefixed SHA512 hash to match")
+
+	_, err := VerifyPassword("{MD5}abc", "Hello world!", SchemePlain)
+	assert.NotNilf(t, err, "expected unsupported scheme to fail")
+}
+
+// TestHashPassword executes a white-box unit test
+// on producing hashes of all supported schemes.
+func TestHashPassword(t *testing.T) {
+
+	for _, scheme := range []string{SchemePlain, SchemeSSHA512, SchemeSHA512Crypt, SchemeBcrypt, SchemeArgon2ID} {
+
+		hashed, err := HashPassword(scheme, "correct horse")
+		assert.Nilf(t, err, "expected %s hash but got: %v", scheme, err)
+
+		match, err := VerifyPassword(hashed, "correct horse", SchemePlain)
+		assert.Nilf(t, err, "expected %s hash to be verified but got: %v", scheme, err)
+		assert.Truef(t, match, "expected %s hash to match", scheme)
+
+		match, _ = VerifyPassword(hashed, "battery staple", SchemePlain)
+		assert.Falsef(t, match, "expected %s hash not to match wrong password", scheme)
+
+		assert.Equalf(t, (scheme != PreferredScheme), NeedsRehash(hashed, SchemePlain), "unexpected rehash decision for %s", scheme)
+	}
+
+	// Bcrypt hashes of Dovecot carry the 2y prefix.
+	match, err := VerifyPassword("{BLF-CRYPT}$2y$05$ki7SmsW.f2mIDfi1AoumguaSsWTBGljeqWbEHr58Qbpj9dX.Z4OKO", "Hello world!", SchemePlain)
+	assert.Nilf(t, err, "expected 2y bcrypt hash to be verified but got: %v", err)
+	assert.Truef(t, match, "expected 2y bcrypt hash to match")
+}
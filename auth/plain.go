@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Structs
+
+// plainExchange is the server side of the PLAIN
+// and LOGIN mechanisms, which both transfer the
+// user's password to the server.
+type plainExchange struct {
+	verifier   PasswordVerifier
+	clientAddr string
+	login      bool
+	step       int
+	username   string
+}
+
+// Functions
+
+// newPlainExchange returns a function starting PLAIN
+// exchanges or, if login is true, LOGIN exchanges.
+func newPlainExchange(login bool) func(verifier interface{}, conn *ConnState) (Exchange, error) {
+
+	return func(verifier interface{}, conn *ConnState) (Exchange, error) {
+
+		passwordVerifier, ok := verifier.(PasswordVerifier)
+		if !ok {
+			return nil, fmt.Errorf("authenticator is unable to verify passwords")
+		}
+
+		return &plainExchange{
+			verifier:   passwordVerifier,
+			clientAddr: conn.ClientAddr,
+			login:      login,
+		}, nil
+	}
+}
+
+// Next implements Exchange.
+func (e *plainExchange) Next(response []byte) ([]byte, *Identity, error) {
+
+	e.step++
+
+	if e.login {
+		return e.nextLogin(response)
+	}
+
+	// Ask for the credentials if the client
+	// did not send an initial response.
+	if (response == nil) && (e.step == 1) {
+		return []byte{}, nil, nil
+	}
+
+	// The response consists of authorization identity,
+	// user name, and password separated by NUL.
+	parts := bytes.Split(response, []byte{0})
+	if len(parts) != 3 {
+		return nil, nil, fmt.Errorf("malformed PLAIN response")
+	}
+
+	// Acting as another user is not supported.
+	if (len(parts[0]) > 0) && !bytes.Equal(parts[0], parts[1]) {
+		return nil, nil, fmt.Errorf("authorization identity differs from user name")
+	}
+
+	return e.verify(string(parts[1]), string(parts[2]))
+}
+
+// nextLogin performs one step of a LOGIN exchange,
+// which asks for user name and password one after
+// the other. An initial response is the user name.
+func (e *plainExchange) nextLogin(response []byte) ([]byte, *Identity, error) {
+
+	if (response == nil) && (e.step == 1) {
+		e.step = 0
+		return []byte("Username:"), nil, nil
+	}
+
+	if e.step == 1 {
+		e.username = string(response)
+		return []byte("Password:"), nil, nil
+	}
+
+	return e.verify(e.username, string(response))
+}
+
+// verify checks the supplied credentials.
+func (e *plainExchange) verify(username string, password string) ([]byte, *Identity, error) {
+
+	id, clientID, err := e.verifier.AuthenticatePlain(username, password, e.clientAddr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nil, &Identity{
+		ID:       id,
+		ClientID: clientID,
+		UserName: username,
+	}, nil
+}
+
+// init registers the PLAIN and LOGIN mechanisms,
+// which must not send passwords in cleartext.
+func init() {
+	RegisterMechanism(&Mechanism{Name: MechanismPlain, RequiresTLS: true, New: newPlainExchange(false)})
+	RegisterMechanism(&Mechanism{Name: MechanismLogin, RequiresTLS: true, New: newPlainExchange(true)})
+}
@@ -2,59 +2,280 @@ package auth
 
 import (
 	"fmt"
+	"net"
+	"strings"
+	"time"
 
-	"crypto/sha512"
 	"crypto/tls"
-	"encoding/base64"
 
 	"github.com/go-pluto/pluto/config"
 	"gopkg.in/jackc/pgx.v2"
 )
 
+// Constants
+
+const (
+	// Defaults of the PostgreSQL authenticator
+	// for unset values of its configuration.
+	postgresDefaultMaxConnections = 5
+	postgresDefaultConnectTimeout = 10 * time.Second
+	postgresDefaultQueryTimeout   = 5 * time.Second
+	postgresDefaultHealthInterval = 30 * time.Second
+
+	// Names of the prepared statements.
+	postgresStmtUser   = "pluto_auth_user"
+	postgresStmtRehash = "pluto_auth_rehash"
+)
+
 // Structs
 
 // PostgresAuthenticator carries all relevant information
 // needed to allow the PostgreSQL-based authenticator to
 // properly authenticate incoming client requests.
 type PostgresAuthenticator struct {
-	Conn *pgx.Conn
+	Pool          *pgx.ConnPool
+	Config        *config.AuthPostgres
+	rehash        bool
+	stopHealthChk chan struct{}
+}
+
+// deadlineConn is a connection to the database that
+// sets a deadline on every write. As every query
+// starts with one, each query has to be answered
+// within timeout.
+type deadlineConn struct {
+	net.Conn
+	timeout time.Duration
 }
 
 // Functions
 
+// Write implements net.Conn.
+func (c *deadlineConn) Write(b []byte) (int, error) {
+
+	c.Conn.SetDeadline(time.Now().Add(c.timeout))
+
+	return c.Conn.Write(b)
+}
+
+// sanitizeIdentifier quotes a possibly schema-qualified
+// table or column name to be used in a query.
+func sanitizeIdentifier(name string) string {
+	return pgx.Identifier(strings.Split(name, ".")).Sanitize()
+}
+
 // NewPostgresAuthenticator expects to be supplied with
 // PostgreSQL database connection information from the
-// config file. It then tries to connect to the database
-// and returns an initialized struct above.
-func NewPostgresAuthenticator(ip string, port uint16, db string, user string, password string, useTLS bool) (*PostgresAuthenticator, error) {
+// config file. It then sets up a pool of connections to
+// the database, prepares the statements looking up users
+// on it, and returns an initialized struct above.
+func NewPostgresAuthenticator(conf *config.AuthPostgres) (*PostgresAuthenticator, error) {
+
+	// Fill in the defaults matching the users
+	// table pluto has always worked with.
+	if conf.Table == "" {
+		conf.Table = "users"
+	}
+
+	if conf.IDColumn == "" {
+		conf.IDColumn = "id"
+	}
+
+	if conf.UsernameColumn == "" {
+		conf.UsernameColumn = "username"
+	}
+
+	if conf.PasswordColumn == "" {
+		conf.PasswordColumn = "password"
+	}
+
+	if conf.DefaultScheme == "" {
+		conf.DefaultScheme = SchemeSHA512
+	}
+
+	if conf.MaxConnections < 2 {
+		conf.MaxConnections = postgresDefaultMaxConnections
+	}
+
+	connectTimeout := postgresDefaultConnectTimeout
+	if conf.ConnectTimeout > 0 {
+		connectTimeout = time.Duration(conf.ConnectTimeout) * time.Second
+	}
+
+	queryTimeout := postgresDefaultQueryTimeout
+	if conf.QueryTimeout > 0 {
+		queryTimeout = time.Duration(conf.QueryTimeout) * time.Second
+	}
+
+	healthInterval := postgresDefaultHealthInterval
+	if conf.HealthCheckInterval > 0 {
+		healthInterval = time.Duration(conf.HealthCheckInterval) * time.Second
+	}
+
+	// Without a custom query, upgraded hashes are written
+	// back to the configured table. Custom queries require
+	// a custom statement for that, too.
+	userQuery := conf.UserQuery
+	rehashQuery := conf.RehashQuery
+
+	if userQuery == "" {
+
+		userQuery = fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s = $1",
+			sanitizeIdentifier(conf.IDColumn), sanitizeIdentifier(conf.PasswordColumn),
+			sanitizeIdentifier(conf.Table), sanitizeIdentifier(conf.UsernameColumn))
+
+		if rehashQuery == "" {
+			rehashQuery = fmt.Sprintf("UPDATE %s SET %s = $1 WHERE %s = $2",
+				sanitizeIdentifier(conf.Table), sanitizeIdentifier(conf.PasswordColumn),
+				sanitizeIdentifier(conf.IDColumn))
+		}
+	}
 
 	// Prepare a default TLS config if useTLS is set to true.
 	// Otherwise, this config will be nil and therefore disable TLS.
 	var dbTLSConfig *tls.Config
-	if useTLS {
+	if conf.UseTLS {
 		dbTLSConfig = new(tls.Config)
 	}
 
-	// Create a new connection config using the imported pgx drivers.
-	connConfig := pgx.ConnConfig{
-		Host:           ip,
-		Port:           port,
-		Database:       db,
-		User:           user,
-		Password:       password,
-		TLSConfig:      dbTLSConfig,
-		UseFallbackTLS: false,
+	dialer := &net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 5 * time.Minute,
+	}
+
+	// Create a new pool config using the imported pgx drivers.
+	poolConfig := pgx.ConnPoolConfig{
+		ConnConfig: pgx.ConnConfig{
+			Host:           conf.IP,
+			Port:           conf.Port,
+			Database:       conf.Database,
+			User:           conf.User,
+			Password:       conf.Password,
+			TLSConfig:      dbTLSConfig,
+			UseFallbackTLS: false,
+			Dial: func(network string, addr string) (net.Conn, error) {
+
+				conn, err := dialer.Dial(network, addr)
+				if err != nil {
+					return nil, err
+				}
+
+				return &deadlineConn{Conn: conn, timeout: queryTimeout}, nil
+			},
+		},
+		MaxConnections: conf.MaxConnections,
+		AcquireTimeout: queryTimeout,
 	}
 
 	// Connect to PostgreSQL database based on above config.
-	conn, err := pgx.Connect(connConfig)
+	pool, err := pgx.NewConnPool(poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("could not connect to specified PostgreSQL database: %v", err)
 	}
 
-	return &PostgresAuthenticator{
-		Conn: conn,
-	}, nil
+	// Prepared statements are available on all
+	// connections the pool opens from now on.
+	_, err = pool.Prepare(postgresStmtUser, userQuery)
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("could not prepare user query: %v", err)
+	}
+
+	rehash := !conf.ReadOnly && (rehashQuery != "")
+	if rehash {
+
+		_, err = pool.Prepare(postgresStmtRehash, rehashQuery)
+		if err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("could not prepare rehash query: %v", err)
+		}
+	}
+
+	p := &PostgresAuthenticator{
+		Pool:          pool,
+		Config:        conf,
+		rehash:        rehash,
+		stopHealthChk: make(chan struct{}),
+	}
+
+	go p.checkHealth(healthInterval)
+
+	return p, nil
+}
+
+// checkHealth periodically pings all idle connections
+// of the pool. Broken ones are dropped when released,
+// so that the pool replaces them by new connections.
+func (p *PostgresAuthenticator) checkHealth(interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+
+		select {
+
+		case <-p.stopHealthChk:
+			return
+
+		case <-ticker.C:
+
+			idle := p.Pool.Stat().AvailableConnections
+			conns := make([]*pgx.Conn, 0, idle)
+
+			for i := 0; i < idle; i++ {
+
+				conn, err := p.Pool.Acquire()
+				if err != nil {
+					break
+				}
+
+				conns = append(conns, conn)
+			}
+
+			for _, conn := range conns {
+				conn.Exec(";")
+				p.Pool.Release(conn)
+			}
+		}
+	}
+}
+
+// Close stops health checks and closes all
+// connections to the database.
+func (p *PostgresAuthenticator) Close() {
+	close(p.stopHealthChk)
+	p.Pool.Close()
+}
+
+// connError reports whether err stems from a broken
+// connection rather than from the database server.
+func connError(err error) bool {
+
+	if (err == nil) || (err == pgx.ErrNoRows) {
+		return false
+	}
+
+	_, isPgError := err.(pgx.PgError)
+
+	return !isPgError
+}
+
+// lookupUser returns ID and password hash of the user
+// named username. If the connection used turns out to
+// be broken, e.g. after the database restarted, the
+// lookup is repeated once on a fresh connection.
+func (p *PostgresAuthenticator) lookupUser(username string) (int, string, error) {
+
+	var dbUserID int
+	var dbPassword string
+
+	err := p.Pool.QueryRow(postgresStmtUser, username).Scan(&dbUserID, &dbPassword)
+	if connError(err) {
+		err = p.Pool.QueryRow(postgresStmtUser, username).Scan(&dbUserID, &dbPassword)
+	}
+
+	return dbUserID, dbPassword, err
 }
 
 // GetWorkerForUser returns the name of the worker node
@@ -74,47 +295,69 @@ func (p *PostgresAuthenticator) GetWorkerForUser(workers map[string]config.Worke
 	return "", fmt.Errorf("no worker responsible for user ID %d", id)
 }
 
+// Mechanisms returns the SASL mechanisms the PostgreSQL
+// authenticator is able to verify. Passwords are stored
+// hashed, which rules out SCRAM.
+func (p *PostgresAuthenticator) Mechanisms() []string {
+	return []string{MechanismPlain, MechanismLogin, MechanismExternal}
+}
+
 // AuthenticatePlain is used to perform the actual process
 // of looking up if the client supplied user credentials exist
 // and match with an user entry in the PostgreSQL database.
 func (p *PostgresAuthenticator) AuthenticatePlain(username string, password string, clientAddr string) (int, string, error) {
 
-	var dbUserID int
+	// Query database for the user's password hash.
+	dbUserID, dbPassword, err := p.lookupUser(username)
+	if err != nil {
+
+		// Check what type of error we received.
+		if err == pgx.ErrNoRows {
+			return -1, "", fmt.Errorf("username not found in users table or password wrong")
+		}
 
-	// Create new SHA512 hash.
-	shaHash := sha512.New()
+		return -1, "", fmt.Errorf("error while trying to locate user: %s", err.Error())
+	}
 
-	// Input supplied password into hash function.
-	_, err := shaHash.Write([]byte(password))
+	// Entries without scheme prefix are of the configured
+	// default scheme, which is the unsalted SHA-512 pluto
+	// stored in the past.
+	match, err := VerifyPassword(dbPassword, password, p.Config.DefaultScheme)
 	if err != nil {
-		return -1, "", fmt.Errorf("failed to write password to hash: %s", err.Error())
+		return -1, "", fmt.Errorf("error while verifying password of user: %s", err.Error())
 	}
 
-	// Produce the actual hash and save it.
-	hashedPassword := shaHash.Sum(nil)
+	if !match {
+		return -1, "", fmt.Errorf("username not found in users table or password wrong")
+	}
+
+	// Upgrade outdated hashes while we know the
+	// password. Failing to do so does not affect
+	// the login, the next one tries again.
+	if p.rehash && NeedsRehash(dbPassword, p.Config.DefaultScheme) {
+
+		rehashed, err := HashPassword(PreferredScheme, password)
+		if err == nil {
+			p.Pool.Exec(postgresStmtRehash, rehashed, dbUserID)
+		}
+	}
 
-	// Encode the hashed text with base64.
-	encHashedPassword := base64.StdEncoding.EncodeToString(hashedPassword)
+	return dbUserID, clientID(clientAddr, username), nil
+}
 
-	// Prepare query for database.
-	query := fmt.Sprintf("SELECT id FROM users WHERE username = '%s' AND password = '{SHA512}%s'", username, encHashedPassword)
+// AuthenticateIdentity returns ID and session identifier
+// of a user whose identity a SASL mechanism verified.
+func (p *PostgresAuthenticator) AuthenticateIdentity(username string, clientAddr string) (int, string, error) {
 
-	// Query database for user matching all criteria.
-	err = p.Conn.QueryRow(query).Scan(&dbUserID)
+	dbUserID, _, err := p.lookupUser(username)
 	if err != nil {
 
-		// Check what type of error we received.
 		if err == pgx.ErrNoRows {
-			return -1, "", fmt.Errorf("username not found in users table or password wrong")
+			return -1, "", fmt.Errorf("username not found in users table")
 		}
 
 		return -1, "", fmt.Errorf("error while trying to locate user: %s", err.Error())
 	}
 
-	// Build the deterministic client-specific session identifier.
-	// Note: we expect this clientID to really identify exactly one
-	// device in one session of one user.
-	clientID := fmt.Sprintf("%s:%s", clientAddr, username)
-
-	return dbUserID, clientID, nil
+	return dbUserID, clientID(clientAddr, username), nil
 }
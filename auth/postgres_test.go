@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/jackc/pgx.v2"
+)
+
+// Functions
+
+// TestSanitizeIdentifier executes a white-box unit test
+// on quoting configured table and column names.
+func TestSanitizeIdentifier(t *testing.T) {
+
+	assert.Equalf(t, `"users"`, sanitizeIdentifier("users"), "unexpected quoted table")
+	assert.Equalf(t, `"auth"."users"`, sanitizeIdentifier("auth.users"), "unexpected quoted schema-qualified table")
+	assert.Equalf(t, `"name"" = '' OR ""1"`, sanitizeIdentifier(`name" = '' OR "1`), "expected quotes in names to be escaped")
+}
+
+// TestDeadlineConn executes a white-box unit test
+// on the per-query timeout of database connections.
+func TestDeadlineConn(t *testing.T) {
+
+	client, server := net.Pipe()
+	defer server.Close()
+
+	conn := &deadlineConn{Conn: client, timeout: 50 * time.Millisecond}
+
+	go func() {
+		buf := make([]byte, 5)
+		server.Read(buf)
+	}()
+
+	_, err := conn.Write([]byte("query"))
+	assert.Nilf(t, err, "expected write to succeed but got: %v", err)
+
+	// The database never answers the query.
+	_, err = conn.Read(make([]byte, 1))
+	netErr, ok := err.(net.Error)
+	assert.Truef(t, (ok && netErr.Timeout()), "expected unanswered query to time out but got: %v", err)
+
+	assert.Truef(t, connError(err), "expected timeout to count as connection error")
+	assert.Falsef(t, connError(pgx.ErrNoRows), "expected missing rows not to count as connection error")
+	assert.Falsef(t, connError(pgx.PgError{Code: "42P01"}), "expected server errors not to count as connection error")
+}
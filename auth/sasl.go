@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"crypto/tls"
+)
+
+// Constants
+
+// Names of the SASL mechanisms shipped with pluto.
+const (
+	// MechanismPlain defines SASL PLAIN (RFC 4616).
+	MechanismPlain = "PLAIN"
+	// MechanismLogin defines the widely used LOGIN
+	// mechanism (draft-murchison-sasl-login).
+	MechanismLogin = "LOGIN"
+	// MechanismScramSHA256 defines SCRAM-SHA-256 (RFC 7677).
+	MechanismScramSHA256 = "SCRAM-SHA-256"
+	// MechanismScramSHA256Plus defines SCRAM-SHA-256-PLUS
+	// with tls-exporter channel binding (RFC 9266).
+	MechanismScramSHA256Plus = "SCRAM-SHA-256-PLUS"
+	// MechanismExternal defines SASL EXTERNAL (RFC 4422)
+	// based on TLS client certificates.
+	MechanismExternal = "EXTERNAL"
+	// MechanismOAuthBearer defines OAUTHBEARER (RFC 7628).
+	MechanismOAuthBearer = "OAUTHBEARER"
+	// MechanismXOAuth2 defines the XOAUTH2 mechanism many
+	// mail clients use to send OAuth 2.0 bearer tokens.
+	MechanismXOAuth2 = "XOAUTH2"
+)
+
+// Structs
+
+// Mechanism describes a SASL mechanism pluto is able
+// to run the server side of. If RequiresTLS is true,
+// the mechanism is only offered on connections
+// protected by TLS. New starts a new exchange of the
+// mechanism verifying credentials with verifier and
+// fails if verifier is unable to verify credentials
+// of this mechanism.
+type Mechanism struct {
+	Name        string
+	RequiresTLS bool
+	New         func(verifier interface{}, conn *ConnState) (Exchange, error)
+}
+
+// ConnState describes the client connection a SASL
+// exchange runs on. TLS is nil if the connection is
+// not protected by TLS. Offered lists the mechanisms
+// offered to the client, which mechanisms with channel
+// binding need to detect downgrade attacks.
+type ConnState struct {
+	ClientAddr string
+	TLS        *tls.ConnectionState
+	Offered    []string
+}
+
+// Identity is the outcome of a successful exchange:
+// the ID and name of the authenticated user as well
+// as the client-specific session identifier.
+type Identity struct {
+	ID       int
+	ClientID string
+	UserName string
+}
+
+// Interfaces
+
+// Exchange is the server side of one SASL
+// authentication exchange.
+type Exchange interface {
+
+	// Next takes in the next response of the client, which
+	// is nil if the client did not send an initial response,
+	// and returns the challenge to send next. Once the client
+	// successfully authenticated, the identity is returned.
+	Next(response []byte) ([]byte, *Identity, error)
+}
+
+// PasswordVerifier is implemented by authenticators
+// able to check a user's password.
+type PasswordVerifier interface {
+	AuthenticatePlain(username string, password string, clientAddr string) (int, string, error)
+}
+
+// IdentityVerifier is implemented by authenticators
+// able to look up users whose identity was proven by
+// other means, e.g. a client certificate.
+type IdentityVerifier interface {
+	AuthenticateIdentity(username string, clientAddr string) (int, string, error)
+}
+
+// Variables
+
+// mechanisms contains all registered SASL
+// mechanisms keyed by their name.
+var (
+	mechanismsLock sync.RWMutex
+	mechanisms     = make(map[string]*Mechanism)
+)
+
+// Functions
+
+// RegisterMechanism makes a SASL mechanism available
+// to the AUTHENTICATE command. Mechanisms call it on
+// initialization.
+func RegisterMechanism(mechanism *Mechanism) {
+
+	mechanismsLock.Lock()
+	defer mechanismsLock.Unlock()
+
+	mechanisms[strings.ToUpper(mechanism.Name)] = mechanism
+}
+
+// LookupMechanism returns the registered
+// mechanism of supplied name, if any.
+func LookupMechanism(name string) (*Mechanism, bool) {
+
+	mechanismsLock.RLock()
+	defer mechanismsLock.RUnlock()
+
+	mechanism, found := mechanisms[strings.ToUpper(name)]
+
+	return mechanism, found
+}
+
+// NewExchange starts an exchange of the named mechanism
+// on the connection described by conn, verifying the
+// client's credentials with verifier.
+func NewExchange(name string, verifier interface{}, conn *ConnState) (Exchange, error) {
+
+	mechanism, found := LookupMechanism(name)
+	if !found {
+		return nil, fmt.Errorf("unknown SASL mechanism %s", name)
+	}
+
+	if mechanism.RequiresTLS && (conn.TLS == nil) {
+		return nil, fmt.Errorf("SASL mechanism %s requires TLS", mechanism.Name)
+	}
+
+	return mechanism.New(verifier, conn)
+}
+
+// clientID builds the deterministic client-specific
+// session identifier of a user authenticated from
+// clientAddr. Note: we expect this clientID to really
+// identify exactly one device in one session of one user.
+func clientID(clientAddr string, username string) string {
+	return fmt.Sprintf("%s:%s", clientAddr, username)
+}
@@ -0,0 +1,373 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// Constants
+
+const (
+	// ScramIterations is the iteration count of
+	// SCRAM credentials pluto derives itself.
+	ScramIterations = 4096
+
+	// scramNonceLength is the number of random
+	// bytes of the server's part of the nonce.
+	scramNonceLength = 18
+
+	// scramExporterLabel is the label of keying
+	// material used as tls-exporter channel binding.
+	scramExporterLabel = "EXPORTER-Channel-Binding"
+)
+
+// Structs
+
+// ScramCredentials contains what a server needs to
+// know about a user's password in order to verify it
+// via SCRAM-SHA-256 (RFC 5802) without storing it.
+type ScramCredentials struct {
+	Salt       []byte
+	Iterations int
+	StoredKey  []byte
+	ServerKey  []byte
+}
+
+// scramExchange is the server side of SCRAM-SHA-256
+// and, if plus is true, SCRAM-SHA-256-PLUS.
+type scramExchange struct {
+	verifier        ScramVerifier
+	conn            *ConnState
+	plus            bool
+	step            int
+	username        string
+	gs2Header       string
+	clientFirstBare string
+	serverFirst     string
+	nonce           string
+	credentials     *ScramCredentials
+	identity        *Identity
+}
+
+// Interfaces
+
+// ScramVerifier is implemented by authenticators able
+// to provide SCRAM-SHA-256 credentials of their users.
+type ScramVerifier interface {
+	IdentityVerifier
+	ScramCredentials(username string) (*ScramCredentials, error)
+}
+
+// Variables
+
+// scramNonce returns the server's part of the nonce
+// of an exchange. Tests replace it to obtain known
+// values.
+var scramNonce = func() (string, error) {
+
+	nonce := make([]byte, scramNonceLength)
+
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(nonce), nil
+}
+
+// Functions
+
+// scramHMAC computes HMAC-SHA-256 of msg under key.
+func scramHMAC(key []byte, msg string) []byte {
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(msg))
+
+	return mac.Sum(nil)
+}
+
+// scramHi implements the function Hi of RFC 5802,
+// which is PBKDF2 with HMAC-SHA-256 as PRF.
+func scramHi(password []byte, salt []byte, iterations int) []byte {
+
+	mac := hmac.New(sha256.New, password)
+	mac.Write(salt)
+	mac.Write([]byte{0, 0, 0, 1})
+
+	u := mac.Sum(nil)
+	result := append([]byte(nil), u...)
+
+	for i := 1; i < iterations; i++ {
+
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(nil)
+
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+
+	return result
+}
+
+// NewScramCredentials derives the SCRAM-SHA-256
+// credentials of password with supplied salt and
+// iteration count.
+func NewScramCredentials(password string, salt []byte, iterations int) *ScramCredentials {
+
+	saltedPassword := scramHi([]byte(password), salt, iterations)
+	storedKey := sha256.Sum256(scramHMAC(saltedPassword, "Client Key"))
+
+	return &ScramCredentials{
+		Salt:       salt,
+		Iterations: iterations,
+		StoredKey:  storedKey[:],
+		ServerKey:  scramHMAC(saltedPassword, "Server Key"),
+	}
+}
+
+// scramSalt returns a salt that stays the same for
+// username, for authenticators deriving credentials
+// from a password on each exchange. Clients are thus
+// able to cache the salted password.
+func scramSalt(username string) []byte {
+
+	salt := sha256.Sum256([]byte(fmt.Sprintf("pluto-scram-salt:%s", username)))
+
+	return salt[:16]
+}
+
+// scramUsername decodes a user name as transferred
+// in SCRAM, where ',' and '=' are escaped.
+func scramUsername(raw string) (string, error) {
+
+	username := strings.NewReplacer("=2C", ",", "=3D", "=").Replace(raw)
+
+	if strings.Count(raw, "=") != (strings.Count(raw, "=2C") + strings.Count(raw, "=3D")) {
+		return "", fmt.Errorf("invalid encoding of SCRAM user name")
+	}
+
+	return username, nil
+}
+
+// newScramExchange returns a function starting
+// SCRAM-SHA-256 exchanges or, if plus is true,
+// SCRAM-SHA-256-PLUS exchanges.
+func newScramExchange(plus bool) func(verifier interface{}, conn *ConnState) (Exchange, error) {
+
+	return func(verifier interface{}, conn *ConnState) (Exchange, error) {
+
+		scramVerifier, ok := verifier.(ScramVerifier)
+		if !ok {
+			return nil, fmt.Errorf("authenticator is unable to provide SCRAM credentials")
+		}
+
+		return &scramExchange{
+			verifier: scramVerifier,
+			conn:     conn,
+			plus:     plus,
+		}, nil
+	}
+}
+
+// Next implements Exchange.
+func (e *scramExchange) Next(response []byte) ([]byte, *Identity, error) {
+
+	// Ask for the client-first-message if the
+	// client did not send an initial response.
+	if (response == nil) && (e.step == 0) {
+		return []byte{}, nil, nil
+	}
+
+	e.step++
+
+	switch e.step {
+
+	case 1:
+		return e.serverFirstMessage(string(response))
+
+	case 2:
+		return e.serverFinalMessage(string(response))
+
+	case 3:
+
+		// The client acknowledges our signature
+		// with an empty response.
+		if len(response) > 0 {
+			return nil, nil, fmt.Errorf("unexpected SCRAM response after server-final-message")
+		}
+
+		return nil, e.identity, nil
+	}
+
+	return nil, nil, fmt.Errorf("SCRAM exchange already completed")
+}
+
+// serverFirstMessage parses the client-first-message
+// and answers it with salt and iteration count of
+// the user's credentials.
+func (e *scramExchange) serverFirstMessage(clientFirst string) ([]byte, *Identity, error) {
+
+	parts := strings.SplitN(clientFirst, ",", 3)
+	if len(parts) != 3 {
+		return nil, nil, fmt.Errorf("malformed SCRAM client-first-message")
+	}
+
+	// Check the requested channel binding. A client
+	// stating that it supports channel binding while
+	// we offered it indicates a downgrade attack.
+	switch {
+
+	case parts[0] == "n":
+
+		if e.plus {
+			return nil, nil, fmt.Errorf("SCRAM-SHA-256-PLUS requires channel binding")
+		}
+
+	case parts[0] == "y":
+
+		if e.plus {
+			return nil, nil, fmt.Errorf("SCRAM-SHA-256-PLUS requires channel binding")
+		}
+
+		for _, offered := range e.conn.Offered {
+
+			if offered == MechanismScramSHA256Plus {
+				return nil, nil, fmt.Errorf("client did not use offered channel binding")
+			}
+		}
+
+	case parts[0] == "p=tls-exporter":
+
+		if !e.plus {
+			return nil, nil, fmt.Errorf("SCRAM-SHA-256 does not support channel binding")
+		}
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported SCRAM channel binding %s", parts[0])
+	}
+
+	attrs := strings.Split(parts[2], ",")
+	if (len(attrs) < 2) || !strings.HasPrefix(attrs[0], "n=") || !strings.HasPrefix(attrs[1], "r=") || (len(attrs[1]) == 2) {
+		return nil, nil, fmt.Errorf("malformed SCRAM client-first-message")
+	}
+
+	username, err := scramUsername(attrs[0][2:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Acting as another user is not supported.
+	if (parts[1] != "") && (parts[1] != fmt.Sprintf("a=%s", attrs[0][2:])) {
+		return nil, nil, fmt.Errorf("authorization identity differs from user name")
+	}
+
+	credentials, err := e.verifier.ScramCredentials(username)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serverNonce, err := scramNonce()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate SCRAM nonce: %v", err)
+	}
+
+	e.username = username
+	e.credentials = credentials
+	e.gs2Header = fmt.Sprintf("%s,%s,", parts[0], parts[1])
+	e.clientFirstBare = parts[2]
+	e.nonce = fmt.Sprintf("%s%s", attrs[1][2:], serverNonce)
+	e.serverFirst = fmt.Sprintf("r=%s,s=%s,i=%d", e.nonce, base64.StdEncoding.EncodeToString(credentials.Salt), credentials.Iterations)
+
+	return []byte(e.serverFirst), nil, nil
+}
+
+// serverFinalMessage verifies the channel binding and
+// the proof contained in the client-final-message and
+// answers it with the server's signature.
+func (e *scramExchange) serverFinalMessage(clientFinal string) ([]byte, *Identity, error) {
+
+	proofPos := strings.LastIndex(clientFinal, ",p=")
+	if proofPos < 0 {
+		return nil, nil, fmt.Errorf("malformed SCRAM client-final-message")
+	}
+
+	withoutProof := clientFinal[:proofPos]
+
+	attrs := strings.Split(withoutProof, ",")
+	if (len(attrs) < 2) || !strings.HasPrefix(attrs[0], "c=") || (attrs[1] != fmt.Sprintf("r=%s", e.nonce)) {
+		return nil, nil, fmt.Errorf("malformed SCRAM client-final-message")
+	}
+
+	binding, err := base64.StdEncoding.DecodeString(attrs[0][2:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("malformed SCRAM channel binding")
+	}
+
+	expBinding := []byte(e.gs2Header)
+	if e.plus {
+
+		keyingMaterial, err := e.conn.TLS.ExportKeyingMaterial(scramExporterLabel, nil, 32)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to export TLS keying material: %v", err)
+		}
+
+		expBinding = append(expBinding, keyingMaterial...)
+	}
+
+	if !hmac.Equal(binding, expBinding) {
+		return nil, nil, fmt.Errorf("SCRAM channel binding did not match")
+	}
+
+	proof, err := base64.StdEncoding.DecodeString(clientFinal[(proofPos + 3):])
+	if err != nil {
+		return nil, nil, fmt.Errorf("malformed SCRAM client proof")
+	}
+
+	authMessage := fmt.Sprintf("%s,%s,%s", e.clientFirstBare, e.serverFirst, withoutProof)
+
+	// Recover the client key from the proof and
+	// check that it hashes to the stored key.
+	clientSignature := scramHMAC(e.credentials.StoredKey, authMessage)
+	if len(proof) != len(clientSignature) {
+		return nil, nil, fmt.Errorf("SCRAM client proof has wrong length")
+	}
+
+	clientKey := make([]byte, len(proof))
+	for i := range proof {
+		clientKey[i] = proof[i] ^ clientSignature[i]
+	}
+
+	storedKey := sha256.Sum256(clientKey)
+	if !hmac.Equal(storedKey[:], e.credentials.StoredKey) {
+		return nil, nil, fmt.Errorf("SCRAM client proof did not match")
+	}
+
+	id, clientID, err := e.verifier.AuthenticateIdentity(e.username, e.conn.ClientAddr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	e.identity = &Identity{
+		ID:       id,
+		ClientID: clientID,
+		UserName: e.username,
+	}
+
+	serverSignature := scramHMAC(e.credentials.ServerKey, authMessage)
+
+	return []byte(fmt.Sprintf("v=%s", base64.StdEncoding.EncodeToString(serverSignature))), nil, nil
+}
+
+// init registers SCRAM-SHA-256 and its variant with
+// channel binding, which is only possible over TLS.
+func init() {
+	RegisterMechanism(&Mechanism{Name: MechanismScramSHA256, New: newScramExchange(false)})
+	RegisterMechanism(&Mechanism{Name: MechanismScramSHA256Plus, RequiresTLS: true, New: newScramExchange(true)})
+}
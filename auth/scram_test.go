@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"fmt"
+	"testing"
+
+	"encoding/base64"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Structs
+
+// testVerifier knows the single user "user"
+// with password "pencil" of RFC 7677.
+type testVerifier struct{}
+
+// Functions
+
+// AuthenticateIdentity implements IdentityVerifier.
+func (v *testVerifier) AuthenticateIdentity(username string, clientAddr string) (int, string, error) {
+
+	if username != "user" {
+		return -1, "", fmt.Errorf("unknown user")
+	}
+
+	return 1, clientID(clientAddr, username), nil
+}
+
+// ScramCredentials implements ScramVerifier.
+func (v *testVerifier) ScramCredentials(username string) (*ScramCredentials, error) {
+
+	if username != "user" {
+		return nil, fmt.Errorf("unknown user")
+	}
+
+	salt, _ := base64.StdEncoding.DecodeString("W22ZaJ0SNY7soEsUEjb6gQ==")
+
+	return NewScramCredentials("pencil", salt, 4096), nil
+}
+
+// TestScramExchange executes a white-box unit test
+// on a SCRAM-SHA-256 exchange using the example of
+// RFC 7677.
+func TestScramExchange(t *testing.T) {
+
+	// Use the server nonce of the example.
+	defer func(nonce func() (string, error)) {
+		scramNonce = nonce
+	}(scramNonce)
+
+	scramNonce = func() (string, error) {
+		return "%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0", nil
+	}
+
+	conn := &ConnState{
+		ClientAddr: "127.0.0.1:4321",
+	}
+
+	exchange, err := NewExchange(MechanismScramSHA256, &testVerifier{}, conn)
+	assert.Nilf(t, err, "expected NewExchange() not to fail but got: %v", err)
+
+	challenge, identity, err := exchange.Next(nil)
+	assert.Nilf(t, err, "expected empty challenge but got error: %v", err)
+	assert.Equalf(t, []byte{}, challenge, "expected empty challenge without initial response")
+
+	challenge, identity, err = exchange.Next([]byte("n,,n=user,r=rOprNGfwEbeRWgbNEkqO"))
+	assert.Nilf(t, err, "expected server-first-message but got error: %v", err)
+	assert.Equalf(t, "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096", string(challenge), "unexpected server-first-message")
+
+	challenge, identity, err = exchange.Next([]byte("c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="))
+	assert.Nilf(t, err, "expected server-final-message but got error: %v", err)
+	assert.Equalf(t, "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=", string(challenge), "unexpected server-final-message")
+	assert.Nilf(t, identity, "expected identity only after final acknowledgement")
+
+	_, identity, err = exchange.Next([]byte{})
+	assert.Nilf(t, err, "expected successful authentication but got error: %v", err)
+	assert.Equalf(t, &Identity{ID: 1, ClientID: "127.0.0.1:4321:user", UserName: "user"}, identity, "unexpected identity")
+
+	// A wrong proof fails.
+	exchange, _ = NewExchange(MechanismScramSHA256, &testVerifier{}, conn)
+	exchange.Next([]byte("n,,n=user,r=rOprNGfwEbeRWgbNEkqO"))
+	_, _, err = exchange.Next([]byte("c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=AAAAZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="))
+	assert.NotNilf(t, err, "expected wrong proof to fail")
+
+	// Claiming channel binding support while
+	// it was offered indicates a downgrade.
+	conn.Offered = []string{MechanismScramSHA256, MechanismScramSHA256Plus}
+	exchange, _ = NewExchange(MechanismScramSHA256, &testVerifier{}, conn)
+	_, _, err = exchange.Next([]byte("y,,n=user,r=rOprNGfwEbeRWgbNEkqO"))
+	assert.NotNilf(t, err, "expected downgraded channel binding to fail")
+
+	// The PLUS variant is only available over TLS.
+	_, err = NewExchange(MechanismScramSHA256Plus, &testVerifier{}, conn)
+	assert.NotNilf(t, err, "expected SCRAM-SHA-256-PLUS to require TLS")
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+
+	"hash/fnv"
+
+	"github.com/go-pluto/pluto/config"
+)
+
+// Functions
+
+// workerForUser returns the name of the worker node
+// responsible for the range of user IDs that contains
+// the supplied user ID.
+func workerForUser(workers map[string]config.Worker, id int) (string, error) {
+
+	for name, worker := range workers {
+
+		if (id >= worker.UserStart) && (id <= worker.UserEnd) {
+			return name, nil
+		}
+	}
+
+	return "", fmt.Errorf("no worker responsible for user ID %d", id)
+}
+
+// orderWorkers returns the supplied workers ordered
+// by their user IDs, so that shard keys map onto the
+// same IDs on every start.
+func orderWorkers(workers map[string]config.Worker) []config.Worker {
+
+	ordered := make([]config.Worker, 0, len(workers))
+	for _, worker := range workers {
+		ordered = append(ordered, worker)
+	}
+
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].UserStart < ordered[j].UserStart
+	})
+
+	return ordered
+}
+
+// shardUserID maps a shard key, e.g. a token subject,
+// onto a user ID in the range of one of the workers.
+// Pluto only uses this ID to route users to their
+// worker, so it only needs to be the same for each
+// key on every login.
+func shardUserID(workers []config.Worker, key string) (int, error) {
+
+	total := 0
+	for _, worker := range workers {
+		total += (worker.UserEnd - worker.UserStart) + 1
+	}
+
+	if total <= 0 {
+		return -1, fmt.Errorf("no worker responsible for any user ID")
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	pos := int(h.Sum32() % uint32(total))
+
+	for _, worker := range workers {
+
+		size := (worker.UserEnd - worker.UserStart) + 1
+		if pos < size {
+			return worker.UserStart + pos, nil
+		}
+
+		pos -= size
+	}
+
+	return -1, fmt.Errorf("no worker responsible for shard key %s", key)
+}
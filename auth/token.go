@@ -0,0 +1,588 @@
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/go-pluto/pluto/config"
+)
+
+// Constants
+
+const (
+	// tokenLeeway is the clock skew tolerated when
+	// checking expiry and start of validity of tokens.
+	tokenLeeway = time.Minute
+
+	// jwksRefreshInterval limits how often keys are
+	// fetched again when a token names an unknown key.
+	jwksRefreshInterval = time.Minute
+)
+
+// Structs
+
+// OAuthAuthenticator validates OAuth 2.0 bearer tokens
+// of users signing in via single sign-on. Tokens are
+// either JWTs checked offline against the keys of a
+// JWKS or opaque tokens checked via an introspection
+// endpoint (RFC 7662).
+type OAuthAuthenticator struct {
+	Config      *config.AuthOAuth
+	Client      *http.Client
+	keysLock    sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+// jsonWebKey is one key of a JSON Web Key Set (RFC 7517).
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// Functions
+
+// NewOAuthAuthenticator prepares validation of tokens as
+// configured. Users are routed to their worker by the
+// numeric user ID their token carries in IDClaim.
+func NewOAuthAuthenticator(conf *config.AuthOAuth) (*OAuthAuthenticator, error) {
+
+	if (conf.IntrospectionURL == "") && (conf.JWKSFile == "") && (conf.JWKSURL == "") {
+		return nil, fmt.Errorf("OAuth authenticator requires a JWKS or an introspection endpoint")
+	}
+
+	// Tokens our identity provider issued for other
+	// applications must not grant access to mailboxes.
+	if (conf.IntrospectionURL == "") && ((conf.Issuer == "") || (conf.Audience == "")) {
+		return nil, fmt.Errorf("OAuth authenticator requires Issuer and Audience to validate JWTs")
+	}
+
+	// Without the user ID the password backends assign,
+	// users would end up on a worker other than the one
+	// serving their mailbox.
+	if conf.IDClaim == "" {
+		return nil, fmt.Errorf("OAuth authenticator requires IDClaim to route users to their worker")
+	}
+
+	if conf.UsernameClaim == "" {
+		conf.UsernameClaim = "sub"
+	}
+
+	o := &OAuthAuthenticator{
+		Config: conf,
+		Client: &http.Client{Timeout: 10 * time.Second},
+		keys:   make(map[string]crypto.PublicKey),
+	}
+
+	if conf.IntrospectionURL != "" {
+		return o, nil
+	}
+
+	if conf.JWKSFile != "" {
+
+		data, err := ioutil.ReadFile(conf.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read supplied JWKS file: %v", err)
+		}
+
+		o.keys, err = parseJWKS(data)
+		if err != nil {
+			return nil, err
+		}
+
+		return o, nil
+	}
+
+	err := o.fetchKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// base64URLInt decodes a big-endian unsigned
+// integer encoded in base64url without padding.
+func base64URLInt(encoded string) (*big.Int, error) {
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
+
+// parseJWKS returns the signature keys contained in
+// a JSON Web Key Set keyed by their key ID. Keys of
+// unsupported types are skipped.
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	err := json.Unmarshal(data, &set)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse JWKS: %v", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+
+	for _, key := range set.Keys {
+
+		if (key.Use != "") && (key.Use != "sig") {
+			continue
+		}
+
+		switch key.Kty {
+
+		case "RSA":
+
+			n, err := base64URLInt(key.N)
+			if err != nil {
+				return nil, fmt.Errorf("invalid modulus of JWKS key %s: %v", key.Kid, err)
+			}
+
+			e, err := base64URLInt(key.E)
+			if (err != nil) || !e.IsInt64() || (e.Int64() < 3) {
+				return nil, fmt.Errorf("invalid exponent of JWKS key %s", key.Kid)
+			}
+
+			keys[key.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+
+		case "EC":
+
+			var curve elliptic.Curve
+
+			switch key.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+
+			x, errX := base64URLInt(key.X)
+			y, errY := base64URLInt(key.Y)
+			if (errX != nil) || (errY != nil) || !curve.IsOnCurve(x, y) {
+				return nil, fmt.Errorf("invalid point of JWKS key %s", key.Kid)
+			}
+
+			keys[key.Kid] = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS does not contain any supported signature key")
+	}
+
+	return keys, nil
+}
+
+// fetchKeys retrieves the JWKS from the configured URL
+// and replaces the known keys with its content.
+func (o *OAuthAuthenticator) fetchKeys() error {
+
+	resp, err := o.Client.Get(o.Config.JWKSURL)
+	if err != nil {
+		return fmt.Errorf("could not fetch JWKS: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching JWKS failed with status %s", resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not read JWKS: %v", err)
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+
+	o.keysLock.Lock()
+	o.keys = keys
+	o.keysFetched = time.Now()
+	o.keysLock.Unlock()
+
+	return nil
+}
+
+// key returns the key of supplied ID. If the key is
+// unknown and keys come from a URL, the issuer may have
+// rotated its keys, so they are fetched again.
+func (o *OAuthAuthenticator) key(kid string) (crypto.PublicKey, error) {
+
+	o.keysLock.RLock()
+	key, found := o.keys[kid]
+	fetched := o.keysFetched
+	o.keysLock.RUnlock()
+
+	if found {
+		return key, nil
+	}
+
+	if (o.Config.JWKSURL == "") || (time.Since(fetched) < jwksRefreshInterval) {
+		return nil, fmt.Errorf("token signed with unknown key %s", kid)
+	}
+
+	err := o.fetchKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	o.keysLock.RLock()
+	key, found = o.keys[kid]
+	o.keysLock.RUnlock()
+
+	if !found {
+		return nil, fmt.Errorf("token signed with unknown key %s", kid)
+	}
+
+	return key, nil
+}
+
+// verifySignature checks the signature of a JWT's
+// signing input with key according to algorithm.
+func verifySignature(alg string, key crypto.PublicKey, input string, sig []byte) error {
+
+	var hash crypto.Hash
+
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported token algorithm %s", alg)
+	}
+
+	var digest []byte
+
+	switch hash {
+	case crypto.SHA256:
+		sum := sha256.Sum256([]byte(input))
+		digest = sum[:]
+	case crypto.SHA384:
+		sum := sha512.Sum384([]byte(input))
+		digest = sum[:]
+	default:
+		sum := sha512.Sum512([]byte(input))
+		digest = sum[:]
+	}
+
+	switch alg[:2] {
+
+	case "RS", "PS":
+
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("token algorithm %s does not match key type", alg)
+		}
+
+		if alg[:2] == "PS" {
+			return rsa.VerifyPSS(rsaKey, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+
+		return rsa.VerifyPKCS1v15(rsaKey, hash, digest, sig)
+
+	case "ES":
+
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("token algorithm %s does not match key type", alg)
+		}
+
+		// The signature is the concatenation of r and s,
+		// each as long as the curve's order.
+		size := (ecKey.Curve.Params().BitSize + 7) / 8
+		if len(sig) != (2 * size) {
+			return fmt.Errorf("token signature has wrong length")
+		}
+
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+
+		if !ecdsa.Verify(ecKey, digest, r, s) {
+			return fmt.Errorf("token signature did not match")
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("unsupported token algorithm %s", alg)
+}
+
+// verifyJWT checks the signature of a JWT
+// and returns the claims it contains.
+func (o *OAuthAuthenticator) verifyJWT(token string) (map[string]interface{}, error) {
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token header")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+
+	err = json.Unmarshal(rawHeader, &header)
+	if (err != nil) || (len(header.Alg) != 5) {
+		return nil, fmt.Errorf("malformed or unsigned token header")
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature")
+	}
+
+	key, err := o.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	err = verifySignature(header.Alg, key, fmt.Sprintf("%s.%s", parts[0], parts[1]), sig)
+	if err != nil {
+		return nil, err
+	}
+
+	rawClaims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token claims")
+	}
+
+	claims := make(map[string]interface{})
+
+	err = json.Unmarshal(rawClaims, &claims)
+	if err != nil {
+		return nil, fmt.Errorf("malformed token claims")
+	}
+
+	return claims, nil
+}
+
+// introspect asks the configured introspection endpoint
+// about token and returns the claims of active tokens.
+func (o *OAuthAuthenticator) introspect(token string) (map[string]interface{}, error) {
+
+	form := url.Values{}
+	form.Set("token", token)
+	form.Set("token_type_hint", "access_token")
+
+	req, err := http.NewRequest("POST", o.Config.IntrospectionURL, bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("could not create introspection request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	if o.Config.IntrospectionClientID != "" {
+		req.SetBasicAuth(o.Config.IntrospectionClientID, o.Config.IntrospectionClientSecret)
+	}
+
+	resp, err := o.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("introspection request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspection request failed with status %s", resp.Status)
+	}
+
+	claims := make(map[string]interface{})
+
+	err = json.NewDecoder(resp.Body).Decode(&claims)
+	if err != nil {
+		return nil, fmt.Errorf("malformed introspection response: %v", err)
+	}
+
+	if active, ok := claims["active"].(bool); !ok || !active {
+		return nil, fmt.Errorf("token is not active")
+	}
+
+	return claims, nil
+}
+
+// checkClaims checks issuer, audience, and validity
+// period of a token. JWTs have to state their expiry,
+// while introspection responses may omit it.
+func (o *OAuthAuthenticator) checkClaims(claims map[string]interface{}, requireExp bool) error {
+
+	now := time.Now()
+
+	if o.Config.Issuer != "" {
+
+		if iss, _ := claims["iss"].(string); iss != o.Config.Issuer {
+			return fmt.Errorf("token issued by unexpected issuer %s", iss)
+		}
+	}
+
+	if o.Config.Audience != "" {
+
+		found := false
+
+		switch aud := claims["aud"].(type) {
+		case string:
+			found = aud == o.Config.Audience
+		case []interface{}:
+			for _, a := range aud {
+				if a == o.Config.Audience {
+					found = true
+				}
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("token not issued for this audience")
+		}
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if ok {
+
+		if now.Add(-tokenLeeway).After(time.Unix(int64(exp), 0)) {
+			return fmt.Errorf("token expired")
+		}
+	} else if requireExp {
+		return fmt.Errorf("token does not state its expiry")
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok {
+
+		if now.Add(tokenLeeway).Before(time.Unix(int64(nbf), 0)) {
+			return fmt.Errorf("token not yet valid")
+		}
+	}
+
+	return nil
+}
+
+// claimUserID returns the user ID contained in a
+// claim, either as JSON number or numeric string.
+func claimUserID(claim interface{}) (int, error) {
+
+	switch value := claim.(type) {
+
+	case float64:
+
+		if (value < 0) || (value != float64(int(value))) {
+			return -1, fmt.Errorf("%v is no valid user ID", value)
+		}
+
+		return int(value), nil
+
+	case string:
+
+		id, err := strconv.Atoi(value)
+		if (err != nil) || (id < 0) {
+			return -1, fmt.Errorf("%q is no valid user ID", value)
+		}
+
+		return id, nil
+	}
+
+	return -1, fmt.Errorf("claim is missing")
+}
+
+// GetWorkerForUser returns the name of the worker node
+// that is responsible for handling the user's mailbox.
+func (o *OAuthAuthenticator) GetWorkerForUser(workers map[string]config.Worker, id int) (string, error) {
+	return workerForUser(workers, id)
+}
+
+// Mechanisms returns the SASL mechanisms transferring
+// bearer tokens, the only credentials this
+// authenticator is able to verify.
+func (o *OAuthAuthenticator) Mechanisms() []string {
+	return []string{MechanismOAuthBearer, MechanismXOAuth2}
+}
+
+// AuthenticatePlain always fails, as users
+// signing in via OAuth do not have passwords.
+func (o *OAuthAuthenticator) AuthenticatePlain(username string, password string, clientAddr string) (int, string, error) {
+	return -1, "", fmt.Errorf("OAuth authenticator does not accept passwords")
+}
+
+// AuthenticateToken validates token and returns the
+// identity of the user it was issued to.
+func (o *OAuthAuthenticator) AuthenticateToken(token string, username string, clientAddr string) (*Identity, error) {
+
+	var claims map[string]interface{}
+	var err error
+
+	if o.Config.IntrospectionURL != "" {
+		claims, err = o.introspect(token)
+	} else {
+		claims, err = o.verifyJWT(token)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = o.checkClaims(claims, (o.Config.IntrospectionURL == ""))
+	if err != nil {
+		return nil, err
+	}
+
+	tokenUser, _ := claims[o.Config.UsernameClaim].(string)
+	if tokenUser == "" {
+		return nil, fmt.Errorf("token does not contain claim %s", o.Config.UsernameClaim)
+	}
+
+	// Acting as another user is not supported.
+	if (username != "") && (username != tokenUser) {
+		return nil, fmt.Errorf("user name differs from token user")
+	}
+
+	id, err := claimUserID(claims[o.Config.IDClaim])
+	if err != nil {
+		return nil, fmt.Errorf("token does not contain a user ID in claim %s: %v", o.Config.IDClaim, err)
+	}
+
+	return &Identity{
+		ID:       id,
+		ClientID: clientID(clientAddr, tokenUser),
+		UserName: tokenUser,
+	}, nil
+}
@@ -35,14 +35,19 @@ var _ = math.Inf
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
 type Msg struct {
-	Replica   string            `protobuf:"bytes,1,opt,name=replica" json:"replica,omitempty"`
-	Vclock    map[string]uint32 `protobuf:"bytes,2,rep,name=vclock" json:"vclock,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
-	Operation string            `protobuf:"bytes,3,opt,name=operation" json:"operation,omitempty"`
-	Create    *Msg_CREATE       `protobuf:"bytes,4,opt,name=create" json:"create,omitempty"`
-	Delete    *Msg_DELETE       `protobuf:"bytes,5,opt,name=delete" json:"delete,omitempty"`
-	Append    *Msg_APPEND       `protobuf:"bytes,6,opt,name=append" json:"append,omitempty"`
-	Expunge   *Msg_EXPUNGE      `protobuf:"bytes,7,opt,name=expunge" json:"expunge,omitempty"`
-	Store     *Msg_STORE        `protobuf:"bytes,8,opt,name=store" json:"store,omitempty"`
+	Replica     string            `protobuf:"bytes,1,opt,name=replica" json:"replica,omitempty"`
+	Vclock      map[string]uint32 `protobuf:"bytes,2,rep,name=vclock" json:"vclock,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Operation   string            `protobuf:"bytes,3,opt,name=operation" json:"operation,omitempty"`
+	Create      *Msg_CREATE       `protobuf:"bytes,4,opt,name=create" json:"create,omitempty"`
+	Delete      *Msg_DELETE       `protobuf:"bytes,5,opt,name=delete" json:"delete,omitempty"`
+	Append      *Msg_APPEND       `protobuf:"bytes,6,opt,name=append" json:"append,omitempty"`
+	Expunge     *Msg_EXPUNGE      `protobuf:"bytes,7,opt,name=expunge" json:"expunge,omitempty"`
+	Store       *Msg_STORE        `protobuf:"bytes,8,opt,name=store" json:"store,omitempty"`
+	Copy        *Msg_COPY         `protobuf:"bytes,9,opt,name=copy" json:"copy,omitempty"`
+	Move        *Msg_MOVE         `protobuf:"bytes,10,opt,name=move" json:"move,omitempty"`
+	Rename      *Msg_RENAME       `protobuf:"bytes,11,opt,name=rename" json:"rename,omitempty"`
+	Subscribe   *Msg_SUBSCRIBE    `protobuf:"bytes,12,opt,name=subscribe" json:"subscribe,omitempty"`
+	Unsubscribe *Msg_UNSUBSCRIBE  `protobuf:"bytes,13,opt,name=unsubscribe" json:"unsubscribe,omitempty"`
 }
 
 func (m *Msg) Reset()                    { *m = Msg{} }
@@ -106,10 +111,47 @@ func (m *Msg) GetStore() *Msg_STORE {
 	return nil
 }
 
+func (m *Msg) GetCopy() *Msg_COPY {
+	if m != nil {
+		return m.Copy
+	}
+	return nil
+}
+
+func (m *Msg) GetMove() *Msg_MOVE {
+	if m != nil {
+		return m.Move
+	}
+	return nil
+}
+
+func (m *Msg) GetRename() *Msg_RENAME {
+	if m != nil {
+		return m.Rename
+	}
+	return nil
+}
+
+func (m *Msg) GetSubscribe() *Msg_SUBSCRIBE {
+	if m != nil {
+		return m.Subscribe
+	}
+	return nil
+}
+
+func (m *Msg) GetUnsubscribe() *Msg_UNSUBSCRIBE {
+	if m != nil {
+		return m.Unsubscribe
+	}
+	return nil
+}
+
 type Msg_CREATE struct {
-	User    string `protobuf:"bytes,1,opt,name=user" json:"user,omitempty"`
-	Mailbox string `protobuf:"bytes,2,opt,name=mailbox" json:"mailbox,omitempty"`
-	AddTag  string `protobuf:"bytes,3,opt,name=addTag" json:"addTag,omitempty"`
+	User        string `protobuf:"bytes,1,opt,name=user" json:"user,omitempty"`
+	Mailbox     string `protobuf:"bytes,2,opt,name=mailbox" json:"mailbox,omitempty"`
+	AddTag      string `protobuf:"bytes,3,opt,name=addTag" json:"addTag,omitempty"`
+	UidValidity uint32 `protobuf:"varint,4,opt,name=uidValidity" json:"uidValidity,omitempty"`
+	SpecialUse  string `protobuf:"bytes,5,opt,name=specialUse" json:"specialUse,omitempty"`
 }
 
 func (m *Msg_CREATE) Reset()                    { *m = Msg_CREATE{} }
@@ -138,6 +180,20 @@ func (m *Msg_CREATE) GetAddTag() string {
 	return ""
 }
 
+func (m *Msg_CREATE) GetUidValidity() uint32 {
+	if m != nil {
+		return m.UidValidity
+	}
+	return 0
+}
+
+func (m *Msg_CREATE) GetSpecialUse() string {
+	if m != nil {
+		return m.SpecialUse
+	}
+	return ""
+}
+
 type Msg_DELETE struct {
 	User     string   `protobuf:"bytes,1,opt,name=user" json:"user,omitempty"`
 	Mailbox  string   `protobuf:"bytes,2,opt,name=mailbox" json:"mailbox,omitempty"`
@@ -179,10 +235,14 @@ func (m *Msg_DELETE) GetRmvMails() []string {
 }
 
 type Msg_APPEND struct {
-	User       string `protobuf:"bytes,1,opt,name=user" json:"user,omitempty"`
-	Mailbox    string `protobuf:"bytes,2,opt,name=mailbox" json:"mailbox,omitempty"`
-	AddTag     string `protobuf:"bytes,3,opt,name=addTag" json:"addTag,omitempty"`
-	AddContent []byte `protobuf:"bytes,4,opt,name=addContent,proto3" json:"addContent,omitempty"`
+	User         string   `protobuf:"bytes,1,opt,name=user" json:"user,omitempty"`
+	Mailbox      string   `protobuf:"bytes,2,opt,name=mailbox" json:"mailbox,omitempty"`
+	AddTag       string   `protobuf:"bytes,3,opt,name=addTag" json:"addTag,omitempty"`
+	AddContent   []byte   `protobuf:"bytes,4,opt,name=addContent,proto3" json:"addContent,omitempty"`
+	OrigUID      uint32   `protobuf:"varint,5,opt,name=origUID" json:"origUID,omitempty"`
+	InternalDate int64    `protobuf:"varint,6,opt,name=internalDate" json:"internalDate,omitempty"`
+	Keywords     []string `protobuf:"bytes,7,rep,name=keywords" json:"keywords,omitempty"`
+	ModSeq       uint64   `protobuf:"varint,8,opt,name=modSeq" json:"modSeq,omitempty"`
 }
 
 func (m *Msg_APPEND) Reset()                    { *m = Msg_APPEND{} }
@@ -218,11 +278,40 @@ func (m *Msg_APPEND) GetAddContent() []byte {
 	return nil
 }
 
+func (m *Msg_APPEND) GetOrigUID() uint32 {
+	if m != nil {
+		return m.OrigUID
+	}
+	return 0
+}
+
+func (m *Msg_APPEND) GetInternalDate() int64 {
+	if m != nil {
+		return m.InternalDate
+	}
+	return 0
+}
+
+func (m *Msg_APPEND) GetKeywords() []string {
+	if m != nil {
+		return m.Keywords
+	}
+	return nil
+}
+
+func (m *Msg_APPEND) GetModSeq() uint64 {
+	if m != nil {
+		return m.ModSeq
+	}
+	return 0
+}
+
 type Msg_EXPUNGE struct {
 	User    string `protobuf:"bytes,1,opt,name=user" json:"user,omitempty"`
 	Mailbox string `protobuf:"bytes,2,opt,name=mailbox" json:"mailbox,omitempty"`
 	RmvTag  string `protobuf:"bytes,3,opt,name=rmvTag" json:"rmvTag,omitempty"`
 	AddTag  string `protobuf:"bytes,4,opt,name=addTag" json:"addTag,omitempty"`
+	ModSeq  uint64 `protobuf:"varint,5,opt,name=modSeq" json:"modSeq,omitempty"`
 }
 
 func (m *Msg_EXPUNGE) Reset()                    { *m = Msg_EXPUNGE{} }
@@ -258,12 +347,21 @@ func (m *Msg_EXPUNGE) GetAddTag() string {
 	return ""
 }
 
+func (m *Msg_EXPUNGE) GetModSeq() uint64 {
+	if m != nil {
+		return m.ModSeq
+	}
+	return 0
+}
+
 type Msg_STORE struct {
-	User       string `protobuf:"bytes,1,opt,name=user" json:"user,omitempty"`
-	Mailbox    string `protobuf:"bytes,2,opt,name=mailbox" json:"mailbox,omitempty"`
-	RmvTag     string `protobuf:"bytes,3,opt,name=rmvTag" json:"rmvTag,omitempty"`
-	AddTag     string `protobuf:"bytes,4,opt,name=addTag" json:"addTag,omitempty"`
-	AddContent []byte `protobuf:"bytes,5,opt,name=addContent,proto3" json:"addContent,omitempty"`
+	User       string   `protobuf:"bytes,1,opt,name=user" json:"user,omitempty"`
+	Mailbox    string   `protobuf:"bytes,2,opt,name=mailbox" json:"mailbox,omitempty"`
+	RmvTag     string   `protobuf:"bytes,3,opt,name=rmvTag" json:"rmvTag,omitempty"`
+	AddTag     string   `protobuf:"bytes,4,opt,name=addTag" json:"addTag,omitempty"`
+	AddContent []byte   `protobuf:"bytes,5,opt,name=addContent,proto3" json:"addContent,omitempty"`
+	Keywords   []string `protobuf:"bytes,6,rep,name=keywords" json:"keywords,omitempty"`
+	ModSeq     uint64   `protobuf:"varint,7,opt,name=modSeq" json:"modSeq,omitempty"`
 }
 
 func (m *Msg_STORE) Reset()                    { *m = Msg_STORE{} }
@@ -306,6 +404,332 @@ func (m *Msg_STORE) GetAddContent() []byte {
 	return nil
 }
 
+func (m *Msg_STORE) GetKeywords() []string {
+	if m != nil {
+		return m.Keywords
+	}
+	return nil
+}
+
+func (m *Msg_STORE) GetModSeq() uint64 {
+	if m != nil {
+		return m.ModSeq
+	}
+	return 0
+}
+
+type Msg_COPY struct {
+	User          string   `protobuf:"bytes,1,opt,name=user" json:"user,omitempty"`
+	TargetMailbox string   `protobuf:"bytes,2,opt,name=targetMailbox" json:"targetMailbox,omitempty"`
+	AddTags       []string `protobuf:"bytes,3,rep,name=addTags" json:"addTags,omitempty"`
+	AddContents   [][]byte `protobuf:"bytes,4,rep,name=addContents,proto3" json:"addContents,omitempty"`
+	OrigUIDs      []uint32 `protobuf:"varint,5,rep,packed,name=origUIDs" json:"origUIDs,omitempty"`
+	ModSeq        uint64   `protobuf:"varint,6,opt,name=modSeq" json:"modSeq,omitempty"`
+}
+
+func (m *Msg_COPY) Reset()                    { *m = Msg_COPY{} }
+func (m *Msg_COPY) String() string            { return proto.CompactTextString(m) }
+func (*Msg_COPY) ProtoMessage()               {}
+func (*Msg_COPY) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 5} }
+
+func (m *Msg_COPY) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *Msg_COPY) GetTargetMailbox() string {
+	if m != nil {
+		return m.TargetMailbox
+	}
+	return ""
+}
+
+func (m *Msg_COPY) GetAddTags() []string {
+	if m != nil {
+		return m.AddTags
+	}
+	return nil
+}
+
+func (m *Msg_COPY) GetAddContents() [][]byte {
+	if m != nil {
+		return m.AddContents
+	}
+	return nil
+}
+
+func (m *Msg_COPY) GetOrigUIDs() []uint32 {
+	if m != nil {
+		return m.OrigUIDs
+	}
+	return nil
+}
+
+func (m *Msg_COPY) GetModSeq() uint64 {
+	if m != nil {
+		return m.ModSeq
+	}
+	return 0
+}
+
+type Msg_MOVE struct {
+	User          string   `protobuf:"bytes,1,opt,name=user" json:"user,omitempty"`
+	Mailbox       string   `protobuf:"bytes,2,opt,name=mailbox" json:"mailbox,omitempty"`
+	RmvTags       []string `protobuf:"bytes,3,rep,name=rmvTags" json:"rmvTags,omitempty"`
+	AddTag        string   `protobuf:"bytes,4,opt,name=addTag" json:"addTag,omitempty"`
+	TargetMailbox string   `protobuf:"bytes,5,opt,name=targetMailbox" json:"targetMailbox,omitempty"`
+	AddTags       []string `protobuf:"bytes,6,rep,name=addTags" json:"addTags,omitempty"`
+	AddContents   [][]byte `protobuf:"bytes,7,rep,name=addContents,proto3" json:"addContents,omitempty"`
+	OrigUIDs      []uint32 `protobuf:"varint,8,rep,packed,name=origUIDs" json:"origUIDs,omitempty"`
+	ModSeq        uint64   `protobuf:"varint,9,opt,name=modSeq" json:"modSeq,omitempty"`
+}
+
+func (m *Msg_MOVE) Reset()                    { *m = Msg_MOVE{} }
+func (m *Msg_MOVE) String() string            { return proto.CompactTextString(m) }
+func (*Msg_MOVE) ProtoMessage()               {}
+func (*Msg_MOVE) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 6} }
+
+func (m *Msg_MOVE) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *Msg_MOVE) GetMailbox() string {
+	if m != nil {
+		return m.Mailbox
+	}
+	return ""
+}
+
+func (m *Msg_MOVE) GetRmvTags() []string {
+	if m != nil {
+		return m.RmvTags
+	}
+	return nil
+}
+
+func (m *Msg_MOVE) GetAddTag() string {
+	if m != nil {
+		return m.AddTag
+	}
+	return ""
+}
+
+func (m *Msg_MOVE) GetTargetMailbox() string {
+	if m != nil {
+		return m.TargetMailbox
+	}
+	return ""
+}
+
+func (m *Msg_MOVE) GetAddTags() []string {
+	if m != nil {
+		return m.AddTags
+	}
+	return nil
+}
+
+func (m *Msg_MOVE) GetAddContents() [][]byte {
+	if m != nil {
+		return m.AddContents
+	}
+	return nil
+}
+
+func (m *Msg_MOVE) GetOrigUIDs() []uint32 {
+	if m != nil {
+		return m.OrigUIDs
+	}
+	return nil
+}
+
+func (m *Msg_MOVE) GetModSeq() uint64 {
+	if m != nil {
+		return m.ModSeq
+	}
+	return 0
+}
+
+type Msg_RENAME struct {
+	User    string               `protobuf:"bytes,1,opt,name=user" json:"user,omitempty"`
+	Folders []*Msg_RENAME_FOLDER `protobuf:"bytes,2,rep,name=folders" json:"folders,omitempty"`
+}
+
+func (m *Msg_RENAME) Reset()                    { *m = Msg_RENAME{} }
+func (m *Msg_RENAME) String() string            { return proto.CompactTextString(m) }
+func (*Msg_RENAME) ProtoMessage()               {}
+func (*Msg_RENAME) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 7} }
+
+func (m *Msg_RENAME) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *Msg_RENAME) GetFolders() []*Msg_RENAME_FOLDER {
+	if m != nil {
+		return m.Folders
+	}
+	return nil
+}
+
+type Msg_RENAME_FOLDER struct {
+	Mailbox     string   `protobuf:"bytes,1,opt,name=mailbox" json:"mailbox,omitempty"`
+	NewMailbox  string   `protobuf:"bytes,2,opt,name=newMailbox" json:"newMailbox,omitempty"`
+	UidValidity uint32   `protobuf:"varint,3,opt,name=uidValidity" json:"uidValidity,omitempty"`
+	RmvTags     []string `protobuf:"bytes,4,rep,name=rmvTags" json:"rmvTags,omitempty"`
+	RmvMails    []string `protobuf:"bytes,5,rep,name=rmvMails" json:"rmvMails,omitempty"`
+	AddTags     []string `protobuf:"bytes,6,rep,name=addTags" json:"addTags,omitempty"`
+	AddMails    []string `protobuf:"bytes,7,rep,name=addMails" json:"addMails,omitempty"`
+	AddContents [][]byte `protobuf:"bytes,8,rep,name=addContents,proto3" json:"addContents,omitempty"`
+	OrigUIDs    []uint32 `protobuf:"varint,9,rep,packed,name=origUIDs" json:"origUIDs,omitempty"`
+	SpecialUse  string   `protobuf:"bytes,10,opt,name=specialUse" json:"specialUse,omitempty"`
+}
+
+func (m *Msg_RENAME_FOLDER) Reset()                    { *m = Msg_RENAME_FOLDER{} }
+func (m *Msg_RENAME_FOLDER) String() string            { return proto.CompactTextString(m) }
+func (*Msg_RENAME_FOLDER) ProtoMessage()               {}
+func (*Msg_RENAME_FOLDER) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 7, 0} }
+
+func (m *Msg_RENAME_FOLDER) GetMailbox() string {
+	if m != nil {
+		return m.Mailbox
+	}
+	return ""
+}
+
+func (m *Msg_RENAME_FOLDER) GetNewMailbox() string {
+	if m != nil {
+		return m.NewMailbox
+	}
+	return ""
+}
+
+func (m *Msg_RENAME_FOLDER) GetUidValidity() uint32 {
+	if m != nil {
+		return m.UidValidity
+	}
+	return 0
+}
+
+func (m *Msg_RENAME_FOLDER) GetRmvTags() []string {
+	if m != nil {
+		return m.RmvTags
+	}
+	return nil
+}
+
+func (m *Msg_RENAME_FOLDER) GetRmvMails() []string {
+	if m != nil {
+		return m.RmvMails
+	}
+	return nil
+}
+
+func (m *Msg_RENAME_FOLDER) GetAddTags() []string {
+	if m != nil {
+		return m.AddTags
+	}
+	return nil
+}
+
+func (m *Msg_RENAME_FOLDER) GetAddMails() []string {
+	if m != nil {
+		return m.AddMails
+	}
+	return nil
+}
+
+func (m *Msg_RENAME_FOLDER) GetAddContents() [][]byte {
+	if m != nil {
+		return m.AddContents
+	}
+	return nil
+}
+
+func (m *Msg_RENAME_FOLDER) GetOrigUIDs() []uint32 {
+	if m != nil {
+		return m.OrigUIDs
+	}
+	return nil
+}
+
+func (m *Msg_RENAME_FOLDER) GetSpecialUse() string {
+	if m != nil {
+		return m.SpecialUse
+	}
+	return ""
+}
+
+type Msg_SUBSCRIBE struct {
+	User    string `protobuf:"bytes,1,opt,name=user" json:"user,omitempty"`
+	Mailbox string `protobuf:"bytes,2,opt,name=mailbox" json:"mailbox,omitempty"`
+	AddTag  string `protobuf:"bytes,3,opt,name=addTag" json:"addTag,omitempty"`
+}
+
+func (m *Msg_SUBSCRIBE) Reset()                    { *m = Msg_SUBSCRIBE{} }
+func (m *Msg_SUBSCRIBE) String() string            { return proto.CompactTextString(m) }
+func (*Msg_SUBSCRIBE) ProtoMessage()               {}
+func (*Msg_SUBSCRIBE) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 8} }
+
+func (m *Msg_SUBSCRIBE) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *Msg_SUBSCRIBE) GetMailbox() string {
+	if m != nil {
+		return m.Mailbox
+	}
+	return ""
+}
+
+func (m *Msg_SUBSCRIBE) GetAddTag() string {
+	if m != nil {
+		return m.AddTag
+	}
+	return ""
+}
+
+type Msg_UNSUBSCRIBE struct {
+	User    string   `protobuf:"bytes,1,opt,name=user" json:"user,omitempty"`
+	Mailbox string   `protobuf:"bytes,2,opt,name=mailbox" json:"mailbox,omitempty"`
+	RmvTags []string `protobuf:"bytes,3,rep,name=rmvTags" json:"rmvTags,omitempty"`
+}
+
+func (m *Msg_UNSUBSCRIBE) Reset()                    { *m = Msg_UNSUBSCRIBE{} }
+func (m *Msg_UNSUBSCRIBE) String() string            { return proto.CompactTextString(m) }
+func (*Msg_UNSUBSCRIBE) ProtoMessage()               {}
+func (*Msg_UNSUBSCRIBE) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 9} }
+
+func (m *Msg_UNSUBSCRIBE) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *Msg_UNSUBSCRIBE) GetMailbox() string {
+	if m != nil {
+		return m.Mailbox
+	}
+	return ""
+}
+
+func (m *Msg_UNSUBSCRIBE) GetRmvTags() []string {
+	if m != nil {
+		return m.RmvTags
+	}
+	return nil
+}
+
 type BinMsgs struct {
 	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
 }
@@ -345,6 +769,12 @@ func init() {
 	proto.RegisterType((*Msg_APPEND)(nil), "comm.Msg.APPEND")
 	proto.RegisterType((*Msg_EXPUNGE)(nil), "comm.Msg.EXPUNGE")
 	proto.RegisterType((*Msg_STORE)(nil), "comm.Msg.STORE")
+	proto.RegisterType((*Msg_COPY)(nil), "comm.Msg.COPY")
+	proto.RegisterType((*Msg_MOVE)(nil), "comm.Msg.MOVE")
+	proto.RegisterType((*Msg_RENAME)(nil), "comm.Msg.RENAME")
+	proto.RegisterType((*Msg_RENAME_FOLDER)(nil), "comm.Msg.RENAME.FOLDER")
+	proto.RegisterType((*Msg_SUBSCRIBE)(nil), "comm.Msg.SUBSCRIBE")
+	proto.RegisterType((*Msg_UNSUBSCRIBE)(nil), "comm.Msg.UNSUBSCRIBE")
 	proto.RegisterType((*BinMsgs)(nil), "comm.BinMsgs")
 	proto.RegisterType((*Conf)(nil), "comm.Conf")
 }
@@ -424,36 +854,57 @@ var _Receiver_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("receiver.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 484 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x54, 0x5d, 0x8b, 0x13, 0x31,
-	0x14, 0xb5, 0x3b, 0x5f, 0xdd, 0xdb, 0xad, 0xae, 0x41, 0x25, 0x0c, 0xba, 0x94, 0x82, 0x58, 0x10,
-	0xfb, 0xd0, 0x7d, 0x51, 0xdf, 0xd6, 0xee, 0x20, 0x82, 0xad, 0x25, 0x56, 0xf1, 0x35, 0x3b, 0x13,
-	0x87, 0x61, 0x67, 0x92, 0x21, 0x49, 0x87, 0xdd, 0x1f, 0xe0, 0x7f, 0xf0, 0xe7, 0x4a, 0x3e, 0x6a,
-	0x6b, 0x7d, 0x5a, 0xd0, 0xb7, 0x9c, 0x9c, 0xd3, 0x9b, 0x73, 0xcf, 0xed, 0x1d, 0xb8, 0x2f, 0x59,
-	0xce, 0xaa, 0x8e, 0xc9, 0x69, 0x2b, 0x85, 0x16, 0x28, 0xcc, 0x45, 0xd3, 0x8c, 0x7f, 0x26, 0x10,
-	0x2c, 0x54, 0x89, 0x30, 0x24, 0x92, 0xb5, 0x75, 0x95, 0x53, 0xdc, 0x1b, 0xf5, 0x26, 0xc7, 0x64,
-	0x0b, 0xd1, 0x2b, 0x88, 0xbb, 0xbc, 0x16, 0xf9, 0x35, 0x3e, 0x1a, 0x05, 0x93, 0xc1, 0xec, 0xf1,
-	0xd4, 0xfc, 0x70, 0xba, 0x50, 0xe5, 0xf4, 0xab, 0xbd, 0xcf, 0xb8, 0x96, 0xb7, 0xc4, 0x8b, 0xd0,
-	0x53, 0x38, 0x16, 0x2d, 0x93, 0x54, 0x57, 0x82, 0xe3, 0xc0, 0x96, 0xda, 0x5d, 0xa0, 0x09, 0xc4,
-	0xb9, 0x64, 0x54, 0x33, 0x1c, 0x8e, 0x7a, 0x93, 0xc1, 0xec, 0x74, 0x57, 0x6c, 0x4e, 0xb2, 0x8b,
-	0x75, 0x46, 0x3c, 0x6f, 0x94, 0x05, 0xab, 0x99, 0x66, 0x38, 0x3a, 0x54, 0x5e, 0x66, 0x1f, 0x33,
-	0xa3, 0x74, 0xbc, 0x51, 0xd2, 0xb6, 0x65, 0xbc, 0xc0, 0xf1, 0xa1, 0xf2, 0x62, 0xb5, 0xca, 0x96,
-	0x97, 0xc4, 0xf3, 0xe8, 0x25, 0x24, 0xec, 0xa6, 0xdd, 0xf0, 0x92, 0xe1, 0xc4, 0x4a, 0x1f, 0xee,
-	0xa4, 0xd9, 0xb7, 0xd5, 0x97, 0xe5, 0xfb, 0x8c, 0x6c, 0x15, 0xe8, 0x39, 0x44, 0x4a, 0x0b, 0xc9,
-	0x70, 0xdf, 0x4a, 0x1f, 0xec, 0xa4, 0x9f, 0xd7, 0x9f, 0x48, 0x46, 0x1c, 0x9b, 0x2e, 0x21, 0x76,
-	0xce, 0x11, 0x82, 0x70, 0xa3, 0x98, 0xf4, 0xf9, 0xd9, 0xb3, 0x89, 0xb5, 0xa1, 0x55, 0x7d, 0x25,
-	0x6e, 0xf0, 0x91, 0x8b, 0xd5, 0x43, 0xf4, 0x04, 0x62, 0x5a, 0x14, 0x6b, 0x5a, 0xfa, 0x90, 0x3c,
-	0x4a, 0x6b, 0x88, 0x5d, 0x7f, 0x77, 0xac, 0x67, 0x06, 0xd8, 0x74, 0x6b, 0x5a, 0x2a, 0x1c, 0x8c,
-	0x02, 0x3b, 0x40, 0x07, 0x51, 0x0a, 0x7d, 0xd9, 0x74, 0x0b, 0x5a, 0xd5, 0x0a, 0x87, 0x96, 0xfa,
-	0x8d, 0x53, 0x0e, 0xb1, 0xcb, 0xe8, 0xdf, 0xb8, 0x47, 0x67, 0x00, 0xb4, 0x28, 0xe6, 0x82, 0x6b,
-	0xc6, 0xb5, 0x9d, 0xf1, 0x09, 0xd9, 0xbb, 0x49, 0x4b, 0x48, 0x7c, 0xd0, 0x77, 0x7f, 0xd0, 0xf5,
-	0xb3, 0x7d, 0xd0, 0xa1, 0x3d, 0x23, 0xe1, 0x1f, 0x31, 0xfe, 0xe8, 0x41, 0x64, 0xe7, 0xf4, 0x7f,
-	0xdf, 0x39, 0x68, 0x38, 0xfa, 0xab, 0xe1, 0x37, 0x30, 0xd8, 0xdb, 0x12, 0x74, 0x0a, 0xc1, 0x35,
-	0xbb, 0xf5, 0x5e, 0xcc, 0x11, 0x3d, 0x82, 0xa8, 0xa3, 0xf5, 0x86, 0x59, 0x23, 0x43, 0xe2, 0xc0,
-	0xdb, 0xa3, 0xd7, 0xbd, 0xf1, 0x33, 0x48, 0xde, 0x55, 0x7c, 0xa1, 0x4a, 0x65, 0x7a, 0x28, 0xa8,
-	0x76, 0xab, 0x79, 0x42, 0xec, 0x79, 0x7c, 0x06, 0xe1, 0x5c, 0xf0, 0xef, 0xc6, 0x99, 0xd2, 0x54,
-	0x6f, 0x94, 0x65, 0x87, 0xc4, 0xa3, 0xd9, 0x39, 0xf4, 0x89, 0xdf, 0x78, 0xf4, 0x02, 0xfa, 0x1f,
-	0x78, 0x2e, 0x9a, 0x8a, 0x97, 0x68, 0xe8, 0xfe, 0xc8, 0xbe, 0x74, 0x0a, 0x0e, 0x9a, 0x52, 0xe3,
-	0x7b, 0x57, 0xb1, 0xfd, 0x36, 0x9c, 0xff, 0x0a, 0x00, 0x00, 0xff, 0xff, 0xc8, 0xd9, 0xeb, 0xaa,
-	0x2d, 0x04, 0x00, 0x00,
+	// 826 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x56, 0x4b, 0x8f, 0xe3, 0x44,
+	0x10, 0xc6, 0xe3, 0x57, 0x52, 0x19, 0x2f, 0xbb, 0xcd, 0xab, 0x65, 0xc1, 0x28, 0x8a, 0x40, 0x44,
+	0x42, 0x44, 0xda, 0xd9, 0x03, 0x8f, 0xdb, 0x3c, 0x0c, 0x1a, 0x69, 0x93, 0x19, 0x7a, 0x93, 0x11,
+	0x1c, 0x3b, 0x76, 0xaf, 0x65, 0xad, 0x5f, 0x72, 0x3b, 0x61, 0x73, 0x43, 0x42, 0x48, 0x1c, 0x39,
+	0xf2, 0x2b, 0xf8, 0x55, 0xfc, 0x10, 0xd4, 0x8f, 0xc4, 0x8e, 0x67, 0x27, 0x68, 0xd1, 0x70, 0x73,
+	0x55, 0x7d, 0x5d, 0x5d, 0x5f, 0xf5, 0xd7, 0xe5, 0x86, 0x47, 0x15, 0x0b, 0x59, 0xb2, 0x66, 0xd5,
+	0xa4, 0xac, 0x8a, 0xba, 0x40, 0x56, 0x58, 0x64, 0xd9, 0xe8, 0xd7, 0x27, 0x60, 0x4e, 0x79, 0x8c,
+	0x30, 0xb8, 0x15, 0x2b, 0xd3, 0x24, 0xa4, 0xd8, 0x18, 0x1a, 0xe3, 0x3e, 0xd9, 0x9a, 0xe8, 0x4b,
+	0x70, 0xd6, 0x61, 0x5a, 0x84, 0xaf, 0xf0, 0xd1, 0xd0, 0x1c, 0x0f, 0x4e, 0x3f, 0x98, 0x88, 0x85,
+	0x93, 0x29, 0x8f, 0x27, 0xb7, 0xd2, 0x1f, 0xe4, 0x75, 0xb5, 0x21, 0x1a, 0x84, 0x3e, 0x86, 0x7e,
+	0x51, 0xb2, 0x8a, 0xd6, 0x49, 0x91, 0x63, 0x53, 0xa6, 0x6a, 0x1c, 0x68, 0x0c, 0x4e, 0x58, 0x31,
+	0x5a, 0x33, 0x6c, 0x0d, 0x8d, 0xf1, 0xe0, 0xf4, 0x71, 0x93, 0xec, 0x82, 0x04, 0x67, 0xf3, 0x80,
+	0xe8, 0xb8, 0x40, 0x46, 0x2c, 0x65, 0x35, 0xc3, 0x76, 0x17, 0x79, 0x19, 0x3c, 0x0f, 0x04, 0x52,
+	0xc5, 0x05, 0x92, 0x96, 0x25, 0xcb, 0x23, 0xec, 0x74, 0x91, 0x67, 0x37, 0x37, 0xc1, 0xec, 0x92,
+	0xe8, 0x38, 0xfa, 0x02, 0x5c, 0xf6, 0xba, 0x5c, 0xe5, 0x31, 0xc3, 0xae, 0x84, 0x3e, 0x69, 0xa0,
+	0xc1, 0x8f, 0x37, 0x8b, 0xd9, 0xf7, 0x01, 0xd9, 0x22, 0xd0, 0x67, 0x60, 0xf3, 0xba, 0xa8, 0x18,
+	0xee, 0x49, 0xe8, 0xbb, 0x0d, 0xf4, 0xc5, 0xfc, 0x9a, 0x04, 0x44, 0x45, 0xd1, 0x08, 0xac, 0xb0,
+	0x28, 0x37, 0xb8, 0x2f, 0x51, 0x8f, 0x5a, 0x7c, 0xae, 0x6f, 0x7e, 0x22, 0x32, 0x26, 0x30, 0x59,
+	0xb1, 0x66, 0x18, 0xba, 0x98, 0xe9, 0xf5, 0x6d, 0x40, 0x64, 0x4c, 0xb0, 0xa8, 0x58, 0x4e, 0x33,
+	0x86, 0x07, 0x5d, 0x16, 0x24, 0x98, 0x9d, 0x4d, 0x03, 0xa2, 0xe3, 0xe8, 0x29, 0xf4, 0xf9, 0x6a,
+	0xc9, 0xc3, 0x2a, 0x59, 0x32, 0x7c, 0x2c, 0xc1, 0xef, 0xb5, 0x8a, 0x5b, 0x9c, 0xbf, 0xb8, 0x20,
+	0x57, 0xe7, 0x01, 0x69, 0x50, 0xe8, 0x2b, 0x18, 0xac, 0xf2, 0x66, 0x91, 0x37, 0x34, 0xf6, 0x0f,
+	0x72, 0x31, 0x6b, 0x96, 0xb5, 0x91, 0xfe, 0x1f, 0x06, 0x38, 0xea, 0x60, 0x10, 0x02, 0x6b, 0xc5,
+	0x59, 0xa5, 0xe5, 0x21, 0xbf, 0x85, 0x6a, 0x32, 0x9a, 0xa4, 0xcb, 0xe2, 0x35, 0x3e, 0x52, 0xaa,
+	0xd1, 0x26, 0xfa, 0x10, 0x1c, 0x1a, 0x45, 0x73, 0x1a, 0x6b, 0x0d, 0x68, 0x0b, 0x0d, 0x61, 0xb0,
+	0x4a, 0xa2, 0x5b, 0x9a, 0x26, 0x51, 0x52, 0x6f, 0xa4, 0x0a, 0x3c, 0xd2, 0x76, 0xa1, 0x13, 0x00,
+	0x5e, 0xb2, 0x30, 0xa1, 0xe9, 0x82, 0xab, 0xc3, 0xef, 0x93, 0x96, 0xc7, 0x4f, 0xc1, 0x51, 0x02,
+	0x78, 0xcb, 0x8a, 0x84, 0xc2, 0xb3, 0xf5, 0x9c, 0xc6, 0x1c, 0x9b, 0x43, 0x53, 0x44, 0xb4, 0x89,
+	0x7c, 0xe8, 0x55, 0xd9, 0x7a, 0x4a, 0x93, 0x94, 0x63, 0x4b, 0x86, 0x76, 0xb6, 0xff, 0xbb, 0x01,
+	0x8e, 0x52, 0xd1, 0x03, 0x35, 0xe0, 0x04, 0x80, 0x46, 0xd1, 0x45, 0x91, 0xd7, 0x2c, 0xaf, 0x25,
+	0xff, 0x63, 0xd2, 0xf2, 0x88, 0x8c, 0x45, 0x95, 0xc4, 0x8b, 0xab, 0x4b, 0xc9, 0xdd, 0x23, 0x5b,
+	0xd3, 0x8f, 0xc1, 0xd5, 0x22, 0x7d, 0xfb, 0x52, 0x14, 0xd5, 0x6d, 0x29, 0xca, 0x6a, 0x95, 0x68,
+	0xb5, 0x4b, 0xf4, 0x7f, 0x33, 0xc0, 0x96, 0x1a, 0xff, 0x7f, 0xf7, 0xe9, 0xb4, 0xc2, 0xee, 0xb6,
+	0xc2, 0xff, 0xd3, 0x00, 0x4b, 0xdc, 0xa2, 0x37, 0x96, 0xf1, 0x29, 0x78, 0x35, 0xad, 0x62, 0x56,
+	0x4f, 0xf7, 0x8a, 0xd9, 0x77, 0x8a, 0x62, 0xd5, 0x66, 0xbb, 0x43, 0xd7, 0xa6, 0x10, 0x62, 0xb3,
+	0x95, 0x3a, 0xf7, 0x63, 0xd2, 0x76, 0x09, 0x59, 0xe8, 0xd6, 0x73, 0x6c, 0x0f, 0xcd, 0xb1, 0x47,
+	0x76, 0xb6, 0xff, 0xb7, 0x01, 0x96, 0xb8, 0xbc, 0x0f, 0xa6, 0xc1, 0xfb, 0x7a, 0x74, 0x87, 0xa6,
+	0xfd, 0x2f, 0x34, 0x9d, 0x83, 0x34, 0xdd, 0xc3, 0x34, 0x7b, 0x1d, 0x9a, 0xbf, 0x98, 0xe0, 0xa8,
+	0xe9, 0xf3, 0x46, 0xa2, 0x4f, 0xc1, 0x7d, 0x59, 0xa4, 0x11, 0xab, 0xb8, 0xfe, 0x37, 0x7c, 0xd4,
+	0x1d, 0x5a, 0x93, 0xef, 0xae, 0x9f, 0x5f, 0x06, 0x84, 0x6c, 0x71, 0xfe, 0x5f, 0x47, 0xe0, 0x28,
+	0x5f, 0xbb, 0x4d, 0xc6, 0x7e, 0x9b, 0x4e, 0x00, 0x72, 0xf6, 0xf3, 0xfe, 0xc1, 0xb6, 0x3c, 0xdd,
+	0x21, 0x62, 0xde, 0x1d, 0x22, 0xad, 0x46, 0x5b, 0xf7, 0x5f, 0x76, 0x7b, 0xff, 0xb2, 0x1f, 0x68,
+	0xa3, 0x0f, 0x3d, 0x1a, 0x45, 0x6a, 0x95, 0xab, 0x56, 0x6d, 0xed, 0x6e, 0x8b, 0x7b, 0x87, 0x5b,
+	0xdc, 0xdf, 0x6f, 0x71, 0x67, 0xdc, 0xc1, 0x9d, 0x71, 0xf7, 0x03, 0xf4, 0x77, 0xb3, 0xf9, 0x61,
+	0x46, 0x90, 0xbf, 0x80, 0xc1, 0x62, 0xf6, 0x5f, 0x93, 0xde, 0x2b, 0x61, 0xff, 0x1b, 0x18, 0xb4,
+	0x1e, 0x04, 0xe8, 0x31, 0x98, 0xaf, 0xd8, 0x46, 0x67, 0x15, 0x9f, 0xe8, 0x7d, 0xb0, 0xd7, 0x34,
+	0x5d, 0x31, 0x99, 0xd2, 0x23, 0xca, 0xf8, 0xf6, 0xe8, 0x6b, 0x63, 0xf4, 0x09, 0xb8, 0xe7, 0x49,
+	0x3e, 0xe5, 0x31, 0x17, 0xd5, 0x44, 0xb4, 0x56, 0xaf, 0x90, 0x63, 0x22, 0xbf, 0x47, 0x27, 0x60,
+	0x5d, 0x14, 0xf9, 0x4b, 0x41, 0x88, 0xd7, 0xb4, 0x5e, 0x71, 0x19, 0xf5, 0x88, 0xb6, 0x4e, 0x9f,
+	0x41, 0x8f, 0xe8, 0xc7, 0x0d, 0xfa, 0x1c, 0x7a, 0x57, 0x79, 0x58, 0x64, 0x49, 0x1e, 0x23, 0x4f,
+	0xc9, 0x51, 0xa7, 0xf6, 0x41, 0x99, 0x22, 0xd5, 0xe8, 0x9d, 0xa5, 0x23, 0x9f, 0x41, 0xcf, 0xfe,
+	0x19, 0x00, 0x8d, 0x40, 0x6b, 0xe3, 0x18, 0x09, 0x00, 0x00,
 }
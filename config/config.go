@@ -22,27 +22,49 @@ type Config struct {
 }
 
 // IMAP is the IMAP server related part
-// of the TOML config file.
+// of the TOML config file. Capabilities listed
+// in DisabledCapabilities are not advertised and
+// their commands are refused.
 type IMAP struct {
-	Greeting           string
-	HierarchySeparator string
+	Greeting             string
+	HierarchySeparator   string
+	DefaultFolders       []DefaultFolder
+	DisabledCapabilities []string
+}
+
+// DefaultFolder names a folder that is created and
+// subscribed for each new user on first login,
+// optionally marked with a special-use attribute
+// such as \Sent or \Trash (RFC 6154).
+type DefaultFolder struct {
+	Name       string
+	SpecialUse string
 }
 
 // Distributor describes the configuration of
 // the first entry point of a pluto setup, the
 // IMAP request authenticator and distributor.
+// If ListenCleartextAddr is set, connections without
+// TLS are accepted there and upgraded via STARTTLS.
+// Clients presenting a certificate issued by one of
+// the authorities in PublicClientCALoc may log in via
+// SASL EXTERNAL.
 type Distributor struct {
-	Name            string
-	PublicMailAddr  string
-	ListenMailAddr  string
-	PrometheusAddr  string
-	PublicCertLoc   string
-	PublicKeyLoc    string
-	InternalCertLoc string
-	InternalKeyLoc  string
-	AuthAdapter     string
-	AuthFile        *AuthFile
-	AuthPostgres    *AuthPostgres
+	Name                string
+	PublicMailAddr      string
+	ListenMailAddr      string
+	ListenCleartextAddr string
+	PrometheusAddr      string
+	PublicCertLoc       string
+	PublicKeyLoc        string
+	PublicClientCALoc   string
+	InternalCertLoc     string
+	InternalKeyLoc      string
+	AuthAdapter         string
+	AuthFile            *AuthFile
+	AuthPostgres        *AuthPostgres
+	AuthOAuth           *AuthOAuth
+	AuthLDAP            *AuthLDAP
 }
 
 // Worker contains the connection and user sharding
@@ -80,13 +102,70 @@ type Storage struct {
 
 // AuthPostgres defines parameters for connecting
 // to a Postgres database for authenticating users.
+// Table and the column names map the users table,
+// unless UserQuery selects ID and password hash of
+// the user named $1 itself. RehashQuery then stores
+// upgraded hash $1 for user ID $2, which ReadOnly
+// turns off. Timeouts and intervals are in seconds.
 type AuthPostgres struct {
-	IP       string
-	Port     uint16
-	Database string
-	User     string
-	Password string
-	UseTLS   bool
+	IP                  string
+	Port                uint16
+	Database            string
+	User                string
+	Password            string
+	UseTLS              bool
+	MaxConnections      int
+	ConnectTimeout      int
+	QueryTimeout        int
+	HealthCheckInterval int
+	Table               string
+	IDColumn            string
+	UsernameColumn      string
+	PasswordColumn      string
+	UserQuery           string
+	RehashQuery         string
+	ReadOnly            bool
+	DefaultScheme       string
+}
+
+// AuthOAuth defines how to validate OAuth 2.0 bearer
+// tokens: offline against the keys of a JWKS file or
+// URL, or via an introspection endpoint. UsernameClaim
+// names the claim containing the user name, IDClaim the
+// one containing the numeric user ID that routes users
+// to their worker just like the password backends do.
+type AuthOAuth struct {
+	JWKSFile                  string
+	JWKSURL                   string
+	IntrospectionURL          string
+	IntrospectionClientID     string
+	IntrospectionClientSecret string
+	Issuer                    string
+	Audience                  string
+	UsernameClaim             string
+	IDClaim                   string
+}
+
+// AuthLDAP defines how to authenticate users against
+// an LDAP directory. If UserDNTemplate is set, users
+// bind directly as the DN it yields for their name.
+// Otherwise, pluto binds as BindDN, searches below
+// BaseDN with UserFilter, and binds as the entry found.
+// IDAttribute names the attribute holding the numeric
+// user ID or another shard key used for routing.
+// Timeout is given in seconds.
+type AuthLDAP struct {
+	URL            string
+	StartTLS       bool
+	RootCALoc      string
+	BindDN         string
+	BindPassword   string
+	UserDNTemplate string
+	BaseDN         string
+	UserFilter     string
+	IDAttribute    string
+	PoolSize       int
+	Timeout        int
 }
 
 // AuthFile provides information on authenticating
@@ -155,6 +234,11 @@ func LoadConfig(configFile string) (*Config, error) {
 		conf.Distributor.PublicKeyLoc = filepath.Join(absPlutoPath, conf.Distributor.PublicKeyLoc)
 	}
 
+	// Distributor.PublicClientCALoc
+	if (conf.Distributor.PublicClientCALoc != "") && (filepath.IsAbs(conf.Distributor.PublicClientCALoc) != true) {
+		conf.Distributor.PublicClientCALoc = filepath.Join(absPlutoPath, conf.Distributor.PublicClientCALoc)
+	}
+
 	// Distributor.InternalCertLoc
 	if filepath.IsAbs(conf.Distributor.InternalCertLoc) != true {
 		conf.Distributor.InternalCertLoc = filepath.Join(absPlutoPath, conf.Distributor.InternalCertLoc)
@@ -173,6 +257,22 @@ func LoadConfig(configFile string) (*Config, error) {
 		}
 	}
 
+	if (conf.Distributor.AuthAdapter == "AuthOAuth") && (conf.Distributor.AuthOAuth != nil) && (conf.Distributor.AuthOAuth.JWKSFile != "") {
+
+		// Distributor.AuthOAuth.JWKSFile
+		if filepath.IsAbs(conf.Distributor.AuthOAuth.JWKSFile) != true {
+			conf.Distributor.AuthOAuth.JWKSFile = filepath.Join(absPlutoPath, conf.Distributor.AuthOAuth.JWKSFile)
+		}
+	}
+
+	if (conf.Distributor.AuthAdapter == "AuthLDAP") && (conf.Distributor.AuthLDAP != nil) && (conf.Distributor.AuthLDAP.RootCALoc != "") {
+
+		// Distributor.AuthLDAP.RootCALoc
+		if filepath.IsAbs(conf.Distributor.AuthLDAP.RootCALoc) != true {
+			conf.Distributor.AuthLDAP.RootCALoc = filepath.Join(absPlutoPath, conf.Distributor.AuthLDAP.RootCALoc)
+		}
+	}
+
 	for name, worker := range conf.Workers {
 
 		// Workers[worker].CertLoc
@@ -0,0 +1,323 @@
+package crdt
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"encoding/base64"
+	"io/ioutil"
+)
+
+// Constants
+
+// NumKeywordLetters is the number of keywords one
+// user can define, one per lowercase letter.
+const NumKeywordLetters = 26
+
+// Structs
+
+// KeywordSet maps the IMAP keywords (user-defined flags)
+// of one user to the lowercase letters 'a' to 'z' that
+// represent them in the info suffix of Maildir file
+// names, like Dovecot's dovecot-keywords file does.
+//
+// It is a grow-only set of (letter, keyword) pairs. A
+// source node binds a new keyword to the first letter
+// no pair refers to yet. If replicas concurrently bind
+// the same letter to different keywords, the keyword
+// sorting first owns the letter on all replicas. Other
+// keywords bound to that letter lose their binding and
+// receive a new letter the next time they are used.
+type KeywordSet struct {
+	File    *os.File
+	Letters [NumKeywordLetters][]string
+}
+
+// Functions
+
+// InitKeywordSetWithFile takes in a file name and
+// initializes a new KeywordSet with opened file
+// handler to that name as designated log file.
+func InitKeywordSetWithFile(fileName string) (*KeywordSet, error) {
+
+	// Attempt to create a new CRDT file.
+	f, err := os.Create(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("opening CRDT file '%s' failed with: %v", fileName, err)
+	}
+
+	// Change permissions.
+	err = f.Chmod(0600)
+	if err != nil {
+		return nil, fmt.Errorf("changing permissions of CRDT file '%s' failed with: %v", fileName, err)
+	}
+
+	// Init an empty KeywordSet.
+	s := &KeywordSet{
+		File: f,
+	}
+
+	// Write newly created CRDT file to stable storage.
+	err = s.WriteKeywordSetToFile()
+	if err != nil {
+		return nil, fmt.Errorf("error during CRDT file write-back: %v", err)
+	}
+
+	return s, nil
+}
+
+// InitKeywordSetFromFile parses a KeywordSet found
+// in the supplied file and returns it, initialized
+// with elements saved in file.
+func InitKeywordSetFromFile(fileName string) (*KeywordSet, error) {
+
+	// Attempt to open CRDT file and assign to set afterwards.
+	f, err := os.OpenFile(fileName, os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("opening CRDT file '%s' failed with: %v", fileName, err)
+	}
+
+	// Init an empty KeywordSet.
+	s := &KeywordSet{
+		File: f,
+	}
+
+	// Parse contained CRDT state from file.
+	contentsRaw, err := ioutil.ReadAll(s.File)
+	if err != nil {
+		return nil, fmt.Errorf("reading all contents from CRDT file '%s' failed with: %v", fileName, err)
+	}
+	contents := strings.TrimSpace(string(contentsRaw))
+
+	// Account for an empty CRDT set which is valid.
+	if contents == "" {
+		return s, nil
+	}
+
+	// Split content at each ';' (semicolon).
+	parts := strings.Split(contents, ";")
+
+	// Elements are always stored as pairs.
+	if (len(parts) % 2) != 0 {
+		return nil, fmt.Errorf("odd number of elements in CRDT file '%s'", fileName)
+	}
+
+	// Range over all letter-keyword pairs.
+	for i := 0; i < len(parts); i += 2 {
+
+		if (len(parts[i]) != 1) || (parts[i][0] < 'a') || (parts[i][0] > 'z') {
+			return nil, fmt.Errorf("invalid keyword letter '%s' in CRDT file '%s'", parts[i], fileName)
+		}
+
+		keyword, err := base64.StdEncoding.DecodeString(parts[(i + 1)])
+		if err != nil {
+			return nil, fmt.Errorf("decoding base64 string in CRDT file '%s' failed: %v", fileName, err)
+		}
+
+		s.AddEffect(rune(parts[i][0]), string(keyword), false)
+	}
+
+	return s, nil
+}
+
+// WriteKeywordSetToFile saves an active KeywordSet
+// onto stable storage at location from initialization.
+func (s *KeywordSet) WriteKeywordSetToFile() error {
+
+	elements := make([]string, 0, (2 * NumKeywordLetters))
+
+	for i, keywords := range s.Letters {
+
+		for _, keyword := range keywords {
+			elements = append(elements, string(rune('a'+i)), base64.StdEncoding.EncodeToString([]byte(keyword)))
+		}
+	}
+
+	marshalled := strings.Join(elements, ";")
+
+	// Reset position in file to beginning.
+	_, err := s.File.Seek(0, os.SEEK_SET)
+	if err != nil {
+		return fmt.Errorf("error while setting head back to beginning in CRDT file '%s': %v", s.File.Name(), err)
+	}
+
+	// Write marshalled set to file.
+	newNumOfBytes, err := s.File.WriteString(marshalled)
+	if err != nil {
+		return fmt.Errorf("failed to write KeywordSet contents to file '%s': %v", s.File.Name(), err)
+	}
+
+	// Adjust file size to just written length of string.
+	err = s.File.Truncate(int64(newNumOfBytes))
+	if err != nil {
+		return fmt.Errorf("error while truncating CRDT file '%s' to new size: %v", s.File.Name(), err)
+	}
+
+	// Save to stable storage.
+	err = s.File.Sync()
+	if err != nil {
+		return fmt.Errorf("could not synchronise CRDT file '%s' contents to stable storage: %v", s.File.Name(), err)
+	}
+
+	return nil
+}
+
+// AddEffect is the effect part of an add operation. It
+// is executed by all replicas including the source node
+// and inserts the pair of letter and keyword. Adding an
+// already present pair has no effect.
+func (s *KeywordSet) AddEffect(letter rune, keyword string, needsWriteBack bool) error {
+
+	if (letter < 'a') || (letter > 'z') {
+		return fmt.Errorf("invalid keyword letter '%c'", letter)
+	}
+
+	i := int(letter - 'a')
+	keywords := s.Letters[i]
+
+	// Keep keywords of a letter sorted, the
+	// first one owns the letter.
+	pos := sort.SearchStrings(keywords, keyword)
+	if (pos < len(keywords)) && (keywords[pos] == keyword) {
+		return nil
+	}
+
+	keywords = append(keywords, "")
+	copy(keywords[(pos+1):], keywords[pos:])
+	keywords[pos] = keyword
+	s.Letters[i] = keywords
+
+	if !needsWriteBack {
+		return nil
+	}
+
+	// Instructed to write changes back to file.
+	err := s.WriteKeywordSetToFile()
+	if err != nil {
+
+		// Error during write-back to stable storage,
+		// remove just added pair again.
+		s.Letters[i] = append(keywords[:pos], keywords[(pos+1):]...)
+
+		return fmt.Errorf("error during writing CRDT file back: %v", err)
+	}
+
+	return nil
+}
+
+// MergeEffect adds all bindings of supplied list as
+// returned by Bindings at another replica.
+func (s *KeywordSet) MergeEffect(bindings []string, needsWriteBack bool) error {
+
+	if len(bindings) > NumKeywordLetters {
+		return fmt.Errorf("received %d keyword bindings, at most %d are possible", len(bindings), NumKeywordLetters)
+	}
+
+	for i, keyword := range bindings {
+
+		if keyword != "" {
+			s.AddEffect(rune('a'+i), keyword, false)
+		}
+	}
+
+	if !needsWriteBack {
+		return nil
+	}
+
+	return s.WriteKeywordSetToFile()
+}
+
+// Add is a helper function only to be executed at the
+// source node of an update. It returns the letter bound
+// to keyword, binding the first free letter to it if
+// none is yet. The binding is not sent on its own, but
+// is part of Bindings sent along with the update making
+// use of it. The second return value indicates whether
+// a new binding was created.
+func (s *KeywordSet) Add(keyword string) (rune, bool, error) {
+
+	if letter, found := s.Letter(keyword); found {
+		return letter, false, nil
+	}
+
+	for i, keywords := range s.Letters {
+
+		if len(keywords) == 0 {
+
+			letter := rune('a' + i)
+
+			err := s.AddEffect(letter, keyword, true)
+			if err != nil {
+				return 0, false, err
+			}
+
+			return letter, true, nil
+		}
+	}
+
+	return 0, false, fmt.Errorf("all %d keywords are in use", NumKeywordLetters)
+}
+
+// Keyword returns the keyword owning letter
+// and whether the letter is in use at all.
+func (s *KeywordSet) Keyword(letter rune) (string, bool) {
+
+	if (letter < 'a') || (letter > 'z') || (len(s.Letters[(letter-'a')]) == 0) {
+		return "", false
+	}
+
+	return s.Letters[(letter - 'a')][0], true
+}
+
+// Letter returns the letter owned by keyword and
+// whether there is one. Keywords are compared
+// case-insensitively.
+func (s *KeywordSet) Letter(keyword string) (rune, bool) {
+
+	for i, keywords := range s.Letters {
+
+		if (len(keywords) > 0) && strings.EqualFold(keywords[0], keyword) {
+			return rune('a' + i), true
+		}
+	}
+
+	return 0, false
+}
+
+// Bindings returns the owning keyword of each letter
+// in use, indexed by position of the letter in the
+// alphabet. Unused letters are left empty.
+func (s *KeywordSet) Bindings() []string {
+
+	bindings := make([]string, 0, NumKeywordLetters)
+
+	for i, keywords := range s.Letters {
+
+		if len(keywords) > 0 {
+
+			for len(bindings) < i {
+				bindings = append(bindings, "")
+			}
+
+			bindings = append(bindings, keywords[0])
+		}
+	}
+
+	return bindings
+}
+
+// Full returns true if all letters are in use
+// and thus no further keyword can be defined.
+func (s *KeywordSet) Full() bool {
+
+	for _, keywords := range s.Letters {
+
+		if len(keywords) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,93 @@
+package crdt
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Functions
+
+// TestKeywordSetAdd executes a white-box unit
+// test on implemented Add() function.
+func TestKeywordSetAdd(t *testing.T) {
+
+	// Delete temporary test file on function exit.
+	defer os.Remove("test-keywords.log")
+
+	s, err := InitKeywordSetWithFile("test-keywords.log")
+	assert.Nilf(t, err, "failed to initialize KeywordSet: %v", err)
+
+	letter, created, err := s.Add("$Junk")
+	assert.Nilf(t, err, "expected Add() not to fail but got: %v", err)
+	assert.Equalf(t, 'a', letter, "expected first keyword to be bound to 'a' but got '%c'", letter)
+	assert.Equalf(t, true, created, "expected first keyword to be newly bound")
+
+	letter, _, _ = s.Add("$Forwarded")
+	assert.Equalf(t, 'b', letter, "expected second keyword to be bound to 'b' but got '%c'", letter)
+
+	// Known keywords keep their letter, regardless of case.
+	letter, created, _ = s.Add("$junk")
+	assert.Equalf(t, 'a', letter, "expected known keyword to keep 'a' but got '%c'", letter)
+	assert.Equalf(t, false, created, "expected known keyword not to be bound again")
+
+	keyword, found := s.Keyword('b')
+	assert.Equalf(t, true, found, "expected letter 'b' to be in use")
+	assert.Equalf(t, "$Forwarded", keyword, "expected letter 'b' to denote $Forwarded but got %s", keyword)
+
+	_, found = s.Keyword('c')
+	assert.Equalf(t, false, found, "expected letter 'c' not to be in use")
+	assert.Equalf(t, []string{"$Junk", "$Forwarded"}, s.Bindings(), "unexpected bindings")
+
+	// Use up all remaining letters.
+	for i := 2; i < NumKeywordLetters; i++ {
+		s.Add(string(rune('A' + i)))
+	}
+	assert.Equalf(t, true, s.Full(), "expected all letters to be in use")
+
+	_, _, err = s.Add("$MDNSent")
+	assert.NotNilf(t, err, "expected Add() to fail when all letters are in use")
+
+	// State survives a restart.
+	r, err := InitKeywordSetFromFile("test-keywords.log")
+	assert.Nilf(t, err, "failed to read KeywordSet from file: %v", err)
+	assert.Equalf(t, s.Bindings(), r.Bindings(), "expected bindings read from file to equal written ones")
+}
+
+// TestKeywordSetConvergence executes a white-box unit
+// test checking that replicas concurrently binding the
+// same letter agree on the keyword owning it.
+func TestKeywordSetConvergence(t *testing.T) {
+
+	// Delete temporary test files on function exit.
+	defer os.Remove("test-keywords-1.log")
+	defer os.Remove("test-keywords-2.log")
+
+	s1, err := InitKeywordSetWithFile("test-keywords-1.log")
+	assert.Nilf(t, err, "failed to initialize KeywordSet: %v", err)
+
+	s2, err := InitKeywordSetWithFile("test-keywords-2.log")
+	assert.Nilf(t, err, "failed to initialize KeywordSet: %v", err)
+
+	// Both replicas concurrently bind letter 'a'.
+	s1.Add("$NotJunk")
+	s2.Add("$Junk")
+
+	// Exchange updates.
+	b1 := s1.Bindings()
+	b2 := s2.Bindings()
+	s1.MergeEffect(b2, true)
+	s2.MergeEffect(b1, true)
+
+	// Merging an update twice has no effect.
+	s2.MergeEffect(b1, true)
+
+	assert.Equalf(t, s1.Bindings(), s2.Bindings(), "expected replicas to agree on bindings")
+	assert.Equalf(t, []string{"$Junk"}, s1.Bindings(), "expected $Junk to own letter 'a'")
+
+	// The losing keyword receives a new letter.
+	letter, created, _ := s1.Add("$NotJunk")
+	assert.Equalf(t, 'b', letter, "expected losing keyword to be bound to 'b' but got '%c'", letter)
+	assert.Equalf(t, true, created, "expected losing keyword to be newly bound")
+}
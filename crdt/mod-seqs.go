@@ -0,0 +1,355 @@
+package crdt
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"encoding/base64"
+	"io/ioutil"
+)
+
+// Structs
+
+// ModSeqs tracks the modification sequences (RFC 7162)
+// of the messages in all mailbox folders of one user.
+// Each change of a message, i.e. adding it, changing
+// its flags, or expunging it, assigns it the next
+// MODSEQ of its folder. Expunged messages are kept
+// with the MODSEQ of their removal, so that clients
+// can learn which of their messages vanished.
+//
+// A source node assigns a change the folder's highest
+// MODSEQ plus one and sends it along with the update.
+// Downstream replicas apply the maximum of received
+// value and their own highest MODSEQ plus one. Replicas
+// having applied the same updates thus assign the same
+// values, while concurrent updates of a partition are
+// sorted after everything a replica already reported
+// to its clients. This keeps each replica's values
+// strictly increasing, which resynchronization needs.
+type ModSeqs struct {
+	File    *os.File
+	Folders map[string]*ModSeqFolder
+}
+
+// ModSeqFolder contains the MODSEQ state of one folder.
+// Mails maps keys of present messages to their MODSEQ,
+// Expunged the keys of removed ones.
+type ModSeqFolder struct {
+	Highest  uint64
+	Mails    map[string]uint64
+	Expunged map[string]uint64
+}
+
+// Functions
+
+// InitModSeqsWithFile takes in a file name and
+// initializes new ModSeqs with opened file handler
+// to that name as designated log file.
+func InitModSeqsWithFile(fileName string) (*ModSeqs, error) {
+
+	// Attempt to create a new CRDT file.
+	f, err := os.Create(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("opening CRDT file '%s' failed with: %v", fileName, err)
+	}
+
+	// Change permissions.
+	err = f.Chmod(0600)
+	if err != nil {
+		return nil, fmt.Errorf("changing permissions of CRDT file '%s' failed with: %v", fileName, err)
+	}
+
+	// Init empty ModSeqs.
+	s := &ModSeqs{
+		File:    f,
+		Folders: make(map[string]*ModSeqFolder),
+	}
+
+	// Write newly created CRDT file to stable storage.
+	err = s.WriteModSeqsToFile()
+	if err != nil {
+		return nil, fmt.Errorf("error during CRDT file write-back: %v", err)
+	}
+
+	return s, nil
+}
+
+// InitModSeqsFromFile parses ModSeqs found in the
+// supplied file and returns them, initialized with
+// elements saved in file.
+func InitModSeqsFromFile(fileName string) (*ModSeqs, error) {
+
+	// Attempt to open CRDT file and assign to set afterwards.
+	f, err := os.OpenFile(fileName, os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("opening CRDT file '%s' failed with: %v", fileName, err)
+	}
+
+	// Init empty ModSeqs.
+	s := &ModSeqs{
+		File:    f,
+		Folders: make(map[string]*ModSeqFolder),
+	}
+
+	// Parse contained CRDT state from file.
+	contentsRaw, err := ioutil.ReadAll(s.File)
+	if err != nil {
+		return nil, fmt.Errorf("reading all contents from CRDT file '%s' failed with: %v", fileName, err)
+	}
+	contents := strings.TrimSpace(string(contentsRaw))
+
+	// Account for an empty CRDT set which is valid.
+	if contents == "" {
+		return s, nil
+	}
+
+	// Split content at each ';' (semicolon).
+	parts := strings.Split(contents, ";")
+
+	// Elements are always stored as quadruples.
+	if (len(parts) % 4) != 0 {
+		return nil, fmt.Errorf("number of elements in CRDT file '%s' not a multiple of four", fileName)
+	}
+
+	// Range over all folder-key-number-kind quadruples.
+	// Kind 'm' denotes a present, 'x' an expunged mail.
+	// An empty key denotes the folder's highest MODSEQ.
+	for i := 0; i < len(parts); i += 4 {
+
+		folder, err := base64.StdEncoding.DecodeString(parts[i])
+		if err != nil {
+			return nil, fmt.Errorf("decoding base64 string in CRDT file '%s' failed: %v", fileName, err)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(parts[(i + 1)])
+		if err != nil {
+			return nil, fmt.Errorf("decoding base64 string in CRDT file '%s' failed: %v", fileName, err)
+		}
+
+		modSeq, err := strconv.ParseUint(parts[(i+2)], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing number in CRDT file '%s' failed: %v", fileName, err)
+		}
+
+		f := s.folder(string(folder))
+
+		if modSeq > f.Highest {
+			f.Highest = modSeq
+		}
+
+		switch {
+		case len(key) == 0:
+		case parts[(i + 3)] == "m":
+			f.Mails[string(key)] = modSeq
+		case parts[(i + 3)] == "x":
+			f.Expunged[string(key)] = modSeq
+		default:
+			return nil, fmt.Errorf("unknown kind '%s' in CRDT file '%s'", parts[(i+3)], fileName)
+		}
+	}
+
+	return s, nil
+}
+
+// WriteModSeqsToFile saves active ModSeqs onto
+// stable storage at location from initialization.
+func (s *ModSeqs) WriteModSeqsToFile() error {
+
+	elements := make([]string, 0, (4 * len(s.Folders)))
+
+	for folderRaw, folder := range s.Folders {
+
+		name := base64.StdEncoding.EncodeToString([]byte(folderRaw))
+
+		elements = append(elements, name, "", strconv.FormatUint(folder.Highest, 10), "m")
+
+		for key, modSeq := range folder.Mails {
+			elements = append(elements, name, base64.StdEncoding.EncodeToString([]byte(key)), strconv.FormatUint(modSeq, 10), "m")
+		}
+
+		for key, modSeq := range folder.Expunged {
+			elements = append(elements, name, base64.StdEncoding.EncodeToString([]byte(key)), strconv.FormatUint(modSeq, 10), "x")
+		}
+	}
+
+	marshalled := strings.Join(elements, ";")
+
+	// Reset position in file to beginning.
+	_, err := s.File.Seek(0, os.SEEK_SET)
+	if err != nil {
+		return fmt.Errorf("error while setting head back to beginning in CRDT file '%s': %v", s.File.Name(), err)
+	}
+
+	// Write marshalled set to file.
+	newNumOfBytes, err := s.File.WriteString(marshalled)
+	if err != nil {
+		return fmt.Errorf("failed to write ModSeqs contents to file '%s': %v", s.File.Name(), err)
+	}
+
+	// Adjust file size to just written length of string.
+	err = s.File.Truncate(int64(newNumOfBytes))
+	if err != nil {
+		return fmt.Errorf("error while truncating CRDT file '%s' to new size: %v", s.File.Name(), err)
+	}
+
+	// Save to stable storage.
+	err = s.File.Sync()
+	if err != nil {
+		return fmt.Errorf("could not synchronise CRDT file '%s' contents to stable storage: %v", s.File.Name(), err)
+	}
+
+	return nil
+}
+
+// folder returns the state of the named folder,
+// initializing it if not yet present.
+func (s *ModSeqs) folder(name string) *ModSeqFolder {
+
+	f, found := s.Folders[name]
+	if !found {
+
+		f = &ModSeqFolder{
+			Mails:    make(map[string]uint64),
+			Expunged: make(map[string]uint64),
+		}
+		s.Folders[name] = f
+	}
+
+	return f
+}
+
+// next returns the MODSEQ to assign to a change of
+// folder f, which is at least the supplied one.
+func (f *ModSeqFolder) next(modSeq uint64) uint64 {
+
+	if modSeq <= f.Highest {
+		modSeq = f.Highest + 1
+	}
+
+	f.Highest = modSeq
+
+	return modSeq
+}
+
+// Update records a change of the message identified by
+// key in folder, e.g. because it was added or its flags
+// were changed. Source nodes pass zero as modSeq,
+// downstream replicas the value the source assigned.
+// The MODSEQ assigned by this replica is returned.
+func (s *ModSeqs) Update(folder string, key string, modSeq uint64, needsWriteBack bool) (uint64, error) {
+
+	f := s.folder(folder)
+
+	prevHighest := f.Highest
+	prevModSeq, existed := f.Mails[key]
+
+	modSeq = f.next(modSeq)
+	f.Mails[key] = modSeq
+
+	if !needsWriteBack {
+		return modSeq, nil
+	}
+
+	// Instructed to write changes back to file.
+	err := s.WriteModSeqsToFile()
+	if err != nil {
+
+		// Error during write-back to stable storage,
+		// revert just recorded change.
+		f.Highest = prevHighest
+		if existed {
+			f.Mails[key] = prevModSeq
+		} else {
+			delete(f.Mails, key)
+		}
+
+		return 0, fmt.Errorf("error during writing CRDT file back: %v", err)
+	}
+
+	return modSeq, nil
+}
+
+// Expunge records the removal of the message identified
+// by key from folder in the same way Update records
+// changes. The MODSEQ assigned by this replica is returned.
+func (s *ModSeqs) Expunge(folder string, key string, modSeq uint64, needsWriteBack bool) (uint64, error) {
+
+	f := s.folder(folder)
+
+	prevHighest := f.Highest
+	prevModSeq, existed := f.Mails[key]
+
+	modSeq = f.next(modSeq)
+	delete(f.Mails, key)
+	f.Expunged[key] = modSeq
+
+	if !needsWriteBack {
+		return modSeq, nil
+	}
+
+	// Instructed to write changes back to file.
+	err := s.WriteModSeqsToFile()
+	if err != nil {
+
+		// Error during write-back to stable storage,
+		// revert just recorded removal.
+		f.Highest = prevHighest
+		delete(f.Expunged, key)
+		if existed {
+			f.Mails[key] = prevModSeq
+		}
+
+		return 0, fmt.Errorf("error during writing CRDT file back: %v", err)
+	}
+
+	return modSeq, nil
+}
+
+// ModSeq returns the MODSEQ of the message identified
+// by key in folder. Messages that were never changed
+// since tracking started have a MODSEQ of one.
+func (s *ModSeqs) ModSeq(folder string, key string) uint64 {
+
+	if f, found := s.Folders[folder]; found {
+
+		if modSeq, found := f.Mails[key]; found {
+			return modSeq
+		}
+	}
+
+	return 1
+}
+
+// Highest returns the HIGHESTMODSEQ value of a folder,
+// which is at least one.
+func (s *ModSeqs) Highest(folder string) uint64 {
+
+	if f, found := s.Folders[folder]; found && (f.Highest > 0) {
+		return f.Highest
+	}
+
+	return 1
+}
+
+// ExpungedSince returns the keys of all messages
+// expunged from folder with a MODSEQ greater than
+// the supplied one.
+func (s *ModSeqs) ExpungedSince(folder string, modSeq uint64) []string {
+
+	keys := make([]string, 0)
+
+	if f, found := s.Folders[folder]; found {
+
+		for key, expModSeq := range f.Expunged {
+
+			if expModSeq > modSeq {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	return keys
+}
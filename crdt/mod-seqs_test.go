@@ -0,0 +1,53 @@
+package crdt
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Functions
+
+// TestModSeqsUpdate executes a white-box unit
+// test on implemented Update() and Expunge().
+func TestModSeqsUpdate(t *testing.T) {
+
+	// Delete temporary test file on function exit.
+	defer os.Remove("test-modseqs.log")
+
+	s, err := InitModSeqsWithFile("test-modseqs.log")
+	assert.Nilf(t, err, "failed to initialize ModSeqs: %v", err)
+
+	assert.Equalf(t, uint64(1), s.Highest("INBOX"), "expected HIGHESTMODSEQ of unknown folder to be 1")
+	assert.Equalf(t, uint64(1), s.ModSeq("INBOX", "a"), "expected MODSEQ of unknown mail to be 1")
+
+	modSeq, err := s.Update("INBOX", "a", 0, true)
+	assert.Nilf(t, err, "expected Update() not to fail but got: %v", err)
+	assert.Equalf(t, uint64(1), modSeq, "expected first change to receive MODSEQ 1 but got %d", modSeq)
+
+	modSeq, _ = s.Update("INBOX", "b", 0, true)
+	assert.Equalf(t, uint64(2), modSeq, "expected second change to receive MODSEQ 2 but got %d", modSeq)
+
+	// Values received from other nodes are kept if
+	// greater, otherwise the next local one is taken.
+	modSeq, _ = s.Update("INBOX", "a", 10, true)
+	assert.Equalf(t, uint64(10), modSeq, "expected received MODSEQ 10 but got %d", modSeq)
+
+	modSeq, _ = s.Update("INBOX", "b", 4, true)
+	assert.Equalf(t, uint64(11), modSeq, "expected MODSEQ 11 but got %d", modSeq)
+
+	modSeq, err = s.Expunge("INBOX", "a", 0, true)
+	assert.Nilf(t, err, "expected Expunge() not to fail but got: %v", err)
+	assert.Equalf(t, uint64(12), modSeq, "expected expunge to receive MODSEQ 12 but got %d", modSeq)
+
+	assert.Equalf(t, uint64(12), s.Highest("INBOX"), "unexpected HIGHESTMODSEQ")
+	assert.Equalf(t, uint64(1), s.Highest("Sent"), "expected other folders to be unaffected")
+	assert.Equalf(t, []string{"a"}, s.ExpungedSince("INBOX", 11), "unexpected expunged mails")
+	assert.Equalf(t, []string{}, s.ExpungedSince("INBOX", 12), "expected no mails expunged after 12")
+
+	// State survives a restart.
+	r, err := InitModSeqsFromFile("test-modseqs.log")
+	assert.Nilf(t, err, "failed to read ModSeqs from file: %v", err)
+	assert.Equalf(t, s.Folders, r.Folders, "expected ModSeqs read from file to equal written ones")
+}
@@ -27,6 +27,13 @@ type ORSet struct {
 // downstream replicas.
 type sendFunc func(...string)
 
+// renameFunc is used as a parameter to Rename. It
+// returns the tag that replaces supplied tag under
+// the new element and whether the tag is to be moved
+// at all. An empty replacement tag is substituted by
+// a new unique UUID tag.
+type renameFunc func(string) (string, bool)
+
 // Functions
 
 // InitORSetWithFile takes in a file name and initializes
@@ -405,3 +412,107 @@ func (s *ORSet) RemovePair(e string, t string, send sendFunc) error {
 
 	return nil
 }
+
+// RenameEffect is the effect part of a rename operation.
+// It is executed by all replicas of the data set including
+// the source node. It removes supplied set of observed tags
+// and inserts the tag-value pairs of supplied add set in
+// one step, so that both changes reach stable storage
+// together. As removed and added tags differ, concurrent
+// operations on the old element are not affected and
+// the usual add-wins semantics of the ORSet apply.
+func (s *ORSet) RenameEffect(rSet map[string]string, aSet map[string]string, needsWriteBack bool) error {
+
+	// Remove observed tags first, then add the new
+	// pairs so that tags present in both survive.
+	for rTag := range rSet {
+		delete(s.Elements, rTag)
+	}
+
+	for aTag, value := range aSet {
+		s.Elements[aTag] = value
+	}
+
+	if !needsWriteBack {
+		return nil
+	}
+
+	// Write changes back to file.
+	err := s.WriteORSetToFile()
+	if err != nil {
+
+		// Error during write-back to stable
+		// storage, revert just made changes.
+		for aTag := range aSet {
+			delete(s.Elements, aTag)
+		}
+
+		for rTag, value := range rSet {
+			s.Elements[rTag] = value
+		}
+
+		return fmt.Errorf("error during write-back of CRDT file: %v", err)
+	}
+
+	return nil
+}
+
+// Rename is a helper function only to be executed by the
+// source node of a rename operation. It replaces all
+// observed tags of element e that rename function selects
+// by the returned tags under element newE and adds one
+// new UUID tag for newE, so that newE is present even if
+// no tag of e was moved. Afterwards, the new UUID tag
+// followed by all pairs of old and new tags is sent to
+// the other replicas.
+func (s *ORSet) Rename(e string, newE string, rename renameFunc, send sendFunc) error {
+
+	// Check precondition: is element present in set?
+	if s.Lookup(e) != true {
+		return fmt.Errorf("element to be renamed not found in set")
+	}
+
+	rmElements := make(map[string]string)
+	addElements := make(map[string]string)
+
+	// Tag declaring presence of the new element.
+	newTag := uuid.NewV4().String()
+	addElements[newTag] = newE
+
+	// Initialize list of arguments to send out.
+	args := make([]string, 1, 5)
+	args[0] = newTag
+
+	for tag, value := range s.Elements {
+
+		if e != value {
+			continue
+		}
+
+		renamedTag, move := rename(tag)
+		if !move {
+			continue
+		}
+
+		if renamedTag == "" {
+			renamedTag = uuid.NewV4().String()
+		}
+
+		rmElements[tag] = e
+		addElements[renamedTag] = newE
+
+		args = append(args, tag, renamedTag)
+	}
+
+	// Execute the effect part of the update rename.
+	// Also, write changes back to stable storage.
+	err := s.RenameEffect(rmElements, addElements, true)
+	if err != nil {
+		return err
+	}
+
+	// Send arguments to other replicas.
+	send(args...)
+
+	return nil
+}
@@ -694,3 +694,71 @@ func TestRemove(t *testing.T) {
 
 	assert.Equalf(t, 4, len(msg2), "expected msg2 to contain exactly 4 elements but found %d", len(msg2))
 }
+
+// TestRename executes a white-box unit test
+// on implemented Rename() function.
+func TestRename(t *testing.T) {
+
+	// Use this variable to compare sent values.
+	var msg []string
+
+	// Delete temporary test file on function exit.
+	defer os.Remove("test-crdt.log")
+
+	// Create new ORSet with associated file.
+	s, err := InitORSetWithFile("test-crdt.log")
+	assert.Nilf(t, err, "expected InitORSetWithFile() not to fail but got: %v", err)
+
+	// Attempt to rename non-existing value.
+	err = s.Rename(v1, v5, func(tag string) (string, bool) { return "", true }, func(args ...string) {})
+	assert.Equal(t, "element to be renamed not found in set", err.Error(), "expected Rename() to return error 'element to be renamed not found in set' but received '%v'", err)
+
+	s.AddEffect(v2, k1, true)
+	s.AddEffect(v2, k2, true)
+	s.AddEffect(v2, k3, true)
+	s.AddEffect(v4, k4, true)
+
+	// Move k1 under a new tag, let k2 receive
+	// a new UUID tag, and keep k3 in place.
+	err = s.Rename(v2, v5, func(tag string) (string, bool) {
+
+		switch tag {
+		case k1:
+			return k6, true
+		case k2:
+			return "", true
+		}
+
+		return "", false
+	}, func(args ...string) { msg = args })
+	assert.Nilf(t, err, "expected Rename() to return nil error but received: %v", err)
+
+	assert.Equalf(t, 5, len(s.Elements), "expected 5 elements in set but found %d", len(s.Elements))
+	assert.Equalf(t, v5, s.Elements[k6], "expected tag '%s' to map to '%v' but found '%v'", k6, v5, s.Elements[k6])
+	assert.Equalf(t, v2, s.Elements[k3], "expected tag '%s' to map to '%v' but found '%v'", k3, v2, s.Elements[k3])
+	assert.Equalf(t, v4, s.Elements[k4], "expected tag '%s' to map to '%v' but found '%v'", k4, v4, s.Elements[k4])
+
+	_, found := s.Elements[k1]
+	assert.Equalf(t, false, found, "expected tag '%s' to be removed from set", k1)
+
+	// New presence tag and two pairs of old and new tags.
+	assert.Equalf(t, 5, len(msg), "expected msg to contain exactly 5 elements but found %d", len(msg))
+	assert.Equalf(t, 36, len(msg[0]), "expected new tag '%s' to be of length 36 but was %d", msg[0], len(msg[0]))
+
+	// A replica applying the same rename
+	// arrives at the identical set.
+	r := &ORSet{
+		Elements: map[string]string{k1: v2, k2: v2, k3: v2, k4: v4},
+	}
+
+	rSet := make(map[string]string)
+	aSet := map[string]string{msg[0]: v5}
+	for i := 1; i < len(msg); i += 2 {
+		rSet[msg[i]] = v2
+		aSet[msg[(i+1)]] = v5
+	}
+
+	err = r.RenameEffect(rSet, aSet, false)
+	assert.Nilf(t, err, "expected RenameEffect() to return nil error but received: %v", err)
+	assert.Equalf(t, s.Elements, r.Elements, "expected replicas to contain identical elements")
+}
@@ -0,0 +1,397 @@
+package crdt
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"encoding/base64"
+	"io/ioutil"
+)
+
+// Structs
+
+// UIDSet assigns IMAP unique identifiers (UIDs) to the
+// messages of all mailbox folders of one user in a way
+// that converges across replicas. Per folder, it keeps
+// a grow-only set of (original UID, message key) entries
+// and a max-register holding the UIDVALIDITY base.
+//
+// A source node assigns a new message the original UID
+// following the highest one it knows of in that folder.
+// The effective UID of a message is its rank in the
+// folder's entries ordered by original UID and key. As
+// long as no two replicas concurrently assign the same
+// original UID, rank and original UID are identical.
+// Each such collision shifts the ranks of entries sorting
+// after it, which is why the UIDVALIDITY of a folder is
+// defined as its base plus the number of collisions.
+// Both values are pure functions of the converging set,
+// thus all replicas agree on them eventually.
+type UIDSet struct {
+	File    *os.File
+	Folders map[string]*UIDFolder
+}
+
+// UIDFolder contains the UID state of one folder.
+// Entries are kept sorted, UIDs maps a message key
+// to its current effective UID.
+type UIDFolder struct {
+	Base       uint32
+	Collisions uint32
+	Entries    []UIDEntry
+	UIDs       map[string]uint32
+}
+
+// UIDEntry is one element of a folder's UID set.
+type UIDEntry struct {
+	OrigUID uint32
+	Key     string
+}
+
+// uidSendFunc is used as a parameter to below defined
+// functions that broadcast the assigned original UID of
+// a message to downstream replicas.
+type uidSendFunc func(uint32)
+
+// Functions
+
+// InitUIDSetWithFile takes in a file name and initializes
+// a new UIDSet with opened file handler to that name as
+// designated log file.
+func InitUIDSetWithFile(fileName string) (*UIDSet, error) {
+
+	// Attempt to create a new CRDT file.
+	f, err := os.Create(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("opening CRDT file '%s' failed with: %v", fileName, err)
+	}
+
+	// Change permissions.
+	err = f.Chmod(0600)
+	if err != nil {
+		return nil, fmt.Errorf("changing permissions of CRDT file '%s' failed with: %v", fileName, err)
+	}
+
+	// Init an empty UIDSet.
+	s := &UIDSet{
+		File:    f,
+		Folders: make(map[string]*UIDFolder),
+	}
+
+	// Write newly created CRDT file to stable storage.
+	err = s.WriteUIDSetToFile()
+	if err != nil {
+		return nil, fmt.Errorf("error during CRDT file write-back: %v", err)
+	}
+
+	return s, nil
+}
+
+// InitUIDSetFromFile parses a UIDSet found in the
+// supplied file and returns it, initialized with
+// elements saved in file.
+func InitUIDSetFromFile(fileName string) (*UIDSet, error) {
+
+	// Attempt to open CRDT file and assign to set afterwards.
+	f, err := os.OpenFile(fileName, os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("opening CRDT file '%s' failed with: %v", fileName, err)
+	}
+
+	// Init an empty UIDSet.
+	s := &UIDSet{
+		File:    f,
+		Folders: make(map[string]*UIDFolder),
+	}
+
+	// Parse contained CRDT state from file.
+	contentsRaw, err := ioutil.ReadAll(s.File)
+	if err != nil {
+		return nil, fmt.Errorf("reading all contents from CRDT file '%s' failed with: %v", fileName, err)
+	}
+	contents := strings.TrimSpace(string(contentsRaw))
+
+	// Account for an empty CRDT set which is valid.
+	if contents == "" {
+		return s, nil
+	}
+
+	// Split content at each ';' (semicolon).
+	parts := strings.Split(contents, ";")
+
+	// Elements are always stored as triples.
+	if (len(parts) % 3) != 0 {
+		return nil, fmt.Errorf("number of elements in CRDT file '%s' not a multiple of three", fileName)
+	}
+
+	// Range over all folder-number-key triples. An empty
+	// key denotes the UIDVALIDITY base of the folder.
+	for i := 0; i < len(parts); i += 3 {
+
+		folder, err := base64.StdEncoding.DecodeString(parts[i])
+		if err != nil {
+			return nil, fmt.Errorf("decoding base64 string in CRDT file '%s' failed: %v", fileName, err)
+		}
+
+		num, err := strconv.ParseUint(parts[(i+1)], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("parsing number in CRDT file '%s' failed: %v", fileName, err)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(parts[(i + 2)])
+		if err != nil {
+			return nil, fmt.Errorf("decoding base64 string in CRDT file '%s' failed: %v", fileName, err)
+		}
+
+		if len(key) == 0 {
+			s.SetBaseEffect(string(folder), uint32(num), false)
+		} else {
+			s.AddEffect(string(folder), uint32(num), string(key), false)
+		}
+	}
+
+	return s, nil
+}
+
+// WriteUIDSetToFile saves an active UIDSet onto
+// stable storage at location from initialization.
+func (s *UIDSet) WriteUIDSetToFile() error {
+
+	elements := make([]string, 0, (3 * len(s.Folders)))
+
+	for folderRaw, folder := range s.Folders {
+
+		name := base64.StdEncoding.EncodeToString([]byte(folderRaw))
+
+		elements = append(elements, name, strconv.FormatUint(uint64(folder.Base), 10), "")
+
+		for _, entry := range folder.Entries {
+			elements = append(elements, name, strconv.FormatUint(uint64(entry.OrigUID), 10), base64.StdEncoding.EncodeToString([]byte(entry.Key)))
+		}
+	}
+
+	marshalled := strings.Join(elements, ";")
+
+	// Reset position in file to beginning.
+	_, err := s.File.Seek(0, os.SEEK_SET)
+	if err != nil {
+		return fmt.Errorf("error while setting head back to beginning in CRDT file '%s': %v", s.File.Name(), err)
+	}
+
+	// Write marshalled set to file.
+	newNumOfBytes, err := s.File.WriteString(marshalled)
+	if err != nil {
+		return fmt.Errorf("failed to write UIDSet contents to file '%s': %v", s.File.Name(), err)
+	}
+
+	// Adjust file size to just written length of string.
+	err = s.File.Truncate(int64(newNumOfBytes))
+	if err != nil {
+		return fmt.Errorf("error while truncating CRDT file '%s' to new size: %v", s.File.Name(), err)
+	}
+
+	// Save to stable storage.
+	err = s.File.Sync()
+	if err != nil {
+		return fmt.Errorf("could not synchronise CRDT file '%s' contents to stable storage: %v", s.File.Name(), err)
+	}
+
+	return nil
+}
+
+// folder returns the state of the named folder,
+// initializing it if not yet present.
+func (s *UIDSet) folder(name string) *UIDFolder {
+
+	f, found := s.Folders[name]
+	if !found {
+
+		f = &UIDFolder{
+			Entries: make([]UIDEntry, 0, 6),
+			UIDs:    make(map[string]uint32),
+		}
+		s.Folders[name] = f
+	}
+
+	return f
+}
+
+// SetBaseEffect merges supplied UIDVALIDITY base into
+// the max-register of the folder. It is executed by
+// all replicas including the source node.
+func (s *UIDSet) SetBaseEffect(folder string, base uint32, needsWriteBack bool) error {
+
+	f := s.folder(folder)
+
+	if base <= f.Base {
+		return nil
+	}
+
+	oldBase := f.Base
+	f.Base = base
+
+	if !needsWriteBack {
+		return nil
+	}
+
+	// Instructed to write changes back to file.
+	err := s.WriteUIDSetToFile()
+	if err != nil {
+
+		// Error during write-back to stable
+		// storage, revert just made change.
+		f.Base = oldBase
+
+		return fmt.Errorf("error during writing CRDT file back: %v", err)
+	}
+
+	return nil
+}
+
+// AddEffect is the effect part of an add operation. It is
+// executed by all replicas including the source node and
+// inserts the entry at its position in the folder's order.
+// Adding an already present entry has no effect.
+func (s *UIDSet) AddEffect(folder string, origUID uint32, key string, needsWriteBack bool) error {
+
+	f := s.folder(folder)
+
+	// Find position of new entry.
+	pos := sort.Search(len(f.Entries), func(i int) bool {
+		return (f.Entries[i].OrigUID > origUID) ||
+			((f.Entries[i].OrigUID == origUID) && (f.Entries[i].Key >= key))
+	})
+
+	if (pos < len(f.Entries)) && (f.Entries[pos].OrigUID == origUID) && (f.Entries[pos].Key == key) {
+		return nil
+	}
+
+	// Insert entry and check if it collides
+	// with a neighbour's original UID.
+	f.Entries = append(f.Entries, UIDEntry{})
+	copy(f.Entries[(pos+1):], f.Entries[pos:])
+	f.Entries[pos] = UIDEntry{
+		OrigUID: origUID,
+		Key:     key,
+	}
+
+	if ((pos > 0) && (f.Entries[(pos-1)].OrigUID == origUID)) ||
+		((pos < (len(f.Entries) - 1)) && (f.Entries[(pos+1)].OrigUID == origUID)) {
+		f.Collisions++
+	}
+
+	// Effective UIDs of this and all following
+	// entries are their rank in the folder.
+	for i := pos; i < len(f.Entries); i++ {
+		f.UIDs[f.Entries[i].Key] = uint32(i + 1)
+	}
+
+	if !needsWriteBack {
+		return nil
+	}
+
+	// Instructed to write changes back to file.
+	err := s.WriteUIDSetToFile()
+	if err != nil {
+
+		// Error during write-back to stable storage,
+		// remove just added entry again.
+		s.removeEntry(folder, pos)
+
+		return fmt.Errorf("error during writing CRDT file back: %v", err)
+	}
+
+	return nil
+}
+
+// removeEntry reverts the insertion of the
+// entry at supplied position in a folder.
+func (s *UIDSet) removeEntry(folder string, pos int) {
+
+	f := s.folder(folder)
+	origUID := f.Entries[pos].OrigUID
+
+	delete(f.UIDs, f.Entries[pos].Key)
+	f.Entries = append(f.Entries[:pos], f.Entries[(pos+1):]...)
+
+	if ((pos > 0) && (f.Entries[(pos-1)].OrigUID == origUID)) ||
+		((pos < len(f.Entries)) && (f.Entries[pos].OrigUID == origUID)) {
+		f.Collisions--
+	}
+
+	for i := pos; i < len(f.Entries); i++ {
+		f.UIDs[f.Entries[i].Key] = uint32(i + 1)
+	}
+}
+
+// Add is a helper function only to be executed at the
+// source node of an update. It assigns the next original
+// UID of the folder to the message identified by key,
+// executes the effect part, and passes the assigned
+// original UID on to the send function.
+func (s *UIDSet) Add(folder string, key string, send uidSendFunc) error {
+
+	origUID := uint32(1)
+
+	f := s.folder(folder)
+	if len(f.Entries) > 0 {
+		origUID = f.Entries[(len(f.Entries)-1)].OrigUID + 1
+	}
+
+	// Apply effect part of update add.
+	// Write changes back to stable storage.
+	err := s.AddEffect(folder, origUID, key, true)
+	if err != nil {
+		return err
+	}
+
+	// Send to other involved nodes.
+	send(origUID)
+
+	return nil
+}
+
+// UID returns the effective UID of the message identified
+// by key in folder and whether it was found.
+func (s *UIDSet) UID(folder string, key string) (uint32, bool) {
+
+	f, found := s.Folders[folder]
+	if !found {
+		return 0, false
+	}
+
+	uid, found := f.UIDs[key]
+
+	return uid, found
+}
+
+// Validity returns the UIDVALIDITY value of a folder.
+// Folders without a recorded base start at one.
+func (s *UIDSet) Validity(folder string) uint32 {
+
+	f, found := s.Folders[folder]
+	if !found {
+		return 1
+	}
+
+	if f.Base == 0 {
+		return 1 + f.Collisions
+	}
+
+	return f.Base + f.Collisions
+}
+
+// Next returns the UIDNEXT value of a folder, the
+// UID the next message added to it will receive.
+func (s *UIDSet) Next(folder string) uint32 {
+
+	f, found := s.Folders[folder]
+	if !found {
+		return 1
+	}
+
+	return uint32(len(f.Entries) + 1)
+}
@@ -0,0 +1,99 @@
+package crdt
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Functions
+
+// TestUIDSetAdd executes a white-box unit
+// test on implemented Add() function.
+func TestUIDSetAdd(t *testing.T) {
+
+	// Delete temporary test file on function exit.
+	defer os.Remove("test-uids.log")
+
+	s, err := InitUIDSetWithFile("test-uids.log")
+	assert.Nilf(t, err, "failed to initialize UIDSet: %v", err)
+
+	assert.Equalf(t, uint32(1), s.Next("INBOX"), "expected UIDNEXT of empty folder to be 1 but got %d", s.Next("INBOX"))
+
+	sent := uint32(0)
+	err = s.Add("INBOX", "a", func(origUID uint32) {
+		sent = origUID
+	})
+	assert.Nilf(t, err, "expected Add() not to fail but got: %v", err)
+	assert.Equalf(t, uint32(1), sent, "expected first original UID to be 1 but got %d", sent)
+
+	err = s.Add("INBOX", "b", func(origUID uint32) {
+		sent = origUID
+	})
+	assert.Nilf(t, err, "expected Add() not to fail but got: %v", err)
+	assert.Equalf(t, uint32(2), sent, "expected second original UID to be 2 but got %d", sent)
+
+	uid, found := s.UID("INBOX", "b")
+	assert.Equalf(t, true, found, "expected key 'b' to have a UID")
+	assert.Equalf(t, uint32(2), uid, "expected UID of 'b' to be 2 but got %d", uid)
+	assert.Equalf(t, uint32(3), s.Next("INBOX"), "expected UIDNEXT to be 3 but got %d", s.Next("INBOX"))
+	assert.Equalf(t, uint32(1), s.Validity("INBOX"), "expected UIDVALIDITY to be 1 but got %d", s.Validity("INBOX"))
+
+	// Other folders are not affected.
+	assert.Equalf(t, uint32(1), s.Next("Sent"), "expected UIDNEXT of other folder to be 1 but got %d", s.Next("Sent"))
+}
+
+// TestUIDSetConvergence executes a white-box unit test
+// checking that replicas receiving concurrent additions
+// in different orders agree on UIDs and UIDVALIDITY.
+func TestUIDSetConvergence(t *testing.T) {
+
+	// Delete temporary test files on function exit.
+	defer os.Remove("test-uids-1.log")
+	defer os.Remove("test-uids-2.log")
+
+	s1, err := InitUIDSetWithFile("test-uids-1.log")
+	assert.Nilf(t, err, "failed to initialize UIDSet: %v", err)
+
+	s2, err := InitUIDSetWithFile("test-uids-2.log")
+	assert.Nilf(t, err, "failed to initialize UIDSet: %v", err)
+
+	s1.SetBaseEffect("Work", 1000, true)
+	s2.SetBaseEffect("Work", 1000, true)
+	s1.AddEffect("Work", 1, "a", true)
+	s2.AddEffect("Work", 1, "a", true)
+
+	// Both replicas concurrently assign original UID 2.
+	var origUID1, origUID2 uint32
+	s1.Add("Work", "x", func(origUID uint32) { origUID1 = origUID })
+	s2.Add("Work", "y", func(origUID uint32) { origUID2 = origUID })
+
+	assert.Equalf(t, uint32(1000), s1.Validity("Work"), "expected UIDVALIDITY before collision to be 1000 but got %d", s1.Validity("Work"))
+
+	// Exchange updates.
+	s1.AddEffect("Work", origUID2, "y", true)
+	s2.AddEffect("Work", origUID1, "x", true)
+
+	// Applying an update twice has no effect.
+	s2.AddEffect("Work", origUID1, "x", true)
+
+	for _, key := range []string{"a", "x", "y"} {
+
+		uid1, _ := s1.UID("Work", key)
+		uid2, _ := s2.UID("Work", key)
+		assert.Equalf(t, uid1, uid2, "expected replicas to agree on UID of '%s' but got %d and %d", key, uid1, uid2)
+	}
+
+	assert.Equalf(t, uint32(1001), s1.Validity("Work"), "expected UIDVALIDITY after collision to be 1001 but got %d", s1.Validity("Work"))
+	assert.Equalf(t, s1.Validity("Work"), s2.Validity("Work"), "expected replicas to agree on UIDVALIDITY")
+	assert.Equalf(t, uint32(4), s2.Next("Work"), "expected UIDNEXT to be 4 but got %d", s2.Next("Work"))
+
+	// State survives a restart.
+	s3, err := InitUIDSetFromFile("test-uids-1.log")
+	assert.Nilf(t, err, "expected InitUIDSetFromFile() not to fail but got: %v", err)
+
+	uid, _ := s3.UID("Work", "y")
+	assert.Equalf(t, uint32(3), uid, "expected UID of 'y' after reload to be 3 but got %d", uid)
+	assert.Equalf(t, uint32(1001), s3.Validity("Work"), "expected UIDVALIDITY after reload to be 1001 but got %d", s3.Validity("Work"))
+}
@@ -49,6 +49,27 @@ func NewPublicTLSConfig(certPath string, keyPath string) (*tls.Config, error) {
 	return config, nil
 }
 
+// AddClientCAs makes a public TLS config request client
+// certificates and verify presented ones against the
+// authorities in PEM format found at caPath. Clients
+// are still allowed to connect without certificate.
+func AddClientCAs(config *tls.Config, caPath string) error {
+
+	caCerts, err := ioutil.ReadFile(caPath)
+	if err != nil {
+		return fmt.Errorf("reading client CA certificates into memory failed with: %v", err)
+	}
+
+	config.ClientCAs = x509.NewCertPool()
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+
+	if ok := config.ClientCAs.AppendCertsFromPEM(caCerts); !ok {
+		return fmt.Errorf("failed to append certificates to client CA pool")
+	}
+
+	return nil
+}
+
 // NewInternalTLSConfig returns a TLS config that is
 // already configured completely for use in nodes to
 // communicate internally. It defines very strict defaults
@@ -23,8 +23,8 @@ var capabilityTests = []struct {
 	in  string
 	out string
 }{
-	{"a CAPABILITY", "* CAPABILITY IMAP4rev1 AUTH=PLAIN\r\na OK CAPABILITY completed"},
-	{"b capability", "* CAPABILITY IMAP4rev1 AUTH=PLAIN\r\nb OK CAPABILITY completed"},
+	{"a CAPABILITY", "* CAPABILITY IMAP4rev1 AUTH=LOGIN AUTH=PLAIN AUTH=SCRAM-SHA-256 AUTH=SCRAM-SHA-256-PLUS ID LITERAL+ SASL-IR\r\na OK CAPABILITY completed"},
+	{"b capability", "* CAPABILITY IMAP4rev1 AUTH=LOGIN AUTH=PLAIN AUTH=SCRAM-SHA-256 AUTH=SCRAM-SHA-256-PLUS ID LITERAL+ SASL-IR\r\nb OK CAPABILITY completed"},
 	{"c CAPABILITY   ", "c BAD Command CAPABILITY was sent with extra parameters"},
 	{"CAPABILITY", "* BAD Received invalid IMAP command"},
 }
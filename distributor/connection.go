@@ -3,8 +3,10 @@ package distributor
 import (
 	"bufio"
 	"fmt"
+	"net"
 	"strings"
 
+	"compress/flate"
 	"crypto/tls"
 
 	"github.com/go-kit/kit/log"
@@ -19,15 +21,21 @@ import (
 // Connection carries all information specific
 // to one observed connection on its way through
 // a pluto node that only authenticates and proxies
-// IMAP connections.
+// IMAP connections. Once the client negotiated
+// COMPRESS=DEFLATE, IncReader reads from and IncWriter
+// writes to flate streams wrapping IncConn. ClientName
+// and ClientVersion are what the client reported via ID.
 type Connection struct {
 	gRPCConn      *grpc.ClientConn
 	gRPCClient    imap.NodeClient
-	IncConn       *tls.Conn
+	IncConn       net.Conn
 	IncReader     *bufio.Reader
+	IncWriter     *flate.Writer
 	IsAuthorized  bool
 	ClientID      string
 	ClientAddr    string
+	ClientName    string
+	ClientVersion string
 	UserName      string
 	PrimaryNode   string
 	PrimaryAddr   string
@@ -39,6 +47,33 @@ type Connection struct {
 
 // Functions
 
+// IsTLS returns true if the connection to
+// the client is protected by TLS.
+func (c *Connection) IsTLS() bool {
+
+	_, ok := c.IncConn.(*tls.Conn)
+
+	return ok
+}
+
+// StartTLS performs the TLS handshake on a connection
+// to the client that was cleartext so far. Any data
+// the client sent before the handshake is discarded.
+func (c *Connection) StartTLS(config *tls.Config) error {
+
+	tlsConn := tls.Server(c.IncConn, config)
+
+	err := tlsConn.Handshake()
+	if err != nil {
+		return err
+	}
+
+	c.IncConn = tlsConn
+	c.IncReader = bufio.NewReader(tlsConn)
+
+	return nil
+}
+
 // Close terminates external and internal connections
 // used by this struct and renders it unusable for
 // further communication.
@@ -74,6 +109,18 @@ func (c *Connection) Close() error {
 // the calling function.
 func (c *Connection) Send(text string) error {
 
+	if c.IncWriter != nil {
+
+		_, err := fmt.Fprintf(c.IncWriter, "%s\r\n", text)
+		if err != nil {
+			return err
+		}
+
+		// Flush compressed data so that the client
+		// receives the complete response right away.
+		return c.IncWriter.Flush()
+	}
+
 	_, err := fmt.Fprintf(c.IncConn, "%s\r\n", text)
 	if err != nil {
 		return err
@@ -82,6 +129,30 @@ func (c *Connection) Send(text string) error {
 	return nil
 }
 
+// Compress wraps the connection to the client in
+// DEFLATE streams as defined in RFC 4978. Everything
+// sent by the client after its COMPRESS command is
+// compressed, including data already buffered.
+func (c *Connection) Compress() error {
+
+	if c.IncWriter != nil {
+		return fmt.Errorf("compression already active")
+	}
+
+	writer, err := flate.NewWriter(c.IncConn, flate.DefaultCompression)
+	if err != nil {
+		return err
+	}
+
+	// The buffered reader implements io.ByteReader, so
+	// decompression never reads past the end of the
+	// compressed stream.
+	c.IncReader = bufio.NewReader(flate.NewReader(c.IncReader))
+	c.IncWriter = writer
+
+	return nil
+}
+
 // Receive wraps the main io.Reader function that awaits text
 // until an IMAP newline symbol and deletes the symbols after-
 // wards again. It returns the resulting string or an error.
@@ -95,6 +166,18 @@ func (c *Connection) Receive() (string, error) {
 	return strings.TrimRight(text, "\r\n"), nil
 }
 
+// ReceiveCommand reads the next complete command from
+// the client. Literals contained in the command are read
+// along with it, for synchronizing ones the client is
+// asked to continue first. Only the message literal of
+// an APPEND is left to be read by ProxyAppend.
+func (c *Connection) ReceiveCommand() (string, error) {
+
+	return imap.ReadCommand(c.IncReader, func() error {
+		return c.Send("+ Ready for additional command text")
+	})
+}
+
 // Connect to primary node or fail over to secondary node
 // in case of an error. If failover fails as well, go back
 // to primary node.
@@ -174,3 +257,9 @@ func (c *Connection) Connect(opts []grpc.DialOption, logger log.Logger, sendPrep
 
 	return nil
 }
+
+// init registers the COMPRESS=DEFLATE capability
+// provided by connections to clients.
+func init() {
+	imap.RegisterCapability(&imap.Capability{Name: "COMPRESS=DEFLATE", PostAuth: true, Commands: []string{imap.CommandCompress}})
+}
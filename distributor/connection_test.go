@@ -0,0 +1,123 @@
+package distributor
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"testing"
+
+	"compress/flate"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-pluto/pluto/imap"
+	"github.com/stretchr/testify/assert"
+)
+
+// Functions
+
+// TestCompress executes a black-box unit test on
+// COMPRESS=DEFLATE (RFC 4978) turned on for a
+// client connection by the distributor.
+func TestCompress(t *testing.T) {
+
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	c := &Connection{
+		IncConn:      server,
+		IncReader:    bufio.NewReader(server),
+		IsAuthorized: true,
+		ClientAddr:   "127.0.0.1:4321",
+	}
+
+	s := &service{
+		logger: log.NewNopLogger(),
+	}
+
+	// Serve commands the way the distributor does,
+	// answering all but COMPRESS right away.
+	received := make(chan string, 8)
+	go func() {
+
+		defer close(received)
+
+		for {
+
+			text, err := c.ReceiveCommand()
+			if err != nil {
+				return
+			}
+
+			received <- text
+
+			req, err := imap.ParseRequest(text)
+			if err != nil {
+				return
+			}
+
+			if req.Command == imap.CommandCompress {
+				s.Compress(c, req)
+				continue
+			}
+
+			c.Send(fmt.Sprintf("%s OK %s completed", req.Tag, req.Command))
+		}
+	}()
+
+	var compressed bytes.Buffer
+	deflater, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+	assert.Nilf(t, err, "expected flate writer but got: %v", err)
+
+	deflate := func(text string) []byte {
+
+		deflater.Write([]byte(text))
+		deflater.Flush()
+
+		data := append([]byte(nil), compressed.Bytes()...)
+		compressed.Reset()
+
+		return data
+	}
+
+	reader := bufio.NewReader(client)
+
+	client.Write([]byte("a1 COMPRESS LZW\r\n"))
+	answer, _ := reader.ReadString('\n')
+	assert.Equalf(t, "a1 BAD Command COMPRESS was not sent with supported mechanism DEFLATE\r\n", answer, "expected unsupported mechanism to be refused")
+
+	// Compressed data sent right after COMPRESS is
+	// read along with it but decompressed anyway.
+	client.Write(append([]byte("a2 COMPRESS DEFLATE\r\n"), deflate("a3 NOOP\r\n")...))
+	answer, _ = reader.ReadString('\n')
+	assert.Equalf(t, "a2 OK DEFLATE active\r\n", answer, "expected OK to be sent without compression")
+
+	inflater := bufio.NewReader(flate.NewReader(reader))
+
+	answer, _ = inflater.ReadString('\n')
+	assert.Equalf(t, "a3 OK NOOP completed\r\n", answer, "expected compressed answer to buffered command")
+
+	client.Write(deflate("a4 COMPRESS DEFLATE\r\n"))
+	answer, _ = inflater.ReadString('\n')
+	assert.Equalf(t, "a4 NO [COMPRESSIONACTIVE] DEFLATE active via COMPRESS\r\n", answer, "expected compression not to be stacked")
+
+	// Continuation requests are flushed so that
+	// clients receive them before sending literals.
+	client.Write(deflate("a5 LOGIN {4}\r\n"))
+	answer, _ = inflater.ReadString('\n')
+	assert.Equalf(t, "+ Ready for additional command text\r\n", answer, "expected compressed continuation request")
+
+	client.Write(deflate("user pass\r\n"))
+	answer, _ = inflater.ReadString('\n')
+	assert.Equalf(t, "a5 OK LOGIN completed\r\n", answer, "expected compressed answer to command with literal")
+
+	client.Close()
+
+	texts := make([]string, 0, 5)
+	for text := range received {
+		texts = append(texts, text)
+	}
+
+	assert.Equalf(t, []string{"a1 COMPRESS LZW", "a2 COMPRESS DEFLATE", "a3 NOOP", "a4 COMPRESS DEFLATE", "a5 LOGIN {4}\r\nuser pass"}, texts, "unexpected commands received")
+}
@@ -8,10 +8,13 @@ import (
 	"strings"
 
 	"crypto/tls"
+	"encoding/base64"
+	"io/ioutil"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/go-kit/kit/metrics"
+	"github.com/go-pluto/pluto/auth"
 	"github.com/go-pluto/pluto/config"
 	"github.com/go-pluto/pluto/imap"
 	"golang.org/x/net/context"
@@ -26,23 +29,29 @@ import (
 type Metrics struct {
 	Commands    metrics.Counter
 	Connections metrics.Counter
+	Clients     metrics.Counter
 }
 
 type service struct {
-	logger        log.Logger
-	metrics       *Metrics
-	authenticator Authenticator
-	tlsConfig     *tls.Config
-	workers       map[string]config.Worker
-	storageAddr   string
-	gRPCOptions   []grpc.DialOption
+	logger          log.Logger
+	metrics         *Metrics
+	authenticator   Authenticator
+	publicTLSConfig *tls.Config
+	tlsConfig       *tls.Config
+	workers         map[string]config.Worker
+	storageAddr     string
+	gRPCOptions     []grpc.DialOption
+	disabledCaps    map[string]bool
+	authCaps        []*imap.Capability
 }
 
 // Interfaces
 
 // Authenticator defines the methods required to
 // perform an IMAP AUTH=PLAIN authentication in order
-// to reach authenticated state (also LOGIN).
+// to reach authenticated state (also LOGIN). Further
+// SASL mechanisms are verified by implementing the
+// respective interfaces of package auth.
 type Authenticator interface {
 
 	// GetWorkerForUser allows us to route an IMAP request to the
@@ -53,6 +62,10 @@ type Authenticator interface {
 	// authentication methods of type PLAIN to perform the
 	// actual part of checking supplied credentials.
 	AuthenticatePlain(username string, password string, clientAddr string) (int, string, error)
+
+	// Mechanisms returns the names of the SASL
+	// mechanisms the authenticator is able to verify.
+	Mechanisms() []string
 }
 
 // Service defines the interface a distributor node
@@ -77,10 +90,26 @@ type Service interface {
 	// as part of the distributor config.
 	Login(c *Connection, req *imap.Request) bool
 
-	// StartTLS states on IMAP STARTTLS command
-	// that current connection is already encrypted.
+	// Authenticate runs the SASL exchange of the mechanism
+	// requested via IMAP AUTHENTICATE command.
+	Authenticate(c *Connection, req *imap.Request) bool
+
+	// StartTLS upgrades a cleartext connection
+	// to TLS on IMAP STARTTLS command.
 	StartTLS(c *Connection, req *imap.Request) bool
 
+	// Noop handles the IMAP NOOP command for clients
+	// that are not yet authenticated.
+	Noop(c *Connection, req *imap.Request) bool
+
+	// Compress turns on COMPRESS=DEFLATE (RFC 4978)
+	// on the connection to an authenticated client.
+	Compress(c *Connection, req *imap.Request) bool
+
+	// ID exchanges implementation information with
+	// the client as defined in RFC 2971.
+	ID(c *Connection, req *imap.Request) bool
+
 	// ProxySelect tunnels a received SELECT request by
 	// an authorized client to the responsible worker or
 	// storage node.
@@ -115,26 +144,158 @@ type Service interface {
 	// an authorized client to the responsible worker or
 	// storage node.
 	ProxyStore(c *Connection, rawReq string) bool
+
+	// ProxyFetch tunnels a received FETCH request by
+	// an authorized client to the responsible worker or
+	// storage node.
+	ProxyFetch(c *Connection, rawReq string) bool
+
+	// ProxySearch tunnels a received SEARCH request by
+	// an authorized client to the responsible worker or
+	// storage node.
+	ProxySearch(c *Connection, rawReq string) bool
+
+	// ProxyUID tunnels a received UID request by
+	// an authorized client to the responsible worker or
+	// storage node.
+	ProxyUID(c *Connection, rawReq string) bool
+
+	// ProxyCopy tunnels a received COPY request by
+	// a client to the responsible worker or storage node.
+	ProxyCopy(c *Connection, rawReq string) bool
+
+	// ProxyMove tunnels a received MOVE request by
+	// a client to the responsible worker or storage node.
+	ProxyMove(c *Connection, rawReq string) bool
+
+	// ProxyRename tunnels a received RENAME request by
+	// a client to the responsible worker or storage node.
+	ProxyRename(c *Connection, rawReq string) bool
+
+	// ProxyExamine tunnels a received EXAMINE request by
+	// a client to the responsible worker or storage node.
+	ProxyExamine(c *Connection, rawReq string) bool
+
+	// ProxyStatus tunnels a received STATUS request by
+	// a client to the responsible worker or storage node.
+	ProxyStatus(c *Connection, rawReq string) bool
+
+	// ProxyClose tunnels a received CLOSE request by
+	// a client to the responsible worker or storage node.
+	ProxyClose(c *Connection, rawReq string) bool
+
+	// ProxyUnselect tunnels a received UNSELECT request by
+	// a client to the responsible worker or storage node.
+	ProxyUnselect(c *Connection, rawReq string) bool
+
+	// ProxyCheck tunnels a received CHECK request by
+	// a client to the responsible worker or storage node.
+	ProxyCheck(c *Connection, rawReq string) bool
+
+	// ProxyNoop tunnels a received NOOP request by
+	// a client to the responsible worker or storage node.
+	ProxyNoop(c *Connection, rawReq string) bool
+
+	// ProxyIdle tunnels a received IDLE request by a client
+	// to the responsible worker or storage node and streams
+	// mailbox changes back until the client sends DONE.
+	ProxyIdle(c *Connection, rawReq string) bool
+
+	// ProxySubscribe tunnels a received SUBSCRIBE request by
+	// a client to the responsible worker or storage node.
+	ProxySubscribe(c *Connection, rawReq string) bool
+
+	// ProxyUnsubscribe tunnels a received UNSUBSCRIBE request by
+	// a client to the responsible worker or storage node.
+	ProxyUnsubscribe(c *Connection, rawReq string) bool
+
+	// ProxyLsub tunnels a received LSUB request by
+	// a client to the responsible worker or storage node.
+	ProxyLsub(c *Connection, rawReq string) bool
+
+	// ProxyEnable tunnels a received ENABLE request by
+	// a client to the responsible worker or storage node.
+	ProxyEnable(c *Connection, rawReq string) bool
+
+	// ProxyNamespace tunnels a received NAMESPACE request by
+	// a client to the responsible worker or storage node.
+	ProxyNamespace(c *Connection, rawReq string) bool
+
+	// ProxySort tunnels a received SORT request by
+	// an authorized client to the responsible worker or
+	// storage node.
+	ProxySort(c *Connection, rawReq string) bool
+
+	// ProxyThread tunnels a received THREAD request by
+	// an authorized client to the responsible worker or
+	// storage node.
+	ProxyThread(c *Connection, rawReq string) bool
 }
 
 // Functions
 
 // NewService takes in all required parameters for spinning
 // up a new distributor node and returns a service struct for
-// this node type wrapping all information.
-func NewService(name string, logger log.Logger, metrics *Metrics, authenticator Authenticator, tlsConfig *tls.Config, workers map[string]config.Worker, storageAddr string) Service {
+// this node type wrapping all information. publicTLSConfig
+// is used to upgrade cleartext client connections, tlsConfig
+// for connections to internal nodes.
+func NewService(name string, logger log.Logger, metrics *Metrics, authenticator Authenticator, publicTLSConfig *tls.Config, tlsConfig *tls.Config, workers map[string]config.Worker, storageAddr string, disabledCaps []string) Service {
+
+	disabled := make(map[string]bool)
+	for _, capability := range disabledCaps {
+		disabled[strings.ToUpper(capability)] = true
+	}
+
+	// Advertise all registered SASL mechanisms the
+	// authenticator is able to verify. EXTERNAL needs
+	// client certificates to be requested. These depend
+	// on this service and are thus kept apart from the
+	// globally registered capabilities.
+	authCaps := make([]*imap.Capability, 0, 4)
+	for _, name := range authenticator.Mechanisms() {
+
+		mechanism, found := auth.LookupMechanism(name)
+		if !found {
+			continue
+		}
+
+		if (mechanism.Name == auth.MechanismExternal) && ((publicTLSConfig == nil) || (publicTLSConfig.ClientCAs == nil)) {
+			continue
+		}
+
+		security := imap.AnySecurity
+		if mechanism.RequiresTLS {
+			security = imap.TLSOnly
+		}
+
+		authCaps = append(authCaps, &imap.Capability{Name: fmt.Sprintf("AUTH=%s", mechanism.Name), PreAuth: true, Security: security})
+	}
 
 	return &service{
-		logger:        logger,
-		metrics:       metrics,
-		authenticator: authenticator,
-		tlsConfig:     tlsConfig,
-		workers:       workers,
-		storageAddr:   storageAddr,
-		gRPCOptions:   imap.DistributorOptions(tlsConfig),
+		logger:          logger,
+		metrics:         metrics,
+		authenticator:   authenticator,
+		publicTLSConfig: publicTLSConfig,
+		tlsConfig:       tlsConfig,
+		workers:         workers,
+		storageAddr:     storageAddr,
+		gRPCOptions:     imap.DistributorOptions(tlsConfig),
+		disabledCaps:    disabled,
+		authCaps:        authCaps,
 	}
 }
 
+// capabilities returns the space-separated list of
+// capabilities to advertise to the client in the
+// current state of its connection.
+func (s *service) capabilities(c *Connection) string {
+
+	return strings.Join(imap.Capabilities(imap.CapabilityState{
+		Authenticated: c.IsAuthorized,
+		TLS:           c.IsTLS(),
+	}, s.disabledCaps, s.authCaps...), " ")
+}
+
 // Run loops over incoming requests at distributor and
 // dispatches each one to a goroutine taking care of
 // the commands supplied.
@@ -161,22 +322,17 @@ func (s *service) Run(listener net.Listener, greeting string) error {
 // or storage node (failover).
 func (s *service) handleConnection(conn net.Conn, greeting string) {
 
-	// Assert we are talking via a TLS connection.
-	tlsConn, ok := conn.(*tls.Conn)
-	if ok != true {
-		level.Info(s.logger).Log("msg", "connection not accepted because it is no *tls.Conn")
-		return
-	}
-
 	// Create a new connection struct for incoming request.
+	// Connections without TLS need to upgrade via STARTTLS
+	// before they may log in.
 	c := &Connection{
-		IncConn:    tlsConn,
-		IncReader:  bufio.NewReader(tlsConn),
-		ClientAddr: tlsConn.RemoteAddr().String(),
+		IncConn:    conn,
+		IncReader:  bufio.NewReader(conn),
+		ClientAddr: conn.RemoteAddr().String(),
 	}
 
 	// Send initial server greeting.
-	err := c.Send(fmt.Sprintf("* OK [CAPABILITY IMAP4rev1 AUTH=PLAIN] %s", greeting))
+	err := c.Send(fmt.Sprintf("* OK [CAPABILITY %s] %s", s.capabilities(c), greeting))
 	if err != nil {
 
 		level.Error(s.logger).Log(
@@ -205,7 +361,32 @@ func (s *service) handleConnection(conn net.Conn, greeting string) {
 	for recvUntil != "LOGOUT" {
 
 		// Receive next incoming client command.
-		rawReq, err := c.Receive()
+		rawReq, err := c.ReceiveCommand()
+		if litErr, ok := err.(*imap.LiteralError); ok {
+
+			// Client announced a literal too big to accept
+			// and waits for our answer. Refuse the command.
+			err := c.Send(litErr.Error())
+			if err != nil {
+
+				level.Error(s.logger).Log(
+					"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+					"err", err,
+				)
+
+				err = c.Close()
+				if err != nil {
+					level.Error(s.logger).Log(
+						"msg", "failed to close Connection struct",
+						"err", err,
+					)
+				}
+				return
+			}
+
+			continue
+		}
+
 		if err != nil {
 
 			// Check if error was a simple disconnect.
@@ -283,6 +464,20 @@ func (s *service) handleConnection(conn net.Conn, greeting string) {
 
 		switch {
 
+		case imap.CommandDisabled(req.Command, s.disabledCaps):
+
+			// Commands of capabilities disabled in
+			// the configuration are not available.
+			err := c.Send(fmt.Sprintf("%s BAD Command %s is disabled", req.Tag, req.Command))
+			if err != nil {
+				level.Error(s.logger).Log(
+					"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+					"err", err,
+				)
+			}
+
+			cmdOK = (err == nil)
+
 		case req.Command == imap.CommandCapability:
 			cmdOK = s.Capability(c, req)
 
@@ -321,6 +516,18 @@ func (s *service) handleConnection(conn net.Conn, greeting string) {
 				s.metrics.Commands.With("command", imap.CommandStartTLS, "status", "failure").Add(1)
 			}
 
+		case req.Command == imap.CommandID:
+			cmdOK = s.ID(c, req)
+
+			logger := log.With(s.logger, "command", imap.CommandID)
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandID, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandID, "status", "failure").Add(1)
+			}
+
 		case req.Command == imap.CommandLogin:
 			cmdOK = s.Login(c, req)
 
@@ -333,6 +540,30 @@ func (s *service) handleConnection(conn net.Conn, greeting string) {
 				s.metrics.Commands.With("command", imap.CommandLogin, "status", "failure").Add(1)
 			}
 
+		case req.Command == imap.CommandAuthenticate:
+			cmdOK = s.Authenticate(c, req)
+
+			logger := log.With(s.logger, "command", imap.CommandAuthenticate)
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandAuthenticate, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandAuthenticate, "status", "failure").Add(1)
+			}
+
+		case (c.IsAuthorized) && (req.Command == imap.CommandCompress):
+			cmdOK = s.Compress(c, req)
+
+			logger := log.With(s.logger, "command", imap.CommandCompress)
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandCompress, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandCompress, "status", "failure").Add(1)
+			}
+
 		case (c.IsAuthorized) && (req.Command == imap.CommandSelect):
 			cmdOK = s.ProxySelect(c, rawReq)
 
@@ -438,115 +669,427 @@ func (s *service) handleConnection(conn net.Conn, greeting string) {
 				s.metrics.Commands.With("command", imap.CommandStore, "status", "failure").Add(1)
 			}
 
-		default:
-			// Client sent inappropriate command. Signal tagged error.
-			err := c.Send(fmt.Sprintf("%s BAD Received invalid IMAP command", req.Tag))
-			if err != nil {
+		case (c.IsAuthorized) && (req.Command == imap.CommandFetch):
+			cmdOK = s.ProxyFetch(c, rawReq)
 
-				level.Error(s.logger).Log(
-					"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
-					"err", err,
-				)
+			logger := log.With(s.logger,
+				"command", imap.CommandFetch,
+				"payload", req.Payload,
+			)
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandFetch, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandFetch, "status", "failure").Add(1)
+			}
 
-				err = c.Close()
-				if err != nil {
-					level.Error(s.logger).Log(
-						"msg", "failed to close Connection struct",
-						"err", err,
-					)
-				}
+		case (c.IsAuthorized) && (req.Command == imap.CommandSearch):
+			cmdOK = s.ProxySearch(c, rawReq)
 
-				return
+			logger := log.With(s.logger,
+				"command", imap.CommandSearch,
+				"payload", req.Payload,
+			)
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandSearch, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandSearch, "status", "failure").Add(1)
 			}
-		}
 
-		// Executed command above indicated failure in
-		// operation. Return from function.
-		if !cmdOK {
-			break
-		}
-	}
+		case (c.IsAuthorized) && (req.Command == imap.CommandUID):
+			cmdOK = s.ProxyUID(c, rawReq)
 
-	// Tear down connection.
-	err = c.Close()
-	if err != nil {
-		level.Error(s.logger).Log(
-			"msg", "failed to close Connection struct",
-			"err", err,
-		)
-	}
+			logger := log.With(s.logger,
+				"command", imap.CommandUID,
+				"payload", req.Payload,
+			)
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandUID, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandUID, "status", "failure").Add(1)
+			}
 
-	return
-}
+		case (c.IsAuthorized) && (req.Command == imap.CommandCopy):
+			cmdOK = s.ProxyCopy(c, rawReq)
 
-// Capability handles the IMAP CAPABILITY command.
-// It outputs the supported actions in the current state.
-func (s *service) Capability(c *Connection, req *imap.Request) bool {
+			logger := log.With(s.logger,
+				"command", imap.CommandCopy,
+				"payload", req.Payload,
+			)
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandCopy, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandCopy, "status", "failure").Add(1)
+			}
 
-	if len(req.Payload) > 0 {
+		case (c.IsAuthorized) && (req.Command == imap.CommandMove):
+			cmdOK = s.ProxyMove(c, rawReq)
 
-		// If payload was not empty to CAPABILITY command,
-		// this is a client error. Return BAD statement.
-		err := c.Send(fmt.Sprintf("%s BAD Command CAPABILITY was sent with extra parameters", req.Tag))
-		if err != nil {
-			level.Error(s.logger).Log(
-				"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
-				"err", err,
+			logger := log.With(s.logger,
+				"command", imap.CommandMove,
+				"payload", req.Payload,
 			)
-			return false
-		}
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandMove, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandMove, "status", "failure").Add(1)
+			}
 
-		return true
-	}
+		case (c.IsAuthorized) && (req.Command == imap.CommandRename):
+			cmdOK = s.ProxyRename(c, rawReq)
 
-	// Send mandatory capability options.
-	// This means, AUTH=PLAIN is allowed and nothing else.
-	// STARTTLS will be answered but is not listed as
-	// each connection already is a TLS connection.
-	err := c.Send(fmt.Sprintf("* CAPABILITY IMAP4rev1 AUTH=PLAIN\r\n%s OK CAPABILITY completed", req.Tag))
-	if err != nil {
-		level.Error(s.logger).Log(
-			"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
-			"err", err,
-		)
-		return false
-	}
+			logger := log.With(s.logger,
+				"command", imap.CommandRename,
+				"payload", req.Payload,
+			)
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandRename, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandRename, "status", "failure").Add(1)
+			}
 
-	return true
-}
+		case (c.IsAuthorized) && (req.Command == imap.CommandExamine):
+			cmdOK = s.ProxyExamine(c, rawReq)
 
-// Logout correctly ends a connection with a client.
-// Also necessarily created management structures will
-// get shut down gracefully.
-func (s *service) Logout(c *Connection, req *imap.Request) bool {
+			logger := log.With(s.logger,
+				"command", imap.CommandExamine,
+				"payload", req.Payload,
+			)
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandExamine, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandExamine, "status", "failure").Add(1)
+			}
 
-	if len(req.Payload) > 0 {
+		case (c.IsAuthorized) && (req.Command == imap.CommandStatus):
+			cmdOK = s.ProxyStatus(c, rawReq)
 
-		// If payload was not empty to LOGOUT command,
-		// this is a client error. Return BAD statement.
-		err := c.Send(fmt.Sprintf("%s BAD Command LOGOUT was sent with extra parameters", req.Tag))
-		if err != nil {
-			level.Error(s.logger).Log(
-				"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
-				"err", err,
+			logger := log.With(s.logger,
+				"command", imap.CommandStatus,
+				"payload", req.Payload,
 			)
-			return false
-		}
-
-		return true
-	}
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandStatus, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandStatus, "status", "failure").Add(1)
+			}
 
-	if c.IsAuthorized {
+		case (c.IsAuthorized) && (req.Command == imap.CommandClose):
+			cmdOK = s.ProxyClose(c, rawReq)
 
-		// If already a worker was assigned, signal logout.
-		conf, err := c.gRPCClient.Close(context.Background(), &imap.Context{
-			ClientID:   c.ClientID,
-			UserName:   c.UserName,
-			RespWorker: c.PrimaryNode,
-		})
-		if (err != nil) || (conf.Status != 0) {
+			logger := log.With(s.logger,
+				"command", imap.CommandClose,
+				"payload", req.Payload,
+			)
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandClose, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandClose, "status", "failure").Add(1)
+			}
 
-			c.Send("* BAD Internal server error, sorry. Closing connection.")
+		case (c.IsAuthorized) && (req.Command == imap.CommandUnselect):
+			cmdOK = s.ProxyUnselect(c, rawReq)
+
+			logger := log.With(s.logger,
+				"command", imap.CommandUnselect,
+				"payload", req.Payload,
+			)
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandUnselect, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandUnselect, "status", "failure").Add(1)
+			}
+
+		case (c.IsAuthorized) && (req.Command == imap.CommandCheck):
+			cmdOK = s.ProxyCheck(c, rawReq)
+
+			logger := log.With(s.logger,
+				"command", imap.CommandCheck,
+				"payload", req.Payload,
+			)
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandCheck, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandCheck, "status", "failure").Add(1)
+			}
+
+		case (c.IsAuthorized) && (req.Command == imap.CommandNoop):
+			cmdOK = s.ProxyNoop(c, rawReq)
+
+			logger := log.With(s.logger,
+				"command", imap.CommandNoop,
+				"payload", req.Payload,
+			)
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandNoop, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandNoop, "status", "failure").Add(1)
+			}
+
+		case (c.IsAuthorized) && (req.Command == imap.CommandIdle):
+			cmdOK = s.ProxyIdle(c, rawReq)
+
+			logger := log.With(s.logger,
+				"command", imap.CommandIdle,
+				"payload", req.Payload,
+			)
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandIdle, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandIdle, "status", "failure").Add(1)
+			}
+
+		case req.Command == imap.CommandNoop:
+			cmdOK = s.Noop(c, req)
+
+			logger := log.With(s.logger, "command", imap.CommandNoop)
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandNoop, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandNoop, "status", "failure").Add(1)
+			}
+
+		case (c.IsAuthorized) && (req.Command == imap.CommandSubscribe):
+			cmdOK = s.ProxySubscribe(c, rawReq)
+
+			logger := log.With(s.logger,
+				"command", imap.CommandSubscribe,
+				"payload", req.Payload,
+			)
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandSubscribe, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandSubscribe, "status", "failure").Add(1)
+			}
+
+		case (c.IsAuthorized) && (req.Command == imap.CommandUnsubscribe):
+			cmdOK = s.ProxyUnsubscribe(c, rawReq)
+
+			logger := log.With(s.logger,
+				"command", imap.CommandUnsubscribe,
+				"payload", req.Payload,
+			)
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandUnsubscribe, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandUnsubscribe, "status", "failure").Add(1)
+			}
+
+		case (c.IsAuthorized) && (req.Command == imap.CommandLsub):
+			cmdOK = s.ProxyLsub(c, rawReq)
+
+			logger := log.With(s.logger,
+				"command", imap.CommandLsub,
+				"payload", req.Payload,
+			)
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandLsub, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandLsub, "status", "failure").Add(1)
+			}
+
+		case (c.IsAuthorized) && (req.Command == imap.CommandEnable):
+			cmdOK = s.ProxyEnable(c, rawReq)
+
+			logger := log.With(s.logger,
+				"command", imap.CommandEnable,
+				"payload", req.Payload,
+			)
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandEnable, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandEnable, "status", "failure").Add(1)
+			}
+
+		case (c.IsAuthorized) && (req.Command == imap.CommandNamespace):
+			cmdOK = s.ProxyNamespace(c, rawReq)
+
+			logger := log.With(s.logger,
+				"command", imap.CommandNamespace,
+				"payload", req.Payload,
+			)
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandNamespace, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandNamespace, "status", "failure").Add(1)
+			}
+
+		case (c.IsAuthorized) && (req.Command == imap.CommandSort):
+			cmdOK = s.ProxySort(c, rawReq)
+
+			logger := log.With(s.logger,
+				"command", imap.CommandSort,
+				"payload", req.Payload,
+			)
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandSort, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandSort, "status", "failure").Add(1)
+			}
+
+		case (c.IsAuthorized) && (req.Command == imap.CommandThread):
+			cmdOK = s.ProxyThread(c, rawReq)
+
+			logger := log.With(s.logger,
+				"command", imap.CommandThread,
+				"payload", req.Payload,
+			)
+			if cmdOK {
+				level.Debug(logger).Log()
+				s.metrics.Commands.With("command", imap.CommandThread, "status", "success").Add(1)
+			} else {
+				level.Info(logger).Log("err", "failed to run")
+				s.metrics.Commands.With("command", imap.CommandThread, "status", "failure").Add(1)
+			}
+
+		default:
+			// Client sent inappropriate command. Signal tagged error.
+			err := c.Send(fmt.Sprintf("%s BAD Received invalid IMAP command", req.Tag))
+			if err != nil {
+
+				level.Error(s.logger).Log(
+					"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+					"err", err,
+				)
+
+				err = c.Close()
+				if err != nil {
+					level.Error(s.logger).Log(
+						"msg", "failed to close Connection struct",
+						"err", err,
+					)
+				}
+
+				return
+			}
+		}
+
+		// Executed command above indicated failure in
+		// operation. Return from function.
+		if !cmdOK {
+			break
+		}
+	}
+
+	// Tear down connection.
+	err = c.Close()
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", "failed to close Connection struct",
+			"err", err,
+		)
+	}
+
+	return
+}
+
+// Capability handles the IMAP CAPABILITY command.
+// It outputs the supported actions in the current state.
+// Which capabilities apply depends on authentication
+// and TLS state as well as the configuration.
+func (s *service) Capability(c *Connection, req *imap.Request) bool {
+
+	if len(req.Payload) > 0 {
+
+		// If payload was not empty to CAPABILITY command,
+		// this is a client error. Return BAD statement.
+		err := c.Send(fmt.Sprintf("%s BAD Command CAPABILITY was sent with extra parameters", req.Tag))
+		if err != nil {
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+				"err", err,
+			)
+			return false
+		}
+
+		return true
+	}
+
+	// Send capabilities applying to the
+	// current state of the connection.
+	err := c.Send(fmt.Sprintf("* CAPABILITY %s\r\n%s OK CAPABILITY completed", s.capabilities(c), req.Tag))
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// Logout correctly ends a connection with a client.
+// Also necessarily created management structures will
+// get shut down gracefully.
+func (s *service) Logout(c *Connection, req *imap.Request) bool {
+
+	if len(req.Payload) > 0 {
+
+		// If payload was not empty to LOGOUT command,
+		// this is a client error. Return BAD statement.
+		err := c.Send(fmt.Sprintf("%s BAD Command LOGOUT was sent with extra parameters", req.Tag))
+		if err != nil {
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+				"err", err,
+			)
+			return false
+		}
+
+		return true
+	}
+
+	if c.IsAuthorized {
+
+		// If already a worker was assigned, signal logout.
+		conf, err := c.gRPCClient.Close(context.Background(), &imap.Context{
+			ClientID:   c.ClientID,
+			UserName:   c.UserName,
+			RespWorker: c.PrimaryNode,
+		})
+		if (err != nil) || (conf.Status != 0) {
+
+			c.Send("* BAD Internal server error, sorry. Closing connection.")
 
 			if err != nil {
 				level.Error(s.logger).Log(
@@ -595,10 +1138,32 @@ func (s *service) Login(c *Connection, req *imap.Request) bool {
 		return true
 	}
 
-	// Split payload on every space character.
-	userCredentials := strings.Split(req.Payload, " ")
+	if !c.IsTLS() {
+
+		// Passwords must not be sent in cleartext,
+		// LOGIN is disabled until STARTTLS succeeded.
+		err := c.Send(fmt.Sprintf("%s NO [PRIVACYREQUIRED] LOGIN is disabled without TLS, use STARTTLS first", req.Tag))
+		if err != nil {
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+				"err", err,
+			)
+			return false
+		}
+
+		return true
+	}
+
+	var userName, password string
+	ok := len(req.Args) == 2
+	if ok {
+		userName, ok = req.Args[0].AString()
+	}
+	if ok {
+		password, ok = req.Args[1].AString()
+	}
 
-	if len(userCredentials) != 2 {
+	if !ok {
 
 		// If payload did not contain exactly two elements,
 		// this is a client error. Return BAD statement.
@@ -615,7 +1180,7 @@ func (s *service) Login(c *Connection, req *imap.Request) bool {
 	}
 
 	// Perform the actual authentication.
-	id, clientID, err := s.authenticator.AuthenticatePlain(userCredentials[0], userCredentials[1], c.ClientAddr)
+	id, clientID, err := s.authenticator.AuthenticatePlain(userName, password, c.ClientAddr)
 	if err != nil {
 
 		// If supplied credentials failed to authenticate client,
@@ -632,12 +1197,22 @@ func (s *service) Login(c *Connection, req *imap.Request) bool {
 		return true
 	}
 
+	return s.completeLogin(c, req, id, clientID, userName)
+}
+
+// completeLogin moves a connection into authenticated
+// state after the user was successfully authenticated
+// via LOGIN or AUTHENTICATE. It connects to the node
+// responsible for the user's mailbox and tells the
+// client the capabilities now available.
+func (s *service) completeLogin(c *Connection, req *imap.Request, id int, clientID string, userName string) bool {
+
 	// Find worker node responsible for this connection.
 	respWorker, err := s.authenticator.GetWorkerForUser(s.workers, id)
 	if err != nil {
 		c.Send("* BAD Internal server error, sorry. Closing connection.")
 		level.Error(s.logger).Log(
-			"msg", fmt.Sprintf("error finding worker for user %s with ID %d", userCredentials[0], id),
+			"msg", fmt.Sprintf("error finding worker for user %s with ID %d", userName, id),
 			"err", err,
 		)
 		return false
@@ -660,7 +1235,7 @@ func (s *service) Login(c *Connection, req *imap.Request) bool {
 	// Save context to connection struct.
 	c.IsAuthorized = true
 	c.ClientID = clientID
-	c.UserName = userCredentials[0]
+	c.UserName = userName
 
 	// Prepare payload to send.
 	payload := &imap.Context{
@@ -669,15 +1244,1679 @@ func (s *service) Login(c *Connection, req *imap.Request) bool {
 		RespWorker: c.PrimaryNode,
 	}
 
-	// Send worker or storage context of to-come client connection.
-	conf, err := c.gRPCClient.Prepare(context.Background(), payload)
+	// Send worker or storage context of to-come client connection.
+	conf, err := c.gRPCClient.Prepare(context.Background(), payload)
+	for err != nil {
+
+		// Check received gRPC error.
+		stat, ok := status.FromError(err)
+		if ok && (stat.Code() == codes.Unavailable) {
+
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during Prepare(), reconnecting...", c.ActualNode, c.ActualAddr))
+
+			err := c.Connect(s.gRPCOptions, s.logger, false)
+			if err != nil {
+				c.Send(err.Error())
+				level.Error(s.logger).Log("msg", "failed too many times to connect to worker or storage, telling client")
+				return true
+			}
+
+			conf, err = c.gRPCClient.Prepare(context.Background(), payload)
+		} else {
+			c.Send("* BAD Internal server error, sorry. Closing connection.")
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error sending Prepare() to internal node %s", c.ActualNode),
+				"err", err,
+			)
+			return false
+		}
+	}
+
+	if conf.Status != 0 {
+		c.Send("* BAD Internal server error, sorry. Closing connection.")
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Prepare() to internal node %s returned error code", c.ActualNode))
+		return false
+	}
+
+	// Signal success to client along with the capabilities
+	// of authenticated state, saving a CAPABILITY command.
+	err = c.Send(fmt.Sprintf("%s OK [CAPABILITY %s] %s completed", req.Tag, s.capabilities(c), req.Command))
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// Authenticate handles the IMAP AUTHENTICATE command
+// including an initial response (RFC 4959). It runs
+// the SASL exchange of the requested mechanism until
+// the client is authenticated, fails, or cancels.
+func (s *service) Authenticate(c *Connection, req *imap.Request) bool {
+
+	if c.IsAuthorized {
+
+		// Connection was already once authenticated,
+		// cannot do that a second time, client error.
+		// Send tagged BAD response.
+		err := c.Send(fmt.Sprintf("%s BAD Command AUTHENTICATE cannot be executed in this state", req.Tag))
+		if err != nil {
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+				"err", err,
+			)
+			return false
+		}
+
+		return true
+	}
+
+	var response []byte
+	ok := ((len(req.Args) == 1) || (len(req.Args) == 2)) && (req.Args[0].Type == imap.NodeAtom)
+
+	// An initial response of "=" denotes an
+	// empty one, anything else is base64.
+	if ok && (len(req.Args) == 2) {
+
+		ok = req.Args[1].Type == imap.NodeAtom
+		if ok && (req.Args[1].Value == "=") {
+			response = []byte{}
+		} else if ok {
+
+			var err error
+			response, err = base64.StdEncoding.DecodeString(req.Args[1].Value)
+			ok = (err == nil)
+		}
+	}
+
+	if !ok {
+
+		// If payload did not contain a mechanism and an
+		// optional initial response, this is a client
+		// error. Return BAD statement.
+		err := c.Send(fmt.Sprintf("%s BAD Command AUTHENTICATE was sent with invalid parameters", req.Tag))
+		if err != nil {
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+				"err", err,
+			)
+			return false
+		}
+
+		return true
+	}
+
+	name := strings.ToUpper(req.Args[0].Value)
+
+	// Only mechanisms currently advertised
+	// to the client may be used.
+	offered := make([]string, 0, 4)
+	for _, capability := range imap.Capabilities(imap.CapabilityState{TLS: c.IsTLS()}, s.disabledCaps, s.authCaps...) {
+
+		if strings.HasPrefix(capability, "AUTH=") {
+			offered = append(offered, strings.TrimPrefix(capability, "AUTH="))
+		}
+	}
+
+	connState := &auth.ConnState{
+		ClientAddr: c.ClientAddr,
+		Offered:    offered,
+	}
+
+	if tlsConn, ok := c.IncConn.(*tls.Conn); ok {
+		state := tlsConn.ConnectionState()
+		connState.TLS = &state
+	}
+
+	var exchange auth.Exchange
+	err := fmt.Errorf("mechanism %s not offered", name)
+
+	for _, offeredName := range offered {
+
+		if offeredName == name {
+			exchange, err = auth.NewExchange(name, s.authenticator, connState)
+			break
+		}
+	}
+
+	if err != nil {
+
+		level.Info(s.logger).Log(
+			"msg", fmt.Sprintf("client %s requested unavailable mechanism %s", c.ClientAddr, name),
+			"err", err,
+		)
+
+		err := c.Send(fmt.Sprintf("%s NO Unsupported authentication mechanism", req.Tag))
+		if err != nil {
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+				"err", err,
+			)
+			return false
+		}
+
+		return true
+	}
+
+	for {
+
+		challenge, identity, err := exchange.Next(response)
+		if err != nil {
+
+			level.Info(s.logger).Log(
+				"msg", fmt.Sprintf("client %s failed to authenticate via %s", c.ClientAddr, name),
+				"err", err,
+			)
+
+			// Failed exchanges do not reveal why.
+			err := c.Send(fmt.Sprintf("%s NO [AUTHENTICATIONFAILED] Authentication failed", req.Tag))
+			if err != nil {
+				level.Error(s.logger).Log(
+					"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+					"err", err,
+				)
+				return false
+			}
+
+			return true
+		}
+
+		if identity != nil {
+			return s.completeLogin(c, req, identity.ID, identity.ClientID, identity.UserName)
+		}
+
+		// Send challenge and await the client's response.
+		err = c.Send(fmt.Sprintf("+ %s", base64.StdEncoding.EncodeToString(challenge)))
+		if err != nil {
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+				"err", err,
+			)
+			return false
+		}
+
+		line, err := c.Receive()
+		if err != nil {
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error while receiving SASL response from client %s", c.ClientAddr),
+				"err", err,
+			)
+			return false
+		}
+
+		// A single asterisk cancels the exchange.
+		if line == "*" {
+
+			err := c.Send(fmt.Sprintf("%s BAD AUTHENTICATE cancelled", req.Tag))
+			if err != nil {
+				level.Error(s.logger).Log(
+					"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+					"err", err,
+				)
+				return false
+			}
+
+			return true
+		}
+
+		response, err = base64.StdEncoding.DecodeString(line)
+		if err != nil {
+
+			err := c.Send(fmt.Sprintf("%s BAD Invalid base64 in SASL response", req.Tag))
+			if err != nil {
+				level.Error(s.logger).Log(
+					"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+					"err", err,
+				)
+				return false
+			}
+
+			return true
+		}
+	}
+}
+
+// StartTLS handles the IMAP STARTTLS command. It
+// upgrades a cleartext connection to TLS, after which
+// the client may log in.
+func (s *service) StartTLS(c *Connection, req *imap.Request) bool {
+
+	if len(req.Payload) > 0 {
+
+		// If payload was not empty to STARTTLS command,
+		// this is a client error. Return BAD statement.
+		err := c.Send(fmt.Sprintf("%s BAD Command STARTTLS was sent with extra parameters", req.Tag))
+		if err != nil {
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+				"err", err,
+			)
+			return false
+		}
+
+		return true
+	}
+
+	if c.IsTLS() {
+
+		// As the connection is already TLS encrypted,
+		// tell client that a TLS session is active.
+		err := c.Send(fmt.Sprintf("%s BAD TLS is already active", req.Tag))
+		if err != nil {
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+				"err", err,
+			)
+			return false
+		}
+
+		return true
+	}
+
+	// Signal client to start the TLS handshake.
+	err := c.Send(fmt.Sprintf("%s OK Begin TLS negotiation now", req.Tag))
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	err = c.StartTLS(s.publicTLSConfig)
+	if err != nil {
+		level.Info(s.logger).Log(
+			"msg", fmt.Sprintf("TLS negotiation with client %s failed", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// Noop handles the IMAP NOOP command for clients
+// that are not yet authenticated. As there is no
+// mailbox state to report, it simply succeeds.
+func (s *service) Noop(c *Connection, req *imap.Request) bool {
+
+	if len(req.Payload) > 0 {
+
+		// If payload was not empty to NOOP command,
+		// this is a client error. Return BAD statement.
+		err := c.Send(fmt.Sprintf("%s BAD Command NOOP was sent with extra parameters", req.Tag))
+		if err != nil {
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+				"err", err,
+			)
+			return false
+		}
+
+		return true
+	}
+
+	err := c.Send(fmt.Sprintf("%s OK NOOP completed", req.Tag))
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// Compress turns on COMPRESS=DEFLATE (RFC 4978) on
+// the connection to an authenticated client. The
+// tagged OK response is the last one sent without
+// compression.
+func (s *service) Compress(c *Connection, req *imap.Request) bool {
+
+	var answer string
+
+	if (len(req.Args) != 1) || (req.Args[0].Type != imap.NodeAtom) || (strings.ToUpper(req.Args[0].Value) != "DEFLATE") {
+
+		// Only DEFLATE is supported. Other or missing
+		// mechanisms are a client error.
+		answer = fmt.Sprintf("%s BAD Command COMPRESS was not sent with supported mechanism DEFLATE", req.Tag)
+	} else if c.IncWriter != nil {
+
+		// Compression cannot be stacked.
+		answer = fmt.Sprintf("%s NO [COMPRESSIONACTIVE] DEFLATE active via COMPRESS", req.Tag)
+	}
+
+	if answer != "" {
+
+		err := c.Send(answer)
+		if err != nil {
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+				"err", err,
+			)
+			return false
+		}
+
+		return true
+	}
+
+	err := c.Send(fmt.Sprintf("%s OK DEFLATE active", req.Tag))
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	err = c.Compress()
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("failed to enable compression for client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// ID records the name and version the client reports
+// about itself (RFC 2971) for logs and metrics and
+// answers with the name of this server. ID is valid
+// in any state.
+func (s *service) ID(c *Connection, req *imap.Request) bool {
+
+	var params map[string]string
+	var err error
+
+	if len(req.Args) != 1 {
+		err = fmt.Errorf("Command ID was not sent with exactly one parameter")
+	} else {
+		params, err = imap.ParseID(req.Args[0])
+	}
+
+	if err != nil {
+
+		// Malformed parameter lists are a client
+		// error. Return BAD statement.
+		err := c.Send(fmt.Sprintf("%s BAD %s", req.Tag, err.Error()))
+		if err != nil {
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+				"err", err,
+			)
+			return false
+		}
+
+		return true
+	}
+
+	if len(params) > 0 {
+
+		c.ClientName = params["name"]
+		c.ClientVersion = params["version"]
+
+		level.Info(s.logger).Log(
+			"msg", "client identified itself",
+			"client", c.ClientAddr,
+			"name", c.ClientName,
+			"version", c.ClientVersion,
+		)
+		s.metrics.Clients.With("name", c.ClientName, "version", c.ClientVersion).Add(1)
+	}
+
+	err = c.Send(fmt.Sprintf("* ID (\"name\" \"pluto\")\r\n%s OK ID completed", req.Tag))
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// ProxySelect tunnels a received SELECT request by
+// an authorized client to the responsible worker or
+// storage node.
+func (s *service) ProxySelect(c *Connection, rawReq string) bool {
+
+	// Prepare payload to send.
+	payload := &imap.Command{
+		Text:     rawReq,
+		ClientID: c.ClientID,
+	}
+
+	// Send the request via gRPC.
+	reply, err := c.gRPCClient.Select(context.Background(), payload)
+	for err != nil {
+
+		// Check received gRPC error.
+		stat, ok := status.FromError(err)
+		if ok && (stat.Code() == codes.Unavailable) {
+
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxySelect(), reconnecting...", c.ActualNode, c.ActualAddr))
+
+			err := c.Connect(s.gRPCOptions, s.logger, false)
+			if err != nil {
+				c.Send(err.Error())
+				level.Error(s.logger).Log("msg", "failed too many times to connect to worker or storage, telling client")
+				return true
+			}
+
+			reply, err = c.gRPCClient.Select(context.Background(), payload)
+		} else {
+			c.Send("* BAD Internal server error, sorry. Closing connection.")
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error sending Select() to internal node %s", c.ActualNode),
+				"err", err,
+			)
+			return false
+		}
+	}
+
+	if reply.Status != 0 {
+		c.Send("* BAD Internal server error, sorry. Closing connection.")
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Select() to internal node %s returned error code", c.ActualNode))
+		return false
+	}
+
+	// And send response from worker or storage to client.
+	err = c.Send(reply.Text)
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error sending SELECT answer to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// ProxyCreate tunnels a received CREATE request by
+// an authorized client to the responsible worker or
+// storage node.
+func (s *service) ProxyCreate(c *Connection, rawReq string) bool {
+
+	// Prepare payload to send.
+	payload := &imap.Command{
+		Text:     rawReq,
+		ClientID: c.ClientID,
+	}
+
+	// Send the request via gRPC.
+	reply, err := c.gRPCClient.Create(context.Background(), payload)
+	for err != nil {
+
+		// Check received gRPC error.
+		stat, ok := status.FromError(err)
+		if ok && (stat.Code() == codes.Unavailable) {
+
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyCreate(), reconnecting...", c.ActualNode, c.ActualAddr))
+
+			err := c.Connect(s.gRPCOptions, s.logger, false)
+			if err != nil {
+				c.Send(err.Error())
+				level.Error(s.logger).Log("msg", "failed too many times to connect to worker or storage, telling client")
+				return true
+			}
+
+			reply, err = c.gRPCClient.Create(context.Background(), payload)
+		} else {
+			c.Send("* BAD Internal server error, sorry. Closing connection.")
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error sending Create() to internal node %s", c.ActualNode),
+				"err", err,
+			)
+			return false
+		}
+	}
+
+	if reply.Status != 0 {
+		c.Send("* BAD Internal server error, sorry. Closing connection.")
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Create() to internal node %s returned error code", c.ActualNode))
+		return false
+	}
+
+	// And send response from worker or storage to client.
+	err = c.Send(reply.Text)
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error sending CREATE answer to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// ProxyDelete tunnels a received DELETE request by
+// an authorized client to the responsible worker or
+// storage node.
+func (s *service) ProxyDelete(c *Connection, rawReq string) bool {
+
+	// Prepare payload to send.
+	payload := &imap.Command{
+		Text:     rawReq,
+		ClientID: c.ClientID,
+	}
+
+	// Send the request via gRPC.
+	reply, err := c.gRPCClient.Delete(context.Background(), payload)
+	for err != nil {
+
+		// Check received gRPC error.
+		stat, ok := status.FromError(err)
+		if ok && (stat.Code() == codes.Unavailable) {
+
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyDelete(), reconnecting...", c.ActualNode, c.ActualAddr))
+
+			err := c.Connect(s.gRPCOptions, s.logger, false)
+			if err != nil {
+				c.Send(err.Error())
+				level.Error(s.logger).Log("msg", "failed too many times to connect to worker or storage, telling client")
+				return true
+			}
+
+			reply, err = c.gRPCClient.Delete(context.Background(), payload)
+		} else {
+			c.Send("* BAD Internal server error, sorry. Closing connection.")
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error sending Delete() to internal node %s", c.ActualNode),
+				"err", err,
+			)
+			return false
+		}
+	}
+
+	if reply.Status != 0 {
+		c.Send("* BAD Internal server error, sorry. Closing connection.")
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Delete() to internal node %s returned error code", c.ActualNode))
+		return false
+	}
+
+	// And send response from worker or storage to client.
+	err = c.Send(reply.Text)
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error sending DELETE answer to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// ProxyList tunnels a received LIST request by
+// an authorized client to the responsible worker or
+// storage node.
+func (s *service) ProxyList(c *Connection, rawReq string) bool {
+
+	// Prepare payload to send.
+	payload := &imap.Command{
+		Text:     rawReq,
+		ClientID: c.ClientID,
+	}
+
+	// Send the request via gRPC.
+	reply, err := c.gRPCClient.List(context.Background(), payload)
+	for err != nil {
+
+		// Check received gRPC error.
+		stat, ok := status.FromError(err)
+		if ok && (stat.Code() == codes.Unavailable) {
+
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyList(), reconnecting...", c.ActualNode, c.ActualAddr))
+
+			err := c.Connect(s.gRPCOptions, s.logger, false)
+			if err != nil {
+				c.Send(err.Error())
+				level.Error(s.logger).Log("msg", "failed too many times to connect to worker or storage, telling client")
+				return true
+			}
+
+			reply, err = c.gRPCClient.List(context.Background(), payload)
+		} else {
+			c.Send("* BAD Internal server error, sorry. Closing connection.")
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error sending List() to internal node %s", c.ActualNode),
+				"err", err,
+			)
+			return false
+		}
+	}
+
+	if reply.Status != 0 {
+		c.Send("* BAD Internal server error, sorry. Closing connection.")
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending List() to internal node %s returned error code", c.ActualNode))
+		return false
+	}
+
+	// And send response from worker or storage to client.
+	err = c.Send(reply.Text)
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error sending LIST answer to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// messageLiteral reports whether the last of the supplied
+// APPEND arguments announces a message literal sent as
+// non-synchronizing literal (RFC 7888) and its size.
+func messageLiteral(args []*imap.Node) (bool, int) {
+
+	if len(args) == 0 {
+		return false, 0
+	}
+
+	message := args[(len(args) - 1)]
+	if message.Pending && message.NonSync {
+		return true, message.Size
+	}
+
+	return false, 0
+}
+
+// abortAppend signals the connected internal node
+// that the client aborted the APPEND in progress.
+func (s *service) abortAppend(c *Connection) {
+
+	conf, err := c.gRPCClient.AppendAbort(context.Background(), &imap.Abort{
+		ClientID: c.ClientID,
+	})
+
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error sending AppendAbort() to internal node %s", c.ActualNode),
+			"err", err,
+		)
+	} else if conf.Status != 0 {
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending AppendAbort() to internal node %s returned error code", c.ActualNode))
+	}
+}
+
+// skipAppend discards the messages a client sends as
+// non-synchronizing literals after its APPEND was
+// refused, starting with one of numBytes bytes.
+func (s *service) skipAppend(c *Connection, numBytes int) error {
+
+	for {
+
+		_, err := io.CopyN(ioutil.Discard, c.IncReader, int64(numBytes))
+		if err != nil {
+			return err
+		}
+
+		rest, err := imap.ReadAppendRest(c.IncReader, func() error {
+			return fmt.Errorf("client expects continuation for refused APPEND")
+		})
+		if err != nil {
+			return err
+		}
+
+		args, err := imap.ParseArgs(strings.TrimPrefix(rest, " "))
+		if err != nil {
+			return nil
+		}
+
+		nonSync, size := messageLiteral(args)
+		if !nonSync {
+			return nil
+		}
+
+		numBytes = size
+	}
+}
+
+// ProxyAppend tunnels a received APPEND request by
+// an authorized client to the responsible worker or
+// storage node. Messages of a MULTIAPPEND (RFC 3502)
+// are handed to the node one after another.
+func (s *service) ProxyAppend(c *Connection, rawReq string) bool {
+
+	// Find out if the message is sent as non-synchronizing
+	// literal (RFC 7888). In this case, the client sends it
+	// right away instead of waiting for a continuation.
+	nonSync := false
+	numBytes := 0
+
+	req, err := imap.ParseRequest(rawReq)
+	if err == nil {
+		nonSync, numBytes = messageLiteral(req.Args)
+	}
+
+	// Prepare payload to send.
+	payload := &imap.Command{
+		Text:     rawReq,
+		ClientID: c.ClientID,
+	}
+
+	// Send the begin part of the APPEND request via gRPC.
+	await, err := c.gRPCClient.AppendBegin(context.Background(), payload)
+	for err != nil {
+
+		// Check received gRPC error.
+		stat, ok := status.FromError(err)
+		if ok && (stat.Code() == codes.Unavailable) {
+
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during begin part of ProxyAppend(), reconnecting...", c.ActualNode, c.ActualAddr))
+
+			err := c.Connect(s.gRPCOptions, s.logger, false)
+			if err != nil {
+				c.Send(err.Error())
+				level.Error(s.logger).Log("msg", "failed too many times to connect to worker or storage, telling client")
+				return true
+			}
+
+			await, err = c.gRPCClient.AppendBegin(context.Background(), payload)
+		} else {
+			c.Send("* BAD Internal server error, sorry. Closing connection.")
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error sending AppendBegin() to internal node %s", c.ActualNode),
+				"err", err,
+			)
+			return false
+		}
+	}
+
+	if await.Status != 0 {
+		c.Send("* BAD Internal server error, sorry. Closing connection.")
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending AppendBegin() to internal node %s returned error code", c.ActualNode))
+		return false
+	}
+
+	var msgBuffer []byte
+
+	for {
+
+		// Pass on either error or continuation response to client.
+		// A non-synchronizing literal needs no continuation.
+		if !nonSync || (await.Text != "+ Ready for literal data") {
+			err = c.Send(await.Text)
+		}
+		if err != nil {
+
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error sending begin APPEND answer to client %s", c.ClientAddr),
+				"err", err,
+			)
+
+			if await.Text == "+ Ready for literal data" {
+				s.abortAppend(c)
+			}
+
+			return false
+		}
+
+		// Check if seen response was no continuation response.
+		// In such case, simply return as this function is done here.
+		if await.Text != "+ Ready for literal data" {
+
+			if nonSync {
+
+				// The message is sent anyway, skip it.
+				err := s.skipAppend(c, numBytes)
+				if err != nil {
+
+					level.Error(s.logger).Log(
+						"msg", fmt.Sprintf("error skipping refused mail content from client %s", c.ClientAddr),
+						"err", err,
+					)
+
+					return false
+				}
+			}
+
+			return true
+		}
+
+		// Reserve space for exact amount of expected data.
+		msgBuffer = make([]byte, await.NumBytes)
+
+		// Read in that amount from connection to client.
+		_, err = io.ReadFull(c.IncReader, msgBuffer)
+		if err != nil {
+
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error reading mail content from client %s", c.ClientAddr),
+				"err", err,
+			)
+
+			s.abortAppend(c)
+
+			return false
+		}
+
+		// Read the rest of the command following the
+		// message, which either ends it or carries the
+		// next message of a MULTIAPPEND.
+		rest, err := imap.ReadAppendRest(c.IncReader, func() error {
+			return c.Send("+ Ready for additional command text")
+		})
+		if litErr, ok := err.(*imap.LiteralError); ok {
+
+			s.abortAppend(c)
+
+			// Client announced a literal too big to accept
+			// and waits for our answer. Refuse the command.
+			litErr.Tag = strings.SplitN(rawReq, " ", 2)[0]
+
+			err = c.Send(litErr.Error())
+			if err != nil {
+
+				level.Error(s.logger).Log(
+					"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+					"err", err,
+				)
+
+				return false
+			}
+
+			return true
+		}
+
+		if err != nil {
+
+			level.Warn(s.logger).Log(
+				"msg", fmt.Sprintf("failed reading rest of APPEND after message from %s", c.ClientAddr),
+				"err", err,
+			)
+
+			s.abortAppend(c)
+
+			return false
+		}
+
+		if rest == "" {
+			break
+		}
+
+		nonSync = false
+		if args, err := imap.ParseArgs(strings.TrimPrefix(rest, " ")); err == nil {
+			nonSync, numBytes = messageLiteral(args)
+		}
+
+		// Hand the message and the rest of the
+		// command to the node via gRPC.
+		await, err = c.gRPCClient.AppendNext(context.Background(), &imap.MailFile{
+			Content:  msgBuffer,
+			ClientID: c.ClientID,
+			Text:     rest,
+		})
+		if (err != nil) || (await.Status != 0) {
+
+			c.Send("* BAD Internal server error, sorry. Closing connection.")
+
+			if err != nil {
+				level.Error(s.logger).Log(
+					"msg", fmt.Sprintf("error sending AppendNext() to internal node %s", c.ActualNode),
+					"err", err,
+				)
+			} else if await.Status != 0 {
+				level.Error(s.logger).Log("msg", fmt.Sprintf("sending AppendNext() to internal node %s returned error code", c.ActualNode))
+			}
+
+			return false
+		}
+	}
+
+	// Send the end part of APPEND request via gRPC.
+	reply, err := c.gRPCClient.AppendEnd(context.Background(), &imap.MailFile{
+		Content:  msgBuffer,
+		ClientID: c.ClientID,
+	})
+	if (err != nil) || (reply.Status != 0) {
+
+		c.Send("* BAD Internal server error, sorry. Closing connection.")
+
+		if err != nil {
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error sending AppendEnd() to internal node %s", c.ActualNode),
+				"err", err,
+			)
+		} else if reply.Status != 0 {
+			level.Error(s.logger).Log("msg", fmt.Sprintf("sending AppendEnd() to internal node %s returned error code", c.ActualNode))
+		}
+
+		return false
+	}
+
+	// And send response from worker or storage to client.
+	err = c.Send(reply.Text)
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error sending end APPEND answer to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// ProxyExpunge tunnels a received EXPUNGE request by
+// an authorized client to the responsible worker or
+// storage node.
+func (s *service) ProxyExpunge(c *Connection, rawReq string) bool {
+
+	// Prepare payload to send.
+	payload := &imap.Command{
+		Text:     rawReq,
+		ClientID: c.ClientID,
+	}
+
+	// Send the request via gRPC.
+	reply, err := c.gRPCClient.Expunge(context.Background(), payload)
+	for err != nil {
+
+		// Check received gRPC error.
+		stat, ok := status.FromError(err)
+		if ok && (stat.Code() == codes.Unavailable) {
+
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyExpunge(), reconnecting...", c.ActualNode, c.ActualAddr))
+
+			err := c.Connect(s.gRPCOptions, s.logger, false)
+			if err != nil {
+				c.Send(err.Error())
+				level.Error(s.logger).Log("msg", "failed too many times to connect to worker or storage, telling client")
+				return true
+			}
+
+			reply, err = c.gRPCClient.Expunge(context.Background(), payload)
+		} else {
+			c.Send("* BAD Internal server error, sorry. Closing connection.")
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error sending Expunge() to internal node %s", c.ActualNode),
+				"err", err,
+			)
+			return false
+		}
+	}
+
+	if reply.Status != 0 {
+		c.Send("* BAD Internal server error, sorry. Closing connection.")
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Expunge() to internal node %s returned error code", c.ActualNode))
+		return false
+	}
+
+	// And send response from worker or storage to client.
+	err = c.Send(reply.Text)
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error sending EXPUNGE answer to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// ProxyStore tunnels a received STORE request by
+// an authorized client to the responsible worker or
+// storage node.
+func (s *service) ProxyStore(c *Connection, rawReq string) bool {
+
+	// Prepare payload to send.
+	payload := &imap.Command{
+		Text:     rawReq,
+		ClientID: c.ClientID,
+	}
+
+	// Send the request via gRPC.
+	reply, err := c.gRPCClient.Store(context.Background(), payload)
+	for err != nil {
+
+		// Check received gRPC error.
+		stat, ok := status.FromError(err)
+		if ok && (stat.Code() == codes.Unavailable) {
+
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyStore(), reconnecting...", c.ActualNode, c.ActualAddr))
+
+			err := c.Connect(s.gRPCOptions, s.logger, false)
+			if err != nil {
+				c.Send(err.Error())
+				level.Error(s.logger).Log("msg", "failed too many times to connect to worker or storage, telling client")
+				return true
+			}
+
+			reply, err = c.gRPCClient.Store(context.Background(), payload)
+		} else {
+			c.Send("* BAD Internal server error, sorry. Closing connection.")
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error sending Store() to internal node %s", c.ActualNode),
+				"err", err,
+			)
+			return false
+		}
+	}
+
+	if reply.Status != 0 {
+		c.Send("* BAD Internal server error, sorry. Closing connection.")
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Store() to internal node %s returned error code", c.ActualNode))
+		return false
+	}
+
+	// And send response from worker or storage to client.
+	err = c.Send(reply.Text)
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error sending STORE answer to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// ProxyFetch tunnels a received FETCH request by
+// an authorized client to the responsible worker or
+// storage node.
+func (s *service) ProxyFetch(c *Connection, rawReq string) bool {
+
+	// Prepare payload to send.
+	payload := &imap.Command{
+		Text:     rawReq,
+		ClientID: c.ClientID,
+	}
+
+	// Send the request via gRPC.
+	reply, err := c.gRPCClient.Fetch(context.Background(), payload)
+	for err != nil {
+
+		// Check received gRPC error.
+		stat, ok := status.FromError(err)
+		if ok && (stat.Code() == codes.Unavailable) {
+
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyFetch(), reconnecting...", c.ActualNode, c.ActualAddr))
+
+			err := c.Connect(s.gRPCOptions, s.logger, false)
+			if err != nil {
+				c.Send(err.Error())
+				level.Error(s.logger).Log("msg", "failed too many times to connect to worker or storage, telling client")
+				return true
+			}
+
+			reply, err = c.gRPCClient.Fetch(context.Background(), payload)
+		} else {
+			c.Send("* BAD Internal server error, sorry. Closing connection.")
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error sending Fetch() to internal node %s", c.ActualNode),
+				"err", err,
+			)
+			return false
+		}
+	}
+
+	if reply.Status != 0 {
+		c.Send("* BAD Internal server error, sorry. Closing connection.")
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Fetch() to internal node %s returned error code", c.ActualNode))
+		return false
+	}
+
+	// And send response from worker or storage to client.
+	err = c.Send(reply.Text)
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error sending FETCH answer to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// ProxySearch tunnels a received SEARCH request by
+// an authorized client to the responsible worker or
+// storage node.
+func (s *service) ProxySearch(c *Connection, rawReq string) bool {
+
+	// Prepare payload to send.
+	payload := &imap.Command{
+		Text:     rawReq,
+		ClientID: c.ClientID,
+	}
+
+	// Send the request via gRPC.
+	reply, err := c.gRPCClient.Search(context.Background(), payload)
+	for err != nil {
+
+		// Check received gRPC error.
+		stat, ok := status.FromError(err)
+		if ok && (stat.Code() == codes.Unavailable) {
+
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxySearch(), reconnecting...", c.ActualNode, c.ActualAddr))
+
+			err := c.Connect(s.gRPCOptions, s.logger, false)
+			if err != nil {
+				c.Send(err.Error())
+				level.Error(s.logger).Log("msg", "failed too many times to connect to worker or storage, telling client")
+				return true
+			}
+
+			reply, err = c.gRPCClient.Search(context.Background(), payload)
+		} else {
+			c.Send("* BAD Internal server error, sorry. Closing connection.")
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error sending Search() to internal node %s", c.ActualNode),
+				"err", err,
+			)
+			return false
+		}
+	}
+
+	if reply.Status != 0 {
+		c.Send("* BAD Internal server error, sorry. Closing connection.")
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Search() to internal node %s returned error code", c.ActualNode))
+		return false
+	}
+
+	// And send response from worker or storage to client.
+	err = c.Send(reply.Text)
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error sending SEARCH answer to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// ProxyUID tunnels a received UID request by
+// an authorized client to the responsible worker or
+// storage node.
+func (s *service) ProxyUID(c *Connection, rawReq string) bool {
+
+	// Prepare payload to send.
+	payload := &imap.Command{
+		Text:     rawReq,
+		ClientID: c.ClientID,
+	}
+
+	// Send the request via gRPC.
+	reply, err := c.gRPCClient.UID(context.Background(), payload)
+	for err != nil {
+
+		// Check received gRPC error.
+		stat, ok := status.FromError(err)
+		if ok && (stat.Code() == codes.Unavailable) {
+
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyUID(), reconnecting...", c.ActualNode, c.ActualAddr))
+
+			err := c.Connect(s.gRPCOptions, s.logger, false)
+			if err != nil {
+				c.Send(err.Error())
+				level.Error(s.logger).Log("msg", "failed too many times to connect to worker or storage, telling client")
+				return true
+			}
+
+			reply, err = c.gRPCClient.UID(context.Background(), payload)
+		} else {
+			c.Send("* BAD Internal server error, sorry. Closing connection.")
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error sending UID() to internal node %s", c.ActualNode),
+				"err", err,
+			)
+			return false
+		}
+	}
+
+	if reply.Status != 0 {
+		c.Send("* BAD Internal server error, sorry. Closing connection.")
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending UID() to internal node %s returned error code", c.ActualNode))
+		return false
+	}
+
+	// And send response from worker or storage to client.
+	err = c.Send(reply.Text)
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error sending UID answer to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// ProxyCopy tunnels a received COPY request by
+// a client to the responsible worker or storage node.
+func (s *service) ProxyCopy(c *Connection, rawReq string) bool {
+
+	// Prepare payload to send.
+	payload := &imap.Command{
+		Text:     rawReq,
+		ClientID: c.ClientID,
+	}
+
+	// Send the request via gRPC.
+	reply, err := c.gRPCClient.Copy(context.Background(), payload)
+	for err != nil {
+
+		// Check received gRPC error.
+		stat, ok := status.FromError(err)
+		if ok && (stat.Code() == codes.Unavailable) {
+
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyCopy(), reconnecting...", c.ActualNode, c.ActualAddr))
+
+			err := c.Connect(s.gRPCOptions, s.logger, false)
+			if err != nil {
+				c.Send(err.Error())
+				level.Error(s.logger).Log("msg", "failed too many times to connect to worker or storage, telling client")
+				return true
+			}
+
+			reply, err = c.gRPCClient.Copy(context.Background(), payload)
+		} else {
+			c.Send("* BAD Internal server error, sorry. Closing connection.")
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error sending Copy() to internal node %s", c.ActualNode),
+				"err", err,
+			)
+			return false
+		}
+	}
+
+	if reply.Status != 0 {
+		c.Send("* BAD Internal server error, sorry. Closing connection.")
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Copy() to internal node %s returned error code", c.ActualNode))
+		return false
+	}
+
+	// And send response from worker or storage to client.
+	err = c.Send(reply.Text)
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error sending COPY answer to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// ProxyMove tunnels a received MOVE request by
+// a client to the responsible worker or storage node.
+func (s *service) ProxyMove(c *Connection, rawReq string) bool {
+
+	// Prepare payload to send.
+	payload := &imap.Command{
+		Text:     rawReq,
+		ClientID: c.ClientID,
+	}
+
+	// Send the request via gRPC.
+	reply, err := c.gRPCClient.Move(context.Background(), payload)
+	for err != nil {
+
+		// Check received gRPC error.
+		stat, ok := status.FromError(err)
+		if ok && (stat.Code() == codes.Unavailable) {
+
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyMove(), reconnecting...", c.ActualNode, c.ActualAddr))
+
+			err := c.Connect(s.gRPCOptions, s.logger, false)
+			if err != nil {
+				c.Send(err.Error())
+				level.Error(s.logger).Log("msg", "failed too many times to connect to worker or storage, telling client")
+				return true
+			}
+
+			reply, err = c.gRPCClient.Move(context.Background(), payload)
+		} else {
+			c.Send("* BAD Internal server error, sorry. Closing connection.")
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error sending Move() to internal node %s", c.ActualNode),
+				"err", err,
+			)
+			return false
+		}
+	}
+
+	if reply.Status != 0 {
+		c.Send("* BAD Internal server error, sorry. Closing connection.")
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Move() to internal node %s returned error code", c.ActualNode))
+		return false
+	}
+
+	// And send response from worker or storage to client.
+	err = c.Send(reply.Text)
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error sending MOVE answer to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// ProxyRename tunnels a received RENAME request by
+// a client to the responsible worker or storage node.
+func (s *service) ProxyRename(c *Connection, rawReq string) bool {
+
+	// Prepare payload to send.
+	payload := &imap.Command{
+		Text:     rawReq,
+		ClientID: c.ClientID,
+	}
+
+	// Send the request via gRPC.
+	reply, err := c.gRPCClient.Rename(context.Background(), payload)
+	for err != nil {
+
+		// Check received gRPC error.
+		stat, ok := status.FromError(err)
+		if ok && (stat.Code() == codes.Unavailable) {
+
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyRename(), reconnecting...", c.ActualNode, c.ActualAddr))
+
+			err := c.Connect(s.gRPCOptions, s.logger, false)
+			if err != nil {
+				c.Send(err.Error())
+				level.Error(s.logger).Log("msg", "failed too many times to connect to worker or storage, telling client")
+				return true
+			}
+
+			reply, err = c.gRPCClient.Rename(context.Background(), payload)
+		} else {
+			c.Send("* BAD Internal server error, sorry. Closing connection.")
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error sending Rename() to internal node %s", c.ActualNode),
+				"err", err,
+			)
+			return false
+		}
+	}
+
+	if reply.Status != 0 {
+		c.Send("* BAD Internal server error, sorry. Closing connection.")
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Rename() to internal node %s returned error code", c.ActualNode))
+		return false
+	}
+
+	// And send response from worker or storage to client.
+	err = c.Send(reply.Text)
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error sending RENAME answer to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// ProxyExamine tunnels a received EXAMINE request by
+// a client to the responsible worker or storage node.
+func (s *service) ProxyExamine(c *Connection, rawReq string) bool {
+
+	// Prepare payload to send.
+	payload := &imap.Command{
+		Text:     rawReq,
+		ClientID: c.ClientID,
+	}
+
+	// Send the request via gRPC.
+	reply, err := c.gRPCClient.Examine(context.Background(), payload)
+	for err != nil {
+
+		// Check received gRPC error.
+		stat, ok := status.FromError(err)
+		if ok && (stat.Code() == codes.Unavailable) {
+
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyExamine(), reconnecting...", c.ActualNode, c.ActualAddr))
+
+			err := c.Connect(s.gRPCOptions, s.logger, false)
+			if err != nil {
+				c.Send(err.Error())
+				level.Error(s.logger).Log("msg", "failed too many times to connect to worker or storage, telling client")
+				return true
+			}
+
+			reply, err = c.gRPCClient.Examine(context.Background(), payload)
+		} else {
+			c.Send("* BAD Internal server error, sorry. Closing connection.")
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error sending Examine() to internal node %s", c.ActualNode),
+				"err", err,
+			)
+			return false
+		}
+	}
+
+	if reply.Status != 0 {
+		c.Send("* BAD Internal server error, sorry. Closing connection.")
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Examine() to internal node %s returned error code", c.ActualNode))
+		return false
+	}
+
+	// And send response from worker or storage to client.
+	err = c.Send(reply.Text)
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error sending EXAMINE answer to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// ProxyStatus tunnels a received STATUS request by
+// a client to the responsible worker or storage node.
+func (s *service) ProxyStatus(c *Connection, rawReq string) bool {
+
+	// Prepare payload to send.
+	payload := &imap.Command{
+		Text:     rawReq,
+		ClientID: c.ClientID,
+	}
+
+	// Send the request via gRPC.
+	reply, err := c.gRPCClient.Status(context.Background(), payload)
+	for err != nil {
+
+		// Check received gRPC error.
+		stat, ok := status.FromError(err)
+		if ok && (stat.Code() == codes.Unavailable) {
+
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyStatus(), reconnecting...", c.ActualNode, c.ActualAddr))
+
+			err := c.Connect(s.gRPCOptions, s.logger, false)
+			if err != nil {
+				c.Send(err.Error())
+				level.Error(s.logger).Log("msg", "failed too many times to connect to worker or storage, telling client")
+				return true
+			}
+
+			reply, err = c.gRPCClient.Status(context.Background(), payload)
+		} else {
+			c.Send("* BAD Internal server error, sorry. Closing connection.")
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error sending Status() to internal node %s", c.ActualNode),
+				"err", err,
+			)
+			return false
+		}
+	}
+
+	if reply.Status != 0 {
+		c.Send("* BAD Internal server error, sorry. Closing connection.")
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Status() to internal node %s returned error code", c.ActualNode))
+		return false
+	}
+
+	// And send response from worker or storage to client.
+	err = c.Send(reply.Text)
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error sending STATUS answer to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// ProxyClose tunnels a received CLOSE request by
+// a client to the responsible worker or storage node.
+func (s *service) ProxyClose(c *Connection, rawReq string) bool {
+
+	// Prepare payload to send.
+	payload := &imap.Command{
+		Text:     rawReq,
+		ClientID: c.ClientID,
+	}
+
+	// Send the request via gRPC.
+	reply, err := c.gRPCClient.CloseMailbox(context.Background(), payload)
+	for err != nil {
+
+		// Check received gRPC error.
+		stat, ok := status.FromError(err)
+		if ok && (stat.Code() == codes.Unavailable) {
+
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyClose(), reconnecting...", c.ActualNode, c.ActualAddr))
+
+			err := c.Connect(s.gRPCOptions, s.logger, false)
+			if err != nil {
+				c.Send(err.Error())
+				level.Error(s.logger).Log("msg", "failed too many times to connect to worker or storage, telling client")
+				return true
+			}
+
+			reply, err = c.gRPCClient.CloseMailbox(context.Background(), payload)
+		} else {
+			c.Send("* BAD Internal server error, sorry. Closing connection.")
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error sending CloseMailbox() to internal node %s", c.ActualNode),
+				"err", err,
+			)
+			return false
+		}
+	}
+
+	if reply.Status != 0 {
+		c.Send("* BAD Internal server error, sorry. Closing connection.")
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending CloseMailbox() to internal node %s returned error code", c.ActualNode))
+		return false
+	}
+
+	// And send response from worker or storage to client.
+	err = c.Send(reply.Text)
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error sending CLOSE answer to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// ProxyUnselect tunnels a received UNSELECT request by
+// a client to the responsible worker or storage node.
+func (s *service) ProxyUnselect(c *Connection, rawReq string) bool {
+
+	// Prepare payload to send.
+	payload := &imap.Command{
+		Text:     rawReq,
+		ClientID: c.ClientID,
+	}
+
+	// Send the request via gRPC.
+	reply, err := c.gRPCClient.Unselect(context.Background(), payload)
+	for err != nil {
+
+		// Check received gRPC error.
+		stat, ok := status.FromError(err)
+		if ok && (stat.Code() == codes.Unavailable) {
+
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyUnselect(), reconnecting...", c.ActualNode, c.ActualAddr))
+
+			err := c.Connect(s.gRPCOptions, s.logger, false)
+			if err != nil {
+				c.Send(err.Error())
+				level.Error(s.logger).Log("msg", "failed too many times to connect to worker or storage, telling client")
+				return true
+			}
+
+			reply, err = c.gRPCClient.Unselect(context.Background(), payload)
+		} else {
+			c.Send("* BAD Internal server error, sorry. Closing connection.")
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error sending Unselect() to internal node %s", c.ActualNode),
+				"err", err,
+			)
+			return false
+		}
+	}
+
+	if reply.Status != 0 {
+		c.Send("* BAD Internal server error, sorry. Closing connection.")
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Unselect() to internal node %s returned error code", c.ActualNode))
+		return false
+	}
+
+	// And send response from worker or storage to client.
+	err = c.Send(reply.Text)
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error sending UNSELECT answer to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	return true
+}
+
+// ProxyCheck tunnels a received CHECK request by
+// a client to the responsible worker or storage node.
+func (s *service) ProxyCheck(c *Connection, rawReq string) bool {
+
+	// Prepare payload to send.
+	payload := &imap.Command{
+		Text:     rawReq,
+		ClientID: c.ClientID,
+	}
+
+	// Send the request via gRPC.
+	reply, err := c.gRPCClient.Check(context.Background(), payload)
 	for err != nil {
 
 		// Check received gRPC error.
 		stat, ok := status.FromError(err)
 		if ok && (stat.Code() == codes.Unavailable) {
 
-			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during Prepare(), reconnecting...", c.ActualNode, c.ActualAddr))
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyCheck(), reconnecting...", c.ActualNode, c.ActualAddr))
 
 			err := c.Connect(s.gRPCOptions, s.logger, false)
 			if err != nil {
@@ -686,28 +2925,28 @@ func (s *service) Login(c *Connection, req *imap.Request) bool {
 				return true
 			}
 
-			conf, err = c.gRPCClient.Prepare(context.Background(), payload)
+			reply, err = c.gRPCClient.Check(context.Background(), payload)
 		} else {
 			c.Send("* BAD Internal server error, sorry. Closing connection.")
 			level.Error(s.logger).Log(
-				"msg", fmt.Sprintf("error sending Prepare() to internal node %s", c.ActualNode),
+				"msg", fmt.Sprintf("error sending Check() to internal node %s", c.ActualNode),
 				"err", err,
 			)
 			return false
 		}
 	}
 
-	if conf.Status != 0 {
+	if reply.Status != 0 {
 		c.Send("* BAD Internal server error, sorry. Closing connection.")
-		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Prepare() to internal node %s returned error code", c.ActualNode))
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Check() to internal node %s returned error code", c.ActualNode))
 		return false
 	}
 
-	// Signal success to client.
-	err = c.Send(fmt.Sprintf("%s OK LOGIN completed", req.Tag))
+	// And send response from worker or storage to client.
+	err = c.Send(reply.Text)
 	if err != nil {
 		level.Error(s.logger).Log(
-			"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+			"msg", fmt.Sprintf("error sending CHECK answer to client %s", c.ClientAddr),
 			"err", err,
 		)
 		return false
@@ -716,32 +2955,55 @@ func (s *service) Login(c *Connection, req *imap.Request) bool {
 	return true
 }
 
-// StartTLS states on IMAP STARTTLS command
-// that current connection is already encrypted.
-func (s *service) StartTLS(c *Connection, req *imap.Request) bool {
+// ProxyNoop tunnels a received NOOP request by
+// a client to the responsible worker or storage node.
+func (s *service) ProxyNoop(c *Connection, rawReq string) bool {
 
-	if len(req.Payload) > 0 {
+	// Prepare payload to send.
+	payload := &imap.Command{
+		Text:     rawReq,
+		ClientID: c.ClientID,
+	}
 
-		// If payload was not empty to STARTTLS command,
-		// this is a client error. Return BAD statement.
-		err := c.Send(fmt.Sprintf("%s BAD Command STARTTLS was sent with extra parameters", req.Tag))
-		if err != nil {
+	// Send the request via gRPC.
+	reply, err := c.gRPCClient.Noop(context.Background(), payload)
+	for err != nil {
+
+		// Check received gRPC error.
+		stat, ok := status.FromError(err)
+		if ok && (stat.Code() == codes.Unavailable) {
+
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyNoop(), reconnecting...", c.ActualNode, c.ActualAddr))
+
+			err := c.Connect(s.gRPCOptions, s.logger, false)
+			if err != nil {
+				c.Send(err.Error())
+				level.Error(s.logger).Log("msg", "failed too many times to connect to worker or storage, telling client")
+				return true
+			}
+
+			reply, err = c.gRPCClient.Noop(context.Background(), payload)
+		} else {
+			c.Send("* BAD Internal server error, sorry. Closing connection.")
 			level.Error(s.logger).Log(
-				"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+				"msg", fmt.Sprintf("error sending Noop() to internal node %s", c.ActualNode),
 				"err", err,
 			)
 			return false
 		}
+	}
 
-		return true
+	if reply.Status != 0 {
+		c.Send("* BAD Internal server error, sorry. Closing connection.")
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Noop() to internal node %s returned error code", c.ActualNode))
+		return false
 	}
 
-	// As the connection is already TLS encrypted,
-	// tell client that a TLS session is active.
-	err := c.Send(fmt.Sprintf("%s BAD TLS is already active", req.Tag))
+	// And send response from worker or storage to client.
+	err = c.Send(reply.Text)
 	if err != nil {
 		level.Error(s.logger).Log(
-			"msg", fmt.Sprintf("error while sending text to client %s", c.ClientAddr),
+			"msg", fmt.Sprintf("error sending NOOP answer to client %s", c.ClientAddr),
 			"err", err,
 		)
 		return false
@@ -750,10 +3012,11 @@ func (s *service) StartTLS(c *Connection, req *imap.Request) bool {
 	return true
 }
 
-// ProxySelect tunnels a received SELECT request by
-// an authorized client to the responsible worker or
-// storage node.
-func (s *service) ProxySelect(c *Connection, rawReq string) bool {
+// ProxyIdle tunnels a received IDLE request by a client
+// to the responsible worker or storage node. All updates
+// streamed back by the node are forwarded to the client
+// until it ends IDLE by sending DONE.
+func (s *service) ProxyIdle(c *Connection, rawReq string) bool {
 
 	// Prepare payload to send.
 	payload := &imap.Command{
@@ -761,15 +3024,20 @@ func (s *service) ProxySelect(c *Connection, rawReq string) bool {
 		ClientID: c.ClientID,
 	}
 
+	// The node keeps streaming until the
+	// context of the stream is cancelled.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	// Send the request via gRPC.
-	reply, err := c.gRPCClient.Select(context.Background(), payload)
+	stream, err := c.gRPCClient.Idle(ctx, payload)
 	for err != nil {
 
 		// Check received gRPC error.
 		stat, ok := status.FromError(err)
 		if ok && (stat.Code() == codes.Unavailable) {
 
-			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxySelect(), reconnecting...", c.ActualNode, c.ActualAddr))
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyIdle(), reconnecting...", c.ActualNode, c.ActualAddr))
 
 			err := c.Connect(s.gRPCOptions, s.logger, false)
 			if err != nil {
@@ -778,28 +3046,101 @@ func (s *service) ProxySelect(c *Connection, rawReq string) bool {
 				return true
 			}
 
-			reply, err = c.gRPCClient.Select(context.Background(), payload)
+			stream, err = c.gRPCClient.Idle(ctx, payload)
 		} else {
 			c.Send("* BAD Internal server error, sorry. Closing connection.")
 			level.Error(s.logger).Log(
-				"msg", fmt.Sprintf("error sending Select() to internal node %s", c.ActualNode),
+				"msg", fmt.Sprintf("error sending Idle() to internal node %s", c.ActualNode),
 				"err", err,
 			)
 			return false
 		}
 	}
 
-	if reply.Status != 0 {
+	// The first reply either confirms IDLE via
+	// a continuation request or rejects it.
+	reply, err := stream.Recv()
+	if err != nil {
 		c.Send("* BAD Internal server error, sorry. Closing connection.")
-		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Select() to internal node %s returned error code", c.ActualNode))
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error receiving Idle() reply from internal node %s", c.ActualNode),
+			"err", err,
+		)
 		return false
 	}
 
-	// And send response from worker or storage to client.
 	err = c.Send(reply.Text)
 	if err != nil {
 		level.Error(s.logger).Log(
-			"msg", fmt.Sprintf("error sending SELECT answer to client %s", c.ClientAddr),
+			"msg", fmt.Sprintf("error sending IDLE answer to client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	if reply.Text != "+ idling" {
+		return true
+	}
+
+	// Forward all updates until the stream ends.
+	done := make(chan struct{})
+	go func() {
+
+		defer close(done)
+
+		for {
+
+			reply, err := stream.Recv()
+			if err != nil {
+				return
+			}
+
+			err = c.Send(reply.Text)
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// Wait for the client to end IDLE.
+	line, err := c.Receive()
+
+	// Stop the stream and wait for forwarding to
+	// finish before answering the client.
+	cancel()
+	<-done
+
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error receiving DONE from client %s", c.ClientAddr),
+			"err", err,
+		)
+		return false
+	}
+
+	req, err := imap.ParseRequest(rawReq)
+	if err != nil {
+		return false
+	}
+
+	if strings.ToUpper(line) != "DONE" {
+
+		err = c.Send(fmt.Sprintf("%s BAD Expected DONE to end IDLE", req.Tag))
+		if err != nil {
+			level.Error(s.logger).Log(
+				"msg", fmt.Sprintf("error sending IDLE answer to client %s", c.ClientAddr),
+				"err", err,
+			)
+			return false
+		}
+
+		return true
+	}
+
+	err = c.Send(fmt.Sprintf("%s OK IDLE terminated", req.Tag))
+	if err != nil {
+		level.Error(s.logger).Log(
+			"msg", fmt.Sprintf("error sending IDLE answer to client %s", c.ClientAddr),
 			"err", err,
 		)
 		return false
@@ -808,10 +3149,9 @@ func (s *service) ProxySelect(c *Connection, rawReq string) bool {
 	return true
 }
 
-// ProxyCreate tunnels a received CREATE request by
-// an authorized client to the responsible worker or
-// storage node.
-func (s *service) ProxyCreate(c *Connection, rawReq string) bool {
+// ProxySubscribe tunnels a received SUBSCRIBE request by
+// a client to the responsible worker or storage node.
+func (s *service) ProxySubscribe(c *Connection, rawReq string) bool {
 
 	// Prepare payload to send.
 	payload := &imap.Command{
@@ -820,14 +3160,14 @@ func (s *service) ProxyCreate(c *Connection, rawReq string) bool {
 	}
 
 	// Send the request via gRPC.
-	reply, err := c.gRPCClient.Create(context.Background(), payload)
+	reply, err := c.gRPCClient.Subscribe(context.Background(), payload)
 	for err != nil {
 
 		// Check received gRPC error.
 		stat, ok := status.FromError(err)
 		if ok && (stat.Code() == codes.Unavailable) {
 
-			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyCreate(), reconnecting...", c.ActualNode, c.ActualAddr))
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxySubscribe(), reconnecting...", c.ActualNode, c.ActualAddr))
 
 			err := c.Connect(s.gRPCOptions, s.logger, false)
 			if err != nil {
@@ -836,11 +3176,11 @@ func (s *service) ProxyCreate(c *Connection, rawReq string) bool {
 				return true
 			}
 
-			reply, err = c.gRPCClient.Create(context.Background(), payload)
+			reply, err = c.gRPCClient.Subscribe(context.Background(), payload)
 		} else {
 			c.Send("* BAD Internal server error, sorry. Closing connection.")
 			level.Error(s.logger).Log(
-				"msg", fmt.Sprintf("error sending Create() to internal node %s", c.ActualNode),
+				"msg", fmt.Sprintf("error sending Subscribe() to internal node %s", c.ActualNode),
 				"err", err,
 			)
 			return false
@@ -849,7 +3189,7 @@ func (s *service) ProxyCreate(c *Connection, rawReq string) bool {
 
 	if reply.Status != 0 {
 		c.Send("* BAD Internal server error, sorry. Closing connection.")
-		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Create() to internal node %s returned error code", c.ActualNode))
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Subscribe() to internal node %s returned error code", c.ActualNode))
 		return false
 	}
 
@@ -857,7 +3197,7 @@ func (s *service) ProxyCreate(c *Connection, rawReq string) bool {
 	err = c.Send(reply.Text)
 	if err != nil {
 		level.Error(s.logger).Log(
-			"msg", fmt.Sprintf("error sending CREATE answer to client %s", c.ClientAddr),
+			"msg", fmt.Sprintf("error sending SUBSCRIBE answer to client %s", c.ClientAddr),
 			"err", err,
 		)
 		return false
@@ -866,10 +3206,9 @@ func (s *service) ProxyCreate(c *Connection, rawReq string) bool {
 	return true
 }
 
-// ProxyDelete tunnels a received DELETE request by
-// an authorized client to the responsible worker or
-// storage node.
-func (s *service) ProxyDelete(c *Connection, rawReq string) bool {
+// ProxyUnsubscribe tunnels a received UNSUBSCRIBE request by
+// a client to the responsible worker or storage node.
+func (s *service) ProxyUnsubscribe(c *Connection, rawReq string) bool {
 
 	// Prepare payload to send.
 	payload := &imap.Command{
@@ -878,14 +3217,14 @@ func (s *service) ProxyDelete(c *Connection, rawReq string) bool {
 	}
 
 	// Send the request via gRPC.
-	reply, err := c.gRPCClient.Delete(context.Background(), payload)
+	reply, err := c.gRPCClient.Unsubscribe(context.Background(), payload)
 	for err != nil {
 
 		// Check received gRPC error.
 		stat, ok := status.FromError(err)
 		if ok && (stat.Code() == codes.Unavailable) {
 
-			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyDelete(), reconnecting...", c.ActualNode, c.ActualAddr))
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyUnsubscribe(), reconnecting...", c.ActualNode, c.ActualAddr))
 
 			err := c.Connect(s.gRPCOptions, s.logger, false)
 			if err != nil {
@@ -894,11 +3233,11 @@ func (s *service) ProxyDelete(c *Connection, rawReq string) bool {
 				return true
 			}
 
-			reply, err = c.gRPCClient.Delete(context.Background(), payload)
+			reply, err = c.gRPCClient.Unsubscribe(context.Background(), payload)
 		} else {
 			c.Send("* BAD Internal server error, sorry. Closing connection.")
 			level.Error(s.logger).Log(
-				"msg", fmt.Sprintf("error sending Delete() to internal node %s", c.ActualNode),
+				"msg", fmt.Sprintf("error sending Unsubscribe() to internal node %s", c.ActualNode),
 				"err", err,
 			)
 			return false
@@ -907,7 +3246,7 @@ func (s *service) ProxyDelete(c *Connection, rawReq string) bool {
 
 	if reply.Status != 0 {
 		c.Send("* BAD Internal server error, sorry. Closing connection.")
-		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Delete() to internal node %s returned error code", c.ActualNode))
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Unsubscribe() to internal node %s returned error code", c.ActualNode))
 		return false
 	}
 
@@ -915,7 +3254,7 @@ func (s *service) ProxyDelete(c *Connection, rawReq string) bool {
 	err = c.Send(reply.Text)
 	if err != nil {
 		level.Error(s.logger).Log(
-			"msg", fmt.Sprintf("error sending DELETE answer to client %s", c.ClientAddr),
+			"msg", fmt.Sprintf("error sending UNSUBSCRIBE answer to client %s", c.ClientAddr),
 			"err", err,
 		)
 		return false
@@ -924,10 +3263,9 @@ func (s *service) ProxyDelete(c *Connection, rawReq string) bool {
 	return true
 }
 
-// ProxyList tunnels a received LIST request by
-// an authorized client to the responsible worker or
-// storage node.
-func (s *service) ProxyList(c *Connection, rawReq string) bool {
+// ProxyLsub tunnels a received LSUB request by
+// a client to the responsible worker or storage node.
+func (s *service) ProxyLsub(c *Connection, rawReq string) bool {
 
 	// Prepare payload to send.
 	payload := &imap.Command{
@@ -936,14 +3274,14 @@ func (s *service) ProxyList(c *Connection, rawReq string) bool {
 	}
 
 	// Send the request via gRPC.
-	reply, err := c.gRPCClient.List(context.Background(), payload)
+	reply, err := c.gRPCClient.Lsub(context.Background(), payload)
 	for err != nil {
 
 		// Check received gRPC error.
 		stat, ok := status.FromError(err)
 		if ok && (stat.Code() == codes.Unavailable) {
 
-			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyList(), reconnecting...", c.ActualNode, c.ActualAddr))
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyLsub(), reconnecting...", c.ActualNode, c.ActualAddr))
 
 			err := c.Connect(s.gRPCOptions, s.logger, false)
 			if err != nil {
@@ -952,11 +3290,11 @@ func (s *service) ProxyList(c *Connection, rawReq string) bool {
 				return true
 			}
 
-			reply, err = c.gRPCClient.List(context.Background(), payload)
+			reply, err = c.gRPCClient.Lsub(context.Background(), payload)
 		} else {
 			c.Send("* BAD Internal server error, sorry. Closing connection.")
 			level.Error(s.logger).Log(
-				"msg", fmt.Sprintf("error sending List() to internal node %s", c.ActualNode),
+				"msg", fmt.Sprintf("error sending Lsub() to internal node %s", c.ActualNode),
 				"err", err,
 			)
 			return false
@@ -965,7 +3303,7 @@ func (s *service) ProxyList(c *Connection, rawReq string) bool {
 
 	if reply.Status != 0 {
 		c.Send("* BAD Internal server error, sorry. Closing connection.")
-		level.Error(s.logger).Log("msg", fmt.Sprintf("sending List() to internal node %s returned error code", c.ActualNode))
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Lsub() to internal node %s returned error code", c.ActualNode))
 		return false
 	}
 
@@ -973,7 +3311,7 @@ func (s *service) ProxyList(c *Connection, rawReq string) bool {
 	err = c.Send(reply.Text)
 	if err != nil {
 		level.Error(s.logger).Log(
-			"msg", fmt.Sprintf("error sending LIST answer to client %s", c.ClientAddr),
+			"msg", fmt.Sprintf("error sending LSUB answer to client %s", c.ClientAddr),
 			"err", err,
 		)
 		return false
@@ -982,10 +3320,9 @@ func (s *service) ProxyList(c *Connection, rawReq string) bool {
 	return true
 }
 
-// ProxyAppend tunnels a received APPEND request by
-// an authorized client to the responsible worker or
-// storage node.
-func (s *service) ProxyAppend(c *Connection, rawReq string) bool {
+// ProxyEnable tunnels a received ENABLE request by
+// a client to the responsible worker or storage node.
+func (s *service) ProxyEnable(c *Connection, rawReq string) bool {
 
 	// Prepare payload to send.
 	payload := &imap.Command{
@@ -993,15 +3330,15 @@ func (s *service) ProxyAppend(c *Connection, rawReq string) bool {
 		ClientID: c.ClientID,
 	}
 
-	// Send the begin part of the APPEND request via gRPC.
-	await, err := c.gRPCClient.AppendBegin(context.Background(), payload)
+	// Send the request via gRPC.
+	reply, err := c.gRPCClient.Enable(context.Background(), payload)
 	for err != nil {
 
 		// Check received gRPC error.
 		stat, ok := status.FromError(err)
 		if ok && (stat.Code() == codes.Unavailable) {
 
-			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during begin part of ProxyAppend(), reconnecting...", c.ActualNode, c.ActualAddr))
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyEnable(), reconnecting...", c.ActualNode, c.ActualAddr))
 
 			err := c.Connect(s.gRPCOptions, s.logger, false)
 			if err != nil {
@@ -1010,142 +3347,77 @@ func (s *service) ProxyAppend(c *Connection, rawReq string) bool {
 				return true
 			}
 
-			await, err = c.gRPCClient.AppendBegin(context.Background(), payload)
+			reply, err = c.gRPCClient.Enable(context.Background(), payload)
 		} else {
 			c.Send("* BAD Internal server error, sorry. Closing connection.")
 			level.Error(s.logger).Log(
-				"msg", fmt.Sprintf("error sending AppendBegin() to internal node %s", c.ActualNode),
+				"msg", fmt.Sprintf("error sending Enable() to internal node %s", c.ActualNode),
 				"err", err,
 			)
 			return false
 		}
 	}
 
-	if await.Status != 0 {
+	if reply.Status != 0 {
 		c.Send("* BAD Internal server error, sorry. Closing connection.")
-		level.Error(s.logger).Log("msg", fmt.Sprintf("sending AppendBegin() to internal node %s returned error code", c.ActualNode))
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Enable() to internal node %s returned error code", c.ActualNode))
 		return false
 	}
 
-	// Pass on either error or continuation response to client.
-	err = c.Send(await.Text)
+	// And send response from worker or storage to client.
+	err = c.Send(reply.Text)
 	if err != nil {
-
 		level.Error(s.logger).Log(
-			"msg", fmt.Sprintf("error sending begin APPEND answer to client %s", c.ClientAddr),
+			"msg", fmt.Sprintf("error sending ENABLE answer to client %s", c.ClientAddr),
 			"err", err,
 		)
-
-		// Signal connected internal node that client aborted APPEND.
-		conf, err := c.gRPCClient.AppendAbort(context.Background(), &imap.Abort{
-			ClientID: c.ClientID,
-		})
-
-		if err != nil {
-			level.Error(s.logger).Log(
-				"msg", fmt.Sprintf("error sending AppendAbort() to internal node %s", c.ActualNode),
-				"err", err,
-			)
-		}
-		if conf.Status != 0 {
-			level.Error(s.logger).Log("msg", fmt.Sprintf("sending AppendAbort() to internal node %s returned error code", c.ActualNode))
-		}
-
 		return false
 	}
 
-	// Check if seen response was no continuation response.
-	// In such case, simply return as this function is done here.
-	if await.Text != "+ Ready for literal data" {
-		return true
-	}
-
-	// Reserve space for exact amount of expected data.
-	msgBuffer := make([]byte, await.NumBytes)
-
-	// Read in that amount from connection to client.
-	_, err = io.ReadFull(c.IncReader, msgBuffer)
-	if err != nil {
-
-		level.Error(s.logger).Log(
-			"msg", fmt.Sprintf("error reading mail content from client %s", c.ClientAddr),
-			"err", err,
-		)
-
-		// Signal connected internal node that client aborted APPEND.
-		conf, err := c.gRPCClient.AppendAbort(context.Background(), &imap.Abort{
-			ClientID: c.ClientID,
-		})
+	return true
+}
 
-		if err != nil {
-			level.Error(s.logger).Log(
-				"msg", fmt.Sprintf("error sending AppendAbort() to internal node %s", c.ActualNode),
-				"err", err,
-			)
-		}
-		if conf.Status != 0 {
-			level.Error(s.logger).Log("msg", fmt.Sprintf("sending AppendAbort() to internal node %s returned error code", c.ActualNode))
-		}
+// ProxyNamespace tunnels a received NAMESPACE request by
+// a client to the responsible worker or storage node.
+func (s *service) ProxyNamespace(c *Connection, rawReq string) bool {
 
-		return false
+	// Prepare payload to send.
+	payload := &imap.Command{
+		Text:     rawReq,
+		ClientID: c.ClientID,
 	}
 
-	// Expect trailing '\r\n' after message content.
-	empty, err := c.Receive()
-	if (err != nil) || (empty != "") {
-
-		retValue := false
-
-		if err == nil {
+	// Send the request via gRPC.
+	reply, err := c.gRPCClient.Namespace(context.Background(), payload)
+	for err != nil {
 
-			// Received string was not empty. Tell client
-			// and prepare error message.
-			c.Send("* BAD Literal message of APPEND was not suffixed with CRLF.")
-			err = fmt.Errorf("received message other than CRLF")
-			retValue = true
-		}
+		// Check received gRPC error.
+		stat, ok := status.FromError(err)
+		if ok && (stat.Code() == codes.Unavailable) {
 
-		level.Warn(s.logger).Log(
-			"msg", fmt.Sprintf("failed at expected CRLF after message from %s", c.ClientAddr),
-			"err", err,
-		)
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyNamespace(), reconnecting...", c.ActualNode, c.ActualAddr))
 
-		// Signal connected internal node that client aborted APPEND.
-		conf, err := c.gRPCClient.AppendAbort(context.Background(), &imap.Abort{
-			ClientID: c.ClientID,
-		})
+			err := c.Connect(s.gRPCOptions, s.logger, false)
+			if err != nil {
+				c.Send(err.Error())
+				level.Error(s.logger).Log("msg", "failed too many times to connect to worker or storage, telling client")
+				return true
+			}
 
-		if err != nil {
+			reply, err = c.gRPCClient.Namespace(context.Background(), payload)
+		} else {
+			c.Send("* BAD Internal server error, sorry. Closing connection.")
 			level.Error(s.logger).Log(
-				"msg", fmt.Sprintf("error sending AppendAbort() to internal node %s", c.ActualNode),
+				"msg", fmt.Sprintf("error sending Namespace() to internal node %s", c.ActualNode),
 				"err", err,
 			)
+			return false
 		}
-		if conf.Status != 0 {
-			level.Error(s.logger).Log("msg", fmt.Sprintf("sending AppendAbort() to internal node %s returned error code", c.ActualNode))
-		}
-
-		return retValue
 	}
 
-	// Send the end part of APPEND request via gRPC.
-	reply, err := c.gRPCClient.AppendEnd(context.Background(), &imap.MailFile{
-		Content:  msgBuffer,
-		ClientID: c.ClientID,
-	})
-	if (err != nil) || (reply.Status != 0) {
-
+	if reply.Status != 0 {
 		c.Send("* BAD Internal server error, sorry. Closing connection.")
-
-		if err != nil {
-			level.Error(s.logger).Log(
-				"msg", fmt.Sprintf("error sending AppendEnd() to internal node %s", c.ActualNode),
-				"err", err,
-			)
-		} else if reply.Status != 0 {
-			level.Error(s.logger).Log("msg", fmt.Sprintf("sending AppendEnd() to internal node %s returned error code", c.ActualNode))
-		}
-
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Namespace() to internal node %s returned error code", c.ActualNode))
 		return false
 	}
 
@@ -1153,7 +3425,7 @@ func (s *service) ProxyAppend(c *Connection, rawReq string) bool {
 	err = c.Send(reply.Text)
 	if err != nil {
 		level.Error(s.logger).Log(
-			"msg", fmt.Sprintf("error sending end APPEND answer to client %s", c.ClientAddr),
+			"msg", fmt.Sprintf("error sending NAMESPACE answer to client %s", c.ClientAddr),
 			"err", err,
 		)
 		return false
@@ -1162,10 +3434,10 @@ func (s *service) ProxyAppend(c *Connection, rawReq string) bool {
 	return true
 }
 
-// ProxyExpunge tunnels a received EXPUNGE request by
+// ProxySort tunnels a received SORT request by
 // an authorized client to the responsible worker or
 // storage node.
-func (s *service) ProxyExpunge(c *Connection, rawReq string) bool {
+func (s *service) ProxySort(c *Connection, rawReq string) bool {
 
 	// Prepare payload to send.
 	payload := &imap.Command{
@@ -1174,14 +3446,14 @@ func (s *service) ProxyExpunge(c *Connection, rawReq string) bool {
 	}
 
 	// Send the request via gRPC.
-	reply, err := c.gRPCClient.Expunge(context.Background(), payload)
+	reply, err := c.gRPCClient.Sort(context.Background(), payload)
 	for err != nil {
 
 		// Check received gRPC error.
 		stat, ok := status.FromError(err)
 		if ok && (stat.Code() == codes.Unavailable) {
 
-			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyExpunge(), reconnecting...", c.ActualNode, c.ActualAddr))
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxySort(), reconnecting...", c.ActualNode, c.ActualAddr))
 
 			err := c.Connect(s.gRPCOptions, s.logger, false)
 			if err != nil {
@@ -1190,11 +3462,11 @@ func (s *service) ProxyExpunge(c *Connection, rawReq string) bool {
 				return true
 			}
 
-			reply, err = c.gRPCClient.Expunge(context.Background(), payload)
+			reply, err = c.gRPCClient.Sort(context.Background(), payload)
 		} else {
 			c.Send("* BAD Internal server error, sorry. Closing connection.")
 			level.Error(s.logger).Log(
-				"msg", fmt.Sprintf("error sending Expunge() to internal node %s", c.ActualNode),
+				"msg", fmt.Sprintf("error sending Sort() to internal node %s", c.ActualNode),
 				"err", err,
 			)
 			return false
@@ -1203,7 +3475,7 @@ func (s *service) ProxyExpunge(c *Connection, rawReq string) bool {
 
 	if reply.Status != 0 {
 		c.Send("* BAD Internal server error, sorry. Closing connection.")
-		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Expunge() to internal node %s returned error code", c.ActualNode))
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Sort() to internal node %s returned error code", c.ActualNode))
 		return false
 	}
 
@@ -1211,7 +3483,7 @@ func (s *service) ProxyExpunge(c *Connection, rawReq string) bool {
 	err = c.Send(reply.Text)
 	if err != nil {
 		level.Error(s.logger).Log(
-			"msg", fmt.Sprintf("error sending EXPUNGE answer to client %s", c.ClientAddr),
+			"msg", fmt.Sprintf("error sending SORT answer to client %s", c.ClientAddr),
 			"err", err,
 		)
 		return false
@@ -1220,10 +3492,10 @@ func (s *service) ProxyExpunge(c *Connection, rawReq string) bool {
 	return true
 }
 
-// ProxyStore tunnels a received STORE request by
+// ProxyThread tunnels a received THREAD request by
 // an authorized client to the responsible worker or
 // storage node.
-func (s *service) ProxyStore(c *Connection, rawReq string) bool {
+func (s *service) ProxyThread(c *Connection, rawReq string) bool {
 
 	// Prepare payload to send.
 	payload := &imap.Command{
@@ -1232,14 +3504,14 @@ func (s *service) ProxyStore(c *Connection, rawReq string) bool {
 	}
 
 	// Send the request via gRPC.
-	reply, err := c.gRPCClient.Store(context.Background(), payload)
+	reply, err := c.gRPCClient.Thread(context.Background(), payload)
 	for err != nil {
 
 		// Check received gRPC error.
 		stat, ok := status.FromError(err)
 		if ok && (stat.Code() == codes.Unavailable) {
 
-			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyStore(), reconnecting...", c.ActualNode, c.ActualAddr))
+			level.Debug(s.logger).Log("msg", fmt.Sprintf("%s (%s) unavailable during ProxyThread(), reconnecting...", c.ActualNode, c.ActualAddr))
 
 			err := c.Connect(s.gRPCOptions, s.logger, false)
 			if err != nil {
@@ -1248,11 +3520,11 @@ func (s *service) ProxyStore(c *Connection, rawReq string) bool {
 				return true
 			}
 
-			reply, err = c.gRPCClient.Store(context.Background(), payload)
+			reply, err = c.gRPCClient.Thread(context.Background(), payload)
 		} else {
 			c.Send("* BAD Internal server error, sorry. Closing connection.")
 			level.Error(s.logger).Log(
-				"msg", fmt.Sprintf("error sending Store() to internal node %s", c.ActualNode),
+				"msg", fmt.Sprintf("error sending Thread() to internal node %s", c.ActualNode),
 				"err", err,
 			)
 			return false
@@ -1261,7 +3533,7 @@ func (s *service) ProxyStore(c *Connection, rawReq string) bool {
 
 	if reply.Status != 0 {
 		c.Send("* BAD Internal server error, sorry. Closing connection.")
-		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Store() to internal node %s returned error code", c.ActualNode))
+		level.Error(s.logger).Log("msg", fmt.Sprintf("sending Thread() to internal node %s returned error code", c.ActualNode))
 		return false
 	}
 
@@ -1269,7 +3541,7 @@ func (s *service) ProxyStore(c *Connection, rawReq string) bool {
 	err = c.Send(reply.Text)
 	if err != nil {
 		level.Error(s.logger).Log(
-			"msg", fmt.Sprintf("error sending STORE answer to client %s", c.ClientAddr),
+			"msg", fmt.Sprintf("error sending THREAD answer to client %s", c.ClientAddr),
 			"err", err,
 		)
 		return false
@@ -1277,3 +3549,11 @@ func (s *service) ProxyStore(c *Connection, rawReq string) bool {
 
 	return true
 }
+
+// init registers the capabilities concerning
+// authentication of client connections.
+func init() {
+	imap.RegisterCapability(&imap.Capability{Name: "STARTTLS", PreAuth: true, Security: imap.CleartextOnly, Commands: []string{imap.CommandStartTLS}})
+	imap.RegisterCapability(&imap.Capability{Name: "LOGINDISABLED", PreAuth: true, Security: imap.CleartextOnly})
+	imap.RegisterCapability(&imap.Capability{Name: "SASL-IR", PreAuth: true})
+}
@@ -79,11 +79,11 @@ var listTests = []struct {
 	outTwo string
 }{
 	{"a LOGIN user1 password1", "a OK LOGIN completed", "a OK LOGIN completed"},
-	{"b LIST \"\" *", "* LIST () \".\" INBOX\r\n* LIST () \".\" university\r\nb OK LIST completed", "* LIST () \".\" university\r\n* LIST () \".\" INBOX\r\nb OK LIST completed"},
+	{"b LIST \"\" *", "* LIST (\\HasNoChildren) \".\" INBOX\r\n* LIST (\\HasNoChildren) \".\" university\r\nb OK LIST completed", "* LIST (\\HasNoChildren) \".\" INBOX\r\n* LIST (\\HasNoChildren) \".\" university\r\nb OK LIST completed"},
 	{"c CREATE university.Modul1", "c OK CREATE completed", "c OK CREATE completed"},
-	{"d LIST \"\" %", "* LIST () \".\" INBOX\r\n* LIST () \".\" university\r\nd OK LIST completed", "* LIST () \".\" university\r\n* LIST () \".\" INBOX\r\nd OK LIST completed"},
+	{"d LIST \"\" %", "* LIST (\\HasNoChildren) \".\" INBOX\r\n* LIST (\\HasChildren) \".\" university\r\nd OK LIST completed", "* LIST (\\HasNoChildren) \".\" INBOX\r\n* LIST (\\HasChildren) \".\" university\r\nd OK LIST completed"},
 	{"e DELETE university", "e OK DELETE completed", "e OK DELETE completed"},
-	{"f LIST \"\" *", "* LIST () \".\" INBOX\r\n* LIST () \".\" university.Modul1\r\nf OK LIST completed", "* LIST () \".\" university.Modul1\r\n* LIST () \".\" INBOX\r\nf OK LIST completed"},
+	{"f LIST \"\" *", "* LIST (\\HasNoChildren) \".\" INBOX\r\n* LIST (\\Noselect \\HasChildren) \".\" university\r\n* LIST (\\HasNoChildren) \".\" university.Modul1\r\nf OK LIST completed", "* LIST (\\HasNoChildren) \".\" INBOX\r\n* LIST (\\Noselect \\HasChildren) \".\" university\r\n* LIST (\\HasNoChildren) \".\" university.Modul1\r\nf OK LIST completed"},
 	{"g LOGOUT", "* BYE Terminating connection\r\ng OK LOGOUT completed", "* BYE Terminating connection\r\ng OK LOGOUT completed"},
 }
 
@@ -131,7 +131,7 @@ var storeTests = []struct {
 	{"o STORE too few", "o BAD Command STORE was not sent with three parameters"},
 	{"p STORE one,two FLAGS (\\Seen)", "p BAD Command STORE was sent with an invalid number parameter"},
 	{"q STORE 2,4:* WHYNOTTHIS? (\\Seen)", "q BAD Unknown data item type specified"},
-	{"r STORE 2,4:* -FLAGS.SILENT \\Seen", "r BAD Command STORE was sent with invalid parenthesized flags list"},
+	{"r STORE 2,4:* -FLAGS.SILENT \\Seen", "r OK STORE completed"},
 	{"s STORE 2,4:* +FLAGS (\\Seen \\Answered)", "* 2 FETCH (FLAGS (\\Answered \\Seen))\r\n* 4 FETCH (FLAGS (\\Answered \\Seen))\r\n* 5 FETCH (FLAGS (\\Answered \\Seen))\r\ns OK STORE completed"},
 	{"t STORE 3,2,1 -FLAGS (\\Answered)", "* 1 FETCH (FLAGS ())\r\n* 2 FETCH (FLAGS (\\Seen))\r\n* 3 FETCH (FLAGS ())\r\nt OK STORE completed"},
 	{"u STORE 1,2,3:* FLAGS.SILENT (\\Draft \\Deleted)", "u OK STORE completed"},
@@ -0,0 +1,700 @@
+package imap
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-pluto/pluto/comm"
+)
+
+// Constants
+
+// appendDateLayout is the date-time format of RFC 3501
+// clients use to set the internal date of an appended
+// message. The day may be padded with a space.
+const appendDateLayout = "_2-Jan-2006 15:04:05 -0700"
+
+// Structs
+
+// appendSpec is the syntactic form of one message of
+// an APPEND command: optional flags and internal date
+// followed by either a literal carrying the message
+// or the parts of a CATENATE (RFC 4469) message.
+// Keywords are kept apart from system flags, as their
+// Maildir letters depend on the user's mailbox.
+type appendSpec struct {
+	flags    string
+	keywords map[string]struct{}
+	date     time.Time
+	literal  *Node
+	parts    []catenatePart
+}
+
+// catenatePart is one part of a CATENATE message,
+// either text sent by the client or an IMAP URL
+// referencing (a section of) an existing message.
+type catenatePart struct {
+	isURL bool
+	value string
+}
+
+// Functions
+
+// maildirFlags converts IMAP system flags into the
+// string of corresponding Maildir flag characters.
+// Flags without a Maildir counterpart are ignored.
+func maildirFlags(flags map[string]struct{}) string {
+
+	mailFlags := make([]rune, 0, 5)
+
+	if _, found := flags["\\Draft"]; found {
+		mailFlags = append(mailFlags, 'D')
+	}
+
+	if _, found := flags["\\Flagged"]; found {
+		mailFlags = append(mailFlags, 'F')
+	}
+
+	if _, found := flags["\\Answered"]; found {
+		mailFlags = append(mailFlags, 'R')
+	}
+
+	if _, found := flags["\\Seen"]; found {
+		mailFlags = append(mailFlags, 'S')
+	}
+
+	if _, found := flags["\\Deleted"]; found {
+		mailFlags = append(mailFlags, 'T')
+	}
+
+	return string(mailFlags)
+}
+
+// parseAppendSpecs parses the message arguments of an
+// APPEND command following the mailbox name. As defined
+// by MULTIAPPEND (RFC 3502), any number of messages may
+// follow each other. Only the last one may be sent as
+// literal whose data is still pending.
+func parseAppendSpecs(args []*Node) ([]*appendSpec, error) {
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("Command APPEND was not sent with appropriate number of parameters")
+	}
+
+	specs := make([]*appendSpec, 0, 1)
+
+	for i := 0; i < len(args); {
+
+		spec := &appendSpec{}
+
+		if args[i].IsList() {
+
+			flags, err := ParseFlags(args[i:(i + 1)])
+			if err != nil {
+				return nil, err
+			}
+
+			if _, found := flags["\\Recent"]; found {
+				return nil, fmt.Errorf("Command APPEND was sent with flag \\Recent which cannot be set")
+			}
+
+			spec.flags = maildirFlags(flags)
+			spec.keywords = flags
+			i++
+		}
+
+		if (i < len(args)) && ((args[i].Type == NodeQuoted) || ((args[i].Type == NodeLiteral) && !args[i].Pending)) {
+
+			date, err := time.Parse(appendDateLayout, args[i].Value)
+			if err != nil {
+				return nil, fmt.Errorf("Command APPEND was sent with invalid date-time")
+			}
+
+			spec.date = date
+			i++
+		}
+
+		if i >= len(args) {
+			return nil, fmt.Errorf("Command APPEND was sent without message")
+		}
+
+		switch {
+
+		case args[i].Type == NodeLiteral:
+
+			if !args[i].Pending {
+				return nil, fmt.Errorf("Command APPEND did not contain proper literal data byte number")
+			}
+
+			spec.literal = args[i]
+			i++
+
+		case (args[i].Type == NodeAtom) && strings.EqualFold(args[i].Value, "CATENATE"):
+
+			if ((i + 1) >= len(args)) || !args[(i+1)].IsList() {
+				return nil, fmt.Errorf("Command APPEND was sent with invalid CATENATE parts")
+			}
+
+			parts, err := parseCatenateParts(args[(i + 1)].Items)
+			if err != nil {
+				return nil, err
+			}
+
+			spec.parts = parts
+			i += 2
+
+		default:
+			return nil, fmt.Errorf("Command APPEND was sent with invalid optional parameters")
+		}
+
+		specs = append(specs, spec)
+	}
+
+	return specs, nil
+}
+
+// parseCatenateParts parses the list of parts of a
+// CATENATE message, each one either TEXT followed by
+// a literal or URL followed by an IMAP URL.
+func parseCatenateParts(items []*Node) ([]catenatePart, error) {
+
+	if (len(items) == 0) || ((len(items) % 2) != 0) {
+		return nil, fmt.Errorf("Command APPEND was sent with invalid CATENATE parts")
+	}
+
+	parts := make([]catenatePart, 0, (len(items) / 2))
+
+	for i := 0; i < len(items); i += 2 {
+
+		if items[i].Type != NodeAtom {
+			return nil, fmt.Errorf("Command APPEND was sent with invalid CATENATE parts")
+		}
+
+		switch strings.ToUpper(items[i].Value) {
+
+		case "TEXT":
+
+			if items[(i+1)].Type != NodeLiteral {
+				return nil, fmt.Errorf("Command APPEND was sent with CATENATE text not sent as literal")
+			}
+
+			parts = append(parts, catenatePart{
+				value: items[(i + 1)].Value,
+			})
+
+		case "URL":
+
+			value, ok := items[(i + 1)].AString()
+			if !ok {
+				return nil, fmt.Errorf("Command APPEND was sent with invalid CATENATE URL")
+			}
+
+			parts = append(parts, catenatePart{
+				isURL: true,
+				value: value,
+			})
+
+		default:
+			return nil, fmt.Errorf("Command APPEND was sent with invalid CATENATE parts")
+		}
+	}
+
+	return parts, nil
+}
+
+// appendMessage creates the message described by spec.
+// The content of a CATENATE message is assembled from
+// its parts, while the content of a message sent as
+// literal is filled in once its data was received. If
+// a URL cannot be resolved, it is returned alongside
+// false. The caller is required to hold the lock.
+func (mailbox *Mailbox) appendMessage(s *Session, spec *appendSpec) (*AppendMessage, string, bool) {
+
+	msg := &AppendMessage{
+		Flags:        spec.flags,
+		InternalDate: spec.date,
+	}
+
+	if spec.literal != nil {
+		return msg, "", true
+	}
+
+	var content bytes.Buffer
+
+	for _, part := range spec.parts {
+
+		if !part.isURL {
+			content.WriteString(part.value)
+			continue
+		}
+
+		data, ok := mailbox.resolveURL(s, part.value)
+		if !ok {
+			return nil, part.value, false
+		}
+
+		content.Write(data)
+	}
+
+	msg.Content = content.Bytes()
+
+	return msg, "", true
+}
+
+// resolveURL returns the data an IMAP URL (RFC 5092)
+// references, which has to be a message or a section
+// of a message in one of the user's own mailboxes.
+// URLs without a mailbox refer to the selected one.
+// The caller is required to hold the lock.
+func (mailbox *Mailbox) resolveURL(s *Session, rawURL string) ([]byte, bool) {
+
+	path := rawURL
+
+	if strings.HasPrefix(strings.ToLower(path), "imap://") {
+
+		// Strip scheme and server, but make sure
+		// the URL does not refer to another user.
+		path = path[len("imap://"):]
+
+		slash := strings.IndexByte(path, '/')
+		if slash < 0 {
+			return nil, false
+		}
+
+		authority := path[:slash]
+		path = path[slash:]
+
+		if at := strings.LastIndexByte(authority, '@'); at >= 0 {
+
+			user := strings.SplitN(authority[:at], ";", 2)[0]
+
+			user, err := url.PathUnescape(user)
+			if (err != nil) || (user != s.UserName) {
+				return nil, false
+			}
+		}
+	}
+
+	var segments []string
+	folder := s.SelectedMailbox
+
+	switch {
+
+	case strings.HasPrefix(path, "/;"):
+
+		if s.State != StateMailbox {
+			return nil, false
+		}
+
+		segments = strings.Split(path[2:], "/;")
+
+	case strings.HasPrefix(path, ";"):
+
+		if s.State != StateMailbox {
+			return nil, false
+		}
+
+		segments = strings.Split(path[1:], "/;")
+
+	case strings.HasPrefix(path, "/"):
+
+		segments = strings.Split(path[1:], "/;")
+		mailboxSegment := segments[0]
+		segments = segments[1:]
+
+		// The mailbox may carry its UIDVALIDITY.
+		var uidValidity string
+		if semicolon := strings.IndexByte(mailboxSegment, ';'); semicolon >= 0 {
+
+			param := strings.SplitN(mailboxSegment[(semicolon+1):], "=", 2)
+			if (len(param) != 2) || !strings.EqualFold(param[0], "UIDVALIDITY") {
+				return nil, false
+			}
+
+			uidValidity = param[1]
+			mailboxSegment = mailboxSegment[:semicolon]
+		}
+
+		name, err := url.PathUnescape(mailboxSegment)
+		if err != nil {
+			return nil, false
+		}
+
+		folder = name
+		if strings.ToUpper(folder) == "INBOX" {
+			folder = "INBOX"
+		}
+
+		if uidValidity != "" {
+
+			validity, err := parseNumber(uidValidity)
+			if (err != nil) || (validity != mailbox.UIDs.Validity(folder)) {
+				return nil, false
+			}
+		}
+
+	default:
+		return nil, false
+	}
+
+	if !mailbox.Structure.Lookup(folder) {
+		return nil, false
+	}
+
+	var uid uint32
+	var section string
+	var partial string
+
+	for _, segment := range segments {
+
+		param := strings.SplitN(segment, "=", 2)
+		if len(param) != 2 {
+			return nil, false
+		}
+
+		value, err := url.PathUnescape(param[1])
+		if err != nil {
+			return nil, false
+		}
+
+		switch strings.ToUpper(param[0]) {
+
+		case "UID":
+
+			uid, err = parseNumber(value)
+			if (err != nil) || (uid == 0) {
+				return nil, false
+			}
+
+		case "SECTION":
+			section = value
+
+		case "PARTIAL":
+			partial = value
+
+		default:
+
+			// URLAUTH and friends are not supported.
+			return nil, false
+		}
+	}
+
+	if uid == 0 {
+		return nil, false
+	}
+
+	mailFileName := ""
+	for _, mail := range mailbox.Mails[folder] {
+
+		if mailbox.mailUID(folder, mail) == uid {
+			mailFileName = mail
+			break
+		}
+	}
+
+	if mailFileName == "" {
+		return nil, false
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(mailbox.FolderPath(folder), "cur", mailFileName))
+	if err != nil {
+		return nil, false
+	}
+
+	if section != "" {
+
+		item := &FetchItem{
+			Name:       "BODY",
+			HasSection: true,
+		}
+
+		err := item.parseSection(section)
+		if err != nil {
+			return nil, false
+		}
+
+		var found bool
+		data, found = parseMessagePart(data, "text/plain").Section(item)
+		if !found {
+			return nil, false
+		}
+	}
+
+	if partial != "" {
+
+		bounds := strings.SplitN(partial, ".", 2)
+
+		offset, err := strconv.Atoi(bounds[0])
+		if (err != nil) || (offset < 0) {
+			return nil, false
+		}
+
+		length := len(data)
+		if len(bounds) == 2 {
+
+			length, err = strconv.Atoi(bounds[1])
+			if (err != nil) || (length < 1) {
+				return nil, false
+			}
+		}
+
+		if offset > len(data) {
+			offset = len(data)
+		}
+
+		if (offset + length) > len(data) {
+			length = len(data) - offset
+		}
+
+		data = data[offset:(offset + length)]
+	}
+
+	return data, true
+}
+
+// continueAppend adds the messages described by specs
+// to the APPEND in progress. If the last one is sent as
+// literal, a continuation request for its data is
+// returned and the lock stays held. Otherwise, all
+// messages are stored and the lock is released.
+func (mailbox *Mailbox) continueAppend(s *Session, specs []*appendSpec, syncChan chan comm.Msg) (*Await, error) {
+
+	for _, spec := range specs {
+
+		keywordLetters, _, err := mailbox.keywordFlags(spec.keywords, true)
+		if err != nil {
+
+			tag := s.AppendInProg.Tag
+			mailbox.abortAppend(s)
+
+			// If no further keyword can be defined,
+			// the client exceeded a server limit.
+			return &Await{
+				Text: fmt.Sprintf("%s NO [LIMIT] %s", tag, err.Error()),
+			}, nil
+		}
+		spec.flags += keywordLetters
+
+		msg, badURL, ok := mailbox.appendMessage(s, spec)
+		if !ok {
+
+			tag := s.AppendInProg.Tag
+			mailbox.abortAppend(s)
+
+			// If a referenced message could not be found,
+			// this is a client error. Return NO response.
+			return &Await{
+				Text: fmt.Sprintf("%s NO [BADURL %s] CATENATE URL could not be resolved", tag, badURL),
+			}, nil
+		}
+
+		if spec.literal != nil {
+
+			s.AppendInProg.Pending = msg
+
+			return &Await{
+				Text:     "+ Ready for literal data",
+				NumBytes: uint32(spec.literal.Size),
+			}, nil
+		}
+
+		s.AppendInProg.Messages = append(s.AppendInProg.Messages, msg)
+	}
+
+	reply, err := mailbox.finishAppend(s, syncChan)
+
+	return &Await{
+		Text:   reply.Text,
+		Status: reply.Status,
+	}, err
+}
+
+// abortAppend discards the APPEND in progress
+// and releases the lock held for it.
+func (mailbox *Mailbox) abortAppend(s *Session) {
+
+	s.AppendInProg = nil
+	mailbox.Lock.Unlock()
+}
+
+// finishAppend stores all messages of the APPEND in
+// progress, each one with its flags in the Maildir info
+// suffix and its internal date as modification time of
+// the mail file, and replicates them. The assigned UIDs
+// are returned to the client in an APPENDUID response
+// code. Afterwards, the lock held since AppendBegin is
+// released.
+func (mailbox *Mailbox) finishAppend(s *Session, syncChan chan comm.Msg) (*Reply, error) {
+
+	defer mailbox.notify()
+	defer mailbox.abortAppend(s)
+
+	appendInProg := s.AppendInProg
+	appendedUIDs := make([]uint32, 0, len(appendInProg.Messages))
+
+	for _, msg := range appendInProg.Messages {
+
+		// Open a new Maildir delivery.
+		appDelivery, err := appendInProg.Maildir.NewDelivery()
+		if err != nil {
+
+			return &Reply{
+				Text:   "* BAD Internal server error, sorry. Closing connection.",
+				Status: 1,
+			}, fmt.Errorf("error during delivery creation: %v", err)
+		}
+
+		// Write actual message content to file.
+		err = appDelivery.Write(msg.Content)
+		if err != nil {
+
+			return &Reply{
+				Text:   "* BAD Internal server error, sorry. Closing connection.",
+				Status: 1,
+			}, fmt.Errorf("error during writing message during delivery: %v", err)
+		}
+
+		// Close and move just created message.
+		newKey, err := appDelivery.Close()
+		if err != nil {
+
+			return &Reply{
+				Text:   "* BAD Internal server error, sorry. Closing connection.",
+				Status: 1,
+			}, fmt.Errorf("error finishing delivery of new message: %v", err)
+		}
+
+		// Follow Maildir's renaming procedure.
+		_, err = appendInProg.Maildir.Unseen()
+		if err != nil {
+
+			return &Reply{
+				Text:   "* BAD Internal server error, sorry. Closing connection.",
+				Status: 1,
+			}, fmt.Errorf("error executing Unseen() on recently delivered messages: %v", err)
+		}
+
+		// Store supplied flags in the info suffix.
+		mailFileName, err := appendInProg.Maildir.SetFlags(newKey, msg.Flags, true)
+		if err != nil {
+
+			return &Reply{
+				Text:   "* BAD Internal server error, sorry. Closing connection.",
+				Status: 1,
+			}, fmt.Errorf("error setting flags of new message: %v", err)
+		}
+		mailFileNamePath := filepath.Join(string(appendInProg.Maildir), "cur", mailFileName)
+
+		// Store supplied internal date as modification time.
+		if !msg.InternalDate.IsZero() {
+
+			err = os.Chtimes(mailFileNamePath, msg.InternalDate, msg.InternalDate)
+			if err != nil {
+
+				return &Reply{
+					Text:   "* BAD Internal server error, sorry. Closing connection.",
+					Status: 1,
+				}, fmt.Errorf("error setting internal date of new message: %v", err)
+			}
+		}
+
+		info, err := os.Stat(mailFileNamePath)
+		if err != nil {
+
+			return &Reply{
+				Text:   "* BAD Internal server error, sorry. Closing connection.",
+				Status: 1,
+			}, fmt.Errorf("error retrieving internal date of new message: %v", err)
+		}
+
+		// Assign the next UID of the folder to the new mail.
+		var origUID uint32
+		err = mailbox.UIDs.Add(appendInProg.Mailbox, newKey, func(assignedUID uint32) {
+			origUID = assignedUID
+		})
+		if err != nil {
+
+			level.Error(mailbox.Logger).Log(
+				"msg", "failed to assign UID during source APPEND execution, will clean up",
+				"err", err,
+			)
+
+			err := os.Remove(mailFileNamePath)
+			if err != nil {
+				level.Error(mailbox.Logger).Log(
+					"msg", "failed to remove created mail message during clean up of failed source APPEND execution",
+					"err", err,
+				)
+			}
+
+			os.Exit(1)
+		}
+
+		// Insert new mail file name into message
+		// number tracking structure.
+		mailbox.insertMail(appendInProg.Mailbox, mailFileName)
+		mailbox.indexMail(appendInProg.Mailbox, mailFileNamePath, msg.Content)
+		modSeq := mailbox.recordChange(appendInProg.Mailbox, newKey, 0, "APPEND")
+
+		// Add mailbox-mail-file pair to structure CRDT
+		// and send an update message to other replicas.
+		err = mailbox.Structure.Add(appendInProg.Mailbox, mailFileName, func(args ...string) {
+			syncChan <- comm.Msg{
+				Operation: "append",
+				Append: &comm.Msg_APPEND{
+					User:         s.UserName,
+					Mailbox:      appendInProg.Mailbox,
+					AddTag:       mailFileName,
+					AddContent:   msg.Content,
+					OrigUID:      origUID,
+					InternalDate: info.ModTime().Unix(),
+					Keywords:     mailbox.Keywords.Bindings(),
+					ModSeq:       modSeq,
+				},
+			}
+		})
+		if err != nil {
+
+			level.Error(mailbox.Logger).Log(
+				"msg", "fail during source APPEND execution, will clean up",
+				"err", err,
+			)
+
+			err := os.Remove(mailFileNamePath)
+			if err != nil {
+				level.Error(mailbox.Logger).Log(
+					"msg", "failed to remove created mail message during clean up of failed source APPEND execution",
+					"err", err,
+				)
+			}
+
+			os.Exit(1)
+		}
+
+		uid, _ := mailbox.UIDs.UID(appendInProg.Mailbox, newKey)
+		appendedUIDs = append(appendedUIDs, uid)
+	}
+
+	// Announce UIDVALIDITY of target mailbox and
+	// UIDs of appended messages (RFC 4315).
+	return &Reply{
+		Text: fmt.Sprintf("%s OK [APPENDUID %d %s] APPEND completed", appendInProg.Tag,
+			mailbox.UIDs.Validity(appendInProg.Mailbox), formatUIDSet(appendedUIDs)),
+	}, nil
+}
+
+// init registers the capabilities of
+// extensions to APPEND.
+func init() {
+	RegisterCapability(&Capability{Name: "CATENATE", PostAuth: true})
+	RegisterCapability(&Capability{Name: "MULTIAPPEND", PostAuth: true})
+}
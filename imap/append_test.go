@@ -0,0 +1,72 @@
+package imap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Variables
+
+var appendSpecTests = []struct {
+	in    string
+	flags []string
+	dates []string
+	err   bool
+}{
+	{"{310}", []string{""}, []string{""}, false},
+	{"(\\Seen \\Draft) {310}", []string{"DS"}, []string{""}, false},
+	{"() \"05-Jan-2017 16:00:00 +0100\" {310}", []string{""}, []string{"05-Jan-2017 16:00:00 +0100"}, false},
+	{"\" 5-Jan-2017 16:00:00 +0100\" {310}", []string{""}, []string{"05-Jan-2017 16:00:00 +0100"}, false},
+	{"(\\Flagged) {3}\r\nabc (\\Answered \\Deleted) {310}", nil, nil, true},
+	{"CATENATE (TEXT {3}\r\nabc URL \"/INBOX/;UID=3\") (\\Seen) {310}", []string{"", "S"}, []string{"", ""}, false},
+	{"CATENATE (URL /Sent/;UID=1)", []string{""}, []string{""}, false},
+	{"", nil, nil, true},
+	{"(\\Seen)", nil, nil, true},
+	{"(\\Recent) {310}", nil, nil, true},
+	{"\"yesterday\" {310}", nil, nil, true},
+	{"CATENATE (TEXT \"abc\")", nil, nil, true},
+	{"CATENATE ()", nil, nil, true},
+	{"CATENATE (BODY {3}\r\nabc)", nil, nil, true},
+	{"NIL {310}", nil, nil, true},
+}
+
+// Functions
+
+// TestParseAppendSpecs executes a white-box table
+// test on implemented parseAppendSpecs() function.
+func TestParseAppendSpecs(t *testing.T) {
+
+	for _, test := range appendSpecTests {
+
+		args, err := ParseArgs(test.in)
+		assert.Nilf(t, err, "expected ParseArgs(%q) not to fail but got: %v", test.in, err)
+
+		specs, err := parseAppendSpecs(args)
+
+		if test.err {
+			assert.NotNilf(t, err, "expected parseAppendSpecs(%q) to fail", test.in)
+			continue
+		}
+
+		assert.Nilf(t, err, "expected parseAppendSpecs(%q) not to fail but got: %v", test.in, err)
+		assert.Equalf(t, len(test.flags), len(specs), "unexpected number of messages in %q", test.in)
+
+		for i, spec := range specs {
+
+			assert.Equalf(t, test.flags[i], spec.flags, "unexpected flags of message %d in %q", i, test.in)
+
+			if test.dates[i] == "" {
+				assert.Truef(t, spec.date.IsZero(), "expected no internal date of message %d in %q", i, test.in)
+				continue
+			}
+
+			date, _ := time.Parse(dateTimeLayout, test.dates[i])
+			assert.Truef(t, date.Equal(spec.date), "unexpected internal date %v of message %d in %q", spec.date, i, test.in)
+		}
+
+		last := specs[(len(specs) - 1)]
+		assert.Equalf(t, args[(len(args)-1)].Pending, last.literal != nil, "unexpected pending message in %q", test.in)
+	}
+}
@@ -0,0 +1,146 @@
+package imap
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Constants
+
+// Connection security a capability
+// is advertised for.
+const (
+	// AnySecurity advertises a capability
+	// regardless of whether TLS is active.
+	AnySecurity = iota
+	// TLSOnly advertises a capability only
+	// on connections protected by TLS.
+	TLSOnly
+	// CleartextOnly advertises a capability
+	// only on connections without TLS.
+	CleartextOnly
+)
+
+// Structs
+
+// Capability describes one entry of the CAPABILITY list
+// (RFC 3501) together with the states of a connection it
+// is advertised in. Commands lists the commands that only
+// exist because of this capability, so that they can be
+// refused if the capability is disabled.
+type Capability struct {
+	Name     string
+	PreAuth  bool
+	PostAuth bool
+	Security int
+	Commands []string
+}
+
+// CapabilityState describes the state of a client
+// connection relevant to the capabilities it gets
+// offered.
+type CapabilityState struct {
+	Authenticated bool
+	TLS           bool
+}
+
+// Variables
+
+// capabilities contains all registered capabilities
+// keyed by their upper-cased name.
+var (
+	capabilitiesLock sync.RWMutex
+	capabilities     = make(map[string]*Capability)
+)
+
+// Functions
+
+// RegisterCapability adds a capability to the list all
+// CAPABILITY responses are built from. Extensions call
+// it on initialization. Registering a name again
+// replaces the previous capability.
+func RegisterCapability(capability *Capability) {
+
+	capabilitiesLock.Lock()
+	defer capabilitiesLock.Unlock()
+
+	capabilities[strings.ToUpper(capability.Name)] = capability
+}
+
+// Capabilities returns the names of all registered
+// capabilities and supplied extra ones applying to the
+// supplied connection state that are not contained in
+// disabled. Extra capabilities belong to the caller,
+// e.g. the AUTH= mechanisms of one distributor, and are
+// not registered. IMAP4rev1 always comes first, the rest
+// is sorted by name.
+func Capabilities(state CapabilityState, disabled map[string]bool, extra ...*Capability) []string {
+
+	capabilitiesLock.RLock()
+	defer capabilitiesLock.RUnlock()
+
+	all := make(map[string]*Capability, (len(capabilities) + len(extra)))
+	for name, capability := range capabilities {
+		all[name] = capability
+	}
+
+	for _, capability := range extra {
+		all[strings.ToUpper(capability.Name)] = capability
+	}
+
+	names := make([]string, 0, len(all))
+
+	for name, capability := range all {
+
+		if (name == "IMAP4REV1") || disabled[name] {
+			continue
+		}
+
+		if (state.Authenticated && !capability.PostAuth) || (!state.Authenticated && !capability.PreAuth) {
+			continue
+		}
+
+		if ((capability.Security == TLSOnly) && !state.TLS) || ((capability.Security == CleartextOnly) && state.TLS) {
+			continue
+		}
+
+		names = append(names, capability.Name)
+	}
+
+	sort.Strings(names)
+
+	return append([]string{"IMAP4rev1"}, names...)
+}
+
+// CommandDisabled returns true if the supplied command
+// belongs to a capability contained in disabled.
+func CommandDisabled(command string, disabled map[string]bool) bool {
+
+	capabilitiesLock.RLock()
+	defer capabilitiesLock.RUnlock()
+
+	for name, capability := range capabilities {
+
+		if !disabled[name] {
+			continue
+		}
+
+		for _, capCommand := range capability.Commands {
+
+			if capCommand == command {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// init registers the capabilities of the
+// base protocol.
+func init() {
+
+	RegisterCapability(&Capability{Name: "IMAP4rev1", PreAuth: true, PostAuth: true})
+	RegisterCapability(&Capability{Name: "LITERAL+", PreAuth: true, PostAuth: true})
+}
@@ -0,0 +1,53 @@
+package imap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Functions
+
+// TestCapabilities executes a white-box unit test
+// on implemented Capabilities() function.
+func TestCapabilities(t *testing.T) {
+
+	RegisterCapability(&Capability{Name: "XTLSONLY", PreAuth: true, Security: TLSOnly})
+	RegisterCapability(&Capability{Name: "XCLEARTEXT", PreAuth: true, Security: CleartextOnly, Commands: []string{"XSTART"}})
+	defer func() {
+		delete(capabilities, "XTLSONLY")
+		delete(capabilities, "XCLEARTEXT")
+	}()
+
+	caps := Capabilities(CapabilityState{TLS: true}, nil)
+	assert.Equalf(t, []string{"IMAP4rev1", "ID", "LITERAL+", "XTLSONLY"}, caps, "unexpected pre-auth capabilities over TLS")
+
+	caps = Capabilities(CapabilityState{}, nil)
+	assert.Equalf(t, []string{"IMAP4rev1", "ID", "LITERAL+", "XCLEARTEXT"}, caps, "unexpected pre-auth capabilities without TLS")
+
+	caps = Capabilities(CapabilityState{Authenticated: true, TLS: true}, map[string]bool{"IDLE": true, "MOVE": true})
+	assert.Equalf(t, "IMAP4rev1", caps[0], "expected IMAP4rev1 to be listed first")
+	assert.Containsf(t, caps, "CONDSTORE", "expected post-auth capabilities to contain CONDSTORE")
+	assert.NotContainsf(t, caps, "IDLE", "expected disabled IDLE not to be listed")
+	assert.NotContainsf(t, caps, "XTLSONLY", "expected pre-auth only capability not to be listed")
+
+	// Capabilities of one caller are listed for it
+	// alone and are subject to the same filtering.
+	authPlain := &Capability{Name: "AUTH=PLAIN", PreAuth: true, Security: TLSOnly}
+
+	caps = Capabilities(CapabilityState{TLS: true}, nil, authPlain)
+	assert.Equalf(t, []string{"IMAP4rev1", "AUTH=PLAIN", "ID", "LITERAL+", "XTLSONLY"}, caps, "expected extra capability to be listed")
+
+	caps = Capabilities(CapabilityState{}, nil, authPlain)
+	assert.NotContainsf(t, caps, "AUTH=PLAIN", "expected TLS-only extra capability not to be listed without TLS")
+
+	caps = Capabilities(CapabilityState{TLS: true}, map[string]bool{"AUTH=PLAIN": true}, authPlain)
+	assert.NotContainsf(t, caps, "AUTH=PLAIN", "expected disabled extra capability not to be listed")
+
+	caps = Capabilities(CapabilityState{TLS: true}, nil)
+	assert.NotContainsf(t, caps, "AUTH=PLAIN", "expected extra capability not to be registered")
+
+	assert.Equalf(t, true, CommandDisabled(CommandMove, map[string]bool{"MOVE": true}), "expected MOVE command to be disabled")
+	assert.Equalf(t, false, CommandDisabled(CommandMove, map[string]bool{"IDLE": true}), "expected MOVE command not to be disabled")
+	assert.Equalf(t, false, CommandDisabled(CommandSelect, map[string]bool{"MOVE": true}), "expected SELECT command not to be disabled")
+}
@@ -0,0 +1,368 @@
+package imap
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// Structs
+
+// selectParams holds the optional parameters of a
+// SELECT or EXAMINE command defined by RFC 7162.
+// If qresync is true, the client supplied the
+// UIDVALIDITY and HIGHESTMODSEQ it last knew of
+// the mailbox and optionally the UIDs it knows.
+type selectParams struct {
+	condStore   bool
+	qresync     bool
+	uidValidity uint32
+	modSeq      uint64
+	knownUIDs   string
+}
+
+// fetchModifiers holds the modifiers of a FETCH
+// command defined by RFC 7162. If changedSince is
+// true, only mails with a MODSEQ greater than modSeq
+// are fetched. If vanished is true, UIDs of mails
+// expunged since then are reported as well.
+type fetchModifiers struct {
+	changedSince bool
+	vanished     bool
+	modSeq       uint64
+}
+
+// Functions
+
+// parseModSeq parses a mod-sequence value of RFC 7162,
+// a positive number of at most 63 bits. If zero is
+// allowed, mod-sequence-valzer is parsed instead.
+func parseModSeq(raw string, allowZero bool) (uint64, error) {
+
+	if (raw == "") || (strings.TrimLeft(raw, "0123456789") != "") {
+		return 0, fmt.Errorf("invalid mod-sequence value %q", raw)
+	}
+
+	modSeq, err := strconv.ParseUint(raw, 10, 63)
+	if (err != nil) || (!allowZero && (modSeq == 0)) {
+		return 0, fmt.Errorf("invalid mod-sequence value %q", raw)
+	}
+
+	return modSeq, nil
+}
+
+// parseSelectParams parses the parenthesized list of
+// parameters following the mailbox name of a SELECT
+// or EXAMINE command. QRESYNC is only accepted if the
+// client enabled it before.
+func parseSelectParams(s *Session, arg *Node) (*selectParams, error) {
+
+	params := &selectParams{}
+
+	if !arg.IsList() {
+		return nil, fmt.Errorf("invalid parameters")
+	}
+
+	for i := 0; i < len(arg.Items); i++ {
+
+		if arg.Items[i].Type != NodeAtom {
+			return nil, fmt.Errorf("invalid parameters")
+		}
+
+		switch strings.ToUpper(arg.Items[i].Value) {
+
+		case "CONDSTORE":
+			params.condStore = true
+
+		case "QRESYNC":
+
+			if !s.QResync {
+				return nil, fmt.Errorf("parameter QRESYNC requires ENABLE QRESYNC")
+			}
+
+			if ((i + 1) >= len(arg.Items)) || !arg.Items[(i+1)].IsList() {
+				return nil, fmt.Errorf("invalid QRESYNC parameter")
+			}
+
+			qresync := arg.Items[(i + 1)].Items
+			if (len(qresync) < 2) || (len(qresync) > 4) {
+				return nil, fmt.Errorf("invalid QRESYNC parameter")
+			}
+
+			for _, item := range qresync[:2] {
+
+				if item.Type != NodeAtom {
+					return nil, fmt.Errorf("invalid QRESYNC parameter")
+				}
+			}
+
+			uidValidity, err := parseNumber(qresync[0].Value)
+			if (err != nil) || (uidValidity == 0) {
+				return nil, fmt.Errorf("invalid UIDVALIDITY in QRESYNC parameter")
+			}
+
+			modSeq, err := parseModSeq(qresync[1].Value, false)
+			if err != nil {
+				return nil, err
+			}
+
+			// Known UIDs are optional and may be followed
+			// by sequence match data, which is only meant
+			// for servers not remembering expunged messages.
+			params.knownUIDs = "1:*"
+			if (len(qresync) > 2) && (qresync[2].Type == NodeAtom) {
+
+				_, err := ParseSeqSet(qresync[2].Value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid known UIDs in QRESYNC parameter")
+				}
+
+				params.knownUIDs = qresync[2].Value
+			}
+
+			params.qresync = true
+			params.uidValidity = uidValidity
+			params.modSeq = modSeq
+			i++
+
+		default:
+			return nil, fmt.Errorf("unknown parameter %s", arg.Items[i].Value)
+		}
+	}
+
+	return params, nil
+}
+
+// parseFetchModifiers parses the parenthesized list of
+// modifiers following the data items of a FETCH command.
+// VANISHED requires a UID FETCH with CHANGEDSINCE by a
+// client that enabled QRESYNC.
+func parseFetchModifiers(s *Session, arg *Node, useUID bool) (*fetchModifiers, error) {
+
+	modifiers := &fetchModifiers{}
+
+	if !arg.IsList() {
+		return nil, fmt.Errorf("Command FETCH was sent with invalid modifiers")
+	}
+
+	for i := 0; i < len(arg.Items); i++ {
+
+		if arg.Items[i].Type != NodeAtom {
+			return nil, fmt.Errorf("Command FETCH was sent with invalid modifiers")
+		}
+
+		switch strings.ToUpper(arg.Items[i].Value) {
+
+		case "CHANGEDSINCE":
+
+			if ((i + 1) >= len(arg.Items)) || (arg.Items[(i+1)].Type != NodeAtom) {
+				return nil, fmt.Errorf("Command FETCH was sent with invalid CHANGEDSINCE modifier")
+			}
+
+			modSeq, err := parseModSeq(arg.Items[(i+1)].Value, false)
+			if err != nil {
+				return nil, err
+			}
+
+			modifiers.changedSince = true
+			modifiers.modSeq = modSeq
+			i++
+
+		case "VANISHED":
+
+			if !useUID || !s.QResync {
+				return nil, fmt.Errorf("Command FETCH modifier VANISHED requires UID FETCH and ENABLE QRESYNC")
+			}
+
+			modifiers.vanished = true
+
+		default:
+			return nil, fmt.Errorf("Command FETCH was sent with unknown modifier %s", arg.Items[i].Value)
+		}
+	}
+
+	if modifiers.vanished && !modifiers.changedSince {
+		return nil, fmt.Errorf("Command FETCH modifier VANISHED requires CHANGEDSINCE")
+	}
+
+	return modifiers, nil
+}
+
+// mailModSeq returns the MODSEQ of supplied mail
+// file in folder. The caller is required to hold
+// the lock.
+func (mailbox *Mailbox) mailModSeq(folder string, mailFileName string) uint64 {
+	return mailbox.ModSeqs.ModSeq(folder, MailKey(mailFileName))
+}
+
+// recordChange assigns the message identified by key
+// in folder its next MODSEQ and returns it. The source
+// of an update passes zero as modSeq, downstream nodes
+// the value received. The caller is required to hold
+// the exclusive mailbox lock.
+func (mailbox *Mailbox) recordChange(folder string, key string, modSeq uint64, op string) uint64 {
+
+	modSeq, err := mailbox.ModSeqs.Update(folder, key, modSeq, true)
+	if err != nil {
+		level.Error(mailbox.Logger).Log(
+			"msg", fmt.Sprintf("failed to record MODSEQ of changed mail during %s execution", op),
+			"err", err,
+		)
+		os.Exit(1)
+	}
+
+	return modSeq
+}
+
+// recordExpunge remembers the message identified by
+// key as expunged from folder in the same way as
+// recordChange. The caller is required to hold the
+// exclusive mailbox lock.
+func (mailbox *Mailbox) recordExpunge(folder string, key string, modSeq uint64, op string) uint64 {
+
+	modSeq, err := mailbox.ModSeqs.Expunge(folder, key, modSeq, true)
+	if err != nil {
+		level.Error(mailbox.Logger).Log(
+			"msg", fmt.Sprintf("failed to record MODSEQ of expunged mail during %s execution", op),
+			"err", err,
+		)
+		os.Exit(1)
+	}
+
+	return modSeq
+}
+
+// vanishedUIDs returns the sorted UIDs of all messages
+// expunged from folder after supplied MODSEQ. Only UIDs
+// contained in UID set known are considered. The caller
+// is required to hold the lock.
+func (mailbox *Mailbox) vanishedUIDs(folder string, modSeq uint64, known string) ([]uint32, error) {
+
+	uids := make([]uint32, 0)
+
+	for _, key := range mailbox.ModSeqs.ExpungedSince(folder, modSeq) {
+
+		if uid, found := mailbox.UIDs.UID(folder, key); found {
+			uids = append(uids, uid)
+		}
+	}
+
+	sort.Slice(uids, func(i, j int) bool {
+		return uids[i] < uids[j]
+	})
+
+	included, err := ParseUIDSet(known, uids)
+	if err != nil {
+		return nil, err
+	}
+
+	knownUIDs := make([]uint32, len(included))
+	for i, index := range included {
+		knownUIDs[i] = uids[index]
+	}
+
+	return knownUIDs, nil
+}
+
+// expungeResponse returns the untagged response
+// informing the client about expunged messages at
+// supplied sequence numbers, which are expected in
+// descending order, and of supplied UIDs. Clients
+// that enabled QRESYNC receive one VANISHED response
+// instead of an EXPUNGE response per message.
+func expungeResponse(s *Session, mailSeqNums []int, uids []uint32) []string {
+
+	if len(mailSeqNums) == 0 {
+		return nil
+	}
+
+	if s.QResync {
+
+		sorted := append([]uint32(nil), uids...)
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i] < sorted[j]
+		})
+
+		return []string{fmt.Sprintf("* VANISHED %s", formatUIDSet(sorted))}
+	}
+
+	lines := make([]string, len(mailSeqNums))
+	for i, mailSeqNum := range mailSeqNums {
+		lines[i] = fmt.Sprintf("* %d EXPUNGE", (mailSeqNum + 1))
+	}
+
+	return lines
+}
+
+// flagsFetch returns the untagged FETCH response
+// reporting the flags of the mail at supplied index
+// of the selected mailbox. UID is included if asked
+// for or if QRESYNC is enabled, MODSEQ whenever the
+// client enabled CONDSTORE. The caller is required
+// to hold the lock.
+func (mailbox *Mailbox) flagsFetch(s *Session, mailSeqNum int, mailFileName string, maildirFlags string, withUID bool) string {
+
+	items := fmt.Sprintf("FLAGS (%s)", mailbox.imapFlags(maildirFlags))
+
+	if withUID || s.QResync {
+		items = fmt.Sprintf("UID %d %s", mailbox.mailUID(s.SelectedMailbox, mailFileName), items)
+	}
+
+	if s.CondStore {
+		items = fmt.Sprintf("%s MODSEQ (%d)", items, mailbox.mailModSeq(s.SelectedMailbox, mailFileName))
+	}
+
+	return fmt.Sprintf("* %d FETCH (%s)", (mailSeqNum + 1), items)
+}
+
+// resyncMailbox returns the responses of a SELECT or
+// EXAMINE with QRESYNC parameter: the UIDs of messages
+// expunged and FETCH responses for messages changed
+// since the MODSEQ the client supplied, restricted to
+// the UIDs it knows. allFlags holds the Maildir flags
+// of all mails of the selected mailbox. The caller is
+// required to hold the lock.
+func (mailbox *Mailbox) resyncMailbox(s *Session, params *selectParams, allFlags []string) (string, error) {
+
+	folder := s.SelectedMailbox
+	answer := ""
+
+	vanished, err := mailbox.vanishedUIDs(folder, params.modSeq, params.knownUIDs)
+	if err != nil {
+		return "", err
+	}
+
+	if len(vanished) > 0 {
+		answer = fmt.Sprintf("* VANISHED (EARLIER) %s\r\n", formatUIDSet(vanished))
+	}
+
+	knownSeqNums, err := ParseUIDSet(params.knownUIDs, mailbox.folderUIDs(folder))
+	if err != nil {
+		return "", err
+	}
+
+	known := make(map[int]bool)
+	for _, mailSeqNum := range knownSeqNums {
+		known[mailSeqNum] = true
+	}
+
+	for i, mail := range mailbox.Mails[folder] {
+
+		if known[i] && (mailbox.mailModSeq(folder, mail) > params.modSeq) {
+			answer = fmt.Sprintf("%s%s\r\n", answer, mailbox.flagsFetch(s, i, mail, allFlags[i], true))
+		}
+	}
+
+	return answer, nil
+}
+
+// init registers the capabilities of
+// RFC 7162.
+func init() {
+	RegisterCapability(&Capability{Name: "CONDSTORE", PostAuth: true})
+	RegisterCapability(&Capability{Name: "QRESYNC", PostAuth: true})
+}
@@ -0,0 +1,82 @@
+package imap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Variables
+
+var modSeqTests = []struct {
+	raw       string
+	allowZero bool
+	modSeq    uint64
+	fails     bool
+}{
+	{"1", false, 1, false},
+	{"12111230047", false, 12111230047, false},
+	{"0", false, 0, true},
+	{"0", true, 0, false},
+	{"9223372036854775807", false, 9223372036854775807, false},
+	{"9223372036854775808", false, 0, true},
+	{"", false, 0, true},
+	{"-1", true, 0, true},
+	{"12a", false, 0, true},
+}
+
+// Functions
+
+// TestParseModSeq executes a white-box table
+// test on implemented parseModSeq() function.
+func TestParseModSeq(t *testing.T) {
+
+	for _, test := range modSeqTests {
+
+		modSeq, err := parseModSeq(test.raw, test.allowZero)
+		assert.Equalf(t, test.fails, err != nil, "unexpected error for %q: %v", test.raw, err)
+		assert.Equalf(t, test.modSeq, modSeq, "unexpected MODSEQ for %q", test.raw)
+	}
+}
+
+// TestParseSelectParams executes a white-box unit
+// test on implemented parseSelectParams() function.
+func TestParseSelectParams(t *testing.T) {
+
+	s := &Session{}
+
+	params, err := parseSelectParams(s, &Node{Type: NodeList, Items: []*Node{
+		{Type: NodeAtom, Value: "CONDSTORE"},
+	}})
+	assert.Nilf(t, err, "expected CONDSTORE to be accepted but got: %v", err)
+	assert.Equalf(t, true, params.condStore, "expected CONDSTORE to be set")
+
+	qresync := &Node{Type: NodeList, Items: []*Node{
+		{Type: NodeAtom, Value: "QRESYNC"},
+		{Type: NodeList, Items: []*Node{
+			{Type: NodeAtom, Value: "67890007"},
+			{Type: NodeAtom, Value: "90060115194045000"},
+			{Type: NodeAtom, Value: "41:211,214:541"},
+		}},
+	}}
+
+	_, err = parseSelectParams(s, qresync)
+	assert.NotNilf(t, err, "expected QRESYNC to require ENABLE QRESYNC")
+
+	s.QResync = true
+
+	params, err = parseSelectParams(s, qresync)
+	assert.Nilf(t, err, "expected QRESYNC to be accepted but got: %v", err)
+	assert.Equalf(t, true, params.qresync, "expected QRESYNC to be set")
+	assert.Equalf(t, uint32(67890007), params.uidValidity, "unexpected UIDVALIDITY")
+	assert.Equalf(t, uint64(90060115194045000), params.modSeq, "unexpected MODSEQ")
+	assert.Equalf(t, "41:211,214:541", params.knownUIDs, "unexpected known UIDs")
+
+	_, err = parseSelectParams(s, &Node{Type: NodeList, Items: []*Node{
+		{Type: NodeAtom, Value: "QRESYNC"},
+		{Type: NodeList, Items: []*Node{
+			{Type: NodeAtom, Value: "67890007"},
+		}},
+	}})
+	assert.NotNilf(t, err, "expected QRESYNC without MODSEQ to fail")
+}
@@ -0,0 +1,453 @@
+package imap
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-pluto/maildir"
+	"github.com/go-pluto/pluto/comm"
+)
+
+// Structs
+
+// copySource carries everything needed to
+// deliver a copy of one mail.
+type copySource struct {
+	content      []byte
+	flags        string
+	internalDate time.Time
+}
+
+// Functions
+
+// readCopySource reads content, flags, and internal
+// date of the mail sourceMail in sourceMaildir.
+func readCopySource(sourceMaildir maildir.Dir, sourceMail string) (*copySource, error) {
+
+	sourceMailPath := filepath.Join(string(sourceMaildir), "cur", sourceMail)
+
+	content, err := ioutil.ReadFile(sourceMailPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading mail to copy: %v", err)
+	}
+
+	info, err := os.Stat(sourceMailPath)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving internal date of mail to copy: %v", err)
+	}
+
+	mailFlags, err := sourceMaildir.Flags(sourceMail, false)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving flags of mail to copy: %v", err)
+	}
+
+	return &copySource{
+		content:      content,
+		flags:        mailFlags,
+		internalDate: info.ModTime(),
+	}, nil
+}
+
+// deliverCopy stores a copy of source under a new key
+// in targetMaildir, carrying over flags and internal
+// date, and returns key and file name of the copy. If
+// this fails, the partly delivered copy is removed.
+func deliverCopy(targetMaildir maildir.Dir, source *copySource) (string, string, error) {
+
+	copyDelivery, err := targetMaildir.NewDelivery()
+	if err != nil {
+		return "", "", fmt.Errorf("error during delivery creation: %v", err)
+	}
+
+	err = copyDelivery.Write(source.content)
+	if err != nil {
+		copyDelivery.Abort()
+		return "", "", fmt.Errorf("error during writing message during delivery: %v", err)
+	}
+
+	newKey, err := copyDelivery.Close()
+	if err != nil {
+		copyDelivery.Abort()
+		return "", "", fmt.Errorf("error finishing delivery of copied message: %v", err)
+	}
+
+	_, err = targetMaildir.Unseen()
+	if err != nil {
+		removeCopy(targetMaildir, newKey)
+		return "", "", fmt.Errorf("error executing Unseen() on copied message: %v", err)
+	}
+
+	copyFileName, err := targetMaildir.SetFlags(newKey, source.flags, true)
+	if err != nil {
+		removeCopy(targetMaildir, newKey)
+		return "", "", fmt.Errorf("error setting flags of copied message: %v", err)
+	}
+
+	err = os.Chtimes(filepath.Join(string(targetMaildir), "cur", copyFileName), source.internalDate, source.internalDate)
+	if err != nil {
+		removeCopy(targetMaildir, newKey)
+		return "", "", fmt.Errorf("error setting internal date of copied message: %v", err)
+	}
+
+	return newKey, copyFileName, nil
+}
+
+// removeCopy removes the copy with supplied key from
+// targetMaildir wherever its delivery has put it.
+func removeCopy(targetMaildir maildir.Dir, key string) {
+
+	os.Remove(filepath.Join(string(targetMaildir), "new", key))
+
+	curFiles, _ := filepath.Glob(filepath.Join(string(targetMaildir), "cur", (key + ":*")))
+	for _, curFile := range curFiles {
+		os.Remove(curFile)
+	}
+}
+
+// Copy duplicates the referenced messages of the currently
+// selected mailbox into the target mailbox, preserving
+// flags and internal date. Copies are stored under new
+// Maildir keys and therefore carry new structure CRDT
+// tags, which makes them behave like appended messages
+// towards concurrent operations on the target mailbox.
+func (mailbox *Mailbox) Copy(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+	return mailbox.copyMails(s, req, false, false, syncChan)
+}
+
+// Move transfers the referenced messages of the currently
+// selected mailbox to the target mailbox. The target side
+// of a MOVE is identical to COPY, the source side removes
+// the messages the same way EXPUNGE does and announces
+// this to the client via untagged EXPUNGE responses.
+func (mailbox *Mailbox) Move(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+	return mailbox.copyMails(s, req, false, true, syncChan)
+}
+
+// copyMails implements COPY, MOVE, UID COPY, and UID MOVE.
+// If useUID is true, messages are referenced by UID, if
+// move is true, copied messages are removed from the
+// selected mailbox afterwards.
+func (mailbox *Mailbox) copyMails(s *Session, req *Request, useUID bool, move bool, syncChan chan comm.Msg) (*Reply, error) {
+
+	command := CommandCopy
+	if move {
+		command = CommandMove
+	}
+
+	if s.State != StateMailbox {
+
+		// If connection was not in correct state when this
+		// command was executed, this is a client error.
+		// Send tagged BAD response.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD No mailbox selected to %s from", req.Tag, strings.ToLower(command)),
+		}, nil
+	}
+
+	if move && s.ReadOnly {
+
+		// Mailboxes selected via EXAMINE must not
+		// be modified. Return NO statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s NO Mailbox was selected read-only, cannot move", req.Tag),
+		}, nil
+	}
+
+	copyArgs := req.Args
+
+	var targetMailboxName string
+	ok := (len(copyArgs) == 2) && (copyArgs[0].Type == NodeAtom)
+	if ok {
+		targetMailboxName, ok = s.mailboxName(copyArgs[1])
+	}
+
+	if !ok {
+
+		// If payload did not contain exactly two
+		// elements, this is a client error.
+		// Return BAD statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command %s was not sent with two parameters", req.Tag, command),
+		}, nil
+	}
+
+	targetMailbox := strings.TrimSuffix(targetMailboxName, mailbox.HierarchySeparator)
+	if strings.ToUpper(targetMailbox) == "INBOX" {
+		targetMailbox = "INBOX"
+	}
+
+	sourceMaildir := maildir.Dir(mailbox.FolderPath(s.SelectedMailbox))
+	targetMaildir := maildir.Dir(mailbox.FolderPath(targetMailbox))
+
+	defer mailbox.notify()
+
+	// Lock node exclusively to make execution
+	// of following CRDT operations atomic.
+	mailbox.Lock.Lock()
+	defer mailbox.Lock.Unlock()
+
+	if !mailbox.Structure.Lookup(targetMailbox) {
+
+		// If target mailbox does not exist, the client
+		// may create it and retry. Return NO statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s NO [TRYCREATE] Target mailbox does not exist", req.Tag),
+		}, nil
+	}
+
+	// Parse sequence numbers or UIDs (first parameter).
+	var mailSeqNums []int
+	var err error
+	if useUID {
+		mailSeqNums, err = ParseUIDSet(copyArgs[0].Value, mailbox.folderUIDs(s.SelectedMailbox))
+	} else {
+		mailSeqNums, err = ParseSeqNumbers(copyArgs[0].Value, len(mailbox.Mails[s.SelectedMailbox]))
+	}
+	if err != nil {
+
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD %s", req.Tag, err.Error()),
+		}, nil
+	}
+
+	// Save file names of all referenced mails before
+	// any copy into the same mailbox shifts them.
+	sourceMails := make([]string, len(mailSeqNums))
+	sourceUIDs := make([]uint32, len(mailSeqNums))
+	for i, mailSeqNum := range mailSeqNums {
+		sourceMails[i] = mailbox.Mails[s.SelectedMailbox][mailSeqNum]
+		sourceUIDs[i] = mailbox.mailUID(s.SelectedMailbox, sourceMails[i])
+	}
+
+	// Read content, flags, and internal date of all
+	// mails to copy before delivering any of them.
+	sources := make([]*copySource, len(sourceMails))
+	for i, sourceMail := range sourceMails {
+
+		sources[i], err = readCopySource(sourceMaildir, sourceMail)
+		if err != nil {
+
+			return &Reply{
+				Text:   "* BAD Internal server error, sorry. Closing connection.",
+				Status: 1,
+			}, err
+		}
+	}
+
+	// Deliver all copies under new keys to the target
+	// mailbox. If any delivery fails, all copies are
+	// removed again and no state has changed.
+	copyKeys := make([]string, len(sources))
+	copyFileNames := make([]string, len(sources))
+	for i, source := range sources {
+
+		copyKeys[i], copyFileNames[i], err = deliverCopy(targetMaildir, source)
+		if err != nil {
+
+			for j := 0; j < i; j++ {
+				removeCopy(targetMaildir, copyKeys[j])
+			}
+
+			return &Reply{
+				Text:   "* BAD Internal server error, sorry. Closing connection.",
+				Status: 1,
+			}, err
+		}
+	}
+
+	addTags := make([]string, 0, len(sourceMails))
+	addContents := make([][]byte, 0, len(sourceMails))
+	origUIDs := make([]uint32, 0, len(sourceMails))
+	copyUIDs := make([]uint32, 0, len(sourceMails))
+
+	// Remember the MODSEQ assigned to the first copy,
+	// downstream replicas assign the following ones.
+	var modSeq uint64
+
+	for i, source := range sources {
+
+		newKey := copyKeys[i]
+		copyFileName := copyFileNames[i]
+		copyFilePath := filepath.Join(string(targetMaildir), "cur", copyFileName)
+
+		// Assign the next UID of the target mailbox.
+		var origUID uint32
+		err = mailbox.UIDs.Add(targetMailbox, newKey, func(assignedUID uint32) {
+			origUID = assignedUID
+		})
+		if err != nil {
+
+			level.Error(mailbox.Logger).Log(
+				"msg", fmt.Sprintf("failed to assign UID during source %s execution, will clean up", command),
+				"err", err,
+			)
+
+			// Copies without UID must not
+			// stay in the target mailbox.
+			for j := i; j < len(copyKeys); j++ {
+				removeCopy(targetMaildir, copyKeys[j])
+			}
+
+			os.Exit(1)
+		}
+
+		// Add copy to structure CRDT without sending,
+		// all copies are sent in one update below.
+		err = mailbox.Structure.AddEffect(targetMailbox, copyFileName, true)
+		if err != nil {
+
+			level.Error(mailbox.Logger).Log(
+				"msg", fmt.Sprintf("failed to add copied mail to structure CRDT during source %s execution", command),
+				"err", err,
+			)
+			os.Exit(1)
+		}
+
+		mailbox.insertMail(targetMailbox, copyFileName)
+		mailbox.indexMail(targetMailbox, copyFilePath, source.content)
+
+		// Copies are changes of the target mailbox.
+		copyModSeq := mailbox.recordChange(targetMailbox, newKey, 0, command)
+		if modSeq == 0 {
+			modSeq = copyModSeq
+		}
+
+		addTags = append(addTags, copyFileName)
+		addContents = append(addContents, source.content)
+		origUIDs = append(origUIDs, origUID)
+		copyUIDs = append(copyUIDs, mailbox.mailUID(targetMailbox, copyFileName))
+	}
+
+	// Tell the client which UIDs the copies received
+	// in the target mailbox (RFC 4315). If no message
+	// was referenced, there is nothing to report.
+	copyUID := ""
+	if len(copyUIDs) > 0 {
+		copyUID = fmt.Sprintf("[COPYUID %d %s %s] ", mailbox.UIDs.Validity(targetMailbox),
+			formatUIDSet(sourceUIDs), formatUIDSet(copyUIDs))
+	}
+
+	if !move {
+
+		syncChan <- comm.Msg{
+			Operation: "copy",
+			Copy: &comm.Msg_COPY{
+				User:          s.UserName,
+				TargetMailbox: targetMailbox,
+				AddTags:       addTags,
+				AddContents:   addContents,
+				OrigUIDs:      origUIDs,
+				ModSeq:        modSeq,
+			},
+		}
+
+		return &Reply{
+			Text: fmt.Sprintf("%s OK %sCOPY completed", req.Tag, copyUID),
+		}, nil
+	}
+
+	// Remove moved mails from the selected mailbox in
+	// descending order of their sequence numbers.
+	rmvSeqNums := make([]int, 0, len(sourceMails))
+	for i, mailFileName := range mailbox.Mails[s.SelectedMailbox] {
+
+		for _, sourceMail := range sourceMails {
+
+			if mailFileName == sourceMail {
+				rmvSeqNums = append(rmvSeqNums, i)
+			}
+		}
+	}
+
+	answerLines := make([]string, 0, (len(rmvSeqNums) + 2))
+	expMailNums := make([]int, 0, len(rmvSeqNums))
+	expUIDs := make([]uint32, 0, len(rmvSeqNums))
+
+	// MOVE (RFC 6851) sends COPYUID in an untagged
+	// OK response before expunging the source mails.
+	if copyUID != "" {
+		answerLines = append(answerLines, fmt.Sprintf("* OK %sMoved UIDs", copyUID))
+	}
+
+	for i := (len(rmvSeqNums) - 1); i >= 0; i-- {
+
+		mailSeqNum := rmvSeqNums[i]
+		mailFileName := mailbox.Mails[s.SelectedMailbox][mailSeqNum]
+
+		expMailNums = append(expMailNums, mailSeqNum)
+		expUIDs = append(expUIDs, mailbox.mailUID(s.SelectedMailbox, mailFileName))
+		mailbox.recordExpunge(s.SelectedMailbox, MailKey(mailFileName), 0, command)
+
+		err := mailbox.Structure.RemovePair(s.SelectedMailbox, mailFileName, func(args ...string) {})
+		if err != nil {
+
+			// This is a write-back error of the updated structure CRDT
+			// log file. Reverting actions were already taken, log error.
+			level.Error(mailbox.Logger).Log(
+				"msg", fmt.Sprintf("failed to remove mail '%v' from user's structure CRDT", mailFileName),
+				"err", err,
+			)
+			os.Exit(1)
+		}
+
+		err = os.Remove(filepath.Join(string(sourceMaildir), "cur", mailFileName))
+		if err != nil {
+
+			level.Error(mailbox.Logger).Log(
+				"msg", "failed to remove moved mail file during source MOVE execution",
+				"err", err,
+			)
+			os.Exit(1)
+		}
+
+		mailbox.Index.Remove(s.SelectedMailbox, MailKey(mailFileName))
+		s.forgetKnownMail(mailFileName)
+		mailbox.Mails[s.SelectedMailbox] = append(mailbox.Mails[s.SelectedMailbox][:mailSeqNum], mailbox.Mails[s.SelectedMailbox][(mailSeqNum+1):]...)
+	}
+
+	answerLines = append(answerLines, expungeResponse(s, expMailNums, expUIDs)...)
+
+	// Add a mailbox-new-UUID pair to the structure CRDT
+	// just like EXPUNGE does and send the whole MOVE.
+	err = mailbox.Structure.Add(s.SelectedMailbox, "", func(args ...string) {
+		syncChan <- comm.Msg{
+			Operation: "move",
+			Move: &comm.Msg_MOVE{
+				User:          s.UserName,
+				Mailbox:       s.SelectedMailbox,
+				RmvTags:       sourceMails,
+				AddTag:        args[0],
+				TargetMailbox: targetMailbox,
+				AddTags:       addTags,
+				AddContents:   addContents,
+				OrigUIDs:      origUIDs,
+				ModSeq:        modSeq,
+			},
+		}
+	})
+	if err != nil {
+
+		level.Error(mailbox.Logger).Log(
+			"msg", "failed to add interest tag to structure CRDT during source MOVE execution",
+			"err", err,
+		)
+		os.Exit(1)
+	}
+
+	answerLines = append(answerLines, fmt.Sprintf("%s OK MOVE completed", req.Tag))
+
+	return &Reply{
+		Text: strings.Join(answerLines, "\r\n"),
+	}, nil
+}
+
+// init registers the MOVE capability.
+func init() {
+	RegisterCapability(&Capability{Name: "MOVE", PostAuth: true, Commands: []string{CommandMove}})
+}
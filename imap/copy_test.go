@@ -0,0 +1,191 @@
+package imap
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/go-pluto/maildir"
+	"github.com/go-pluto/pluto/comm"
+	"github.com/stretchr/testify/assert"
+)
+
+// Variables
+
+// copyMails are stored in this order in the
+// INBOX of the mailbox copies are tested on.
+var copyMails = []struct {
+	key   string
+	flags string
+}{
+	{"1400000001.a", "S"},
+	{"1400000002.b", ""},
+	{"1400000003.c", "FR"},
+	{"1400000004.d", "T"},
+	{"1400000005.e", "D"},
+}
+
+// Functions
+
+// checkCopies compares the mails in folder at supplied
+// indices against the INBOX mails copies were made of.
+func checkCopies(t *testing.T, mailbox *Mailbox, folder string, indices []int, sources []int) {
+
+	for i, index := range indices {
+
+		copyFileName := mailbox.Mails[folder][index]
+		copyFilePath := filepath.Join(mailbox.FolderPath(folder), "cur", copyFileName)
+		source := copyMails[sources[i]]
+
+		flags, err := maildir.Dir(mailbox.FolderPath(folder)).Flags(copyFileName, false)
+		assert.Nilf(t, err, "expected flags of copy of %s but got: %v", source.key, err)
+		assert.Equalf(t, source.flags, flags, "expected flags of %s to be carried over", source.key)
+
+		info, err := os.Stat(copyFilePath)
+		assert.Nilf(t, err, "expected copy of %s to be stored but got: %v", source.key, err)
+		assert.Equalf(t, copyDate(sources[i]).Unix(), info.ModTime().Unix(), "expected internal date of %s to be carried over", source.key)
+
+		content, _ := ioutil.ReadFile(copyFilePath)
+		assert.Equalf(t, copyContent(sources[i]), string(content), "expected content of %s to be copied", source.key)
+
+		assert.NotEqualf(t, source.key, MailKey(copyFileName), "expected copy of %s to be stored under a new key", source.key)
+	}
+}
+
+// copyDate returns the internal date of
+// the i-th mail in copyMails.
+func copyDate(i int) time.Time {
+	return time.Date(2017, time.March, (i + 1), 12, 0, 0, 0, time.UTC)
+}
+
+// copyContent returns the content of
+// the i-th mail in copyMails.
+func copyContent(i int) string {
+	return fmt.Sprintf("Subject: Mail %d\r\n\r\nBody of mail %d.\r\n", i, i)
+}
+
+// TestCopyMails executes a black-box unit test on
+// COPY, MOVE, and UID MOVE between two mailboxes.
+func TestCopyMails(t *testing.T) {
+
+	mailbox, cleanup := newTestMailbox(t, "Archive")
+	defer cleanup()
+
+	for i, mail := range copyMails {
+		addTestMail(t, mailbox, "INBOX", mail.key, mail.flags, copyDate(i), copyContent(i))
+	}
+
+	s := &Session{
+		State:           StateMailbox,
+		UserName:        "user",
+		SelectedMailbox: "INBOX",
+		KnownMails:      append([]string(nil), mailbox.Mails["INBOX"]...),
+	}
+
+	syncChan := make(chan comm.Msg, 1)
+	sourceMails := append([]string(nil), mailbox.Mails["INBOX"]...)
+	validity := mailbox.UIDs.Validity("Archive")
+
+	run := func(command string, payload string) (*Reply, error) {
+
+		req, err := ParseRequest(fmt.Sprintf("a1 %s %s", command, payload))
+		assert.Nilf(t, err, "expected %s %s to be parsed but got: %v", command, payload, err)
+
+		switch command {
+		case CommandCopy:
+			return mailbox.Copy(s, req, syncChan)
+		case CommandMove:
+			return mailbox.Move(s, req, syncChan)
+		}
+
+		return mailbox.UID(s, req, syncChan)
+	}
+
+	reply, _ := run(CommandCopy, "1 Nowhere")
+	assert.Equalf(t, "a1 NO [TRYCREATE] Target mailbox does not exist", reply.Text, "expected missing target to be refused")
+
+	reply, _ = run(CommandCopy, "6 Archive")
+	assert.Truef(t, strings.HasPrefix(reply.Text, "a1 BAD "), "expected invalid sequence number to be refused but got: %s", reply.Text)
+
+	s.ReadOnly = true
+	reply, _ = run(CommandMove, "1 Archive")
+	assert.Equalf(t, "a1 NO Mailbox was selected read-only, cannot move", reply.Text, "expected MOVE from read-only mailbox to be refused")
+	s.ReadOnly = false
+
+	// If any mail cannot be read, nothing is
+	// delivered and no update is sent.
+	unreadable := filepath.Join(mailbox.FolderPath("INBOX"), "cur", sourceMails[4])
+	os.Rename(unreadable, (unreadable + ".hidden"))
+
+	reply, err := run(CommandMove, "1:* Archive")
+	assert.NotNilf(t, err, "expected MOVE of unreadable mail to fail")
+	assert.Equalf(t, uint32(1), reply.Status, "expected connection to be closed")
+	assert.Equalf(t, 0, len(syncChan), "expected no update to be sent")
+	assert.Equalf(t, 0, len(mailbox.Mails["Archive"]), "expected no mail to be copied")
+	assert.Equalf(t, sourceMails, mailbox.Mails["INBOX"], "expected no mail to be moved")
+
+	delivered, _ := ioutil.ReadDir(filepath.Join(mailbox.FolderPath("Archive"), "cur"))
+	assert.Equalf(t, 0, len(delivered), "expected no copy to be stored")
+
+	os.Rename((unreadable + ".hidden"), unreadable)
+
+	// COPY keeps the source mails and reports the
+	// UIDs of the copies in order of the source UIDs.
+	reply, err = run(CommandCopy, "5:4,2 Archive")
+	assert.Nilf(t, err, "expected COPY not to fail but got: %v", err)
+	assert.Equalf(t, fmt.Sprintf("a1 OK [COPYUID %d 2,4:5 1:3] COPY completed", validity), reply.Text, "unexpected answer to COPY")
+	assert.Equalf(t, sourceMails, mailbox.Mails["INBOX"], "expected source mails to stay")
+	assert.Equalf(t, []uint32{1, 2, 3}, mailbox.folderUIDs("Archive"), "expected copies to be assigned UIDs")
+	checkCopies(t, mailbox, "Archive", []int{0, 1, 2}, []int{1, 3, 4})
+
+	upd := <-syncChan
+	assert.Equalf(t, "copy", upd.Operation, "expected a COPY update")
+	assert.Equalf(t, "user", upd.Copy.User, "unexpected user in update")
+	assert.Equalf(t, "Archive", upd.Copy.TargetMailbox, "unexpected target in update")
+	assert.Equalf(t, mailbox.Mails["Archive"], upd.Copy.AddTags, "expected copies as tags in update")
+	assert.Equalf(t, []byte(copyContent(3)), upd.Copy.AddContents[1], "expected contents of copies in update")
+	assert.Equalf(t, []uint32{1, 2, 3}, upd.Copy.OrigUIDs, "expected UIDs of copies in update")
+	assert.Equalf(t, mailbox.mailModSeq("Archive", mailbox.Mails["Archive"][0]), upd.Copy.ModSeq, "expected MODSEQ of first copy in update")
+
+	// MOVE expunges the source mails
+	// after reporting COPYUID.
+	reply, err = run(CommandMove, "1:2 Archive")
+	assert.Nilf(t, err, "expected MOVE not to fail but got: %v", err)
+	assert.Equalf(t, fmt.Sprintf("* OK [COPYUID %d 1:2 4:5] Moved UIDs\r\n* 2 EXPUNGE\r\n* 1 EXPUNGE\r\na1 OK MOVE completed", validity), reply.Text, "unexpected answer to MOVE")
+	assert.Equalf(t, sourceMails[2:], mailbox.Mails["INBOX"], "expected moved mails to be removed")
+	assert.Equalf(t, sourceMails[2:], s.KnownMails, "expected client to know of moved mails being removed")
+	checkCopies(t, mailbox, "Archive", []int{3, 4}, []int{0, 1})
+
+	for _, sourceMail := range sourceMails[:2] {
+
+		_, err = os.Stat(filepath.Join(mailbox.FolderPath("INBOX"), "cur", sourceMail))
+		assert.Truef(t, os.IsNotExist(err), "expected moved mail %s to be removed", sourceMail)
+	}
+
+	upd = <-syncChan
+	assert.Equalf(t, "move", upd.Operation, "expected a MOVE update")
+	assert.Equalf(t, "INBOX", upd.Move.Mailbox, "unexpected source in update")
+	assert.Equalf(t, sourceMails[:2], upd.Move.RmvTags, "expected source mails to be removed in update")
+	assert.Equalf(t, "Archive", upd.Move.TargetMailbox, "unexpected target in update")
+	assert.Equalf(t, mailbox.Mails["Archive"][3:], upd.Move.AddTags, "expected copies as tags in update")
+	assert.Equalf(t, []uint32{4, 5}, upd.Move.OrigUIDs, "expected UIDs of copies in update")
+	assert.NotEqualf(t, "", upd.Move.AddTag, "expected a new tag declaring INBOX present")
+
+	// UID MOVE addresses mails by UID, which
+	// now differ from sequence numbers.
+	reply, err = run(CommandUID, "MOVE 4:* Archive")
+	assert.Nilf(t, err, "expected UID MOVE not to fail but got: %v", err)
+	assert.Equalf(t, fmt.Sprintf("* OK [COPYUID %d 4:5 6:7] Moved UIDs\r\n* 3 EXPUNGE\r\n* 2 EXPUNGE\r\na1 OK MOVE completed", validity), reply.Text, "unexpected answer to UID MOVE")
+	assert.Equalf(t, sourceMails[2:3], mailbox.Mails["INBOX"], "expected moved mails to be removed")
+	assert.Equalf(t, []uint32{3}, mailbox.folderUIDs("INBOX"), "expected remaining mail to keep its UID")
+	checkCopies(t, mailbox, "Archive", []int{5, 6}, []int{3, 4})
+
+	upd = <-syncChan
+	assert.Equalf(t, sourceMails[3:], upd.Move.RmvTags, "expected source mails to be removed in update")
+	assert.Equalf(t, []uint32{6, 7}, upd.Move.OrigUIDs, "expected UIDs of copies in update")
+}
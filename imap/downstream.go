@@ -1,7 +1,9 @@
 package imap
 
 import (
+	"fmt"
 	"os"
+	"time"
 
 	"path/filepath"
 
@@ -14,16 +16,30 @@ import (
 // of a CREATE operation.
 func (mailbox *Mailbox) ApplyCreate(createUpd *comm.Msg_CREATE) {
 
-	createMaildir := filepath.Join(mailbox.MaildirPath, createUpd.Mailbox)
+	// Wake up sessions waiting in IDLE
+	// after the update was applied.
+	defer mailbox.notify()
+
+	mailbox.Lock.Lock()
+	defer mailbox.Lock.Unlock()
+
+	mailbox.applyCreateFolder(createUpd.Mailbox, []string{createUpd.AddTag}, createUpd.UidValidity, "CREATE")
+	mailbox.setSpecialUse(createUpd.AddTag, createUpd.SpecialUse)
+}
+
+// applyCreateFolder performs the downstream effects of
+// creating folder with supplied tags and UIDVALIDITY base.
+// It is shared by CREATE and RENAME, whose name op is used
+// in log messages. The caller is required to hold the lock.
+func (mailbox *Mailbox) applyCreateFolder(folder string, tags []string, uidValidity uint32, op string) {
+
+	createMaildir := mailbox.FolderPath(folder)
 
 	// We need to track existence state of various
 	// file system objects in case we need to revert.
 	maildirExisted := true
 	msgSeqNumExisted := true
 
-	mailbox.Lock.Lock()
-	defer mailbox.Lock.Unlock()
-
 	// Only attempt to create the corresponding
 	// Maildir if it does not already exist.
 	_, err := os.Stat(createMaildir)
@@ -35,7 +51,7 @@ func (mailbox *Mailbox) ApplyCreate(createUpd *comm.Msg_CREATE) {
 		err = maildir.Dir(createMaildir).Create()
 		if err != nil {
 			level.Error(mailbox.Logger).Log(
-				"msg", "maildir for new mailbox folder could not be created in downstream CREATE execution",
+				"msg", fmt.Sprintf("maildir for new mailbox folder could not be created in downstream %s execution", op),
 				"err", err,
 			)
 			os.Exit(1)
@@ -45,41 +61,54 @@ func (mailbox *Mailbox) ApplyCreate(createUpd *comm.Msg_CREATE) {
 	// If no slice was found in mail message structure,
 	// initialize one for new mailbox to track message
 	// sequence numbers in it.
-	_, found := mailbox.Mails[createUpd.Mailbox]
+	_, found := mailbox.Mails[folder]
 	if !found {
 		msgSeqNumExisted = false
-		mailbox.Mails[createUpd.Mailbox] = make([]string, 0, 6)
+		mailbox.Mails[folder] = make([]string, 0, 6)
 	}
 
-	// Add a new mailbox folder in structure CRDT.
-	err = mailbox.Structure.AddEffect(createUpd.Mailbox, createUpd.AddTag, true)
+	// Merge received UIDVALIDITY base of folder.
+	err = mailbox.UIDs.SetBaseEffect(folder, uidValidity, true)
 	if err != nil {
-
 		level.Error(mailbox.Logger).Log(
-			"msg", "fail during downstream CREATE execution, will clean up",
+			"msg", fmt.Sprintf("failed to update UID CRDT in downstream %s execution", op),
 			"err", err,
 		)
+		os.Exit(1)
+	}
 
-		// If it did not exist, remove the just
-		// added slice from mail message map.
-		if !msgSeqNumExisted {
-			delete(mailbox.Mails, createUpd.Mailbox)
-		}
+	// Add a new mailbox folder in structure CRDT.
+	for _, tag := range tags {
 
-		// If it did not exist, attempt to remove
-		// the created Maildir.
-		if !maildirExisted {
+		err = mailbox.Structure.AddEffect(folder, tag, true)
+		if err != nil {
 
-			err = maildir.Dir(createMaildir).Remove()
-			if err != nil {
-				level.Error(mailbox.Logger).Log(
-					"msg", "failed to remove created Maildir during clean up of failed downstream CREATE execution",
-					"err", err,
-				)
+			level.Error(mailbox.Logger).Log(
+				"msg", fmt.Sprintf("fail during downstream %s execution, will clean up", op),
+				"err", err,
+			)
+
+			// If it did not exist, remove the just
+			// added slice from mail message map.
+			if !msgSeqNumExisted {
+				delete(mailbox.Mails, folder)
 			}
-		}
 
-		os.Exit(1)
+			// If it did not exist, attempt to remove
+			// the created Maildir.
+			if !maildirExisted {
+
+				err = maildir.Dir(createMaildir).Remove()
+				if err != nil {
+					level.Error(mailbox.Logger).Log(
+						"msg", fmt.Sprintf("failed to remove created Maildir during clean up of failed downstream %s execution", op),
+						"err", err,
+					)
+				}
+			}
+
+			os.Exit(1)
+		}
 	}
 }
 
@@ -87,78 +116,98 @@ func (mailbox *Mailbox) ApplyCreate(createUpd *comm.Msg_CREATE) {
 // of a DELETE operation.
 func (mailbox *Mailbox) ApplyDelete(deleteUpd *comm.Msg_DELETE) {
 
-	delMaildir := filepath.Join(mailbox.MaildirPath, deleteUpd.Mailbox)
-
-	rmElements := make(map[string]string)
-	for _, tag := range deleteUpd.RmvTags {
-		rmElements[tag] = deleteUpd.Mailbox
-	}
+	// Wake up sessions waiting in IDLE
+	// after the update was applied.
+	defer mailbox.notify()
 
 	mailbox.Lock.Lock()
 	defer mailbox.Lock.Unlock()
 
+	mailbox.applyDeleteFolder(deleteUpd.Mailbox, deleteUpd.RmvTags, deleteUpd.RmvMails, "DELETE")
+}
+
+// applyDeleteFolder performs the downstream effects of
+// removing the observed tags of folder. If concurrent
+// operations keep the folder alive, only the supplied
+// mail files are removed, otherwise the whole folder.
+// It is shared by DELETE and RENAME, whose name op is
+// used in log messages. The caller is required to hold
+// the lock.
+func (mailbox *Mailbox) applyDeleteFolder(folder string, rmvTags []string, rmvMails []string, op string) {
+
+	delMaildir := mailbox.FolderPath(folder)
+
+	rmElements := make(map[string]string)
+	for _, tag := range rmvTags {
+		rmElements[tag] = folder
+	}
+
 	// Remove received pairs from structure CRDT.
 	err := mailbox.Structure.RemoveEffect(rmElements, true)
 	if err != nil {
 		level.Error(mailbox.Logger).Log(
-			"msg", "failed to remove elements of mailbox folder to delete from user's structure CRDT",
+			"msg", fmt.Sprintf("failed to remove elements of mailbox folder from user's structure CRDT in downstream %s execution", op),
 			"err", err,
 		)
 		os.Exit(1)
 	}
 
-	if mailbox.Structure.Lookup(deleteUpd.Mailbox) {
+	mailbox.dropSpecialUse(rmvTags)
+
+	if mailbox.Structure.Lookup(folder) {
 
 		// Concurrent IMAP operations have declared interest in
 		// this mailbox by adding elements to the structure CRDT.
 		// Do not remove the underlying files. Instead, delete
 		// the mail files sent by the source node as representing
-		// the folder's content at the time of DELETE issuance.
+		// the folder's content at the time of the operation.
 
-		for _, mail := range deleteUpd.RmvMails {
+		for _, mail := range rmvMails {
 
-			var delFileName string
-			if deleteUpd.Mailbox == "INBOX" {
-				delFileName = filepath.Join(mailbox.MaildirPath, "cur", mail)
-			} else {
-				delFileName = filepath.Join(mailbox.MaildirPath, deleteUpd.Mailbox, "cur", mail)
-			}
+			delFileName := filepath.Join(mailbox.FolderPath(folder), "cur", mail)
 
-			// Delete the file system object.
+			// Delete the file system object. A concurrent
+			// operation might already have removed it.
 			err := os.Remove(delFileName)
-			if err != nil {
+			if (err != nil) && !os.IsNotExist(err) {
 				level.Error(mailbox.Logger).Log(
-					"msg", "failed to remove an underlying mail file in downstream DELETE execution",
+					"msg", fmt.Sprintf("failed to remove an underlying mail file in downstream %s execution", op),
 					"err", err,
 				)
 				os.Exit(1)
 			}
 
-			// As well as the mail's entries in the
-			// internal sequence number representation.
-			for msgNum, msgName := range mailbox.Mails[deleteUpd.Mailbox] {
+			// As well as the mail's entries in the message
+			// index and sequence number representation.
+			mailbox.Index.Remove(folder, MailKey(mail))
+
+			for msgNum, msgName := range mailbox.Mails[folder] {
 
 				if msgName == mail {
 
 					realMsgNum := msgNum + 1
-					mailbox.Mails[deleteUpd.Mailbox] = append(mailbox.Mails[deleteUpd.Mailbox][:msgNum], mailbox.Mails[deleteUpd.Mailbox][realMsgNum:]...)
+					mailbox.Mails[folder] = append(mailbox.Mails[folder][:msgNum], mailbox.Mails[folder][realMsgNum:]...)
+
+					break
 				}
 			}
 		}
 
 	} else {
 
-		// This DELETE operation removed the entire presence of this
+		// This operation removed the entire presence of this
 		// mailbox folder from the user's mailbox. Thus, file system
 		// clean up of files and folders, and internal state
 		// representation manipulation is due.
 
 		// Remove slice from contents map if present.
-		_, found := mailbox.Mails[deleteUpd.Mailbox]
+		_, found := mailbox.Mails[folder]
 		if found {
-			delete(mailbox.Mails, deleteUpd.Mailbox)
+			delete(mailbox.Mails, folder)
 		}
 
+		mailbox.Index.RemoveFolder(folder)
+
 		// Remove files associated with deleted mailbox
 		// from stable storage, if present.
 		_, err = os.Stat(delMaildir)
@@ -167,7 +216,7 @@ func (mailbox *Mailbox) ApplyDelete(deleteUpd *comm.Msg_DELETE) {
 			err = maildir.Dir(delMaildir).Remove()
 			if err != nil {
 				level.Error(mailbox.Logger).Log(
-					"msg", "failed to remove Maildir in downstream DELETE execution",
+					"msg", fmt.Sprintf("failed to remove Maildir in downstream %s execution", op),
 					"err", err,
 				)
 				os.Exit(1)
@@ -184,26 +233,37 @@ func (mailbox *Mailbox) ApplyAppend(appendUpd *comm.Msg_APPEND) {
 	// the folder name as value and the mail file name
 	// as tag in downstream message.
 
+	// Wake up sessions waiting in IDLE
+	// after the update was applied.
+	defer mailbox.notify()
+
+	mailbox.Lock.Lock()
+	defer mailbox.Lock.Unlock()
+
+	mailbox.applyKeywords(appendUpd.Keywords, "APPEND")
+	mailbox.applyAddMail(appendUpd.Mailbox, appendUpd.AddTag, appendUpd.AddContent, appendUpd.OrigUID, appendUpd.InternalDate, appendUpd.ModSeq, "APPEND")
+}
+
+// applyAddMail performs the downstream effects of adding
+// one mail with supplied file name as tag and content to
+// folder, recreating the folder if it was concurrently
+// deleted. It is shared by APPEND, COPY, and MOVE, whose
+// name op is used in log messages. If internalDate is not
+// zero, it is set as modification time of the mail file.
+// The mail is assigned at least the MODSEQ modSeq.
+// The caller is required to hold the exclusive mailbox lock.
+func (mailbox *Mailbox) applyAddMail(folder string, tag string, content []byte, origUID uint32, internalDate int64, modSeq uint64, op string) {
+
 	// We need to track if we had to create the
 	// mailbox folder in case we need to revert.
 	createdMailbox := false
 
-	appendMaildir := mailbox.MaildirPath
-	var appendFileName string
-
-	if appendUpd.Mailbox == "INBOX" {
-		appendFileName = filepath.Join(mailbox.MaildirPath, "cur", appendUpd.AddTag)
-	} else {
-		appendMaildir = filepath.Join(mailbox.MaildirPath, appendUpd.Mailbox)
-		appendFileName = filepath.Join(mailbox.MaildirPath, appendUpd.Mailbox, "cur", appendUpd.AddTag)
-	}
-
-	mailbox.Lock.Lock()
-	defer mailbox.Lock.Unlock()
+	appendMaildir := mailbox.FolderPath(folder)
+	appendFileName := filepath.Join(appendMaildir, "cur", tag)
 
 	// Check if the specified mailbox folder to append the message to
 	// is not present. If that is the case, create the mailbox folder.
-	if !mailbox.Structure.Lookup(appendUpd.Mailbox) {
+	if !mailbox.Structure.Lookup(folder) {
 
 		createdMailbox = true
 
@@ -213,16 +273,16 @@ func (mailbox *Mailbox) ApplyAppend(appendUpd *comm.Msg_APPEND) {
 			err = maildir.Dir(appendMaildir).Create()
 			if err != nil {
 				level.Error(mailbox.Logger).Log(
-					"msg", "missing mailbox folder could not be created in downstream APPEND execution",
+					"msg", fmt.Sprintf("missing mailbox folder could not be created in downstream %s execution", op),
 					"err", err,
 				)
 				os.Exit(1)
 			}
 		}
 
-		_, found := mailbox.Mails[appendUpd.Mailbox]
+		_, found := mailbox.Mails[folder]
 		if !found {
-			mailbox.Mails[appendUpd.Mailbox] = make([]string, 0, 6)
+			mailbox.Mails[folder] = make([]string, 0, 6)
 		}
 	}
 
@@ -231,7 +291,7 @@ func (mailbox *Mailbox) ApplyAppend(appendUpd *comm.Msg_APPEND) {
 	if err != nil {
 
 		level.Error(mailbox.Logger).Log(
-			"msg", "failed to create file for mail to append in downstream APPEND execution",
+			"msg", fmt.Sprintf("failed to create file for mail to append in downstream %s execution", op),
 			"err", err,
 		)
 
@@ -239,12 +299,12 @@ func (mailbox *Mailbox) ApplyAppend(appendUpd *comm.Msg_APPEND) {
 		// remove that state again.
 		if createdMailbox {
 
-			delete(mailbox.Mails, appendUpd.Mailbox)
+			delete(mailbox.Mails, folder)
 
 			err = maildir.Dir(appendMaildir).Remove()
 			if err != nil {
 				level.Error(mailbox.Logger).Log(
-					"msg", "failed to remove created Maildir during clean up of failed downstream APPEND execution",
+					"msg", fmt.Sprintf("failed to remove created Maildir during clean up of failed downstream %s execution", op),
 					"err", err,
 				)
 			}
@@ -254,11 +314,11 @@ func (mailbox *Mailbox) ApplyAppend(appendUpd *comm.Msg_APPEND) {
 	}
 
 	// Write received message content to created file.
-	_, err = appendFile.Write(appendUpd.AddContent)
+	_, err = appendFile.Write(content)
 	if err != nil {
 
 		level.Error(mailbox.Logger).Log(
-			"msg", "failed to write message content in downstream APPEND execution",
+			"msg", fmt.Sprintf("failed to write message content in downstream %s execution", op),
 			"err", err,
 		)
 
@@ -266,19 +326,19 @@ func (mailbox *Mailbox) ApplyAppend(appendUpd *comm.Msg_APPEND) {
 		err = os.Remove(appendFileName)
 		if err != nil {
 			level.Error(mailbox.Logger).Log(
-				"msg", "failed to remove created mail file during clean up of failed downstream APPEND execution",
+				"msg", fmt.Sprintf("failed to remove created mail file during clean up of failed downstream %s execution", op),
 				"err", err,
 			)
 		}
 
 		if createdMailbox {
 
-			delete(mailbox.Mails, appendUpd.Mailbox)
+			delete(mailbox.Mails, folder)
 
 			err = maildir.Dir(appendMaildir).Remove()
 			if err != nil {
 				level.Error(mailbox.Logger).Log(
-					"msg", "failed to remove created Maildir during clean up of failed downstream APPEND execution",
+					"msg", fmt.Sprintf("failed to remove created Maildir during clean up of failed downstream %s execution", op),
 					"err", err,
 				)
 			}
@@ -292,26 +352,26 @@ func (mailbox *Mailbox) ApplyAppend(appendUpd *comm.Msg_APPEND) {
 	if err != nil {
 
 		level.Error(mailbox.Logger).Log(
-			"msg", "failed to sync message to stable storage in downstream APPEND execution",
+			"msg", fmt.Sprintf("failed to sync message to stable storage in downstream %s execution", op),
 			"err", err,
 		)
 
 		err = os.Remove(appendFileName)
 		if err != nil {
 			level.Error(mailbox.Logger).Log(
-				"msg", "failed to remove created mail file during clean up of failed downstream APPEND execution",
+				"msg", fmt.Sprintf("failed to remove created mail file during clean up of failed downstream %s execution", op),
 				"err", err,
 			)
 		}
 
 		if createdMailbox {
 
-			delete(mailbox.Mails, appendUpd.Mailbox)
+			delete(mailbox.Mails, folder)
 
 			err = maildir.Dir(appendMaildir).Remove()
 			if err != nil {
 				level.Error(mailbox.Logger).Log(
-					"msg", "failed to remove created Maildir during clean up of failed downstream APPEND execution",
+					"msg", fmt.Sprintf("failed to remove created Maildir during clean up of failed downstream %s execution", op),
 					"err", err,
 				)
 			}
@@ -320,38 +380,63 @@ func (mailbox *Mailbox) ApplyAppend(appendUpd *comm.Msg_APPEND) {
 		os.Exit(1)
 	}
 
-	// Append new mail file name to message sequence
+	// Carry over the internal date set at the source node.
+	if internalDate != 0 {
+
+		err = os.Chtimes(appendFileName, time.Unix(internalDate, 0), time.Unix(internalDate, 0))
+		if err != nil {
+			level.Error(mailbox.Logger).Log(
+				"msg", fmt.Sprintf("failed to set internal date of mail file in downstream %s execution", op),
+				"err", err,
+			)
+			os.Exit(1)
+		}
+	}
+
+	// Record the UID the source node assigned.
+	err = mailbox.UIDs.AddEffect(folder, origUID, MailKey(tag), true)
+	if err != nil {
+		level.Error(mailbox.Logger).Log(
+			"msg", fmt.Sprintf("failed to update UID CRDT in downstream %s execution", op),
+			"err", err,
+		)
+		os.Exit(1)
+	}
+
+	// Insert new mail file name into message sequence
 	// numbers tracking structure.
 	// Mind: tag in this case means mail file name.
-	mailbox.Mails[appendUpd.Mailbox] = append(mailbox.Mails[appendUpd.Mailbox], appendUpd.AddTag)
+	mailbox.insertMail(folder, tag)
+	mailbox.indexMail(folder, appendFileName, content)
+	mailbox.recordChange(folder, MailKey(tag), modSeq, op)
 
 	// Declare interest of the APPEND operation in the involved
 	// mailbox folder by putting the mailbox-file-name pair
 	// into the structure OR-Set.
-	err = mailbox.Structure.AddEffect(appendUpd.Mailbox, appendUpd.AddTag, true)
+	err = mailbox.Structure.AddEffect(folder, tag, true)
 	if err != nil {
 
 		level.Error(mailbox.Logger).Log(
-			"msg", "failed to update structure OR-Set in downstream APPEND execution",
+			"msg", fmt.Sprintf("failed to update structure OR-Set in downstream %s execution", op),
 			"err", err,
 		)
 
 		err = os.Remove(appendFileName)
 		if err != nil {
 			level.Error(mailbox.Logger).Log(
-				"msg", "failed to remove created mail file during clean up of failed downstream APPEND execution",
+				"msg", fmt.Sprintf("failed to remove created mail file during clean up of failed downstream %s execution", op),
 				"err", err,
 			)
 		}
 
 		if createdMailbox {
 
-			delete(mailbox.Mails, appendUpd.Mailbox)
+			delete(mailbox.Mails, folder)
 
 			err = maildir.Dir(appendMaildir).Remove()
 			if err != nil {
 				level.Error(mailbox.Logger).Log(
-					"msg", "failed to remove created Maildir during clean up of failed downstream APPEND execution",
+					"msg", fmt.Sprintf("failed to remove created Maildir during clean up of failed downstream %s execution", op),
 					"err", err,
 				)
 			}
@@ -365,43 +450,52 @@ func (mailbox *Mailbox) ApplyAppend(appendUpd *comm.Msg_APPEND) {
 // of an EXPUNGE operation.
 func (mailbox *Mailbox) ApplyExpunge(expungeUpd *comm.Msg_EXPUNGE) {
 
-	createdMailbox := false
+	// Wake up sessions waiting in IDLE
+	// after the update was applied.
+	defer mailbox.notify()
 
-	rmElements := map[string]string{
-		expungeUpd.RmvTag: expungeUpd.Mailbox,
-	}
+	mailbox.Lock.Lock()
+	defer mailbox.Lock.Unlock()
 
-	expungeMaildir := mailbox.MaildirPath
-	var delFileName string
+	mailbox.applyRemoveMail(expungeUpd.Mailbox, expungeUpd.RmvTag, expungeUpd.ModSeq, "EXPUNGE")
+	mailbox.applyFolderInterest(expungeUpd.Mailbox, expungeUpd.AddTag, "EXPUNGE")
+}
 
-	if expungeUpd.Mailbox == "INBOX" {
-		delFileName = filepath.Join(mailbox.MaildirPath, "cur", expungeUpd.RmvTag)
-	} else {
-		expungeMaildir = filepath.Join(mailbox.MaildirPath, expungeUpd.Mailbox)
-		delFileName = filepath.Join(mailbox.MaildirPath, expungeUpd.Mailbox, "cur", expungeUpd.RmvTag)
+// applyRemoveMail performs the downstream effects of
+// removing the mail with supplied file name as tag from
+// folder. It is shared by EXPUNGE and MOVE, whose name
+// op is used in log messages. The removal is assigned at
+// least the MODSEQ modSeq. The caller is required to
+// hold the exclusive mailbox lock.
+func (mailbox *Mailbox) applyRemoveMail(folder string, tag string, modSeq uint64, op string) {
+
+	rmElements := map[string]string{
+		tag: folder,
 	}
 
-	mailbox.Lock.Lock()
-	defer mailbox.Lock.Unlock()
+	delFileName := filepath.Join(mailbox.FolderPath(folder), "cur", tag)
 
 	err := mailbox.Structure.RemoveEffect(rmElements, true)
 	if err != nil {
 		level.Error(mailbox.Logger).Log(
-			"msg", "failed to remove mail elements from structure CRDT in downstream EXPUNGE execution",
+			"msg", fmt.Sprintf("failed to remove mail elements from structure CRDT in downstream %s execution", op),
 			"err", err,
 		)
 		os.Exit(1)
 	}
 
-	for msgNum, msgName := range mailbox.Mails[expungeUpd.Mailbox] {
+	mailbox.Index.Remove(folder, MailKey(tag))
+	mailbox.recordExpunge(folder, MailKey(tag), modSeq, op)
+
+	for msgNum, msgName := range mailbox.Mails[folder] {
 
 		// Find removed mail file's sequence number.
-		if msgName == expungeUpd.RmvTag {
+		if msgName == tag {
 
 			// Delete mail's sequence number from message
 			// sequence number tracking structure.
 			realMsgNum := msgNum + 1
-			mailbox.Mails[expungeUpd.Mailbox] = append(mailbox.Mails[expungeUpd.Mailbox][:msgNum], mailbox.Mails[expungeUpd.Mailbox][realMsgNum:]...)
+			mailbox.Mails[folder] = append(mailbox.Mails[folder][:msgNum], mailbox.Mails[folder][realMsgNum:]...)
 		}
 	}
 
@@ -413,46 +507,58 @@ func (mailbox *Mailbox) ApplyExpunge(expungeUpd *comm.Msg_EXPUNGE) {
 		// of the file is an error we need to handle.
 		if !os.IsNotExist(err) {
 			level.Error(mailbox.Logger).Log(
-				"msg", "failed to remove underlying mail file in downstream EXPUNGE execution",
+				"msg", fmt.Sprintf("failed to remove underlying mail file in downstream %s execution", op),
 				"err", err,
 			)
 			os.Exit(1)
 		}
 	}
+}
 
-	// Check if the specified mailbox folder to remove the message from
-	// is not present. If that is the case, create the mailbox folder.
-	if !mailbox.Structure.Lookup(expungeUpd.Mailbox) {
+// applyFolderInterest adds the pair of folder and tag to
+// the structure CRDT, declaring the interest of an update
+// operation in the folder. If the folder was concurrently
+// deleted, it is recreated. The caller is required to
+// hold the exclusive mailbox lock.
+func (mailbox *Mailbox) applyFolderInterest(folder string, tag string, op string) {
+
+	createdMailbox := false
+
+	interestMaildir := mailbox.FolderPath(folder)
+
+	// Check if the specified mailbox folder is not present.
+	// If that is the case, create the mailbox folder.
+	if !mailbox.Structure.Lookup(folder) {
 
 		createdMailbox = true
 
-		_, err := os.Stat(expungeMaildir)
+		_, err := os.Stat(interestMaildir)
 		if os.IsNotExist(err) {
 
-			err = maildir.Dir(expungeMaildir).Create()
+			err = maildir.Dir(interestMaildir).Create()
 			if err != nil {
 				level.Error(mailbox.Logger).Log(
-					"msg", "missing mailbox folder could not be created in downstream EXPUNGE execution",
+					"msg", fmt.Sprintf("missing mailbox folder could not be created in downstream %s execution", op),
 					"err", err,
 				)
 				os.Exit(1)
 			}
 		}
 
-		_, found := mailbox.Mails[expungeUpd.Mailbox]
+		_, found := mailbox.Mails[folder]
 		if !found {
-			mailbox.Mails[expungeUpd.Mailbox] = make([]string, 0, 6)
+			mailbox.Mails[folder] = make([]string, 0, 6)
 		}
 	}
 
 	// Add the mailbox-addTag pair to structure CRDT.
 	// This declares the interest of this operation in
 	// the upper-level mailbox folder.
-	err = mailbox.Structure.AddEffect(expungeUpd.Mailbox, expungeUpd.AddTag, true)
+	err := mailbox.Structure.AddEffect(folder, tag, true)
 	if err != nil {
 
 		level.Error(mailbox.Logger).Log(
-			"msg", "fail during downstream EXPUNGE execution, will clean up",
+			"msg", fmt.Sprintf("fail during downstream %s execution, will clean up", op),
 			"err", err,
 		)
 
@@ -460,12 +566,12 @@ func (mailbox *Mailbox) ApplyExpunge(expungeUpd *comm.Msg_EXPUNGE) {
 		// remove that state again.
 		if createdMailbox {
 
-			delete(mailbox.Mails, expungeUpd.Mailbox)
+			delete(mailbox.Mails, folder)
 
-			err = maildir.Dir(expungeMaildir).Remove()
+			err = maildir.Dir(interestMaildir).Remove()
 			if err != nil {
 				level.Error(mailbox.Logger).Log(
-					"msg", "failed to remove created Maildir during clean up of failed downstream EXPUNGE execution",
+					"msg", fmt.Sprintf("failed to remove created Maildir during clean up of failed downstream %s execution", op),
 					"err", err,
 				)
 			}
@@ -475,6 +581,27 @@ func (mailbox *Mailbox) ApplyExpunge(expungeUpd *comm.Msg_EXPUNGE) {
 	}
 }
 
+// applyKeywords merges the keyword bindings sent along
+// with an APPEND or STORE into the keyword CRDT, so that
+// letters in the mail file name resolve to the same
+// keywords as at the source. The caller is required to
+// hold the exclusive mailbox lock.
+func (mailbox *Mailbox) applyKeywords(bindings []string, op string) {
+
+	if len(bindings) == 0 {
+		return
+	}
+
+	err := mailbox.Keywords.MergeEffect(bindings, true)
+	if err != nil {
+		level.Error(mailbox.Logger).Log(
+			"msg", fmt.Sprintf("failed to merge keywords into keyword CRDT in downstream %s execution", op),
+			"err", err,
+		)
+		os.Exit(1)
+	}
+}
+
 // ApplyStore performs the downstream part
 // of a STORE operation.
 func (mailbox *Mailbox) ApplyStore(storeUpd *comm.Msg_STORE) {
@@ -485,22 +612,19 @@ func (mailbox *Mailbox) ApplyStore(storeUpd *comm.Msg_STORE) {
 		storeUpd.RmvTag: storeUpd.Mailbox,
 	}
 
-	storeMaildir := mailbox.MaildirPath
-	var delFileName string
-	var storeFileName string
+	storeMaildir := mailbox.FolderPath(storeUpd.Mailbox)
+	delFileName := filepath.Join(storeMaildir, "cur", storeUpd.RmvTag)
+	storeFileName := filepath.Join(storeMaildir, "cur", storeUpd.AddTag)
 
-	if storeUpd.Mailbox == "INBOX" {
-		delFileName = filepath.Join(mailbox.MaildirPath, "cur", storeUpd.RmvTag)
-		storeFileName = filepath.Join(mailbox.MaildirPath, "cur", storeUpd.AddTag)
-	} else {
-		storeMaildir = filepath.Join(mailbox.MaildirPath, storeUpd.Mailbox)
-		delFileName = filepath.Join(mailbox.MaildirPath, storeUpd.Mailbox, "cur", storeUpd.RmvTag)
-		storeFileName = filepath.Join(mailbox.MaildirPath, storeUpd.Mailbox, "cur", storeUpd.AddTag)
-	}
+	// Wake up sessions waiting in IDLE
+	// after the update was applied.
+	defer mailbox.notify()
 
 	mailbox.Lock.Lock()
 	defer mailbox.Lock.Unlock()
 
+	mailbox.applyKeywords(storeUpd.Keywords, "STORE")
+
 	err := mailbox.Structure.RemoveEffect(rmElements, true)
 	if err != nil {
 		level.Error(mailbox.Logger).Log(
@@ -678,6 +802,10 @@ func (mailbox *Mailbox) ApplyStore(storeUpd *comm.Msg_STORE) {
 		os.Exit(1)
 	}
 
+	// Refresh the mail's entry in the message index.
+	mailbox.indexMail(storeUpd.Mailbox, storeFileName, storeUpd.AddContent)
+	mailbox.recordChange(storeUpd.Mailbox, MailKey(storeUpd.AddTag), storeUpd.ModSeq, "STORE")
+
 	for msgNum, msgName := range mailbox.Mails[storeUpd.Mailbox] {
 
 		// Find old mail file's sequence number.
@@ -688,3 +816,131 @@ func (mailbox *Mailbox) ApplyStore(storeUpd *comm.Msg_STORE) {
 		}
 	}
 }
+
+// ApplyCopy performs the downstream part
+// of a COPY operation.
+func (mailbox *Mailbox) ApplyCopy(copyUpd *comm.Msg_COPY) {
+
+	// Wake up sessions waiting in IDLE
+	// after the update was applied.
+	defer mailbox.notify()
+
+	mailbox.Lock.Lock()
+	defer mailbox.Lock.Unlock()
+
+	// Each copied mail is added to the target folder like
+	// an appended one. As the copies carry new tags, they
+	// keep the target folder alive in case of a concurrent
+	// DELETE, which is the same outcome as for APPEND.
+	for i, addTag := range copyUpd.AddTags {
+		mailbox.applyAddMail(copyUpd.TargetMailbox, addTag, copyUpd.AddContents[i], copyUpd.OrigUIDs[i], 0, copyUpd.ModSeq, "COPY")
+	}
+}
+
+// ApplyMove performs the downstream part
+// of a MOVE operation.
+func (mailbox *Mailbox) ApplyMove(moveUpd *comm.Msg_MOVE) {
+
+	// Wake up sessions waiting in IDLE
+	// after the update was applied.
+	defer mailbox.notify()
+
+	mailbox.Lock.Lock()
+	defer mailbox.Lock.Unlock()
+
+	// Add the mails to the target folder first, so that
+	// they are never missing from both folders.
+	for i, addTag := range moveUpd.AddTags {
+		mailbox.applyAddMail(moveUpd.TargetMailbox, addTag, moveUpd.AddContents[i], moveUpd.OrigUIDs[i], 0, moveUpd.ModSeq, "MOVE")
+	}
+
+	// Afterwards, remove them from the source folder
+	// exactly as an EXPUNGE would.
+	for _, rmvTag := range moveUpd.RmvTags {
+		mailbox.applyRemoveMail(moveUpd.Mailbox, rmvTag, 0, "MOVE")
+	}
+
+	mailbox.applyFolderInterest(moveUpd.Mailbox, moveUpd.AddTag, "MOVE")
+}
+
+// ApplyRename performs the downstream part
+// of a RENAME operation.
+func (mailbox *Mailbox) ApplyRename(renameUpd *comm.Msg_RENAME) {
+
+	// Wake up sessions waiting in IDLE
+	// after the update was applied.
+	defer mailbox.notify()
+
+	mailbox.Lock.Lock()
+	defer mailbox.Lock.Unlock()
+
+	for _, folder := range renameUpd.Folders {
+
+		// Create the new folder with the mails of the old
+		// one under their new names. As all tags are new,
+		// a concurrent DELETE of the new name does not
+		// affect them, and a concurrent RENAME of the same
+		// folder to another name results in both folders.
+		mailbox.applyCreateFolder(folder.NewMailbox, folder.AddTags, folder.UidValidity, "RENAME")
+		mailbox.setSpecialUse(folder.AddTags[0], folder.SpecialUse)
+
+		for i, addMail := range folder.AddMails {
+			mailbox.applyAddMail(folder.NewMailbox, addMail, folder.AddContents[i], folder.OrigUIDs[i], 0, 0, "RENAME")
+		}
+
+		// Renaming INBOX leaves it empty, so its
+		// mails count as expunged.
+		if folder.Mailbox == "INBOX" {
+
+			for _, rmvMail := range folder.RmvMails {
+				mailbox.recordExpunge(folder.Mailbox, MailKey(rmvMail), 0, "RENAME")
+			}
+		}
+
+		// Remove the observed state of the old folder. Mails
+		// concurrently added to it keep it present.
+		mailbox.applyDeleteFolder(folder.Mailbox, folder.RmvTags, folder.RmvMails, "RENAME")
+		mailbox.unselectRenamed(folder.Mailbox)
+	}
+}
+
+// ApplySubscribe performs the downstream part
+// of a SUBSCRIBE operation.
+func (mailbox *Mailbox) ApplySubscribe(subscribeUpd *comm.Msg_SUBSCRIBE) {
+
+	mailbox.Lock.Lock()
+	defer mailbox.Lock.Unlock()
+
+	// Add received pair to subscription CRDT.
+	err := mailbox.Subscriptions.AddEffect(subscribeUpd.Mailbox, subscribeUpd.AddTag, true)
+	if err != nil {
+		level.Error(mailbox.Logger).Log(
+			"msg", "failed to add folder to user's subscription CRDT in downstream SUBSCRIBE execution",
+			"err", err,
+		)
+		os.Exit(1)
+	}
+}
+
+// ApplyUnsubscribe performs the downstream part
+// of an UNSUBSCRIBE operation.
+func (mailbox *Mailbox) ApplyUnsubscribe(unsubscribeUpd *comm.Msg_UNSUBSCRIBE) {
+
+	mailbox.Lock.Lock()
+	defer mailbox.Lock.Unlock()
+
+	rmElements := make(map[string]string)
+	for _, tag := range unsubscribeUpd.RmvTags {
+		rmElements[tag] = unsubscribeUpd.Mailbox
+	}
+
+	// Remove received pairs from subscription CRDT.
+	err := mailbox.Subscriptions.RemoveEffect(rmElements, true)
+	if err != nil {
+		level.Error(mailbox.Logger).Log(
+			"msg", "failed to remove folder from user's subscription CRDT in downstream UNSUBSCRIBE execution",
+			"err", err,
+		)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,92 @@
+package imap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-pluto/pluto/comm"
+)
+
+// Variables
+
+// enablers maps the names of all extensions a client
+// may turn on via ENABLE to the function doing so for
+// a session. Each function returns true if the extension
+// was not enabled before. Extensions that change the
+// behaviour of a session register here.
+var enablers = map[string]func(s *Session) bool{
+	"UTF8=ACCEPT": func(s *Session) bool {
+		enabled := !s.UTF8Accept
+		s.UTF8Accept = true
+		return enabled
+	},
+	"CONDSTORE": func(s *Session) bool {
+		enabled := !s.CondStore
+		s.CondStore = true
+		return enabled
+	},
+	"QRESYNC": func(s *Session) bool {
+
+		// QRESYNC implies CONDSTORE.
+		enabled := !s.QResync
+		s.QResync = true
+		s.CondStore = true
+		return enabled
+	},
+}
+
+// Functions
+
+// Enable turns on the requested extensions for the
+// remainder of the session as defined in RFC 5161.
+// Supported are all extensions in enablers. Unknown
+// extensions are ignored and extensions already
+// enabled are not reported again.
+func (mailbox *Mailbox) Enable(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+
+	if s.State != StateAuthenticated {
+
+		// ENABLE is only valid in authenticated state,
+		// i.e. before a mailbox was selected. This is
+		// a client error. Send tagged BAD response.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command ENABLE is only valid in authenticated state", req.Tag),
+		}, nil
+	}
+
+	if len(req.Args) == 0 {
+
+		// If no extension was named, this is a client
+		// error. Return BAD statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command ENABLE was sent without extensions", req.Tag),
+		}, nil
+	}
+
+	enabled := make([]string, 0, len(req.Args))
+
+	for _, arg := range req.Args {
+
+		if arg.Type != NodeAtom {
+			return &Reply{
+				Text: fmt.Sprintf("%s BAD Command ENABLE was sent with invalid extensions", req.Tag),
+			}, nil
+		}
+
+		name := strings.ToUpper(arg.Value)
+
+		enable, found := enablers[name]
+		if found && enable(s) {
+			enabled = append(enabled, name)
+		}
+	}
+
+	return &Reply{
+		Text: fmt.Sprintf("%s\r\n%s OK ENABLE completed", strings.Join(append([]string{"* ENABLED"}, enabled...), " "), req.Tag),
+	}, nil
+}
+
+// init registers the ENABLE capability.
+func init() {
+	RegisterCapability(&Capability{Name: "ENABLE", PostAuth: true, Commands: []string{CommandEnable}})
+}
@@ -0,0 +1,992 @@
+package imap
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"mime"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"io/ioutil"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+
+	"github.com/go-pluto/maildir"
+	"github.com/go-pluto/pluto/comm"
+)
+
+// Constants
+
+// Layout of date-time values in IMAP responses
+// such as INTERNALDATE.
+const dateTimeLayout = "02-Jan-2006 15:04:05 -0700"
+
+// Variables
+
+// fetchMacros maps the three FETCH macros
+// to the data items they stand for.
+var fetchMacros = map[string]string{
+	"ALL":  "FLAGS INTERNALDATE RFC822.SIZE ENVELOPE",
+	"FAST": "FLAGS INTERNALDATE RFC822.SIZE",
+	"FULL": "FLAGS INTERNALDATE RFC822.SIZE ENVELOPE BODY",
+}
+
+// fetchSimpleItems contains all FETCH data items
+// that do not carry a section specification.
+var fetchSimpleItems = map[string]bool{
+	"FLAGS":         true,
+	"INTERNALDATE":  true,
+	"RFC822.SIZE":   true,
+	"ENVELOPE":      true,
+	"BODYSTRUCTURE": true,
+	"BODY":          true,
+	"RFC822":        true,
+	"RFC822.HEADER": true,
+	"RFC822.TEXT":   true,
+	"UID":           true,
+	"MODSEQ":        true,
+}
+
+// Structs
+
+// FetchItem represents one data item a client requested
+// in a FETCH command, for example FLAGS, ENVELOPE, or
+// BODY.PEEK[1.HEADER.FIELDS (From To)]<0.512>.
+type FetchItem struct {
+	Name       string
+	Peek       bool
+	HasSection bool
+	Part       []int
+	Specifier  string
+	Fields     []string
+	Partial    bool
+	Offset     int
+	Length     int
+}
+
+// messagePart is the parsed representation of a mail
+// message or one of its MIME body parts. It keeps the
+// raw bytes of header and body so that sections can be
+// returned to clients exactly as stored.
+type messagePart struct {
+	Raw       []byte
+	Header    []byte
+	Body      []byte
+	Fields    textproto.MIMEHeader
+	MediaType string
+	Params    map[string]string
+	Children  []*messagePart
+	Message   *messagePart
+}
+
+// Functions
+
+// ParseFetchItems takes in the data items argument of a
+// FETCH request, e.g. "(FLAGS BODY.PEEK[HEADER])" or
+// "FAST", and returns the list of requested items in order.
+func ParseFetchItems(recv *Node) ([]*FetchItem, error) {
+
+	var tokens []string
+
+	switch recv.Type {
+
+	case NodeAtom:
+
+		// Expand macros first.
+		if macro, found := fetchMacros[strings.ToUpper(recv.Value)]; found {
+			tokens = strings.Fields(macro)
+		} else {
+			tokens = []string{recv.Value}
+		}
+
+	case NodeList:
+
+		tokens = make([]string, 0, len(recv.Items))
+
+		for _, item := range recv.Items {
+
+			if item.Type != NodeAtom {
+				return nil, fmt.Errorf("Command FETCH was sent with invalid parenthesized data items list")
+			}
+
+			tokens = append(tokens, item.Value)
+		}
+
+	default:
+		return nil, fmt.Errorf("Command FETCH was sent with invalid data items")
+	}
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("Command FETCH was sent without data items")
+	}
+
+	items := make([]*FetchItem, 0, len(tokens))
+
+	for _, token := range tokens {
+
+		item, err := parseFetchItem(token)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// parseFetchItem parses one data item of a FETCH request.
+func parseFetchItem(token string) (*FetchItem, error) {
+
+	upper := strings.ToUpper(token)
+
+	open := strings.IndexByte(upper, '[')
+	if open < 0 {
+
+		if !fetchSimpleItems[upper] {
+			return nil, fmt.Errorf("Command FETCH was sent with unknown data item %s", token)
+		}
+
+		return &FetchItem{
+			Name: upper,
+		}, nil
+	}
+
+	item := &FetchItem{
+		Name:       "BODY",
+		HasSection: true,
+	}
+
+	switch upper[:open] {
+	case "BODY":
+	case "BODY.PEEK":
+		item.Peek = true
+	default:
+		return nil, fmt.Errorf("Command FETCH was sent with unknown data item %s", token)
+	}
+
+	close := strings.LastIndexByte(upper, ']')
+	if close < open {
+		return nil, fmt.Errorf("Command FETCH was sent with unbalanced section brackets")
+	}
+
+	err := item.parseSection(token[(open + 1):close])
+	if err != nil {
+		return nil, err
+	}
+
+	partial := token[(close + 1):]
+	if partial != "" {
+
+		if !strings.HasPrefix(partial, "<") || !strings.HasSuffix(partial, ">") {
+			return nil, fmt.Errorf("Command FETCH was sent with invalid partial specification")
+		}
+
+		octets := strings.SplitN(partial[1:(len(partial)-1)], ".", 2)
+		if len(octets) != 2 {
+			return nil, fmt.Errorf("Command FETCH was sent with invalid partial specification")
+		}
+
+		item.Offset, err = strconv.Atoi(octets[0])
+		if (err != nil) || (item.Offset < 0) {
+			return nil, fmt.Errorf("Command FETCH was sent with invalid partial specification")
+		}
+
+		item.Length, err = strconv.Atoi(octets[1])
+		if (err != nil) || (item.Length < 1) {
+			return nil, fmt.Errorf("Command FETCH was sent with invalid partial specification")
+		}
+
+		item.Partial = true
+	}
+
+	return item, nil
+}
+
+// parseSection parses the section specification between
+// the brackets of a BODY[] or BODY.PEEK[] data item.
+func (item *FetchItem) parseSection(section string) error {
+
+	rest := section
+
+	// Consume leading part numbers.
+	for rest != "" {
+
+		head := rest
+		dot := strings.IndexByte(rest, '.')
+		if dot >= 0 {
+			head = rest[:dot]
+		}
+
+		num, err := strconv.Atoi(head)
+		if err != nil {
+			break
+		}
+
+		if num < 1 {
+			return fmt.Errorf("Command FETCH was sent with invalid section part number")
+		}
+
+		item.Part = append(item.Part, num)
+
+		if dot < 0 {
+			rest = ""
+		} else {
+
+			// A dot has to be followed by another
+			// part number or a specifier.
+			rest = rest[(dot + 1):]
+			if rest == "" {
+				return fmt.Errorf("Command FETCH was sent with invalid section specification")
+			}
+		}
+	}
+
+	if rest == "" {
+		return nil
+	}
+
+	fields := ""
+	item.Specifier = strings.ToUpper(rest)
+
+	if space := strings.IndexByte(rest, ' '); space >= 0 {
+		item.Specifier = strings.ToUpper(rest[:space])
+		fields = strings.TrimSpace(rest[(space + 1):])
+	}
+
+	switch item.Specifier {
+
+	case "HEADER", "TEXT":
+
+		if fields != "" {
+			return fmt.Errorf("Command FETCH was sent with invalid section specification")
+		}
+
+	case "MIME":
+
+		if (fields != "") || (len(item.Part) == 0) {
+			return fmt.Errorf("Command FETCH was sent with invalid section specification")
+		}
+
+	case "HEADER.FIELDS", "HEADER.FIELDS.NOT":
+
+		if !strings.HasPrefix(fields, "(") || !strings.HasSuffix(fields, ")") {
+			return fmt.Errorf("Command FETCH was sent with invalid header fields list")
+		}
+
+		for _, field := range strings.Fields(fields[1:(len(fields) - 1)]) {
+			item.Fields = append(item.Fields, strings.ToUpper(strings.Trim(field, "\"")))
+		}
+
+		if len(item.Fields) == 0 {
+			return fmt.Errorf("Command FETCH was sent with empty header fields list")
+		}
+
+	default:
+		return fmt.Errorf("Command FETCH was sent with invalid section specification")
+	}
+
+	return nil
+}
+
+// SetsSeen returns true if fetching this item
+// implicitly sets the \Seen flag on a message.
+func (item *FetchItem) SetsSeen() bool {
+
+	if item.HasSection {
+		return !item.Peek
+	}
+
+	return (item.Name == "RFC822") || (item.Name == "RFC822.TEXT")
+}
+
+// needsContent returns true if this item requires
+// the content of the mail file to be read.
+func (item *FetchItem) needsContent() bool {
+
+	switch item.Name {
+	case "FLAGS", "INTERNALDATE", "RFC822.SIZE", "UID", "MODSEQ":
+		return false
+	}
+
+	return true
+}
+
+// ResponseName returns the name under which the
+// value of this item is reported back to clients.
+func (item *FetchItem) ResponseName() string {
+
+	if !item.HasSection {
+		return item.Name
+	}
+
+	parts := make([]string, 0, (len(item.Part) + 1))
+	for _, num := range item.Part {
+		parts = append(parts, strconv.Itoa(num))
+	}
+
+	if item.Specifier != "" {
+		parts = append(parts, item.Specifier)
+	}
+
+	section := strings.Join(parts, ".")
+	if len(item.Fields) > 0 {
+		section = fmt.Sprintf("%s (%s)", section, strings.Join(item.Fields, " "))
+	}
+
+	if item.Partial {
+		return fmt.Sprintf("BODY[%s]<%d>", section, item.Offset)
+	}
+
+	return fmt.Sprintf("BODY[%s]", section)
+}
+
+// imapFlags converts a string of Maildir flag
+// characters into the space-separated list of
+// corresponding IMAP system flags and keywords.
+// The caller is required to hold the lock.
+func (mailbox *Mailbox) imapFlags(maildirFlags string) string {
+
+	flags := make([]string, 0, len(maildirFlags))
+
+	for _, flag := range maildirFlags {
+
+		switch flag {
+		case 'D':
+			flags = append(flags, "\\Draft")
+		case 'F':
+			flags = append(flags, "\\Flagged")
+		case 'R':
+			flags = append(flags, "\\Answered")
+		case 'S':
+			flags = append(flags, "\\Seen")
+		case 'T':
+			flags = append(flags, "\\Deleted")
+		default:
+			if keyword, found := mailbox.Keywords.Keyword(flag); found {
+				flags = append(flags, keyword)
+			}
+		}
+	}
+
+	return strings.Join(flags, " ")
+}
+
+// imapString renders s as an IMAP quoted string or,
+// if it contains characters not allowed in quoted
+// strings, as an IMAP literal.
+func imapString(s string) string {
+
+	for i := 0; i < len(s); i++ {
+
+		if (s[i] == '\r') || (s[i] == '\n') || (s[i] == 0) || (s[i] > 0x7f) {
+			return imapLiteral([]byte(s))
+		}
+	}
+
+	s = strings.Replace(s, "\\", "\\\\", -1)
+	s = strings.Replace(s, "\"", "\\\"", -1)
+
+	return fmt.Sprintf("\"%s\"", s)
+}
+
+// imapNString behaves like imapString but
+// renders an empty string as NIL.
+func imapNString(s string) string {
+
+	if s == "" {
+		return "NIL"
+	}
+
+	return imapString(s)
+}
+
+// imapLiteral renders data as an IMAP literal.
+func imapLiteral(data []byte) string {
+	return fmt.Sprintf("{%d}\r\n%s", len(data), data)
+}
+
+// parseMessagePart splits raw into header and body and
+// parses its MIME structure recursively. defaultType is
+// the media type assumed if no Content-Type is present.
+func parseMessagePart(raw []byte, defaultType string) *messagePart {
+
+	part := &messagePart{
+		Raw: raw,
+	}
+
+	// Find the empty line separating header and body.
+	sepIdx := -1
+	sepLen := 0
+
+	if bytes.HasPrefix(raw, []byte("\r\n")) {
+		sepIdx, sepLen = 0, 2
+	} else if bytes.HasPrefix(raw, []byte("\n")) {
+		sepIdx, sepLen = 0, 1
+	} else {
+
+		if i := bytes.Index(raw, []byte("\r\n\r\n")); i >= 0 {
+			sepIdx, sepLen = i, 4
+		}
+
+		if i := bytes.Index(raw, []byte("\n\n")); (i >= 0) && ((sepIdx < 0) || (i < sepIdx)) {
+			sepIdx, sepLen = i, 2
+		}
+	}
+
+	if sepIdx < 0 {
+		part.Header = raw
+	} else {
+		part.Header = raw[:(sepIdx + sepLen)]
+		part.Body = raw[(sepIdx + sepLen):]
+	}
+
+	// Parse header fields. Malformed headers
+	// result in the fields read up to the error.
+	headerReader := textproto.NewReader(bufio.NewReader(terminatedHeader(part.Header)))
+	part.Fields, _ = headerReader.ReadMIMEHeader()
+	if part.Fields == nil {
+		part.Fields = make(textproto.MIMEHeader)
+	}
+
+	part.MediaType = defaultType
+	part.Params = make(map[string]string)
+
+	if contentType := part.Fields.Get("Content-Type"); contentType != "" {
+
+		mediaType, params, err := mime.ParseMediaType(contentType)
+		if err == nil {
+			part.MediaType = mediaType
+			part.Params = params
+		}
+	}
+
+	if (part.MediaType == "text/plain") && (part.Params["charset"] == "") {
+		part.Params["charset"] = "us-ascii"
+	}
+
+	switch {
+
+	case strings.HasPrefix(part.MediaType, "multipart/"):
+
+		childType := "text/plain"
+		if part.MediaType == "multipart/digest" {
+			childType = "message/rfc822"
+		}
+
+		for _, child := range splitMultipart(part.Body, part.Params["boundary"]) {
+			part.Children = append(part.Children, parseMessagePart(child, childType))
+		}
+
+	case part.MediaType == "message/rfc822":
+		part.Message = parseMessagePart(part.Body, "text/plain")
+	}
+
+	return part
+}
+
+// terminatedHeader returns a reader on the supplied header
+// that is guaranteed to end in an empty line so that
+// textproto is able to parse it completely.
+func terminatedHeader(header []byte) *bytes.Reader {
+
+	if !bytes.HasSuffix(header, []byte("\n\n")) && !bytes.HasSuffix(header, []byte("\r\n\r\n")) {
+
+		terminated := make([]byte, len(header), (len(header) + 4))
+		copy(terminated, header)
+
+		return bytes.NewReader(append(terminated, "\r\n\r\n"...))
+	}
+
+	return bytes.NewReader(header)
+}
+
+// splitMultipart returns the raw body parts of a
+// multipart body delimited by supplied boundary.
+func splitMultipart(body []byte, boundary string) [][]byte {
+
+	parts := make([][]byte, 0, 2)
+
+	if boundary == "" {
+		return parts
+	}
+
+	delim := []byte(fmt.Sprintf("--%s", boundary))
+	pos := 0
+	start := -1
+
+	for {
+
+		i := bytes.Index(body[pos:], delim)
+		if i < 0 {
+			break
+		}
+		i += pos
+
+		// Delimiters have to start at the beginning of a line.
+		if (i > 0) && (body[i-1] != '\n') {
+			pos = i + len(delim)
+			continue
+		}
+
+		if start >= 0 {
+
+			// The line break preceding the delimiter
+			// belongs to the delimiter, not the part.
+			end := i
+			if (end > start) && (body[end-1] == '\n') {
+
+				end--
+
+				if (end > start) && (body[end-1] == '\r') {
+					end--
+				}
+			}
+
+			parts = append(parts, body[start:end])
+		}
+
+		after := i + len(delim)
+
+		// Close delimiter ends the multipart body.
+		if bytes.HasPrefix(body[after:], []byte("--")) {
+			return parts
+		}
+
+		newline := bytes.IndexByte(body[after:], '\n')
+		if newline < 0 {
+			return parts
+		}
+
+		start = after + newline + 1
+		pos = start
+	}
+
+	// Tolerate a missing close delimiter.
+	if (start >= 0) && (start < len(body)) {
+		parts = append(parts, body[start:])
+	}
+
+	return parts
+}
+
+// countLines returns the number of lines in data.
+func countLines(data []byte) int {
+
+	lines := bytes.Count(data, []byte("\n"))
+	if (len(data) > 0) && (data[len(data)-1] != '\n') {
+		lines++
+	}
+
+	return lines
+}
+
+// envelopeAddresses renders an address header value
+// as parenthesized list of IMAP address structures.
+func envelopeAddresses(value string) string {
+
+	if value == "" {
+		return "NIL"
+	}
+
+	addrs, err := mail.ParseAddressList(value)
+	if (err != nil) || (len(addrs) == 0) {
+		return "NIL"
+	}
+
+	structs := make([]string, 0, len(addrs))
+
+	for _, addr := range addrs {
+
+		local := addr.Address
+		host := ""
+
+		if at := strings.LastIndexByte(addr.Address, '@'); at >= 0 {
+			local = addr.Address[:at]
+			host = addr.Address[(at + 1):]
+		}
+
+		structs = append(structs, fmt.Sprintf("(%s NIL %s %s)", imapNString(addr.Name), imapNString(local), imapNString(host)))
+	}
+
+	return fmt.Sprintf("(%s)", strings.Join(structs, ""))
+}
+
+// Envelope returns the IMAP ENVELOPE structure
+// of a parsed message.
+func (part *messagePart) Envelope() string {
+
+	from := part.Fields.Get("From")
+
+	sender := part.Fields.Get("Sender")
+	if sender == "" {
+		sender = from
+	}
+
+	replyTo := part.Fields.Get("Reply-To")
+	if replyTo == "" {
+		replyTo = from
+	}
+
+	return fmt.Sprintf("(%s %s %s %s %s %s %s %s %s %s)",
+		imapNString(part.Fields.Get("Date")),
+		imapNString(part.Fields.Get("Subject")),
+		envelopeAddresses(from),
+		envelopeAddresses(sender),
+		envelopeAddresses(replyTo),
+		envelopeAddresses(part.Fields.Get("To")),
+		envelopeAddresses(part.Fields.Get("Cc")),
+		envelopeAddresses(part.Fields.Get("Bcc")),
+		imapNString(part.Fields.Get("In-Reply-To")),
+		imapNString(part.Fields.Get("Message-Id")))
+}
+
+// bodyParams renders MIME parameters as an IMAP
+// parenthesized list of attribute-value pairs.
+func bodyParams(params map[string]string) string {
+
+	if len(params) == 0 {
+		return "NIL"
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, (2 * len(keys)))
+	for _, key := range keys {
+		pairs = append(pairs, imapString(strings.ToUpper(key)), imapString(params[key]))
+	}
+
+	return fmt.Sprintf("(%s)", strings.Join(pairs, " "))
+}
+
+// bodyDisposition renders the Content-Disposition
+// header of a part as required by BODYSTRUCTURE.
+func (part *messagePart) bodyDisposition() string {
+
+	value := part.Fields.Get("Content-Disposition")
+	if value == "" {
+		return "NIL"
+	}
+
+	disposition, params, err := mime.ParseMediaType(value)
+	if err != nil {
+		return "NIL"
+	}
+
+	return fmt.Sprintf("(%s %s)", imapString(strings.ToUpper(disposition)), bodyParams(params))
+}
+
+// BodyStructure returns the IMAP body structure of a
+// parsed message part. If extended is true, extension
+// data is included as for BODYSTRUCTURE, otherwise the
+// non-extensible form used for BODY is returned.
+func (part *messagePart) BodyStructure(extended bool) string {
+
+	mediaType := strings.SplitN(part.MediaType, "/", 2)
+	if len(mediaType) < 2 {
+		mediaType = append(mediaType, "")
+	}
+
+	if len(part.Children) > 0 {
+
+		children := ""
+		for _, child := range part.Children {
+			children = fmt.Sprintf("%s%s", children, child.BodyStructure(extended))
+		}
+
+		if !extended {
+			return fmt.Sprintf("(%s %s)", children, imapString(strings.ToUpper(mediaType[1])))
+		}
+
+		return fmt.Sprintf("(%s %s %s %s %s NIL)", children, imapString(strings.ToUpper(mediaType[1])),
+			bodyParams(part.Params), part.bodyDisposition(), imapNString(part.Fields.Get("Content-Language")))
+	}
+
+	encoding := strings.ToUpper(part.Fields.Get("Content-Transfer-Encoding"))
+	if encoding == "" {
+		encoding = "7BIT"
+	}
+
+	structure := fmt.Sprintf("%s %s %s %s %s %s %d",
+		imapString(strings.ToUpper(mediaType[0])), imapString(strings.ToUpper(mediaType[1])),
+		bodyParams(part.Params), imapNString(part.Fields.Get("Content-Id")),
+		imapNString(part.Fields.Get("Content-Description")), imapString(encoding), len(part.Body))
+
+	if part.Message != nil {
+		structure = fmt.Sprintf("%s %s %s %d", structure, part.Message.Envelope(),
+			part.Message.BodyStructure(extended), countLines(part.Body))
+	} else if mediaType[0] == "text" {
+		structure = fmt.Sprintf("%s %d", structure, countLines(part.Body))
+	}
+
+	if extended {
+		structure = fmt.Sprintf("%s %s %s %s NIL", structure, imapNString(part.Fields.Get("Content-Md5")),
+			part.bodyDisposition(), imapNString(part.Fields.Get("Content-Language")))
+	}
+
+	return fmt.Sprintf("(%s)", structure)
+}
+
+// filterHeader returns the lines of header belonging to
+// one of fields. If not is true, the lines of all other
+// fields are returned instead.
+func filterHeader(header []byte, fields []string, not bool) []byte {
+
+	wanted := make(map[string]bool)
+	for _, field := range fields {
+		wanted[strings.ToUpper(field)] = true
+	}
+
+	filtered := make([]byte, 0, len(header))
+	include := false
+
+	for _, line := range bytes.SplitAfter(header, []byte("\n")) {
+
+		if len(bytes.TrimRight(line, "\r\n")) == 0 {
+			continue
+		}
+
+		// Continuation lines belong to the previous field.
+		if (line[0] != ' ') && (line[0] != '\t') {
+
+			name := line
+			if colon := bytes.IndexByte(line, ':'); colon >= 0 {
+				name = line[:colon]
+			}
+
+			include = wanted[strings.ToUpper(string(bytes.TrimSpace(name)))] != not
+		}
+
+		if include {
+			filtered = append(filtered, line...)
+		}
+	}
+
+	return append(filtered, "\r\n"...)
+}
+
+// Section returns the data of the section specified in
+// item. If the section does not exist, false is returned.
+func (part *messagePart) Section(item *FetchItem) ([]byte, bool) {
+
+	cur := part
+
+	for i, num := range item.Part {
+
+		// Descending below an encapsulated message
+		// refers to the parts of that message.
+		if (i > 0) && (cur.Message != nil) {
+			cur = cur.Message
+		}
+
+		if len(cur.Children) > 0 {
+
+			if num > len(cur.Children) {
+				return nil, false
+			}
+
+			cur = cur.Children[(num - 1)]
+
+		} else if num != 1 {
+
+			// A non-multipart message only has part 1.
+			return nil, false
+		}
+	}
+
+	// Part specifiers other than MIME refer to
+	// the message encapsulated in the part.
+	msg := cur
+	if len(item.Part) > 0 {
+		msg = cur.Message
+	}
+
+	switch item.Specifier {
+
+	case "":
+
+		if len(item.Part) > 0 {
+			return cur.Body, true
+		}
+
+		return cur.Raw, true
+
+	case "MIME":
+		return cur.Header, true
+	}
+
+	if msg == nil {
+		return []byte{}, true
+	}
+
+	switch item.Specifier {
+	case "HEADER":
+		return msg.Header, true
+	case "HEADER.FIELDS":
+		return filterHeader(msg.Header, item.Fields, false), true
+	case "HEADER.FIELDS.NOT":
+		return filterHeader(msg.Header, item.Fields, true), true
+	}
+
+	return msg.Body, true
+}
+
+// fetchMail builds the untagged FETCH response for the
+// message at mailSeqNum in the selected mailbox. If any
+// item implicitly sets the \Seen flag, the flag change
+// is applied and replicated like a STORE. The caller
+// is required to hold the exclusive mailbox lock.
+func (mailbox *Mailbox) fetchMail(s *Session, fetchMaildir maildir.Dir, mailSeqNum int, items []*FetchItem, syncChan chan comm.Msg) (string, error) {
+
+	mailFileName := mailbox.Mails[s.SelectedMailbox][mailSeqNum]
+	mailFilePath := filepath.Join(string(fetchMaildir), "cur", mailFileName)
+
+	// Find out what this request needs.
+	needsContent := false
+	setsSeen := false
+	flagsRequested := false
+	modSeqRequested := false
+
+	for _, item := range items {
+
+		if item.needsContent() {
+			needsContent = true
+		}
+
+		if item.SetsSeen() {
+			setsSeen = true
+		}
+
+		if item.Name == "FLAGS" {
+			flagsRequested = true
+		}
+
+		if item.Name == "MODSEQ" {
+			modSeqRequested = true
+		}
+	}
+
+	mailInfo, err := os.Stat(mailFilePath)
+	if err != nil {
+		return "", fmt.Errorf("error while stat'ing mail file in FETCH operation: %v", err)
+	}
+
+	var msg *messagePart
+	if needsContent {
+
+		content, err := ioutil.ReadFile(mailFilePath)
+		if err != nil {
+			return "", fmt.Errorf("error while reading in mail file content in FETCH operation: %v", err)
+		}
+
+		msg = parseMessagePart(content, "text/plain")
+	}
+
+	mailFlags, err := fetchMaildir.Flags(mailFileName, false)
+	if err != nil {
+		return "", fmt.Errorf("error while retrieving flags from mail file: %v", err)
+	}
+
+	// Set the \Seen flag as side effect of fetching
+	// message content if not present, unless the
+	// mailbox was selected read-only.
+	flagsChanged := false
+	if setsSeen && !s.ReadOnly && !strings.ContainsRune(mailFlags, 'S') {
+
+		err := mailbox.setMailFlags(s, fetchMaildir, mailSeqNum, fmt.Sprintf("%sS", mailFlags), syncChan)
+		if err != nil {
+			return "", err
+		}
+
+		mailFlags, err = fetchMaildir.Flags(mailbox.Mails[s.SelectedMailbox][mailSeqNum], false)
+		if err != nil {
+			return "", fmt.Errorf("error while retrieving flags from mail file: %v", err)
+		}
+
+		flagsChanged = true
+	}
+
+	answerItems := make([]string, 0, (len(items) + 1))
+
+	for _, item := range items {
+
+		switch {
+
+		case item.HasSection:
+
+			data, found := msg.Section(item)
+			if !found {
+				answerItems = append(answerItems, fmt.Sprintf("%s NIL", item.ResponseName()))
+				continue
+			}
+
+			if item.Partial {
+
+				if item.Offset > len(data) {
+					data = []byte{}
+				} else {
+
+					data = data[item.Offset:]
+					if item.Length < len(data) {
+						data = data[:item.Length]
+					}
+				}
+			}
+
+			answerItems = append(answerItems, fmt.Sprintf("%s %s", item.ResponseName(), imapLiteral(data)))
+
+		case item.Name == "UID":
+			answerItems = append(answerItems, fmt.Sprintf("UID %d", mailbox.mailUID(s.SelectedMailbox, mailbox.Mails[s.SelectedMailbox][mailSeqNum])))
+
+		case item.Name == "FLAGS":
+			answerItems = append(answerItems, fmt.Sprintf("FLAGS (%s)", mailbox.imapFlags(mailFlags)))
+
+		case item.Name == "MODSEQ":
+			answerItems = append(answerItems, fmt.Sprintf("MODSEQ (%d)", mailbox.mailModSeq(s.SelectedMailbox, mailbox.Mails[s.SelectedMailbox][mailSeqNum])))
+
+		case item.Name == "INTERNALDATE":
+			answerItems = append(answerItems, fmt.Sprintf("INTERNALDATE \"%s\"", mailInfo.ModTime().Format(dateTimeLayout)))
+
+		case item.Name == "RFC822.SIZE":
+			answerItems = append(answerItems, fmt.Sprintf("RFC822.SIZE %d", mailInfo.Size()))
+
+		case item.Name == "ENVELOPE":
+			answerItems = append(answerItems, fmt.Sprintf("ENVELOPE %s", msg.Envelope()))
+
+		case item.Name == "BODYSTRUCTURE":
+			answerItems = append(answerItems, fmt.Sprintf("BODYSTRUCTURE %s", msg.BodyStructure(true)))
+
+		case item.Name == "BODY":
+			answerItems = append(answerItems, fmt.Sprintf("BODY %s", msg.BodyStructure(false)))
+
+		case item.Name == "RFC822":
+			answerItems = append(answerItems, fmt.Sprintf("RFC822 %s", imapLiteral(msg.Raw)))
+
+		case item.Name == "RFC822.HEADER":
+			answerItems = append(answerItems, fmt.Sprintf("RFC822.HEADER %s", imapLiteral(msg.Header)))
+
+		case item.Name == "RFC822.TEXT":
+			answerItems = append(answerItems, fmt.Sprintf("RFC822.TEXT %s", imapLiteral(msg.Body)))
+		}
+	}
+
+	// Inform client about implicitly changed flags.
+	if flagsChanged && !flagsRequested {
+		answerItems = append(answerItems, fmt.Sprintf("FLAGS (%s)", mailbox.imapFlags(mailFlags)))
+	}
+
+	// Along with the new MODSEQ, if CONDSTORE is in use.
+	if flagsChanged && s.CondStore && !modSeqRequested {
+		answerItems = append(answerItems, fmt.Sprintf("MODSEQ (%d)", mailbox.mailModSeq(s.SelectedMailbox, mailbox.Mails[s.SelectedMailbox][mailSeqNum])))
+	}
+
+	return fmt.Sprintf("* %d FETCH (%s)", (mailSeqNum + 1), strings.Join(answerItems, " ")), nil
+}
@@ -0,0 +1,318 @@
+package imap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Variables
+
+var fetchItemsTests = []struct {
+	payload string
+	out     []*FetchItem
+	names   string
+	fails   bool
+}{
+	{"FAST", []*FetchItem{{Name: "FLAGS"}, {Name: "INTERNALDATE"}, {Name: "RFC822.SIZE"}}, "FLAGS INTERNALDATE RFC822.SIZE", false},
+	{"all", []*FetchItem{{Name: "FLAGS"}, {Name: "INTERNALDATE"}, {Name: "RFC822.SIZE"}, {Name: "ENVELOPE"}}, "FLAGS INTERNALDATE RFC822.SIZE ENVELOPE", false},
+	{"FULL", []*FetchItem{{Name: "FLAGS"}, {Name: "INTERNALDATE"}, {Name: "RFC822.SIZE"}, {Name: "ENVELOPE"}, {Name: "BODY"}}, "FLAGS INTERNALDATE RFC822.SIZE ENVELOPE BODY", false},
+	{"uid", []*FetchItem{{Name: "UID"}}, "UID", false},
+	{"(FLAGS BODYSTRUCTURE RFC822.HEADER MODSEQ)", []*FetchItem{{Name: "FLAGS"}, {Name: "BODYSTRUCTURE"}, {Name: "RFC822.HEADER"}, {Name: "MODSEQ"}}, "FLAGS BODYSTRUCTURE RFC822.HEADER MODSEQ", false},
+	{"BODY[]", []*FetchItem{{Name: "BODY", HasSection: true}}, "BODY[]", false},
+	{"BODY.PEEK[]", []*FetchItem{{Name: "BODY", HasSection: true, Peek: true}}, "BODY[]", false},
+	{"body.peek[]<0.10>", []*FetchItem{{Name: "BODY", HasSection: true, Peek: true, Partial: true, Offset: 0, Length: 10}}, "BODY[]<0>", false},
+	{"BODY[3]<512.1>", []*FetchItem{{Name: "BODY", HasSection: true, Part: []int{3}, Partial: true, Offset: 512, Length: 1}}, "BODY[3]<512>", false},
+	{"(UID BODY[1.2.HEADER.FIELDS (From To)])", []*FetchItem{{Name: "UID"}, {Name: "BODY", HasSection: true, Part: []int{1, 2}, Specifier: "HEADER.FIELDS", Fields: []string{"FROM", "TO"}}}, "UID BODY[1.2.HEADER.FIELDS (FROM TO)]", false},
+	{"BODY.PEEK[HEADER.FIELDS.NOT (Subject)]<0.512>", []*FetchItem{{Name: "BODY", HasSection: true, Peek: true, Specifier: "HEADER.FIELDS.NOT", Fields: []string{"SUBJECT"}, Partial: true, Length: 512}}, "BODY[HEADER.FIELDS.NOT (SUBJECT)]<0>", false},
+	{"BODY[2.1.mime]", []*FetchItem{{Name: "BODY", HasSection: true, Part: []int{2, 1}, Specifier: "MIME"}}, "BODY[2.1.MIME]", false},
+	{"()", nil, "", true},
+	{"\"FLAGS\"", nil, "", true},
+	{"(FLAGS (UID))", nil, "", true},
+	{"UNKNOWN", nil, "", true},
+	{"RFC822.PEEK", nil, "", true},
+	{"RFC822[]", nil, "", true},
+	{"BODY[]0.5", nil, "", true},
+	{"BODY[]<0>", nil, "", true},
+	{"BODY[]<0.0>", nil, "", true},
+	{"BODY[]<-1.5>", nil, "", true},
+	{"BODY[]<a.5>", nil, "", true},
+	{"BODY[MIME]", nil, "", true},
+	{"BODY[1.]", nil, "", true},
+}
+
+var parseSectionTests = []struct {
+	section   string
+	part      []int
+	specifier string
+	fields    []string
+	fails     bool
+}{
+	{"", nil, "", nil, false},
+	{"1", []int{1}, "", nil, false},
+	{"4.2.17", []int{4, 2, 17}, "", nil, false},
+	{"header", nil, "HEADER", nil, false},
+	{"TEXT", nil, "TEXT", nil, false},
+	{"1.2.TEXT", []int{1, 2}, "TEXT", nil, false},
+	{"1.2.MIME", []int{1, 2}, "MIME", nil, false},
+	{"1.2.HEADER.FIELDS (Subject \"Message-ID\")", []int{1, 2}, "HEADER.FIELDS", []string{"SUBJECT", "MESSAGE-ID"}, false},
+	{"HEADER.FIELDS.NOT (To Cc)", nil, "HEADER.FIELDS.NOT", []string{"TO", "CC"}, false},
+	{"0", nil, "", nil, true},
+	{"1.0.TEXT", nil, "", nil, true},
+	{"MIME", nil, "", nil, true},
+	{"1.MIME (To)", nil, "", nil, true},
+	{"HEADER (To)", nil, "", nil, true},
+	{"TEXT (To)", nil, "", nil, true},
+	{"HEADER.FIELDS", nil, "", nil, true},
+	{"HEADER.FIELDS To", nil, "", nil, true},
+	{"HEADER.FIELDS ()", nil, "", nil, true},
+	{"1.BODY", nil, "", nil, true},
+	{"1.", nil, "", nil, true},
+}
+
+var splitMultipartTests = []struct {
+	body     string
+	boundary string
+	parts    []string
+}{
+	{"--b\r\nA\r\n--b\r\nB\r\n--b--\r\n", "b", []string{"A", "B"}},
+	{"Preamble.\r\n--b\r\nA\r\n--b--\r\nEpilogue.\r\n", "b", []string{"A"}},
+	{"--b\nA\n\n--b\nB\n--b--\n", "b", []string{"A\n", "B"}},
+	{"--b \r\nA\r\n--b--", "b", []string{"A"}},
+	{"--b\r\nnot--b here\r\n--b--", "b", []string{"not--b here"}},
+	{"--b\r\n\r\n--b--", "b", []string{""}},
+	{"--b\r\nA\r\n--b\r\nB\r\n", "b", []string{"A", "B\r\n"}},
+	{"--b\r\nA\r\n--b", "b", []string{"A"}},
+	{"--b", "b", []string{}},
+	{"A\r\nB\r\n", "b", []string{}},
+	{"--b\r\nA\r\n--b--", "", []string{}},
+}
+
+var parseMessagePartTests = []struct {
+	raw       string
+	header    string
+	body      string
+	mediaType string
+	params    map[string]string
+	subject   string
+	children  []string
+}{
+	{"Subject: Hi\r\n\r\nBody.\r\n", "Subject: Hi\r\n\r\n", "Body.\r\n", "text/plain", map[string]string{"charset": "us-ascii"}, "Hi", nil},
+	{"Subject: Hi\n\nBody.\n", "Subject: Hi\n\n", "Body.\n", "text/plain", map[string]string{"charset": "us-ascii"}, "Hi", nil},
+	{"Subject: Only header", "Subject: Only header", "", "text/plain", map[string]string{"charset": "us-ascii"}, "Only header", nil},
+	{"\r\nOnly body.", "\r\n", "Only body.", "text/plain", map[string]string{"charset": "us-ascii"}, "", nil},
+	{"Subject: Hi\r\nno colon here\r\nTo: b@example.org\r\n\r\nBody.", "Subject: Hi\r\nno colon here\r\nTo: b@example.org\r\n\r\n", "Body.", "text/plain", map[string]string{"charset": "us-ascii"}, "Hi", nil},
+	{"Content-Type: ;;;\r\n\r\nBody.", "Content-Type: ;;;\r\n\r\n", "Body.", "text/plain", map[string]string{"charset": "us-ascii"}, "", nil},
+	{"Content-Type: text/html; charset=\"UTF-8\"\r\n\r\n<p/>", "Content-Type: text/html; charset=\"UTF-8\"\r\n\r\n", "<p/>", "text/html", map[string]string{"charset": "UTF-8"}, "", nil},
+	{"Content-Type: multipart/mixed\r\n\r\n--b\r\nA\r\n--b--", "Content-Type: multipart/mixed\r\n\r\n", "--b\r\nA\r\n--b--", "multipart/mixed", map[string]string{}, "", nil},
+	{"Content-Type: multipart/mixed; boundary=b\r\n\r\n--b\r\nContent-Type: image/png\r\n\r\nPNG\r\n--b\r\nA", "Content-Type: multipart/mixed; boundary=b\r\n\r\n", "--b\r\nContent-Type: image/png\r\n\r\nPNG\r\n--b\r\nA", "multipart/mixed", map[string]string{"boundary": "b"}, "", []string{"image/png", "text/plain"}},
+	{"Content-Type: multipart/digest; boundary=b\r\n\r\n--b\r\n\r\nSubject: Digested\r\n\r\nA\r\n--b--", "Content-Type: multipart/digest; boundary=b\r\n\r\n", "--b\r\n\r\nSubject: Digested\r\n\r\nA\r\n--b--", "multipart/digest", map[string]string{"boundary": "b"}, "", []string{"message/rfc822"}},
+}
+
+// structureMail is a nested multipart message whose
+// second inner part encapsulates a forwarded message.
+var structureMail = strings.Join([]string{
+	"From: Alice Example <alice@example.org>",
+	"To: bob@example.org, \"Carol\" <carol@example.org>",
+	"Subject: Nested",
+	"Date: Wed, 01 Mar 2017 12:00:00 +0000",
+	"Message-ID: <nested@example.org>",
+	"MIME-Version: 1.0",
+	"Content-Type: multipart/mixed; boundary=\"outer\"",
+	"",
+	"Preamble.",
+	"--outer",
+	"Content-Type: multipart/mixed; boundary=inner",
+	"",
+	"--inner",
+	"Content-Type: text/plain; charset=utf-8",
+	"",
+	"Hello Bob.",
+	"--inner",
+	"Content-Type: message/rfc822",
+	"Content-Disposition: attachment; filename=\"fwd.eml\"",
+	"",
+	"From: Dave <dave@example.org>",
+	"Subject: Forwarded",
+	"X-Note: kept",
+	"",
+	"Forwarded body.",
+	"--inner--",
+	"--outer",
+	"Content-Type: text/html; charset=utf-8",
+	"Content-Transfer-Encoding: quoted-printable",
+	"Content-Language: en",
+	"",
+	"<p>Bye =3D</p>",
+	"--outer--",
+	"Epilogue.",
+	"",
+}, "\r\n")
+
+var sectionTests = []struct {
+	item  string
+	data  string
+	found bool
+}{
+	{"BODY[HEADER.FIELDS (Subject To)]", "To: bob@example.org, \"Carol\" <carol@example.org>\r\nSubject: Nested\r\n\r\n", true},
+	{"BODY[HEADER.FIELDS.NOT (From To Date Message-ID MIME-Version Content-Type)]", "Subject: Nested\r\n\r\n", true},
+	{"BODY[1.1]", "Hello Bob.", true},
+	{"BODY[1.1.MIME]", "Content-Type: text/plain; charset=utf-8\r\n\r\n", true},
+	{"BODY[1.2.MIME]", "Content-Type: message/rfc822\r\nContent-Disposition: attachment; filename=\"fwd.eml\"\r\n\r\n", true},
+	{"BODY[1.2.HEADER]", "From: Dave <dave@example.org>\r\nSubject: Forwarded\r\nX-Note: kept\r\n\r\n", true},
+	{"BODY[1.2.HEADER.FIELDS (Subject)]", "Subject: Forwarded\r\n\r\n", true},
+	{"BODY[1.2.HEADER.FIELDS.NOT (From Subject)]", "X-Note: kept\r\n\r\n", true},
+	{"BODY[1.2.HEADER.FIELDS (Cc)]", "\r\n", true},
+	{"BODY[1.2.TEXT]", "Forwarded body.", true},
+	{"BODY[1.2.1]", "Forwarded body.", true},
+	{"BODY[1.2]", "From: Dave <dave@example.org>\r\nSubject: Forwarded\r\nX-Note: kept\r\n\r\nForwarded body.", true},
+	{"BODY[2]", "<p>Bye =3D</p>", true},
+	{"BODY[2.HEADER]", "", true},
+	{"BODY[1.3]", "", false},
+	{"BODY[3]", "", false},
+	{"BODY[2.2]", "", false},
+	{"BODY[1.1.2]", "", false},
+}
+
+// Functions
+
+// TestParseFetchItems executes a white-box table
+// test on implemented ParseFetchItems() function.
+func TestParseFetchItems(t *testing.T) {
+
+	for _, test := range fetchItemsTests {
+
+		args, err := ParseArgs(test.payload)
+		assert.Nilf(t, err, "failed to parse arguments %q: %v", test.payload, err)
+
+		items, err := ParseFetchItems(args[0])
+		if test.fails {
+			assert.NotNilf(t, err, "expected ParseFetchItems(%q) to fail", test.payload)
+			continue
+		}
+
+		assert.Nilf(t, err, "expected ParseFetchItems(%q) not to fail but got: %v", test.payload, err)
+		assert.Equalf(t, test.out, items, "unexpected items for %q", test.payload)
+
+		names := make([]string, len(items))
+		for i, item := range items {
+			names[i] = item.ResponseName()
+		}
+
+		assert.Equalf(t, test.names, strings.Join(names, " "), "unexpected response names for %q", test.payload)
+	}
+}
+
+// TestParseSection executes a white-box table
+// test on implemented parseSection() function.
+func TestParseSection(t *testing.T) {
+
+	for _, test := range parseSectionTests {
+
+		item := &FetchItem{}
+
+		err := item.parseSection(test.section)
+		if test.fails {
+			assert.NotNilf(t, err, "expected parseSection(%q) to fail", test.section)
+			continue
+		}
+
+		assert.Nilf(t, err, "expected parseSection(%q) not to fail but got: %v", test.section, err)
+		assert.Equalf(t, test.part, item.Part, "unexpected part numbers for %q", test.section)
+		assert.Equalf(t, test.specifier, item.Specifier, "unexpected specifier for %q", test.section)
+		assert.Equalf(t, test.fields, item.Fields, "unexpected header fields for %q", test.section)
+	}
+}
+
+// TestSplitMultipart executes a white-box table
+// test on implemented splitMultipart() function.
+func TestSplitMultipart(t *testing.T) {
+
+	for _, test := range splitMultipartTests {
+
+		parts := make([]string, 0)
+		for _, part := range splitMultipart([]byte(test.body), test.boundary) {
+			parts = append(parts, string(part))
+		}
+
+		assert.Equalf(t, test.parts, parts, "unexpected parts of %q", test.body)
+	}
+}
+
+// TestParseMessagePart executes a white-box table
+// test on parseMessagePart() with well-formed and
+// malformed messages.
+func TestParseMessagePart(t *testing.T) {
+
+	for _, test := range parseMessagePartTests {
+
+		part := parseMessagePart([]byte(test.raw), "text/plain")
+
+		assert.Equalf(t, test.raw, string(part.Raw), "expected raw message to be kept for %q", test.raw)
+		assert.Equalf(t, test.header, string(part.Header), "unexpected header of %q", test.raw)
+		assert.Equalf(t, test.body, string(part.Body), "unexpected body of %q", test.raw)
+		assert.Equalf(t, test.mediaType, part.MediaType, "unexpected media type of %q", test.raw)
+		assert.Equalf(t, test.params, part.Params, "unexpected parameters of %q", test.raw)
+		assert.Equalf(t, test.subject, part.Fields.Get("Subject"), "unexpected subject of %q", test.raw)
+
+		var children []string
+		for _, child := range part.Children {
+			children = append(children, child.MediaType)
+		}
+
+		assert.Equalf(t, test.children, children, "unexpected children of %q", test.raw)
+	}
+
+	// Parts of a digest encapsulate messages.
+	digest := parseMessagePart([]byte(parseMessagePartTests[9].raw), "text/plain")
+	assert.Equalf(t, "Digested", digest.Children[0].Message.Fields.Get("Subject"), "expected digest part to be parsed as message")
+}
+
+// TestSection executes a white-box table test on
+// sections of a nested multipart message.
+func TestSection(t *testing.T) {
+
+	msg := parseMessagePart([]byte(structureMail), "text/plain")
+
+	for _, test := range sectionTests {
+
+		item, err := parseFetchItem(test.item)
+		assert.Nilf(t, err, "expected %s to be parsed but got: %v", test.item, err)
+
+		data, found := msg.Section(item)
+		assert.Equalf(t, test.found, found, "unexpected presence of %s", test.item)
+		assert.Equalf(t, test.data, string(data), "unexpected data of %s", test.item)
+	}
+
+	item, _ := parseFetchItem("BODY[]")
+	data, _ := msg.Section(item)
+	assert.Equalf(t, structureMail, string(data), "expected BODY[] to be the whole message")
+}
+
+// TestEnvelopeAndBodyStructure executes a white-box
+// unit test on ENVELOPE, BODY, and BODYSTRUCTURE of
+// a nested multipart message.
+func TestEnvelopeAndBodyStructure(t *testing.T) {
+
+	msg := parseMessagePart([]byte(structureMail), "text/plain")
+
+	alice := "((\"Alice Example\" NIL \"alice\" \"example.org\"))"
+	assert.Equalf(t, ("(\"Wed, 01 Mar 2017 12:00:00 +0000\" \"Nested\" " + alice + " " + alice + " " + alice +
+		" ((NIL NIL \"bob\" \"example.org\")(\"Carol\" NIL \"carol\" \"example.org\")) NIL NIL NIL \"<nested@example.org>\")"),
+		msg.Envelope(), "unexpected ENVELOPE")
+
+	forwarded := "(NIL \"Forwarded\" ((\"Dave\" NIL \"dave\" \"example.org\")) ((\"Dave\" NIL \"dave\" \"example.org\")) ((\"Dave\" NIL \"dave\" \"example.org\")) NIL NIL NIL NIL NIL)"
+
+	assert.Equalf(t, ("(((\"TEXT\" \"PLAIN\" (\"CHARSET\" \"utf-8\") NIL NIL \"7BIT\" 10 1)" +
+		"(\"MESSAGE\" \"RFC822\" NIL NIL NIL \"7BIT\" 82 " + forwarded + " (\"TEXT\" \"PLAIN\" (\"CHARSET\" \"us-ascii\") NIL NIL \"7BIT\" 15 1) 5) \"MIXED\")" +
+		"(\"TEXT\" \"HTML\" (\"CHARSET\" \"utf-8\") NIL NIL \"QUOTED-PRINTABLE\" 14 1) \"MIXED\")"),
+		msg.BodyStructure(false), "unexpected BODY")
+
+	assert.Equalf(t, ("(((\"TEXT\" \"PLAIN\" (\"CHARSET\" \"utf-8\") NIL NIL \"7BIT\" 10 1 NIL NIL NIL NIL)" +
+		"(\"MESSAGE\" \"RFC822\" NIL NIL NIL \"7BIT\" 82 " + forwarded + " (\"TEXT\" \"PLAIN\" (\"CHARSET\" \"us-ascii\") NIL NIL \"7BIT\" 15 1 NIL NIL NIL NIL) 5 NIL (\"ATTACHMENT\" (\"FILENAME\" \"fwd.eml\")) NIL NIL)" +
+		" \"MIXED\" (\"BOUNDARY\" \"inner\") NIL NIL NIL)" +
+		"(\"TEXT\" \"HTML\" (\"CHARSET\" \"utf-8\") NIL NIL \"QUOTED-PRINTABLE\" 14 1 NIL NIL \"en\" NIL)" +
+		" \"MIXED\" (\"BOUNDARY\" \"outer\") NIL NIL NIL)"),
+		msg.BodyStructure(true), "unexpected BODYSTRUCTURE")
+}
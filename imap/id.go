@@ -0,0 +1,82 @@
+package imap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Constants
+
+// Limits on the parameter list of an ID
+// command as defined in RFC 2971.
+const (
+	maxIDPairs       = 30
+	maxIDFieldLength = 30
+	maxIDValueLength = 1024
+)
+
+// Functions
+
+// ParseID parses the argument of an ID command (RFC 2971),
+// either NIL or a list of field-value pairs, into a map of
+// lower-cased field names to their values. Fields with a
+// value of NIL are left out.
+func ParseID(arg *Node) (map[string]string, error) {
+
+	params := make(map[string]string)
+
+	if arg.IsNIL() {
+		return params, nil
+	}
+
+	if !arg.IsList() || ((len(arg.Items) % 2) != 0) {
+		return nil, fmt.Errorf("Command ID was sent with invalid parameter list")
+	}
+
+	if len(arg.Items) > (2 * maxIDPairs) {
+		return nil, fmt.Errorf("Command ID was sent with more than %d fields", maxIDPairs)
+	}
+
+	seen := make(map[string]bool)
+
+	for i := 0; i < len(arg.Items); i += 2 {
+
+		// Fields have to be strings, not atoms.
+		if (arg.Items[i].Type == NodeAtom) || (arg.Items[i].Type == NodeList) {
+			return nil, fmt.Errorf("Command ID was sent with invalid field name")
+		}
+
+		field := strings.ToLower(arg.Items[i].Value)
+		if len(field) > maxIDFieldLength {
+			return nil, fmt.Errorf("Command ID was sent with too long field name")
+		}
+
+		if seen[field] {
+			return nil, fmt.Errorf("Command ID was sent with field %s more than once", arg.Items[i].Value)
+		}
+		seen[field] = true
+
+		value := arg.Items[(i + 1)]
+		if value.IsNIL() {
+			continue
+		}
+
+		if (value.Type == NodeAtom) || (value.Type == NodeList) {
+			return nil, fmt.Errorf("Command ID was sent with invalid value for field %s", arg.Items[i].Value)
+		}
+
+		if len(value.Value) > maxIDValueLength {
+			return nil, fmt.Errorf("Command ID was sent with too long value for field %s", arg.Items[i].Value)
+		}
+
+		params[field] = value.Value
+	}
+
+	return params, nil
+}
+
+// init registers the ID capability, which
+// is offered in any state.
+func init() {
+	RegisterCapability(&Capability{Name: "ID", PreAuth: true, PostAuth: true, Commands: []string{CommandID}})
+}
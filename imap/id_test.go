@@ -0,0 +1,42 @@
+package imap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Variables
+
+var idTests = []struct {
+	payload string
+	params  map[string]string
+	fails   bool
+}{
+	{"NIL", map[string]string{}, false},
+	{"(\"name\" \"sodr\" \"version\" \"19.34\")", map[string]string{"name": "sodr", "version": "19.34"}, false},
+	{"(\"Name\" \"Thunderbird\" \"vendor\" NIL)", map[string]string{"name": "Thunderbird"}, false},
+	{"()", map[string]string{}, false},
+	{"(\"name\")", nil, true},
+	{"(name \"sodr\")", nil, true},
+	{"(\"name\" sodr)", nil, true},
+	{"(\"name\" \"a\" \"NAME\" \"b\")", nil, true},
+	{"sodr", nil, true},
+}
+
+// Functions
+
+// TestParseID executes a white-box table
+// test on implemented ParseID() function.
+func TestParseID(t *testing.T) {
+
+	for _, test := range idTests {
+
+		args, err := ParseArgs(test.payload)
+		assert.Nilf(t, err, "failed to parse arguments %q: %v", test.payload, err)
+
+		params, err := ParseID(args[0])
+		assert.Equalf(t, test.fails, err != nil, "unexpected error for %q: %v", test.payload, err)
+		assert.Equalf(t, test.params, params, "unexpected parameters for %q", test.payload)
+	}
+}
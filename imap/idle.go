@@ -0,0 +1,152 @@
+package imap
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Structs
+
+// Watchers keeps track of all sessions of one user that
+// currently wait in IDLE for changes to the mailbox.
+// Each watcher owns a channel that is signalled after
+// any local or downstream operation changed the mailbox.
+type Watchers struct {
+	lock  *sync.Mutex
+	chans map[chan struct{}]bool
+}
+
+// Functions
+
+// NewWatchers returns an empty set of watchers.
+func NewWatchers() *Watchers {
+
+	return &Watchers{
+		lock:  &sync.Mutex{},
+		chans: make(map[chan struct{}]bool),
+	}
+}
+
+// Add registers a new watcher and returns the
+// channel it will be signalled on.
+func (w *Watchers) Add() chan struct{} {
+
+	// Buffer one signal so that notifying never
+	// blocks and changes are not missed while the
+	// watcher is busy sending updates.
+	c := make(chan struct{}, 1)
+
+	w.lock.Lock()
+	w.chans[c] = true
+	w.lock.Unlock()
+
+	return c
+}
+
+// Remove unregisters the watcher of channel c.
+func (w *Watchers) Remove(c chan struct{}) {
+
+	w.lock.Lock()
+	delete(w.chans, c)
+	w.lock.Unlock()
+}
+
+// Notify signals all registered watchers
+// that the mailbox has changed.
+func (w *Watchers) Notify() {
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for c := range w.chans {
+
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Idle implements the IMAP IDLE command (RFC 2177). After
+// confirming the command with a continuation request, it
+// sends untagged EXISTS, EXPUNGE, and FETCH responses via
+// send whenever the selected mailbox is changed by another
+// session or a downstream update, until stop is closed.
+// Terminating IDLE with a tagged response is left to the
+// caller, as only the caller learns about the client's DONE.
+func (mailbox *Mailbox) Idle(s *Session, req *Request, stop <-chan struct{}, send func(string) error) error {
+
+	if (s.State != StateAuthenticated) && (s.State != StateMailbox) {
+
+		// If connection was not in correct state when this
+		// command was executed, this is a client error.
+		// Send tagged BAD response.
+		return send(fmt.Sprintf("%s BAD Command IDLE cannot be executed in this state", req.Tag))
+	}
+
+	if len(req.Payload) > 0 {
+
+		// If payload was not empty to IDLE command,
+		// this is a client error. Return BAD statement.
+		return send(fmt.Sprintf("%s BAD Command IDLE was sent with extra parameters", req.Tag))
+	}
+
+	changed := mailbox.Watchers.Add()
+	defer mailbox.Watchers.Remove(changed)
+
+	err := send("+ idling")
+	if err != nil {
+		return err
+	}
+
+	// Report changes that happened since the
+	// last command right away.
+	select {
+	case changed <- struct{}{}:
+	default:
+	}
+
+	for {
+
+		select {
+
+		case <-stop:
+			return nil
+
+		case <-changed:
+
+			// Lock exclusively as the known mails of
+			// the session are updated as well. Read the
+			// state under the same lock, as it belongs
+			// to the selected mailbox.
+			var answerLines []string
+
+			mailbox.Lock.Lock()
+			if s.State == StateMailbox {
+				answerLines = mailbox.pendingUpdates(s)
+			}
+			mailbox.Lock.Unlock()
+
+			if len(answerLines) == 0 {
+				continue
+			}
+
+			err := send(strings.Join(answerLines, "\r\n"))
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// notify informs all sessions in IDLE about
+// a change to the mailbox.
+func (mailbox *Mailbox) notify() {
+	mailbox.Watchers.Notify()
+}
+
+// init registers the IDLE capability.
+func init() {
+	RegisterCapability(&Capability{Name: "IDLE", PostAuth: true, Commands: []string{CommandIdle}})
+}
@@ -0,0 +1,159 @@
+package imap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Variables
+
+var idleStateTests = []struct {
+	state   State
+	payload string
+	answer  string
+}{
+	{StateAuthenticated, " now", "a1 BAD Command IDLE was sent with extra parameters"},
+	{StateNotAuthenticated, "", "a1 BAD Command IDLE cannot be executed in this state"},
+	{StateLogout, "", "a1 BAD Command IDLE cannot be executed in this state"},
+}
+
+// Functions
+
+// receiveIdle returns the next text sent during
+// IDLE or fails the test after a second.
+func receiveIdle(t *testing.T, sent chan string) string {
+
+	select {
+	case text := <-sent:
+		return text
+	case <-time.After(time.Second):
+		t.Fatalf("expected IDLE to send a response")
+	}
+
+	return ""
+}
+
+// TestWatchers executes a white-box unit test on
+// registering, notifying, and removing watchers.
+func TestWatchers(t *testing.T) {
+
+	w := NewWatchers()
+
+	first := w.Add()
+	second := w.Add()
+	assert.Equalf(t, 2, len(w.chans), "expected two watchers to be registered")
+
+	// Signals are buffered once, notifying
+	// again does not block.
+	w.Notify()
+	w.Notify()
+	assert.Equalf(t, 1, len(first), "expected first watcher to be signalled once")
+	assert.Equalf(t, 1, len(second), "expected second watcher to be signalled once")
+
+	<-first
+	<-second
+
+	w.Remove(first)
+	assert.Equalf(t, 1, len(w.chans), "expected one watcher to be left")
+
+	w.Notify()
+	assert.Equalf(t, 0, len(first), "expected removed watcher not to be signalled")
+	assert.Equalf(t, 1, len(second), "expected remaining watcher to be signalled")
+
+	w.Remove(second)
+	assert.Equalf(t, 0, len(w.chans), "expected no watcher to be left")
+}
+
+// TestIdle executes a black-box unit test on IDLE
+// reporting pending changes of the selected mailbox.
+func TestIdle(t *testing.T) {
+
+	mailbox, cleanup := newTestMailbox(t)
+	defer cleanup()
+
+	date := time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)
+	addTestMail(t, mailbox, "INBOX", "1400000001.a", "", date, "Subject: First\r\n\r\nOne.\r\n")
+
+	for _, test := range idleStateTests {
+
+		s := &Session{
+			State:    test.state,
+			UserName: "user",
+		}
+
+		req, _ := ParseRequest("a1 IDLE" + test.payload)
+
+		var answer string
+		err := mailbox.Idle(s, req, nil, func(text string) error {
+			answer = text
+			return nil
+		})
+		assert.Nilf(t, err, "expected IDLE not to fail but got: %v", err)
+		assert.Equalf(t, test.answer, answer, "unexpected answer to IDLE%s in state %d", test.payload, test.state)
+	}
+
+	assert.Equalf(t, 0, len(mailbox.Watchers.chans), "expected refused IDLE not to register a watcher")
+
+	s := &Session{
+		State:           StateMailbox,
+		UserName:        "user",
+		SelectedMailbox: "INBOX",
+		KnownMails:      append([]string(nil), mailbox.Mails["INBOX"]...),
+	}
+
+	// A mail delivered before IDLE is reported
+	// right after the continuation request.
+	addTestMail(t, mailbox, "INBOX", "1400000002.b", "", date, "Subject: Second\r\n\r\nTwo.\r\n")
+
+	req, _ := ParseRequest("a1 IDLE")
+	stop := make(chan struct{})
+	sent := make(chan string, 4)
+	done := make(chan error)
+
+	go func() {
+		done <- mailbox.Idle(s, req, stop, func(text string) error {
+			sent <- text
+			return nil
+		})
+	}()
+
+	assert.Equalf(t, "+ idling", receiveIdle(t, sent), "expected continuation request")
+	assert.Equalf(t, "* 2 EXISTS", receiveIdle(t, sent), "expected pending mail to be reported")
+
+	mailbox.Lock.RLock()
+	assert.Equalf(t, mailbox.Mails["INBOX"], s.KnownMails, "expected client to know the reported mail")
+	assert.Equalf(t, 1, len(mailbox.Watchers.chans), "expected IDLE to register a watcher")
+	mailbox.Lock.RUnlock()
+
+	// Changes of others are reported once
+	// watchers are notified of them.
+	mailbox.Lock.Lock()
+	mailbox.Mails["INBOX"] = mailbox.Mails["INBOX"][1:]
+	mailbox.Lock.Unlock()
+	mailbox.notify()
+
+	assert.Equalf(t, "* 1 EXPUNGE", receiveIdle(t, sent), "expected removed mail to be reported")
+
+	mailbox.Lock.RLock()
+	assert.Equalf(t, mailbox.Mails["INBOX"], s.KnownMails, "expected client to know of the removal")
+	mailbox.Lock.RUnlock()
+
+	// Without changes, nothing is sent.
+	mailbox.notify()
+
+	// Stopping IDLE unregisters its watcher
+	// and leaves the tagged response to the caller.
+	close(stop)
+
+	select {
+	case err := <-done:
+		assert.Nilf(t, err, "expected IDLE to end without error but got: %v", err)
+	case <-time.After(time.Second):
+		t.Fatalf("expected IDLE to end after being stopped")
+	}
+
+	assert.Equalf(t, 0, len(sent), "expected nothing else to be sent")
+	assert.Equalf(t, 0, len(mailbox.Watchers.chans), "expected watcher to be removed")
+}
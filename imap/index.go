@@ -0,0 +1,340 @@
+package imap
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"os"
+	"strings"
+	"time"
+
+	"encoding/base64"
+	"io/ioutil"
+	"mime/quotedprintable"
+	"net/mail"
+	"path/filepath"
+)
+
+// Structs
+
+// MessageIndex keeps searchable metadata of all mails
+// of one user so that SEARCH does not have to read and
+// parse every mail file on each request. Entries are
+// addressed by folder and Maildir key of a mail, thus
+// stay valid when flags change the mail's file name.
+type MessageIndex struct {
+	Folders map[string]map[string]*IndexEntry
+}
+
+// IndexEntry contains the metadata of one mail. Header
+// values are decoded and lower-cased for case-insensitive
+// matching. The sort keys of RFC 5256 and the message IDs
+// needed to thread mails are extracted once when the mail
+// is indexed. Bodies are not kept in memory, searches in
+// them read the mail file instead.
+type IndexEntry struct {
+	Size         int64
+	InternalDate time.Time
+	SentDate     time.Time
+	Header       map[string][]string
+	HeaderText   string
+	BaseSubject  string
+	IsReply      bool
+	SortFrom     string
+	SortTo       string
+	SortCc       string
+	MessageID    string
+	References   []string
+}
+
+// base64Cleaner drops line breaks from base64
+// encoded content before it is decoded.
+type base64Cleaner struct {
+	reader io.Reader
+}
+
+// Functions
+
+// NewMessageIndex returns an empty message index.
+func NewMessageIndex() *MessageIndex {
+
+	return &MessageIndex{
+		Folders: make(map[string]map[string]*IndexEntry),
+	}
+}
+
+// Add parses supplied mail content and records the
+// resulting entry under folder and key, replacing
+// any previous entry.
+func (idx *MessageIndex) Add(folder string, key string, content []byte, internalDate time.Time) *IndexEntry {
+
+	msg := parseMessagePart(content, "text/plain")
+	decoder := &mime.WordDecoder{}
+
+	entry := &IndexEntry{
+		Size:         int64(len(content)),
+		InternalDate: internalDate,
+		Header:       make(map[string][]string),
+		HeaderText:   strings.ToLower(string(msg.Header)),
+	}
+
+	for name, values := range msg.Fields {
+
+		decValues := make([]string, 0, len(values))
+
+		for _, value := range values {
+
+			// Fall back to the raw value if encoded
+			// words could not be decoded.
+			decValue, err := decoder.DecodeHeader(value)
+			if err != nil {
+				decValue = value
+			}
+
+			decValues = append(decValues, strings.ToLower(decValue))
+		}
+
+		entry.Header[strings.ToLower(name)] = decValues
+	}
+
+	if sentDate, err := mailDate(msg.Fields.Get("Date")); err == nil {
+		entry.SentDate = sentDate
+	}
+
+	subject := ""
+	if values := entry.Header["subject"]; len(values) > 0 {
+		subject = values[0]
+	}
+	entry.BaseSubject, entry.IsReply = baseSubject(subject)
+
+	entry.SortFrom = sortAddress(msg.Fields.Get("From"))
+	entry.SortTo = sortAddress(msg.Fields.Get("To"))
+	entry.SortCc = sortAddress(msg.Fields.Get("Cc"))
+
+	if ids := messageIDs(msg.Fields.Get("Message-Id")); len(ids) > 0 {
+		entry.MessageID = ids[0]
+	}
+
+	// In-Reply-To is only consulted if no
+	// References header field is present.
+	entry.References = messageIDs(msg.Fields.Get("References"))
+	if len(entry.References) == 0 {
+
+		if ids := messageIDs(msg.Fields.Get("In-Reply-To")); len(ids) > 0 {
+			entry.References = ids[:1]
+		}
+	}
+
+	f, found := idx.Folders[folder]
+	if !found {
+		f = make(map[string]*IndexEntry)
+		idx.Folders[folder] = f
+	}
+
+	f[key] = entry
+
+	return entry
+}
+
+// Lookup returns the entry stored for
+// key in folder, if present.
+func (idx *MessageIndex) Lookup(folder string, key string) (*IndexEntry, bool) {
+
+	entry, found := idx.Folders[folder][key]
+
+	return entry, found
+}
+
+// Remove deletes the entry of key in folder.
+func (idx *MessageIndex) Remove(folder string, key string) {
+
+	if f, found := idx.Folders[folder]; found {
+		delete(f, key)
+	}
+}
+
+// RemoveFolder deletes all entries of folder.
+func (idx *MessageIndex) RemoveFolder(folder string) {
+	delete(idx.Folders, folder)
+}
+
+// mailDate parses the value of a Date header.
+func mailDate(value string) (time.Time, error) {
+
+	// Try the layouts net/mail knows of first and
+	// fall back to dates without day of week.
+	date, err := mail.ParseDate(value)
+	if err == nil {
+		return date, nil
+	}
+
+	for _, layout := range []string{"2 Jan 2006 15:04:05 -0700", "2 Jan 2006 15:04 -0700"} {
+
+		date, err = time.Parse(layout, strings.TrimSpace(value))
+		if err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, err
+}
+
+// SortDate returns the date SORT and THREAD order
+// mails by, which is the sent date or, if the mail
+// carries no valid Date header, the internal date.
+func (entry *IndexEntry) SortDate() time.Time {
+
+	if entry.SentDate.IsZero() {
+		return entry.InternalDate
+	}
+
+	return entry.SentDate
+}
+
+// sortAddress returns the lower-cased local part of
+// the first address in an address header value as
+// used by SORT, or the empty string if there is none.
+func sortAddress(value string) string {
+
+	addrs, err := mail.ParseAddressList(value)
+	if (err != nil) || (len(addrs) == 0) {
+		return ""
+	}
+
+	local := addrs[0].Address
+	if at := strings.LastIndexByte(local, '@'); at >= 0 {
+		local = local[:at]
+	}
+
+	return strings.ToLower(local)
+}
+
+// messageIDs returns all message IDs including their
+// angle brackets contained in a header value such as
+// the one of References.
+func messageIDs(value string) []string {
+
+	ids := make([]string, 0)
+
+	for {
+
+		start := strings.IndexByte(value, '<')
+		if start < 0 {
+			break
+		}
+
+		end := strings.IndexByte(value[start:], '>')
+		if end < 0 {
+			break
+		}
+
+		// Folding may have inserted whitespace.
+		ids = append(ids, strings.Join(strings.Fields(value[start:(start+end+1)]), ""))
+		value = value[(start + end + 1):]
+	}
+
+	return ids
+}
+
+// mailText returns the lower-cased and decoded
+// text of all textual parts of a mail.
+func mailText(content []byte) string {
+
+	text := &bytes.Buffer{}
+	collectText(parseMessagePart(content, "text/plain"), text)
+
+	return strings.ToLower(text.String())
+}
+
+// collectText appends the decoded content of all
+// textual leaf parts of a message to buf.
+func collectText(part *messagePart, buf *bytes.Buffer) {
+
+	if part.Message != nil {
+		collectText(part.Message, buf)
+		return
+	}
+
+	if len(part.Children) > 0 {
+
+		for _, child := range part.Children {
+			collectText(child, buf)
+		}
+
+		return
+	}
+
+	if !strings.HasPrefix(part.MediaType, "text/") {
+		return
+	}
+
+	var reader io.Reader = bytes.NewReader(part.Body)
+
+	switch strings.ToLower(part.Fields.Get("Content-Transfer-Encoding")) {
+	case "quoted-printable":
+		reader = quotedprintable.NewReader(reader)
+	case "base64":
+		reader = base64.NewDecoder(base64.StdEncoding, &base64Cleaner{reader: reader})
+	}
+
+	decoded, err := ioutil.ReadAll(reader)
+	if err != nil {
+		decoded = part.Body
+	}
+
+	buf.Write(decoded)
+	buf.WriteString("\n")
+}
+
+// Read implements io.Reader.
+func (c *base64Cleaner) Read(p []byte) (int, error) {
+
+	n, err := c.reader.Read(p)
+
+	clean := 0
+	for i := 0; i < n; i++ {
+
+		if (p[i] != '\r') && (p[i] != '\n') {
+			p[clean] = p[i]
+			clean++
+		}
+	}
+
+	return clean, err
+}
+
+// indexMail adds the mail stored at mailFilePath with
+// supplied content to the message index of folder.
+// Its modification time is taken as internal date.
+func (mailbox *Mailbox) indexMail(folder string, mailFilePath string, content []byte) {
+
+	internalDate := time.Now()
+	if info, err := os.Stat(mailFilePath); err == nil {
+		internalDate = info.ModTime()
+	}
+
+	mailbox.Index.Add(folder, MailKey(filepath.Base(mailFilePath)), content, internalDate)
+}
+
+// indexEntry returns the index entry of supplied mail
+// in folder, reading the mail file to create it if the
+// mail was not yet indexed, e.g. after a restart.
+func (mailbox *Mailbox) indexEntry(folder string, mailFilePath string) (*IndexEntry, error) {
+
+	entry, found := mailbox.Index.Lookup(folder, MailKey(filepath.Base(mailFilePath)))
+	if found {
+		return entry, nil
+	}
+
+	content, err := ioutil.ReadFile(mailFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(mailFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return mailbox.Index.Add(folder, MailKey(filepath.Base(mailFilePath)), content, info.ModTime()), nil
+}
@@ -0,0 +1,95 @@
+package imap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Constants
+
+// systemFlags lists the IMAP system flags that can
+// be stored permanently, in the order announced in
+// FLAGS and PERMANENTFLAGS responses.
+const systemFlags = "\\Answered \\Flagged \\Deleted \\Seen \\Draft"
+
+// Functions
+
+// isKeyword returns true if supplied flag is a
+// keyword, i.e. a user-defined flag as opposed
+// to a system flag starting with a backslash.
+func isKeyword(flag string) bool {
+	return (flag != "") && !strings.HasPrefix(flag, "\\")
+}
+
+// keywordFlags returns the Maildir letters of all
+// keywords contained in flags. If create is true,
+// keywords not yet known are bound to a free letter,
+// otherwise they are skipped. The second return value
+// reports whether new keywords were defined. The caller
+// is required to hold the lock.
+func (mailbox *Mailbox) keywordFlags(flags map[string]struct{}, create bool) (string, bool, error) {
+
+	letters := make([]rune, 0, len(flags))
+	created := false
+
+	for flag := range flags {
+
+		if !isKeyword(flag) {
+			continue
+		}
+
+		if !create {
+
+			if letter, found := mailbox.Keywords.Letter(flag); found {
+				letters = append(letters, letter)
+			}
+
+			continue
+		}
+
+		letter, isNew, err := mailbox.Keywords.Add(flag)
+		if err != nil {
+			return "", false, fmt.Errorf("Cannot define keyword %s, %v", flag, err)
+		}
+
+		letters = append(letters, letter)
+		created = created || isNew
+	}
+
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i] < letters[j]
+	})
+
+	return string(letters), created, nil
+}
+
+// flagsList returns the flags defined in this mailbox:
+// all system flags followed by all known keywords.
+// The caller is required to hold the lock.
+func (mailbox *Mailbox) flagsList() string {
+
+	flags := systemFlags
+
+	for _, keyword := range mailbox.Keywords.Bindings() {
+
+		if keyword != "" {
+			flags = fmt.Sprintf("%s %s", flags, keyword)
+		}
+	}
+
+	return flags
+}
+
+// permanentFlags returns the flags a client can change
+// permanently in this mailbox. Unless all letters are
+// in use, new keywords may be defined, which is denoted
+// by \*. The caller is required to hold the lock.
+func (mailbox *Mailbox) permanentFlags() string {
+
+	if mailbox.Keywords.Full() {
+		return mailbox.flagsList()
+	}
+
+	return fmt.Sprintf("%s \\*", mailbox.flagsList())
+}
@@ -0,0 +1,431 @@
+package imap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-pluto/pluto/comm"
+)
+
+// Structs
+
+// listOptions collects the selection and return
+// options of an extended LIST command (RFC 5258)
+// as well as its canonical mailbox patterns.
+type listOptions struct {
+	Patterns         []string
+	SelectSubscribed bool
+	SelectSpecialUse bool
+	RecursiveMatch   bool
+	ReturnSubscribed bool
+	ReturnStatus     []string
+}
+
+// Functions
+
+// List allows clients to learn about the mailboxes
+// available and also returns the hierarchy delimiter.
+// Supplied patterns are interpreted relative to the
+// reference name and may contain the wildcards '*',
+// matching any characters, and '%', matching any
+// characters but the hierarchy delimiter. Besides the
+// basic form, the extended form of LIST-EXTENDED with
+// selection options SUBSCRIBED, REMOTE, RECURSIVEMATCH,
+// and SPECIAL-USE and return options SUBSCRIBED,
+// CHILDREN, SPECIAL-USE, and STATUS of LIST-STATUS
+// (RFC 5819) is supported. Special-use attributes
+// (RFC 6154) are always returned.
+func (mailbox *Mailbox) List(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+
+	if (s.State != StateAuthenticated) && (s.State != StateMailbox) {
+
+		// If connection was not in correct state when this
+		// command was executed, this is a client error.
+		// Send tagged BAD response.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command LIST cannot be executed in this state", req.Tag),
+		}, nil
+	}
+
+	opts, err := mailbox.parseListOptions(s, req.Args)
+	if err != nil {
+
+		// If payload could not be parsed, this is
+		// a client error. Return BAD statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command LIST %s", req.Tag, err.Error()),
+		}, nil
+	}
+
+	if (len(opts.Patterns) == 1) && (opts.Patterns[0] == "") {
+
+		// An empty pattern requests the hierarchy
+		// delimiter and root name of the reference.
+		return &Reply{
+			Text: fmt.Sprintf("* LIST (\\Noselect) \"%s\" \"\"\r\n%s OK LIST completed", mailbox.HierarchySeparator, req.Tag),
+		}, nil
+	}
+
+	mailbox.Lock.RLock()
+	defer mailbox.Lock.RUnlock()
+
+	existing := make(map[string]bool)
+	for _, folder := range mailbox.Structure.GetAllValues() {
+		existing[folder] = true
+	}
+
+	subscribed := make(map[string]bool)
+	for _, folder := range mailbox.Subscriptions.GetAllValues() {
+		subscribed[folder] = true
+	}
+
+	// Collect all names that may be listed: existing
+	// folders, subscribed ones if selected, and all
+	// levels of hierarchy above them.
+	candidates := make(map[string]bool)
+	for folder := range existing {
+		mailbox.addWithParents(candidates, folder)
+	}
+
+	if opts.SelectSubscribed {
+
+		for folder := range subscribed {
+			mailbox.addWithParents(candidates, folder)
+		}
+	}
+
+	names := make([]string, 0, len(candidates))
+	for name := range candidates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	answerLines := make([]string, 0, len(names))
+
+	for _, name := range names {
+
+		if !mailbox.listMatchesAny(opts.Patterns, name) {
+			continue
+		}
+
+		childInfo := false
+
+		if opts.SelectSubscribed && !subscribed[name] {
+
+			// With RECURSIVEMATCH, names that are not
+			// subscribed themselves are still listed if
+			// one of their descendants is subscribed.
+			if !opts.RecursiveMatch || !mailbox.hasDescendant(subscribed, name) {
+				continue
+			}
+
+			childInfo = true
+		}
+
+		specialUse := ""
+		if existing[name] {
+			specialUse = mailbox.specialUse(name)
+		}
+
+		if opts.SelectSpecialUse && (specialUse == "") {
+			continue
+		}
+
+		attributes := make([]string, 0, 4)
+
+		if subscribed[name] && (opts.SelectSubscribed || opts.ReturnSubscribed) {
+			attributes = append(attributes, "\\Subscribed")
+		}
+
+		if existing[name] {
+
+			if mailbox.hasDescendant(existing, name) {
+				attributes = append(attributes, "\\HasChildren")
+			} else {
+				attributes = append(attributes, "\\HasNoChildren")
+			}
+		} else if mailbox.hasDescendant(existing, name) {
+			attributes = append(attributes, "\\Noselect", "\\HasChildren")
+		} else {
+			attributes = append(attributes, "\\NonExistent")
+		}
+
+		if specialUse != "" {
+			attributes = append(attributes, specialUse)
+		}
+
+		answerLine := fmt.Sprintf("* LIST (%s) \"%s\" %s", strings.Join(attributes, " "), mailbox.HierarchySeparator, s.quoteMailbox(name))
+		if childInfo {
+			answerLine = fmt.Sprintf("%s (\"CHILDINFO\" (\"SUBSCRIBED\"))", answerLine)
+		}
+
+		answerLines = append(answerLines, answerLine)
+
+		if (opts.ReturnStatus != nil) && existing[name] {
+
+			// LIST-STATUS places the STATUS response
+			// right after the mailbox's LIST response.
+			statusAnswer, err := mailbox.folderStatus(s, name, opts.ReturnStatus)
+			if err != nil {
+
+				return &Reply{
+					Text:   "* BAD Internal server error, sorry. Closing connection.",
+					Status: 1,
+				}, err
+			}
+
+			answerLines = append(answerLines, statusAnswer)
+		}
+	}
+
+	answerLines = append(answerLines, fmt.Sprintf("%s OK LIST completed", req.Tag))
+
+	return &Reply{
+		Text: strings.Join(answerLines, "\r\n"),
+	}, nil
+}
+
+// parseListOptions parses the arguments of a basic or
+// extended LIST command and returns all options found.
+func (mailbox *Mailbox) parseListOptions(s *Session, args []*Node) (*listOptions, error) {
+
+	opts := &listOptions{}
+
+	if (len(args) > 0) && args[0].IsList() {
+
+		// Payload starts with a list of selection options.
+		for _, option := range args[0].Items {
+
+			if option.Type != NodeAtom {
+				return nil, fmt.Errorf("was sent with invalid selection options")
+			}
+
+			switch strings.ToUpper(option.Value) {
+			case "SUBSCRIBED":
+				opts.SelectSubscribed = true
+			case "REMOTE":
+				// There are no remote mailboxes to include.
+			case "RECURSIVEMATCH":
+				opts.RecursiveMatch = true
+			case "SPECIAL-USE":
+				opts.SelectSpecialUse = true
+			default:
+				return nil, fmt.Errorf("was sent with unknown selection option %s", option.Value)
+			}
+		}
+
+		if opts.RecursiveMatch && !opts.SelectSubscribed && !opts.SelectSpecialUse {
+			return nil, fmt.Errorf("needs another selection option next to RECURSIVEMATCH")
+		}
+
+		args = args[1:]
+	}
+
+	if len(args) < 2 {
+		return nil, fmt.Errorf("was not sent with a reference and a mailbox name")
+	}
+
+	reference, ok := s.mailboxName(args[0])
+	if !ok {
+		return nil, fmt.Errorf("was not sent with a reference and a mailbox name")
+	}
+
+	var patterns []string
+	if args[1].IsList() {
+
+		for _, pattern := range args[1].Items {
+
+			value, ok := s.mailboxName(pattern)
+			if !ok {
+				return nil, fmt.Errorf("was sent with invalid mailbox patterns")
+			}
+
+			patterns = append(patterns, value)
+		}
+
+		if len(patterns) == 0 {
+			return nil, fmt.Errorf("was sent with an empty list of mailbox patterns")
+		}
+	} else {
+
+		pattern, ok := s.mailboxName(args[1])
+		if !ok {
+			return nil, fmt.Errorf("was sent with invalid mailbox patterns")
+		}
+
+		patterns = []string{pattern}
+	}
+
+	for _, pattern := range patterns {
+
+		if pattern == "" {
+
+			// Keep empty pattern as is, it requests
+			// the hierarchy delimiter.
+			opts.Patterns = append(opts.Patterns, "")
+			continue
+		}
+
+		opts.Patterns = append(opts.Patterns, mailbox.canonicalPattern(reference, pattern))
+	}
+
+	args = args[2:]
+
+	if len(args) == 0 {
+		return opts, nil
+	}
+
+	if (len(args) != 2) || (args[0].Type != NodeAtom) || (strings.ToUpper(args[0].Value) != "RETURN") || !args[1].IsList() {
+		return nil, fmt.Errorf("was sent with invalid return options")
+	}
+
+	for i := 0; i < len(args[1].Items); i++ {
+
+		option := args[1].Items[i]
+		if option.Type != NodeAtom {
+			return nil, fmt.Errorf("was sent with invalid return options")
+		}
+
+		switch strings.ToUpper(option.Value) {
+		case "SUBSCRIBED":
+			opts.ReturnSubscribed = true
+		case "CHILDREN", "SPECIAL-USE":
+			// Child information and special-use
+			// attributes are always returned.
+		case "STATUS":
+
+			// STATUS is followed by a list of status items.
+			if ((i + 1) >= len(args[1].Items)) || !args[1].Items[(i+1)].IsList() {
+				return nil, fmt.Errorf("was sent without status items for return option STATUS")
+			}
+
+			opts.ReturnStatus = make([]string, 0, len(args[1].Items[(i+1)].Items))
+
+			for _, statusItem := range args[1].Items[(i + 1)].Items {
+
+				item := strings.ToUpper(statusItem.Value)
+				if (statusItem.Type != NodeAtom) || !StatusItems[item] {
+					return nil, fmt.Errorf("was sent with unknown status item %s", statusItem.Value)
+				}
+
+				opts.ReturnStatus = append(opts.ReturnStatus, item)
+			}
+
+			i++
+
+		default:
+			return nil, fmt.Errorf("was sent with unknown return option %s", option.Value)
+		}
+	}
+
+	return opts, nil
+}
+
+// canonicalPattern combines reference name and mailbox
+// pattern into one pattern relative to the root of the
+// user's mailbox. Names of INBOX are case-insensitive
+// and thus normalized to upper case.
+func (mailbox *Mailbox) canonicalPattern(reference string, pattern string) string {
+
+	canonical := pattern
+	if (reference != "") && !strings.HasPrefix(pattern, mailbox.HierarchySeparator) {
+
+		if strings.HasSuffix(reference, mailbox.HierarchySeparator) {
+			canonical = reference + pattern
+		} else {
+			canonical = reference + mailbox.HierarchySeparator + pattern
+		}
+	}
+
+	canonical = strings.TrimPrefix(canonical, mailbox.HierarchySeparator)
+
+	if (len(canonical) >= 5) && strings.EqualFold(canonical[:5], "INBOX") {
+		canonical = "INBOX" + canonical[5:]
+	}
+
+	return canonical
+}
+
+// listMatchesAny returns true if the folder name
+// matches at least one of the supplied patterns.
+func (mailbox *Mailbox) listMatchesAny(patterns []string, folder string) bool {
+
+	for _, pattern := range patterns {
+
+		if mailbox.listMatches(pattern, folder) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// listMatches returns true if the folder name matches
+// the supplied canonical LIST or LSUB pattern. '*'
+// matches zero or more characters, '%' does as well
+// but does not match the hierarchy delimiter.
+func (mailbox *Mailbox) listMatches(pattern string, folder string) bool {
+
+	sep := mailbox.HierarchySeparator
+
+	// matches[j] states whether the pattern suffix
+	// considered so far matches folder[j:].
+	matches := make([]bool, (len(folder) + 1))
+	matches[len(folder)] = true
+
+	for i := (len(pattern) - 1); i >= 0; i-- {
+
+		next := matches
+		matches = make([]bool, (len(folder) + 1))
+
+		for j := len(folder); j >= 0; j-- {
+
+			switch pattern[i] {
+			case '*':
+				matches[j] = next[j] || ((j < len(folder)) && matches[(j+1)])
+			case '%':
+				matches[j] = next[j] || ((j < len(folder)) && !strings.HasPrefix(folder[j:], sep) && matches[(j+1)])
+			default:
+				matches[j] = (j < len(folder)) && (folder[j] == pattern[i]) && next[(j+1)]
+			}
+		}
+	}
+
+	return matches[0]
+}
+
+// addWithParents adds folder and all levels of
+// hierarchy above it to the set of names.
+func (mailbox *Mailbox) addWithParents(names map[string]bool, folder string) {
+
+	names[folder] = true
+
+	for i := strings.LastIndex(folder, mailbox.HierarchySeparator); i > 0; i = strings.LastIndex(folder[:i], mailbox.HierarchySeparator) {
+		names[folder[:i]] = true
+	}
+}
+
+// hasDescendant returns true if any folder in the
+// set of names is located below the supplied one.
+func (mailbox *Mailbox) hasDescendant(names map[string]bool, folder string) bool {
+
+	prefix := folder + mailbox.HierarchySeparator
+
+	for name := range names {
+
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// init registers the capabilities of
+// extensions to LIST.
+func init() {
+	RegisterCapability(&Capability{Name: "CHILDREN", PostAuth: true})
+	RegisterCapability(&Capability{Name: "LIST-EXTENDED", PostAuth: true})
+	RegisterCapability(&Capability{Name: "LIST-STATUS", PostAuth: true})
+}
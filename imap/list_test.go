@@ -0,0 +1,156 @@
+package imap
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-pluto/pluto/comm"
+	"github.com/stretchr/testify/assert"
+)
+
+// Variables
+
+var listTests = []struct {
+	payload string
+	lines   []string
+}{
+	{"\"\" *", []string{
+		"* LIST (\\HasNoChildren) \".\" Archive",
+		"* LIST (\\HasNoChildren) \".\" INBOX",
+		"* LIST (\\HasChildren) \".\" Work",
+		"* LIST (\\HasChildren) \".\" Work.Projects",
+		"* LIST (\\HasNoChildren) \".\" Work.Projects.Pluto",
+		"a1 OK LIST completed",
+	}},
+	{"\"\" %", []string{
+		"* LIST (\\HasNoChildren) \".\" Archive",
+		"* LIST (\\HasNoChildren) \".\" INBOX",
+		"* LIST (\\HasChildren) \".\" Work",
+		"a1 OK LIST completed",
+	}},
+	{"Work %", []string{
+		"* LIST (\\HasChildren) \".\" Work.Projects",
+		"a1 OK LIST completed",
+	}},
+	{"\"\" inbox", []string{
+		"* LIST (\\HasNoChildren) \".\" INBOX",
+		"a1 OK LIST completed",
+	}},
+	{"\"\" (INBOX Work.*)", []string{
+		"* LIST (\\HasNoChildren) \".\" INBOX",
+		"* LIST (\\HasChildren) \".\" Work.Projects",
+		"* LIST (\\HasNoChildren) \".\" Work.Projects.Pluto",
+		"a1 OK LIST completed",
+	}},
+	{"\"\" \"\"", []string{
+		"* LIST (\\Noselect) \".\" \"\"",
+		"a1 OK LIST completed",
+	}},
+	{"(REMOTE) \"\" %", []string{
+		"* LIST (\\HasNoChildren) \".\" Archive",
+		"* LIST (\\HasNoChildren) \".\" INBOX",
+		"* LIST (\\HasChildren) \".\" Work",
+		"a1 OK LIST completed",
+	}},
+	{"(SUBSCRIBED) \"\" *", []string{
+		"* LIST (\\Subscribed \\HasNoChildren) \".\" INBOX",
+		"* LIST (\\Subscribed \\NonExistent) \".\" Old.Mail",
+		"* LIST (\\Subscribed \\HasChildren) \".\" Work.Projects",
+		"a1 OK LIST completed",
+	}},
+	{"(SUBSCRIBED RECURSIVEMATCH) \"\" %", []string{
+		"* LIST (\\Subscribed \\HasNoChildren) \".\" INBOX",
+		"* LIST (\\NonExistent) \".\" Old (\"CHILDINFO\" (\"SUBSCRIBED\"))",
+		"* LIST (\\HasChildren) \".\" Work (\"CHILDINFO\" (\"SUBSCRIBED\"))",
+		"a1 OK LIST completed",
+	}},
+	{"\"\" % RETURN (SUBSCRIBED)", []string{
+		"* LIST (\\HasNoChildren) \".\" Archive",
+		"* LIST (\\Subscribed \\HasNoChildren) \".\" INBOX",
+		"* LIST (\\HasChildren) \".\" Work",
+		"a1 OK LIST completed",
+	}},
+	{"\"\" % RETURN (CHILDREN)", []string{
+		"* LIST (\\HasNoChildren) \".\" Archive",
+		"* LIST (\\HasNoChildren) \".\" INBOX",
+		"* LIST (\\HasChildren) \".\" Work",
+		"a1 OK LIST completed",
+	}},
+	{"\"\" % RETURN (STATUS (MESSAGES UNSEEN))", []string{
+		"* LIST (\\HasNoChildren) \".\" Archive",
+		"* STATUS Archive (MESSAGES 2 UNSEEN 1)",
+		"* LIST (\\HasNoChildren) \".\" INBOX",
+		"* STATUS INBOX (MESSAGES 0 UNSEEN 0)",
+		"* LIST (\\HasChildren) \".\" Work",
+		"* STATUS Work (MESSAGES 0 UNSEEN 0)",
+		"a1 OK LIST completed",
+	}},
+	{"(SUBSCRIBED) \"\" * RETURN (STATUS (MESSAGES))", []string{
+		"* LIST (\\Subscribed \\HasNoChildren) \".\" INBOX",
+		"* STATUS INBOX (MESSAGES 0)",
+		"* LIST (\\Subscribed \\NonExistent) \".\" Old.Mail",
+		"* LIST (\\Subscribed \\HasChildren) \".\" Work.Projects",
+		"* STATUS Work.Projects (MESSAGES 0)",
+		"a1 OK LIST completed",
+	}},
+	{"\"\"", []string{"a1 BAD Command LIST was not sent with a reference and a mailbox name"}},
+	{"(RECURSIVEMATCH) \"\" *", []string{"a1 BAD Command LIST needs another selection option next to RECURSIVEMATCH"}},
+	{"(UNKNOWN) \"\" *", []string{"a1 BAD Command LIST was sent with unknown selection option UNKNOWN"}},
+	{"\"\" () ", []string{"a1 BAD Command LIST was sent with an empty list of mailbox patterns"}},
+	{"\"\" * RETURN SUBSCRIBED", []string{"a1 BAD Command LIST was sent with invalid return options"}},
+	{"\"\" * RETURN (UNKNOWN)", []string{"a1 BAD Command LIST was sent with unknown return option UNKNOWN"}},
+	{"\"\" * RETURN (STATUS)", []string{"a1 BAD Command LIST was sent without status items for return option STATUS"}},
+	{"\"\" * RETURN (STATUS (BOGUS))", []string{"a1 BAD Command LIST was sent with unknown status item BOGUS"}},
+}
+
+// Functions
+
+// TestList executes a black-box table test on LIST
+// in its basic form and with the selection and return
+// options of LIST-EXTENDED (RFC 5258) and LIST-STATUS.
+func TestList(t *testing.T) {
+
+	mailbox, cleanup := newTestMailbox(t, "Archive", "Work", "Work.Projects", "Work.Projects.Pluto", "Old.Mail")
+	defer cleanup()
+
+	date := time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)
+	addTestMail(t, mailbox, "Archive", "1400000001.a", "S", date, "Subject: First\r\n\r\nOne.\r\n")
+	addTestMail(t, mailbox, "Archive", "1400000002.b", "", date, "Subject: Second\r\n\r\nTwo.\r\n")
+
+	s := &Session{
+		State:    StateAuthenticated,
+		UserName: "user",
+	}
+
+	syncChan := make(chan comm.Msg, 1)
+
+	// Old.Mail stays subscribed after being deleted,
+	// leaving Old without any existing folder below.
+	for _, command := range []string{"SUBSCRIBE INBOX", "SUBSCRIBE Work.Projects", "SUBSCRIBE Old.Mail", "DELETE Old.Mail"} {
+
+		req, _ := ParseRequest("a1 " + command)
+
+		var reply *Reply
+		if strings.HasPrefix(command, CommandSubscribe) {
+			reply, _ = mailbox.Subscribe(s, req, syncChan)
+		} else {
+			reply, _ = mailbox.Delete(s, req, syncChan)
+		}
+
+		assert.Truef(t, strings.HasPrefix(reply.Text, "a1 OK "), "expected %s to succeed but got: %s", command, reply.Text)
+		<-syncChan
+	}
+
+	for _, test := range listTests {
+
+		req, err := ParseRequest("a1 LIST " + test.payload)
+		assert.Nilf(t, err, "expected LIST %s to be parsed but got: %v", test.payload, err)
+
+		reply, err := mailbox.List(s, req, syncChan)
+		assert.Nilf(t, err, "expected LIST %s not to fail but got: %v", test.payload, err)
+		assert.Equalf(t, strings.Join(test.lines, "\r\n"), reply.Text, "unexpected answer to LIST %s", test.payload)
+	}
+
+	assert.Equalf(t, 0, len(syncChan), "expected LIST not to send any update")
+}
@@ -0,0 +1,345 @@
+package imap
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"encoding/base64"
+	"path/filepath"
+)
+
+// Variables
+
+// mutf7Encoding is the modified BASE64 encoding of
+// RFC 3501, section 5.1.3, which uses ',' instead
+// of '/' and omits padding.
+var mutf7Encoding = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+,").WithPadding(base64.NoPadding).Strict()
+
+// Functions
+
+// EncodeMUTF7 encodes a mailbox name in modified UTF-7
+// as defined in RFC 3501, section 5.1.3. Printable
+// US-ASCII characters represent themselves, except for
+// '&' which is encoded as "&-". All other characters
+// are encoded as modified BASE64 of their UTF-16 form.
+func EncodeMUTF7(name string) string {
+
+	encoded := make([]byte, 0, len(name))
+
+	for i := 0; i < len(name); {
+
+		c := name[i]
+
+		if (c >= 0x20) && (c <= 0x7e) {
+
+			if c == '&' {
+				encoded = append(encoded, '&', '-')
+			} else {
+				encoded = append(encoded, c)
+			}
+
+			i++
+			continue
+		}
+
+		// Collect the whole run of characters
+		// that are not printable US-ASCII.
+		runes := make([]rune, 0, 4)
+		for i < len(name) && ((name[i] < 0x20) || (name[i] > 0x7e)) {
+
+			r, size := utf8.DecodeRuneInString(name[i:])
+			runes = append(runes, r)
+			i += size
+		}
+
+		units := utf16.Encode(runes)
+		raw := make([]byte, 0, (2 * len(units)))
+		for _, unit := range units {
+			raw = append(raw, byte(unit>>8), byte(unit))
+		}
+
+		encoded = append(encoded, '&')
+		encoded = append(encoded, mutf7Encoding.EncodeToString(raw)...)
+		encoded = append(encoded, '-')
+	}
+
+	return string(encoded)
+}
+
+// DecodeMUTF7 decodes a mailbox name sent in modified
+// UTF-7. Names not conforming to RFC 3501, e.g. those
+// containing 8-bit characters or needlessly encoded
+// printable US-ASCII characters, are rejected so that
+// each mailbox has exactly one encoded form.
+func DecodeMUTF7(encoded string) (string, error) {
+
+	name := make([]rune, 0, len(encoded))
+
+	for i := 0; i < len(encoded); i++ {
+
+		c := encoded[i]
+
+		if (c < 0x20) || (c > 0x7e) {
+			return "", fmt.Errorf("mailbox name contains invalid character")
+		}
+
+		if c != '&' {
+			name = append(name, rune(c))
+			continue
+		}
+
+		end := strings.IndexByte(encoded[i:], '-')
+		if end < 0 {
+			return "", fmt.Errorf("mailbox name contains unterminated encoding")
+		}
+		end += i
+
+		if end == (i + 1) {
+
+			// "&-" stands for '&'.
+			name = append(name, '&')
+			i = end
+			continue
+		}
+
+		raw, err := mutf7Encoding.DecodeString(encoded[(i + 1):end])
+		if (err != nil) || ((len(raw) % 2) != 0) {
+			return "", fmt.Errorf("mailbox name contains invalid encoding")
+		}
+
+		units := make([]uint16, (len(raw) / 2))
+		for j := range units {
+			units[j] = (uint16(raw[(2*j)]) << 8) | uint16(raw[((2*j)+1)])
+		}
+
+		for j := 0; j < len(units); j++ {
+
+			r := rune(units[j])
+
+			if utf16.IsSurrogate(r) {
+
+				if (j + 1) >= len(units) {
+					return "", fmt.Errorf("mailbox name contains invalid encoding")
+				}
+
+				r = utf16.DecodeRune(r, rune(units[(j+1)]))
+				if r == unicode.ReplacementChar {
+					return "", fmt.Errorf("mailbox name contains invalid encoding")
+				}
+
+				j++
+			}
+
+			// Printable US-ASCII characters must
+			// represent themselves.
+			if (r >= 0x20) && (r <= 0x7e) {
+				return "", fmt.Errorf("mailbox name contains invalid encoding")
+			}
+
+			name = append(name, r)
+		}
+
+		i = end
+	}
+
+	return string(name), nil
+}
+
+// MaildirFolder maps a mailbox folder name to the name
+// of the Maildir++ folder storing it below the user's
+// Maildir. INBOX is the Maildir itself, all other folders
+// are stored in a directory named after their modified
+// UTF-7 form prefixed with '.'. Path separators, '%',
+// glob metacharacters, and a leading '.' are escaped as
+// %XX, so the directory name never contains a path
+// separator and never equals "." or "..". Thus, no
+// folder name can lead out of the Maildir.
+func MaildirFolder(folder string) string {
+
+	if folder == "INBOX" {
+		return ""
+	}
+
+	encoded := EncodeMUTF7(folder)
+	dir := make([]byte, 1, (len(encoded) + 1))
+	dir[0] = '.'
+
+	for i := 0; i < len(encoded); i++ {
+
+		c := encoded[i]
+
+		if (strings.IndexByte("/\\%*?[]", c) >= 0) || ((i == 0) && (c == '.')) {
+			dir = append(dir, fmt.Sprintf("%%%02X", c)...)
+		} else {
+			dir = append(dir, c)
+		}
+	}
+
+	return string(dir)
+}
+
+// FolderFromMaildir reverses MaildirFolder and returns
+// the mailbox folder name stored in the Maildir++ folder
+// dir. An empty dir denotes INBOX.
+func FolderFromMaildir(dir string) (string, error) {
+
+	if dir == "" {
+		return "INBOX", nil
+	}
+
+	if !strings.HasPrefix(dir, ".") || (len(dir) < 2) {
+		return "", fmt.Errorf("%q is no Maildir++ folder", dir)
+	}
+
+	encoded := make([]byte, 0, len(dir))
+
+	for i := 1; i < len(dir); i++ {
+
+		if dir[i] != '%' {
+			encoded = append(encoded, dir[i])
+			continue
+		}
+
+		if (i + 2) >= len(dir) {
+			return "", fmt.Errorf("%q contains invalid escape", dir)
+		}
+
+		c, err := strconv.ParseUint(dir[(i+1):(i+3)], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("%q contains invalid escape", dir)
+		}
+
+		encoded = append(encoded, byte(c))
+		i += 2
+	}
+
+	return DecodeMUTF7(string(encoded))
+}
+
+// FolderPath returns the path of the Maildir storing
+// the supplied mailbox folder.
+func (mailbox *Mailbox) FolderPath(folder string) string {
+	return filepath.Join(mailbox.MaildirPath, MaildirFolder(folder))
+}
+
+// MigrateFolder moves a folder stored in the legacy layout,
+// i.e. directly below the user's Maildir under its plain
+// name, to the Maildir++ folder MaildirFolder maps it to.
+// Legacy folders outside the user's Maildir are ignored.
+func (mailbox *Mailbox) MigrateFolder(folder string) error {
+
+	if folder == "INBOX" {
+		return nil
+	}
+
+	legacyPath := filepath.Join(mailbox.MaildirPath, folder)
+	folderPath := mailbox.FolderPath(folder)
+
+	rel, err := filepath.Rel(mailbox.MaildirPath, legacyPath)
+	if (err != nil) || (rel == ".") || (rel == "..") || strings.HasPrefix(rel, (".."+string(filepath.Separator))) {
+		return nil
+	}
+
+	if legacyPath == folderPath {
+		return nil
+	}
+
+	if _, err := os.Stat(filepath.Join(legacyPath, "cur")); err != nil {
+		return nil
+	}
+
+	if _, err := os.Stat(folderPath); err == nil {
+		return nil
+	}
+
+	err = os.Rename(legacyPath, folderPath)
+	if err != nil {
+		return fmt.Errorf("error while migrating folder %s to Maildir++ layout: %v", folder, err)
+	}
+
+	return nil
+}
+
+// validFolderName checks that a folder name to be created
+// does not contain empty levels of hierarchy and consists
+// of valid, printable UTF-8 characters only.
+func (mailbox *Mailbox) validFolderName(folder string) bool {
+
+	if !utf8.ValidString(folder) {
+		return false
+	}
+
+	for _, r := range folder {
+
+		if (r < 0x20) || ((r >= 0x7f) && (r < 0xa0)) {
+			return false
+		}
+	}
+
+	for _, level := range strings.Split(folder, mailbox.HierarchySeparator) {
+
+		if level == "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// mailboxName returns the mailbox name sent by the
+// client as argument arg. Once UTF8=ACCEPT (RFC 6855)
+// is enabled, names are sent in UTF-8, otherwise in
+// modified UTF-7. It reports false if arg is no string
+// or not encoded correctly.
+func (s *Session) mailboxName(arg *Node) (string, bool) {
+
+	raw, ok := arg.AString()
+	if !ok {
+		return "", false
+	}
+
+	if s.UTF8Accept {
+		return raw, utf8.ValidString(raw)
+	}
+
+	name, err := DecodeMUTF7(raw)
+	if err != nil {
+		return "", false
+	}
+
+	return name, true
+}
+
+// quoteMailbox returns the mailbox name encoded as the
+// client expects it, as atom if possible and as quoted
+// string otherwise.
+func (s *Session) quoteMailbox(name string) string {
+
+	if !s.UTF8Accept {
+		name = EncodeMUTF7(name)
+	}
+
+	atom := name != ""
+	for i := 0; i < len(name); i++ {
+
+		if (name[i] <= 0x20) || (name[i] > 0x7e) || strings.ContainsRune("\"\\(){}%*]", rune(name[i])) {
+			atom = false
+		}
+	}
+
+	if atom {
+		return name
+	}
+
+	return fmt.Sprintf("\"%s\"", strings.NewReplacer("\\", "\\\\", "\"", "\\\"").Replace(name))
+}
+
+// init registers the UTF8=ACCEPT capability.
+func init() {
+	RegisterCapability(&Capability{Name: "UTF8=ACCEPT", PostAuth: true})
+}
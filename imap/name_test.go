@@ -0,0 +1,123 @@
+package imap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Variables
+
+var mutf7Tests = []struct {
+	name    string
+	encoded string
+}{
+	{"INBOX", "INBOX"},
+	{"Tom & Jerry", "Tom &- Jerry"},
+	{"Entwürfe", "Entw&APw-rfe"},
+	{"~peter/mail/台北/日本語", "~peter/mail/&U,BTFw-/&ZeVnLIqe-"},
+	{"Gesendete Objekte/€", "Gesendete Objekte/&IKw-"},
+	{"😀", "&2D3eAA-"},
+	{"", ""},
+}
+
+var invalidMUTF7Tests = []string{
+	"Entwürfe",
+	"&U,BTFw",
+	"&AGE-",
+	"&U,BTF-",
+	"&2D0-",
+	"&U/BTFw-",
+	"a\tb",
+}
+
+var maildirFolderTests = []struct {
+	folder string
+	dir    string
+}{
+	{"INBOX", ""},
+	{"Sent", ".Sent"},
+	{"Work.Projects", ".Work.Projects"},
+	{"..", ".%2E."},
+	{"../../etc", ".%2E.%2F..%2Fetc"},
+	{"Reports/2017", ".Reports%2F2017"},
+	{"100% *done*", ".100%25 %2Adone%2A"},
+	{"Entwürfe", ".Entw&APw-rfe"},
+}
+
+// Functions
+
+// TestMUTF7 executes a white-box table test on
+// implemented EncodeMUTF7() and DecodeMUTF7().
+func TestMUTF7(t *testing.T) {
+
+	for _, test := range mutf7Tests {
+
+		encoded := EncodeMUTF7(test.name)
+		assert.Equalf(t, test.encoded, encoded, "unexpected encoding of %q", test.name)
+
+		name, err := DecodeMUTF7(test.encoded)
+		assert.Nilf(t, err, "expected DecodeMUTF7(%q) not to fail but got: %v", test.encoded, err)
+		assert.Equalf(t, test.name, name, "unexpected decoding of %q", test.encoded)
+	}
+
+	for _, encoded := range invalidMUTF7Tests {
+
+		_, err := DecodeMUTF7(encoded)
+		assert.NotNilf(t, err, "expected DecodeMUTF7(%q) to fail", encoded)
+	}
+}
+
+// TestMaildirFolder executes a white-box table test
+// on implemented MaildirFolder() and FolderFromMaildir().
+func TestMaildirFolder(t *testing.T) {
+
+	for _, test := range maildirFolderTests {
+
+		dir := MaildirFolder(test.folder)
+		assert.Equalf(t, test.dir, dir, "unexpected Maildir++ folder for %q", test.folder)
+
+		folder, err := FolderFromMaildir(dir)
+		assert.Nilf(t, err, "expected FolderFromMaildir(%q) not to fail but got: %v", dir, err)
+		assert.Equalf(t, test.folder, folder, "unexpected folder for %q", dir)
+	}
+
+	_, err := FolderFromMaildir("Sent")
+	assert.NotNilf(t, err, "expected FolderFromMaildir() to fail on missing '.'")
+
+	_, err = FolderFromMaildir(".Sent%2")
+	assert.NotNilf(t, err, "expected FolderFromMaildir() to fail on truncated escape")
+}
+
+// FuzzMaildirFolder checks that every valid folder name
+// maps to a single path element below the Maildir and
+// that the mapping can be reversed.
+func FuzzMaildirFolder(f *testing.F) {
+
+	for _, test := range maildirFolderTests {
+		f.Add(test.folder)
+	}
+
+	f.Fuzz(func(t *testing.T, folder string) {
+
+		mailbox := &Mailbox{
+			MaildirPath:        "/var/mail/user",
+			HierarchySeparator: ".",
+		}
+
+		if (folder == "INBOX") || !mailbox.validFolderName(folder) {
+			return
+		}
+
+		dir := MaildirFolder(folder)
+		if (dir == ".") || (dir == "..") || strings.ContainsAny(dir, "/\\") {
+			t.Errorf("folder %q mapped to unsafe directory %q", folder, dir)
+		}
+
+		reversed, err := FolderFromMaildir(dir)
+		if (err != nil) || (reversed != folder) {
+			t.Errorf("folder %q mapped to %q which reversed to %q (%v)", folder, dir, reversed, err)
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package imap
+
+import (
+	"fmt"
+
+	"github.com/go-pluto/pluto/comm"
+)
+
+// Functions
+
+// Namespace returns the namespaces of the user's mailbox
+// as defined in RFC 2342. All folders of a user reside in
+// one personal namespace without prefix. There are no
+// other users' or shared namespaces.
+func (mailbox *Mailbox) Namespace(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+
+	if (s.State != StateAuthenticated) && (s.State != StateMailbox) {
+
+		// If connection was not in correct state when this
+		// command was executed, this is a client error.
+		// Send tagged BAD response.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command NAMESPACE cannot be executed in this state", req.Tag),
+		}, nil
+	}
+
+	if len(req.Args) > 0 {
+
+		// If payload was not empty to NAMESPACE command,
+		// this is a client error. Return BAD statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command NAMESPACE was sent with extra parameters", req.Tag),
+		}, nil
+	}
+
+	return &Reply{
+		Text: fmt.Sprintf("* NAMESPACE ((\"\" \"%s\")) NIL NIL\r\n%s OK NAMESPACE completed", mailbox.HierarchySeparator, req.Tag),
+	}, nil
+}
+
+// init registers the NAMESPACE capability.
+func init() {
+	RegisterCapability(&Capability{Name: "NAMESPACE", PostAuth: true, Commands: []string{CommandNamespace}})
+}
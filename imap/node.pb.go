@@ -169,6 +169,7 @@ func (m *Await) GetNumBytes() uint32 {
 type MailFile struct {
 	Content  []byte `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"`
 	ClientID string `protobuf:"bytes,2,opt,name=clientID" json:"clientID,omitempty"`
+	Text     string `protobuf:"bytes,3,opt,name=text" json:"text,omitempty"`
 }
 
 func (m *MailFile) Reset()                    { *m = MailFile{} }
@@ -190,6 +191,13 @@ func (m *MailFile) GetClientID() string {
 	return ""
 }
 
+func (m *MailFile) GetText() string {
+	if m != nil {
+		return m.Text
+	}
+	return ""
+}
+
 type Abort struct {
 	ClientID string `protobuf:"bytes,1,opt,name=clientID" json:"clientID,omitempty"`
 }
@@ -238,6 +246,27 @@ type NodeClient interface {
 	AppendAbort(ctx context.Context, in *Abort, opts ...grpc.CallOption) (*Confirmation, error)
 	Expunge(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error)
 	Store(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error)
+	Fetch(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error)
+	Search(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error)
+	UID(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error)
+	Copy(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error)
+	Move(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error)
+	Rename(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error)
+	Examine(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error)
+	Status(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error)
+	CloseMailbox(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error)
+	Unselect(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error)
+	Check(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error)
+	Noop(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error)
+	Idle(ctx context.Context, in *Command, opts ...grpc.CallOption) (Node_IdleClient, error)
+	Subscribe(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error)
+	Unsubscribe(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error)
+	Lsub(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error)
+	Enable(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error)
+	AppendNext(ctx context.Context, in *MailFile, opts ...grpc.CallOption) (*Await, error)
+	Namespace(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error)
+	Sort(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error)
+	Thread(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error)
 }
 
 type nodeClient struct {
@@ -347,6 +376,218 @@ func (c *nodeClient) Store(ctx context.Context, in *Command, opts ...grpc.CallOp
 	return out, nil
 }
 
+func (c *nodeClient) Fetch(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error) {
+	out := new(Reply)
+	err := grpc.Invoke(ctx, "/imap.Node/Fetch", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) Search(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error) {
+	out := new(Reply)
+	err := grpc.Invoke(ctx, "/imap.Node/Search", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) UID(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error) {
+	out := new(Reply)
+	err := grpc.Invoke(ctx, "/imap.Node/UID", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) Copy(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error) {
+	out := new(Reply)
+	err := grpc.Invoke(ctx, "/imap.Node/Copy", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) Move(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error) {
+	out := new(Reply)
+	err := grpc.Invoke(ctx, "/imap.Node/Move", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) Rename(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error) {
+	out := new(Reply)
+	err := grpc.Invoke(ctx, "/imap.Node/Rename", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) Examine(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error) {
+	out := new(Reply)
+	err := grpc.Invoke(ctx, "/imap.Node/Examine", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) Status(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error) {
+	out := new(Reply)
+	err := grpc.Invoke(ctx, "/imap.Node/Status", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) CloseMailbox(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error) {
+	out := new(Reply)
+	err := grpc.Invoke(ctx, "/imap.Node/CloseMailbox", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) Unselect(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error) {
+	out := new(Reply)
+	err := grpc.Invoke(ctx, "/imap.Node/Unselect", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) Check(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error) {
+	out := new(Reply)
+	err := grpc.Invoke(ctx, "/imap.Node/Check", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) Noop(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error) {
+	out := new(Reply)
+	err := grpc.Invoke(ctx, "/imap.Node/Noop", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) Idle(ctx context.Context, in *Command, opts ...grpc.CallOption) (Node_IdleClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Node_serviceDesc.Streams[0], c.cc, "/imap.Node/Idle", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &nodeIdleClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Node_IdleClient interface {
+	Recv() (*Reply, error)
+	grpc.ClientStream
+}
+
+type nodeIdleClient struct {
+	grpc.ClientStream
+}
+
+func (x *nodeIdleClient) Recv() (*Reply, error) {
+	m := new(Reply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *nodeClient) Subscribe(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error) {
+	out := new(Reply)
+	err := grpc.Invoke(ctx, "/imap.Node/Subscribe", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) Unsubscribe(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error) {
+	out := new(Reply)
+	err := grpc.Invoke(ctx, "/imap.Node/Unsubscribe", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) Lsub(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error) {
+	out := new(Reply)
+	err := grpc.Invoke(ctx, "/imap.Node/Lsub", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) Enable(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error) {
+	out := new(Reply)
+	err := grpc.Invoke(ctx, "/imap.Node/Enable", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) AppendNext(ctx context.Context, in *MailFile, opts ...grpc.CallOption) (*Await, error) {
+	out := new(Await)
+	err := grpc.Invoke(ctx, "/imap.Node/AppendNext", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) Namespace(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error) {
+	out := new(Reply)
+	err := grpc.Invoke(ctx, "/imap.Node/Namespace", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) Sort(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error) {
+	out := new(Reply)
+	err := grpc.Invoke(ctx, "/imap.Node/Sort", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) Thread(ctx context.Context, in *Command, opts ...grpc.CallOption) (*Reply, error) {
+	out := new(Reply)
+	err := grpc.Invoke(ctx, "/imap.Node/Thread", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Node service
 
 type NodeServer interface {
@@ -361,6 +602,27 @@ type NodeServer interface {
 	AppendAbort(context.Context, *Abort) (*Confirmation, error)
 	Expunge(context.Context, *Command) (*Reply, error)
 	Store(context.Context, *Command) (*Reply, error)
+	Fetch(context.Context, *Command) (*Reply, error)
+	Search(context.Context, *Command) (*Reply, error)
+	UID(context.Context, *Command) (*Reply, error)
+	Copy(context.Context, *Command) (*Reply, error)
+	Move(context.Context, *Command) (*Reply, error)
+	Rename(context.Context, *Command) (*Reply, error)
+	Examine(context.Context, *Command) (*Reply, error)
+	Status(context.Context, *Command) (*Reply, error)
+	CloseMailbox(context.Context, *Command) (*Reply, error)
+	Unselect(context.Context, *Command) (*Reply, error)
+	Check(context.Context, *Command) (*Reply, error)
+	Noop(context.Context, *Command) (*Reply, error)
+	Idle(*Command, Node_IdleServer) error
+	Subscribe(context.Context, *Command) (*Reply, error)
+	Unsubscribe(context.Context, *Command) (*Reply, error)
+	Lsub(context.Context, *Command) (*Reply, error)
+	Enable(context.Context, *Command) (*Reply, error)
+	AppendNext(context.Context, *MailFile) (*Await, error)
+	Namespace(context.Context, *Command) (*Reply, error)
+	Sort(context.Context, *Command) (*Reply, error)
+	Thread(context.Context, *Command) (*Reply, error)
 }
 
 func RegisterNodeServer(s *grpc.Server, srv NodeServer) {
@@ -565,6 +827,387 @@ func _Node_Store_Handler(srv interface{}, ctx context.Context, dec func(interfac
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Node_Fetch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Command)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Fetch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/imap.Node/Fetch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Fetch(ctx, req.(*Command))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Command)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/imap.Node/Search",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Search(ctx, req.(*Command))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_UID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Command)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).UID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/imap.Node/UID",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).UID(ctx, req.(*Command))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_Copy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Command)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Copy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/imap.Node/Copy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Copy(ctx, req.(*Command))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_Move_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Command)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Move(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/imap.Node/Move",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Move(ctx, req.(*Command))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_Rename_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Command)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Rename(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/imap.Node/Rename",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Rename(ctx, req.(*Command))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_Examine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Command)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Examine(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/imap.Node/Examine",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Examine(ctx, req.(*Command))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Command)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/imap.Node/Status",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Status(ctx, req.(*Command))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_CloseMailbox_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Command)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).CloseMailbox(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/imap.Node/CloseMailbox",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).CloseMailbox(ctx, req.(*Command))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_Unselect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Command)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Unselect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/imap.Node/Unselect",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Unselect(ctx, req.(*Command))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_Check_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Command)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Check(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/imap.Node/Check",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Check(ctx, req.(*Command))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_Noop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Command)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Noop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/imap.Node/Noop",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Noop(ctx, req.(*Command))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_Idle_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Command)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NodeServer).Idle(m, &nodeIdleServer{stream})
+}
+
+type Node_IdleServer interface {
+	Send(*Reply) error
+	grpc.ServerStream
+}
+
+type nodeIdleServer struct {
+	grpc.ServerStream
+}
+
+func (x *nodeIdleServer) Send(m *Reply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Node_Subscribe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Command)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Subscribe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/imap.Node/Subscribe",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Subscribe(ctx, req.(*Command))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_Unsubscribe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Command)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Unsubscribe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/imap.Node/Unsubscribe",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Unsubscribe(ctx, req.(*Command))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_Lsub_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Command)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Lsub(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/imap.Node/Lsub",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Lsub(ctx, req.(*Command))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_Enable_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Command)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Enable(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/imap.Node/Enable",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Enable(ctx, req.(*Command))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_AppendNext_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MailFile)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).AppendNext(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/imap.Node/AppendNext",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).AppendNext(ctx, req.(*MailFile))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_Namespace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Command)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Namespace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/imap.Node/Namespace",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Namespace(ctx, req.(*Command))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_Sort_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Command)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Sort(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/imap.Node/Sort",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Sort(ctx, req.(*Command))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_Thread_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Command)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Thread(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/imap.Node/Thread",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Thread(ctx, req.(*Command))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Node_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "imap.Node",
 	HandlerType: (*NodeServer)(nil),
@@ -613,38 +1256,130 @@ var _Node_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Store",
 			Handler:    _Node_Store_Handler,
 		},
+		{
+			MethodName: "Fetch",
+			Handler:    _Node_Fetch_Handler,
+		},
+		{
+			MethodName: "Search",
+			Handler:    _Node_Search_Handler,
+		},
+		{
+			MethodName: "UID",
+			Handler:    _Node_UID_Handler,
+		},
+		{
+			MethodName: "Copy",
+			Handler:    _Node_Copy_Handler,
+		},
+		{
+			MethodName: "Move",
+			Handler:    _Node_Move_Handler,
+		},
+		{
+			MethodName: "Rename",
+			Handler:    _Node_Rename_Handler,
+		},
+		{
+			MethodName: "Examine",
+			Handler:    _Node_Examine_Handler,
+		},
+		{
+			MethodName: "Status",
+			Handler:    _Node_Status_Handler,
+		},
+		{
+			MethodName: "CloseMailbox",
+			Handler:    _Node_CloseMailbox_Handler,
+		},
+		{
+			MethodName: "Unselect",
+			Handler:    _Node_Unselect_Handler,
+		},
+		{
+			MethodName: "Check",
+			Handler:    _Node_Check_Handler,
+		},
+		{
+			MethodName: "Noop",
+			Handler:    _Node_Noop_Handler,
+		},
+		{
+			MethodName: "Subscribe",
+			Handler:    _Node_Subscribe_Handler,
+		},
+		{
+			MethodName: "Unsubscribe",
+			Handler:    _Node_Unsubscribe_Handler,
+		},
+		{
+			MethodName: "Lsub",
+			Handler:    _Node_Lsub_Handler,
+		},
+		{
+			MethodName: "Enable",
+			Handler:    _Node_Enable_Handler,
+		},
+		{
+			MethodName: "AppendNext",
+			Handler:    _Node_AppendNext_Handler,
+		},
+		{
+			MethodName: "Namespace",
+			Handler:    _Node_Namespace_Handler,
+		},
+		{
+			MethodName: "Sort",
+			Handler:    _Node_Sort_Handler,
+		},
+		{
+			MethodName: "Thread",
+			Handler:    _Node_Thread_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Idle",
+			Handler:       _Node_Idle_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "node.proto",
 }
 
 func init() { proto.RegisterFile("node.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 390 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x93, 0x4f, 0x6f, 0xda, 0x40,
-	0x10, 0xc5, 0xf9, 0x63, 0x63, 0x18, 0xa0, 0x87, 0x3d, 0x54, 0x16, 0x87, 0xaa, 0x72, 0x5b, 0x5a,
-	0xb5, 0x95, 0x55, 0x95, 0x53, 0x6f, 0x05, 0x43, 0xa4, 0x48, 0x09, 0x89, 0xcc, 0x21, 0xe7, 0x05,
-	0x4f, 0xd0, 0x2a, 0xf6, 0xee, 0x6a, 0xbd, 0x56, 0xe0, 0xc3, 0xe6, 0xbb, 0x44, 0xb6, 0xb1, 0x09,
-	0x49, 0xc0, 0xc9, 0xf1, 0x79, 0x7e, 0x3b, 0xf3, 0x66, 0x9e, 0x0c, 0xc0, 0x45, 0x80, 0xae, 0x54,
-	0x42, 0x0b, 0x62, 0xb0, 0x88, 0x4a, 0x87, 0x82, 0xe5, 0x09, 0xae, 0x71, 0xa3, 0xc9, 0x00, 0xda,
-	0xab, 0x90, 0x21, 0xd7, 0xe7, 0x53, 0xbb, 0xfe, 0xb9, 0xfe, 0xa3, 0xe3, 0x97, 0x3a, 0xad, 0x25,
-	0x31, 0xaa, 0x39, 0x8d, 0xd0, 0x6e, 0xe4, 0xb5, 0x42, 0x93, 0x4f, 0x00, 0x0a, 0x63, 0x79, 0x23,
-	0xd4, 0x1d, 0x2a, 0xbb, 0x99, 0x55, 0x9f, 0x7c, 0x71, 0x86, 0xd0, 0xf3, 0x04, 0xbf, 0x65, 0x2a,
-	0xa2, 0x9a, 0x09, 0x4e, 0x3e, 0x42, 0x2b, 0xd6, 0x54, 0x27, 0x71, 0x36, 0xa5, 0xef, 0xef, 0x94,
-	0xf3, 0x2f, 0xb5, 0x12, 0x45, 0x94, 0x07, 0x84, 0x80, 0x91, 0x5a, 0xda, 0xd9, 0x30, 0x5e, 0xd8,
-	0x6b, 0x1c, 0xda, 0x73, 0x46, 0x60, 0xfa, 0x28, 0xc3, 0xed, 0xab, 0x0f, 0xf7, 0xf3, 0x1a, 0x07,
-	0xf3, 0xae, 0xc0, 0x1c, 0xdf, 0x53, 0xa6, 0xdf, 0xf3, 0x28, 0x75, 0xc1, 0x93, 0x68, 0xb2, 0xd5,
-	0x18, 0x67, 0xab, 0xf6, 0xfd, 0x52, 0x3b, 0xff, 0xa1, 0x7d, 0x49, 0x59, 0x78, 0xc6, 0x42, 0x24,
-	0x36, 0x58, 0xab, 0xf4, 0xae, 0x3c, 0x6f, 0xdb, 0xf3, 0x0b, 0x79, 0x72, 0x8f, 0x2f, 0x60, 0x8e,
-	0x97, 0x42, 0x9d, 0xcc, 0xe2, 0xef, 0x43, 0x13, 0x8c, 0xb9, 0x08, 0x90, 0xb8, 0x60, 0x5d, 0x2b,
-	0x94, 0x54, 0x21, 0xe9, 0xbb, 0x69, 0x9a, 0xee, 0x2e, 0xca, 0x01, 0x29, 0x65, 0x79, 0x76, 0xa7,
-	0x46, 0x7e, 0x83, 0xe9, 0x85, 0x22, 0x7e, 0x23, 0x3d, 0x84, 0xd6, 0x02, 0x43, 0x5c, 0xe9, 0x3d,
-	0x9e, 0x85, 0x33, 0xe8, 0xe6, 0x32, 0x3b, 0x78, 0xce, 0x79, 0x0a, 0xa9, 0xc6, 0x6a, 0x6e, 0x8a,
-	0x21, 0x56, 0x72, 0x5f, 0xc1, 0xb8, 0x60, 0x71, 0xd5, 0xd4, 0x5f, 0xd0, 0x1d, 0x4b, 0x89, 0x3c,
-	0x98, 0xe0, 0x9a, 0xf1, 0x23, 0x70, 0x16, 0xaf, 0x53, 0x23, 0x3f, 0xa1, 0x93, 0xc3, 0x33, 0x1e,
-	0x90, 0x0f, 0x79, 0xad, 0x48, 0xea, 0x79, 0xe3, 0x3f, 0x45, 0xe3, 0x3c, 0x88, 0xa2, 0x53, 0x2a,
-	0x8e, 0x1c, 0xea, 0x3b, 0x58, 0xb3, 0x8d, 0x4c, 0xf8, 0xba, 0x6a, 0xb3, 0x6f, 0x60, 0x2e, 0xb4,
-	0x50, 0x15, 0xd8, 0xb2, 0x95, 0xfd, 0x9f, 0xa3, 0xc7, 0x00, 0x00, 0x00, 0xff, 0xff, 0xc9, 0x0b,
-	0xdf, 0x36, 0xad, 0x03, 0x00, 0x00,
+	// 495 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x95, 0x4f, 0x6f, 0xd3, 0x30,
+	0x18, 0xc6, 0xd7, 0x2e, 0xe9, 0x9f, 0x77, 0x2d, 0x07, 0x1f, 0x50, 0xd5, 0x03, 0x42, 0x19, 0x1b,
+	0x03, 0xa6, 0x6a, 0x62, 0x27, 0x6e, 0x74, 0xe9, 0x26, 0x55, 0x62, 0x05, 0x25, 0xaa, 0x38, 0x3b,
+	0xc9, 0xcb, 0x66, 0x2d, 0xb1, 0x2d, 0xdb, 0x81, 0xf6, 0xe3, 0xf2, 0x4d, 0x50, 0x92, 0xa6, 0x63,
+	0x40, 0xeb, 0x70, 0x7c, 0xea, 0x5f, 0xde, 0x3f, 0xcf, 0x63, 0xab, 0x00, 0x5c, 0x24, 0x38, 0x91,
+	0x4a, 0x18, 0x41, 0x1c, 0x96, 0x51, 0xe9, 0x51, 0xe8, 0xfa, 0x82, 0x1b, 0x5c, 0x19, 0x32, 0x86,
+	0x5e, 0x9c, 0x32, 0xe4, 0x66, 0x3e, 0x1b, 0xb5, 0x5e, 0xb6, 0xce, 0xfa, 0xc1, 0x56, 0x17, 0x67,
+	0xb9, 0x46, 0xb5, 0xa0, 0x19, 0x8e, 0xda, 0xd5, 0x59, 0xad, 0xc9, 0x0b, 0x00, 0x85, 0x5a, 0x7e,
+	0x15, 0xea, 0x01, 0xd5, 0xe8, 0xb0, 0x3c, 0xfd, 0xed, 0x17, 0xef, 0x14, 0x06, 0xbe, 0xe0, 0xdf,
+	0x98, 0xca, 0xa8, 0x61, 0x82, 0x93, 0xe7, 0xd0, 0xd1, 0x86, 0x9a, 0x5c, 0x97, 0x5d, 0x86, 0xc1,
+	0x46, 0x79, 0x1f, 0x8a, 0x51, 0xb2, 0x8c, 0xf2, 0x84, 0x10, 0x70, 0x8a, 0x91, 0x36, 0x63, 0x38,
+	0x7f, 0x8d, 0xd7, 0x7e, 0x3a, 0x9e, 0x77, 0x09, 0x6e, 0x80, 0x32, 0x5d, 0xff, 0xf3, 0xc3, 0xc7,
+	0x7e, 0xed, 0x27, 0xfd, 0x3e, 0x83, 0x3b, 0xfd, 0x41, 0x99, 0xf9, 0x9f, 0x8f, 0x8a, 0x29, 0x78,
+	0x9e, 0x5d, 0xad, 0x0d, 0xea, 0x72, 0xd5, 0x61, 0xb0, 0xd5, 0xde, 0x47, 0xe8, 0xdd, 0x52, 0x96,
+	0xde, 0xb0, 0x14, 0xc9, 0x08, 0xba, 0x71, 0xe1, 0x2b, 0xaf, 0xca, 0x0e, 0x82, 0x5a, 0xee, 0xdd,
+	0xe3, 0x18, 0xdc, 0x69, 0x24, 0xd4, 0xde, 0x2c, 0xde, 0xff, 0xec, 0x81, 0xb3, 0x10, 0x09, 0x92,
+	0x09, 0x74, 0xbf, 0x28, 0x94, 0x54, 0x21, 0x19, 0x4e, 0x8a, 0x34, 0x27, 0x9b, 0x28, 0xc7, 0x64,
+	0x2b, 0xb7, 0xb6, 0x7b, 0x07, 0xe4, 0x1c, 0x5c, 0x3f, 0x15, 0xba, 0x21, 0x7d, 0x0a, 0x9d, 0x10,
+	0x53, 0x8c, 0xcd, 0x23, 0x5e, 0x86, 0x33, 0x3e, 0xaa, 0x64, 0x69, 0x78, 0xc5, 0xf9, 0x0a, 0xa9,
+	0x41, 0x3b, 0x37, 0xc3, 0x14, 0xad, 0xdc, 0x2b, 0x70, 0x3e, 0x31, 0x6d, 0xeb, 0xfa, 0x0e, 0x8e,
+	0xa6, 0x52, 0x22, 0x4f, 0xae, 0xf0, 0x8e, 0xf1, 0x1d, 0x70, 0x19, 0xaf, 0x77, 0x40, 0xde, 0x42,
+	0xbf, 0x82, 0xaf, 0x79, 0x42, 0x9e, 0x55, 0x67, 0x75, 0x52, 0x7f, 0x16, 0xbe, 0xa8, 0x0b, 0x57,
+	0x41, 0xd4, 0x95, 0x0a, 0xb1, 0xc3, 0xa8, 0xd7, 0xd0, 0xbd, 0x5e, 0xc9, 0x9c, 0xdf, 0xd9, 0x36,
+	0x3b, 0x01, 0x37, 0x34, 0x42, 0x35, 0xc0, 0x6e, 0xd0, 0xc4, 0xf7, 0x76, 0x3f, 0x43, 0xa4, 0xca,
+	0xca, 0x1d, 0xc3, 0xe1, 0x72, 0x3e, 0xb3, 0x9b, 0xee, 0x0b, 0xb9, 0xb6, 0x53, 0xb7, 0xe2, 0x7b,
+	0x83, 0xa0, 0x03, 0xe4, 0x34, 0xb3, 0x71, 0xa5, 0x6f, 0x34, 0x63, 0xbc, 0x41, 0xc1, 0xb0, 0x7a,
+	0x7d, 0xfb, 0xb9, 0x73, 0x18, 0x94, 0xf7, 0xbb, 0x88, 0x36, 0x12, 0x2b, 0x0b, 0x7d, 0x06, 0xbd,
+	0x25, 0xd7, 0x4d, 0x6e, 0xf8, 0x09, 0xb8, 0xfe, 0x3d, 0xc6, 0x0f, 0x76, 0x77, 0x16, 0x42, 0x48,
+	0xeb, 0x32, 0xce, 0x3c, 0x49, 0x2d, 0x2b, 0x5f, 0xb4, 0xc8, 0x1b, 0xe8, 0x87, 0x79, 0xa4, 0x63,
+	0xc5, 0x22, 0xb4, 0xbf, 0x85, 0x25, 0xd7, 0x0d, 0xe1, 0xe2, 0x79, 0xe9, 0x3c, 0xda, 0x4f, 0x45,
+	0x9d, 0xf2, 0x3f, 0xe2, 0xf2, 0xd7, 0x00, 0x5e, 0x84, 0x3f, 0x5d, 0x31, 0x06, 0x00, 0x00,
 }
@@ -0,0 +1,583 @@
+package imap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Constants
+
+const (
+	// NodeAtom denotes an atom, e.g. a command
+	// argument such as FLAGS, NIL, or \Seen.
+	NodeAtom NodeType = iota
+	// NodeQuoted denotes a quoted string.
+	NodeQuoted
+	// NodeLiteral denotes a string sent as literal.
+	NodeLiteral
+	// NodeList denotes a parenthesized list.
+	NodeList
+)
+
+// MaxLiteralSize is the largest literal in bytes that
+// is accepted as part of a command. The message literal
+// of APPEND is not read as part of the command and thus
+// not restricted by it, while the text parts of CATENATE
+// (RFC 4469) are.
+const MaxLiteralSize = 1 << 20
+
+// Structs
+
+// NodeType distinguishes the different kinds
+// of arguments an IMAP command is made of.
+type NodeType int
+
+// Node is one argument of a parsed IMAP command. Atoms,
+// quoted strings, and literals carry their value, lists
+// carry their items. A literal at the very end of the
+// command whose data was not sent yet, as the message
+// of APPEND, is marked as pending and only carries the
+// announced size.
+type Node struct {
+	Type    NodeType
+	Value   string
+	Items   []*Node
+	Size    int
+	NonSync bool
+	Pending bool
+}
+
+// SeqRange is one element of a sequence set. A single
+// number is a range with equal start and end. Zero
+// stands for '*', the largest number in use.
+type SeqRange struct {
+	Start uint32
+	End   uint32
+}
+
+// LiteralError signals that a client announced a
+// synchronizing literal pluto refuses to accept.
+// As the client waits for a continuation request
+// before sending the literal, the connection is
+// still in sync and can carry on.
+type LiteralError struct {
+	Tag  string
+	Size int64
+}
+
+// argParser keeps track of the position while
+// parsing the arguments of a command.
+type argParser struct {
+	payload string
+	pos     int
+}
+
+// Functions
+
+// Error returns the tagged BAD response to
+// send to the client for a refused literal.
+func (e *LiteralError) Error() string {
+	return fmt.Sprintf("%s BAD Literal of %d bytes exceeds maximum size of %d bytes", e.Tag, e.Size, MaxLiteralSize)
+}
+
+// IsList returns true if node is a parenthesized list.
+func (n *Node) IsList() bool {
+	return n.Type == NodeList
+}
+
+// IsNIL returns true if node is the atom NIL.
+func (n *Node) IsNIL() bool {
+	return (n.Type == NodeAtom) && strings.EqualFold(n.Value, "NIL")
+}
+
+// AString returns the value of node if it may be used
+// as an astring, i.e. if it is an atom, a quoted string,
+// or a literal whose data was received.
+func (n *Node) AString() (string, bool) {
+
+	if (n.Type == NodeList) || n.Pending {
+		return "", false
+	}
+
+	return n.Value, true
+}
+
+// ParseArgs parses the payload of a command into its
+// arguments according to the grammar of RFC 3501. Atoms
+// may contain a bracketed section, as in BODY[1.MIME]
+// or BODY[HEADER.FIELDS (From To)]<0.512>, which is kept
+// as part of the atom. Literals are expected to be
+// followed by their data, except for a literal that
+// ends the payload, which is returned as pending.
+func ParseArgs(payload string) ([]*Node, error) {
+
+	p := &argParser{
+		payload: payload,
+	}
+
+	args, err := p.parseItems(false)
+	if err != nil {
+		return nil, err
+	}
+
+	return args, nil
+}
+
+// parseItems parses arguments until the end of the
+// payload or, inside a list, until its closing
+// parenthesis, which is consumed.
+func (p *argParser) parseItems(inList bool) ([]*Node, error) {
+
+	items := make([]*Node, 0, 4)
+
+	for {
+
+		// Skip separating space characters.
+		for (p.pos < len(p.payload)) && (p.payload[p.pos] == ' ') {
+			p.pos++
+		}
+
+		if p.pos >= len(p.payload) {
+
+			if inList {
+				return nil, fmt.Errorf("unbalanced parentheses")
+			}
+
+			return items, nil
+		}
+
+		var item *Node
+		var err error
+
+		switch p.payload[p.pos] {
+
+		case ')':
+
+			if !inList {
+				return nil, fmt.Errorf("unbalanced parentheses")
+			}
+
+			p.pos++
+
+			return items, nil
+
+		case '(':
+
+			p.pos++
+
+			list, err := p.parseItems(true)
+			if err != nil {
+				return nil, err
+			}
+
+			item = &Node{
+				Type:  NodeList,
+				Items: list,
+			}
+
+		case '"':
+			item, err = p.parseQuoted()
+
+		case '{':
+			item, err = p.parseLiteral()
+
+		default:
+			item, err = p.parseAtom()
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if item.Pending && inList {
+			return nil, fmt.Errorf("literal data missing")
+		}
+
+		// Arguments need to be separated.
+		if (p.pos < len(p.payload)) && (p.payload[p.pos] != ' ') && (p.payload[p.pos] != ')') {
+			return nil, fmt.Errorf("missing space after argument")
+		}
+
+		items = append(items, item)
+	}
+}
+
+// parseQuoted parses a quoted string. Only double
+// quotes and backslashes may be escaped, CR and LF
+// must not be contained.
+func (p *argParser) parseQuoted() (*Node, error) {
+
+	value := make([]byte, 0, 16)
+
+	for p.pos++; p.pos < len(p.payload); p.pos++ {
+
+		c := p.payload[p.pos]
+
+		switch c {
+
+		case '"':
+
+			p.pos++
+
+			return &Node{
+				Type:  NodeQuoted,
+				Value: string(value),
+			}, nil
+
+		case '\\':
+
+			p.pos++
+
+			if (p.pos >= len(p.payload)) || ((p.payload[p.pos] != '\\') && (p.payload[p.pos] != '"')) {
+				return nil, fmt.Errorf("invalid escape in quoted string")
+			}
+
+			value = append(value, p.payload[p.pos])
+
+		case '\r', '\n', 0:
+			return nil, fmt.Errorf("invalid character in quoted string")
+
+		default:
+			value = append(value, c)
+		}
+	}
+
+	return nil, fmt.Errorf("unterminated quoted string")
+}
+
+// parseLiteral parses a synchronizing literal {n} or
+// non-synchronizing literal {n+} (RFC 7888) including
+// its data following the CRLF.
+func (p *argParser) parseLiteral() (*Node, error) {
+
+	end := strings.IndexByte(p.payload[p.pos:], '}')
+	if end < 0 {
+		return nil, fmt.Errorf("invalid literal")
+	}
+	end += p.pos
+
+	item := &Node{
+		Type: NodeLiteral,
+	}
+
+	sizeRaw := p.payload[(p.pos + 1):end]
+	if strings.HasSuffix(sizeRaw, "+") {
+		item.NonSync = true
+		sizeRaw = strings.TrimSuffix(sizeRaw, "+")
+	}
+
+	size, err := parseNumber(sizeRaw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid literal size")
+	}
+	item.Size = int(size)
+
+	p.pos = end + 1
+
+	if p.pos == len(p.payload) {
+
+		// Data of a literal ending the command
+		// is received separately.
+		item.Pending = true
+
+		return item, nil
+	}
+
+	if !strings.HasPrefix(p.payload[p.pos:], "\r\n") {
+		return nil, fmt.Errorf("literal size not followed by CRLF")
+	}
+	p.pos += 2
+
+	if (len(p.payload) - p.pos) < item.Size {
+		return nil, fmt.Errorf("literal data shorter than announced")
+	}
+
+	item.Value = p.payload[p.pos:(p.pos + item.Size)]
+	p.pos += item.Size
+
+	return item, nil
+}
+
+// parseAtom parses an atom. A section in brackets
+// directly following the atom's name is included
+// with all of its spaces and parentheses, as is a
+// partial specification in angle brackets.
+func (p *argParser) parseAtom() (*Node, error) {
+
+	start := p.pos
+	depth := 0
+
+	for ; p.pos < len(p.payload); p.pos++ {
+
+		c := p.payload[p.pos]
+
+		if (c < 0x20) || (c == 0x7f) {
+			return nil, fmt.Errorf("invalid character in atom")
+		}
+
+		if depth == 0 {
+
+			if (c == ' ') || (c == '(') || (c == ')') || (c == '"') || (c == '{') {
+				break
+			}
+
+			if c == '[' {
+				depth++
+			}
+
+			continue
+		}
+
+		if c == '[' {
+			depth++
+		} else if c == ']' {
+			depth--
+		}
+	}
+
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced section brackets")
+	}
+
+	if p.pos == start {
+		return nil, fmt.Errorf("invalid character %q", p.payload[p.pos])
+	}
+
+	return &Node{
+		Type:  NodeAtom,
+		Value: p.payload[start:p.pos],
+	}, nil
+}
+
+// parseNumber parses an unsigned 32 bit number
+// consisting of digits only.
+func parseNumber(raw string) (uint32, error) {
+
+	if (raw == "") || (strings.TrimLeft(raw, "0123456789") != "") {
+		return 0, fmt.Errorf("invalid number %q", raw)
+	}
+
+	num, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", raw)
+	}
+
+	return uint32(num), nil
+}
+
+// ParseSeqSet parses a sequence set such as 1,3:5,7:*
+// into its ranges. Numbers need to be greater than zero,
+// '*' is represented by zero in the returned ranges.
+func ParseSeqSet(raw string) ([]SeqRange, error) {
+
+	if raw == "" {
+		return nil, fmt.Errorf("empty sequence set")
+	}
+
+	parseSeqNum := func(num string) (uint32, error) {
+
+		if num == "*" {
+			return 0, nil
+		}
+
+		seqNum, err := parseNumber(num)
+		if (err != nil) || (seqNum == 0) {
+			return 0, fmt.Errorf("invalid sequence number %q", num)
+		}
+
+		return seqNum, nil
+	}
+
+	set := make([]SeqRange, 0, 4)
+
+	for _, element := range strings.Split(raw, ",") {
+
+		bounds := strings.Split(element, ":")
+		if len(bounds) > 2 {
+			return nil, fmt.Errorf("invalid sequence range %q", element)
+		}
+
+		start, err := parseSeqNum(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+
+		end := start
+		if len(bounds) == 2 {
+
+			end, err = parseSeqNum(bounds[1])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		set = append(set, SeqRange{
+			Start: start,
+			End:   end,
+		})
+	}
+
+	return set, nil
+}
+
+// Resolve replaces '*' in the range by max and
+// returns start and end in ascending order.
+func (r SeqRange) Resolve(max uint32) (uint32, uint32) {
+
+	start, end := r.Start, r.End
+
+	if start == 0 {
+		start = max
+	}
+
+	if end == 0 {
+		end = max
+	}
+
+	if end < start {
+		start, end = end, start
+	}
+
+	return start, end
+}
+
+// literalSuffix reports whether line ends in the
+// announcement of a literal and returns its size.
+func literalSuffix(line string) (int64, bool, bool) {
+
+	if !strings.HasSuffix(line, "}") {
+		return 0, false, false
+	}
+
+	open := strings.LastIndexByte(line, '{')
+	if open < 0 {
+		return 0, false, false
+	}
+
+	sizeRaw := line[(open + 1):(len(line) - 1)]
+
+	nonSync := strings.HasSuffix(sizeRaw, "+")
+	if nonSync {
+		sizeRaw = strings.TrimSuffix(sizeRaw, "+")
+	}
+
+	if (sizeRaw == "") || (strings.TrimLeft(sizeRaw, "0123456789") != "") {
+		return 0, false, false
+	}
+
+	size, err := strconv.ParseInt(sizeRaw, 10, 64)
+	if err != nil {
+
+		// Too many digits for any acceptable size.
+		size = (1 << 63) - 1
+	}
+
+	return size, nonSync, true
+}
+
+// isMessageLiteral reports whether the literal that
+// command ends in is the message of an APPEND, which
+// is streamed to the node separately.
+func isMessageLiteral(command string) bool {
+
+	req, err := ParseRequest(command)
+	if (err != nil) || (req.Command != CommandAppend) {
+		return false
+	}
+
+	// The mailbox name may be sent as literal, any
+	// literal following it is the message.
+	return len(req.Args) >= 2
+}
+
+// isNextMessageLiteral reports whether the literal that
+// the rest of a MULTIAPPEND command ends in is the message
+// following the previous one.
+func isNextMessageLiteral(rest string) bool {
+
+	args, err := ParseArgs(strings.TrimPrefix(rest, " "))
+
+	return (err == nil) && (len(args) > 0)
+}
+
+// ReadCommand reads one complete command from r and
+// returns it without the final CRLF. Literals announced
+// at the end of a line are read including their data and
+// the rest of the command following them. Before the data
+// of a synchronizing literal is read, cont is called to
+// send the client a continuation request. The message
+// literal of APPEND is left for the caller to read.
+func ReadCommand(r *bufio.Reader, cont func() error) (string, error) {
+	return readLines(r, cont, isMessageLiteral)
+}
+
+// ReadAppendRest reads the rest of an APPEND command
+// following the data of a message literal. It is empty
+// if the command ends, otherwise it contains the next
+// message of a MULTIAPPEND (RFC 3502) including its
+// leading space. As with ReadCommand, the literal of
+// this message is left for the caller to read.
+func ReadAppendRest(r *bufio.Reader, cont func() error) (string, error) {
+	return readLines(r, cont, isNextMessageLiteral)
+}
+
+// readLines reads lines from r and the data of literals
+// announced at their end until a line is complete or its
+// literal is reported by isMessage to carry a message.
+func readLines(r *bufio.Reader, cont func() error, isMessage func(string) bool) (string, error) {
+
+	command := ""
+
+	for {
+
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		command += line
+
+		// Only the part of the command following the
+		// data of previous literals is inspected.
+		size, nonSync, found := literalSuffix(line)
+		if !found || isMessage(command) {
+			return command, nil
+		}
+
+		if size > MaxLiteralSize {
+
+			if nonSync {
+
+				// The client already sends the data,
+				// there is no way back into sync.
+				return "", fmt.Errorf("non-synchronizing literal of %d bytes exceeds maximum size", size)
+			}
+
+			return "", &LiteralError{
+				Tag:  strings.SplitN(command, " ", 2)[0],
+				Size: size,
+			}
+		}
+
+		if !nonSync {
+
+			err := cont()
+			if err != nil {
+				return "", err
+			}
+		}
+
+		data := make([]byte, size)
+
+		_, err = io.ReadFull(r, data)
+		if err != nil {
+			return "", err
+		}
+
+		command = fmt.Sprintf("%s\r\n%s", command, data)
+	}
+}
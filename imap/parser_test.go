@@ -0,0 +1,296 @@
+package imap
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Variables
+
+var parseArgsTests = []struct {
+	in  string
+	out []*Node
+	err bool
+}{
+	{"", []*Node{}, false},
+	{"INBOX", []*Node{{Type: NodeAtom, Value: "INBOX"}}, false},
+	{"\"My Folder\" \"\"", []*Node{{Type: NodeQuoted, Value: "My Folder"}, {Type: NodeQuoted, Value: ""}}, false},
+	{"\"say \\\"hi\\\" \\\\o/\"", []*Node{{Type: NodeQuoted, Value: "say \"hi\" \\o/"}}, false},
+	{"{5}\r\nsmith {7+}\r\nses ame", []*Node{{Type: NodeLiteral, Value: "smith", Size: 5}, {Type: NodeLiteral, Value: "ses ame", Size: 7, NonSync: true}}, false},
+	{"{0}\r\n", []*Node{{Type: NodeLiteral, Value: ""}}, false},
+	{"saved (\\Seen \\Draft) \"05-Jan-2017 16:00:00 +0100\" {310}", []*Node{
+		{Type: NodeAtom, Value: "saved"},
+		{Type: NodeList, Items: []*Node{{Type: NodeAtom, Value: "\\Seen"}, {Type: NodeAtom, Value: "\\Draft"}}},
+		{Type: NodeQuoted, Value: "05-Jan-2017 16:00:00 +0100"},
+		{Type: NodeLiteral, Size: 310, Pending: true},
+	}, false},
+	{"1:* (FLAGS BODY.PEEK[HEADER.FIELDS (From To)]<0.512>)", []*Node{
+		{Type: NodeAtom, Value: "1:*"},
+		{Type: NodeList, Items: []*Node{{Type: NodeAtom, Value: "FLAGS"}, {Type: NodeAtom, Value: "BODY.PEEK[HEADER.FIELDS (From To)]<0.512>"}}},
+	}, false},
+	{"(()) ()", []*Node{{Type: NodeList, Items: []*Node{{Type: NodeList, Items: []*Node{}}}}, {Type: NodeList, Items: []*Node{}}}, false},
+	{"(a", nil, true},
+	{"a)", nil, true},
+	{"\"unterminated", nil, true},
+	{"\"bad \\escape\"", nil, true},
+	{"\"a\"b", nil, true},
+	{"{5}\r\nabc", nil, true},
+	{"{5} rest", nil, true},
+	{"{x}\r\n", nil, true},
+	{"(a {3})", nil, true},
+	{"BODY[HEADER", nil, true},
+	{"a\rb", nil, true},
+}
+
+var seqSetTests = []struct {
+	in  string
+	out []SeqRange
+	err bool
+}{
+	{"1", []SeqRange{{1, 1}}, false},
+	{"2,4:7,9:*", []SeqRange{{2, 2}, {4, 7}, {9, 0}}, false},
+	{"*:3", []SeqRange{{0, 3}}, false},
+	{"4294967295", []SeqRange{{4294967295, 4294967295}}, false},
+	{"", nil, true},
+	{"0", nil, true},
+	{"1,", nil, true},
+	{"1:2:3", nil, true},
+	{"-1", nil, true},
+	{"+1", nil, true},
+	{"4294967296", nil, true},
+	{"one,two", nil, true},
+}
+
+var readCommandTests = []struct {
+	in    string
+	out   string
+	conts int
+	rest  string
+}{
+	{"a NOOP\r\n", "a NOOP", 0, ""},
+	{"b LOGIN {5}\r\nsmith {6}\r\nsesame\r\n", "b LOGIN {5}\r\nsmith {6}\r\nsesame", 2, ""},
+	{"c LOGIN {5+}\r\nsmith \"sesame\"\r\n", "c LOGIN {5+}\r\nsmith \"sesame\"", 0, ""},
+	{"d SELECT {3}\r\na}{\r\n", "d SELECT {3}\r\na}{", 1, ""},
+	{"e APPEND INBOX (\\Seen) {4}\r\nmail\r\n", "e APPEND INBOX (\\Seen) {4}", 0, "mail\r\n"},
+	{"f APPEND {5}\r\nSaved {4+}\r\nmail\r\n", "f APPEND {5}\r\nSaved {4+}", 1, "mail\r\n"},
+	{"g APPEND Drafts CATENATE (TEXT {5}\r\nhello URL \"/INBOX/;UID=3\")\r\n", "g APPEND Drafts CATENATE (TEXT {5}\r\nhello URL \"/INBOX/;UID=3\")", 1, ""},
+}
+
+var readAppendRestTests = []struct {
+	in    string
+	out   string
+	conts int
+	rest  string
+}{
+	{"\r\n", "", 0, ""},
+	{" (\\Draft) {4}\r\nmail\r\n", " (\\Draft) {4}", 0, "mail\r\n"},
+	{" \"05-Jan-2017 16:00:00 +0100\" {4+}\r\nmail\r\n", " \"05-Jan-2017 16:00:00 +0100\" {4+}", 0, "mail\r\n"},
+	{" CATENATE (TEXT {3}\r\nabc) {4}\r\nmail\r\n", " CATENATE (TEXT {3}\r\nabc) {4}", 1, "mail\r\n"},
+}
+
+// Functions
+
+// TestParseArgs executes a white-box table
+// test on implemented ParseArgs() function.
+func TestParseArgs(t *testing.T) {
+
+	for _, test := range parseArgsTests {
+
+		args, err := ParseArgs(test.in)
+
+		if test.err {
+			assert.NotNilf(t, err, "expected ParseArgs(%q) to fail", test.in)
+			continue
+		}
+
+		assert.Nilf(t, err, "expected ParseArgs(%q) not to fail but got: %v", test.in, err)
+		assert.Equalf(t, test.out, args, "unexpected arguments for %q", test.in)
+	}
+}
+
+// TestParseRequest executes a white-box unit test
+// on arguments and errors of ParseRequest().
+func TestParseRequest(t *testing.T) {
+
+	req, err := ParseRequest("a1 rename \"Old Stuff\" {9+}\r\nNew Stuff")
+	assert.Nilf(t, err, "expected ParseRequest() not to fail but got: %v", err)
+	assert.Equalf(t, CommandRename, req.Command, "expected command RENAME but got %s", req.Command)
+	assert.Equalf(t, 2, len(req.Args), "expected two arguments but got %d", len(req.Args))
+	assert.Equalf(t, "Old Stuff", req.Args[0].Value, "unexpected first argument %q", req.Args[0].Value)
+	assert.Equalf(t, "New Stuff", req.Args[1].Value, "unexpected second argument %q", req.Args[1].Value)
+
+	_, err = ParseRequest("a2 SELECT \"INBOX")
+	assert.NotNilf(t, err, "expected ParseRequest() to fail on unterminated quoted string")
+	assert.Truef(t, strings.HasPrefix(err.Error(), "a2 BAD "), "expected tagged BAD response but got: %v", err)
+}
+
+// TestParseSeqSet executes a white-box table
+// test on implemented ParseSeqSet() function.
+func TestParseSeqSet(t *testing.T) {
+
+	for _, test := range seqSetTests {
+
+		set, err := ParseSeqSet(test.in)
+
+		if test.err {
+			assert.NotNilf(t, err, "expected ParseSeqSet(%q) to fail", test.in)
+			continue
+		}
+
+		assert.Nilf(t, err, "expected ParseSeqSet(%q) not to fail but got: %v", test.in, err)
+		assert.Equalf(t, test.out, set, "unexpected ranges for %q", test.in)
+	}
+
+	msgNums, err := ParseSeqNumbers("4:2,*,1", 5)
+	assert.Nilf(t, err, "expected ParseSeqNumbers() not to fail but got: %v", err)
+	assert.Equalf(t, []int{0, 1, 2, 3, 4}, msgNums, "unexpected sequence numbers %v", msgNums)
+
+	_, err = ParseSeqNumbers("3:6", 5)
+	assert.NotNilf(t, err, "expected ParseSeqNumbers() to fail on non-existing message")
+
+	msgNums, err = ParseUIDSet("*:5,9", []uint32{2, 5, 7, 9})
+	assert.Nilf(t, err, "expected ParseUIDSet() not to fail but got: %v", err)
+	assert.Equalf(t, []int{1, 2, 3}, msgNums, "unexpected message indices %v", msgNums)
+}
+
+// TestReadCommand executes a white-box table
+// test on implemented ReadCommand() function.
+func TestReadCommand(t *testing.T) {
+
+	for _, test := range readCommandTests {
+
+		r := bufio.NewReader(strings.NewReader(test.in))
+		conts := 0
+
+		command, err := ReadCommand(r, func() error {
+			conts++
+			return nil
+		})
+		assert.Nilf(t, err, "expected ReadCommand(%q) not to fail but got: %v", test.in, err)
+		assert.Equalf(t, test.out, command, "unexpected command read from %q", test.in)
+		assert.Equalf(t, test.conts, conts, "expected %d continuation requests for %q but got %d", test.conts, test.in, conts)
+
+		rest := make([]byte, r.Buffered())
+		r.Read(rest)
+		assert.Equalf(t, test.rest, string(rest), "unexpected data left after %q", test.in)
+	}
+
+	// Refuse too big synchronizing literals without
+	// asking the client to send them.
+	r := bufio.NewReader(strings.NewReader("g LOGIN {99999999}\r\n"))
+	_, err := ReadCommand(r, func() error {
+		t.Errorf("expected no continuation request for refused literal")
+		return nil
+	})
+	litErr, ok := err.(*LiteralError)
+	assert.Truef(t, ok, "expected *LiteralError but got: %v", err)
+	if ok {
+		assert.Equalf(t, "g", litErr.Tag, "expected tag g but got %s", litErr.Tag)
+	}
+}
+
+// TestReadAppendRest executes a white-box table
+// test on implemented ReadAppendRest() function.
+func TestReadAppendRest(t *testing.T) {
+
+	for _, test := range readAppendRestTests {
+
+		r := bufio.NewReader(strings.NewReader(test.in))
+		conts := 0
+
+		rest, err := ReadAppendRest(r, func() error {
+			conts++
+			return nil
+		})
+		assert.Nilf(t, err, "expected ReadAppendRest(%q) not to fail but got: %v", test.in, err)
+		assert.Equalf(t, test.out, rest, "unexpected rest read from %q", test.in)
+		assert.Equalf(t, test.conts, conts, "expected %d continuation requests for %q but got %d", test.conts, test.in, conts)
+
+		left := make([]byte, r.Buffered())
+		r.Read(left)
+		assert.Equalf(t, test.rest, string(left), "unexpected data left after %q", test.in)
+	}
+}
+
+// FuzzParseArgs checks that ParseArgs() never panics and
+// that only a literal ending the payload may be pending.
+func FuzzParseArgs(f *testing.F) {
+
+	for _, test := range parseArgsTests {
+		f.Add(test.in)
+	}
+
+	f.Fuzz(func(t *testing.T, payload string) {
+
+		args, err := ParseArgs(payload)
+		if err != nil {
+			return
+		}
+
+		for i, arg := range args {
+
+			if arg.Pending && (i != (len(args) - 1)) {
+				t.Errorf("pending literal not at end of %q", payload)
+			}
+
+			if (arg.Type == NodeLiteral) && !arg.Pending && (len(arg.Value) != arg.Size) {
+				t.Errorf("literal of size %d with %d bytes of data in %q", arg.Size, len(arg.Value), payload)
+			}
+		}
+	})
+}
+
+// FuzzParseSeqSet checks that ParseSeqSet() never panics
+// and that sequence numbers resolve within their bounds.
+func FuzzParseSeqSet(f *testing.F) {
+
+	for _, test := range seqSetTests {
+		f.Add(test.in, uint32(7))
+	}
+
+	f.Fuzz(func(t *testing.T, raw string, max uint32) {
+
+		set, err := ParseSeqSet(raw)
+		if err != nil {
+			return
+		}
+
+		for _, seqRange := range set {
+
+			start, end := seqRange.Resolve(max)
+			if start > end {
+				t.Errorf("range %v of %q resolved to %d:%d", seqRange, raw, start, end)
+			}
+		}
+
+		ParseSeqNumbers(raw, int(max%1000))
+		ParseUIDSet(raw, []uint32{1, 2, 3, max})
+	})
+}
+
+// FuzzReadCommand checks that ReadCommand() never panics
+// and that everything it returns is a parsable request
+// or rejected with a tagged error.
+func FuzzReadCommand(f *testing.F) {
+
+	for _, test := range readCommandTests {
+		f.Add(test.in)
+	}
+
+	f.Fuzz(func(t *testing.T, in string) {
+
+		r := bufio.NewReader(strings.NewReader(in))
+
+		command, err := ReadCommand(r, func() error {
+			return nil
+		})
+		if err != nil {
+			return
+		}
+
+		ParseRequest(command)
+	})
+}
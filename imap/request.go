@@ -3,7 +3,6 @@ package imap
 import (
 	"fmt"
 	"sort"
-	"strconv"
 	"strings"
 )
 
@@ -18,6 +17,8 @@ const (
 	CommandStartTLS = "STARTTLS"
 	// CommandLogin defines IMAPv4 LOGIN support.
 	CommandLogin = "LOGIN"
+	// CommandAuthenticate defines IMAPv4 AUTHENTICATE support.
+	CommandAuthenticate = "AUTHENTICATE"
 	// CommandSelect defines IMAPv4 SELECT support.
 	CommandSelect = "SELECT"
 	// CommandCreate defines IMAPv4 CREATE support.
@@ -32,6 +33,50 @@ const (
 	CommandExpunge = "EXPUNGE"
 	// CommandStore defines IMAPv4 STORE support.
 	CommandStore = "STORE"
+	// CommandFetch defines IMAPv4 FETCH support.
+	CommandFetch = "FETCH"
+	// CommandSearch defines IMAPv4 SEARCH support.
+	CommandSearch = "SEARCH"
+	// CommandUID defines IMAPv4 UID support.
+	CommandUID = "UID"
+	// CommandCopy defines IMAPv4 COPY support.
+	CommandCopy = "COPY"
+	// CommandMove defines IMAP MOVE support (RFC 6851).
+	CommandMove = "MOVE"
+	// CommandRename defines IMAPv4 RENAME support.
+	CommandRename = "RENAME"
+	// CommandExamine defines IMAPv4 EXAMINE support.
+	CommandExamine = "EXAMINE"
+	// CommandStatus defines IMAPv4 STATUS support.
+	CommandStatus = "STATUS"
+	// CommandClose defines IMAPv4 CLOSE support.
+	CommandClose = "CLOSE"
+	// CommandUnselect defines IMAP UNSELECT support (RFC 3691).
+	CommandUnselect = "UNSELECT"
+	// CommandCheck defines IMAPv4 CHECK support.
+	CommandCheck = "CHECK"
+	// CommandNoop defines IMAPv4 NOOP support.
+	CommandNoop = "NOOP"
+	// CommandIdle defines IMAP IDLE support (RFC 2177).
+	CommandIdle = "IDLE"
+	// CommandSubscribe defines IMAPv4 SUBSCRIBE support.
+	CommandSubscribe = "SUBSCRIBE"
+	// CommandUnsubscribe defines IMAPv4 UNSUBSCRIBE support.
+	CommandUnsubscribe = "UNSUBSCRIBE"
+	// CommandLsub defines IMAPv4 LSUB support.
+	CommandLsub = "LSUB"
+	// CommandEnable defines IMAP ENABLE support (RFC 5161).
+	CommandEnable = "ENABLE"
+	// CommandCompress defines IMAP COMPRESS support (RFC 4978).
+	CommandCompress = "COMPRESS"
+	// CommandID defines IMAP ID support (RFC 2971).
+	CommandID = "ID"
+	// CommandNamespace defines IMAP NAMESPACE support (RFC 2342).
+	CommandNamespace = "NAMESPACE"
+	// CommandSort defines IMAP SORT support (RFC 5256).
+	CommandSort = "SORT"
+	// CommandThread defines IMAP THREAD support (RFC 5256).
+	CommandThread = "THREAD"
 )
 
 // Variables
@@ -40,28 +85,54 @@ const (
 // for checking if a supplied IMAP command
 // is supported by pluto.
 var SupportedCommands = map[string]bool{
-	CommandCapability: true,
-	CommandLogout:     true,
-	CommandStartTLS:   true,
-	CommandLogin:      true,
-	CommandSelect:     true,
-	CommandCreate:     true,
-	CommandDelete:     true,
-	CommandList:       true,
-	CommandAppend:     true,
-	CommandExpunge:    true,
-	CommandStore:      true,
+	CommandCapability:   true,
+	CommandLogout:       true,
+	CommandStartTLS:     true,
+	CommandLogin:        true,
+	CommandAuthenticate: true,
+	CommandSelect:       true,
+	CommandCreate:       true,
+	CommandDelete:       true,
+	CommandList:         true,
+	CommandAppend:       true,
+	CommandExpunge:      true,
+	CommandStore:        true,
+	CommandFetch:        true,
+	CommandSearch:       true,
+	CommandUID:          true,
+	CommandCopy:         true,
+	CommandMove:         true,
+	CommandRename:       true,
+	CommandExamine:      true,
+	CommandStatus:       true,
+	CommandClose:        true,
+	CommandUnselect:     true,
+	CommandCheck:        true,
+	CommandNoop:         true,
+	CommandIdle:         true,
+	CommandSubscribe:    true,
+	CommandUnsubscribe:  true,
+	CommandLsub:         true,
+	CommandEnable:       true,
+	CommandCompress:     true,
+	CommandID:           true,
+	CommandNamespace:    true,
+	CommandSort:         true,
+	CommandThread:       true,
 }
 
 // Structs
 
 // Request represents the parsed content of a client
-// command line sent to pluto. Payload will be examined
-// further in command specific functions.
+// command line sent to pluto. Args contains the parsed
+// arguments of the command, Payload their raw text.
+// Both will be examined further in command specific
+// functions.
 type Request struct {
 	Tag     string
 	Command string
 	Payload string
+	Args    []*Node
 }
 
 // Functions
@@ -94,16 +165,26 @@ func ParseRequest(req string) (*Request, error) {
 	}
 
 	// If the command has a defined payload, add
-	// it to the struct as blob payload text.
+	// it to the struct as blob payload text and
+	// parse it into the command's arguments.
 	if len(tmpReq) > 2 {
+
 		finalReq.Payload = tmpReq[2]
+
+		args, err := ParseArgs(finalReq.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("%s BAD Command %s was sent with invalid arguments: %v", finalReq.Tag, finalReq.Command, err)
+		}
+
+		finalReq.Args = args
 	}
 
 	return finalReq, nil
 }
 
 // ParseSeqNumbers returns complete and normalized list
-// of message sequence numbers for use in e.g. STORE command.
+// of message sequence numbers for use in e.g. STORE or
+// FETCH commands.
 func ParseSeqNumbers(recv string, lenMailboxContents int) ([]int, error) {
 
 	// If supplied number of mail messages in selected
@@ -112,142 +193,116 @@ func ParseSeqNumbers(recv string, lenMailboxContents int) ([]int, error) {
 		return nil, fmt.Errorf("Cannot select mail in empty mailbox")
 	}
 
+	seqSet, err := ParseSeqSet(recv)
+	if err != nil {
+
+		// Number parameter was invalid, client error.
+		// Send tagged BAD response.
+		return nil, fmt.Errorf("Command was sent with an invalid number parameter")
+	}
+
 	// Initialize needed data stores.
-	var err error
 	msgNums := make([]int, 0, 6)
 	seenMsgNums := make(map[int]bool)
 
-	// Split into sequence ranges or sequence numbers.
-	msgNumsSet := strings.Split(recv, ",")
-
-	for _, numsSet := range msgNumsSet {
-
-		numStart := 0
-		numEnd := 0
-
-		// Split into sequence numbers if not already done.
-		msgNumsRange := strings.Split(numsSet, ":")
-
-		if msgNumsRange[0] == "*" {
+	for _, seqRange := range seqSet {
 
-			// If wildcard symbol was set as beginning range
-			// number, replace it with maximum number in mailbox.
-			numStart = lenMailboxContents
-		} else {
+		numStart, numEnd := seqRange.Resolve(uint32(lenMailboxContents))
 
-			// Convert string to number.
-			numStart, err = strconv.Atoi(msgNumsRange[0])
-			if err != nil {
-
-				// Number parameter was invalid, client error.
-				// Send tagged BAD response.
-				return nil, fmt.Errorf("Command STORE was sent with an invalid number parameter")
-			}
+		// Make sure that numStart and numEnd both
+		// refer to existing message sequence numbers.
+		if numEnd > uint32(lenMailboxContents) {
+			return nil, fmt.Errorf("Command was sent with a number parameter not referring to an existing mail message")
 		}
 
-		if len(msgNumsRange) > 1 {
-
-			if msgNumsRange[1] == "*" {
-
-				// If wildcard symbol was set as end number of range,
-				// replace it with maximum number in mailbox.
-				numEnd = lenMailboxContents
-			} else {
-
-				// Convert string to number.
-				numEnd, err = strconv.Atoi(msgNumsRange[1])
-				if err != nil {
+		for u := int(numStart); u <= int(numEnd); u++ {
 
-					// Number parameter was invalid, client error.
-					// Send tagged BAD response.
-					return nil, fmt.Errorf("Command STORE was sent with an invalid number parameter")
-				}
-			}
+			if _, seen := seenMsgNums[u]; !seen {
 
-			if numEnd < numStart {
+				// Sequence number specified, append it if
+				// we have not yet seen this value.
+				msgNums = append(msgNums, (u - 1))
 
-				// If end range number is bigger than start
-				// range number, exchange both values.
-				numTmp := numEnd
-				numEnd = numStart
-				numStart = numTmp
+				// Set corresponding seen value to true.
+				seenMsgNums[u] = true
 			}
 		}
+	}
 
-		if len(msgNumsRange) == 1 {
+	// Sort resulting numbers list.
+	sort.Ints(msgNums)
 
-			// Make sure that numStart refers to an
-			// existing message sequence number.
-			if (numStart < 1) || (numStart > lenMailboxContents) {
-				return nil, fmt.Errorf("Command STORE was sent with a number parameter not referring to an existing mail message")
-			}
+	return msgNums, nil
+}
 
-			if _, seen := seenMsgNums[numStart]; !seen {
+// ParseUIDSet returns the sorted indices into uids of all
+// messages whose UID is contained in the supplied UID set.
+// uids is expected to be sorted ascendingly. In contrast
+// to message sequence numbers, UIDs not referring to any
+// existing message are silently ignored.
+func ParseUIDSet(recv string, uids []uint32) ([]int, error) {
 
-				// Sequence number specified, append it if
-				// we have not yet seen this value.
-				msgNums = append(msgNums, (numStart - 1))
+	uidSet, err := ParseSeqSet(recv)
+	if err != nil {
+		return nil, fmt.Errorf("Command was sent with an invalid UID parameter")
+	}
 
-				// Set corresponding seen value to true.
-				seenMsgNums[numStart] = true
-			}
+	msgNums := make([]int, 0, 6)
 
-		} else {
+	// Wildcard symbol stands for the highest UID in use.
+	maxUID := uint32(0)
+	if len(uids) > 0 {
+		maxUID = uids[(len(uids) - 1)]
+	}
 
-			// Make sure that numStart and numEnd both
-			// refer to existing message sequence numbers.
-			if (numStart < 1) || (numStart > lenMailboxContents) || (numEnd < 1) || (numEnd > lenMailboxContents) {
-				return nil, fmt.Errorf("Command STORE was sent with a number parameter not referring to an existing mail message")
-			}
+	// Mark all included messages.
+	included := make([]bool, len(uids))
 
-			for u := numStart; u <= numEnd; u++ {
+	for _, uidRange := range uidSet {
 
-				if _, seen := seenMsgNums[u]; !seen {
+		uidStart, uidEnd := uidRange.Resolve(maxUID)
 
-					// Sequence number specified, append it if
-					// we have not yet seen this value.
-					msgNums = append(msgNums, (u - 1))
+		// Find first message in range and mark
+		// all following ones inside the range.
+		i := sort.Search(len(uids), func(i int) bool {
+			return uids[i] >= uidStart
+		})
 
-					// Set corresponding seen value to true.
-					seenMsgNums[u] = true
-				}
-			}
+		for ; (i < len(uids)) && (uids[i] <= uidEnd); i++ {
+			included[i] = true
 		}
 	}
 
-	// Sort resulting numbers list.
-	sort.Ints(msgNums)
+	for i, isIncluded := range included {
+
+		if isIncluded {
+			msgNums = append(msgNums, i)
+		}
+	}
 
 	return msgNums, nil
 }
 
-// ParseFlags takes in the string representation of
-// a parenthesized list of IMAP flags and returns a
-// map containing all found flags.
-func ParseFlags(recv string) (map[string]struct{}, error) {
+// ParseFlags takes in the flag arguments of a command,
+// either one parenthesized list of flags or a sequence
+// of single flags, and returns a map containing all
+// found flags.
+func ParseFlags(recv []*Node) (map[string]struct{}, error) {
 
 	// Reserve space.
 	flags := make(map[string]struct{})
 
-	if strings.HasPrefix(recv, "(") {
-		// Remove leading parenthesis.
-		recv = strings.TrimPrefix(recv, "(")
-	} else {
-		return nil, fmt.Errorf("Command STORE was sent with invalid parenthesized flags list")
+	if (len(recv) == 1) && recv[0].IsList() {
+		recv = recv[0].Items
 	}
 
-	if strings.HasSuffix(recv, ")") {
-		// Remove trailing parenthesis.
-		recv = strings.TrimSuffix(recv, ")")
-	} else {
-		return nil, fmt.Errorf("Command STORE was sent with invalid parenthesized flags list")
-	}
+	for _, flag := range recv {
 
-	// Split at space symbols.
-	flagsRaw := strings.Split(recv, " ")
+		if flag.Type != NodeAtom {
+			return nil, fmt.Errorf("Command was sent with invalid flags list")
+		}
 
-	for _, flag := range flagsRaw {
-		flags[flag] = struct{}{}
+		flags[flag.Value] = struct{}{}
 	}
 
 	return flags, nil
@@ -0,0 +1,771 @@
+package imap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/go-pluto/maildir"
+	"github.com/go-pluto/pluto/comm"
+	"github.com/go-pluto/pluto/crdt"
+)
+
+// Constants
+
+// Layout of dates in SEARCH keys.
+const searchDateLayout = "2-Jan-2006"
+
+// Variables
+
+// searchFlagKeys maps SEARCH keys testing for a system
+// flag to the corresponding Maildir flag character and
+// whether the flag is required to be present.
+var searchFlagKeys = map[string]struct {
+	flag    rune
+	present bool
+}{
+	"ANSWERED":   {'R', true},
+	"DELETED":    {'T', true},
+	"DRAFT":      {'D', true},
+	"FLAGGED":    {'F', true},
+	"SEEN":       {'S', true},
+	"UNANSWERED": {'R', false},
+	"UNDELETED":  {'T', false},
+	"UNDRAFT":    {'D', false},
+	"UNFLAGGED":  {'F', false},
+	"UNSEEN":     {'S', false},
+}
+
+// searchHeaderKeys maps SEARCH keys matching
+// on a header field to the field's name.
+var searchHeaderKeys = map[string]string{
+	"BCC":     "bcc",
+	"CC":      "cc",
+	"FROM":    "from",
+	"SUBJECT": "subject",
+	"TO":      "to",
+}
+
+// Structs
+
+// searchToken is one lexical element of a SEARCH
+// request. Quoted strings are never interpreted
+// as keys or parentheses.
+type searchToken struct {
+	value  string
+	quoted bool
+}
+
+// searchMsg carries everything search keys
+// are evaluated against for one message. The
+// body text is only read from path if a key
+// needs it, failing to do so is noted in err.
+type searchMsg struct {
+	seqNum   int
+	uid      uint32
+	flags    string
+	modSeq   uint64
+	entry    *IndexEntry
+	path     string
+	body     string
+	bodyRead bool
+	err      error
+}
+
+// searchFunc is a compiled search key.
+type searchFunc func(m *searchMsg) bool
+
+// searchParser compiles a list of search tokens sent
+// with command into a searchFunc. It needs the number
+// of messages and their UIDs to resolve sequence and
+// UID sets and the keyword CRDT to resolve keywords
+// to Maildir letters. usesModSeq records whether the
+// MODSEQ key was used.
+type searchParser struct {
+	command    string
+	tokens     []searchToken
+	pos        int
+	numMails   int
+	uids       []uint32
+	keywords   *crdt.KeywordSet
+	needsIndex bool
+	usesModSeq bool
+}
+
+// Functions
+
+// searchTokens flattens the arguments of a SEARCH
+// request into atoms, strings, and parentheses.
+func searchTokens(args []*Node) []searchToken {
+
+	tokens := make([]searchToken, 0, (len(args) + 2))
+
+	for _, arg := range args {
+
+		switch arg.Type {
+
+		case NodeList:
+			tokens = append(tokens, searchToken{value: "("})
+			tokens = append(tokens, searchTokens(arg.Items)...)
+			tokens = append(tokens, searchToken{value: ")"})
+
+		case NodeAtom:
+			tokens = append(tokens, searchToken{value: arg.Value})
+
+		default:
+			tokens = append(tokens, searchToken{value: arg.Value, quoted: true})
+		}
+	}
+
+	return tokens
+}
+
+// bodyText returns the lower-cased text of the
+// message's body, reading it on first use.
+func (m *searchMsg) bodyText() string {
+
+	if !m.bodyRead {
+
+		m.bodyRead = true
+
+		content, err := ioutil.ReadFile(m.path)
+		if err != nil {
+			m.err = err
+			return ""
+		}
+
+		m.body = mailText(content)
+	}
+
+	return m.body
+}
+
+// next returns the next token or an error
+// if all tokens have been consumed.
+func (p *searchParser) next() (searchToken, error) {
+
+	if p.pos >= len(p.tokens) {
+		return searchToken{}, fmt.Errorf("Command %s ended unexpectedly", p.command)
+	}
+
+	token := p.tokens[p.pos]
+	p.pos++
+
+	return token, nil
+}
+
+// nextString returns the next token as string argument.
+func (p *searchParser) nextString() (string, error) {
+
+	token, err := p.next()
+	if err != nil {
+		return "", err
+	}
+
+	if !token.quoted && ((token.value == "(") || (token.value == ")")) {
+		return "", fmt.Errorf("Command %s was sent with missing string argument", p.command)
+	}
+
+	return token.value, nil
+}
+
+// nextDate returns the next token as date argument.
+func (p *searchParser) nextDate() (time.Time, error) {
+
+	value, err := p.nextString()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	date, err := time.Parse(searchDateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Command %s was sent with invalid date %s", p.command, value)
+	}
+
+	return date, nil
+}
+
+// nextNumber returns the next token as number argument.
+func (p *searchParser) nextNumber() (int64, error) {
+
+	value, err := p.nextString()
+	if err != nil {
+		return 0, err
+	}
+
+	num, err := strconv.ParseInt(value, 10, 64)
+	if (err != nil) || (num < 0) {
+		return 0, fmt.Errorf("Command %s was sent with invalid number %s", p.command, value)
+	}
+
+	return num, nil
+}
+
+// dayOf truncates t to its date in its own time
+// zone, as SEARCH disregards time and time zone.
+func dayOf(t time.Time) time.Time {
+
+	year, month, day := t.Date()
+
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// dateKey builds a searchFunc comparing a message
+// date against date depending on the key's prefix.
+func dateKey(key string, date time.Time, msgDate func(m *searchMsg) time.Time) searchFunc {
+
+	switch {
+
+	case strings.HasSuffix(key, "BEFORE"):
+		return func(m *searchMsg) bool {
+			return dayOf(msgDate(m)).Before(date)
+		}
+
+	case strings.HasSuffix(key, "ON"):
+		return func(m *searchMsg) bool {
+			return dayOf(msgDate(m)).Equal(date)
+		}
+	}
+
+	return func(m *searchMsg) bool {
+		return !dayOf(msgDate(m)).Before(date)
+	}
+}
+
+// indexSet turns a list of indices into a searchFunc.
+func indexSet(mailSeqNums []int) searchFunc {
+
+	inSet := make(map[int]bool)
+	for _, mailSeqNum := range mailSeqNums {
+		inSet[mailSeqNum] = true
+	}
+
+	return func(m *searchMsg) bool {
+		return inSet[m.seqNum]
+	}
+}
+
+// parseKeys parses search keys until the end of input
+// or a closing parenthesis and combines them by AND.
+func (p *searchParser) parseKeys() (searchFunc, error) {
+
+	keys := make([]searchFunc, 0, 4)
+
+	for p.pos < len(p.tokens) {
+
+		token := p.tokens[p.pos]
+		if !token.quoted && (token.value == ")") {
+			break
+		}
+
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("Command %s was sent without search keys", p.command)
+	}
+
+	return func(m *searchMsg) bool {
+
+		for _, key := range keys {
+
+			if !key(m) {
+				return false
+			}
+		}
+
+		return true
+	}, nil
+}
+
+// parseKey parses exactly one search key.
+func (p *searchParser) parseKey() (searchFunc, error) {
+
+	token, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	if token.quoted {
+		return nil, fmt.Errorf("Command %s was sent with unexpected string %s", p.command, token.value)
+	}
+
+	key := strings.ToUpper(token.value)
+
+	if flagKey, found := searchFlagKeys[key]; found {
+
+		return func(m *searchMsg) bool {
+			return strings.ContainsRune(m.flags, flagKey.flag) == flagKey.present
+		}, nil
+	}
+
+	if field, found := searchHeaderKeys[key]; found {
+
+		value, err := p.nextString()
+		if err != nil {
+			return nil, err
+		}
+
+		return p.headerKey(field, value), nil
+	}
+
+	switch key {
+
+	case "(":
+
+		keys, err := p.parseKeys()
+		if err != nil {
+			return nil, err
+		}
+
+		closing, err := p.next()
+		if err != nil || closing.quoted || (closing.value != ")") {
+			return nil, fmt.Errorf("Command %s was sent with unbalanced parentheses", p.command)
+		}
+
+		return keys, nil
+
+	case ")":
+		return nil, fmt.Errorf("Command %s was sent with unbalanced parentheses", p.command)
+
+	case "ALL", "OLD":
+
+		return func(m *searchMsg) bool {
+			return true
+		}, nil
+
+	case "NEW", "RECENT":
+
+		// Pluto never sets the \Recent flag, so no
+		// message is recent and NEW, which equals
+		// (RECENT UNSEEN), matches none either.
+		return func(m *searchMsg) bool {
+			return false
+		}, nil
+
+	case "NOT":
+
+		negated, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+
+		return func(m *searchMsg) bool {
+			return !negated(m)
+		}, nil
+
+	case "OR":
+
+		left, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+
+		return func(m *searchMsg) bool {
+			return left(m) || right(m)
+		}, nil
+
+	case "HEADER":
+
+		field, err := p.nextString()
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := p.nextString()
+		if err != nil {
+			return nil, err
+		}
+
+		return p.headerKey(strings.ToLower(field), value), nil
+
+	case "BODY", "TEXT":
+
+		value, err := p.nextString()
+		if err != nil {
+			return nil, err
+		}
+
+		value = strings.ToLower(value)
+
+		if key == "BODY" {
+
+			return func(m *searchMsg) bool {
+				return strings.Contains(m.bodyText(), value)
+			}, nil
+		}
+
+		p.needsIndex = true
+
+		return func(m *searchMsg) bool {
+			return strings.Contains(m.entry.HeaderText, value) || strings.Contains(m.bodyText(), value)
+		}, nil
+
+	case "BEFORE", "ON", "SINCE":
+
+		date, err := p.nextDate()
+		if err != nil {
+			return nil, err
+		}
+
+		p.needsIndex = true
+
+		return dateKey(key, date, func(m *searchMsg) time.Time {
+			return m.entry.InternalDate
+		}), nil
+
+	case "SENTBEFORE", "SENTON", "SENTSINCE":
+
+		date, err := p.nextDate()
+		if err != nil {
+			return nil, err
+		}
+
+		p.needsIndex = true
+
+		return dateKey(key, date, func(m *searchMsg) time.Time {
+			return m.entry.SentDate
+		}), nil
+
+	case "LARGER", "SMALLER":
+
+		size, err := p.nextNumber()
+		if err != nil {
+			return nil, err
+		}
+
+		p.needsIndex = true
+
+		if key == "LARGER" {
+
+			return func(m *searchMsg) bool {
+				return m.entry.Size > size
+			}, nil
+		}
+
+		return func(m *searchMsg) bool {
+			return m.entry.Size < size
+		}, nil
+
+	case "KEYWORD", "UNKEYWORD":
+
+		keyword, err := p.nextString()
+		if err != nil {
+			return nil, err
+		}
+
+		unkeyword := key == "UNKEYWORD"
+
+		// A keyword never defined is not
+		// carried by any message.
+		letter, found := p.keywords.Letter(keyword)
+		if !found {
+
+			return func(m *searchMsg) bool {
+				return unkeyword
+			}, nil
+		}
+
+		return func(m *searchMsg) bool {
+			return strings.ContainsRune(m.flags, letter) != unkeyword
+		}, nil
+
+	case "MODSEQ":
+
+		value, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+
+		// The optional metadata entry and its type are
+		// accepted, but as MODSEQs are only tracked per
+		// message, each entry matches the message's one.
+		if value.quoted {
+
+			entryType, err := p.next()
+			if err != nil {
+				return nil, err
+			}
+
+			switch strings.ToLower(entryType.value) {
+			case "priv", "shared", "all":
+			default:
+				return nil, fmt.Errorf("Command %s was sent with invalid MODSEQ entry type %s", p.command, entryType.value)
+			}
+
+			value, err = p.next()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		modSeq, err := parseModSeq(value.value, true)
+		if err != nil {
+			return nil, err
+		}
+
+		p.usesModSeq = true
+
+		return func(m *searchMsg) bool {
+			return m.modSeq >= modSeq
+		}, nil
+
+	case "UID":
+
+		uidSet, err := p.nextString()
+		if err != nil {
+			return nil, err
+		}
+
+		mailSeqNums, err := ParseUIDSet(uidSet, p.uids)
+		if err != nil {
+			return nil, err
+		}
+
+		return indexSet(mailSeqNums), nil
+	}
+
+	// Any other key has to be a sequence set.
+	if strings.Trim(key, "0123456789:*,") != "" {
+		return nil, fmt.Errorf("Command %s was sent with unknown search key %s", p.command, token.value)
+	}
+
+	if p.numMails == 0 {
+		return indexSet(nil), nil
+	}
+
+	mailSeqNums, err := ParseSeqNumbers(key, p.numMails)
+	if err != nil {
+		return nil, err
+	}
+
+	return indexSet(mailSeqNums), nil
+}
+
+// headerKey builds a searchFunc matching messages whose
+// header field contains value. An empty value matches
+// all messages that have the field at all.
+func (p *searchParser) headerKey(field string, value string) searchFunc {
+
+	value = strings.ToLower(value)
+	p.needsIndex = true
+
+	return func(m *searchMsg) bool {
+
+		for _, fieldValue := range m.entry.Header[field] {
+
+			if strings.Contains(fieldValue, value) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// supportedCharset returns true if charset is
+// one search keys may be specified in.
+func supportedCharset(charset string) bool {
+
+	charset = strings.ToUpper(charset)
+
+	return (charset == "UTF-8") || (charset == "US-ASCII")
+}
+
+// compileSearch parses the search keys in tokens sent
+// with supplied command against the selected mailbox
+// and returns the parser and the compiled keys. The
+// caller is required to hold the lock.
+func (mailbox *Mailbox) compileSearch(s *Session, command string, tokens []searchToken) (*searchParser, searchFunc, error) {
+
+	parser := &searchParser{
+		command:  command,
+		tokens:   tokens,
+		numMails: len(mailbox.Mails[s.SelectedMailbox]),
+		uids:     mailbox.folderUIDs(s.SelectedMailbox),
+		keywords: mailbox.Keywords,
+	}
+
+	matcher, err := parser.parseKeys()
+	if (err == nil) && (parser.pos < len(parser.tokens)) {
+		err = fmt.Errorf("Command %s was sent with unbalanced parentheses", command)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Using the MODSEQ key enables CONDSTORE.
+	if parser.usesModSeq {
+		s.CondStore = true
+	}
+
+	return parser, matcher, nil
+}
+
+// matchMails evaluates matcher against all mails of the
+// selected mailbox and returns the matching ones in order
+// of their sequence numbers. If withIndex is true, index
+// entries are provided even if no search key needs them.
+// The caller is required to hold the exclusive lock as
+// missing entries are added to the index.
+func (mailbox *Mailbox) matchMails(s *Session, parser *searchParser, matcher searchFunc, withIndex bool) ([]*searchMsg, error) {
+
+	searchMaildir := maildir.Dir(mailbox.FolderPath(s.SelectedMailbox))
+	mails := mailbox.Mails[s.SelectedMailbox]
+	matches := make([]*searchMsg, 0, len(mails))
+
+	for i, mailFileName := range mails {
+
+		mailFlags, err := searchMaildir.Flags(mailFileName, false)
+		if err != nil {
+			return nil, fmt.Errorf("error while retrieving flags for mail: %v", err)
+		}
+
+		msg := &searchMsg{
+			seqNum: i,
+			uid:    parser.uids[i],
+			flags:  mailFlags,
+			modSeq: mailbox.mailModSeq(s.SelectedMailbox, mailFileName),
+			path:   filepath.Join(string(searchMaildir), "cur", mailFileName),
+		}
+
+		// Only consult the index if anyone needs it.
+		if parser.needsIndex || withIndex {
+
+			msg.entry, err = mailbox.indexEntry(s.SelectedMailbox, msg.path)
+			if err != nil {
+				return nil, fmt.Errorf("error while indexing mail: %v", err)
+			}
+		}
+
+		matched := matcher(msg)
+		if msg.err != nil {
+			return nil, fmt.Errorf("error while reading body of mail: %v", msg.err)
+		}
+
+		if matched {
+			matches = append(matches, msg)
+		}
+	}
+
+	return matches, nil
+}
+
+// searchResponse returns the untagged response of
+// supplied name listing the messages in msgs by UID
+// if useUID is true or else by sequence number. If
+// withModSeq is true, the highest MODSEQ of all
+// listed messages is appended.
+func searchResponse(name string, msgs []*searchMsg, useUID bool, withModSeq bool) string {
+
+	answer := fmt.Sprintf("* %s", name)
+	highestModSeq := uint64(0)
+
+	for _, msg := range msgs {
+
+		if useUID {
+			answer = fmt.Sprintf("%s %d", answer, msg.uid)
+		} else {
+			answer = fmt.Sprintf("%s %d", answer, (msg.seqNum + 1))
+		}
+
+		if msg.modSeq > highestModSeq {
+			highestModSeq = msg.modSeq
+		}
+	}
+
+	// Searches for MODSEQ report the highest
+	// MODSEQ of all matching messages.
+	if withModSeq && (len(msgs) > 0) {
+		answer = fmt.Sprintf("%s (MODSEQ %d)", answer, highestModSeq)
+	}
+
+	return answer
+}
+
+// Search returns the message sequence numbers of all
+// messages in the selected mailbox matching all of the
+// supplied search keys.
+func (mailbox *Mailbox) Search(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+	return mailbox.search(s, req, false)
+}
+
+// search implements SEARCH and UID SEARCH with the full
+// search key grammar of RFC 3501. If useUID is true, UIDs
+// are returned instead of message sequence numbers. Keys
+// concerning headers, dates, or size are evaluated against
+// the mailbox's message index, bodies are read from disk.
+func (mailbox *Mailbox) search(s *Session, req *Request, useUID bool) (*Reply, error) {
+
+	if s.State != StateMailbox {
+
+		// If connection was not in correct state when this
+		// command was executed, this is a client error.
+		// Send tagged BAD response.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD No mailbox selected to search", req.Tag),
+		}, nil
+	}
+
+	tokens := searchTokens(req.Args)
+
+	// Check optionally specified charset.
+	if (len(tokens) > 0) && !tokens[0].quoted && (strings.ToUpper(tokens[0].value) == "CHARSET") {
+
+		if len(tokens) < 2 {
+
+			return &Reply{
+				Text: fmt.Sprintf("%s BAD Command SEARCH was sent without charset", req.Tag),
+			}, nil
+		}
+
+		if !supportedCharset(tokens[1].value) {
+
+			return &Reply{
+				Text: fmt.Sprintf("%s NO [BADCHARSET (US-ASCII UTF-8)] Charset not supported", req.Tag),
+			}, nil
+		}
+
+		tokens = tokens[2:]
+	}
+
+	// Lock node exclusively as evaluating keys
+	// may add missing entries to the index.
+	mailbox.Lock.Lock()
+	defer mailbox.Lock.Unlock()
+
+	parser, matcher, err := mailbox.compileSearch(s, CommandSearch, tokens)
+	if err != nil {
+
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD %s", req.Tag, err.Error()),
+		}, nil
+	}
+
+	matches, err := mailbox.matchMails(s, parser, matcher, false)
+	if err != nil {
+
+		return &Reply{
+			Text:   "* BAD Internal server error, sorry. Closing connection.",
+			Status: 1,
+		}, err
+	}
+
+	return &Reply{
+		Text: fmt.Sprintf("%s\r\n%s OK SEARCH completed", searchResponse("SEARCH", matches, useUID, parser.usesModSeq), req.Tag),
+	}, nil
+}
@@ -0,0 +1,259 @@
+package imap
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-pluto/maildir"
+	"github.com/go-pluto/pluto/crdt"
+	"github.com/stretchr/testify/assert"
+)
+
+// Variables
+
+// searchMails are stored in this order in the
+// INBOX of the mailbox searches are tested on.
+var searchMails = []struct {
+	key          string
+	flags        string
+	internalDate time.Time
+	content      string
+}{
+	{"1488300000.a", "S", time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC), "Date: Tue, 28 Feb 2017 10:00:00 +0100\r\nFrom: Alice <alice@example.com>\r\nTo: bob@example.com\r\nSubject: Meeting agenda\r\nMessage-ID: <a@example.com>\r\n\r\nLet us meet on Monday.\r\n"},
+	{"1488700000.b", "", time.Date(2017, time.March, 5, 12, 0, 0, 0, time.UTC), "Date: 5 Mar 2017 09:00:00 +0000\r\nFrom: Bob <bob@example.com>\r\nTo: alice@example.com\r\nSubject: =?UTF-8?Q?Gr=C3=BC=C3=9Fe?=\r\nMessage-ID: <b@example.com>\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\nDer Kuchen ist fertig, gr=C3=BC=C3=9Fe!\r\n"},
+	{"1489100000.c", "FR", time.Date(2017, time.March, 10, 12, 0, 0, 0, time.UTC), "Date: Fri, 10 Mar 2017 12:00:00 +0000\r\nFrom: Alice <alice@example.com>\r\nTo: bob@example.com\r\nCc: carol@example.com\r\nSubject: Re: Meeting agenda\r\nMessage-ID: <c@example.com>\r\nIn-Reply-To: <a@example.com>\r\n\r\nMonday works for me.\r\n"},
+	{"1491000000.d", "T", time.Date(2017, time.April, 1, 12, 0, 0, 0, time.UTC), "Date: Sat, 1 Apr 2017 08:00:00 +0000\r\nFrom: Carol <carol@example.com>\r\nTo: alice@example.com\r\nSubject: Report\r\nMessage-ID: <d@example.com>\r\nMIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=\"sep\"\r\n\r\n--sep\r\nContent-Type: text/plain\r\n\r\nSee the attachment inside.\r\n--sep\r\nContent-Type: application/octet-stream\r\nContent-Transfer-Encoding: base64\r\n\r\n" + strings.Repeat("c2VjcmV0IHJlcG9ydCBkYXRh\r\n", 20) + "--sep--\r\n"},
+}
+
+var searchTests = []struct {
+	query  string
+	result string
+}{
+	{"ALL", "1 2 3 4"},
+	{"all", "1 2 3 4"},
+	{"SEEN", "1"},
+	{"UNSEEN", "2 3 4"},
+	{"FLAGGED ANSWERED", "3"},
+	{"DELETED", "4"},
+	{"UNDELETED UNDRAFT", "1 2 3"},
+	{"RECENT", ""},
+	{"NEW", ""},
+	{"OLD", "1 2 3 4"},
+	{"NOT RECENT", "1 2 3 4"},
+	{"OR SEEN FLAGGED", "1 3"},
+	{"OR OR SEEN FLAGGED DELETED", "1 3 4"},
+	{"OR SEEN (FLAGGED DELETED)", "1"},
+	{"OR (SEEN DELETED) NOT OR FLAGGED DELETED", "1 2"},
+	{"NOT (FROM alice SUBJECT meeting)", "2 4"},
+	{"NOT NOT SEEN", "1"},
+	{"((UNSEEN) OR (FLAGGED) (DELETED))", "3 4"},
+	{"2:3", "2 3"},
+	{"1,3:*", "1 3 4"},
+	{"*", "4"},
+	{"4:2 UNSEEN NOT 4", "2 3"},
+	{"UID 2:3", "1 2"},
+	{"UID 3,10:*", "2 4"},
+	{"OR 1 UID 4", "1 3"},
+	{"SINCE 5-Mar-2017", "2 3 4"},
+	{"BEFORE 5-Mar-2017", "1"},
+	{"ON 10-Mar-2017", "3"},
+	{"SINCE 5-Mar-2017 BEFORE 1-Apr-2017", "2 3"},
+	{"SENTON 28-Feb-2017", "1"},
+	{"SENTBEFORE 5-Mar-2017", "1"},
+	{"SENTSINCE 5-Mar-2017 NOT SENTSINCE 1-Apr-2017", "2 3"},
+	{"FROM ALICE", "1 3"},
+	{"TO alice@example.com", "2 4"},
+	{"CC carol", "3"},
+	{"BCC carol", ""},
+	{"SUBJECT \"meeting agenda\"", "1 3"},
+	{"SUBJECT grüße", "2"},
+	{"HEADER Message-ID <c@example.com>", "3"},
+	{"HEADER In-Reply-To \"\"", "3"},
+	{"BODY monday", "1 3"},
+	{"BODY agenda", ""},
+	{"TEXT agenda", "1 3"},
+	{"BODY \"grüße\"", "2"},
+	{"BODY attachment", "4"},
+	{"BODY secret", ""},
+	{"LARGER 500", "4"},
+	{"SMALLER 500", "1 2 3"},
+	{"KEYWORD $Important", ""},
+	{"UNKEYWORD $Important", "1 2 3 4"},
+	{"CHARSET UTF-8 SUBJECT grüße", "2"},
+	{"CHARSET us-ascii SEEN", "1"},
+	{"CHARSET ISO-8859-1 SEEN", "NO"},
+	{"CHARSET", "BAD"},
+	{"NOT", "BAD"},
+	{"OR SEEN", "BAD"},
+	{"(SEEN", "BAD"},
+	{"SEEN)", "BAD"},
+	{"()", "BAD"},
+	{"FROM", "BAD"},
+	{"SINCE 2017-03-05", "BAD"},
+	{"LARGER -1", "BAD"},
+	{"5", "BAD"},
+	{"UNKNOWN", "BAD"},
+	{"\"SEEN\"", "BAD"},
+}
+
+// Functions
+
+// newTestMailbox returns the mailbox of a user whose
+// CRDTs and Maildir reside in a temporary directory
+// and who owns supplied folders in addition to INBOX.
+// The returned function removes the directory.
+func newTestMailbox(t *testing.T, folders ...string) (*Mailbox, func()) {
+
+	dir, err := ioutil.TempDir("", "pluto-mailbox")
+	assert.Nilf(t, err, "expected temporary directory but got: %v", err)
+
+	initORSet := func(name string) *crdt.ORSet {
+
+		set, err := crdt.InitORSetWithFile(filepath.Join(dir, name))
+		assert.Nilf(t, err, "expected %s to be initialized but got: %v", name, err)
+
+		return set
+	}
+
+	uids, err := crdt.InitUIDSetWithFile(filepath.Join(dir, "uids.crdt"))
+	assert.Nilf(t, err, "expected UID CRDT to be initialized but got: %v", err)
+
+	keywords, err := crdt.InitKeywordSetWithFile(filepath.Join(dir, "keywords.crdt"))
+	assert.Nilf(t, err, "expected keyword CRDT to be initialized but got: %v", err)
+
+	modSeqs, err := crdt.InitModSeqsWithFile(filepath.Join(dir, "modseqs.crdt"))
+	assert.Nilf(t, err, "expected MODSEQ CRDT to be initialized but got: %v", err)
+
+	mailbox := &Mailbox{
+		Logger:             log.NewNopLogger(),
+		Lock:               &sync.RWMutex{},
+		Structure:          initORSet("structure.crdt"),
+		Subscriptions:      initORSet("subscriptions.crdt"),
+		SpecialUse:         initORSet("specialuse.crdt"),
+		UIDs:               uids,
+		Keywords:           keywords,
+		ModSeqs:            modSeqs,
+		Index:              NewMessageIndex(),
+		Watchers:           NewWatchers(),
+		Sessions:           make(map[*Session]bool),
+		Mails:              make(map[string][]string),
+		CRDTPath:           dir,
+		MaildirPath:        filepath.Join(dir, "Maildir"),
+		HierarchySeparator: ".",
+	}
+
+	for _, folder := range append([]string{"INBOX"}, folders...) {
+
+		err = maildir.Dir(mailbox.FolderPath(folder)).Create()
+		assert.Nilf(t, err, "expected Maildir of %s to be created but got: %v", folder, err)
+
+		err = mailbox.Structure.Add(folder, "", func(args ...string) {})
+		assert.Nilf(t, err, "expected %s to be added to structure but got: %v", folder, err)
+
+		mailbox.Mails[folder] = make([]string, 0)
+	}
+
+	return mailbox, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+// addTestMail stores content as mail with supplied
+// key and flags in folder of mailbox, dated to
+// internalDate, adds it to the structure CRDT,
+// and assigns it the next UID.
+func addTestMail(t *testing.T, mailbox *Mailbox, folder string, key string, flags string, internalDate time.Time, content string) string {
+
+	mailFileName := fmt.Sprintf("%s:2,%s", key, flags)
+	mailFilePath := filepath.Join(mailbox.FolderPath(folder), "cur", mailFileName)
+
+	err := ioutil.WriteFile(mailFilePath, []byte(content), 0600)
+	assert.Nilf(t, err, "expected mail %s to be written but got: %v", key, err)
+
+	err = os.Chtimes(mailFilePath, internalDate, internalDate)
+	assert.Nilf(t, err, "expected internal date of %s to be set but got: %v", key, err)
+
+	err = mailbox.Structure.Add(folder, mailFileName, func(args ...string) {})
+	assert.Nilf(t, err, "expected mail %s to be added to structure but got: %v", key, err)
+
+	mailbox.Mails[folder] = append(mailbox.Mails[folder], mailFileName)
+
+	err = mailbox.TrackMails(folder, true)
+	assert.Nilf(t, err, "expected mail %s to be assigned a UID but got: %v", key, err)
+
+	return mailFileName
+}
+
+// TestSearch executes a black-box table test on
+// SEARCH with the search key grammar of RFC 3501.
+func TestSearch(t *testing.T) {
+
+	mailbox, cleanup := newTestMailbox(t)
+	defer cleanup()
+
+	// Expunge the first mail so that UIDs
+	// differ from message sequence numbers.
+	expunged := addTestMail(t, mailbox, "INBOX", "1400000000.x", "", time.Now(), "Subject: Gone\r\n\r\n")
+	os.Remove(filepath.Join(mailbox.FolderPath("INBOX"), "cur", expunged))
+	mailbox.Mails["INBOX"] = mailbox.Mails["INBOX"][:0]
+
+	for _, mail := range searchMails {
+		addTestMail(t, mailbox, "INBOX", mail.key, mail.flags, mail.internalDate, mail.content)
+	}
+
+	s := &Session{
+		State:           StateMailbox,
+		SelectedMailbox: "INBOX",
+	}
+
+	for _, test := range searchTests {
+
+		req, err := ParseRequest(fmt.Sprintf("a1 SEARCH %s", test.query))
+		if err != nil {
+			assert.Equalf(t, "BAD", test.result, "expected SEARCH %s to be parsed but got: %v", test.query, err)
+			continue
+		}
+
+		reply, err := mailbox.Search(s, req, nil)
+		assert.Nilf(t, err, "expected SEARCH %s not to fail but got: %v", test.query, err)
+
+		switch test.result {
+
+		case "BAD", "NO":
+			assert.Truef(t, strings.HasPrefix(reply.Text, fmt.Sprintf("a1 %s ", test.result)), "expected SEARCH %s to be answered with %s but got: %s", test.query, test.result, reply.Text)
+
+		default:
+			expected := strings.TrimSpace(fmt.Sprintf("* SEARCH %s", test.result))
+			assert.Equalf(t, fmt.Sprintf("%s\r\na1 OK SEARCH completed", expected), reply.Text, "unexpected result of SEARCH %s", test.query)
+		}
+	}
+
+	// UID SEARCH reports UIDs instead
+	// of message sequence numbers.
+	req, _ := ParseRequest("a2 UID SEARCH UNSEEN")
+	reply, err := mailbox.UID(s, req, nil)
+	assert.Nilf(t, err, "expected UID SEARCH not to fail but got: %v", err)
+	assert.Equalf(t, "* SEARCH 3 4 5\r\na2 OK SEARCH completed", reply.Text, "unexpected result of UID SEARCH")
+
+	// Bodies are not kept in the index but read
+	// from disk, so a vanished mail is noticed.
+	os.Remove(filepath.Join(mailbox.FolderPath("INBOX"), "cur", fmt.Sprintf("%s:2,%s", searchMails[0].key, searchMails[0].flags)))
+
+	req, _ = ParseRequest("a3 SEARCH BODY monday")
+	reply, err = mailbox.Search(s, req, nil)
+	assert.NotNilf(t, err, "expected SEARCH BODY on vanished mail to fail")
+	assert.Equalf(t, uint32(1), reply.Status, "expected connection to be closed")
+
+	req, _ = ParseRequest("a4 SEARCH SUBJECT report")
+	reply, err = mailbox.Search(s, req, nil)
+	assert.Nilf(t, err, "expected SEARCH SUBJECT to be answered from the index but got: %v", err)
+	assert.Equalf(t, "* SEARCH 4\r\na4 OK SEARCH completed", reply.Text, "unexpected result of SEARCH SUBJECT")
+}
@@ -0,0 +1,445 @@
+package imap
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"path/filepath"
+
+	"github.com/go-pluto/maildir"
+	"github.com/go-pluto/pluto/comm"
+)
+
+// Variables
+
+// StatusItems contains all status data items
+// supported by STATUS and LIST-STATUS.
+var StatusItems = map[string]bool{
+	"MESSAGES":      true,
+	"RECENT":        true,
+	"UIDNEXT":       true,
+	"UIDVALIDITY":   true,
+	"UNSEEN":        true,
+	"SIZE":          true,
+	"HIGHESTMODSEQ": true,
+}
+
+// Functions
+
+// Status returns the requested status items of any
+// existing mailbox without selecting it. Supported
+// items are MESSAGES, RECENT, UIDNEXT, UIDVALIDITY,
+// UNSEEN, SIZE, and HIGHESTMODSEQ.
+func (mailbox *Mailbox) Status(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+
+	if (s.State != StateAuthenticated) && (s.State != StateMailbox) {
+
+		// If connection was not in correct state when this
+		// command was executed, this is a client error.
+		// Send tagged BAD response.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command STATUS cannot be executed in this state", req.Tag),
+		}, nil
+	}
+
+	statusArgs := req.Args
+
+	var statusMailboxName string
+	ok := (len(statusArgs) == 2) && statusArgs[1].IsList()
+	if ok {
+		statusMailboxName, ok = s.mailboxName(statusArgs[0])
+	}
+
+	if !ok {
+
+		// If payload did not contain a mailbox and a
+		// parenthesized list of items, this is a client
+		// error. Return BAD statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command STATUS was not sent with a mailbox and a list of status items", req.Tag),
+		}, nil
+	}
+
+	statusMailbox := strings.TrimSuffix(statusMailboxName, mailbox.HierarchySeparator)
+	if strings.ToUpper(statusMailbox) == "INBOX" {
+		statusMailbox = "INBOX"
+	}
+
+	statusItems := make([]string, 0, len(statusArgs[1].Items))
+
+	for _, item := range statusArgs[1].Items {
+
+		statusItem := strings.ToUpper(item.Value)
+		if (item.Type != NodeAtom) || !StatusItems[statusItem] {
+
+			// If an unknown status item was requested,
+			// this is a client error. Return BAD statement.
+			return &Reply{
+				Text: fmt.Sprintf("%s BAD Unknown status item %s", req.Tag, item.Value),
+			}, nil
+		}
+
+		// Requesting HIGHESTMODSEQ enables CONDSTORE.
+		if statusItem == "HIGHESTMODSEQ" {
+			s.CondStore = true
+		}
+
+		statusItems = append(statusItems, statusItem)
+	}
+
+	mailbox.Lock.RLock()
+	defer mailbox.Lock.RUnlock()
+
+	if !mailbox.Structure.Lookup(statusMailbox) {
+
+		// If mailbox does not exist, this is
+		// a client error. Return NO statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s NO Cannot get status of folder that does not exist", req.Tag),
+		}, nil
+	}
+
+	statusAnswer, err := mailbox.folderStatus(s, statusMailbox, statusItems)
+	if err != nil {
+
+		return &Reply{
+			Text:   "* BAD Internal server error, sorry. Closing connection.",
+			Status: 1,
+		}, err
+	}
+
+	return &Reply{
+		Text: fmt.Sprintf("%s\r\n%s OK STATUS completed", statusAnswer, req.Tag),
+	}, nil
+}
+
+// folderStatus returns the untagged STATUS response
+// for the supplied, already validated status items
+// of an existing folder. It is shared by STATUS and
+// LIST-STATUS. The caller is required to hold the lock.
+func (mailbox *Mailbox) folderStatus(s *Session, folder string, statusItems []string) (string, error) {
+
+	statusMaildir := maildir.Dir(mailbox.FolderPath(folder))
+
+	// Count unseen mails and sum up sizes. Same as
+	// SELECT, we count unseen mails as recent ones.
+	unseenMails := 0
+	size := int64(0)
+
+	for _, mail := range mailbox.Mails[folder] {
+
+		mailFlags, err := statusMaildir.Flags(mail, false)
+		if err != nil {
+			return "", fmt.Errorf("error while retrieving flags for mail: %v", err)
+		}
+
+		if !strings.ContainsRune(mailFlags, 'S') {
+			unseenMails++
+		}
+
+		info, err := os.Stat(filepath.Join(string(statusMaildir), "cur", mail))
+		if err != nil {
+			return "", fmt.Errorf("error while retrieving size of mail: %v", err)
+		}
+
+		size += info.Size()
+	}
+
+	answerItems := make([]string, 0, len(statusItems))
+
+	for _, statusItem := range statusItems {
+
+		switch statusItem {
+		case "MESSAGES":
+			answerItems = append(answerItems, fmt.Sprintf("MESSAGES %d", len(mailbox.Mails[folder])))
+		case "RECENT":
+			answerItems = append(answerItems, "RECENT 0")
+		case "UIDNEXT":
+			answerItems = append(answerItems, fmt.Sprintf("UIDNEXT %d", mailbox.UIDs.Next(folder)))
+		case "UIDVALIDITY":
+			answerItems = append(answerItems, fmt.Sprintf("UIDVALIDITY %d", mailbox.UIDs.Validity(folder)))
+		case "UNSEEN":
+			answerItems = append(answerItems, fmt.Sprintf("UNSEEN %d", unseenMails))
+		case "SIZE":
+			answerItems = append(answerItems, fmt.Sprintf("SIZE %d", size))
+		case "HIGHESTMODSEQ":
+			answerItems = append(answerItems, fmt.Sprintf("HIGHESTMODSEQ %d", mailbox.ModSeqs.Highest(folder)))
+		}
+	}
+
+	return fmt.Sprintf("* STATUS %s (%s)", s.quoteMailbox(folder), strings.Join(answerItems, " ")), nil
+}
+
+// Close permanently removes all mails flagged as Deleted
+// from the selected mailbox, without sending untagged
+// EXPUNGE responses, and returns to authenticated state.
+// Mailboxes selected read-only are left untouched.
+func (mailbox *Mailbox) Close(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+
+	if s.State != StateMailbox {
+
+		// If connection was not in correct state when this
+		// command was executed, this is a client error.
+		// Send tagged BAD response.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD No mailbox selected to close", req.Tag),
+		}, nil
+	}
+
+	if len(req.Payload) > 0 {
+
+		// If payload was not empty to CLOSE command,
+		// this is a client error. Return BAD statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command CLOSE was sent with extra parameters", req.Tag),
+		}, nil
+	}
+
+	if !s.ReadOnly {
+
+		// Expunge as usual but drop the answer.
+		reply, err := mailbox.expunge(s, &Request{Tag: req.Tag}, false, syncChan)
+		if err != nil {
+			return reply, err
+		}
+	}
+
+	s.unselect()
+
+	return &Reply{
+		Text: fmt.Sprintf("%s OK CLOSE completed", req.Tag),
+	}, nil
+}
+
+// Unselect returns to authenticated state without
+// removing any mails from the selected mailbox.
+func (mailbox *Mailbox) Unselect(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+
+	if s.State != StateMailbox {
+
+		// If connection was not in correct state when this
+		// command was executed, this is a client error.
+		// Send tagged BAD response.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD No mailbox selected to unselect", req.Tag),
+		}, nil
+	}
+
+	if len(req.Payload) > 0 {
+
+		// If payload was not empty to UNSELECT command,
+		// this is a client error. Return BAD statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command UNSELECT was sent with extra parameters", req.Tag),
+		}, nil
+	}
+
+	s.unselect()
+
+	return &Reply{
+		Text: fmt.Sprintf("%s OK UNSELECT completed", req.Tag),
+	}, nil
+}
+
+// Check requests a checkpoint of the selected mailbox.
+// As all changes are written to stable storage as soon
+// as they are made, there is nothing left to do.
+func (mailbox *Mailbox) Check(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+
+	if s.State != StateMailbox {
+
+		// If connection was not in correct state when this
+		// command was executed, this is a client error.
+		// Send tagged BAD response.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD No mailbox selected to check", req.Tag),
+		}, nil
+	}
+
+	if len(req.Payload) > 0 {
+
+		// If payload was not empty to CHECK command,
+		// this is a client error. Return BAD statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command CHECK was sent with extra parameters", req.Tag),
+		}, nil
+	}
+
+	return &Reply{
+		Text: fmt.Sprintf("%s OK CHECK completed", req.Tag),
+	}, nil
+}
+
+// Noop does nothing by itself but, if a mailbox is
+// selected, informs the client about all changes to
+// it since the client last learned about its state.
+// This includes changes by other sessions as well as
+// downstream updates from other replicas.
+func (mailbox *Mailbox) Noop(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+
+	if len(req.Payload) > 0 {
+
+		// If payload was not empty to NOOP command,
+		// this is a client error. Return BAD statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command NOOP was sent with extra parameters", req.Tag),
+		}, nil
+	}
+
+	answerLines := make([]string, 0, 2)
+
+	if s.State == StateMailbox {
+
+		mailbox.Lock.RLock()
+		answerLines = append(answerLines, mailbox.pendingUpdates(s)...)
+		mailbox.Lock.RUnlock()
+	}
+
+	answerLines = append(answerLines, fmt.Sprintf("%s OK NOOP completed", req.Tag))
+
+	return &Reply{
+		Text: strings.Join(answerLines, "\r\n"),
+	}, nil
+}
+
+// pendingUpdates compares the mails of the selected
+// mailbox known to the client with the current ones
+// and returns untagged EXPUNGE (or VANISHED), FETCH,
+// and EXISTS responses describing the difference. Afterwards,
+// the client is considered to know the current state.
+// The caller is required to hold the lock.
+func (mailbox *Mailbox) pendingUpdates(s *Session) []string {
+
+	mails := mailbox.Mails[s.SelectedMailbox]
+	answerLines := make([]string, 0, 2)
+
+	// Map each current mail's key to its file
+	// name, which contains the mail's flags.
+	current := make(map[string]string)
+	for _, mail := range mails {
+		current[MailKey(mail)] = mail
+	}
+
+	// Report removed mails in descending order so
+	// that sequence numbers stay valid for the client.
+	expMailNums := make([]int, 0)
+	expUIDs := make([]uint32, 0)
+	for i := (len(s.KnownMails) - 1); i >= 0; i-- {
+
+		if _, found := current[MailKey(s.KnownMails[i])]; !found {
+			expMailNums = append(expMailNums, i)
+			expUIDs = append(expUIDs, mailbox.mailUID(s.SelectedMailbox, s.KnownMails[i]))
+			s.KnownMails = append(s.KnownMails[:i], s.KnownMails[(i+1):]...)
+		}
+	}
+	answerLines = append(answerLines, expungeResponse(s, expMailNums, expUIDs)...)
+
+	// Report mails whose flags have changed.
+	for i, knownMail := range s.KnownMails {
+
+		mail := current[MailKey(knownMail)]
+		if mail == knownMail {
+			continue
+		}
+
+		mailFlags, err := maildir.Dir(mailbox.FolderPath(s.SelectedMailbox)).Flags(mail, false)
+		if err != nil {
+			continue
+		}
+
+		answerLines = append(answerLines, mailbox.flagsFetch(s, i, mail, mailFlags, false))
+	}
+
+	if len(mails) != len(s.KnownMails) {
+		answerLines = append(answerLines, fmt.Sprintf("* %d EXISTS", len(mails)))
+	}
+
+	s.KnownMails = append(s.KnownMails[:0], mails...)
+
+	return answerLines
+}
+
+// unselect returns the session to authenticated state.
+func (s *Session) unselect() {
+
+	s.State = StateAuthenticated
+	s.SelectedMailbox = ""
+	s.ReadOnly = false
+	s.KnownMails = nil
+}
+
+// AddSession registers a session of the user
+// so that changes to the folder it selected
+// can be applied to it.
+func (mailbox *Mailbox) AddSession(s *Session) {
+
+	mailbox.Lock.Lock()
+	mailbox.Sessions[s] = true
+	mailbox.Lock.Unlock()
+}
+
+// RemoveSession unregisters a session of the
+// user after its connection was closed.
+func (mailbox *Mailbox) RemoveSession(s *Session) {
+
+	mailbox.Lock.Lock()
+	delete(mailbox.Sessions, s)
+	mailbox.Lock.Unlock()
+}
+
+// unselectRenamed returns all sessions that selected
+// folder to authenticated state after it was renamed.
+// The renamed folder has a new UIDVALIDITY and its
+// mails new keys, thus the sessions cannot follow it
+// without invalidating the UIDs their clients know.
+// Folders still present, such as INBOX, keep their
+// sessions. The caller is required to hold the lock.
+func (mailbox *Mailbox) unselectRenamed(folder string) {
+
+	if mailbox.Structure.Lookup(folder) {
+		return
+	}
+
+	for s := range mailbox.Sessions {
+
+		if (s.State == StateMailbox) && (s.SelectedMailbox == folder) {
+			s.unselect()
+		}
+	}
+}
+
+// renameKnownMail replaces a mail file name the client
+// knows about after this session changed its flags and
+// reported the change to the client.
+func (s *Session) renameKnownMail(mailFileName string, newMailFileName string) {
+
+	for i, knownMail := range s.KnownMails {
+
+		if knownMail == mailFileName {
+			s.KnownMails[i] = newMailFileName
+			return
+		}
+	}
+}
+
+// forgetKnownMail drops a mail file name the client knows
+// about after this session removed the mail and reported
+// the removal to the client.
+func (s *Session) forgetKnownMail(mailFileName string) {
+
+	for i, knownMail := range s.KnownMails {
+
+		if knownMail == mailFileName {
+			s.KnownMails = append(s.KnownMails[:i], s.KnownMails[(i+1):]...)
+			return
+		}
+	}
+}
+
+// init registers the capabilities of
+// UNSELECT and STATUS=SIZE.
+func init() {
+	RegisterCapability(&Capability{Name: "UNSELECT", PostAuth: true, Commands: []string{CommandUnselect}})
+	RegisterCapability(&Capability{Name: "STATUS=SIZE", PostAuth: true})
+}
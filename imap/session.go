@@ -1,6 +1,8 @@
 package imap
 
 import (
+	"time"
+
 	"github.com/go-pluto/maildir"
 	"github.com/go-pluto/pluto/comm"
 )
@@ -24,7 +26,15 @@ type State int
 
 // Session contains all elements needed for tracking
 // and performing the actual IMAP operations for an
-// authenticated client.
+// authenticated client. KnownMails holds the mail file
+// names of the selected mailbox as last reported to the
+// client, which allows to inform it about changes made
+// by other sessions and replicas. UTF8Accept is set
+// once the client enabled UTF8=ACCEPT (RFC 6855) and
+// mailbox names are exchanged in UTF-8 instead of
+// modified UTF-7. CondStore and QResync are set once
+// the client enabled the respective extension of
+// RFC 7162, which changes the responses it receives.
 type Session struct {
 	State             State
 	ClientID          string
@@ -32,15 +42,33 @@ type Session struct {
 	RespWorker        string
 	StorageSubnetChan chan comm.Msg
 	SelectedMailbox   string
+	ReadOnly          bool
+	KnownMails        []string
 	AppendInProg      *AppendInProg
+	UTF8Accept        bool
+	CondStore         bool
+	QResync           bool
 }
 
 // AppendInProg captures the important environment
 // characteristics handed from AppendBegin to AppendEnd.
+// Messages holds all messages of a MULTIAPPEND that
+// were received completely, Pending the message whose
+// literal data is awaited next.
 type AppendInProg struct {
-	Tag         string
-	Mailbox     string
-	Maildir     maildir.Dir
-	FlagsRaw    string
-	DateTimeRaw string
+	Tag      string
+	Mailbox  string
+	Maildir  maildir.Dir
+	Messages []*AppendMessage
+	Pending  *AppendMessage
+}
+
+// AppendMessage is one message to be appended. Flags
+// holds the Maildir flag characters to store the message
+// with, a zero InternalDate stands for the time of the
+// delivery.
+type AppendMessage struct {
+	Flags        string
+	InternalDate time.Time
+	Content      []byte
 }
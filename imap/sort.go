@@ -0,0 +1,381 @@
+package imap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-pluto/pluto/comm"
+)
+
+// Variables
+
+// sortKeys contains all sort keys
+// defined by RFC 5256.
+var sortKeys = map[string]bool{
+	"ARRIVAL": true,
+	"CC":      true,
+	"DATE":    true,
+	"FROM":    true,
+	"SIZE":    true,
+	"SUBJECT": true,
+	"TO":      true,
+}
+
+// Structs
+
+// sortCriterion is one sort key of a SORT command.
+// If reverse is true, the key's order is reversed.
+type sortCriterion struct {
+	key     string
+	reverse bool
+}
+
+// Functions
+
+// parseSortCriteria parses the parenthesized list
+// of sort criteria of a SORT command.
+func parseSortCriteria(arg *Node) ([]sortCriterion, error) {
+
+	if !arg.IsList() || (len(arg.Items) == 0) {
+		return nil, fmt.Errorf("Command SORT was sent without sort criteria")
+	}
+
+	criteria := make([]sortCriterion, 0, len(arg.Items))
+	reverse := false
+
+	for _, item := range arg.Items {
+
+		if item.Type != NodeAtom {
+			return nil, fmt.Errorf("Command SORT was sent with invalid sort criteria")
+		}
+
+		key := strings.ToUpper(item.Value)
+
+		if key == "REVERSE" {
+
+			if reverse {
+				return nil, fmt.Errorf("Command SORT was sent with invalid sort criteria")
+			}
+
+			reverse = true
+			continue
+		}
+
+		if !sortKeys[key] {
+			return nil, fmt.Errorf("Command SORT was sent with unknown sort key %s", item.Value)
+		}
+
+		criteria = append(criteria, sortCriterion{
+			key:     key,
+			reverse: reverse,
+		})
+		reverse = false
+	}
+
+	// REVERSE has to be followed by a key.
+	if reverse {
+		return nil, fmt.Errorf("Command SORT was sent with invalid sort criteria")
+	}
+
+	return criteria, nil
+}
+
+// skipSubjectBlob removes a leading subj-blob of RFC
+// 5256, a bracketed text such as a list name, and the
+// whitespace following it from subject.
+func skipSubjectBlob(subject string) (string, bool) {
+
+	if !strings.HasPrefix(subject, "[") {
+		return subject, false
+	}
+
+	end := strings.IndexAny(subject[1:], "[]")
+	if (end < 0) || (subject[(end+1)] != ']') {
+		return subject, false
+	}
+
+	return strings.TrimLeft(subject[(end+2):], " "), true
+}
+
+// skipSubjectRefwd removes a leading subj-refwd of
+// RFC 5256, e.g. "re:" or "fwd [list]:", from subject.
+func skipSubjectRefwd(subject string) (string, bool) {
+
+	var rest string
+
+	switch {
+	case strings.HasPrefix(subject, "re"):
+		rest = subject[2:]
+	case strings.HasPrefix(subject, "fwd"):
+		rest = subject[3:]
+	case strings.HasPrefix(subject, "fw"):
+		rest = subject[2:]
+	default:
+		return subject, false
+	}
+
+	rest = strings.TrimLeft(rest, " ")
+	rest, _ = skipSubjectBlob(rest)
+
+	if !strings.HasPrefix(rest, ":") {
+		return subject, false
+	}
+
+	return rest[1:], true
+}
+
+// skipSubjectLeader removes a leading subj-leader of
+// RFC 5256, any number of subj-blobs followed by a
+// subj-refwd, from subject.
+func skipSubjectLeader(subject string) (string, bool) {
+
+	rest := subject
+
+	for {
+
+		if trimmed, ok := skipSubjectRefwd(rest); ok {
+			return trimmed, true
+		}
+
+		trimmed, ok := skipSubjectBlob(rest)
+		if !ok {
+			return subject, false
+		}
+
+		rest = trimmed
+	}
+}
+
+// baseSubject extracts the base subject of RFC 5256
+// section 2.1 from a decoded and lower-cased subject.
+// The returned flag is true if the subject indicated
+// a reply or forwarded message.
+func baseSubject(subject string) (string, bool) {
+
+	isReply := false
+
+	// Reduce all whitespace to single spaces.
+	base := strings.Join(strings.Fields(subject), " ")
+
+	for {
+
+		// Remove trailing "(fwd)" markers.
+		for strings.HasSuffix(base, "(fwd)") {
+			base = strings.TrimRight(strings.TrimSuffix(base, "(fwd)"), " ")
+			isReply = true
+		}
+
+		// Remove leading "re:" and similar as well
+		// as bracketed texts, as long as something
+		// remains of the subject.
+		for {
+
+			prev := base
+
+			if trimmed, ok := skipSubjectLeader(base); ok {
+				base = strings.TrimLeft(trimmed, " ")
+				isReply = true
+			}
+
+			if trimmed, ok := skipSubjectBlob(base); ok && (trimmed != "") {
+				base = trimmed
+			}
+
+			if base == prev {
+				break
+			}
+		}
+
+		// Unwrap subjects of the form "[fwd: ...]"
+		// and start over with the enclosed one.
+		if strings.HasPrefix(base, "[fwd:") && strings.HasSuffix(base, "]") {
+			base = strings.TrimSpace(base[5:(len(base) - 1)])
+			isReply = true
+			continue
+		}
+
+		return base, isReply
+	}
+}
+
+// compareTimes compares two points in time
+// in the way compareByKey compares keys.
+func compareTimes(a time.Time, b time.Time) int {
+
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+
+	return 0
+}
+
+// compareByKey compares two messages by supplied sort
+// key and returns a negative number if a sorts before
+// b, a positive one if after b, and zero otherwise.
+func compareByKey(a *searchMsg, b *searchMsg, key string) int {
+
+	switch key {
+
+	case "ARRIVAL":
+		return compareTimes(a.entry.InternalDate, b.entry.InternalDate)
+
+	case "DATE":
+		return compareTimes(a.entry.SortDate(), b.entry.SortDate())
+
+	case "SIZE":
+
+		switch {
+		case a.entry.Size < b.entry.Size:
+			return -1
+		case a.entry.Size > b.entry.Size:
+			return 1
+		}
+
+		return 0
+
+	case "SUBJECT":
+		return strings.Compare(a.entry.BaseSubject, b.entry.BaseSubject)
+
+	case "FROM":
+		return strings.Compare(a.entry.SortFrom, b.entry.SortFrom)
+
+	case "TO":
+		return strings.Compare(a.entry.SortTo, b.entry.SortTo)
+
+	case "CC":
+		return strings.Compare(a.entry.SortCc, b.entry.SortCc)
+	}
+
+	return 0
+}
+
+// sortMsgs sorts msgs by supplied criteria. Messages
+// equal in all criteria keep their sequence order.
+func sortMsgs(msgs []*searchMsg, criteria []sortCriterion) {
+
+	sort.SliceStable(msgs, func(i, j int) bool {
+
+		for _, criterion := range criteria {
+
+			cmp := compareByKey(msgs[i], msgs[j], criterion.key)
+			if criterion.reverse {
+				cmp = -cmp
+			}
+
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+
+		return msgs[i].seqNum < msgs[j].seqNum
+	})
+}
+
+// searchSortArgs evaluates the charset and the search
+// keys following the first argument of a SORT or THREAD
+// request against the selected mailbox. If they are not
+// valid, the reply to send is returned instead of the
+// matching messages. The caller is required to hold
+// the exclusive lock.
+func (mailbox *Mailbox) searchSortArgs(s *Session, req *Request, command string) ([]*searchMsg, *searchParser, *Reply, error) {
+
+	if (len(req.Args) < 3) || req.Args[1].IsList() {
+
+		// A charset and at least one search key
+		// are required, this is a client error.
+		return nil, nil, &Reply{
+			Text: fmt.Sprintf("%s BAD Command %s was sent with invalid number of parameters", req.Tag, command),
+		}, nil
+	}
+
+	if !supportedCharset(req.Args[1].Value) {
+
+		return nil, nil, &Reply{
+			Text: fmt.Sprintf("%s NO [BADCHARSET (US-ASCII UTF-8)] Charset not supported", req.Tag),
+		}, nil
+	}
+
+	parser, matcher, err := mailbox.compileSearch(s, command, searchTokens(req.Args[2:]))
+	if err != nil {
+
+		return nil, nil, &Reply{
+			Text: fmt.Sprintf("%s BAD %s", req.Tag, err.Error()),
+		}, nil
+	}
+
+	matches, err := mailbox.matchMails(s, parser, matcher, true)
+	if err != nil {
+
+		return nil, nil, &Reply{
+			Text:   "* BAD Internal server error, sorry. Closing connection.",
+			Status: 1,
+		}, err
+	}
+
+	return matches, parser, nil, nil
+}
+
+// Sort returns the message sequence numbers of all
+// messages in the selected mailbox matching supplied
+// search keys ordered by supplied sort criteria.
+func (mailbox *Mailbox) Sort(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+	return mailbox.sort(s, req, false)
+}
+
+// sort implements SORT and UID SORT as defined by RFC
+// 5256. Sort keys are taken from the message index. If
+// useUID is true, UIDs are returned instead of message
+// sequence numbers.
+func (mailbox *Mailbox) sort(s *Session, req *Request, useUID bool) (*Reply, error) {
+
+	if s.State != StateMailbox {
+
+		// If connection was not in correct state when this
+		// command was executed, this is a client error.
+		// Send tagged BAD response.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD No mailbox selected to sort", req.Tag),
+		}, nil
+	}
+
+	if len(req.Args) == 0 {
+
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command SORT was sent with invalid number of parameters", req.Tag),
+		}, nil
+	}
+
+	criteria, err := parseSortCriteria(req.Args[0])
+	if err != nil {
+
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD %s", req.Tag, err.Error()),
+		}, nil
+	}
+
+	// Lock node exclusively as evaluating keys
+	// may add missing entries to the index.
+	mailbox.Lock.Lock()
+	defer mailbox.Lock.Unlock()
+
+	matches, parser, reply, err := mailbox.searchSortArgs(s, req, CommandSort)
+	if reply != nil {
+		return reply, err
+	}
+
+	sortMsgs(matches, criteria)
+
+	return &Reply{
+		Text: fmt.Sprintf("%s\r\n%s OK SORT completed", searchResponse("SORT", matches, useUID, parser.usesModSeq), req.Tag),
+	}, nil
+}
+
+// init registers the SORT capability.
+func init() {
+	RegisterCapability(&Capability{Name: "SORT", PostAuth: true, Commands: []string{CommandSort}})
+}
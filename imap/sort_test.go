@@ -0,0 +1,76 @@
+package imap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Variables
+
+var baseSubjectTests = []struct {
+	subject string
+	base    string
+	isReply bool
+}{
+	{"meeting", "meeting", false},
+	{"  weekly   \tmeeting ", "weekly meeting", false},
+	{"re: meeting", "meeting", true},
+	{"RE: Re: fwd: meeting", "meeting", true},
+	{"re[2]: meeting", "meeting", true},
+	{"[pluto-dev] re: meeting", "meeting", true},
+	{"[pluto-dev] meeting", "meeting", false},
+	{"[pluto-dev]", "[pluto-dev]", false},
+	{"meeting (fwd)", "meeting", true},
+	{"[fwd: re: meeting]", "meeting", true},
+	{"re: [fwd: meeting] (fwd)", "meeting", true},
+	{"regarding: meeting", "regarding: meeting", false},
+	{"", "", false},
+}
+
+var sortCriteriaTests = []struct {
+	payload  string
+	criteria []sortCriterion
+	fails    bool
+}{
+	{"(DATE)", []sortCriterion{{key: "DATE"}}, false},
+	{"(reverse arrival subject)", []sortCriterion{{key: "ARRIVAL", reverse: true}, {key: "SUBJECT"}}, false},
+	{"(FROM REVERSE SIZE CC TO)", []sortCriterion{{key: "FROM"}, {key: "SIZE", reverse: true}, {key: "CC"}, {key: "TO"}}, false},
+	{"()", nil, true},
+	{"DATE", nil, true},
+	{"(REVERSE)", nil, true},
+	{"(REVERSE REVERSE DATE)", nil, true},
+	{"(DISPLAYFROM)", nil, true},
+	{"(\"DATE\")", nil, true},
+}
+
+// Functions
+
+// TestBaseSubject executes a white-box table
+// test on implemented baseSubject() function.
+func TestBaseSubject(t *testing.T) {
+
+	for _, test := range baseSubjectTests {
+
+		// Subjects are lower-cased by the index.
+		base, isReply := baseSubject(strings.ToLower(test.subject))
+		assert.Equalf(t, test.base, base, "unexpected base subject of %q", test.subject)
+		assert.Equalf(t, test.isReply, isReply, "unexpected reply indication of %q", test.subject)
+	}
+}
+
+// TestParseSortCriteria executes a white-box table
+// test on implemented parseSortCriteria() function.
+func TestParseSortCriteria(t *testing.T) {
+
+	for _, test := range sortCriteriaTests {
+
+		args, err := ParseArgs(test.payload)
+		assert.Nilf(t, err, "failed to parse arguments %q: %v", test.payload, err)
+
+		criteria, err := parseSortCriteria(args[0])
+		assert.Equalf(t, test.fails, err != nil, "unexpected error for %q: %v", test.payload, err)
+		assert.Equalf(t, test.criteria, criteria, "unexpected sort criteria for %q", test.payload)
+	}
+}
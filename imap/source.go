@@ -3,9 +3,10 @@ package imap
 import (
 	"fmt"
 	"os"
-	"strconv"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"io/ioutil"
 	"path/filepath"
@@ -22,19 +23,42 @@ import (
 // Mailbox represents the state of one user's
 // mailbox in the provided email service. It
 // serializes access for mutating state, contains
-// the structure OR-Set, keeps track of message
-// sequence numbers, and provides user-specific
-// path values in the file system.
+// the structure OR-Set, OR-Sets of subscribed
+// folders and of special-use attributes, the
+// UID and keyword CRDTs, and the modification
+// sequences of messages, keeps track of message
+// sequence numbers, holds the message index for
+// searches, all sessions of the user and those
+// waiting in IDLE for changes, and provides
+// user-specific path values in the file system.
 type Mailbox struct {
 	Logger             log.Logger
 	Lock               *sync.RWMutex
 	Structure          *crdt.ORSet
+	Subscriptions      *crdt.ORSet
+	SpecialUse         *crdt.ORSet
+	UIDs               *crdt.UIDSet
+	Keywords           *crdt.KeywordSet
+	ModSeqs            *crdt.ModSeqs
+	Index              *MessageIndex
+	Watchers           *Watchers
+	Sessions           map[*Session]bool
 	Mails              map[string][]string
 	CRDTPath           string
 	MaildirPath        string
 	HierarchySeparator string
 }
 
+// folderRename carries everything read from stable
+// storage to rename one folder: the mails' contents
+// and their new keys in order of the folder's mails.
+type folderRename struct {
+	folder    string
+	newFolder string
+	newKeys   []string
+	contents  [][]byte
+}
+
 // Functions
 
 // Select sets the current mailbox based on supplied
@@ -47,6 +71,23 @@ type Mailbox struct {
 // handling, this function would send a useful message to
 // the client and still return true.
 func (mailbox *Mailbox) Select(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+	return mailbox.selectMailbox(s, req, false)
+}
+
+// Examine selects a mailbox exactly like Select does
+// but marks it as read-only, so that no command in
+// this session is allowed to modify its contents.
+func (mailbox *Mailbox) Examine(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+	return mailbox.selectMailbox(s, req, true)
+}
+
+// selectMailbox implements SELECT and EXAMINE.
+func (mailbox *Mailbox) selectMailbox(s *Session, req *Request, readOnly bool) (*Reply, error) {
+
+	command := CommandSelect
+	if readOnly {
+		command = CommandExamine
+	}
 
 	if (s.State != StateAuthenticated) && (s.State != StateMailbox) {
 
@@ -54,40 +95,59 @@ func (mailbox *Mailbox) Select(s *Session, req *Request, syncChan chan comm.Msg)
 		// command was executed, this is a client error.
 		// Send tagged BAD response.
 		return &Reply{
-			Text: fmt.Sprintf("%s BAD Command SELECT cannot be executed in this state", req.Tag),
+			Text: fmt.Sprintf("%s BAD Command %s cannot be executed in this state", req.Tag, command),
 		}, nil
 	}
 
-	if len(req.Payload) < 1 {
+	if len(req.Args) < 1 {
 
 		// If no mailbox to select was specified in payload,
 		// this is a client error. Return BAD statement.
 		return &Reply{
-			Text: fmt.Sprintf("%s BAD Command SELECT was sent without a mailbox to select", req.Tag),
+			Text: fmt.Sprintf("%s BAD Command %s was sent without a mailbox to select", req.Tag, command),
 		}, nil
 	}
 
-	// Split payload on every whitespace character.
-	reqMailboxes := strings.Split(req.Payload, " ")
-
-	if len(reqMailboxes) != 1 {
+	if len(req.Args) > 2 {
 
 		// If there were more than two names supplied to select,
 		// this is a client error. Return BAD statement.
 		return &Reply{
-			Text: fmt.Sprintf("%s BAD Command SELECT was sent with multiple mailbox names instead of only one", req.Tag),
+			Text: fmt.Sprintf("%s BAD Command %s was sent with multiple mailbox names instead of only one", req.Tag, command),
 		}, nil
 	}
 
-	reqMailboxPath := mailbox.MaildirPath
+	// The mailbox name may be followed by a list of
+	// parameters, e.g. CONDSTORE or QRESYNC (RFC 7162).
+	params := &selectParams{}
+	if len(req.Args) == 2 {
+
+		var err error
+
+		params, err = parseSelectParams(s, req.Args[1])
+		if err != nil {
+
+			return &Reply{
+				Text: fmt.Sprintf("%s BAD Command %s was sent with %s", req.Tag, command, err.Error()),
+			}, nil
+		}
+	}
+
+	reqMailboxName, ok := s.mailboxName(req.Args[0])
+	if !ok {
+
+		// If the mailbox name was no string,
+		// this is a client error. Return BAD statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command %s was sent with an invalid mailbox name", req.Tag, command),
+		}, nil
+	}
 
-	// If any other mailbox than INBOX was specified,
-	// append it to mailbox in order to check it.
-	if reqMailboxes[0] != "INBOX" {
-		reqMailboxPath = filepath.Join(reqMailboxPath, reqMailboxes[0])
+	if strings.ToUpper(reqMailboxName) == "INBOX" {
+		reqMailboxName = "INBOX"
 	}
 
-	reqMailbox := maildir.Dir(reqMailboxPath)
+	reqMailbox := maildir.Dir(mailbox.FolderPath(reqMailboxName))
 
 	// Check if mailbox is existing and a correct maildir folder.
 	err := reqMailbox.Check()
@@ -96,22 +156,39 @@ func (mailbox *Mailbox) Select(s *Session, req *Request, syncChan chan comm.Msg)
 		// If specified maildir did not turn out to be a valid one,
 		// this is a client error. Return NO statement.
 		return &Reply{
-			Text: fmt.Sprintf("%s NO SELECT failure, not a valid Maildir folder", req.Tag),
+			Text: fmt.Sprintf("%s NO %s failure, not a valid Maildir folder", req.Tag, command),
 		}, nil
 	}
 
+	// Tell the client that the previously selected
+	// mailbox was closed, so that it can tell apart
+	// responses for both mailboxes (RFC 7162).
+	closed := ""
+	if s.State == StateMailbox {
+		closed = "* OK [CLOSED] Previous mailbox closed\r\n"
+	}
+
+	// Selecting with CONDSTORE enables the extension.
+	if params.condStore {
+		s.CondStore = true
+	}
+
 	// Set selected mailbox in connection to supplied one
 	// and advance IMAP state of connection to Mailbox.
 	s.State = StateMailbox
-	s.SelectedMailbox = reqMailboxes[0]
+	s.SelectedMailbox = reqMailboxName
+	s.ReadOnly = readOnly
 
 	mailbox.Lock.RLock()
 	defer mailbox.Lock.RUnlock()
 
-	// Count how many mails do not have the \Seen
-	// flag attached, i.e. recent mails.
-	recentMails := 0
-	for _, mail := range mailbox.Mails[s.SelectedMailbox] {
+	// Remember the mails the client learns about.
+	s.KnownMails = append([]string(nil), mailbox.Mails[s.SelectedMailbox]...)
+
+	// Pluto never sets the \Recent flag, hence no
+	// mail is reported as recent.
+	allFlags := make([]string, len(mailbox.Mails[s.SelectedMailbox]))
+	for i, mail := range mailbox.Mails[s.SelectedMailbox] {
 
 		// Retrieve flags of mail.
 		mailFlags, err := reqMailbox.Flags(mail, false)
@@ -125,17 +202,38 @@ func (mailbox *Mailbox) Select(s *Session, req *Request, syncChan chan comm.Msg)
 			}, fmt.Errorf("error while retrieving flags for mail: %v", err)
 		}
 
-		// Check if \Seen flag is present.
-		if strings.ContainsRune(mailFlags, 'S') != true {
-			recentMails++
+		allFlags[i] = mailFlags
+	}
+
+	// With QRESYNC, the client is told about all changes
+	// since the state it last knew of, unless the mailbox
+	// was recreated in the meantime.
+	resync := ""
+	if params.qresync && (params.uidValidity == mailbox.UIDs.Validity(s.SelectedMailbox)) {
+
+		resync, err = mailbox.resyncMailbox(s, params, allFlags)
+		if err != nil {
+
+			return &Reply{
+				Text: fmt.Sprintf("%s BAD Command %s was sent with %s", req.Tag, command, err.Error()),
+			}, nil
 		}
 	}
 
-	// TODO: Add other SELECT response elements if needed.
+	// In read-only mode, no flags can be changed
+	// permanently by the client.
+	permanentFlags := mailbox.permanentFlags()
+	access := "READ-WRITE"
+	if readOnly {
+		permanentFlags = ""
+		access = "READ-ONLY"
+	}
 
 	// Send answer to requesting client.
 	return &Reply{
-		Text: fmt.Sprintf("* %d EXISTS\r\n* %d RECENT\r\n* FLAGS (\\Answered \\Flagged \\Deleted \\Seen \\Draft)\r\n* OK [PERMANENTFLAGS (\\Answered \\Flagged \\Deleted \\Seen \\Draft)]\r\n%s OK [READ-WRITE] SELECT completed", len(mailbox.Mails), recentMails, req.Tag),
+		Text: fmt.Sprintf("%s* %d EXISTS\r\n* 0 RECENT\r\n* FLAGS (%s)\r\n* OK [PERMANENTFLAGS (%s)]\r\n* OK [UIDVALIDITY %d] UIDs valid\r\n* OK [UIDNEXT %d] Predicted next UID\r\n* OK [HIGHESTMODSEQ %d] Highest\r\n%s%s OK [%s] %s completed",
+			closed, len(mailbox.Mails[s.SelectedMailbox]), mailbox.flagsList(), permanentFlags, mailbox.UIDs.Validity(s.SelectedMailbox), mailbox.UIDs.Next(s.SelectedMailbox),
+			mailbox.ModSeqs.Highest(s.SelectedMailbox), resync, req.Tag, access, command),
 	}, nil
 }
 
@@ -153,13 +251,19 @@ func (mailbox *Mailbox) Create(s *Session, req *Request, syncChan chan comm.Msg)
 		}, nil
 	}
 
-	// Split payload on every space character.
-	createMailboxFolders := strings.Split(req.Payload, " ")
+	createArgs := req.Args
 
-	if len(createMailboxFolders) != 1 {
+	var createMailboxName string
+	ok := (len(createArgs) == 1) || ((len(createArgs) == 2) && createArgs[1].IsList())
+	if ok {
+		createMailboxName, ok = s.mailboxName(createArgs[0])
+	}
 
-		// If payload did not contain exactly one element,
-		// this is a client error. Return BAD statement.
+	if !ok {
+
+		// If payload did not contain a mailbox name and
+		// optionally a list of parameters, this is a
+		// client error. Return BAD statement.
 		return &Reply{
 			Text: fmt.Sprintf("%s BAD Command CREATE was not sent with exactly one parameter", req.Tag),
 		}, nil
@@ -167,7 +271,7 @@ func (mailbox *Mailbox) Create(s *Session, req *Request, syncChan chan comm.Msg)
 
 	// Trim supplied mailbox folder name of hierarchy
 	// separator if it was sent with a trailing one.
-	createMailboxFolder := strings.TrimSuffix(createMailboxFolders[0], mailbox.HierarchySeparator)
+	createMailboxFolder := strings.TrimSuffix(createMailboxName, mailbox.HierarchySeparator)
 
 	if strings.ToUpper(createMailboxFolder) == "INBOX" {
 
@@ -178,7 +282,66 @@ func (mailbox *Mailbox) Create(s *Session, req *Request, syncChan chan comm.Msg)
 		}, nil
 	}
 
-	createMaildir := maildir.Dir(filepath.Join(mailbox.MaildirPath, createMailboxFolder))
+	if !mailbox.validFolderName(createMailboxFolder) {
+
+		// If mailbox folder to-be-created contained empty
+		// levels of hierarchy or control characters, this
+		// is a client error. Return NO response.
+		return &Reply{
+			Text: fmt.Sprintf("%s NO [CANNOT] New mailbox name is not allowed", req.Tag),
+		}, nil
+	}
+
+	// Collect special-use attributes requested
+	// via CREATE-SPECIAL-USE (RFC 6154).
+	var specialUses []string
+
+	if len(createArgs) == 2 {
+
+		createParams := createArgs[1].Items
+
+		if (len(createParams) != 2) || (createParams[0].Type != NodeAtom) || (strings.ToUpper(createParams[0].Value) != "USE") || !createParams[1].IsList() {
+
+			return &Reply{
+				Text: fmt.Sprintf("%s BAD Command CREATE was sent with unknown parameters", req.Tag),
+			}, nil
+		}
+
+		for _, use := range createParams[1].Items {
+
+			if use.Type != NodeAtom {
+
+				return &Reply{
+					Text: fmt.Sprintf("%s BAD Command CREATE was sent with invalid special-use attributes", req.Tag),
+				}, nil
+			}
+
+			// Attributes are case-insensitive, so
+			// look for the canonical spelling.
+			canonicalUse := ""
+			for specialUse := range SpecialUses {
+
+				if strings.EqualFold(specialUse, use.Value) {
+					canonicalUse = specialUse
+				}
+			}
+
+			if canonicalUse == "" {
+
+				// If attribute is unknown or not supported,
+				// this is a client error. Return NO response.
+				return &Reply{
+					Text: fmt.Sprintf("%s NO [USEATTR] Special-use attribute %s is not supported", req.Tag, use.Value),
+				}, nil
+			}
+
+			specialUses = append(specialUses, canonicalUse)
+		}
+	}
+
+	specialUse := strings.Join(specialUses, " ")
+
+	createMaildir := maildir.Dir(mailbox.FolderPath(createMailboxFolder))
 
 	// Lock node exclusively to make execution
 	// of following CRDT operations atomic.
@@ -194,6 +357,18 @@ func (mailbox *Mailbox) Create(s *Session, req *Request, syncChan chan comm.Msg)
 		}, nil
 	}
 
+	for _, use := range specialUses {
+
+		if usedFolder, found := mailbox.specialUseFolder(use); found {
+
+			// Each special use may only be assigned to
+			// one folder. Return NO response.
+			return &Reply{
+				Text: fmt.Sprintf("%s NO [USEATTR] Special-use attribute %s is already assigned to %s", req.Tag, use, s.quoteMailbox(usedFolder)),
+			}, nil
+		}
+	}
+
 	// Create a new Maildir on stable storage.
 	err := createMaildir.Create()
 	if err != nil {
@@ -208,15 +383,40 @@ func (mailbox *Mailbox) Create(s *Session, req *Request, syncChan chan comm.Msg)
 	// to track message sequence numbers in it.
 	mailbox.Mails[createMailboxFolder] = make([]string, 0, 6)
 
+	// Choose a UIDVALIDITY base for the new folder that is
+	// greater than any value a former folder of the same
+	// name might have exposed to clients.
+	uidValidity := uint32(time.Now().Unix())
+	if prevValidity := mailbox.UIDs.Validity(createMailboxFolder); prevValidity >= uidValidity {
+		uidValidity = prevValidity + 1
+	}
+
+	err = mailbox.UIDs.SetBaseEffect(createMailboxFolder, uidValidity, true)
+	if err != nil {
+
+		delete(mailbox.Mails, createMailboxFolder)
+
+		return &Reply{
+			Text:   "* BAD Internal server error, sorry. Closing connection.",
+			Status: 1,
+		}, fmt.Errorf("error while setting UIDVALIDITY of new mailbox: %v", err)
+	}
+
 	// Add the folder as new item to the user's structure CRDT
-	// and synchronize with other replicas.
+	// and synchronize with other replicas. Special-use
+	// attributes are bound to the folder's new tag.
 	err = mailbox.Structure.Add(createMailboxFolder, "", func(args ...string) {
+
+		mailbox.setSpecialUse(args[0], specialUse)
+
 		syncChan <- comm.Msg{
 			Operation: "create",
 			Create: &comm.Msg_CREATE{
-				User:    s.UserName,
-				Mailbox: createMailboxFolder,
-				AddTag:  args[0],
+				User:        s.UserName,
+				Mailbox:     createMailboxFolder,
+				AddTag:      args[0],
+				UidValidity: uidValidity,
+				SpecialUse:  specialUse,
 			},
 		}
 	})
@@ -262,10 +462,13 @@ func (mailbox *Mailbox) Delete(s *Session, req *Request, syncChan chan comm.Msg)
 		}, nil
 	}
 
-	// Split payload on every space character.
-	deleteMailboxFolders := strings.Split(req.Payload, " ")
+	var deleteMailboxName string
+	ok := len(req.Args) == 1
+	if ok {
+		deleteMailboxName, ok = s.mailboxName(req.Args[0])
+	}
 
-	if len(deleteMailboxFolders) != 1 {
+	if !ok {
 
 		// If payload did not contain exactly one element,
 		// this is a client error. Return BAD statement.
@@ -276,7 +479,7 @@ func (mailbox *Mailbox) Delete(s *Session, req *Request, syncChan chan comm.Msg)
 
 	// Trim supplied mailbox folder name of hierarchy
 	// separator if it was sent with a trailing one.
-	deleteMailboxFolder := strings.TrimSuffix(deleteMailboxFolders[0], mailbox.HierarchySeparator)
+	deleteMailboxFolder := strings.TrimSuffix(deleteMailboxName, mailbox.HierarchySeparator)
 
 	if strings.ToUpper(deleteMailboxFolder) == "INBOX" {
 
@@ -287,7 +490,9 @@ func (mailbox *Mailbox) Delete(s *Session, req *Request, syncChan chan comm.Msg)
 		}, nil
 	}
 
-	deleteMaildir := maildir.Dir(filepath.Join(mailbox.MaildirPath, deleteMailboxFolder))
+	deleteMaildir := maildir.Dir(mailbox.FolderPath(deleteMailboxFolder))
+
+	defer mailbox.notify()
 
 	// Lock node exclusively to make execution
 	// of following CRDT operations atomic.
@@ -304,7 +509,7 @@ func (mailbox *Mailbox) Delete(s *Session, req *Request, syncChan chan comm.Msg)
 
 	// Record mail message state of mailbox folder
 	// to delete in order to send it downstream.
-	files, err := ioutil.ReadDir(filepath.Join(mailbox.MaildirPath, deleteMailboxFolder, "cur"))
+	files, err := ioutil.ReadDir(filepath.Join(string(deleteMaildir), "cur"))
 	if err != nil {
 		return &Reply{
 			Text:   "* BAD Internal server error, sorry. Closing connection.",
@@ -327,6 +532,9 @@ func (mailbox *Mailbox) Delete(s *Session, req *Request, syncChan chan comm.Msg)
 	// Remove element from user's structure CRDT and send out
 	// remove update operations to all other replicas.
 	err = mailbox.Structure.Remove(deleteMailboxFolder, func(args ...string) {
+
+		mailbox.dropSpecialUse(args)
+
 		syncChan <- comm.Msg{
 			Operation: "delete",
 			Delete: &comm.Msg_DELETE{
@@ -350,6 +558,7 @@ func (mailbox *Mailbox) Delete(s *Session, req *Request, syncChan chan comm.Msg)
 	}
 
 	delete(mailbox.Mails, deleteMailboxFolder)
+	mailbox.Index.RemoveFolder(deleteMailboxFolder)
 
 	// Remove files associated with the deleted
 	// mailbox folder from stable storage.
@@ -368,9 +577,14 @@ func (mailbox *Mailbox) Delete(s *Session, req *Request, syncChan chan comm.Msg)
 	}, nil
 }
 
-// List allows clients to learn about the mailboxes
-// available and also returns the hierarchy delimiter.
-func (mailbox *Mailbox) List(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+// Rename changes the name of an existing mailbox and of
+// all mailboxes below it in the hierarchy. Mails are moved
+// to the new mailboxes under new names, which makes the
+// operation a combination of CREATE, MOVE, and DELETE in
+// terms of the structure CRDT. Renaming INBOX moves all
+// of its mails to the new mailbox and leaves INBOX empty
+// but present, its inferior mailboxes are not renamed.
+func (mailbox *Mailbox) Rename(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
 
 	if (s.State != StateAuthenticated) && (s.State != StateMailbox) {
 
@@ -378,308 +592,485 @@ func (mailbox *Mailbox) List(s *Session, req *Request, syncChan chan comm.Msg) (
 		// command was executed, this is a client error.
 		// Send tagged BAD response.
 		return &Reply{
-			Text: fmt.Sprintf("%s BAD Command LIST cannot be executed in this state", req.Tag),
+			Text: fmt.Sprintf("%s BAD Command RENAME cannot be executed in this state", req.Tag),
 		}, nil
 	}
 
-	// Split payload on every space character.
-	listArgs := strings.Split(req.Payload, " ")
+	var oldMailboxName, newMailboxName string
+	ok := len(req.Args) == 2
+	if ok {
+		oldMailboxName, ok = s.mailboxName(req.Args[0])
+	}
+	if ok {
+		newMailboxName, ok = s.mailboxName(req.Args[1])
+	}
 
-	if len(listArgs) != 2 {
+	if !ok {
 
-		// If payload did not contain between exactly two elements,
+		// If payload did not contain exactly two elements,
 		// this is a client error. Return BAD statement.
 		return &Reply{
-			Text: fmt.Sprintf("%s BAD Command LIST was not sent with exactly two arguments", req.Tag),
+			Text: fmt.Sprintf("%s BAD Command RENAME was not sent with exactly two parameters", req.Tag),
 		}, nil
 	}
 
-	if (listArgs[1] != "%") && (listArgs[1] != "*") {
+	// Trim supplied mailbox folder names of hierarchy
+	// separator if they were sent with a trailing one.
+	oldMailboxFolder := strings.TrimSuffix(oldMailboxName, mailbox.HierarchySeparator)
+	newMailboxFolder := strings.TrimSuffix(newMailboxName, mailbox.HierarchySeparator)
 
-		// If second argument is not one of two wildcards,
-		// this is a client error. Return BAD statement.
+	if strings.ToUpper(oldMailboxFolder) == "INBOX" {
+		oldMailboxFolder = "INBOX"
+	}
+
+	if strings.ToUpper(newMailboxFolder) == "INBOX" {
+
+		// If mailbox folder was to be renamed to INBOX,
+		// this is a client error. Return NO response.
 		return &Reply{
-			Text: fmt.Sprintf("%s BAD Command LIST needs either '%%' or '*' as mailbox name", req.Tag),
+			Text: fmt.Sprintf("%s NO New mailbox cannot be named INBOX", req.Tag),
 		}, nil
 	}
 
-	mailbox.Lock.RLock()
+	if !mailbox.validFolderName(newMailboxFolder) {
 
-	// Retrieve all distinct elements present in structure.
-	mailboxFolders := mailbox.Structure.GetAllValues()
+		// If new mailbox folder name contained empty
+		// levels of hierarchy or control characters, this
+		// is a client error. Return NO response.
+		return &Reply{
+			Text: fmt.Sprintf("%s NO [CANNOT] New mailbox name is not allowed", req.Tag),
+		}, nil
+	}
 
-	// Reserve space for answer.
-	listAnswerLines := make([]string, 0, (len(mailboxFolders) - 1))
+	if (oldMailboxFolder != "INBOX") && strings.HasPrefix(newMailboxFolder, (oldMailboxFolder+mailbox.HierarchySeparator)) {
 
-	for _, mailboxFolder := range mailboxFolders {
+		// A mailbox cannot become one of its own
+		// inferiors. Return NO response.
+		return &Reply{
+			Text: fmt.Sprintf("%s NO Mailbox cannot be renamed below itself", req.Tag),
+		}, nil
+	}
 
-		// Split currently considered mailbox folder
-		// name at defined hierarchy separator.
-		mailboxParts := strings.Split(mailboxFolder, mailbox.HierarchySeparator)
+	defer mailbox.notify()
 
-		if (listArgs[1] == "*") || (len(mailboxParts) == 1) {
+	// Lock node exclusively to make execution
+	// of following CRDT operations atomic.
+	mailbox.Lock.Lock()
+	defer mailbox.Lock.Unlock()
 
-			// Either always include a mailbox in the response
-			// or only when it is a top level mailbox.
-			listAnswerLines = append(listAnswerLines, fmt.Sprintf("* LIST () \"%s\" %s", mailbox.HierarchySeparator, mailboxFolder))
-		}
+	if !mailbox.Structure.Lookup(oldMailboxFolder) {
+		return &Reply{
+			Text: fmt.Sprintf("%s NO Cannot rename folder that does not exist", req.Tag),
+		}, nil
 	}
 
-	mailbox.Lock.RUnlock()
+	// Collect the mailbox folder to rename and all of its
+	// inferiors, except for INBOX whose inferiors stay.
+	renameFolders := []string{oldMailboxFolder}
 
-	var answer string
-	for _, listAnswerLine := range listAnswerLines {
+	if oldMailboxFolder != "INBOX" {
 
-		if answer == "" {
-			answer = fmt.Sprintf("%s\r\n", listAnswerLine)
-		} else {
-			answer = fmt.Sprintf("%s%s\r\n", answer, listAnswerLine)
+		for _, folder := range mailbox.Structure.GetAllValues() {
+
+			if strings.HasPrefix(folder, (oldMailboxFolder + mailbox.HierarchySeparator)) {
+				renameFolders = append(renameFolders, folder)
+			}
 		}
 	}
 
-	if answer == "" {
-		answer = fmt.Sprintf("%s OK LIST completed", req.Tag)
-	} else {
-		answer = fmt.Sprintf("%s%s OK LIST completed", answer, req.Tag)
+	for _, folder := range renameFolders {
+
+		if mailbox.Structure.Lookup(newMailboxFolder + strings.TrimPrefix(folder, oldMailboxFolder)) {
+
+			// If any of the new names already exists,
+			// this is a client error. Return NO response.
+			return &Reply{
+				Text: fmt.Sprintf("%s NO New mailbox cannot be named after already existing mailbox", req.Tag),
+			}, nil
+		}
 	}
 
-	return &Reply{
-		Text: answer,
-	}, nil
-}
+	// Read all mails and create all new Maildirs before
+	// changing any state, so that failing to do so leaves
+	// the mailbox untouched.
+	renames := make([]*folderRename, 0, len(renameFolders))
 
-// AppendBegin checks environment conditions and returns
-// a message specifying the awaited number of bytes.
-func (mailbox *Mailbox) AppendBegin(s *Session, req *Request) (*Await, error) {
+	for _, folder := range renameFolders {
 
-	if (s.State != StateAuthenticated) && (s.State != StateMailbox) {
+		rename, err := mailbox.prepareRename(folder, (newMailboxFolder + strings.TrimPrefix(folder, oldMailboxFolder)))
+		if err != nil {
 
-		// If connection was not in correct state when this
-		// command was executed, this is a client error.
-		// Send tagged BAD response.
-		return &Await{
-			Text: fmt.Sprintf("%s BAD Command APPEND cannot be executed in this state", req.Tag),
-		}, nil
-	}
+			for _, prepared := range renames {
 
-	// Split payload on every space character.
-	// TODO: Fix this for multiple (\Flag1 \Flag2) as parameter.
-	appendArgs := strings.Split(req.Payload, " ")
-	lenAppendArgs := len(appendArgs)
+				rmErr := maildir.Dir(mailbox.FolderPath(prepared.newFolder)).Remove()
+				if rmErr != nil {
+					level.Error(mailbox.Logger).Log(
+						"msg", "failed to remove created Maildir during clean up of failed source RENAME execution",
+						"err", rmErr,
+					)
+				}
+			}
 
-	if (lenAppendArgs < 2) || (lenAppendArgs > 4) {
+			return &Reply{
+				Text:   "* BAD Internal server error, sorry. Closing connection.",
+				Status: 1,
+			}, err
+		}
 
-		// If payload did not contain between two and four
-		// elements, this is a client error.
-		// Return BAD statement.
-		return &Await{
-			Text: fmt.Sprintf("%s BAD Command APPEND was not sent with appropriate number of parameters", req.Tag),
-		}, nil
+		renames = append(renames, rename)
 	}
 
-	// Make space for tracking environment characteristics
-	// for this command from AppendBegin to AppendEnd.
-	appendInProg := &AppendInProg{
-		Tag: req.Tag,
+	renameUpd := &comm.Msg_RENAME{
+		User:    s.UserName,
+		Folders: make([]*comm.Msg_RENAME_FOLDER, 0, len(renames)),
 	}
 
-	// Depending on amount of arguments, store them.
-	var numBytesRaw string
-	switch lenAppendArgs {
-
-	case 2:
-		appendInProg.Mailbox = appendArgs[0]
-		numBytesRaw = appendArgs[1]
-
-	case 3:
-		appendInProg.Mailbox = appendArgs[0]
-		appendInProg.FlagsRaw = appendArgs[1]
-		numBytesRaw = appendArgs[2]
+	for _, rename := range renames {
+		renameUpd.Folders = append(renameUpd.Folders, mailbox.renameFolder(rename))
+	}
 
-	case 4:
-		appendInProg.Mailbox = appendArgs[0]
-		appendInProg.FlagsRaw = appendArgs[1]
-		appendInProg.DateTimeRaw = appendArgs[2]
-		numBytesRaw = appendArgs[3]
+	// Synchronize all renamed folders with
+	// other replicas in one update.
+	syncChan <- comm.Msg{
+		Operation: "rename",
+		Rename:    renameUpd,
 	}
 
-	// If user specified INBOX, set it accordingly.
-	if strings.ToUpper(appendInProg.Mailbox) == "INBOX" {
-		appendInProg.Mailbox = "INBOX"
+	return &Reply{
+		Text: fmt.Sprintf("%s OK RENAME completed", req.Tag),
+	}, nil
+}
+
+// prepareRename reads the mails of folder, generates
+// their new keys, and creates the Maildir of newFolder.
+// Nothing else is changed, so that RENAME can still be
+// aborted if this fails for any of the folders involved.
+// The caller is required to hold the lock.
+func (mailbox *Mailbox) prepareRename(folder string, newFolder string) (*folderRename, error) {
+
+	rename := &folderRename{
+		folder:    folder,
+		newFolder: newFolder,
+		newKeys:   make([]string, len(mailbox.Mails[folder])),
+		contents:  make([][]byte, len(mailbox.Mails[folder])),
 	}
 
-	// If flags were supplied, parse them.
-	if appendInProg.FlagsRaw != "" {
+	for i, mailFileName := range mailbox.Mails[folder] {
 
-		_, err := ParseFlags(appendInProg.FlagsRaw)
+		content, err := ioutil.ReadFile(filepath.Join(mailbox.FolderPath(folder), "cur", mailFileName))
 		if err != nil {
+			return nil, fmt.Errorf("error reading mail to rename: %v", err)
+		}
 
-			// Parsing flags from APPEND request produced
-			// an error. Return tagged BAD response.
-			return &Await{
-				Text: fmt.Sprintf("%s BAD %s", req.Tag, err.Error()),
-			}, nil
+		newKey, err := maildir.Key()
+		if err != nil {
+			return nil, fmt.Errorf("error generating key for renamed mail: %v", err)
 		}
 
-		// TODO: Do something with these flags.
+		rename.newKeys[i] = newKey
+		rename.contents[i] = content
 	}
 
-	// If date-time was supplied, parse it.
-	if appendInProg.DateTimeRaw != "" {
-		// TODO: Parse time and do something with it.
+	// Create a new Maildir on stable storage.
+	err := maildir.Dir(mailbox.FolderPath(newFolder)).Create()
+	if err != nil {
+		return nil, fmt.Errorf("error while creating Maildir for renamed mailbox: %v", err)
 	}
 
-	// Parse out how many bytes we are expecting.
-	numBytesString := strings.TrimPrefix(numBytesRaw, "{")
-	numBytesString = strings.TrimSuffix(numBytesString, "}")
+	return rename, nil
+}
 
-	// Convert string number to int.
-	numBytes, err := strconv.Atoi(numBytesString)
-	if err != nil {
+// renameFolder moves all mails of a folder prepared by
+// prepareRename to the new folder and replaces the folder's
+// tags in the structure CRDT. It returns the part of the
+// downstream update message that describes this folder.
+// As mails are moved on stable storage from here on, any
+// failure is fatal. The caller is required to hold the lock.
+func (mailbox *Mailbox) renameFolder(rename *folderRename) *comm.Msg_RENAME_FOLDER {
 
-		// If we were not able to parse out the number,
-		// it was probably a client error. Send tagged BAD.
-		return &Await{
-			Text: fmt.Sprintf("%s BAD Command APPEND did not contain proper literal data byte number", req.Tag),
-		}, nil
-	}
+	folder := rename.folder
+	newFolder := rename.newFolder
 
-	if appendInProg.Mailbox == "INBOX" {
-		appendInProg.Maildir = maildir.Dir(mailbox.MaildirPath)
-	} else {
-		appendInProg.Maildir = maildir.Dir(filepath.Join(mailbox.MaildirPath, appendInProg.Mailbox))
-	}
+	oldMaildir := maildir.Dir(mailbox.FolderPath(folder))
+	newMaildir := maildir.Dir(mailbox.FolderPath(newFolder))
 
-	// Lock node exclusively to make execution
-	// of following CRDT operations atomic.
-	mailbox.Lock.Lock()
+	mailbox.Mails[newFolder] = make([]string, 0, len(mailbox.Mails[folder]))
 
-	if !mailbox.Structure.Lookup(appendInProg.Mailbox) {
+	// The renamed folder starts a new UID sequence,
+	// thus it needs a new UIDVALIDITY, too.
+	uidValidity := uint32(time.Now().Unix())
+	if prevValidity := mailbox.UIDs.Validity(newFolder); prevValidity >= uidValidity {
+		uidValidity = prevValidity + 1
+	}
 
-		mailbox.Lock.Unlock()
+	err := mailbox.UIDs.SetBaseEffect(newFolder, uidValidity, true)
+	if err != nil {
+		level.Error(mailbox.Logger).Log(
+			"msg", "failed to set UIDVALIDITY during source RENAME execution",
+			"err", err,
+		)
+		os.Exit(1)
+	}
 
-		// If mailbox folder to append message to does not exist,
-		// this is a client error. Return NO response.
-		return &Await{
-			Text: fmt.Sprintf("%s NO [TRYCREATE] Mailbox to append to does not exist", req.Tag),
-		}, nil
+	folderUpd := &comm.Msg_RENAME_FOLDER{
+		Mailbox:     folder,
+		NewMailbox:  newFolder,
+		UidValidity: uidValidity,
+		SpecialUse:  mailbox.specialUse(folder),
 	}
 
-	// Store created appendInProg context in session.
-	s.AppendInProg = appendInProg
+	// Move each mail in order of its UID to the new
+	// folder under a new key but with the same info
+	// part. Renaming the file preserves its internal date.
+	newMailFileNames := make(map[string]string)
 
-	return &Await{
-		Text:     "+ Ready for literal data",
-		NumBytes: uint32(numBytes),
-	}, nil
-}
+	for i, mailFileName := range mailbox.Mails[folder] {
 
-// AppendEnd receives the mail file associated with a
-// prior AppendBegin.
-func (mailbox *Mailbox) AppendEnd(s *Session, content []byte, syncChan chan comm.Msg) (*Reply, error) {
+		newKey := rename.newKeys[i]
+		content := rename.contents[i]
 
-	defer mailbox.Lock.Unlock()
-	defer func() {
-		s.AppendInProg = nil
-	}()
+		mailFilePath := filepath.Join(string(oldMaildir), "cur", mailFileName)
+		newMailFileName := newKey + strings.TrimPrefix(mailFileName, MailKey(mailFileName))
+		newMailFilePath := filepath.Join(string(newMaildir), "cur", newMailFileName)
 
-	// Open a new Maildir delivery.
-	appDelivery, err := s.AppendInProg.Maildir.NewDelivery()
-	if err != nil {
+		err = os.Rename(mailFilePath, newMailFilePath)
+		if err != nil {
+			level.Error(mailbox.Logger).Log(
+				"msg", "failed to move mail to renamed mailbox during source RENAME execution",
+				"err", err,
+			)
+			os.Exit(1)
+		}
 
-		return &Reply{
-			Text:   "* BAD Internal server error, sorry. Closing connection.",
-			Status: 1,
-		}, fmt.Errorf("error during delivery creation: %v", err)
-	}
+		var origUID uint32
+		err = mailbox.UIDs.Add(newFolder, newKey, func(assignedUID uint32) {
+			origUID = assignedUID
+		})
+		if err != nil {
 
-	// Write actual message content to file.
-	err = appDelivery.Write(content)
-	if err != nil {
+			level.Error(mailbox.Logger).Log(
+				"msg", "failed to assign UID during source RENAME execution",
+				"err", err,
+			)
+			os.Exit(1)
+		}
 
-		return &Reply{
-			Text:   "* BAD Internal server error, sorry. Closing connection.",
-			Status: 1,
-		}, fmt.Errorf("error during writing message during delivery: %v", err)
-	}
+		mailbox.insertMail(newFolder, newMailFileName)
+		mailbox.indexMail(newFolder, newMailFilePath, content)
+		mailbox.Index.Remove(folder, MailKey(mailFileName))
+		mailbox.recordChange(newFolder, newKey, 0, "RENAME")
 
-	// Close and move just created message.
-	newKey, err := appDelivery.Close()
-	if err != nil {
+		// INBOX stays, so clients need to learn
+		// that its mails vanished.
+		if folder == "INBOX" {
+			mailbox.recordExpunge(folder, MailKey(mailFileName), 0, "RENAME")
+		}
 
-		return &Reply{
-			Text:   "* BAD Internal server error, sorry. Closing connection.",
-			Status: 1,
-		}, fmt.Errorf("error finishing delivery of new message: %v", err)
+		newMailFileNames[mailFileName] = newMailFileName
+
+		folderUpd.RmvMails = append(folderUpd.RmvMails, mailFileName)
+		folderUpd.AddMails = append(folderUpd.AddMails, newMailFileName)
+		folderUpd.AddContents = append(folderUpd.AddContents, content)
+		folderUpd.OrigUIDs = append(folderUpd.OrigUIDs, origUID)
 	}
 
-	// Follow Maildir's renaming procedure.
-	_, err = s.AppendInProg.Maildir.Unseen()
-	if err != nil {
+	// Replace all observed tags of the folder by new ones.
+	// Mails take their new file names as tags, all other
+	// tags are replaced by new UUIDs. INBOX keeps the tags
+	// declaring its presence because it always exists.
+	err = mailbox.Structure.Rename(folder, newFolder, func(tag string) (string, bool) {
 
-		return &Reply{
-			Text:   "* BAD Internal server error, sorry. Closing connection.",
-			Status: 1,
-		}, fmt.Errorf("error executing Unseen() on recently delivered messages: %v", err)
-	}
+		if newMailFileName, found := newMailFileNames[tag]; found {
+			return newMailFileName, true
+		}
 
-	// Find file name of just delivered mail.
-	mailFileNamePath, err := s.AppendInProg.Maildir.Filename(newKey)
-	if err != nil {
+		return "", (folder != "INBOX")
+	}, func(args ...string) {
 
-		return &Reply{
-			Text:   "* BAD Internal server error, sorry. Closing connection.",
-			Status: 1,
-		}, fmt.Errorf("error finding file name of new message: %v", err)
-	}
-	mailFileName := filepath.Base(mailFileNamePath)
+		folderUpd.AddTags = append(folderUpd.AddTags, args[0])
 
-	// Append new mail file name to message
-	// number tracking structure.
-	mailbox.Mails[s.AppendInProg.Mailbox] = append(mailbox.Mails[s.AppendInProg.Mailbox], mailFileName)
+		for i := 1; i < len(args); i += 2 {
 
-	// Add mailbox-mail-file pair to structure CRDT
-	// and send an update message to other replicas.
-	err = mailbox.Structure.Add(s.AppendInProg.Mailbox, mailFileName, func(args ...string) {
-		syncChan <- comm.Msg{
-			Operation: "append",
-			Append: &comm.Msg_APPEND{
-				User:       s.UserName,
-				Mailbox:    s.AppendInProg.Mailbox,
-				AddTag:     mailFileName,
-				AddContent: content,
-			},
+			folderUpd.RmvTags = append(folderUpd.RmvTags, args[i])
+
+			if _, found := newMailFileNames[args[i]]; !found {
+				folderUpd.AddTags = append(folderUpd.AddTags, args[(i+1)])
+			}
 		}
 	})
 	if err != nil {
 
 		level.Error(mailbox.Logger).Log(
-			"msg", "fail during source APPEND execution, will clean up",
+			"msg", "failed to rename mailbox folder in user's structure CRDT",
 			"err", err,
 		)
+		os.Exit(1)
+	}
 
-		err := os.Remove(mailFileNamePath)
-		if err != nil {
-			level.Error(mailbox.Logger).Log(
-				"msg", "failed to remove created mail message during clean up of failed source APPEND execution",
-				"err", err,
-			)
-		}
+	// Special-use attributes follow the folder
+	// to the new tag declaring its presence.
+	mailbox.dropSpecialUse(folderUpd.RmvTags)
+	mailbox.setSpecialUse(folderUpd.AddTags[0], folderUpd.SpecialUse)
+
+	if folder == "INBOX" {
+		mailbox.Mails[folder] = make([]string, 0, 6)
+		return folderUpd
+	}
+
+	delete(mailbox.Mails, folder)
+	mailbox.Index.RemoveFolder(folder)
 
+	// Remove the now empty Maildir of the old
+	// folder from stable storage.
+	err = oldMaildir.Remove()
+	if err != nil {
+		level.Error(mailbox.Logger).Log(
+			"msg", "failed to remove Maildir of renamed mailbox during source RENAME execution",
+			"err", err,
+		)
 		os.Exit(1)
 	}
 
-	answer := fmt.Sprintf("%s OK APPEND completed", s.AppendInProg.Tag)
+	mailbox.unselectRenamed(folder)
 
-	return &Reply{
-		Text: answer,
-	}, nil
+	return folderUpd
 }
 
-// Expunge deletes messages permanently from currently
+// AppendBegin checks environment conditions and returns
+// a message specifying the awaited number of bytes. If
+// no message is sent as literal, e.g. as all of them are
+// assembled via CATENATE (RFC 4469), they are stored
+// right away and the final response is returned.
+func (mailbox *Mailbox) AppendBegin(s *Session, req *Request, syncChan chan comm.Msg) (*Await, error) {
+
+	if (s.State != StateAuthenticated) && (s.State != StateMailbox) {
+
+		// If connection was not in correct state when this
+		// command was executed, this is a client error.
+		// Send tagged BAD response.
+		return &Await{
+			Text: fmt.Sprintf("%s BAD Command APPEND cannot be executed in this state", req.Tag),
+		}, nil
+	}
+
+	if len(req.Args) < 2 {
+
+		// If payload did not contain at least a mailbox
+		// and a message, this is a client error.
+		// Return BAD statement.
+		return &Await{
+			Text: fmt.Sprintf("%s BAD Command APPEND was not sent with appropriate number of parameters", req.Tag),
+		}, nil
+	}
+
+	mailboxName, ok := s.mailboxName(req.Args[0])
+	if !ok {
+
+		return &Await{
+			Text: fmt.Sprintf("%s BAD Command APPEND was sent with an invalid mailbox name", req.Tag),
+		}, nil
+	}
+
+	// Parse flags, internal date, and content
+	// of all messages to append.
+	specs, err := parseAppendSpecs(req.Args[1:])
+	if err != nil {
+
+		// Parsing messages from APPEND request produced
+		// an error. Return tagged BAD response.
+		return &Await{
+			Text: fmt.Sprintf("%s BAD %s", req.Tag, err.Error()),
+		}, nil
+	}
+
+	// If user specified INBOX, set it accordingly.
+	if strings.ToUpper(mailboxName) == "INBOX" {
+		mailboxName = "INBOX"
+	}
+
+	// Lock node exclusively to make execution
+	// of following CRDT operations atomic.
+	mailbox.Lock.Lock()
+
+	if !mailbox.Structure.Lookup(mailboxName) {
+
+		mailbox.Lock.Unlock()
+
+		// If mailbox folder to append message to does not exist,
+		// this is a client error. Return NO response.
+		return &Await{
+			Text: fmt.Sprintf("%s NO [TRYCREATE] Mailbox to append to does not exist", req.Tag),
+		}, nil
+	}
+
+	// Store context tracking environment characteristics
+	// for this command from AppendBegin to AppendEnd.
+	s.AppendInProg = &AppendInProg{
+		Tag:     req.Tag,
+		Mailbox: mailboxName,
+		Maildir: maildir.Dir(mailbox.FolderPath(mailboxName)),
+	}
+
+	return mailbox.continueAppend(s, specs, syncChan)
+}
+
+// AppendNext receives the mail file of the message
+// awaited by the APPEND in progress and the rest of
+// the command following it, which contains the next
+// message of a MULTIAPPEND (RFC 3502).
+func (mailbox *Mailbox) AppendNext(s *Session, content []byte, rest string, syncChan chan comm.Msg) (*Await, error) {
+
+	s.AppendInProg.Pending.Content = content
+	s.AppendInProg.Messages = append(s.AppendInProg.Messages, s.AppendInProg.Pending)
+	s.AppendInProg.Pending = nil
+
+	tag := s.AppendInProg.Tag
+
+	var specs []*appendSpec
+	args, err := ParseArgs(strings.TrimPrefix(rest, " "))
+	if (err == nil) && strings.HasPrefix(rest, " ") {
+		specs, err = parseAppendSpecs(args)
+	} else if err == nil {
+		err = fmt.Errorf("Literal message of APPEND was not suffixed with CRLF")
+	}
+
+	if err != nil {
+
+		mailbox.abortAppend(s)
+
+		// If the next message was malformed, none of
+		// the messages is appended. Return tagged BAD.
+		return &Await{
+			Text: fmt.Sprintf("%s BAD %s", tag, err.Error()),
+		}, nil
+	}
+
+	return mailbox.continueAppend(s, specs, syncChan)
+}
+
+// AppendEnd receives the mail file associated with a
+// prior AppendBegin or AppendNext and stores all
+// messages of the APPEND.
+func (mailbox *Mailbox) AppendEnd(s *Session, content []byte, syncChan chan comm.Msg) (*Reply, error) {
+
+	s.AppendInProg.Pending.Content = content
+	s.AppendInProg.Messages = append(s.AppendInProg.Messages, s.AppendInProg.Pending)
+	s.AppendInProg.Pending = nil
+
+	return mailbox.finishAppend(s, syncChan)
+}
+
+// Expunge deletes messages permanently from currently
 // selected mailbox that have been flagged as Deleted
 // prior to calling this function.
 func (mailbox *Mailbox) Expunge(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+	return mailbox.expunge(s, req, false, syncChan)
+}
+
+// expunge implements EXPUNGE and UID EXPUNGE. If useUID
+// is true, the payload contains a UID set and only the
+// messages flagged as Deleted in that set are removed.
+func (mailbox *Mailbox) expunge(s *Session, req *Request, useUID bool, syncChan chan comm.Msg) (*Reply, error) {
 
 	if s.State != StateMailbox {
 
@@ -691,7 +1082,16 @@ func (mailbox *Mailbox) Expunge(s *Session, req *Request, syncChan chan comm.Msg
 		}, nil
 	}
 
-	if len(req.Payload) > 0 {
+	if s.ReadOnly {
+
+		// Mailboxes selected via EXAMINE must not
+		// be modified. Return NO statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s NO Mailbox was selected read-only, cannot expunge", req.Tag),
+		}, nil
+	}
+
+	if !useUID && (len(req.Payload) > 0) {
 
 		// If payload was not empty to EXPUNGE command,
 		// this is a client error. Return BAD statement.
@@ -700,13 +1100,17 @@ func (mailbox *Mailbox) Expunge(s *Session, req *Request, syncChan chan comm.Msg
 		}, nil
 	}
 
-	var expMaildir maildir.Dir
-	if s.SelectedMailbox == "INBOX" {
-		expMaildir = maildir.Dir(filepath.Join(mailbox.MaildirPath, "cur"))
-	} else {
-		expMaildir = maildir.Dir(filepath.Join(mailbox.MaildirPath, s.SelectedMailbox, "cur"))
+	if useUID && ((len(req.Args) != 1) || (req.Args[0].Type != NodeAtom)) {
+
+		// UID EXPUNGE requires a UID set to restrict
+		// expunged messages to. Return BAD statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command UID EXPUNGE was sent without a UID set", req.Tag),
+		}, nil
 	}
 
+	expMaildir := maildir.Dir(filepath.Join(mailbox.FolderPath(s.SelectedMailbox), "cur"))
+
 	// Reserve space for mails to expunge.
 	expMailNums := make([]int, 0, 6)
 
@@ -715,6 +1119,8 @@ func (mailbox *Mailbox) Expunge(s *Session, req *Request, syncChan chan comm.Msg
 	var answer string
 	var expAnswerLines []string
 
+	defer mailbox.notify()
+
 	// Lock node exclusively to make execution
 	// of following CRDT operations atomic.
 	mailbox.Lock.Lock()
@@ -724,6 +1130,25 @@ func (mailbox *Mailbox) Expunge(s *Session, req *Request, syncChan chan comm.Msg
 	// number of these files.
 	numExpMails := len(mailbox.Mails[s.SelectedMailbox])
 
+	// In case of UID EXPUNGE, only consider
+	// mails referenced by supplied UID set.
+	var expCandidates map[int]bool
+	if useUID {
+
+		mailSeqNums, err := ParseUIDSet(req.Args[0].Value, mailbox.folderUIDs(s.SelectedMailbox))
+		if err != nil {
+
+			return &Reply{
+				Text: fmt.Sprintf("%s BAD %s", req.Tag, err.Error()),
+			}, nil
+		}
+
+		expCandidates = make(map[int]bool)
+		for _, mailSeqNum := range mailSeqNums {
+			expCandidates[mailSeqNum] = true
+		}
+	}
+
 	// Only do the work if there are any mails
 	// present in mailbox.
 	if numExpMails > 0 {
@@ -731,6 +1156,11 @@ func (mailbox *Mailbox) Expunge(s *Session, req *Request, syncChan chan comm.Msg
 		// Iterate over all mail files in reverse order.
 		for i := (numExpMails - 1); i >= 0; i-- {
 
+			// Skip mails not in the UID set, if any.
+			if (expCandidates != nil) && !expCandidates[i] {
+				continue
+			}
+
 			// Retrieve all flags of fetched mail.
 			mailFlags, err := expMaildir.Flags(mailbox.Mails[s.SelectedMailbox][i], false)
 			if err != nil {
@@ -748,11 +1178,18 @@ func (mailbox *Mailbox) Expunge(s *Session, req *Request, syncChan chan comm.Msg
 			}
 		}
 
-		// Reserve space for answer lines.
-		expAnswerLines = make([]string, 0, len(expMailNums))
+		// Reserve space for UIDs of expunged mails.
+		expUIDs := make([]uint32, 0, len(expMailNums))
 
 		for _, mailSeqNum := range expMailNums {
 
+			expKey := MailKey(mailbox.Mails[s.SelectedMailbox][mailSeqNum])
+			expUID, _ := mailbox.UIDs.UID(s.SelectedMailbox, expKey)
+			expUIDs = append(expUIDs, expUID)
+
+			// Expunging a mail increases the folder's MODSEQ.
+			modSeq := mailbox.recordExpunge(s.SelectedMailbox, expKey, 0, "EXPUNGE")
+
 			// Remove each mail to expunge from structure CRDT.
 			err := mailbox.Structure.RemovePair(s.SelectedMailbox, mailbox.Mails[s.SelectedMailbox][mailSeqNum], func(args ...string) {})
 			if err != nil {
@@ -775,6 +1212,7 @@ func (mailbox *Mailbox) Expunge(s *Session, req *Request, syncChan chan comm.Msg
 						Mailbox: s.SelectedMailbox,
 						RmvTag:  mailbox.Mails[s.SelectedMailbox][mailSeqNum],
 						AddTag:  args[0],
+						ModSeq:  modSeq,
 					},
 				}
 			})
@@ -791,14 +1229,17 @@ func (mailbox *Mailbox) Expunge(s *Session, req *Request, syncChan chan comm.Msg
 				}, fmt.Errorf("error while removing expunged mail file from stable storage: %v", err)
 			}
 
-			// Immediately remove mail from contents structure.
+			// Immediately remove mail from contents structure
+			// and message index.
+			mailbox.Index.Remove(s.SelectedMailbox, MailKey(mailbox.Mails[s.SelectedMailbox][mailSeqNum]))
+			s.forgetKnownMail(mailbox.Mails[s.SelectedMailbox][mailSeqNum])
 			realMailSeqNum := mailSeqNum + 1
 			mailbox.Mails[s.SelectedMailbox] = append(mailbox.Mails[s.SelectedMailbox][:mailSeqNum], mailbox.Mails[s.SelectedMailbox][realMailSeqNum:]...)
-
-			// Append individual remove answer to answer lines.
-			expAnswerLines = append(expAnswerLines, fmt.Sprintf("* %d EXPUNGE", realMailSeqNum))
 		}
 
+		// Inform client about removed mails.
+		expAnswerLines = expungeResponse(s, expMailNums, expUIDs)
+
 		for _, expAnswerLine := range expAnswerLines {
 
 			// Append FETCH part with new flags.
@@ -825,6 +1266,13 @@ func (mailbox *Mailbox) Expunge(s *Session, req *Request, syncChan chan comm.Msg
 // of flags to change in those messages and changes the
 // attributes for these mails throughout the system.
 func (mailbox *Mailbox) Store(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+	return mailbox.store(s, req, false, syncChan)
+}
+
+// store implements STORE and UID STORE. If useUID is
+// true, messages are referenced by UIDs and each FETCH
+// response contains the UID of the message.
+func (mailbox *Mailbox) store(s *Session, req *Request, useUID bool, syncChan chan comm.Msg) (*Reply, error) {
 
 	if s.State != StateMailbox {
 
@@ -836,10 +1284,45 @@ func (mailbox *Mailbox) Store(s *Session, req *Request, syncChan chan comm.Msg)
 		}, nil
 	}
 
-	// Split payload on every space character.
-	storeArgs := strings.SplitN(req.Payload, " ", 3)
+	if s.ReadOnly {
+
+		// Mailboxes selected via EXAMINE must not
+		// be modified. Return NO statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s NO Mailbox was selected read-only, cannot store", req.Tag),
+		}, nil
+	}
+
+	storeArgs := req.Args
+
+	// An optional list of modifiers may follow the
+	// sequence set, e.g. UNCHANGEDSINCE (RFC 7162).
+	var unchangedSince *uint64
+	if (len(storeArgs) > 1) && storeArgs[1].IsList() {
+
+		modifiers := storeArgs[1].Items
+		if (len(modifiers) != 2) || (modifiers[0].Type != NodeAtom) || (strings.ToUpper(modifiers[0].Value) != "UNCHANGEDSINCE") || (modifiers[1].Type != NodeAtom) {
+
+			return &Reply{
+				Text: fmt.Sprintf("%s BAD Command STORE was sent with unknown modifiers", req.Tag),
+			}, nil
+		}
+
+		modSeq, err := parseModSeq(modifiers[1].Value, true)
+		if err != nil {
 
-	if len(storeArgs) < 3 {
+			return &Reply{
+				Text: fmt.Sprintf("%s BAD %s", req.Tag, err.Error()),
+			}, nil
+		}
+
+		// Using the modifier enables CONDSTORE.
+		s.CondStore = true
+		unchangedSince = &modSeq
+		storeArgs = append([]*Node{storeArgs[0]}, storeArgs[2:]...)
+	}
+
+	if (len(storeArgs) < 3) || (storeArgs[0].Type != NodeAtom) || (storeArgs[1].Type != NodeAtom) {
 
 		// If payload did not contain at least three
 		// elements, this is a client error.
@@ -850,7 +1333,7 @@ func (mailbox *Mailbox) Store(s *Session, req *Request, syncChan chan comm.Msg)
 	}
 
 	// Parse data item type (second parameter).
-	dataItemType := storeArgs[1]
+	dataItemType := strings.ToUpper(storeArgs[1].Value)
 
 	if (dataItemType != "FLAGS") && (dataItemType != "FLAGS.SILENT") &&
 		(dataItemType != "+FLAGS") && (dataItemType != "+FLAGS.SILENT") &&
@@ -871,8 +1354,8 @@ func (mailbox *Mailbox) Store(s *Session, req *Request, syncChan chan comm.Msg)
 		silent = true
 	}
 
-	// Parse flag argument (third parameter).
-	flags, err := ParseFlags(storeArgs[2])
+	// Parse flag arguments (third parameter on).
+	flags, err := ParseFlags(storeArgs[2:])
 	if err != nil {
 
 		// Parsing flags from STORE request produced
@@ -882,14 +1365,9 @@ func (mailbox *Mailbox) Store(s *Session, req *Request, syncChan chan comm.Msg)
 		}, nil
 	}
 
-	var selectedMailbox string
-	if s.SelectedMailbox == "INBOX" {
-		selectedMailbox = mailbox.MaildirPath
-	} else {
-		selectedMailbox = filepath.Join(mailbox.MaildirPath, s.SelectedMailbox)
-	}
+	storeMaildir := maildir.Dir(mailbox.FolderPath(s.SelectedMailbox))
 
-	storeMaildir := maildir.Dir(selectedMailbox)
+	defer mailbox.notify()
 
 	// Lock node exclusively to make execution
 	// of following CRDT operations atomic.
@@ -901,7 +1379,12 @@ func (mailbox *Mailbox) Store(s *Session, req *Request, syncChan chan comm.Msg)
 	// CAUTION: We expect this function to fail if supplied
 	//          message sequence numbers did not refer to
 	//          existing messages in mailbox.
-	mailSeqNums, err := ParseSeqNumbers(storeArgs[0], numMails)
+	var mailSeqNums []int
+	if useUID {
+		mailSeqNums, err = ParseUIDSet(storeArgs[0].Value, mailbox.folderUIDs(s.SelectedMailbox))
+	} else {
+		mailSeqNums, err = ParseSeqNumbers(storeArgs[0].Value, numMails)
+	}
 	if err != nil {
 
 		mailbox.Lock.Unlock()
@@ -913,48 +1396,50 @@ func (mailbox *Mailbox) Store(s *Session, req *Request, syncChan chan comm.Msg)
 		}, nil
 	}
 
-	answerLines := make([]string, 0, len(mailSeqNums))
-
-	for _, mailSeqNum := range mailSeqNums {
+	// Map supplied keywords to their Maildir letters.
+	// Only adding or setting flags defines new ones.
+	keywordLetters, keywordsCreated, err := mailbox.keywordFlags(flags, !strings.HasPrefix(dataItemType, "-"))
+	if err != nil {
 
-		// Initialize runes slice for new flags of mail.
-		newMailFlags := make([]rune, 0, 5)
+		mailbox.Lock.Unlock()
 
-		// Depending on the presence of various standard
-		// flags extend newMailFlags further.
+		// If no further keyword can be defined,
+		// the client exceeded a server limit.
+		return &Reply{
+			Text: fmt.Sprintf("%s NO [LIMIT] %s", req.Tag, err.Error()),
+		}, nil
+	}
 
-		if _, found := flags["\\Draft"]; found {
-			newMailFlags = append(newMailFlags, 'D')
-		}
+	answerLines := make([]string, 0, len(mailSeqNums))
 
-		if _, found := flags["\\Flagged"]; found {
-			newMailFlags = append(newMailFlags, 'F')
-		}
+	// Collect mails changed after the MODSEQ the
+	// client supplied, which must not be modified.
+	modified := make([]uint32, 0)
 
-		if _, found := flags["\\Answered"]; found {
-			newMailFlags = append(newMailFlags, 'R')
-		}
+	// Announce newly defined keywords (RFC 3501,
+	// section 7.2.6) independent of silent mode.
+	var flagsAnswer string
+	if keywordsCreated {
+		flagsAnswer = fmt.Sprintf("* FLAGS (%s)\r\n* OK [PERMANENTFLAGS (%s)] Limited\r\n", mailbox.flagsList(), mailbox.permanentFlags())
+	}
 
-		if _, found := flags["\\Seen"]; found {
-			newMailFlags = append(newMailFlags, 'S')
-		}
+	for _, mailSeqNum := range mailSeqNums {
 
-		if _, found := flags["\\Deleted"]; found {
-			newMailFlags = append(newMailFlags, 'T')
-		}
+		// Initialize runes slice for new flags of mail
+		// from the standard flags and keywords supplied.
+		newMailFlags := []rune(maildirFlags(flags) + keywordLetters)
 
 		mailFileName := mailbox.Mails[s.SelectedMailbox][mailSeqNum]
 
-		// Read message content from file.
-		mailFileContent, err := ioutil.ReadFile(filepath.Join(selectedMailbox, "cur", mailFileName))
-		if err != nil {
+		if (unchangedSince != nil) && (mailbox.mailModSeq(s.SelectedMailbox, mailFileName) > *unchangedSince) {
 
-			mailbox.Lock.Unlock()
+			if useUID {
+				modified = append(modified, mailbox.mailUID(s.SelectedMailbox, mailFileName))
+			} else {
+				modified = append(modified, uint32(mailSeqNum+1))
+			}
 
-			return &Reply{
-				Text:   "* BAD Internal server error, sorry. Closing connection.",
-				Status: 1,
-			}, fmt.Errorf("error while reading in mail file content in STORE operation: %v", err)
+			continue
 		}
 
 		// Retrieve flags included in mail file name.
@@ -1009,11 +1494,11 @@ func (mailbox *Mailbox) Store(s *Session, req *Request, syncChan chan comm.Msg)
 
 		// Check if we really have to perform an update
 		// across the system or if we can save the energy.
-		if mailFlags != string(newMailFlags) {
+		changed := mailFlags != string(newMailFlags)
+		if changed {
 
-			// Set just constructed new flags string in mail's
-			// file name (renaming it).
-			newMailFileName, err := storeMaildir.SetFlags(mailFileName, string(newMailFlags), false)
+			// Rename mail file and replicate the change.
+			err := mailbox.setMailFlags(s, storeMaildir, mailSeqNum, string(newMailFlags), syncChan)
 			if err != nil {
 
 				mailbox.Lock.Unlock()
@@ -1021,136 +1506,293 @@ func (mailbox *Mailbox) Store(s *Session, req *Request, syncChan chan comm.Msg)
 				return &Reply{
 					Text:   "* BAD Internal server error, sorry. Closing connection.",
 					Status: 1,
-				}, fmt.Errorf("error renaming mail file in STORE operation: %v", err)
+				}, err
 			}
+		}
 
-			// First, remove the former name of the mail file
-			// but do not yet send out an update operation.
-			err = mailbox.Structure.RemovePair(s.SelectedMailbox, mailFileName, func(args ...string) {})
-			if err != nil {
+		if silent != true {
 
-				// This is a write-back error of the updated structure CRDT
-				// log file. Reverting actions were already taken, log error.
-				level.Error(mailbox.Logger).Log(
-					"msg", "failed to remove old mail name from structure CRDT",
-					"err", err,
-				)
-				mailbox.Lock.Unlock()
-				os.Exit(1)
+			// Append this file's FETCH answer.
+			answerLines = append(answerLines, mailbox.flagsFetch(s, mailSeqNum, mailbox.Mails[s.SelectedMailbox][mailSeqNum], string(newMailFlags), useUID))
+		} else if changed && s.CondStore {
+
+			// Clients using CONDSTORE learn the new MODSEQ
+			// of changed mails even in silent mode.
+			modSeqItem := fmt.Sprintf("MODSEQ (%d)", mailbox.mailModSeq(s.SelectedMailbox, mailbox.Mails[s.SelectedMailbox][mailSeqNum]))
+			if useUID {
+				modSeqItem = fmt.Sprintf("UID %d %s", mailbox.mailUID(s.SelectedMailbox, mailbox.Mails[s.SelectedMailbox][mailSeqNum]), modSeqItem)
 			}
 
-			// Second, add the new mail file's name and finally
-			// instruct all other nodes to do the same.
-			err = mailbox.Structure.Add(s.SelectedMailbox, newMailFileName, func(args ...string) {
-				syncChan <- comm.Msg{
-					Operation: "store",
-					Store: &comm.Msg_STORE{
-						User:       s.UserName,
-						Mailbox:    s.SelectedMailbox,
-						RmvTag:     mailFileName,
-						AddTag:     newMailFileName,
-						AddContent: mailFileContent,
-					},
-				}
-			})
-			if err != nil {
+			answerLines = append(answerLines, fmt.Sprintf("* %d FETCH (%s)", (mailSeqNum+1), modSeqItem))
+		}
+	}
 
-				// This is a write-back error of the updated structure CRDT
-				// log file. Reverting actions were already taken, log error.
-				level.Error(mailbox.Logger).Log(
-					"msg", "failed to add renamed mail name to structure CRDT",
-					"err", err,
-				)
-				mailbox.Lock.Unlock()
-				os.Exit(1)
-			}
+	mailbox.Lock.Unlock()
+
+	var answer string
+	for _, answerLine := range answerLines {
 
-			// Replace the mail's file name in the message
-			// sequence number tracking structure.
-			mailbox.Mails[s.SelectedMailbox][mailSeqNum] = newMailFileName
+		// Append FETCH part with new flags.
+		if answer == "" {
+			answer = fmt.Sprintf("%s\r\n", answerLine)
+		} else {
+			answer = fmt.Sprintf("%s%s\r\n", answer, answerLine)
 		}
+	}
 
-		if silent != true {
+	// Report mails left untouched by a conditional STORE.
+	completed := "STORE completed"
+	if len(modified) > 0 {
 
-			answerFlagString := ""
+		sort.Slice(modified, func(i, j int) bool {
+			return modified[i] < modified[j]
+		})
 
-			for _, newFlag := range newMailFlags {
+		completed = fmt.Sprintf("[MODIFIED %s] Conditional STORE failed", formatUIDSet(modified))
+	}
 
-				switch newFlag {
+	if answer == "" {
+		answer = fmt.Sprintf("%s%s OK %s", flagsAnswer, req.Tag, completed)
+	} else {
+		answer = fmt.Sprintf("%s%s%s OK %s", flagsAnswer, answer, req.Tag, completed)
+	}
 
-				case 'D':
+	return &Reply{
+		Text: answer,
+	}, nil
+}
 
-					if answerFlagString == "" {
-						answerFlagString = "\\Draft"
-					} else {
-						answerFlagString = fmt.Sprintf("%s \\Draft", answerFlagString)
-					}
+// setMailFlags replaces the flags of the mail at supplied
+// sequence number in the selected mailbox with newMailFlags.
+// The mail file is renamed accordingly and the change is
+// sent downstream as a STORE operation. The caller is
+// required to hold the exclusive mailbox lock.
+func (mailbox *Mailbox) setMailFlags(s *Session, mailMaildir maildir.Dir, mailSeqNum int, newMailFlags string, syncChan chan comm.Msg) error {
 
-				case 'F':
+	mailFileName := mailbox.Mails[s.SelectedMailbox][mailSeqNum]
+
+	// Read message content from file.
+	mailFileContent, err := ioutil.ReadFile(filepath.Join(string(mailMaildir), "cur", mailFileName))
+	if err != nil {
+		return fmt.Errorf("error while reading in mail file content in flags update: %v", err)
+	}
 
-					if answerFlagString == "" {
-						answerFlagString = "\\Flagged"
-					} else {
-						answerFlagString = fmt.Sprintf("%s \\Flagged", answerFlagString)
-					}
+	// Set new flags string in mail's
+	// file name (renaming it).
+	newMailFileName, err := mailMaildir.SetFlags(mailFileName, newMailFlags, false)
+	if err != nil {
+		return fmt.Errorf("error renaming mail file in flags update: %v", err)
+	}
 
-				case 'R':
+	// First, remove the former name of the mail file
+	// but do not yet send out an update operation.
+	err = mailbox.Structure.RemovePair(s.SelectedMailbox, mailFileName, func(args ...string) {})
+	if err != nil {
 
-					if answerFlagString == "" {
-						answerFlagString = "\\Answered"
-					} else {
-						answerFlagString = fmt.Sprintf("%s \\Answered", answerFlagString)
-					}
+		// This is a write-back error of the updated structure CRDT
+		// log file. Reverting actions were already taken, log error.
+		level.Error(mailbox.Logger).Log(
+			"msg", "failed to remove old mail name from structure CRDT",
+			"err", err,
+		)
+		os.Exit(1)
+	}
+
+	// Flag changes increase the MODSEQ of the mail.
+	modSeq := mailbox.recordChange(s.SelectedMailbox, MailKey(newMailFileName), 0, "STORE")
+
+	// Second, add the new mail file's name and finally
+	// instruct all other nodes to do the same.
+	err = mailbox.Structure.Add(s.SelectedMailbox, newMailFileName, func(args ...string) {
+		syncChan <- comm.Msg{
+			Operation: "store",
+			Store: &comm.Msg_STORE{
+				User:       s.UserName,
+				Mailbox:    s.SelectedMailbox,
+				RmvTag:     mailFileName,
+				AddTag:     newMailFileName,
+				AddContent: mailFileContent,
+				Keywords:   mailbox.Keywords.Bindings(),
+				ModSeq:     modSeq,
+			},
+		}
+	})
+	if err != nil {
 
-				case 'S':
+		// This is a write-back error of the updated structure CRDT
+		// log file. Reverting actions were already taken, log error.
+		level.Error(mailbox.Logger).Log(
+			"msg", "failed to add renamed mail name to structure CRDT",
+			"err", err,
+		)
+		os.Exit(1)
+	}
 
-					if answerFlagString == "" {
-						answerFlagString = "\\Seen"
-					} else {
-						answerFlagString = fmt.Sprintf("%s \\Seen", answerFlagString)
-					}
+	// Replace the mail's file name in the message
+	// sequence number tracking structure.
+	mailbox.Mails[s.SelectedMailbox][mailSeqNum] = newMailFileName
+	s.renameKnownMail(mailFileName, newMailFileName)
 
-				case 'T':
+	return nil
+}
 
-					if answerFlagString == "" {
-						answerFlagString = "\\Deleted"
-					} else {
-						answerFlagString = fmt.Sprintf("%s \\Deleted", answerFlagString)
-					}
+// Fetch takes in message sequence numbers and a list of
+// data items and returns the requested data for each of
+// the referenced messages. Fetching message content
+// without PEEK implicitly sets the \Seen flag, which is
+// replicated the same way as a STORE would.
+func (mailbox *Mailbox) Fetch(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+	return mailbox.fetch(s, req, false, syncChan)
+}
 
-				}
+// fetch implements FETCH and UID FETCH. If useUID is
+// true, messages are referenced by UIDs and the UID
+// data item is always included in responses.
+func (mailbox *Mailbox) fetch(s *Session, req *Request, useUID bool, syncChan chan comm.Msg) (*Reply, error) {
 
-			}
+	if s.State != StateMailbox {
 
-			// Append this file's FETCH answer.
-			realMailSeqNum := mailSeqNum + 1
-			answerLines = append(answerLines, fmt.Sprintf("* %d FETCH (FLAGS (%s))", realMailSeqNum, answerFlagString))
+		// If connection was not in correct state when this
+		// command was executed, this is a client error.
+		// Send tagged BAD response.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD No mailbox selected to fetch from", req.Tag),
+		}, nil
+	}
+
+	fetchArgs := req.Args
+
+	if (len(fetchArgs) < 2) || (len(fetchArgs) > 3) || (fetchArgs[0].Type != NodeAtom) {
+
+		// If payload did not contain a sequence set
+		// and data items, this is a client error.
+		// Return BAD statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command FETCH was not sent with two parameters", req.Tag),
+		}, nil
+	}
+
+	// Parse data items (second parameter).
+	items, err := ParseFetchItems(fetchArgs[1])
+	if err != nil {
+
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD %s", req.Tag, err.Error()),
+		}, nil
+	}
+
+	// An optional list of modifiers may follow the
+	// data items, e.g. CHANGEDSINCE (RFC 7162).
+	modifiers := &fetchModifiers{}
+	if len(fetchArgs) == 3 {
+
+		modifiers, err = parseFetchModifiers(s, fetchArgs[2], useUID)
+		if err != nil {
+
+			return &Reply{
+				Text: fmt.Sprintf("%s BAD %s", req.Tag, err.Error()),
+			}, nil
 		}
 	}
 
-	mailbox.Lock.Unlock()
+	modSeqRequested := false
+	for _, item := range items {
 
-	var answer string
-	if silent != true {
+		if item.Name == "MODSEQ" {
+			modSeqRequested = true
+		}
+	}
 
-		for _, answerLine := range answerLines {
+	// Requesting MODSEQ enables CONDSTORE and
+	// CHANGEDSINCE implies requesting it.
+	if modSeqRequested || modifiers.changedSince {
+		s.CondStore = true
+	}
 
-			// Append FETCH part with new flags.
-			if answer == "" {
-				answer = fmt.Sprintf("%s\r\n", answerLine)
-			} else {
-				answer = fmt.Sprintf("%s%s\r\n", answer, answerLine)
+	if modifiers.changedSince && !modSeqRequested {
+		items = append(items, &FetchItem{Name: "MODSEQ"})
+	}
+
+	// UID FETCH responses always contain the UID.
+	if useUID {
+
+		uidRequested := false
+		for _, item := range items {
+
+			if item.Name == "UID" {
+				uidRequested = true
 			}
 		}
+
+		if !uidRequested {
+			items = append([]*FetchItem{&FetchItem{Name: "UID"}}, items...)
+		}
 	}
 
-	if answer == "" {
-		answer = fmt.Sprintf("%s OK STORE completed", req.Tag)
+	fetchMaildir := maildir.Dir(mailbox.FolderPath(s.SelectedMailbox))
+
+	defer mailbox.notify()
+
+	// Lock node exclusively because fetching
+	// may set the \Seen flag on messages.
+	mailbox.Lock.Lock()
+	defer mailbox.Lock.Unlock()
+
+	// Parse sequence numbers or UIDs (first parameter).
+	var mailSeqNums []int
+	if useUID {
+		mailSeqNums, err = ParseUIDSet(fetchArgs[0].Value, mailbox.folderUIDs(s.SelectedMailbox))
 	} else {
-		answer = fmt.Sprintf("%s%s OK STORE completed", answer, req.Tag)
+		mailSeqNums, err = ParseSeqNumbers(fetchArgs[0].Value, len(mailbox.Mails[s.SelectedMailbox]))
+	}
+	if err != nil {
+
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD %s", req.Tag, err.Error()),
+		}, nil
+	}
+
+	answerLines := make([]string, 0, (len(mailSeqNums) + 2))
+
+	// Report UIDs of the requested set expunged since
+	// the supplied MODSEQ before the changed mails.
+	if modifiers.vanished {
+
+		vanished, err := mailbox.vanishedUIDs(s.SelectedMailbox, modifiers.modSeq, fetchArgs[0].Value)
+		if err != nil {
+
+			return &Reply{
+				Text: fmt.Sprintf("%s BAD %s", req.Tag, err.Error()),
+			}, nil
+		}
+
+		if len(vanished) > 0 {
+			answerLines = append(answerLines, fmt.Sprintf("* VANISHED (EARLIER) %s", formatUIDSet(vanished)))
+		}
 	}
 
+	for _, mailSeqNum := range mailSeqNums {
+
+		// Skip mails not changed since the supplied MODSEQ.
+		if modifiers.changedSince && (mailbox.mailModSeq(s.SelectedMailbox, mailbox.Mails[s.SelectedMailbox][mailSeqNum]) <= modifiers.modSeq) {
+			continue
+		}
+
+		answerLine, err := mailbox.fetchMail(s, fetchMaildir, mailSeqNum, items, syncChan)
+		if err != nil {
+
+			return &Reply{
+				Text:   "* BAD Internal server error, sorry. Closing connection.",
+				Status: 1,
+			}, err
+		}
+
+		answerLines = append(answerLines, answerLine)
+	}
+
+	answerLines = append(answerLines, fmt.Sprintf("%s OK FETCH completed", req.Tag))
+
 	return &Reply{
-		Text: answer,
+		Text: strings.Join(answerLines, "\r\n"),
 	}, nil
 }
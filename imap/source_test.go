@@ -0,0 +1,300 @@
+package imap
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/go-pluto/pluto/comm"
+	"github.com/stretchr/testify/assert"
+)
+
+// Variables
+
+// selectTests are run in order on one session, so
+// that each one starts in the state left by the
+// previous one. In lines, {validity} stands for
+// the UIDVALIDITY and {modseq} for the HIGHESTMODSEQ
+// of the selected folder.
+var selectTests = []struct {
+	command  string
+	payload  string
+	folder   string
+	readOnly bool
+	lines    []string
+}{
+	{CommandSelect, "inbox", "INBOX", false, []string{
+		"* 3 EXISTS",
+		"* 0 RECENT",
+		"* FLAGS (\\Answered \\Flagged \\Deleted \\Seen \\Draft)",
+		"* OK [PERMANENTFLAGS (\\Answered \\Flagged \\Deleted \\Seen \\Draft \\*)]",
+		"* OK [UIDVALIDITY {validity}] UIDs valid",
+		"* OK [UIDNEXT 4] Predicted next UID",
+		"* OK [HIGHESTMODSEQ {modseq}] Highest",
+		"a1 OK [READ-WRITE] SELECT completed",
+	}},
+	{CommandExamine, "INBOX", "INBOX", true, []string{
+		"* OK [CLOSED] Previous mailbox closed",
+		"* 3 EXISTS",
+		"* 0 RECENT",
+		"* FLAGS (\\Answered \\Flagged \\Deleted \\Seen \\Draft)",
+		"* OK [PERMANENTFLAGS ()]",
+		"* OK [UIDVALIDITY {validity}] UIDs valid",
+		"* OK [UIDNEXT 4] Predicted next UID",
+		"* OK [HIGHESTMODSEQ {modseq}] Highest",
+		"a1 OK [READ-ONLY] EXAMINE completed",
+	}},
+	{CommandSelect, "Missing", "INBOX", true, []string{"a1 NO SELECT failure, not a valid Maildir folder"}},
+	{CommandSelect, "", "INBOX", true, []string{"a1 BAD Command SELECT was sent without a mailbox to select"}},
+	{CommandExamine, "INBOX Archive Work", "INBOX", true, []string{"a1 BAD Command EXAMINE was sent with multiple mailbox names instead of only one"}},
+	{CommandSelect, "INBOX Archive", "INBOX", true, []string{"a1 BAD Command SELECT was sent with invalid parameters"}},
+	{CommandSelect, "INBOX (QRESYNC (1 1))", "INBOX", true, []string{"a1 BAD Command SELECT was sent with parameter QRESYNC requires ENABLE QRESYNC"}},
+	{CommandSelect, "Archive (CONDSTORE)", "Archive", false, []string{
+		"* OK [CLOSED] Previous mailbox closed",
+		"* 0 EXISTS",
+		"* 0 RECENT",
+		"* FLAGS (\\Answered \\Flagged \\Deleted \\Seen \\Draft)",
+		"* OK [PERMANENTFLAGS (\\Answered \\Flagged \\Deleted \\Seen \\Draft \\*)]",
+		"* OK [UIDVALIDITY {validity}] UIDs valid",
+		"* OK [UIDNEXT 1] Predicted next UID",
+		"* OK [HIGHESTMODSEQ {modseq}] Highest",
+		"a1 OK [READ-WRITE] SELECT completed",
+	}},
+}
+
+// Functions
+
+// TestSelect executes a black-box table test on
+// SELECT and EXAMINE and the session state they
+// leave behind.
+func TestSelect(t *testing.T) {
+
+	mailbox, cleanup := newTestMailbox(t, "Archive")
+	defer cleanup()
+
+	date := time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)
+	addTestMail(t, mailbox, "INBOX", "1400000001.a", "S", date, "Subject: First\r\n\r\nOne.\r\n")
+	addTestMail(t, mailbox, "INBOX", "1400000002.b", "", date, "Subject: Second\r\n\r\nTwo.\r\n")
+	addTestMail(t, mailbox, "INBOX", "1400000003.c", "FR", date, "Subject: Third\r\n\r\nThree.\r\n")
+
+	s := &Session{
+		State:    StateAuthenticated,
+		UserName: "user",
+	}
+
+	for _, test := range selectTests {
+
+		req, err := ParseRequest(strings.TrimSpace(fmt.Sprintf("a1 %s %s", test.command, test.payload)))
+		assert.Nilf(t, err, "expected %s %s to be parsed but got: %v", test.command, test.payload, err)
+
+		var reply *Reply
+		if test.command == CommandSelect {
+			reply, err = mailbox.Select(s, req, nil)
+		} else {
+			reply, err = mailbox.Examine(s, req, nil)
+		}
+
+		assert.Nilf(t, err, "expected %s %s not to fail but got: %v", test.command, test.payload, err)
+
+		expected := strings.NewReplacer(
+			"{validity}", fmt.Sprintf("%d", mailbox.UIDs.Validity(test.folder)),
+			"{modseq}", fmt.Sprintf("%d", mailbox.ModSeqs.Highest(test.folder)),
+		).Replace(strings.Join(test.lines, "\r\n"))
+		assert.Equalf(t, expected, reply.Text, "unexpected answer to %s %s", test.command, test.payload)
+
+		// Refused commands leave the
+		// previous selection in place.
+		assert.Equalf(t, StateMailbox, s.State, "expected a mailbox to be selected after %s %s", test.command, test.payload)
+		assert.Equalf(t, test.folder, s.SelectedMailbox, "unexpected folder selected after %s %s", test.command, test.payload)
+		assert.Equalf(t, test.readOnly, s.ReadOnly, "unexpected access after %s %s", test.command, test.payload)
+		assert.Equalf(t, append([]string(nil), mailbox.Mails[test.folder]...), s.KnownMails, "expected client to know all mails after %s %s", test.command, test.payload)
+	}
+
+	assert.Truef(t, s.CondStore, "expected SELECT with CONDSTORE to enable it")
+}
+
+// TestRename executes a black-box unit test on
+// renaming a mailbox folder together with its
+// inferiors.
+func TestRename(t *testing.T) {
+
+	mailbox, cleanup := newTestMailbox(t, "Work", "Work.Projects", "Work.Projects.Pluto", "Workshop")
+	defer cleanup()
+
+	date := time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)
+	addTestMail(t, mailbox, "Work", "1400000001.a", "S", date, "Subject: First\r\n\r\nOne.\r\n")
+	addTestMail(t, mailbox, "Work", "1400000002.b", "FR", date, "Subject: Second\r\n\r\nTwo.\r\n")
+	addTestMail(t, mailbox, "Work.Projects.Pluto", "1400000003.c", "", date, "Subject: Third\r\n\r\nThree.\r\n")
+	addTestMail(t, mailbox, "Workshop", "1400000004.d", "", date, "Subject: Fourth\r\n\r\nFour.\r\n")
+
+	s := &Session{
+		State:    StateAuthenticated,
+		UserName: "user",
+	}
+
+	syncChan := make(chan comm.Msg, 1)
+
+	rename := func(payload string) *Reply {
+
+		req, err := ParseRequest("a1 RENAME " + payload)
+		assert.Nilf(t, err, "expected RENAME %s to be parsed but got: %v", payload, err)
+
+		reply, err := mailbox.Rename(s, req, syncChan)
+		assert.Nilf(t, err, "expected RENAME %s not to fail but got: %v", payload, err)
+
+		return reply
+	}
+
+	assert.Equalf(t, "a1 NO New mailbox cannot be named after already existing mailbox", rename("Work Workshop").Text, "expected existing name to be refused")
+	assert.Equalf(t, "a1 NO Mailbox cannot be renamed below itself", rename("Work Work.Old").Text, "expected rename below itself to be refused")
+	assert.Equalf(t, "a1 NO Cannot rename folder that does not exist", rename("Missing Jobs").Text, "expected missing folder to be refused")
+
+	// If any mail of any folder cannot be read, the
+	// mailbox is left untouched and nothing is sent.
+	unreadable := filepath.Join(mailbox.FolderPath("Work.Projects.Pluto"), "cur", mailbox.Mails["Work.Projects.Pluto"][0])
+	content, _ := ioutil.ReadFile(unreadable)
+	os.Remove(unreadable)
+
+	req, _ := ParseRequest("a2 RENAME Work Jobs")
+	reply, err := mailbox.Rename(s, req, syncChan)
+	assert.NotNilf(t, err, "expected RENAME of unreadable mail to fail")
+	assert.Equalf(t, uint32(1), reply.Status, "expected connection to be closed")
+	assert.Equalf(t, 0, len(syncChan), "expected no update to be sent")
+	assert.Equalf(t, []string{"INBOX", "Work", "Work.Projects", "Work.Projects.Pluto", "Workshop"}, sortedFolders(mailbox), "expected structure to be untouched")
+	assert.Equalf(t, 2, len(mailbox.Mails["Work"]), "expected mails of Work to stay")
+
+	for _, folder := range []string{"Jobs", "Jobs.Projects", "Jobs.Projects.Pluto"} {
+
+		_, err = os.Stat(mailbox.FolderPath(folder))
+		assert.Truef(t, os.IsNotExist(err), "expected Maildir of %s to be cleaned up", folder)
+	}
+
+	for _, mailFileName := range mailbox.Mails["Work"] {
+
+		_, err = os.Stat(filepath.Join(mailbox.FolderPath("Work"), "cur", mailFileName))
+		assert.Nilf(t, err, "expected mail %s to stay in Work but got: %v", mailFileName, err)
+	}
+
+	ioutil.WriteFile(unreadable, content, 0600)
+
+	// Now all inferiors are renamed along,
+	// but not the sibling sharing a prefix.
+	assert.Equalf(t, "a1 OK RENAME completed", rename("Work Jobs").Text, "expected RENAME to succeed")
+	assert.Equalf(t, []string{"INBOX", "Jobs", "Jobs.Projects", "Jobs.Projects.Pluto", "Workshop"}, sortedFolders(mailbox), "unexpected structure after RENAME")
+
+	assert.Equalf(t, 2, len(mailbox.Mails["Jobs"]), "expected mails to be moved to Jobs")
+	assert.Equalf(t, []uint32{1, 2}, mailbox.folderUIDs("Jobs"), "expected mails to be assigned UIDs in order")
+	assert.Equalf(t, "FR", mailbox.Mails["Jobs"][1][(len(mailbox.Mails["Jobs"][1])-2):], "expected flags to be kept")
+	assert.Equalf(t, 1, len(mailbox.Mails["Jobs.Projects.Pluto"]), "expected mail to be moved to Jobs.Projects.Pluto")
+	assert.Equalf(t, 1, len(mailbox.Mails["Workshop"]), "expected Workshop to keep its mail")
+
+	moved, err := ioutil.ReadFile(filepath.Join(mailbox.FolderPath("Jobs.Projects.Pluto"), "cur", mailbox.Mails["Jobs.Projects.Pluto"][0]))
+	assert.Nilf(t, err, "expected moved mail to be readable but got: %v", err)
+	assert.Equalf(t, content, moved, "expected content of moved mail to be kept")
+
+	for _, folder := range []string{"Work", "Work.Projects", "Work.Projects.Pluto"} {
+
+		_, found := mailbox.Mails[folder]
+		assert.Falsef(t, found, "expected %s to be gone", folder)
+
+		_, err = os.Stat(mailbox.FolderPath(folder))
+		assert.Truef(t, os.IsNotExist(err), "expected Maildir of %s to be removed", folder)
+	}
+
+	// All folders are replicated in one update.
+	upd := <-syncChan
+	assert.Equalf(t, "rename", upd.Operation, "expected a RENAME update")
+	assert.Equalf(t, 3, len(upd.Rename.Folders), "expected all renamed folders in update")
+
+	for _, folderUpd := range upd.Rename.Folders {
+
+		assert.Equalf(t, ("Jobs" + folderUpd.Mailbox[len("Work"):]), folderUpd.NewMailbox, "unexpected new name of %s", folderUpd.Mailbox)
+		assert.Equalf(t, mailbox.Mails[folderUpd.NewMailbox], append([]string{}, folderUpd.AddMails...), "unexpected mails added to %s", folderUpd.NewMailbox)
+		assert.Equalf(t, len(folderUpd.AddMails), len(folderUpd.AddContents), "expected contents of all mails of %s", folderUpd.NewMailbox)
+		assert.Equalf(t, len(folderUpd.AddMails), len(folderUpd.OrigUIDs), "expected UIDs of all mails of %s", folderUpd.NewMailbox)
+		assert.Equalf(t, mailbox.UIDs.Validity(folderUpd.NewMailbox), folderUpd.UidValidity, "unexpected UIDVALIDITY of %s", folderUpd.NewMailbox)
+	}
+}
+
+// TestRenameSelected executes a black-box unit test
+// on sessions that selected a folder being renamed.
+func TestRenameSelected(t *testing.T) {
+
+	mailbox, cleanup := newTestMailbox(t, "Work", "Work.Projects", "Workshop")
+	defer cleanup()
+
+	date := time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)
+	addTestMail(t, mailbox, "INBOX", "1400000001.a", "", date, "Subject: First\r\n\r\nOne.\r\n")
+	addTestMail(t, mailbox, "Work", "1400000002.b", "", date, "Subject: Second\r\n\r\nTwo.\r\n")
+
+	sessions := make(map[string]*Session)
+	for _, folder := range []string{"INBOX", "Work", "Work.Projects", "Workshop"} {
+
+		sessions[folder] = &Session{
+			State:           StateMailbox,
+			UserName:        "user",
+			SelectedMailbox: folder,
+			KnownMails:      append([]string(nil), mailbox.Mails[folder]...),
+		}
+
+		mailbox.AddSession(sessions[folder])
+	}
+
+	s := &Session{
+		State:    StateAuthenticated,
+		UserName: "user",
+	}
+
+	syncChan := make(chan comm.Msg, 1)
+
+	// Sessions of the renamed folder and its inferiors
+	// cannot keep their UIDs and are thus unselected.
+	req, _ := ParseRequest("a1 RENAME Work Jobs")
+	reply, err := mailbox.Rename(s, req, syncChan)
+	assert.Nilf(t, err, "expected RENAME not to fail but got: %v", err)
+	assert.Equalf(t, "a1 OK RENAME completed", reply.Text, "expected RENAME to succeed")
+	<-syncChan
+
+	for _, folder := range []string{"Work", "Work.Projects"} {
+
+		assert.Equalf(t, StateAuthenticated, sessions[folder].State, "expected session of %s to be unselected", folder)
+		assert.Equalf(t, "", sessions[folder].SelectedMailbox, "expected session of %s to select no folder", folder)
+		assert.Nilf(t, sessions[folder].KnownMails, "expected session of %s to know no mails", folder)
+	}
+
+	assert.Equalf(t, StateMailbox, sessions["Workshop"].State, "expected session of sibling to stay selected")
+
+	// INBOX stays in place, its sessions
+	// learn about its mails vanishing.
+	req, _ = ParseRequest("a1 RENAME INBOX Old")
+	mailbox.Rename(s, req, syncChan)
+	<-syncChan
+
+	assert.Equalf(t, StateMailbox, sessions["INBOX"].State, "expected session of INBOX to stay selected")
+	assert.Equalf(t, []string{"* 1 EXPUNGE"}, mailbox.pendingUpdates(sessions["INBOX"]), "expected mails of INBOX to be expunged")
+
+	// Closed sessions are not tracked anymore.
+	mailbox.RemoveSession(sessions["Workshop"])
+
+	req, _ = ParseRequest("a1 RENAME Workshop Shop")
+	mailbox.Rename(s, req, syncChan)
+	<-syncChan
+
+	assert.Equalf(t, StateMailbox, sessions["Workshop"].State, "expected removed session to be left untouched")
+}
+
+// sortedFolders returns the sorted names of
+// all folders of mailbox.
+func sortedFolders(mailbox *Mailbox) []string {
+
+	names := mailbox.Structure.GetAllValues()
+	sort.Strings(names)
+
+	return names
+}
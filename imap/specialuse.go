@@ -0,0 +1,223 @@
+package imap
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"path/filepath"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-pluto/pluto/comm"
+	"github.com/go-pluto/pluto/config"
+)
+
+// Constants
+
+// ProvisionMarker is the file in a user's CRDT folder
+// that marks the user as new. Default folders are only
+// provisioned while it is present.
+const ProvisionMarker = "provision.pending"
+
+// Variables
+
+// SpecialUses contains the special-use attributes
+// (RFC 6154) that can be assigned to a folder on
+// CREATE. \All and \Flagged denote virtual folders
+// which pluto does not provide.
+var SpecialUses = map[string]bool{
+	"\\Archive": true,
+	"\\Drafts":  true,
+	"\\Junk":    true,
+	"\\Sent":    true,
+	"\\Trash":   true,
+}
+
+// Functions
+
+// Provision creates and subscribes all supplied default
+// folders the user does not have yet. Folders are created
+// via the regular CREATE command and thus replicated to
+// all other nodes like any folder a client creates. This
+// only happens once for a new user: afterwards the marker
+// is removed so that folders the user deleted stay gone.
+func (mailbox *Mailbox) Provision(userName string, folders []config.DefaultFolder, syncChan chan comm.Msg) {
+
+	marker := filepath.Join(mailbox.CRDTPath, ProvisionMarker)
+
+	if _, err := os.Stat(marker); err != nil {
+		return
+	}
+
+	s := &Session{
+		State:    StateAuthenticated,
+		UserName: userName,
+	}
+
+	for _, folder := range folders {
+
+		mailbox.Lock.RLock()
+		exists := mailbox.Structure.Lookup(folder.Name)
+		mailbox.Lock.RUnlock()
+
+		if exists {
+			continue
+		}
+
+		createReq := fmt.Sprintf("provision %s %s", CommandCreate, s.quoteMailbox(folder.Name))
+		if folder.SpecialUse != "" {
+			createReq = fmt.Sprintf("%s (USE (%s))", createReq, folder.SpecialUse)
+		}
+
+		req, err := ParseRequest(createReq)
+		if err != nil {
+
+			level.Error(mailbox.Logger).Log(
+				"msg", fmt.Sprintf("invalid default folder %s", folder.Name),
+				"err", err,
+			)
+			continue
+		}
+
+		reply, err := mailbox.Create(s, req, syncChan)
+		if err == nil {
+
+			req.Command = CommandSubscribe
+			req.Args = req.Args[:1]
+
+			reply, err = mailbox.Subscribe(s, req, syncChan)
+		}
+
+		if err != nil {
+
+			level.Error(mailbox.Logger).Log(
+				"msg", fmt.Sprintf("failed to provision default folder %s", folder.Name),
+				"err", err,
+			)
+		} else if !strings.HasPrefix(reply.Text, "provision OK") {
+
+			level.Warn(mailbox.Logger).Log(
+				"msg", fmt.Sprintf("could not provision default folder %s", folder.Name),
+				"reply", reply.Text,
+			)
+		}
+	}
+
+	err := os.Remove(marker)
+	if err != nil {
+		level.Error(mailbox.Logger).Log(
+			"msg", "failed to remove provisioning marker of user",
+			"err", err,
+		)
+	}
+}
+
+// specialUse returns the special-use attributes of
+// folder, separated by spaces. Attributes are bound
+// to the folder's tags in the structure CRDT and thus
+// disappear with the folder. The caller is required
+// to hold the lock.
+func (mailbox *Mailbox) specialUse(folder string) string {
+
+	uses := make(map[string]bool)
+
+	for tag, value := range mailbox.Structure.Elements {
+
+		if value != folder {
+			continue
+		}
+
+		if use, found := mailbox.SpecialUse.Elements[tag]; found {
+
+			for _, attribute := range strings.Fields(use) {
+				uses[attribute] = true
+			}
+		}
+	}
+
+	attributes := make([]string, 0, len(uses))
+	for use := range uses {
+		attributes = append(attributes, use)
+	}
+	sort.Strings(attributes)
+
+	return strings.Join(attributes, " ")
+}
+
+// specialUseFolder returns the name of a folder that
+// carries the special-use attribute use, if any. The
+// caller is required to hold the lock.
+func (mailbox *Mailbox) specialUseFolder(use string) (string, bool) {
+
+	for tag, uses := range mailbox.SpecialUse.Elements {
+
+		folder, found := mailbox.Structure.Elements[tag]
+		if !found {
+			continue
+		}
+
+		for _, attribute := range strings.Fields(uses) {
+
+			if attribute == use {
+				return folder, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// setSpecialUse binds special-use attributes use to
+// the structure CRDT tag of a folder. The caller is
+// required to hold the exclusive lock.
+func (mailbox *Mailbox) setSpecialUse(tag string, use string) {
+
+	if use == "" {
+		return
+	}
+
+	err := mailbox.SpecialUse.AddEffect(use, tag, true)
+	if err != nil {
+		level.Error(mailbox.Logger).Log(
+			"msg", "failed to add special-use attribute to user's special-use CRDT",
+			"err", err,
+		)
+		os.Exit(1)
+	}
+}
+
+// dropSpecialUse removes the special-use attributes
+// bound to any of the supplied structure CRDT tags
+// after these were removed. The caller is required
+// to hold the exclusive lock.
+func (mailbox *Mailbox) dropSpecialUse(tags []string) {
+
+	rmElements := make(map[string]string)
+	for _, tag := range tags {
+
+		if use, found := mailbox.SpecialUse.Elements[tag]; found {
+			rmElements[tag] = use
+		}
+	}
+
+	if len(rmElements) == 0 {
+		return
+	}
+
+	err := mailbox.SpecialUse.RemoveEffect(rmElements, true)
+	if err != nil {
+		level.Error(mailbox.Logger).Log(
+			"msg", "failed to remove special-use attributes from user's special-use CRDT",
+			"err", err,
+		)
+		os.Exit(1)
+	}
+}
+
+// init registers the capabilities of
+// special-use mailboxes (RFC 6154).
+func init() {
+	RegisterCapability(&Capability{Name: "SPECIAL-USE", PostAuth: true})
+	RegisterCapability(&Capability{Name: "CREATE-SPECIAL-USE", PostAuth: true})
+}
@@ -0,0 +1,218 @@
+package imap
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/go-pluto/pluto/comm"
+	"github.com/go-pluto/pluto/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// Variables
+
+var defaultFolders = []config.DefaultFolder{
+	{Name: "Sent", SpecialUse: "\\Sent"},
+	{Name: "Junk", SpecialUse: "\\Junk"},
+	{Name: "Notes"},
+}
+
+var createSpecialUseTests = []struct {
+	payload    string
+	reply      string
+	folder     string
+	specialUse string
+}{
+	{"Sent (USE (\\Sent))", "a1 OK CREATE completed", "Sent", "\\Sent"},
+	{"Archive (use (\\archive))", "a1 OK CREATE completed", "Archive", "\\Archive"},
+	{"Bin (USE (\\Trash \\Junk))", "a1 OK CREATE completed", "Bin", "\\Junk \\Trash"},
+	{"Work.Drafts (USE (\\Drafts))", "a1 OK CREATE completed", "Work.Drafts", "\\Drafts"},
+	{"Plain", "a1 OK CREATE completed", "Plain", ""},
+	{"Outbox (USE (\\Sent))", "a1 NO [USEATTR] Special-use attribute \\Sent is already assigned to Sent", "", ""},
+	{"Everything (USE (\\All))", "a1 NO [USEATTR] Special-use attribute \\All is not supported", "", ""},
+	{"Odd (USES (\\Drafts))", "a1 BAD Command CREATE was sent with unknown parameters", "", ""},
+	{"Odd (USE \\Drafts)", "a1 BAD Command CREATE was sent with unknown parameters", "", ""},
+	{"Odd (USE (\\Drafts) USE (\\Sent))", "a1 BAD Command CREATE was sent with unknown parameters", "", ""},
+	{"Odd (USE (\"\\\\Drafts\"))", "a1 BAD Command CREATE was sent with invalid special-use attributes", "", ""},
+}
+
+var listSpecialUseTests = []struct {
+	payload string
+	lines   []string
+}{
+	{"\"\" *", []string{
+		"* LIST (\\HasNoChildren \\Archive) \".\" Archive",
+		"* LIST (\\HasNoChildren \\Junk \\Trash) \".\" Bin",
+		"* LIST (\\HasNoChildren) \".\" INBOX",
+		"* LIST (\\HasNoChildren) \".\" Plain",
+		"* LIST (\\HasNoChildren \\Sent) \".\" Sent",
+		"* LIST (\\Noselect \\HasChildren) \".\" Work",
+		"* LIST (\\HasNoChildren \\Drafts) \".\" Work.Drafts",
+	}},
+	{"(SPECIAL-USE) \"\" *", []string{
+		"* LIST (\\HasNoChildren \\Archive) \".\" Archive",
+		"* LIST (\\HasNoChildren \\Junk \\Trash) \".\" Bin",
+		"* LIST (\\HasNoChildren \\Sent) \".\" Sent",
+		"* LIST (\\HasNoChildren \\Drafts) \".\" Work.Drafts",
+	}},
+	{"(SPECIAL-USE) \"\" % RETURN (SPECIAL-USE)", []string{
+		"* LIST (\\HasNoChildren \\Archive) \".\" Archive",
+		"* LIST (\\HasNoChildren \\Junk \\Trash) \".\" Bin",
+		"* LIST (\\HasNoChildren \\Sent) \".\" Sent",
+	}},
+	{"\"\" Work.% RETURN (SPECIAL-USE)", []string{
+		"* LIST (\\HasNoChildren \\Drafts) \".\" Work.Drafts",
+	}},
+}
+
+// Functions
+
+// TestProvision executes a black-box unit test on
+// provisioning default folders of a new user.
+func TestProvision(t *testing.T) {
+
+	mailbox, cleanup := newTestMailbox(t)
+	defer cleanup()
+
+	syncChan := make(chan comm.Msg, 10)
+	marker := filepath.Join(mailbox.CRDTPath, ProvisionMarker)
+
+	// Users without marker are left untouched.
+	mailbox.Provision("user", defaultFolders, syncChan)
+	assert.Equalf(t, []string{"INBOX"}, sortedFolders(mailbox), "expected no folder to be provisioned")
+	assert.Equalf(t, 0, len(syncChan), "expected no update to be sent")
+
+	err := ioutil.WriteFile(marker, []byte{}, 0644)
+	assert.Nilf(t, err, "expected provisioning marker to be written but got: %v", err)
+
+	// New users receive all default folders, each
+	// created and subscribed via replicated updates.
+	mailbox.Provision("user", defaultFolders, syncChan)
+	assert.Equalf(t, []string{"INBOX", "Junk", "Notes", "Sent"}, sortedFolders(mailbox), "expected default folders to be provisioned")
+	assert.Equalf(t, "\\Sent", mailbox.specialUse("Sent"), "expected Sent to be marked \\Sent")
+	assert.Equalf(t, "\\Junk", mailbox.specialUse("Junk"), "expected Junk to be marked \\Junk")
+	assert.Equalf(t, "", mailbox.specialUse("Notes"), "expected Notes not to be marked")
+
+	for _, folder := range defaultFolders {
+		assert.Truef(t, mailbox.Subscriptions.Lookup(folder.Name), "expected %s to be subscribed", folder.Name)
+	}
+
+	operations := make([]string, 0, len(syncChan))
+	for len(syncChan) > 0 {
+		operations = append(operations, (<-syncChan).Operation)
+	}
+
+	assert.Equalf(t, []string{"create", "subscribe", "create", "subscribe", "create", "subscribe"}, operations, "unexpected updates")
+
+	_, err = os.Stat(marker)
+	assert.Truef(t, os.IsNotExist(err), "expected provisioning marker to be removed")
+
+	// Default folders the user deletes stay deleted.
+	s := &Session{
+		State:    StateAuthenticated,
+		UserName: "user",
+	}
+
+	req, _ := ParseRequest("a1 DELETE Junk")
+	reply, err := mailbox.Delete(s, req, syncChan)
+	assert.Nilf(t, err, "expected DELETE not to fail but got: %v", err)
+	assert.Equalf(t, "a1 OK DELETE completed", reply.Text, "expected Junk to be deleted")
+	<-syncChan
+
+	mailbox.Provision("user", defaultFolders, syncChan)
+	assert.Equalf(t, []string{"INBOX", "Notes", "Sent"}, sortedFolders(mailbox), "expected Junk not to be provisioned again")
+	assert.Equalf(t, 0, len(syncChan), "expected no update to be sent")
+}
+
+// TestCreateSpecialUse executes a black-box table
+// test on CREATE with special-use attributes as
+// defined by CREATE-SPECIAL-USE (RFC 6154).
+func TestCreateSpecialUse(t *testing.T) {
+
+	mailbox, cleanup := newTestMailbox(t)
+	defer cleanup()
+
+	s := &Session{
+		State:    StateAuthenticated,
+		UserName: "user",
+	}
+
+	syncChan := make(chan comm.Msg, 1)
+
+	for _, test := range createSpecialUseTests {
+
+		req, err := ParseRequest("a1 CREATE " + test.payload)
+		assert.Nilf(t, err, "expected CREATE %s to be parsed but got: %v", test.payload, err)
+
+		reply, err := mailbox.Create(s, req, syncChan)
+		assert.Nilf(t, err, "expected CREATE %s not to fail but got: %v", test.payload, err)
+		assert.Equalf(t, test.reply, reply.Text, "unexpected answer to CREATE %s", test.payload)
+
+		if test.folder == "" {
+			assert.Equalf(t, 0, len(syncChan), "expected no update for CREATE %s", test.payload)
+			continue
+		}
+
+		assert.Equalf(t, test.specialUse, mailbox.specialUse(test.folder), "unexpected special use of %s", test.folder)
+
+		upd := <-syncChan
+		uses := strings.Fields(upd.Create.SpecialUse)
+		sort.Strings(uses)
+
+		assert.Equalf(t, test.folder, upd.Create.Mailbox, "unexpected folder in update")
+		assert.Equalf(t, test.specialUse, strings.Join(uses, " "), "expected special use of %s in update", test.folder)
+	}
+
+	assert.Equalf(t, []string{"Archive", "Bin", "INBOX", "Plain", "Sent", "Work.Drafts"}, sortedFolders(mailbox), "expected refused folders not to be created")
+
+	// Attributes disappear with their folder
+	// and may be assigned anew afterwards.
+	req, _ := ParseRequest("a1 DELETE Sent")
+	mailbox.Delete(s, req, syncChan)
+	<-syncChan
+
+	_, found := mailbox.specialUseFolder("\\Sent")
+	assert.Falsef(t, found, "expected \\Sent to be released by DELETE")
+
+	req, _ = ParseRequest("a1 CREATE Outbox (USE (\\Sent))")
+	reply, _ := mailbox.Create(s, req, syncChan)
+	assert.Equalf(t, "a1 OK CREATE completed", reply.Text, "expected released \\Sent to be assignable")
+	assert.Equalf(t, "\\Sent", mailbox.specialUse("Outbox"), "expected Outbox to be marked \\Sent")
+}
+
+// TestListSpecialUse executes a black-box table test
+// on special-use attributes in LIST responses.
+func TestListSpecialUse(t *testing.T) {
+
+	mailbox, cleanup := newTestMailbox(t)
+	defer cleanup()
+
+	s := &Session{
+		State:    StateAuthenticated,
+		UserName: "user",
+	}
+
+	syncChan := make(chan comm.Msg, 1)
+
+	for _, test := range createSpecialUseTests[:5] {
+
+		req, _ := ParseRequest("a1 CREATE " + test.payload)
+		mailbox.Create(s, req, syncChan)
+		<-syncChan
+	}
+
+	for _, test := range listSpecialUseTests {
+
+		req, err := ParseRequest("a1 LIST " + test.payload)
+		assert.Nilf(t, err, "expected LIST %s to be parsed but got: %v", test.payload, err)
+
+		reply, err := mailbox.List(s, req, syncChan)
+		assert.Nilf(t, err, "expected LIST %s not to fail but got: %v", test.payload, err)
+		assert.Equalf(t, strings.Join(append(test.lines, "a1 OK LIST completed"), "\r\n"), reply.Text, "unexpected answer to LIST %s", test.payload)
+	}
+}
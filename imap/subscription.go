@@ -0,0 +1,274 @@
+package imap
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-pluto/pluto/comm"
+)
+
+// Functions
+
+// Subscribe adds the supplied mailbox to the set of
+// subscribed mailboxes of the user. The subscription
+// set is an OR-Set of its own, replicated the same
+// way as the structure OR-Set, so that concurrent
+// subscribes and unsubscribes converge on all nodes.
+func (mailbox *Mailbox) Subscribe(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+
+	if (s.State != StateAuthenticated) && (s.State != StateMailbox) {
+
+		// If connection was not in correct state when this
+		// command was executed, this is a client error.
+		// Send tagged BAD response.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command SUBSCRIBE cannot be executed in this state", req.Tag),
+		}, nil
+	}
+
+	var subscribeName string
+	ok := len(req.Args) == 1
+	if ok {
+		subscribeName, ok = s.mailboxName(req.Args[0])
+	}
+
+	if !ok {
+
+		// If payload did not contain exactly one element,
+		// this is a client error. Return BAD statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command SUBSCRIBE was not sent with exactly one parameter", req.Tag),
+		}, nil
+	}
+
+	subscribeMailbox := strings.TrimSuffix(subscribeName, mailbox.HierarchySeparator)
+	if strings.ToUpper(subscribeMailbox) == "INBOX" {
+		subscribeMailbox = "INBOX"
+	}
+
+	// Lock node exclusively to make execution
+	// of following CRDT operations atomic.
+	mailbox.Lock.Lock()
+	defer mailbox.Lock.Unlock()
+
+	if !mailbox.Structure.Lookup(subscribeMailbox) {
+
+		// If mailbox does not exist, this is
+		// a client error. Return NO statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s NO Cannot subscribe to folder that does not exist", req.Tag),
+		}, nil
+	}
+
+	if mailbox.Subscriptions.Lookup(subscribeMailbox) {
+
+		// Subscribing twice does not change
+		// anything. Return OK right away.
+		return &Reply{
+			Text: fmt.Sprintf("%s OK SUBSCRIBE completed", req.Tag),
+		}, nil
+	}
+
+	// Add the folder to the user's subscription
+	// CRDT and synchronize with other replicas.
+	err := mailbox.Subscriptions.Add(subscribeMailbox, "", func(args ...string) {
+		syncChan <- comm.Msg{
+			Operation: "subscribe",
+			Subscribe: &comm.Msg_SUBSCRIBE{
+				User:    s.UserName,
+				Mailbox: subscribeMailbox,
+				AddTag:  args[0],
+			},
+		}
+	})
+	if err != nil {
+
+		level.Error(mailbox.Logger).Log(
+			"msg", "failed to add folder to subscription CRDT during source SUBSCRIBE execution",
+			"err", err,
+		)
+		os.Exit(1)
+	}
+
+	return &Reply{
+		Text: fmt.Sprintf("%s OK SUBSCRIBE completed", req.Tag),
+	}, nil
+}
+
+// Unsubscribe removes the supplied mailbox from the
+// set of subscribed mailboxes of the user. Mailboxes
+// that do not exist anymore can still be unsubscribed.
+func (mailbox *Mailbox) Unsubscribe(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+
+	if (s.State != StateAuthenticated) && (s.State != StateMailbox) {
+
+		// If connection was not in correct state when this
+		// command was executed, this is a client error.
+		// Send tagged BAD response.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command UNSUBSCRIBE cannot be executed in this state", req.Tag),
+		}, nil
+	}
+
+	var unsubscribeName string
+	ok := len(req.Args) == 1
+	if ok {
+		unsubscribeName, ok = s.mailboxName(req.Args[0])
+	}
+
+	if !ok {
+
+		// If payload did not contain exactly one element,
+		// this is a client error. Return BAD statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command UNSUBSCRIBE was not sent with exactly one parameter", req.Tag),
+		}, nil
+	}
+
+	unsubscribeMailbox := strings.TrimSuffix(unsubscribeName, mailbox.HierarchySeparator)
+	if strings.ToUpper(unsubscribeMailbox) == "INBOX" {
+		unsubscribeMailbox = "INBOX"
+	}
+
+	// Lock node exclusively to make execution
+	// of following CRDT operations atomic.
+	mailbox.Lock.Lock()
+	defer mailbox.Lock.Unlock()
+
+	if !mailbox.Subscriptions.Lookup(unsubscribeMailbox) {
+
+		// If mailbox is not subscribed, this is
+		// a client error. Return NO statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s NO Cannot unsubscribe from folder that is not subscribed", req.Tag),
+		}, nil
+	}
+
+	// Remove all tags of the folder from the user's
+	// subscription CRDT and synchronize with other
+	// replicas. A concurrent SUBSCRIBE wins.
+	err := mailbox.Subscriptions.Remove(unsubscribeMailbox, func(args ...string) {
+		syncChan <- comm.Msg{
+			Operation: "unsubscribe",
+			Unsubscribe: &comm.Msg_UNSUBSCRIBE{
+				User:    s.UserName,
+				Mailbox: unsubscribeMailbox,
+				RmvTags: args,
+			},
+		}
+	})
+	if err != nil {
+
+		level.Error(mailbox.Logger).Log(
+			"msg", "failed to remove folder from subscription CRDT during source UNSUBSCRIBE execution",
+			"err", err,
+		)
+		os.Exit(1)
+	}
+
+	return &Reply{
+		Text: fmt.Sprintf("%s OK UNSUBSCRIBE completed", req.Tag),
+	}, nil
+}
+
+// Lsub lists all subscribed mailboxes matching the
+// supplied pattern relative to the reference name.
+// Subscribed mailboxes that do not exist anymore
+// are marked as \Noselect, as are levels of hierarchy
+// matched by '%' that only contain subscriptions below.
+func (mailbox *Mailbox) Lsub(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+
+	if (s.State != StateAuthenticated) && (s.State != StateMailbox) {
+
+		// If connection was not in correct state when this
+		// command was executed, this is a client error.
+		// Send tagged BAD response.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command LSUB cannot be executed in this state", req.Tag),
+		}, nil
+	}
+
+	var reference, lsubPattern string
+	ok := len(req.Args) == 2
+	if ok {
+		reference, ok = s.mailboxName(req.Args[0])
+	}
+	if ok {
+		lsubPattern, ok = s.mailboxName(req.Args[1])
+	}
+
+	if !ok {
+
+		// If payload did not contain a reference and
+		// a pattern, this is a client error.
+		// Return BAD statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command LSUB was not sent with a reference and a mailbox name", req.Tag),
+		}, nil
+	}
+
+	pattern := mailbox.canonicalPattern(reference, lsubPattern)
+
+	mailbox.Lock.RLock()
+
+	subscribedFolders := mailbox.Subscriptions.GetAllValues()
+
+	// Find all names to list and whether they
+	// are subscribed themselves.
+	lsubNames := make(map[string]bool)
+
+	for _, subscribedFolder := range subscribedFolders {
+
+		if mailbox.listMatches(pattern, subscribedFolder) {
+			lsubNames[subscribedFolder] = true
+			continue
+		}
+
+		if !strings.HasSuffix(pattern, "%") {
+			continue
+		}
+
+		// Otherwise, list the deepest matching level
+		// of hierarchy above the subscribed folder.
+		for i := strings.LastIndex(subscribedFolder, mailbox.HierarchySeparator); i > 0; i = strings.LastIndex(subscribedFolder[:i], mailbox.HierarchySeparator) {
+
+			if mailbox.listMatches(pattern, subscribedFolder[:i]) {
+
+				if !lsubNames[subscribedFolder[:i]] {
+					lsubNames[subscribedFolder[:i]] = false
+				}
+
+				break
+			}
+		}
+	}
+
+	names := make([]string, 0, len(lsubNames))
+	for name := range lsubNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	answerLines := make([]string, 0, (len(names) + 1))
+
+	for _, name := range names {
+
+		attributes := ""
+		if !lsubNames[name] || !mailbox.Structure.Lookup(name) {
+			attributes = "\\Noselect"
+		}
+
+		answerLines = append(answerLines, fmt.Sprintf("* LSUB (%s) \"%s\" %s", attributes, mailbox.HierarchySeparator, s.quoteMailbox(name)))
+	}
+
+	mailbox.Lock.RUnlock()
+
+	answerLines = append(answerLines, fmt.Sprintf("%s OK LSUB completed", req.Tag))
+
+	return &Reply{
+		Text: strings.Join(answerLines, "\r\n"),
+	}, nil
+}
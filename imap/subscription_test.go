@@ -0,0 +1,142 @@
+package imap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-pluto/pluto/comm"
+	"github.com/stretchr/testify/assert"
+)
+
+// Variables
+
+var subscribeTests = []struct {
+	command string
+	payload string
+	answer  string
+	update  string
+}{
+	{CommandSubscribe, "Work.Projects", "a1 OK SUBSCRIBE completed", "Work.Projects"},
+	{CommandSubscribe, "inbox", "a1 OK SUBSCRIBE completed", "INBOX"},
+	{CommandSubscribe, "Work.Projects.Pluto", "a1 OK SUBSCRIBE completed", "Work.Projects.Pluto"},
+	{CommandSubscribe, "Archive.", "a1 OK SUBSCRIBE completed", "Archive"},
+	{CommandSubscribe, "Work.Projects", "a1 OK SUBSCRIBE completed", ""},
+	{CommandSubscribe, "Missing", "a1 NO Cannot subscribe to folder that does not exist", ""},
+	{CommandSubscribe, "", "a1 BAD Command SUBSCRIBE was not sent with exactly one parameter", ""},
+	{CommandSubscribe, "Work Archive", "a1 BAD Command SUBSCRIBE was not sent with exactly one parameter", ""},
+	{CommandUnsubscribe, "Missing", "a1 NO Cannot unsubscribe from folder that is not subscribed", ""},
+	{CommandUnsubscribe, "", "a1 BAD Command UNSUBSCRIBE was not sent with exactly one parameter", ""},
+}
+
+var lsubTests = []struct {
+	payload string
+	lines   []string
+}{
+	{"\"\" *", []string{
+		"* LSUB () \".\" Archive",
+		"* LSUB () \".\" INBOX",
+		"* LSUB () \".\" Work.Projects",
+		"* LSUB (\\Noselect) \".\" Work.Projects.Pluto",
+		"a1 OK LSUB completed",
+	}},
+	{"\"\" %", []string{
+		"* LSUB () \".\" Archive",
+		"* LSUB () \".\" INBOX",
+		"* LSUB (\\Noselect) \".\" Work",
+		"a1 OK LSUB completed",
+	}},
+	{"Work %", []string{
+		"* LSUB () \".\" Work.Projects",
+		"a1 OK LSUB completed",
+	}},
+	{"Work.Projects %", []string{
+		"* LSUB (\\Noselect) \".\" Work.Projects.Pluto",
+		"a1 OK LSUB completed",
+	}},
+	{"\"\" Inbox", []string{
+		"* LSUB () \".\" INBOX",
+		"a1 OK LSUB completed",
+	}},
+	{"\"\" Missing", []string{
+		"a1 OK LSUB completed",
+	}},
+	{"\"\"", []string{
+		"a1 BAD Command LSUB was not sent with a reference and a mailbox name",
+	}},
+}
+
+// Functions
+
+// TestSubscriptions executes a black-box table test
+// on SUBSCRIBE, UNSUBSCRIBE, and LSUB.
+func TestSubscriptions(t *testing.T) {
+
+	mailbox, cleanup := newTestMailbox(t, "Archive", "Work", "Work.Projects", "Work.Projects.Pluto")
+	defer cleanup()
+
+	s := &Session{
+		State:    StateAuthenticated,
+		UserName: "user",
+	}
+
+	syncChan := make(chan comm.Msg, 1)
+
+	run := func(command string, payload string) *Reply {
+
+		req, err := ParseRequest(strings.TrimSpace("a1 " + command + " " + payload))
+		assert.Nilf(t, err, "expected %s %s to be parsed but got: %v", command, payload, err)
+
+		var reply *Reply
+		switch command {
+		case CommandSubscribe:
+			reply, err = mailbox.Subscribe(s, req, syncChan)
+		case CommandUnsubscribe:
+			reply, err = mailbox.Unsubscribe(s, req, syncChan)
+		case CommandDelete:
+			reply, err = mailbox.Delete(s, req, syncChan)
+		default:
+			reply, err = mailbox.Lsub(s, req, syncChan)
+		}
+
+		assert.Nilf(t, err, "expected %s %s not to fail but got: %v", command, payload, err)
+
+		return reply
+	}
+
+	for _, test := range subscribeTests {
+
+		assert.Equalf(t, test.answer, run(test.command, test.payload).Text, "unexpected answer to %s %s", test.command, test.payload)
+
+		if test.update == "" {
+			assert.Equalf(t, 0, len(syncChan), "expected no update for %s %s", test.command, test.payload)
+			continue
+		}
+
+		upd := <-syncChan
+		assert.Equalf(t, "subscribe", upd.Operation, "expected a SUBSCRIBE update")
+		assert.Equalf(t, test.update, upd.Subscribe.Mailbox, "unexpected folder in update")
+		assert.Truef(t, mailbox.Subscriptions.Lookup(test.update), "expected %s to be subscribed", test.update)
+	}
+
+	// Deleted folders stay subscribed.
+	run(CommandDelete, "Work.Projects.Pluto")
+	<-syncChan
+
+	for _, test := range lsubTests {
+		assert.Equalf(t, strings.Join(test.lines, "\r\n"), run(CommandLsub, test.payload).Text, "unexpected answer to LSUB %s", test.payload)
+	}
+
+	// Subscriptions of deleted folders
+	// can still be removed.
+	for _, folder := range []string{"Work.Projects.Pluto", "INBOX"} {
+
+		assert.Equalf(t, "a1 OK UNSUBSCRIBE completed", run(CommandUnsubscribe, folder).Text, "expected %s to be unsubscribed", folder)
+
+		upd := <-syncChan
+		assert.Equalf(t, "unsubscribe", upd.Operation, "expected an UNSUBSCRIBE update")
+		assert.Equalf(t, folder, upd.Unsubscribe.Mailbox, "unexpected folder in update")
+		assert.Equalf(t, 1, len(upd.Unsubscribe.RmvTags), "expected tag of subscription to be removed")
+	}
+
+	assert.Equalf(t, "* LSUB () \".\" Archive\r\n* LSUB () \".\" Work.Projects\r\na1 OK LSUB completed", run(CommandLsub, "\"\" *").Text, "expected subscriptions to be removed")
+}
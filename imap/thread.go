@@ -0,0 +1,408 @@
+package imap
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-pluto/pluto/comm"
+)
+
+// Structs
+
+// threadNode is one message of a thread along with
+// its replies. Nodes without message are placeholders
+// for messages referenced but not present.
+type threadNode struct {
+	msg      *searchMsg
+	parent   *threadNode
+	children []*threadNode
+}
+
+// Functions
+
+// first returns the message node stands for in
+// comparisons, which is the one of its first child
+// if node is a placeholder.
+func (node *threadNode) first() *searchMsg {
+
+	if (node.msg == nil) && (len(node.children) > 0) {
+		return node.children[0].first()
+	}
+
+	return node.msg
+}
+
+// descendsFrom returns true if node is
+// ancestor or one of its descendants.
+func (node *threadNode) descendsFrom(ancestor *threadNode) bool {
+
+	for n := node; n != nil; n = n.parent {
+
+		if n == ancestor {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unlink removes node from the replies
+// of its parent, if it has one.
+func (node *threadNode) unlink() {
+
+	if node.parent == nil {
+		return
+	}
+
+	siblings := node.parent.children
+	for i, sibling := range siblings {
+
+		if sibling == node {
+			node.parent.children = append(siblings[:i], siblings[(i+1):]...)
+			break
+		}
+	}
+
+	node.parent = nil
+}
+
+// link makes child a reply to node, removing
+// it from the replies of its previous parent.
+func (node *threadNode) link(child *threadNode) {
+
+	child.unlink()
+	child.parent = node
+	node.children = append(node.children, child)
+}
+
+// sortThreads orders nodes and, recursively, their
+// replies by sent date and sequence number.
+func sortThreads(nodes []*threadNode) {
+
+	for _, node := range nodes {
+		sortThreads(node.children)
+	}
+
+	sort.SliceStable(nodes, func(i, j int) bool {
+
+		a, b := nodes[i].first(), nodes[j].first()
+
+		if cmp := compareTimes(a.entry.SortDate(), b.entry.SortDate()); cmp != 0 {
+			return cmp < 0
+		}
+
+		return a.seqNum < b.seqNum
+	})
+}
+
+// threadOrderedSubject implements the ORDEREDSUBJECT
+// algorithm of RFC 5256. Messages sharing the same base
+// subject form one thread in which all messages are
+// replies to the earliest one.
+func threadOrderedSubject(msgs []*searchMsg) []*threadNode {
+
+	sorted := append([]*searchMsg(nil), msgs...)
+	sortMsgs(sorted, []sortCriterion{{key: "SUBJECT"}, {key: "DATE"}})
+
+	threads := make([]*threadNode, 0)
+	var thread *threadNode
+
+	for _, msg := range sorted {
+
+		if (thread != nil) && (thread.msg.entry.BaseSubject == msg.entry.BaseSubject) {
+			thread.link(&threadNode{msg: msg})
+			continue
+		}
+
+		thread = &threadNode{msg: msg}
+		threads = append(threads, thread)
+	}
+
+	sortThreads(threads)
+
+	return threads
+}
+
+// pruneThreads removes placeholders without replies
+// from nodes and replaces placeholders by their replies
+// unless they are the root of more than one thread.
+func pruneThreads(nodes []*threadNode, isRoot bool) []*threadNode {
+
+	pruned := make([]*threadNode, 0, len(nodes))
+
+	for _, node := range nodes {
+
+		node.children = pruneThreads(node.children, false)
+
+		if (node.msg == nil) && (!isRoot || (len(node.children) < 2)) {
+
+			for _, child := range node.children {
+				child.parent = node.parent
+			}
+
+			pruned = append(pruned, node.children...)
+			continue
+		}
+
+		pruned = append(pruned, node)
+	}
+
+	return pruned
+}
+
+// mergeBySubject gathers threads of the same base
+// subject as step 5 of the REFERENCES algorithm
+// describes and returns the remaining threads.
+func mergeBySubject(threads []*threadNode) []*threadNode {
+
+	subjects := make(map[string]*threadNode)
+
+	for _, thread := range threads {
+
+		entry := thread.first().entry
+		if entry.BaseSubject == "" {
+			continue
+		}
+
+		// Placeholders and messages that are no
+		// replies are preferred as thread roots.
+		prev, found := subjects[entry.BaseSubject]
+		if !found || ((thread.msg == nil) && (prev.msg != nil)) || ((prev.msg != nil) && prev.first().entry.IsReply && (thread.msg != nil) && !entry.IsReply) {
+			subjects[entry.BaseSubject] = thread
+		}
+	}
+
+	merged := make([]*threadNode, 0, len(threads))
+
+	for _, thread := range threads {
+
+		entry := thread.first().entry
+
+		root, found := subjects[entry.BaseSubject]
+		if !found || (root == thread) {
+			merged = append(merged, thread)
+			continue
+		}
+
+		switch {
+
+		case (root.msg == nil) && (thread.msg == nil):
+			for len(thread.children) > 0 {
+				root.link(thread.children[0])
+			}
+
+		case root.msg == nil:
+			root.link(thread)
+
+		case (thread.msg != nil) && !root.first().entry.IsReply && entry.IsReply:
+			root.link(thread)
+
+		default:
+
+			// Turn root into a placeholder for
+			// both threads, keeping its position.
+			prevRoot := &threadNode{msg: root.msg}
+			for len(root.children) > 0 {
+				prevRoot.link(root.children[0])
+			}
+
+			root.msg = nil
+			root.link(prevRoot)
+			root.link(thread)
+		}
+	}
+
+	return merged
+}
+
+// threadReferences implements the REFERENCES algorithm
+// of RFC 5256, which arranges messages by the message
+// IDs in their References or In-Reply-To header fields
+// and then gathers threads of the same base subject.
+func threadReferences(msgs []*searchMsg) []*threadNode {
+
+	ids := make(map[string]*threadNode)
+	nodes := make([]*threadNode, 0, len(msgs))
+
+	node := func(id string) *threadNode {
+
+		n, found := ids[id]
+		if !found {
+			n = &threadNode{}
+			ids[id] = n
+			nodes = append(nodes, n)
+		}
+
+		return n
+	}
+
+	for _, msg := range msgs {
+
+		// Messages without or with a duplicate message ID
+		// get one of their own. It cannot collide with a
+		// real one as those are enclosed in brackets.
+		id := msg.entry.MessageID
+		if n, found := ids[id]; (id == "") || (found && (n.msg != nil)) {
+			id = strconv.Itoa(msg.seqNum)
+		}
+
+		msgNode := node(id)
+		msgNode.msg = msg
+
+		// Link referenced messages to each other in
+		// order, keeping existing links and avoiding
+		// loops.
+		var parent *threadNode
+		for _, ref := range msg.entry.References {
+
+			refNode := node(ref)
+
+			if (parent != nil) && (refNode.parent == nil) && !parent.descendsFrom(refNode) {
+				parent.link(refNode)
+			}
+
+			parent = refNode
+		}
+
+		// The last reference becomes the message's parent,
+		// overriding any parent established before.
+		if (parent != nil) && parent.descendsFrom(msgNode) {
+			parent = nil
+		}
+
+		if parent != nil {
+			parent.link(msgNode)
+		} else {
+			msgNode.unlink()
+		}
+	}
+
+	roots := make([]*threadNode, 0)
+	for _, n := range nodes {
+
+		if n.parent == nil {
+			roots = append(roots, n)
+		}
+	}
+
+	threads := mergeBySubject(pruneThreads(roots, true))
+	sortThreads(threads)
+
+	return threads
+}
+
+// formatThread returns the members of a thread as
+// listed in a THREAD response without the enclosing
+// parentheses. Messages are given by UID if useUID
+// is true and else by sequence number.
+func formatThread(node *threadNode, useUID bool) string {
+
+	members := make([]string, 0, 2)
+
+	if node.msg != nil {
+
+		if useUID {
+			members = append(members, strconv.FormatUint(uint64(node.msg.uid), 10))
+		} else {
+			members = append(members, strconv.Itoa(node.msg.seqNum+1))
+		}
+	}
+
+	switch len(node.children) {
+
+	case 0:
+
+	case 1:
+		members = append(members, formatThread(node.children[0], useUID))
+
+	default:
+
+		branches := make([]string, len(node.children))
+		for i, child := range node.children {
+			branches[i] = fmt.Sprintf("(%s)", formatThread(child, useUID))
+		}
+
+		members = append(members, strings.Join(branches, ""))
+	}
+
+	return strings.Join(members, " ")
+}
+
+// Thread returns the message sequence numbers of all
+// messages in the selected mailbox matching supplied
+// search keys arranged in threads.
+func (mailbox *Mailbox) Thread(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+	return mailbox.thread(s, req, false)
+}
+
+// thread implements THREAD and UID THREAD with the
+// ORDEREDSUBJECT and REFERENCES algorithms of RFC 5256.
+// If useUID is true, UIDs are returned instead of
+// message sequence numbers.
+func (mailbox *Mailbox) thread(s *Session, req *Request, useUID bool) (*Reply, error) {
+
+	if s.State != StateMailbox {
+
+		// If connection was not in correct state when this
+		// command was executed, this is a client error.
+		// Send tagged BAD response.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD No mailbox selected to thread", req.Tag),
+		}, nil
+	}
+
+	if (len(req.Args) == 0) || (req.Args[0].Type != NodeAtom) {
+
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command THREAD was sent with invalid number of parameters", req.Tag),
+		}, nil
+	}
+
+	var algorithm func(msgs []*searchMsg) []*threadNode
+
+	switch strings.ToUpper(req.Args[0].Value) {
+
+	case "ORDEREDSUBJECT":
+		algorithm = threadOrderedSubject
+
+	case "REFERENCES":
+		algorithm = threadReferences
+
+	default:
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command THREAD was sent with unknown algorithm %s", req.Tag, req.Args[0].Value),
+		}, nil
+	}
+
+	// Lock node exclusively as evaluating keys
+	// may add missing entries to the index.
+	mailbox.Lock.Lock()
+	defer mailbox.Lock.Unlock()
+
+	matches, _, reply, err := mailbox.searchSortArgs(s, req, CommandThread)
+	if reply != nil {
+		return reply, err
+	}
+
+	answer := "* THREAD"
+	if len(matches) > 0 {
+		answer = "* THREAD "
+	}
+
+	for _, thread := range algorithm(matches) {
+		answer = fmt.Sprintf("%s(%s)", answer, formatThread(thread, useUID))
+	}
+
+	return &Reply{
+		Text: fmt.Sprintf("%s\r\n%s OK THREAD completed", answer, req.Tag),
+	}, nil
+}
+
+// init registers the threading algorithms
+// as capabilities.
+func init() {
+	RegisterCapability(&Capability{Name: "THREAD=ORDEREDSUBJECT", PostAuth: true})
+	RegisterCapability(&Capability{Name: "THREAD=REFERENCES", PostAuth: true})
+}
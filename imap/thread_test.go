@@ -0,0 +1,98 @@
+package imap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Functions
+
+// threadTestMsgs returns messages in sequence order
+// carrying supplied subjects, message IDs, and
+// references, sent one hour apart.
+func threadTestMsgs(headers [][]string) []*searchMsg {
+
+	msgs := make([]*searchMsg, len(headers))
+	sent := time.Date(2017, time.March, 1, 8, 0, 0, 0, time.UTC)
+
+	for i, header := range headers {
+
+		base, isReply := baseSubject(header[0])
+
+		msgs[i] = &searchMsg{
+			seqNum: i,
+			uid:    uint32(i + 10),
+			entry: &IndexEntry{
+				SentDate:    sent.Add(time.Duration(i) * time.Hour),
+				BaseSubject: base,
+				IsReply:     isReply,
+				MessageID:   header[1],
+				References:  messageIDs(header[2]),
+			},
+		}
+	}
+
+	return msgs
+}
+
+// formatThreads renders threads as in
+// a THREAD response.
+func formatThreads(threads []*threadNode, useUID bool) string {
+
+	answer := ""
+	for _, thread := range threads {
+		answer = answer + "(" + formatThread(thread, useUID) + ")"
+	}
+
+	return answer
+}
+
+// TestThreadReferences executes a white-box unit
+// test on implemented threadReferences() function.
+func TestThreadReferences(t *testing.T) {
+
+	msgs := threadTestMsgs([][]string{
+		{"plans", "<1@pluto>", ""},
+		{"re: plans", "<2@pluto>", "<1@pluto>"},
+		{"lunch", "<3@pluto>", ""},
+		{"re: plans", "<4@pluto>", "<1@pluto> <2@pluto>"},
+		{"re: plans", "<5@pluto>", "<1@pluto>"},
+		{"re: lunch", "<6@pluto>", "<missing@pluto>"},
+		{"re: holidays", "<7@pluto>", "<gone@pluto>"},
+		{"re: holidays", "<8@pluto>", "<gone@pluto>"},
+	})
+
+	// Message 6 is gathered under 3 by subject, the
+	// replies to the missing message 7 and 8 refer
+	// to are kept together under a placeholder.
+	threads := threadReferences(msgs)
+	assert.Equalf(t, "(1 (2 4)(5))(3 6)((7)(8))", formatThreads(threads, false), "unexpected threads")
+	assert.Equalf(t, "(10 (11 13)(14))(12 15)((16)(17))", formatThreads(threads, true), "unexpected threads by UID")
+
+	// References forming a loop are ignored,
+	// the earlier link is kept.
+	msgs = threadTestMsgs([][]string{
+		{"a", "<1@pluto>", "<2@pluto>"},
+		{"b", "<2@pluto>", "<1@pluto>"},
+	})
+
+	assert.Equalf(t, "(2 1)", formatThreads(threadReferences(msgs), false), "unexpected threads of looping references")
+}
+
+// TestThreadOrderedSubject executes a white-box unit
+// test on implemented threadOrderedSubject() function.
+func TestThreadOrderedSubject(t *testing.T) {
+
+	msgs := threadTestMsgs([][]string{
+		{"plans", "", ""},
+		{"lunch", "", ""},
+		{"re: plans", "", ""},
+		{"holidays", "", ""},
+		{"re: lunch", "", ""},
+		{"fwd: plans", "", ""},
+	})
+
+	assert.Equalf(t, "(1 (3)(6))(2 5)(4)", formatThreads(threadOrderedSubject(msgs), false), "unexpected threads")
+}
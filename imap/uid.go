@@ -0,0 +1,211 @@
+package imap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"path/filepath"
+
+	"github.com/go-pluto/pluto/comm"
+)
+
+// Functions
+
+// MailKey returns the unique Maildir key contained in a
+// mail file name. As the key does not change when flags
+// are modified, it identifies a message for its lifetime.
+func MailKey(mailFileName string) string {
+
+	if i := strings.IndexRune(mailFileName, ':'); i >= 0 {
+		return mailFileName[:i]
+	}
+
+	return mailFileName
+}
+
+// mailUID returns the UID of supplied mail file in
+// folder or zero if no UID is known for it.
+func (mailbox *Mailbox) mailUID(folder string, mailFileName string) uint32 {
+
+	uid, _ := mailbox.UIDs.UID(folder, MailKey(mailFileName))
+
+	return uid
+}
+
+// folderUIDs returns the UIDs of all mails in folder
+// in order of their message sequence numbers.
+func (mailbox *Mailbox) folderUIDs(folder string) []uint32 {
+
+	uids := make([]uint32, len(mailbox.Mails[folder]))
+
+	for i, mailFileName := range mailbox.Mails[folder] {
+		uids[i] = mailbox.mailUID(folder, mailFileName)
+	}
+
+	return uids
+}
+
+// formatUIDSet renders UIDs as sequence set of the
+// form used in UIDPLUS (RFC 4315) response codes,
+// joining runs of consecutive UIDs into ranges. The
+// order of supplied UIDs is preserved.
+func formatUIDSet(uids []uint32) string {
+
+	ranges := make([]string, 0, len(uids))
+
+	for i := 0; i < len(uids); {
+
+		j := i
+		for ((j + 1) < len(uids)) && (uids[(j+1)] == (uids[j] + 1)) {
+			j++
+		}
+
+		if i == j {
+			ranges = append(ranges, fmt.Sprintf("%d", uids[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d:%d", uids[i], uids[j]))
+		}
+
+		i = j + 1
+	}
+
+	return strings.Join(ranges, ",")
+}
+
+// insertMail places supplied mail file name in the
+// message sequence number tracking structure of folder
+// at the position its UID demands. As the relative order
+// of UIDs never changes, the structure stays sorted.
+func (mailbox *Mailbox) insertMail(folder string, mailFileName string) {
+
+	mails := mailbox.Mails[folder]
+	uid := mailbox.mailUID(folder, mailFileName)
+
+	pos := sort.Search(len(mails), func(i int) bool {
+		return mailbox.mailUID(folder, mails[i]) > uid
+	})
+
+	mails = append(mails, "")
+	copy(mails[(pos+1):], mails[pos:])
+	mails[pos] = mailFileName
+
+	mailbox.Mails[folder] = mails
+}
+
+// TrackMails is used when building up state from stable
+// storage and sorts the mails of folder by UID. If the
+// UID CRDT was just created, i.e. assignUIDs is true,
+// mails are assigned UIDs in order of their keys. These
+// assignments are not replicated, as all replicas start
+// out with the same mail files when they first introduce
+// UIDs. Afterwards, each mail delivered by pluto has a UID,
+// hence untracked mails were placed in the Maildir behind
+// pluto's back on this replica only and fail startup.
+func (mailbox *Mailbox) TrackMails(folder string, assignUIDs bool) error {
+
+	untracked := make([]string, 0)
+
+	for _, mailFileName := range mailbox.Mails[folder] {
+
+		if _, found := mailbox.UIDs.UID(folder, MailKey(mailFileName)); !found {
+			untracked = append(untracked, MailKey(mailFileName))
+		}
+	}
+
+	if !assignUIDs && (len(untracked) > 0) {
+		return fmt.Errorf("folder %s contains %d mails not delivered via pluto, e.g. %s, which other replicas would never learn of", folder, len(untracked), filepath.Join(mailbox.FolderPath(folder), "cur", untracked[0]))
+	}
+
+	sort.Strings(untracked)
+
+	for _, key := range untracked {
+
+		err := mailbox.UIDs.Add(folder, key, func(origUID uint32) {})
+		if err != nil {
+			return fmt.Errorf("failed to assign UID to untracked mail: %v", err)
+		}
+	}
+
+	mails := mailbox.Mails[folder]
+	sort.Slice(mails, func(i, j int) bool {
+		return mailbox.mailUID(folder, mails[i]) < mailbox.mailUID(folder, mails[j])
+	})
+
+	return nil
+}
+
+// UID handles the UID variants of FETCH, STORE, SEARCH,
+// EXPUNGE, COPY, and MOVE. Messages are addressed by their UID
+// instead of by message sequence number and responses
+// always contain the UID of affected messages.
+func (mailbox *Mailbox) UID(s *Session, req *Request, syncChan chan comm.Msg) (*Reply, error) {
+
+	if s.State != StateMailbox {
+
+		// If connection was not in correct state when this
+		// command was executed, this is a client error.
+		// Send tagged BAD response.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD No mailbox selected for UID command", req.Tag),
+		}, nil
+	}
+
+	if (len(req.Args) == 0) || (req.Args[0].Type != NodeAtom) {
+
+		// If no command followed UID, this is
+		// a client error. Return BAD statement.
+		return &Reply{
+			Text: fmt.Sprintf("%s BAD Command UID was sent without a command", req.Tag),
+		}, nil
+	}
+
+	// The first argument is the command,
+	// all others are passed on to it.
+	uidReq := &Request{
+		Tag:     req.Tag,
+		Command: strings.ToUpper(req.Args[0].Value),
+		Args:    req.Args[1:],
+	}
+
+	if uidPayload := strings.SplitN(req.Payload, " ", 2); len(uidPayload) > 1 {
+		uidReq.Payload = uidPayload[1]
+	}
+
+	switch uidReq.Command {
+
+	case CommandFetch:
+		return mailbox.fetch(s, uidReq, true, syncChan)
+
+	case CommandStore:
+		return mailbox.store(s, uidReq, true, syncChan)
+
+	case CommandSearch:
+		return mailbox.search(s, uidReq, true)
+
+	case CommandSort:
+		return mailbox.sort(s, uidReq, true)
+
+	case CommandThread:
+		return mailbox.thread(s, uidReq, true)
+
+	case CommandExpunge:
+		return mailbox.expunge(s, uidReq, true, syncChan)
+
+	case CommandCopy:
+		return mailbox.copyMails(s, uidReq, true, false, syncChan)
+
+	case CommandMove:
+		return mailbox.copyMails(s, uidReq, true, true, syncChan)
+	}
+
+	// Any other command is a client error.
+	return &Reply{
+		Text: fmt.Sprintf("%s BAD Command UID was sent with unsupported command", req.Tag),
+	}, nil
+}
+
+// init registers the UIDPLUS capability.
+func init() {
+	RegisterCapability(&Capability{Name: "UIDPLUS", PostAuth: true})
+}
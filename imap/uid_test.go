@@ -0,0 +1,69 @@
+package imap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Variables
+
+var uidSetTests = []struct {
+	uids []uint32
+	out  string
+}{
+	{[]uint32{}, ""},
+	{[]uint32{7}, "7"},
+	{[]uint32{1, 2, 3}, "1:3"},
+	{[]uint32{1, 2, 3, 5, 8, 9}, "1:3,5,8:9"},
+	{[]uint32{9, 3, 4}, "9,3:4"},
+	{[]uint32{4, 3}, "4,3"},
+}
+
+// Functions
+
+// TestFormatUIDSet executes a white-box table
+// test on implemented formatUIDSet() function.
+func TestFormatUIDSet(t *testing.T) {
+
+	for _, test := range uidSetTests {
+
+		out := formatUIDSet(test.uids)
+		assert.Equalf(t, test.out, out, "unexpected UID set for %v", test.uids)
+	}
+}
+
+// TestTrackMails executes a white-box unit test on
+// assigning UIDs to mails found on stable storage.
+func TestTrackMails(t *testing.T) {
+
+	mailbox, cleanup := newTestMailbox(t)
+	defer cleanup()
+
+	// Mails present when UIDs are introduced are
+	// assigned UIDs in order of their keys.
+	mailbox.Mails["INBOX"] = []string{"1400000002.b:2,S", "1400000001.a:2,", "1400000003.c:2,F"}
+
+	err := mailbox.TrackMails("INBOX", true)
+	assert.Nilf(t, err, "expected untracked mails to be assigned UIDs but got: %v", err)
+	assert.Equalf(t, []string{"1400000001.a:2,", "1400000002.b:2,S", "1400000003.c:2,F"}, mailbox.Mails["INBOX"], "expected mails to be ordered by UID")
+	assert.Equalf(t, []uint32{1, 2, 3}, mailbox.folderUIDs("INBOX"), "unexpected UIDs")
+
+	// Tracked mails are only ordered by UID.
+	mailbox.Mails["INBOX"] = []string{"1400000003.c:2,F", "1400000001.a:2,"}
+
+	err = mailbox.TrackMails("INBOX", false)
+	assert.Nilf(t, err, "expected tracked mails to be accepted but got: %v", err)
+	assert.Equalf(t, []string{"1400000001.a:2,", "1400000003.c:2,F"}, mailbox.Mails["INBOX"], "expected mails to be ordered by UID")
+
+	// Afterwards, a mail placed in the Maildir behind
+	// pluto's back would get a UID no other replica
+	// learns of, thus startup fails.
+	mailbox.Mails["INBOX"] = append(mailbox.Mails["INBOX"], "1400000000.x:2,")
+
+	err = mailbox.TrackMails("INBOX", false)
+	assert.NotNilf(t, err, "expected untracked mail to fail startup")
+
+	_, found := mailbox.UIDs.UID("INBOX", "1400000000.x")
+	assert.Falsef(t, found, "expected no UID to be assigned locally")
+}
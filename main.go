@@ -1,8 +1,10 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"runtime"
@@ -20,6 +22,7 @@ import (
 	"github.com/go-pluto/pluto/config"
 	"github.com/go-pluto/pluto/crypto"
 	"github.com/go-pluto/pluto/distributor"
+	"github.com/go-pluto/pluto/imap"
 	"github.com/go-pluto/pluto/storage"
 	"github.com/go-pluto/pluto/worker"
 	"github.com/satori/go.uuid"
@@ -35,13 +38,24 @@ func initAuthenticator(config *config.Config) (distributor.Authenticator, error)
 	switch config.Distributor.AuthAdapter {
 	case "AuthPostgres":
 		// Connect to PostgreSQL database.
-		return auth.NewPostgresAuthenticator(
-			config.Distributor.AuthPostgres.IP,
-			config.Distributor.AuthPostgres.Port,
-			config.Distributor.AuthPostgres.Database,
-			config.Distributor.AuthPostgres.User,
-			config.Distributor.AuthPostgres.Password,
-			config.Distributor.AuthPostgres.UseTLS,
+		if config.Distributor.AuthPostgres == nil {
+			return nil, fmt.Errorf("AuthPostgres adapter selected but not configured")
+		}
+		return auth.NewPostgresAuthenticator(config.Distributor.AuthPostgres)
+	case "AuthOAuth":
+		if config.Distributor.AuthOAuth == nil {
+			return nil, fmt.Errorf("AuthOAuth adapter selected but not configured")
+		}
+		// Validate bearer tokens of SSO-backed users.
+		return auth.NewOAuthAuthenticator(config.Distributor.AuthOAuth)
+	case "AuthLDAP":
+		if config.Distributor.AuthLDAP == nil {
+			return nil, fmt.Errorf("AuthLDAP adapter selected but not configured")
+		}
+		// Connect to the LDAP directory.
+		return auth.NewLDAPAuthenticator(
+			config.Distributor.AuthLDAP,
+			config.Workers,
 		)
 	default: // AuthFile
 		// Open authentication file and read user information.
@@ -52,6 +66,40 @@ func initAuthenticator(config *config.Config) (distributor.Authenticator, error)
 	}
 }
 
+// runPasswd reads a password from stdin and prints its
+// hash in the scheme chosen via flag, ready to be put
+// into a users file or table. It returns the exit code.
+func runPasswd(args []string) int {
+
+	passwdFlags := flag.NewFlagSet("passwd", flag.ExitOnError)
+	schemeFlag := passwdFlags.String("scheme", auth.PreferredScheme, "Password scheme to hash with: ARGON2ID, BLF-CRYPT, SHA512-CRYPT, SSHA512, or PLAIN.")
+	passwdFlags.Parse(args)
+
+	fmt.Fprint(os.Stderr, "Password: ")
+
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if (err != nil) && (err != io.EOF) {
+		fmt.Fprintf(os.Stderr, "failed to read password: %v\n", err)
+		return 1
+	}
+
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		fmt.Fprintln(os.Stderr, "password must not be empty")
+		return 1
+	}
+
+	hash, err := auth.HashPassword(*schemeFlag, password)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to hash password: %v\n", err)
+		return 1
+	}
+
+	fmt.Println(hash)
+
+	return 0
+}
+
 // initLogger initializes a JSON gokit-logger set
 // to the according log level supplied via cli flag.
 func initLogger(loglevel string) log.Logger {
@@ -81,8 +129,10 @@ func initLogger(loglevel string) log.Logger {
 
 // createUserFiles adds the required files and folders
 // for the number of test users we make use of in our tests.
-// This concerns Maildir and CRDT files and folders.
-func createUserFiles(crdtLayerRoot string, maildirRoot string, start int, end int) error {
+// This concerns Maildir and CRDT files and folders. If
+// provision is true, new users are marked to receive the
+// configured default folders on their first login.
+func createUserFiles(crdtLayerRoot string, maildirRoot string, start int, end int, provision bool) error {
 
 	err := os.MkdirAll(maildirRoot, 0755)
 	if err != nil {
@@ -110,6 +160,10 @@ func createUserFiles(crdtLayerRoot string, maildirRoot string, start int, end in
 
 		structureFile := filepath.Join(crdtFolder, "structure.crdt")
 
+		// Seed the structure with INBOX only. All configured
+		// default folders are created by the worker on first
+		// login via the replicated CREATE path so that all
+		// replicas agree.
 		if !exists(structureFile) {
 
 			data := fmt.Sprintf("SU5CT1g=;%s", uuid.NewV4().String())
@@ -117,6 +171,26 @@ func createUserFiles(crdtLayerRoot string, maildirRoot string, start int, end in
 			if err != nil {
 				return err
 			}
+
+			if provision {
+
+				err := ioutil.WriteFile(filepath.Join(crdtFolder, imap.ProvisionMarker), []byte{}, 0644)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		subscriptionsFile := filepath.Join(crdtFolder, "subscriptions.crdt")
+
+		if !exists(subscriptionsFile) {
+
+			// Subscribe each user to INBOX by default.
+			data := fmt.Sprintf("SU5CT1g=;%s", uuid.NewV4().String())
+			err := ioutil.WriteFile(subscriptionsFile, []byte(data), 0644)
+			if err != nil {
+				return err
+			}
 		}
 	}
 
@@ -145,6 +219,12 @@ func main() {
 	// Set CPUs usable by pluto to all available.
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
+	// The passwd subcommand only produces password
+	// hashes and does not start a node.
+	if (len(os.Args) > 1) && (os.Args[1] == "passwd") {
+		os.Exit(runPasswd(os.Args[2:]))
+	}
+
 	// Parse command-line flag that defines a config path.
 	configFlag := flag.String("config", "config.toml", "Provide path to configuration file in TOML syntax.")
 	loglevelFlag := flag.String("loglevel", "debug", "This flag sets the default logging level.")
@@ -195,6 +275,18 @@ func main() {
 			os.Exit(1)
 		}
 
+		if conf.Distributor.PublicClientCALoc != "" {
+
+			err = crypto.AddClientCAs(publicTLSConfig, conf.Distributor.PublicClientCALoc)
+			if err != nil {
+				level.Error(logger).Log(
+					"msg", "failed to add client CAs to public TLS config",
+					"err", err,
+				)
+				os.Exit(1)
+			}
+		}
+
 		mailSocket, err := tls.Listen("tcp", conf.Distributor.ListenMailAddr, publicTLSConfig)
 		if err != nil {
 			level.Error(logger).Log(
@@ -221,7 +313,38 @@ func main() {
 		}
 
 		var distrS distributor.Service
-		distrS = distributor.NewService(conf.Distributor.Name, logger, plutoMetrics.Distributor, authenticator, intlTLSConfig, conf.Workers, conf.Storage.PublicMailAddr)
+		distrS = distributor.NewService(conf.Distributor.Name, logger, plutoMetrics.Distributor, authenticator, publicTLSConfig, intlTLSConfig, conf.Workers, conf.Storage.PublicMailAddr, conf.IMAP.DisabledCapabilities)
+
+		if conf.Distributor.ListenCleartextAddr != "" {
+
+			// Clients connecting without TLS have
+			// to upgrade via STARTTLS before login.
+			cleartextSocket, err := net.Listen("tcp", conf.Distributor.ListenCleartextAddr)
+			if err != nil {
+				level.Error(logger).Log(
+					"msg", "failed to listen for public mail cleartext connections",
+					"err", err,
+				)
+				os.Exit(1)
+			}
+			defer cleartextSocket.Close()
+
+			level.Info(logger).Log(
+				"msg", "accepting public mail connections requiring STARTTLS",
+				"listen_addr", conf.Distributor.ListenCleartextAddr,
+			)
+
+			go func() {
+
+				if err := distrS.Run(cleartextSocket, conf.IMAP.Greeting); err != nil {
+					level.Error(logger).Log(
+						"msg", "failed to run on cleartext socket",
+						"err", err,
+					)
+					os.Exit(1)
+				}
+			}()
+		}
 
 		if err := distrS.Run(mailSocket, conf.IMAP.Greeting); err != nil {
 			level.Error(logger).Log(
@@ -257,7 +380,7 @@ func main() {
 
 		// Create all non-existent files and folders for
 		// all users this worker is responsible for.
-		err := createUserFiles(wConfig.CRDTLayerRoot, wConfig.MaildirRoot, wConfig.UserStart, wConfig.UserEnd)
+		err := createUserFiles(wConfig.CRDTLayerRoot, wConfig.MaildirRoot, wConfig.UserStart, wConfig.UserEnd, true)
 		if err != nil {
 			level.Error(logger).Log(
 				"msg", "failed to create user files",
@@ -422,7 +545,7 @@ func main() {
 					// Create all non-existent files and folders on
 					// storage for all users the currently examined
 					// worker is responsible for.
-					err := createUserFiles(conf.Storage.CRDTLayerRoot, conf.Storage.MaildirRoot, c.UserStart, c.UserEnd)
+					err := createUserFiles(conf.Storage.CRDTLayerRoot, conf.Storage.MaildirRoot, c.UserStart, c.UserEnd, false)
 					if err != nil {
 						level.Error(logger).Log(
 							"msg", "failed to create user files",
@@ -26,6 +26,7 @@ func NewPlutoMetrics(distributorAddr string) *PlutoMetrics {
 		m.Distributor = &distributor.Metrics{
 			Commands:    discard.NewCounter(),
 			Connections: discard.NewCounter(),
+			Clients:     discard.NewCounter(),
 		}
 	} else {
 		m.Distributor = &distributor.Metrics{
@@ -45,6 +46,14 @@ func NewPlutoMetrics(distributorAddr string) *PlutoMetrics {
 					Help:      "Number of connections opened to pluto",
 				}, nil,
 			),
+			Clients: prometheus.NewCounterFrom(
+				prom.CounterOpts{
+					Namespace: "pluto",
+					Subsystem: "distributor",
+					Name:      "clients_total",
+					Help:      "Number of clients identified via ID",
+				}, []string{"name", "version"},
+			),
 		}
 	}
 
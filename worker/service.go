@@ -28,14 +28,15 @@ type Metrics struct {
 }
 
 type service struct {
-	tlsConfig    *tls.Config
-	config       config.Worker
-	mailboxes    map[string]*imap.Mailbox
-	sessions     map[string]*imap.Session
-	sessionsLock *sync.RWMutex
-	Name         string
-	IMAPNodeGRPC *grpc.Server
-	SyncSendChan chan comm.Msg
+	tlsConfig      *tls.Config
+	config         config.Worker
+	mailboxes      map[string]*imap.Mailbox
+	sessions       map[string]*imap.Session
+	sessionsLock   *sync.RWMutex
+	defaultFolders []config.DefaultFolder
+	Name           string
+	IMAPNodeGRPC   *grpc.Server
+	SyncSendChan   chan comm.Msg
 }
 
 // Interfaces
@@ -81,6 +82,10 @@ type Service interface {
 	// a message specifying the awaited number of bytes.
 	AppendBegin(ctx context.Context, comd *imap.Command) (*imap.Await, error)
 
+	// AppendNext receives the mail file of one message
+	// of a MULTIAPPEND and the rest of the command.
+	AppendNext(ctx context.Context, comd *imap.MailFile) (*imap.Await, error)
+
 	// AppendEnd receives the mail file associated with a
 	// prior AppendBegin.
 	AppendEnd(ctx context.Context, comd *imap.MailFile) (*imap.Reply, error)
@@ -98,6 +103,88 @@ type Service interface {
 	// of flags to change in those messages and changes the
 	// attributes for these mails throughout the system.
 	Store(ctx context.Context, comd *imap.Command) (*imap.Reply, error)
+
+	// Fetch takes in message sequence numbers and data
+	// items and returns the requested parts of the messages.
+	// Fetching content without PEEK sets the \Seen flag.
+	Fetch(ctx context.Context, comd *imap.Command) (*imap.Reply, error)
+
+	// Search returns the message sequence numbers of
+	// all messages matching the supplied search keys.
+	Search(ctx context.Context, comd *imap.Command) (*imap.Reply, error)
+
+	// UID executes FETCH, STORE, SEARCH, EXPUNGE, COPY, or MOVE
+	// with messages referenced by their UIDs.
+	UID(ctx context.Context, comd *imap.Command) (*imap.Reply, error)
+
+	// Copy duplicates messages of the selected
+	// mailbox into another mailbox.
+	Copy(ctx context.Context, comd *imap.Command) (*imap.Reply, error)
+
+	// Move transfers messages of the selected
+	// mailbox to another mailbox.
+	Move(ctx context.Context, comd *imap.Command) (*imap.Reply, error)
+
+	// Rename changes the name of a mailbox
+	// and all of its inferiors.
+	Rename(ctx context.Context, comd *imap.Command) (*imap.Reply, error)
+
+	// Examine selects a mailbox read-only.
+	Examine(ctx context.Context, comd *imap.Command) (*imap.Reply, error)
+
+	// Status returns status items of a mailbox
+	// without selecting it.
+	Status(ctx context.Context, comd *imap.Command) (*imap.Reply, error)
+
+	// CloseMailbox expunges and deselects the
+	// selected mailbox.
+	CloseMailbox(ctx context.Context, comd *imap.Command) (*imap.Reply, error)
+
+	// Unselect deselects the selected mailbox
+	// without expunging it.
+	Unselect(ctx context.Context, comd *imap.Command) (*imap.Reply, error)
+
+	// Check requests a checkpoint of the
+	// selected mailbox.
+	Check(ctx context.Context, comd *imap.Command) (*imap.Reply, error)
+
+	// Noop returns pending updates of the
+	// selected mailbox.
+	Noop(ctx context.Context, comd *imap.Command) (*imap.Reply, error)
+
+	// Idle streams changes of the selected mailbox
+	// to the client until the stream is cancelled.
+	Idle(comd *imap.Command, stream imap.Node_IdleServer) error
+
+	// Subscribe adds a mailbox to the
+	// subscribed mailboxes of the user.
+	Subscribe(ctx context.Context, comd *imap.Command) (*imap.Reply, error)
+
+	// Unsubscribe removes a mailbox from the
+	// subscribed mailboxes of the user.
+	Unsubscribe(ctx context.Context, comd *imap.Command) (*imap.Reply, error)
+
+	// Lsub lists the subscribed
+	// mailboxes of the user.
+	Lsub(ctx context.Context, comd *imap.Command) (*imap.Reply, error)
+
+	// Enable turns on the requested
+	// extensions for the session.
+	Enable(ctx context.Context, comd *imap.Command) (*imap.Reply, error)
+
+	// Namespace returns the namespaces of the user's
+	// mailbox as defined in RFC 2342.
+	Namespace(ctx context.Context, comd *imap.Command) (*imap.Reply, error)
+
+	// Sort returns the message sequence numbers of all
+	// messages matching the supplied search keys in
+	// the order of the supplied sort criteria.
+	Sort(ctx context.Context, comd *imap.Command) (*imap.Reply, error)
+
+	// Thread returns the message sequence numbers of
+	// all messages matching the supplied search keys
+	// arranged in threads.
+	Thread(ctx context.Context, comd *imap.Command) (*imap.Reply, error)
 }
 
 // Functions
@@ -108,12 +195,13 @@ type Service interface {
 func NewService(name string, tlsConfig *tls.Config, config *config.Config) Service {
 
 	return &service{
-		tlsConfig:    tlsConfig,
-		config:       config.Workers[name],
-		mailboxes:    make(map[string]*imap.Mailbox),
-		sessions:     make(map[string]*imap.Session),
-		sessionsLock: &sync.RWMutex{},
-		Name:         name,
+		tlsConfig:      tlsConfig,
+		config:         config.Workers[name],
+		mailboxes:      make(map[string]*imap.Mailbox),
+		sessions:       make(map[string]*imap.Session),
+		sessionsLock:   &sync.RWMutex{},
+		defaultFolders: config.IMAP.DefaultFolders,
+		Name:           name,
 	}
 }
 
@@ -171,10 +259,96 @@ func (s *service) constructState(logger log.Logger, sep string) error {
 				return fmt.Errorf("reading structure CRDT failed: %v", err)
 			}
 
+			// Read in subscription CRDT from file or
+			// start with an empty one if not yet present.
+			var subscriptionsCRDT *crdt.ORSet
+			subscriptionsFile := filepath.Join(folder, "subscriptions.crdt")
+
+			_, err = os.Stat(subscriptionsFile)
+			if os.IsNotExist(err) {
+				subscriptionsCRDT, err = crdt.InitORSetWithFile(subscriptionsFile)
+			} else {
+				subscriptionsCRDT, err = crdt.InitORSetFromFile(subscriptionsFile)
+			}
+			if err != nil {
+				return fmt.Errorf("reading subscription CRDT failed: %v", err)
+			}
+
+			// Read in special-use CRDT from file or start
+			// with an empty one if not yet present.
+			var specialUseCRDT *crdt.ORSet
+			specialUseFile := filepath.Join(folder, "specialuse.crdt")
+
+			_, err = os.Stat(specialUseFile)
+			if os.IsNotExist(err) {
+				specialUseCRDT, err = crdt.InitORSetWithFile(specialUseFile)
+			} else {
+				specialUseCRDT, err = crdt.InitORSetFromFile(specialUseFile)
+			}
+			if err != nil {
+				return fmt.Errorf("reading special-use CRDT failed: %v", err)
+			}
+
+			// Read in UID CRDT from file or start
+			// with an empty one if not yet present.
+			var uidsCRDT *crdt.UIDSet
+			uidsFile := filepath.Join(folder, "uids.crdt")
+
+			// Only mails present before UIDs were
+			// introduced may lack a UID.
+			_, err = os.Stat(uidsFile)
+			newUIDs := os.IsNotExist(err)
+			if newUIDs {
+				uidsCRDT, err = crdt.InitUIDSetWithFile(uidsFile)
+			} else {
+				uidsCRDT, err = crdt.InitUIDSetFromFile(uidsFile)
+			}
+			if err != nil {
+				return fmt.Errorf("reading UID CRDT failed: %v", err)
+			}
+
+			// Read in keyword CRDT from file or start
+			// with an empty one if not yet present.
+			var keywordsCRDT *crdt.KeywordSet
+			keywordsFile := filepath.Join(folder, "keywords.crdt")
+
+			_, err = os.Stat(keywordsFile)
+			if os.IsNotExist(err) {
+				keywordsCRDT, err = crdt.InitKeywordSetWithFile(keywordsFile)
+			} else {
+				keywordsCRDT, err = crdt.InitKeywordSetFromFile(keywordsFile)
+			}
+			if err != nil {
+				return fmt.Errorf("reading keyword CRDT failed: %v", err)
+			}
+
+			// Read in MODSEQ state from file or start
+			// with an empty one if not yet present.
+			var modSeqsCRDT *crdt.ModSeqs
+			modSeqsFile := filepath.Join(folder, "modseqs.crdt")
+
+			_, err = os.Stat(modSeqsFile)
+			if os.IsNotExist(err) {
+				modSeqsCRDT, err = crdt.InitModSeqsWithFile(modSeqsFile)
+			} else {
+				modSeqsCRDT, err = crdt.InitModSeqsFromFile(modSeqsFile)
+			}
+			if err != nil {
+				return fmt.Errorf("reading MODSEQ CRDT failed: %v", err)
+			}
+
 			s.mailboxes[userName] = &imap.Mailbox{
 				Logger:             logger,
 				Lock:               &sync.RWMutex{},
 				Structure:          structureCRDT,
+				Subscriptions:      subscriptionsCRDT,
+				SpecialUse:         specialUseCRDT,
+				UIDs:               uidsCRDT,
+				Keywords:           keywordsCRDT,
+				ModSeqs:            modSeqsCRDT,
+				Index:              imap.NewMessageIndex(),
+				Watchers:           imap.NewWatchers(),
+				Sessions:           make(map[*imap.Session]bool),
 				Mails:              make(map[string][]string),
 				CRDTPath:           filepath.Join(s.config.CRDTLayerRoot, userName),
 				MaildirPath:        filepath.Join(s.config.MaildirRoot, userName),
@@ -191,15 +365,18 @@ func (s *service) constructState(logger log.Logger, sep string) error {
 				// Prepare some space for found mail files.
 				s.mailboxes[userName].Mails[mailboxFolder] = make([]string, 0, 6)
 
-				if mailboxFolder == "INBOX" {
-					mailboxFolderCur = filepath.Join(s.config.MaildirRoot, userName, "cur")
-				} else {
-					mailboxFolderCur = filepath.Join(s.config.MaildirRoot, userName, mailboxFolder, "cur")
+				// Move folders still stored under their
+				// plain name to their Maildir++ folder.
+				err := s.mailboxes[userName].MigrateFolder(mailboxFolder)
+				if err != nil {
+					return err
 				}
 
+				mailboxFolderCur = filepath.Join(s.mailboxes[userName].FolderPath(mailboxFolder), "cur")
+
 				// Read file system content (mail messages)
 				// into internal state.
-				err := filepath.Walk(mailboxFolderCur, func(path string, info os.FileInfo, err error) error {
+				err = filepath.Walk(mailboxFolderCur, func(path string, info os.FileInfo, err error) error {
 
 					if err != nil {
 						return err
@@ -220,6 +397,12 @@ func (s *service) constructState(logger log.Logger, sep string) error {
 				if err != nil {
 					return fmt.Errorf("error while walking user Maildir: %v", err)
 				}
+
+				// Order mails by their UIDs.
+				err = s.mailboxes[userName].TrackMails(mailboxFolder, newUIDs)
+				if err != nil {
+					return err
+				}
 			}
 		}
 	}
@@ -266,6 +449,26 @@ func (s *service) ApplyCRDTUpd(applyChan <-chan comm.Msg, doneChan chan<- struct
 		case "store":
 			mailbox := s.mailboxes[msg.Store.User]
 			mailbox.ApplyStore(msg.Store)
+
+		case "copy":
+			mailbox := s.mailboxes[msg.Copy.User]
+			mailbox.ApplyCopy(msg.Copy)
+
+		case "move":
+			mailbox := s.mailboxes[msg.Move.User]
+			mailbox.ApplyMove(msg.Move)
+
+		case "rename":
+			mailbox := s.mailboxes[msg.Rename.User]
+			mailbox.ApplyRename(msg.Rename)
+
+		case "subscribe":
+			mailbox := s.mailboxes[msg.Subscribe.User]
+			mailbox.ApplySubscribe(msg.Subscribe)
+
+		case "unsubscribe":
+			mailbox := s.mailboxes[msg.Unsubscribe.User]
+			mailbox.ApplyUnsubscribe(msg.Unsubscribe)
 		}
 
 		// Signal receiver that an update was performed.
@@ -285,7 +488,7 @@ func (s *service) Prepare(ctx context.Context, clientCtx *imap.Context) (*imap.C
 	s.sessionsLock.Lock()
 
 	// Create new connection tracking object.
-	s.sessions[clientCtx.ClientID] = &imap.Session{
+	sess := &imap.Session{
 		State:             imap.StateAuthenticated,
 		ClientID:          clientCtx.ClientID,
 		UserName:          clientCtx.UserName,
@@ -294,8 +497,24 @@ func (s *service) Prepare(ctx context.Context, clientCtx *imap.Context) (*imap.C
 		AppendInProg:      nil,
 	}
 
+	prevSess, found := s.sessions[clientCtx.ClientID]
+	s.sessions[clientCtx.ClientID] = sess
+
 	s.sessionsLock.Unlock()
 
+	// Register session with the user's mailbox so
+	// that it learns about its selected folder being
+	// renamed by other sessions. A session replaced
+	// by a reconnecting client is not needed anymore.
+	if found {
+		s.mailboxes[prevSess.UserName].RemoveSession(prevSess)
+	}
+	s.mailboxes[clientCtx.UserName].AddSession(sess)
+
+	// Create default folders of a new user. Only the
+	// worker does so, storage learns of them via CRDT.
+	s.mailboxes[clientCtx.UserName].Provision(clientCtx.UserName, s.defaultFolders, s.SyncSendChan)
+
 	return &imap.Confirmation{
 		Status: 0,
 	}, nil
@@ -307,11 +526,17 @@ func (s *service) Close(ctx context.Context, clientCtx *imap.Context) (*imap.Con
 
 	s.sessionsLock.Lock()
 
+	sess, found := s.sessions[clientCtx.ClientID]
+
 	// Delete connection-tracking object from sessions map.
 	delete(s.sessions, clientCtx.ClientID)
 
 	s.sessionsLock.Unlock()
 
+	if found {
+		s.mailboxes[sess.UserName].RemoveSession(sess)
+	}
+
 	return &imap.Confirmation{
 		Status: 0,
 	}, nil
@@ -441,7 +666,33 @@ func (s *service) AppendBegin(ctx context.Context, comd *imap.Command) (*imap.Aw
 	}
 
 	// Forward gathered info to IMAP function.
-	await, err := s.mailboxes[sess.UserName].AppendBegin(sess, req)
+	await, err := s.mailboxes[sess.UserName].AppendBegin(sess, req, s.SyncSendChan)
+
+	return await, err
+}
+
+// AppendNext receives the mail file of one message
+// of a MULTIAPPEND and the rest of the command.
+func (s *service) AppendNext(ctx context.Context, mailFile *imap.MailFile) (*imap.Await, error) {
+
+	s.sessionsLock.RLock()
+
+	// Retrieve active IMAP connection context
+	// from map of all known to this node.
+	sess := s.sessions[mailFile.ClientID]
+
+	s.sessionsLock.RUnlock()
+
+	// Make sure that an APPEND is actually in progress.
+	if (sess.AppendInProg == nil) || (sess.AppendInProg.Pending == nil) {
+
+		return &imap.Await{
+			Status: 1,
+		}, fmt.Errorf("no APPEND in progress for client %s but AppendNext was invoked", mailFile.ClientID)
+	}
+
+	// Forward gathered info to IMAP function.
+	await, err := s.mailboxes[sess.UserName].AppendNext(sess, mailFile.Content, mailFile.Text, s.SyncSendChan)
 
 	return await, err
 }
@@ -459,7 +710,7 @@ func (s *service) AppendEnd(ctx context.Context, mailFile *imap.MailFile) (*imap
 	s.sessionsLock.RUnlock()
 
 	// Make sure that an APPEND is actually in progress.
-	if sess.AppendInProg == nil {
+	if (sess.AppendInProg == nil) || (sess.AppendInProg.Pending == nil) {
 
 		return &imap.Reply{
 			Status: 1,
@@ -546,3 +797,527 @@ func (s *service) Store(ctx context.Context, comd *imap.Command) (*imap.Reply, e
 
 	return reply, err
 }
+
+// Fetch takes in message sequence numbers and data
+// items and returns the requested parts of the messages.
+// Fetching content without PEEK sets the \Seen flag.
+func (s *service) Fetch(ctx context.Context, comd *imap.Command) (*imap.Reply, error) {
+
+	s.sessionsLock.RLock()
+
+	// Retrieve active IMAP connection context
+	// from map of all known to this node.
+	sess := s.sessions[comd.ClientID]
+
+	s.sessionsLock.RUnlock()
+
+	// Parse received raw request into struct.
+	req, err := imap.ParseRequest(comd.Text)
+	if err != nil {
+		return &imap.Reply{
+			Status: 1,
+		}, err
+	}
+
+	// Forward gathered info to IMAP function.
+	reply, err := s.mailboxes[sess.UserName].Fetch(sess, req, s.SyncSendChan)
+
+	return reply, err
+}
+
+// Search returns the message sequence numbers of
+// all messages matching the supplied search keys.
+func (s *service) Search(ctx context.Context, comd *imap.Command) (*imap.Reply, error) {
+
+	s.sessionsLock.RLock()
+
+	// Retrieve active IMAP connection context
+	// from map of all known to this node.
+	sess := s.sessions[comd.ClientID]
+
+	s.sessionsLock.RUnlock()
+
+	// Parse received raw request into struct.
+	req, err := imap.ParseRequest(comd.Text)
+	if err != nil {
+		return &imap.Reply{
+			Status: 1,
+		}, err
+	}
+
+	// Forward gathered info to IMAP function.
+	reply, err := s.mailboxes[sess.UserName].Search(sess, req, s.SyncSendChan)
+
+	return reply, err
+}
+
+// UID executes FETCH, STORE, SEARCH, EXPUNGE, COPY, or MOVE
+// with messages referenced by their UIDs.
+func (s *service) UID(ctx context.Context, comd *imap.Command) (*imap.Reply, error) {
+
+	s.sessionsLock.RLock()
+
+	// Retrieve active IMAP connection context
+	// from map of all known to this node.
+	sess := s.sessions[comd.ClientID]
+
+	s.sessionsLock.RUnlock()
+
+	// Parse received raw request into struct.
+	req, err := imap.ParseRequest(comd.Text)
+	if err != nil {
+		return &imap.Reply{
+			Status: 1,
+		}, err
+	}
+
+	// Forward gathered info to IMAP function.
+	reply, err := s.mailboxes[sess.UserName].UID(sess, req, s.SyncSendChan)
+
+	return reply, err
+}
+
+// Copy duplicates messages of the selected mailbox
+// into another mailbox.
+func (s *service) Copy(ctx context.Context, comd *imap.Command) (*imap.Reply, error) {
+
+	s.sessionsLock.RLock()
+
+	// Retrieve active IMAP connection context
+	// from map of all known to this node.
+	sess := s.sessions[comd.ClientID]
+
+	s.sessionsLock.RUnlock()
+
+	// Parse received raw request into struct.
+	req, err := imap.ParseRequest(comd.Text)
+	if err != nil {
+		return &imap.Reply{
+			Status: 1,
+		}, err
+	}
+
+	// Forward gathered info to IMAP function.
+	reply, err := s.mailboxes[sess.UserName].Copy(sess, req, s.SyncSendChan)
+
+	return reply, err
+}
+
+// Move transfers messages of the selected mailbox
+// to another mailbox.
+func (s *service) Move(ctx context.Context, comd *imap.Command) (*imap.Reply, error) {
+
+	s.sessionsLock.RLock()
+
+	// Retrieve active IMAP connection context
+	// from map of all known to this node.
+	sess := s.sessions[comd.ClientID]
+
+	s.sessionsLock.RUnlock()
+
+	// Parse received raw request into struct.
+	req, err := imap.ParseRequest(comd.Text)
+	if err != nil {
+		return &imap.Reply{
+			Status: 1,
+		}, err
+	}
+
+	// Forward gathered info to IMAP function.
+	reply, err := s.mailboxes[sess.UserName].Move(sess, req, s.SyncSendChan)
+
+	return reply, err
+}
+
+// Rename changes the name of a mailbox and
+// all of its inferiors.
+func (s *service) Rename(ctx context.Context, comd *imap.Command) (*imap.Reply, error) {
+
+	s.sessionsLock.RLock()
+
+	// Retrieve active IMAP connection context
+	// from map of all known to this node.
+	sess := s.sessions[comd.ClientID]
+
+	s.sessionsLock.RUnlock()
+
+	// Parse received raw request into struct.
+	req, err := imap.ParseRequest(comd.Text)
+	if err != nil {
+		return &imap.Reply{
+			Status: 1,
+		}, err
+	}
+
+	// Forward gathered info to IMAP function.
+	reply, err := s.mailboxes[sess.UserName].Rename(sess, req, s.SyncSendChan)
+
+	return reply, err
+}
+
+// Examine selects a mailbox read-only.
+func (s *service) Examine(ctx context.Context, comd *imap.Command) (*imap.Reply, error) {
+
+	s.sessionsLock.RLock()
+
+	// Retrieve active IMAP connection context
+	// from map of all known to this node.
+	sess := s.sessions[comd.ClientID]
+
+	s.sessionsLock.RUnlock()
+
+	// Parse received raw request into struct.
+	req, err := imap.ParseRequest(comd.Text)
+	if err != nil {
+		return &imap.Reply{
+			Status: 1,
+		}, err
+	}
+
+	// Forward gathered info to IMAP function.
+	reply, err := s.mailboxes[sess.UserName].Examine(sess, req, s.SyncSendChan)
+
+	return reply, err
+}
+
+// Status returns status items of a mailbox
+// without selecting it.
+func (s *service) Status(ctx context.Context, comd *imap.Command) (*imap.Reply, error) {
+
+	s.sessionsLock.RLock()
+
+	// Retrieve active IMAP connection context
+	// from map of all known to this node.
+	sess := s.sessions[comd.ClientID]
+
+	s.sessionsLock.RUnlock()
+
+	// Parse received raw request into struct.
+	req, err := imap.ParseRequest(comd.Text)
+	if err != nil {
+		return &imap.Reply{
+			Status: 1,
+		}, err
+	}
+
+	// Forward gathered info to IMAP function.
+	reply, err := s.mailboxes[sess.UserName].Status(sess, req, s.SyncSendChan)
+
+	return reply, err
+}
+
+// CloseMailbox expunges and deselects the
+// selected mailbox.
+func (s *service) CloseMailbox(ctx context.Context, comd *imap.Command) (*imap.Reply, error) {
+
+	s.sessionsLock.RLock()
+
+	// Retrieve active IMAP connection context
+	// from map of all known to this node.
+	sess := s.sessions[comd.ClientID]
+
+	s.sessionsLock.RUnlock()
+
+	// Parse received raw request into struct.
+	req, err := imap.ParseRequest(comd.Text)
+	if err != nil {
+		return &imap.Reply{
+			Status: 1,
+		}, err
+	}
+
+	// Forward gathered info to IMAP function.
+	reply, err := s.mailboxes[sess.UserName].Close(sess, req, s.SyncSendChan)
+
+	return reply, err
+}
+
+// Unselect deselects the selected mailbox
+// without expunging it.
+func (s *service) Unselect(ctx context.Context, comd *imap.Command) (*imap.Reply, error) {
+
+	s.sessionsLock.RLock()
+
+	// Retrieve active IMAP connection context
+	// from map of all known to this node.
+	sess := s.sessions[comd.ClientID]
+
+	s.sessionsLock.RUnlock()
+
+	// Parse received raw request into struct.
+	req, err := imap.ParseRequest(comd.Text)
+	if err != nil {
+		return &imap.Reply{
+			Status: 1,
+		}, err
+	}
+
+	// Forward gathered info to IMAP function.
+	reply, err := s.mailboxes[sess.UserName].Unselect(sess, req, s.SyncSendChan)
+
+	return reply, err
+}
+
+// Check requests a checkpoint of the
+// selected mailbox.
+func (s *service) Check(ctx context.Context, comd *imap.Command) (*imap.Reply, error) {
+
+	s.sessionsLock.RLock()
+
+	// Retrieve active IMAP connection context
+	// from map of all known to this node.
+	sess := s.sessions[comd.ClientID]
+
+	s.sessionsLock.RUnlock()
+
+	// Parse received raw request into struct.
+	req, err := imap.ParseRequest(comd.Text)
+	if err != nil {
+		return &imap.Reply{
+			Status: 1,
+		}, err
+	}
+
+	// Forward gathered info to IMAP function.
+	reply, err := s.mailboxes[sess.UserName].Check(sess, req, s.SyncSendChan)
+
+	return reply, err
+}
+
+// Noop returns pending updates of the
+// selected mailbox.
+func (s *service) Noop(ctx context.Context, comd *imap.Command) (*imap.Reply, error) {
+
+	s.sessionsLock.RLock()
+
+	// Retrieve active IMAP connection context
+	// from map of all known to this node.
+	sess := s.sessions[comd.ClientID]
+
+	s.sessionsLock.RUnlock()
+
+	// Parse received raw request into struct.
+	req, err := imap.ParseRequest(comd.Text)
+	if err != nil {
+		return &imap.Reply{
+			Status: 1,
+		}, err
+	}
+
+	// Forward gathered info to IMAP function.
+	reply, err := s.mailboxes[sess.UserName].Noop(sess, req, s.SyncSendChan)
+
+	return reply, err
+}
+
+// Idle streams changes of the selected mailbox
+// to the client until the stream is cancelled.
+func (s *service) Idle(comd *imap.Command, stream imap.Node_IdleServer) error {
+
+	s.sessionsLock.RLock()
+
+	// Retrieve active IMAP connection context
+	// from map of all known to this node.
+	sess := s.sessions[comd.ClientID]
+
+	s.sessionsLock.RUnlock()
+
+	// Parse received raw request into struct.
+	req, err := imap.ParseRequest(comd.Text)
+	if err != nil {
+		return err
+	}
+
+	// Forward gathered info to IMAP function and
+	// stop idling as soon as the distributor
+	// cancels the stream.
+	return s.mailboxes[sess.UserName].Idle(sess, req, stream.Context().Done(), func(text string) error {
+		return stream.Send(&imap.Reply{
+			Text: text,
+		})
+	})
+}
+
+// Subscribe adds a mailbox to the
+// subscribed mailboxes of the user.
+func (s *service) Subscribe(ctx context.Context, comd *imap.Command) (*imap.Reply, error) {
+
+	s.sessionsLock.RLock()
+
+	// Retrieve active IMAP connection context
+	// from map of all known to this node.
+	sess := s.sessions[comd.ClientID]
+
+	s.sessionsLock.RUnlock()
+
+	// Parse received raw request into struct.
+	req, err := imap.ParseRequest(comd.Text)
+	if err != nil {
+		return &imap.Reply{
+			Status: 1,
+		}, err
+	}
+
+	// Forward gathered info to IMAP function.
+	reply, err := s.mailboxes[sess.UserName].Subscribe(sess, req, s.SyncSendChan)
+
+	return reply, err
+}
+
+// Unsubscribe removes a mailbox from the
+// subscribed mailboxes of the user.
+func (s *service) Unsubscribe(ctx context.Context, comd *imap.Command) (*imap.Reply, error) {
+
+	s.sessionsLock.RLock()
+
+	// Retrieve active IMAP connection context
+	// from map of all known to this node.
+	sess := s.sessions[comd.ClientID]
+
+	s.sessionsLock.RUnlock()
+
+	// Parse received raw request into struct.
+	req, err := imap.ParseRequest(comd.Text)
+	if err != nil {
+		return &imap.Reply{
+			Status: 1,
+		}, err
+	}
+
+	// Forward gathered info to IMAP function.
+	reply, err := s.mailboxes[sess.UserName].Unsubscribe(sess, req, s.SyncSendChan)
+
+	return reply, err
+}
+
+// Lsub lists the subscribed
+// mailboxes of the user.
+func (s *service) Lsub(ctx context.Context, comd *imap.Command) (*imap.Reply, error) {
+
+	s.sessionsLock.RLock()
+
+	// Retrieve active IMAP connection context
+	// from map of all known to this node.
+	sess := s.sessions[comd.ClientID]
+
+	s.sessionsLock.RUnlock()
+
+	// Parse received raw request into struct.
+	req, err := imap.ParseRequest(comd.Text)
+	if err != nil {
+		return &imap.Reply{
+			Status: 1,
+		}, err
+	}
+
+	// Forward gathered info to IMAP function.
+	reply, err := s.mailboxes[sess.UserName].Lsub(sess, req, s.SyncSendChan)
+
+	return reply, err
+}
+
+// Enable turns on the requested
+// extensions for the session.
+func (s *service) Enable(ctx context.Context, comd *imap.Command) (*imap.Reply, error) {
+
+	s.sessionsLock.RLock()
+
+	// Retrieve active IMAP connection context
+	// from map of all known to this node.
+	sess := s.sessions[comd.ClientID]
+
+	s.sessionsLock.RUnlock()
+
+	// Parse received raw request into struct.
+	req, err := imap.ParseRequest(comd.Text)
+	if err != nil {
+		return &imap.Reply{
+			Status: 1,
+		}, err
+	}
+
+	// Forward gathered info to IMAP function.
+	reply, err := s.mailboxes[sess.UserName].Enable(sess, req, s.SyncSendChan)
+
+	return reply, err
+}
+
+// Namespace returns the namespaces of the user's
+// mailbox as defined in RFC 2342.
+func (s *service) Namespace(ctx context.Context, comd *imap.Command) (*imap.Reply, error) {
+
+	s.sessionsLock.RLock()
+
+	// Retrieve active IMAP connection context
+	// from map of all known to this node.
+	sess := s.sessions[comd.ClientID]
+
+	s.sessionsLock.RUnlock()
+
+	// Parse received raw request into struct.
+	req, err := imap.ParseRequest(comd.Text)
+	if err != nil {
+		return &imap.Reply{
+			Status: 1,
+		}, err
+	}
+
+	// Forward gathered info to IMAP function.
+	reply, err := s.mailboxes[sess.UserName].Namespace(sess, req, s.SyncSendChan)
+
+	return reply, err
+}
+
+// Sort returns the message sequence numbers of all
+// messages matching the supplied search keys in
+// the order of the supplied sort criteria.
+func (s *service) Sort(ctx context.Context, comd *imap.Command) (*imap.Reply, error) {
+
+	s.sessionsLock.RLock()
+
+	// Retrieve active IMAP connection context
+	// from map of all known to this node.
+	sess := s.sessions[comd.ClientID]
+
+	s.sessionsLock.RUnlock()
+
+	// Parse received raw request into struct.
+	req, err := imap.ParseRequest(comd.Text)
+	if err != nil {
+		return &imap.Reply{
+			Status: 1,
+		}, err
+	}
+
+	// Forward gathered info to IMAP function.
+	reply, err := s.mailboxes[sess.UserName].Sort(sess, req, s.SyncSendChan)
+
+	return reply, err
+}
+
+// Thread returns the message sequence numbers of
+// all messages matching the supplied search keys
+// arranged in threads.
+func (s *service) Thread(ctx context.Context, comd *imap.Command) (*imap.Reply, error) {
+
+	s.sessionsLock.RLock()
+
+	// Retrieve active IMAP connection context
+	// from map of all known to this node.
+	sess := s.sessions[comd.ClientID]
+
+	s.sessionsLock.RUnlock()
+
+	// Parse received raw request into struct.
+	req, err := imap.ParseRequest(comd.Text)
+	if err != nil {
+		return &imap.Reply{
+			Status: 1,
+		}, err
+	}
+
+	// Forward gathered info to IMAP function.
+	reply, err := s.mailboxes[sess.UserName].Thread(sess, req, s.SyncSendChan)
+
+	return reply, err
+}